@@ -1,63 +1,266 @@
 package main
 
 import (
-	"log"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/jmoiron/sqlx"
-	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/config"
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
 	"github.com/arturo/autohost-cloud-api/internal/grpc/nodepb"
 	"github.com/arturo/autohost-cloud-api/internal/handler"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/postgres"
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
 )
 
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system environment")
+	logging.Setup(os.Stdout, slog.LevelInfo)
+
+	printConfig := flag.Bool("print-config", false, "print the effective configuration with secrets redacted and exit")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+	if *printConfig {
+		cfg.Print(os.Stdout)
+		if err := cfg.Validate(); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+			os.Exit(1)
+		}
+		return
 	}
+	// Todo lo que falte se detecta aquí y no en la primera petición
+	if err := cfg.Validate(); err != nil {
+		fatal("invalid configuration", err)
+	}
+	level, _ := logging.ParseLevel(cfg.LogLevel)
+	logging.SetLevel(level)
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		log.Fatal("DATABASE_URL not set")
+	exporter, err := tracing.NewExporter(cfg.Tracing.Exporter, os.Stdout)
+	if err != nil {
+		fatal("configure tracing", err)
 	}
+	shutdownTracing := tracing.Setup(exporter, cfg.Tracing.SampleRatio)
 
-	db := sqlx.MustConnect("postgres", dbURL)
+	db, err := postgres.Connect(cfg.DatabaseURL)
+	if err != nil {
+		fatal("connect to database", err)
+	}
 	defer db.Close()
 
-	app := handler.NewRouter(&handler.Config{DB: db})
+	mailer, err := cfg.Mailer()
+	if err != nil {
+		fatal("configure mailer", err)
+	}
 
-	// ── gRPC server ───────────────────────────────────────────────────────────
-	grpcPort := os.Getenv("GRPC_PORT")
-	if grpcPort == "" {
-		grpcPort = "9090"
+	keys := platform.NewKeyRing(cfg.JWTConfig())
+	signingKeys, err := loadSigningKeys(context.Background(), cfg, db, keys)
+	if err != nil {
+		fatal("load JWT signing keys", err)
+	}
+
+	mfaKey, err := cfg.MFAKey()
+	if err != nil {
+		fatal("configure MFA", err)
 	}
 
-	lis, err := net.Listen("tcp", "0.0.0.0:"+grpcPort)
+	var oidcProvider *platform.OIDCProvider
+	if oidcCfg := cfg.OIDCConfig(); oidcCfg != nil {
+		oidcProvider = platform.NewOIDCProvider(*oidcCfg, nil)
+	}
+
+	blobs, err := cfg.BlobStore()
+	if err != nil {
+		fatal("open blob store", err, "dir", cfg.Jobs.BlobDir)
+	}
+	artifacts, err := cfg.ArtifactStore()
 	if err != nil {
-		log.Fatalf("Failed to listen on gRPC port %s: %v", grpcPort, err)
+		fatal("open artifact store", err, "dir", cfg.Artifacts.Dir)
 	}
+	trustedProxies, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		fatal("invalid configuration", err)
+	}
+
+	app := handler.NewRouter(&handler.Config{
+		DB:             db,
+		Mailer:         mailer,
+		Keys:           keys,
+		MFAKey:         mfaKey,
+		OIDC:           oidcProvider,
+		FrontendURL:    cfg.FrontendURL,
+		TrustedProxies: trustedProxies,
+		RefreshTTL:     cfg.JWT.RefreshTTL,
+		AdminToken:     cfg.AdminToken,
+		RequestTimeout: cfg.RequestTimeout,
+
+		JobOutputs:      blobs,
+		JobOutputLimits: cfg.JobOutputLimits(),
+		Artifacts:       artifacts,
+		ArtifactLimits:  cfg.ArtifactLimits(),
+	})
 
-	grpcSrv := grpc.NewServer()
+	// ── gRPC server ───────────────────────────────────────────────────────────
+	grpcAddr := fmt.Sprintf("0.0.0.0:%d", cfg.GRPCPort)
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		fatal("listen on gRPC address", err, "addr", grpcAddr)
+	}
+
+	grpcSrv := grpc.NewServer(
+		// Un resultado lleva hasta JOB_OUTPUT_MAX_SIZE de salida más el resto
+		// del mensaje; el límite por defecto de gRPC son 4 MB
+		grpc.MaxRecvMsgSize(cfg.Jobs.OutputMaxSize+64<<10),
+		grpc.ChainUnaryInterceptor(tracing.UnaryServerInterceptor, logging.UnaryServerInterceptor, apperr.UnaryServerInterceptor),
+		grpc.ChainStreamInterceptor(tracing.StreamServerInterceptor, logging.StreamServerInterceptor, apperr.StreamServerInterceptor),
+	)
 	nodepb.RegisterNodeAgentServiceServer(grpcSrv, app.GRPCServer)
 
+	// Servicio estándar grpc.health.v1 para el balanceador de los agentes:
+	// NOT_SERVING hasta que el servidor arranca y otra vez al apagarse
+	healthSrv := health.NewServer()
+	healthSrv.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	healthSrv.SetServingStatus(nodepb.NodeAgentService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_NOT_SERVING)
+	healthpb.RegisterHealthServer(grpcSrv, healthSrv)
+	app.Health.AddCheck("grpc", func(ctx context.Context) error {
+		resp, err := healthSrv.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			return err
+		}
+		if resp.Status != healthpb.HealthCheckResponse_SERVING {
+			return fmt.Errorf("gRPC server is %s", resp.Status)
+		}
+		return nil
+	})
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	if signingKeys != nil {
+		go reloadSigningKeys(ctx, signingKeys, keys)
+	}
+	go app.RunMaintenance(ctx)
+
 	go func() {
-		log.Printf("gRPC server listening on :%s", grpcPort)
+		slog.Info("gRPC server listening", "addr", grpcAddr)
+		healthSrv.Resume()
 		if err := grpcSrv.Serve(lis); err != nil {
-			log.Fatalf("gRPC server error: %v", err)
+			fatal("gRPC server", err)
 		}
 	}()
 
 	// ── HTTP server ───────────────────────────────────────────────────────────
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	httpSrv := &http.Server{Addr: fmt.Sprintf("0.0.0.0:%d", cfg.HTTPPort), Handler: app.HTTP}
+	go func() {
+		slog.Info("HTTP server listening", "addr", httpSrv.Addr)
+		if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fatal("HTTP server", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop() // a second signal kills the process right away
+
+	slog.Info("shutting down", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+
+	// El balanceador deja de mandar agentes y peticiones antes de nada
+	healthSrv.Shutdown()
+
+	// Los agentes se despiden primero: mientras esperamos los jobs en curso la
+	// API HTTP sigue atendiendo y los streams abiertos siguen recibiendo resultados
+	app.DrainAgents(shutdownCtx)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		if err := httpSrv.Shutdown(shutdownCtx); err != nil {
+			slog.Error("HTTP shutdown", "error", err)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		stopped := make(chan struct{})
+		go func() {
+			grpcSrv.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-shutdownCtx.Done():
+			slog.Warn("gRPC graceful stop timed out, closing remaining streams")
+			grpcSrv.Stop()
+		}
+	}()
+	wg.Wait()
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("flush traces", "error", err)
+	}
+	slog.Info("shutdown complete")
+}
+
+// fatal registra err y termina el proceso
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append(args, "error", err)...)
+	os.Exit(1)
+}
+
+// loadSigningKeys carga las claves de firma de JWT_KEYS_DIR o, si no está
+// definido, de Postgres. En Postgres crea la primera clave si no hay ninguna
+// y devuelve el servicio con el que recargarlas (ver reloadSigningKeys).
+func loadSigningKeys(ctx context.Context, cfg *config.Config, db *sqlx.DB, keys *platform.KeyRing) (*signingkey.Service, error) {
+	if cfg.JWT.KeysDir != "" {
+		return nil, keys.LoadDir(cfg.JWT.KeysDir, cfg.JWT.SigningKeyID)
 	}
 
-	addr := "0.0.0.0:" + port
-	log.Printf("HTTP server listening on %s", addr)
-	log.Fatal(http.ListenAndServe(addr, app.HTTP))
+	encKey, alg, err := cfg.SigningKeyStore()
+	if err != nil {
+		return nil, err
+	}
+	// La clave retirada sigue verificando mientras pueda haber tokens vivos
+	grace := keys.Config().AccessTTL + time.Minute
+	svc := signingkey.NewService(postgres.NewSigningKeyRepository(db), encKey, alg, signingkey.ActivationDelay, grace)
+	if err := svc.EnsureActive(ctx); err != nil {
+		return nil, err
+	}
+	if err := svc.Load(ctx, keys); err != nil {
+		return nil, err
+	}
+	return svc, nil
+}
+
+// reloadSigningKeys recarga las claves hasta que termine ctx, para ver las
+// rotaciones hechas por otra instancia y activar las claves rotadas a tiempo
+func reloadSigningKeys(ctx context.Context, svc *signingkey.Service, keys *platform.KeyRing) {
+	ticker := time.NewTicker(signingkey.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := svc.Load(ctx, keys); err != nil && ctx.Err() == nil {
+				slog.Error("reload signing keys", "error", err)
+			}
+		}
+	}
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+
+	"github.com/arturo/autohost-cloud-api/internal/config"
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/postgres"
+)
+
+// usage:
+// go run ./cmd/jwtkeys list
+// go run ./cmd/jwtkeys rotate
+// go run ./cmd/jwtkeys generate RS256 keys/2025-01-01.pem
+// go run ./cmd/jwtkeys encryption-key
+func main() {
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ invalid configuration: %v", err)
+	}
+	args := flag.Args()
+
+	if len(args) < 1 {
+		log.Fatalf("Usage: jwtkeys [flags] [list|rotate|generate|encryption-key]")
+	}
+
+	switch action := args[0]; action {
+	case "generate":
+		// Para JWT_KEYS_DIR: escribe una clave PEM en un fichero
+		if len(args) < 3 {
+			log.Fatal("Usage: jwtkeys generate <RS256|EdDSA> <file.pem>")
+		}
+		k, err := platform.GenerateSigningKey(args[1])
+		if err != nil {
+			log.Fatalf("❌ generate key: %v", err)
+		}
+		pemBytes, err := platform.MarshalPrivateKeyPEM(k)
+		if err != nil {
+			log.Fatalf("❌ encode key: %v", err)
+		}
+		if err := os.WriteFile(args[2], pemBytes, 0o600); err != nil {
+			log.Fatalf("❌ write key: %v", err)
+		}
+		fmt.Println("✅ Key written to", args[2])
+
+	case "encryption-key":
+		key, err := platform.GenerateEncryptionKey()
+		if err != nil {
+			log.Fatalf("❌ generate encryption key: %v", err)
+		}
+		fmt.Println(key)
+
+	case "list", "rotate":
+		svc := newService(cfg)
+		if action == "rotate" {
+			k, err := svc.Rotate(context.Background())
+			if err != nil {
+				log.Fatalf("❌ rotate failed: %v", err)
+			}
+			fmt.Println("✅ New signing key", k.ID, "signs from", k.ActivatesAt.Format(time.RFC3339))
+			return
+		}
+		keys, err := svc.List(context.Background())
+		if err != nil {
+			log.Fatalf("❌ list failed: %v", err)
+		}
+		for _, k := range keys {
+			status := "active"
+			switch now := time.Now(); {
+			case k.ActivatesAt.After(now):
+				status = "verify-only, signs from " + k.ActivatesAt.Format(time.RFC3339)
+			case k.RetiredAt != nil && !k.RetiredAt.After(now):
+				status = "verify-only until " + k.ExpiresAt.Format(time.RFC3339)
+			case k.RetiredAt != nil:
+				status = "active until " + k.RetiredAt.Format(time.RFC3339)
+			}
+			fmt.Printf("🔑 %s %s created=%s %s\n", k.ID, k.Algorithm, k.CreatedAt.Format(time.RFC3339), status)
+		}
+
+	default:
+		log.Fatalf("❌ Unknown action: %s", action)
+	}
+}
+
+func newService(cfg *config.Config) *signingkey.Service {
+	if cfg.DatabaseURL == "" {
+		log.Fatal("❌ DATABASE_URL not set")
+	}
+	encKey, alg, err := cfg.SigningKeyStore()
+	if err != nil {
+		log.Fatalf("❌ %v", err)
+	}
+
+	db := sqlx.MustConnect("postgres", cfg.DatabaseURL)
+	grace := cfg.JWT.AccessTTL + time.Minute
+	return signingkey.NewService(postgres.NewSigningKeyRepository(db), encKey, alg, signingkey.ActivationDelay, grace)
+}
@@ -1,38 +1,37 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"log"
 	"os"
 
 	"github.com/golang-migrate/migrate/v4"
-	_ "github.com/golang-migrate/migrate/v4/database/postgres"
-	_ "github.com/golang-migrate/migrate/v4/source/file"
-	"github.com/joho/godotenv"
+
+	"github.com/arturo/autohost-cloud-api/internal/config"
+	"github.com/arturo/autohost-cloud-api/internal/repository/postgres"
 )
 
 // usage:
 // go run ./cmd/migrate up
 // go run ./cmd/migrate down 1
 func main() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("⚠️  No .env file found, using system environment")
+	cfg, err := config.Load(flag.CommandLine, os.Args[1:])
+	if err != nil {
+		log.Fatalf("❌ invalid configuration: %v", err)
 	}
+	args := flag.Args()
 
-	if len(os.Args) < 2 {
-		log.Fatalf("Usage: migrate [up|down|version|force]")
+	if len(args) < 1 {
+		log.Fatalf("Usage: migrate [flags] [up|down|version|force]")
 	}
-	action := os.Args[1]
+	action := args[0]
 
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
+	if cfg.DatabaseURL == "" {
 		log.Fatal("❌ DATABASE_URL not set")
 	}
 
-	m, err := migrate.New(
-		"file://migrations",
-		dbURL,
-	)
+	m, err := postgres.NewMigrator("migrations", cfg.DatabaseURL)
 	if err != nil {
 		log.Fatalf("❌ create migrate instance: %v", err)
 	}
@@ -47,8 +46,8 @@ func main() {
 
 	case "down":
 		steps := 1
-		if len(os.Args) > 2 {
-			fmt.Sscanf(os.Args[2], "%d", &steps)
+		if len(args) > 1 {
+			fmt.Sscanf(args[1], "%d", &steps)
 		}
 		if err := m.Steps(-steps); err != nil {
 			log.Fatalf("❌ migration down failed: %v", err)
@@ -63,11 +62,11 @@ func main() {
 		fmt.Printf("📦 version=%d dirty=%v\n", v, dirty)
 
 	case "force":
-		if len(os.Args) < 3 {
+		if len(args) < 2 {
 			log.Fatal("Usage: migrate force <version>")
 		}
 		var v int
-		fmt.Sscanf(os.Args[2], "%d", &v)
+		fmt.Sscanf(args[1], "%d", &v)
 		if err := m.Force(v); err != nil {
 			log.Fatalf("❌ force failed: %v", err)
 		}
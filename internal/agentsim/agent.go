@@ -0,0 +1,217 @@
+// Package agentsim simulates node agents for tests. A simulated agent
+// enrolls over HTTP, connects over gRPC (usually through an in-memory
+// bufconn listener) or WebSocket, registers commands and answers the jobs the
+// server pushes to it, speaking the same protocol as the real agent.
+package agentsim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+// NodeInfo describes the host an agent enrolls.
+type NodeInfo struct {
+	Hostname     string `json:"hostname"`
+	IPLocal      string `json:"ip_local"`
+	OS           string `json:"os"`
+	Arch         string `json:"arch"`
+	VersionAgent string `json:"version_agent"`
+}
+
+// Enrollment is what the server returns for a successful enrollment.
+type Enrollment struct {
+	NodeID   string `json:"node_id"`
+	APIToken string `json:"api_token"`
+}
+
+// HTTPError is returned by Enroll when the server answers with an error.
+type HTTPError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("enroll: status %d: %s", e.StatusCode, strings.TrimSpace(e.Body))
+}
+
+// Enroll exchanges enrollToken for a node ID and API token by calling
+// POST {baseURL}/v1/enrollments/enroll. A nil client uses http.DefaultClient.
+func Enroll(ctx context.Context, client *http.Client, baseURL, enrollToken string, info NodeInfo) (*Enrollment, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(struct {
+		EnrollToken string `json:"enroll_token"`
+		NodeInfo
+	}{enrollToken, info})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost,
+		strings.TrimSuffix(baseURL, "/")+"/v1/enrollments/enroll", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, &HTTPError{StatusCode: resp.StatusCode, Body: string(b)}
+	}
+	var e Enrollment
+	if err := json.NewDecoder(resp.Body).Decode(&e); err != nil {
+		return nil, fmt.Errorf("enroll: decode response: %w", err)
+	}
+	return &e, nil
+}
+
+// Command is a command the agent offers to the server.
+type Command struct {
+	Name        string
+	Description string
+	Type        nodecommand.CommandType
+	ScriptPath  string
+}
+
+// Job is an execute_job request pushed by the server.
+type Job struct {
+	ID          string
+	CommandName string
+	CommandType nodecommand.CommandType
+	Traceparent string
+	Tracestate  string
+}
+
+// Result is what the agent reports for a job.
+type Result struct {
+	Status job.JobStatus
+	Output string
+	Error  string
+}
+
+// Shutdown is a server_shutdown notice.
+type Shutdown struct {
+	Reason         string
+	ReconnectAfter time.Duration
+}
+
+// Agent is a connected simulated agent, independent of the transport.
+type Agent interface {
+	// RegisterCommands announces cmds to the server.
+	RegisterCommands(ctx context.Context, cmds ...Command) error
+	// NextJob waits for the next job pushed by the server.
+	NextJob(ctx context.Context) (Job, error)
+	// NextShutdown waits for the next server_shutdown notice.
+	NextShutdown(ctx context.Context) (Shutdown, error)
+	// NextSync waits for the next sync_scripts manifest. The server sends
+	// one right after the agent connects.
+	NextSync(ctx context.Context) ([]script.Entry, error)
+	// Report sends the result of a job.
+	Report(ctx context.Context, jobID string, r Result) error
+	// Close disconnects the agent.
+	Close() error
+}
+
+// Handler decides how an agent answers a job.
+type Handler func(Job) Result
+
+// Serve answers every job a receives with h until ctx ends or the
+// connection fails.
+func Serve(ctx context.Context, a Agent, h Handler) error {
+	for {
+		j, err := a.NextJob(ctx)
+		if err != nil {
+			return err
+		}
+		if err := a.Report(ctx, j.ID, h(j)); err != nil {
+			return err
+		}
+	}
+}
+
+// inbox queues the messages pushed by the server until the test reads them.
+type inbox struct {
+	jobs      chan Job
+	shutdowns chan Shutdown
+	syncs     chan []script.Entry
+	done      chan struct{} // closed when the connection ends
+	err       error         // why the connection ended; set before done is closed
+}
+
+func newInbox() *inbox {
+	return &inbox{
+		jobs:      make(chan Job, 64),
+		shutdowns: make(chan Shutdown, 8),
+		syncs:     make(chan []script.Entry, 16),
+		done:      make(chan struct{}),
+	}
+}
+
+func (in *inbox) close(err error) {
+	in.err = err
+	close(in.done)
+}
+
+func (in *inbox) nextJob(ctx context.Context) (Job, error) {
+	// Jobs that arrived before the connection ended are still delivered
+	select {
+	case j := <-in.jobs:
+		return j, nil
+	default:
+	}
+	select {
+	case j := <-in.jobs:
+		return j, nil
+	case <-in.done:
+		return Job{}, in.err
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+}
+
+func (in *inbox) nextShutdown(ctx context.Context) (Shutdown, error) {
+	select {
+	case s := <-in.shutdowns:
+		return s, nil
+	default:
+	}
+	select {
+	case s := <-in.shutdowns:
+		return s, nil
+	case <-in.done:
+		return Shutdown{}, in.err
+	case <-ctx.Done():
+		return Shutdown{}, ctx.Err()
+	}
+}
+
+func (in *inbox) nextSync(ctx context.Context) ([]script.Entry, error) {
+	select {
+	case s := <-in.syncs:
+		return s, nil
+	default:
+	}
+	select {
+	case s := <-in.syncs:
+		return s, nil
+	case <-in.done:
+		return nil, in.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
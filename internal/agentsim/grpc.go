@@ -0,0 +1,246 @@
+package agentsim
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	pb "github.com/arturo/autohost-cloud-api/internal/grpc/nodepb"
+)
+
+// GRPCServer is a gRPC server listening on an in-memory bufconn listener.
+type GRPCServer struct {
+	*grpc.Server
+	lis *bufconn.Listener
+}
+
+// StartGRPC starts a gRPC server on a bufconn listener. register adds the
+// services before the server starts serving.
+func StartGRPC(register func(*grpc.Server), opts ...grpc.ServerOption) *GRPCServer {
+	s := &GRPCServer{Server: grpc.NewServer(opts...), lis: bufconn.Listen(1 << 20)}
+	register(s.Server)
+	go s.Serve(s.lis)
+	return s
+}
+
+// Dial opens a client connection to the server.
+func (s *GRPCServer) Dial() (*grpc.ClientConn, error) {
+	return grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return s.lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+}
+
+// GRPCAgent is a simulated agent that talks to NodeAgentService.
+type GRPCAgent struct {
+	client pb.NodeAgentServiceClient
+	token  string
+
+	cancel context.CancelFunc
+	sendMu sync.Mutex
+	stream pb.NodeAgentService_ConnectClient
+	in     *inbox
+}
+
+// NewGRPCAgent returns an agent that authenticates with apiToken. Call
+// Connect before waiting for jobs; RegisterCommands works without it.
+func NewGRPCAgent(conn grpc.ClientConnInterface, apiToken string) *GRPCAgent {
+	return &GRPCAgent{client: pb.NewNodeAgentServiceClient(conn), token: apiToken}
+}
+
+func (a *GRPCAgent) outgoing(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+a.token)
+}
+
+// Connect opens the Connect stream and starts receiving server messages.
+// The stream stays open until Close, independently of ctx, which only bounds
+// opening it.
+func (a *GRPCAgent) Connect(ctx context.Context) error {
+	streamCtx, cancel := context.WithCancel(a.outgoing(context.Background()))
+	stop := context.AfterFunc(ctx, cancel)
+	stream, err := a.client.Connect(streamCtx)
+	if !stop() || err != nil {
+		cancel()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return err
+	}
+	a.cancel = cancel
+	a.stream = stream
+	a.in = newInbox()
+	go a.recv()
+	return nil
+}
+
+// Wait blocks until the server ends the Connect stream and returns its
+// status (nil on a clean end).
+func (a *GRPCAgent) Wait() error {
+	<-a.in.done
+	return a.in.err
+}
+
+func (a *GRPCAgent) recv() {
+	for {
+		msg, err := a.stream.Recv()
+		if err != nil {
+			a.in.close(err)
+			return
+		}
+		switch p := msg.Payload.(type) {
+		case *pb.ServerMessage_ExecuteJob:
+			ct := nodecommand.CommandTypeDefault
+			if p.ExecuteJob.GetCommandType() == pb.CommandType_COMMAND_TYPE_CUSTOM {
+				ct = nodecommand.CommandTypeCustom
+			}
+			a.in.jobs <- Job{
+				ID:          p.ExecuteJob.GetJobId(),
+				CommandName: p.ExecuteJob.GetCommandName(),
+				CommandType: ct,
+				Traceparent: p.ExecuteJob.GetTraceparent(),
+				Tracestate:  p.ExecuteJob.GetTracestate(),
+			}
+		case *pb.ServerMessage_ServerShutdown:
+			a.in.shutdowns <- Shutdown{
+				Reason:         p.ServerShutdown.GetReason(),
+				ReconnectAfter: time.Duration(p.ServerShutdown.GetReconnectAfterMs()) * time.Millisecond,
+			}
+		case *pb.ServerMessage_SyncScripts:
+			entries := []script.Entry{}
+			for _, ref := range p.SyncScripts.GetScripts() {
+				entries = append(entries, script.Entry{Name: ref.GetName(), Version: int(ref.GetVersion()), SHA256: ref.GetSha256()})
+			}
+			a.in.syncs <- entries
+		}
+	}
+}
+
+// RegisterCommands sends cmds over a RegisterCommands stream.
+func (a *GRPCAgent) RegisterCommands(ctx context.Context, cmds ...Command) error {
+	_, err := a.RegisterCommandsCount(ctx, cmds...)
+	return err
+}
+
+// RegisterCommandsCount is like RegisterCommands but also returns how many
+// commands the server stored.
+func (a *GRPCAgent) RegisterCommandsCount(ctx context.Context, cmds ...Command) (int32, error) {
+	stream, err := a.client.RegisterCommands(a.outgoing(ctx))
+	if err != nil {
+		return 0, err
+	}
+	for _, c := range cmds {
+		t := pb.CommandType_COMMAND_TYPE_DEFAULT
+		if c.Type == nodecommand.CommandTypeCustom {
+			t = pb.CommandType_COMMAND_TYPE_CUSTOM
+		}
+		if err := stream.Send(&pb.RegisterCommandRequest{
+			Name:        c.Name,
+			Description: c.Description,
+			Type:        t,
+			ScriptPath:  c.ScriptPath,
+		}); err != nil {
+			break // the real error comes from CloseAndRecv
+		}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetRegistered(), nil
+}
+
+// Artifact is a file uploaded for a job. SHA256 is optional.
+type Artifact struct {
+	JobID       string
+	Name        string
+	ContentType string
+	SHA256      string
+	Data        []byte
+}
+
+// UploadArtifact sends art over an UploadArtifact stream in chunks of
+// chunkSize bytes and returns the server's reply.
+func (a *GRPCAgent) UploadArtifact(ctx context.Context, art Artifact, chunkSize int) (*pb.UploadArtifactResponse, error) {
+	stream, err := a.client.UploadArtifact(a.outgoing(ctx))
+	if err != nil {
+		return nil, err
+	}
+	first := &pb.ArtifactChunk{JobId: art.JobID, Name: art.Name, ContentType: art.ContentType, Sha256: art.SHA256}
+	data := art.Data
+	for first != nil || len(data) > 0 {
+		chunk := first
+		if chunk == nil {
+			chunk = &pb.ArtifactChunk{}
+		}
+		first = nil
+		n := min(chunkSize, len(data))
+		chunk.Data, data = data[:n], data[n:]
+		if err := stream.Send(chunk); err != nil {
+			break // the real error comes from CloseAndRecv
+		}
+	}
+	return stream.CloseAndRecv()
+}
+
+func (a *GRPCAgent) NextJob(ctx context.Context) (Job, error) {
+	return a.in.nextJob(ctx)
+}
+
+func (a *GRPCAgent) NextSync(ctx context.Context) ([]script.Entry, error) {
+	return a.in.nextSync(ctx)
+}
+
+func (a *GRPCAgent) NextShutdown(ctx context.Context) (Shutdown, error) {
+	return a.in.nextShutdown(ctx)
+}
+
+// Report sends a JobResult message.
+func (a *GRPCAgent) Report(ctx context.Context, jobID string, r Result) error {
+	st := pb.JobStatus_JOB_STATUS_COMPLETED
+	switch r.Status {
+	case job.StatusRunning:
+		st = pb.JobStatus_JOB_STATUS_RUNNING
+	case job.StatusFailed:
+		st = pb.JobStatus_JOB_STATUS_FAILED
+	}
+	return a.send(&pb.NodeMessage{Payload: &pb.NodeMessage_JobResult{JobResult: &pb.JobResultPayload{
+		JobId:  jobID,
+		Status: st,
+		Output: r.Output,
+		Error:  r.Error,
+	}}})
+}
+
+// Heartbeat sends a Heartbeat message.
+func (a *GRPCAgent) Heartbeat() error {
+	return a.send(&pb.NodeMessage{Payload: &pb.NodeMessage_Heartbeat{Heartbeat: &pb.HeartbeatPayload{}}})
+}
+
+func (a *GRPCAgent) send(msg *pb.NodeMessage) error {
+	a.sendMu.Lock()
+	defer a.sendMu.Unlock()
+	return a.stream.Send(msg)
+}
+
+// Close ends the Connect stream.
+func (a *GRPCAgent) Close() error {
+	if a.stream == nil {
+		return nil
+	}
+	a.sendMu.Lock()
+	err := a.stream.CloseSend()
+	a.sendMu.Unlock()
+	a.cancel()
+	return err
+}
@@ -0,0 +1,223 @@
+package agentsim
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+// wsMessage mirrors the envelope used by the WebSocket handler.
+type wsMessage struct {
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// WSAgent is a simulated agent connected over WebSocket.
+type WSAgent struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	in      *inbox
+	pongs   chan struct{}
+}
+
+// DialWS connects to GET {baseURL}/v1/ws/ws with apiToken and waits for the
+// server's "connected" message, after which the node can receive jobs.
+// baseURL is an http(s) URL such as httptest.Server.URL.
+func DialWS(ctx context.Context, baseURL, apiToken string) (*WSAgent, error) {
+	u := strings.TrimSuffix(baseURL, "/") + "/v1/ws/ws"
+	u = "ws" + strings.TrimPrefix(u, "http")
+	header := http.Header{"Authorization": {"Bearer " + apiToken}}
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial websocket: %w (status %d)", err, resp.StatusCode)
+		}
+		return nil, fmt.Errorf("dial websocket: %w", err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetReadDeadline(deadline)
+	}
+	var welcome wsMessage
+	if err := conn.ReadJSON(&welcome); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("read welcome message: %w", err)
+	}
+	if welcome.Type != "connected" {
+		conn.Close()
+		return nil, fmt.Errorf("unexpected first message %q", welcome.Type)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	a := &WSAgent{conn: conn, in: newInbox(), pongs: make(chan struct{}, 8)}
+	go a.readLoop()
+	return a, nil
+}
+
+func (a *WSAgent) readLoop() {
+	for {
+		_, data, err := a.conn.ReadMessage()
+		if err != nil {
+			a.in.close(err)
+			return
+		}
+		// The server batches queued messages into one frame, one per line
+		for _, line := range bytes.Split(data, []byte{'\n'}) {
+			var msg wsMessage
+			if err := json.Unmarshal(line, &msg); err != nil {
+				continue
+			}
+			a.handle(msg)
+		}
+	}
+}
+
+func (a *WSAgent) handle(msg wsMessage) {
+	switch msg.Type {
+	case "execute_job":
+		var p struct {
+			JobID       string                  `json:"job_id"`
+			CommandName string                  `json:"command_name"`
+			CommandType nodecommand.CommandType `json:"command_type"`
+			Traceparent string                  `json:"traceparent"`
+			Tracestate  string                  `json:"tracestate"`
+		}
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			a.in.jobs <- Job{
+				ID:          p.JobID,
+				CommandName: p.CommandName,
+				CommandType: p.CommandType,
+				Traceparent: p.Traceparent,
+				Tracestate:  p.Tracestate,
+			}
+		}
+	case "server_shutdown":
+		var p struct {
+			Reason           string `json:"reason"`
+			ReconnectAfterMs int64  `json:"reconnect_after_ms"`
+		}
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			a.in.shutdowns <- Shutdown{
+				Reason:         p.Reason,
+				ReconnectAfter: time.Duration(p.ReconnectAfterMs) * time.Millisecond,
+			}
+		}
+	case "sync_scripts":
+		var p struct {
+			Scripts []script.Entry `json:"scripts"`
+		}
+		if json.Unmarshal(msg.Payload, &p) == nil {
+			if p.Scripts == nil {
+				p.Scripts = []script.Entry{}
+			}
+			a.in.syncs <- p.Scripts
+		}
+	case "pong":
+		select {
+		case a.pongs <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (a *WSAgent) send(msgType string, payload any) error {
+	msg := wsMessage{Type: msgType, Timestamp: time.Now()}
+	if payload != nil {
+		b, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		msg.Payload = b
+	}
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	return a.conn.WriteJSON(msg)
+}
+
+// RegisterCommands sends one register_command message per command. The
+// server does not acknowledge them.
+func (a *WSAgent) RegisterCommands(ctx context.Context, cmds ...Command) error {
+	for _, c := range cmds {
+		if err := a.send("register_command", map[string]any{
+			"name":        c.Name,
+			"description": c.Description,
+			"type":        c.Type,
+			"script_path": c.ScriptPath,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *WSAgent) NextJob(ctx context.Context) (Job, error) {
+	return a.in.nextJob(ctx)
+}
+
+func (a *WSAgent) NextSync(ctx context.Context) ([]script.Entry, error) {
+	return a.in.nextSync(ctx)
+}
+
+func (a *WSAgent) NextShutdown(ctx context.Context) (Shutdown, error) {
+	return a.in.nextShutdown(ctx)
+}
+
+// Report sends a job_result message.
+func (a *WSAgent) Report(ctx context.Context, jobID string, r Result) error {
+	status := r.Status
+	if status == "" {
+		status = job.StatusCompleted
+	}
+	return a.send("job_result", map[string]any{
+		"job_id": jobID,
+		"status": status,
+		"output": r.Output,
+		"error":  r.Error,
+	})
+}
+
+// Ping sends a ping message and waits for the server's pong. Since the
+// server handles messages in order, a pong also means every message sent
+// before the ping has been processed.
+func (a *WSAgent) Ping(ctx context.Context) error {
+	if err := a.send("ping", nil); err != nil {
+		return err
+	}
+	select {
+	case <-a.pongs:
+		return nil
+	case <-a.in.done:
+		return a.in.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Wait blocks until the connection ends and returns why.
+func (a *WSAgent) Wait() error {
+	<-a.in.done
+	return a.in.err
+}
+
+// Close sends a normal close frame and closes the connection.
+func (a *WSAgent) Close() error {
+	a.writeMu.Lock()
+	a.conn.WriteControl(websocket.CloseMessage,
+		websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""),
+		time.Now().Add(time.Second))
+	a.writeMu.Unlock()
+	return a.conn.Close()
+}
@@ -0,0 +1,111 @@
+// Package apperr define los errores tipados que comparten dominio y
+// transporte: cada error lleva un código estable que se traduce a un status
+// HTTP (cuerpos RFC 7807 problem+json) y a un código gRPC desde un único sitio.
+package apperr
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"google.golang.org/grpc/codes"
+)
+
+// Code es el identificador legible por máquina de un tipo de error
+type Code string
+
+const (
+	InvalidArgument    Code = "invalid_argument"
+	Unauthenticated    Code = "unauthenticated"
+	PermissionDenied   Code = "permission_denied"
+	NotFound           Code = "not_found"
+	AlreadyExists      Code = "already_exists"
+	FailedPrecondition Code = "failed_precondition"
+	Gone               Code = "gone"
+	RateLimited        Code = "rate_limited"
+	Unavailable        Code = "unavailable"
+	UpstreamError      Code = "upstream_error"
+	Internal           Code = "internal"
+)
+
+var mapping = map[Code]struct {
+	http int
+	grpc codes.Code
+}{
+	InvalidArgument:    {http.StatusBadRequest, codes.InvalidArgument},
+	Unauthenticated:    {http.StatusUnauthorized, codes.Unauthenticated},
+	PermissionDenied:   {http.StatusForbidden, codes.PermissionDenied},
+	NotFound:           {http.StatusNotFound, codes.NotFound},
+	AlreadyExists:      {http.StatusConflict, codes.AlreadyExists},
+	FailedPrecondition: {http.StatusConflict, codes.FailedPrecondition},
+	Gone:               {http.StatusGone, codes.FailedPrecondition},
+	RateLimited:        {http.StatusTooManyRequests, codes.ResourceExhausted},
+	Unavailable:        {http.StatusServiceUnavailable, codes.Unavailable},
+	UpstreamError:      {http.StatusBadGateway, codes.Unavailable},
+	Internal:           {http.StatusInternalServerError, codes.Internal},
+}
+
+// HTTPStatus devuelve el status HTTP del código (500 si es desconocido)
+func (c Code) HTTPStatus() int {
+	if m, ok := mapping[c]; ok {
+		return m.http
+	}
+	return http.StatusInternalServerError
+}
+
+// GRPCCode devuelve el código gRPC equivalente (Internal si es desconocido)
+func (c Code) GRPCCode() codes.Code {
+	if m, ok := mapping[c]; ok {
+		return m.grpc
+	}
+	return codes.Internal
+}
+
+// Error es un error con código. Los errores de dominio se declaran como
+// variables *Error, así que errors.Is sigue funcionando por identidad.
+type Error struct {
+	Code    Code
+	Message string   // mensaje seguro para el cliente
+	Err     error    // causa interna; nunca se expone
+	Details []string // errores de validación por campo, visibles para el cliente
+}
+
+// New crea un error con código
+func New(code Code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+// Wrap crea un error con código que conserva err como causa
+func Wrap(code Code, message string, err error) *Error {
+	return &Error{Code: code, Message: message, Err: err}
+}
+
+// WithDetails devuelve un error con código que enumera los problemas concretos
+// de la petición (por ejemplo, cada campo que no cumple el esquema)
+func WithDetails(code Code, message string, details []string) *Error {
+	return &Error{Code: code, Message: message, Details: details}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error { return e.Err }
+
+// From extrae el *Error de la cadena de err. Cualquier otro error se trata
+// como Internal, sin exponer su mensaje.
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return Wrap(Internal, "internal error", err)
+}
+
+// CodeOf devuelve el código de err (Internal si no está tipado)
+func CodeOf(err error) Code {
+	return From(err).Code
+}
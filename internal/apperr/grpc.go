@@ -0,0 +1,38 @@
+package apperr
+
+import (
+	"context"
+	"log/slog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCStatus convierte err en un error de status gRPC. Los que ya son status
+// se devuelven tal cual.
+func GRPCStatus(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	e := From(err)
+	msg := e.Message
+	if e.Code == Internal {
+		slog.Error("internal error", "error", err)
+		msg = "internal error"
+	}
+	return status.Error(e.Code.GRPCCode(), msg)
+}
+
+// UnaryServerInterceptor traduce los errores devueltos por los handlers unarios
+func UnaryServerInterceptor(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	resp, err := handler(ctx, req)
+	return resp, GRPCStatus(err)
+}
+
+// StreamServerInterceptor traduce los errores devueltos por los handlers de streams
+func StreamServerInterceptor(srv any, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return GRPCStatus(handler(srv, ss))
+}
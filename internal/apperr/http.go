@@ -0,0 +1,71 @@
+package apperr
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/middleware"
+
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// Problem es un cuerpo RFC 7807 con el código de error como extensión
+type Problem struct {
+	Type      string   `json:"type"`
+	Title     string   `json:"title"`
+	Status    int      `json:"status"`
+	Detail    string   `json:"detail,omitempty"`
+	Instance  string   `json:"instance,omitempty"`
+	Code      Code     `json:"code"`
+	RequestID string   `json:"request_id,omitempty"`
+	Errors    []string `json:"errors,omitempty"`
+}
+
+// ProblemTypeBase es el prefijo del campo type; el resto es el código
+const ProblemTypeBase = "https://autohost.dev/problems/"
+
+// Write responde err como application/problem+json. Al cliente nunca le llega
+// el detalle de un error interno; si tiene causa se registra en el log con el
+// id de la petición.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	e := From(err)
+	status := e.Code.HTTPStatus()
+	reqID := chimiddleware.GetReqID(r.Context())
+
+	detail := e.Message
+	if e.Code == Internal {
+		if e.Err != nil {
+			logging.FromContext(r.Context()).Error("internal error", "error", err)
+		}
+		detail = "internal error"
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Problem{
+		Type:      ProblemTypeBase + string(e.Code),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      e.Code,
+		RequestID: reqID,
+		Errors:    e.Details,
+	})
+}
+
+// Respond es el equivalente de http.Error con cuerpo problem+json
+func Respond(w http.ResponseWriter, r *http.Request, code Code, message string) {
+	Write(w, r, New(code, message))
+}
+
+// TooManyRequests responde 429 con Retry-After en segundos (redondeado hacia arriba)
+func TooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	secs := max(int(math.Ceil(retryAfter.Seconds())), 1)
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	Respond(w, r, RateLimited, "too many requests")
+}
@@ -0,0 +1,50 @@
+// Package blob stores immutable objects addressed by the SHA-256 of their
+// content. Storing the same bytes twice yields the same key and a single
+// copy. FSStore keeps objects on the local filesystem; S3Store keeps them in
+// any S3-compatible object storage through a small ObjectClient interface.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+var (
+	ErrNotFound   = apperr.New(apperr.NotFound, "blob not found")
+	ErrInvalidKey = apperr.New(apperr.InvalidArgument, "invalid blob key")
+)
+
+// Store is a content-addressed object store.
+type Store interface {
+	// Put stores everything read from r and returns its key, the lowercase
+	// hex SHA-256 of the content.
+	Put(ctx context.Context, r io.Reader) (key string, err error)
+	// Open returns the content stored under key, or ErrNotFound.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the object; deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Key returns the key data is stored under.
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidKey reports whether key has the shape of a key returned by Put. Stores
+// check it before using a key in a path.
+func ValidKey(key string) bool {
+	if len(key) != sha256.Size*2 {
+		return false
+	}
+	for _, c := range key {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
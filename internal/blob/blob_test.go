@@ -0,0 +1,127 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// memClient is an in-memory ObjectClient.
+type memClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (c *memClient) PutObject(_ context.Context, key string, body io.ReadSeeker, size int64) error {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return errors.New("size mismatch")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[key] = data
+	return nil
+}
+
+func (c *memClient) GetObject(_ context.Context, key string) (io.ReadCloser, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (c *memClient) DeleteObject(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.objects, key)
+	return nil
+}
+
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	content := strings.Repeat("line of output\n", 1000)
+
+	key, err := s.Put(ctx, strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key != Key([]byte(content)) || !ValidKey(key) {
+		t.Errorf("key = %q, want the SHA-256 of the content", key)
+	}
+	again, err := s.Put(ctx, strings.NewReader(content))
+	if err != nil || again != key {
+		t.Errorf("second Put = %q, %v", again, err)
+	}
+
+	rc, err := s.Open(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(got) != content {
+		t.Errorf("Open returned %d bytes, %v", len(got), err)
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Open after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("second Delete error = %v", err)
+	}
+
+	for _, bad := range []string{"", "../../etc/passwd", strings.Repeat("A", 64), key[:63]} {
+		if _, err := s.Open(ctx, bad); !errors.Is(err, ErrInvalidKey) {
+			t.Errorf("Open(%q) error = %v, want ErrInvalidKey", bad, err)
+		}
+	}
+}
+
+func TestFSStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFSStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+
+	key, err := s.Put(context.Background(), strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, key[:2], key)); err != nil {
+		t.Errorf("object not at its sharded path: %v", err)
+	}
+	if tmp, _ := os.ReadDir(filepath.Join(dir, "tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestS3Store(t *testing.T) {
+	client := &memClient{objects: map[string][]byte{}}
+	testStore(t, NewS3Store(client, "blobs/", t.TempDir()))
+
+	key, err := NewS3Store(client, "blobs/", t.TempDir()).Put(context.Background(), strings.NewReader("x"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.objects["blobs/"+key]; !ok {
+		t.Errorf("object not stored under the prefix: %v", client.objects)
+	}
+}
@@ -0,0 +1,74 @@
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// ObjectClient is the part of an S3-compatible API that S3Store needs. An
+// adapter over the AWS SDK, MinIO or any other client implements it for one
+// bucket.
+type ObjectClient interface {
+	// PutObject uploads size bytes from body under key.
+	PutObject(ctx context.Context, key string, body io.ReadSeeker, size int64) error
+	// GetObject returns the object, or ErrNotFound when there is none.
+	GetObject(ctx context.Context, key string) (io.ReadCloser, error)
+	// DeleteObject removes the object; a missing object is not an error.
+	DeleteObject(ctx context.Context, key string) error
+}
+
+// S3Store keeps objects in S3-compatible storage under prefix+key.
+type S3Store struct {
+	client ObjectClient
+	prefix string
+	tmpDir string
+}
+
+// NewS3Store returns a store over client. Put spools each object to a
+// temporary file in tmpDir ("" for the system default) to learn its key and
+// size before uploading it.
+func NewS3Store(client ObjectClient, prefix, tmpDir string) *S3Store {
+	return &S3Store{client: client, prefix: prefix, tmpDir: tmpDir}
+}
+
+func (s *S3Store) Put(ctx context.Context, r io.Reader) (string, error) {
+	tmp, err := os.CreateTemp(s.tmpDir, "blob-*")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		tmp.Close()
+		os.Remove(tmp.Name())
+	}()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	key := hex.EncodeToString(h.Sum(nil))
+	if err := s.client.PutObject(ctx, s.prefix+key, tmp, size); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+func (s *S3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	if !ValidKey(key) {
+		return nil, ErrInvalidKey
+	}
+	return s.client.GetObject(ctx, s.prefix+key)
+}
+
+func (s *S3Store) Delete(ctx context.Context, key string) error {
+	if !ValidKey(key) {
+		return ErrInvalidKey
+	}
+	return s.client.DeleteObject(ctx, s.prefix+key)
+}
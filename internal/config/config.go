@@ -0,0 +1,243 @@
+// Package config reúne toda la configuración del servidor en un struct
+// tipado. Se carga una sola vez al arrancar, se valida entera (todos los
+// errores a la vez, no uno por petición) y se pasa explícitamente a quien la
+// necesita: ningún otro paquete lee variables de entorno.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// Config es la configuración de la API y de las herramientas de cmd/.
+//
+// Cada campo hoja declara su clave con la etiqueta env (la misma en el
+// entorno y en el fichero), su valor por defecto con default y, si es un
+// secreto, secret:"true" (o secret:"url" para ocultar solo la contraseña de
+// una URL) para que --print-config no lo muestre. El flag equivalente es la
+// clave en minúsculas con guiones: DATABASE_URL -> --database-url.
+type Config struct {
+	HTTPPort        int           `env:"PORT" default:"8080" usage:"HTTP listen port"`
+	GRPCPort        int           `env:"GRPC_PORT" default:"9090" usage:"gRPC listen port"`
+	ShutdownTimeout time.Duration `env:"SHUTDOWN_TIMEOUT" default:"30s" usage:"max wait for in-flight jobs on SIGTERM"`
+	RequestTimeout  time.Duration `env:"REQUEST_TIMEOUT" default:"15s" usage:"deadline of each HTTP request and of the database work for each agent message"`
+	DatabaseURL     string        `env:"DATABASE_URL" secret:"url" usage:"PostgreSQL connection URL"`
+	FrontendURL     string        `env:"FRONTEND_URL" usage:"dashboard origin, used for CORS and email links"`
+	LogLevel        string        `env:"LOG_LEVEL" default:"info" usage:"minimum log level (debug | info | warn | error), changeable at runtime via /admin/log-level"`
+	AdminToken      string        `env:"ADMIN_TOKEN" secret:"true" usage:"bearer token for the /admin endpoints (disabled when empty)"`
+	TrustedProxies  []string      `env:"TRUSTED_PROXIES" usage:"IPs or CIDRs of the reverse proxies whose X-Forwarded-For and X-Real-IP are trusted, space or comma separated"`
+
+	JWT       JWT
+	MFA       MFA
+	Mail      Mail
+	OIDC      OIDC
+	Tracing   Tracing
+	Jobs      Jobs
+	Artifacts Artifacts
+}
+
+// JWT configura los access y refresh tokens y el almacén de claves de firma
+type JWT struct {
+	Issuer     string        `env:"JWT_ISSUER" default:"autohost-cloud" usage:"iss of access tokens"`
+	Audience   string        `env:"JWT_AUDIENCE" default:"autohost-cloud-api" usage:"aud of access tokens"`
+	AccessTTL  time.Duration `env:"ACCESS_TOKEN_TTL" default:"15m" usage:"access token lifetime"`
+	RefreshTTL time.Duration `env:"REFRESH_TOKEN_TTL" default:"720h" usage:"refresh token lifetime"`
+	// Con KeysDir las claves se leen de ficheros PEM; si no, de Postgres
+	// cifradas con KeyEncryptionKey
+	KeysDir          string `env:"JWT_KEYS_DIR" usage:"directory of PEM signing keys (instead of Postgres)"`
+	SigningKeyID     string `env:"JWT_SIGNING_KEY_ID" usage:"kid of the signing key in JWT_KEYS_DIR"`
+	KeyEncryptionKey string `env:"JWT_KEY_ENCRYPTION_KEY" secret:"true" usage:"base64 AES-256 key that encrypts signing keys in Postgres"`
+	Algorithm        string `env:"JWT_ALGORITHM" default:"RS256" usage:"algorithm of new signing keys (RS256 | EdDSA)"`
+}
+
+// MFA configura el cifrado de los secretos TOTP
+type MFA struct {
+	EncryptionKey string `env:"MFA_ENCRYPTION_KEY" secret:"true" usage:"base64 AES-256 key for TOTP secrets (defaults to JWT_KEY_ENCRYPTION_KEY)"`
+}
+
+// Mail selecciona cómo se envían los correos
+type Mail struct {
+	Driver string `env:"MAIL_DRIVER" default:"log" usage:"log | file"`
+	Dir    string `env:"MAIL_DIR" default:"tmp/mail" usage:"output directory of the file driver"`
+}
+
+// OIDC configura el single sign-on; sin IssuerURL está desactivado
+type OIDC struct {
+	IssuerURL    string   `env:"OIDC_ISSUER_URL" usage:"OpenID Connect issuer (enables single sign-on)"`
+	ClientID     string   `env:"OIDC_CLIENT_ID" usage:"OIDC client id"`
+	ClientSecret string   `env:"OIDC_CLIENT_SECRET" secret:"true" usage:"OIDC client secret"`
+	RedirectURL  string   `env:"OIDC_REDIRECT_URL" usage:"OIDC redirect URL registered with the provider"`
+	Scopes       []string `env:"OIDC_SCOPES" default:"openid email profile" usage:"OIDC scopes, space or comma separated"`
+}
+
+// Tracing configura OpenTelemetry; con el exportador none no se exporta
+// nada, pero el contexto de traza se sigue propagando
+type Tracing struct {
+	Exporter    string  `env:"TRACING_EXPORTER" default:"none" usage:"span exporter (none | stdout)"`
+	SampleRatio float64 `env:"TRACING_SAMPLE_RATIO" default:"1" usage:"fraction of new traces sampled, 0 to 1"`
+}
+
+// Jobs limita la salida de los jobs que se guarda. Lo que pasa de
+// OutputInlineLimit se corta en la fila y se guarda entero en BlobDir; lo que
+// pasa de OutputMaxSize se descarta (y es también el tamaño máximo de un
+// mensaje de agente)
+type Jobs struct {
+	OutputInlineLimit int    `env:"JOB_OUTPUT_INLINE_LIMIT" default:"65536" usage:"bytes of job output stored inline; the rest goes to the blob store"`
+	OutputMaxSize     int    `env:"JOB_OUTPUT_MAX_SIZE" default:"16777216" usage:"max bytes of job output kept, and max size of an agent message"`
+	BlobDir           string `env:"BLOB_DIR" default:"data/blobs" usage:"directory of the content-addressed blob store"`
+}
+
+// Artifacts configura los ficheros que suben los agentes para sus jobs. Van a
+// un almacén propio, separado del de la salida de los jobs, para que la purga
+// por retención no borre contenido que un job aún referencia
+type Artifacts struct {
+	Dir       string        `env:"ARTIFACT_DIR" default:"data/artifacts" usage:"directory of the job artifact store"`
+	MaxSize   int           `env:"ARTIFACT_MAX_SIZE" default:"1073741824" usage:"max bytes of a job artifact"`
+	Retention time.Duration `env:"ARTIFACT_RETENTION" default:"720h" usage:"how long job artifacts can be downloaded"`
+}
+
+// DefaultFile es el fichero que se lee si no se indica --config y existe
+const DefaultFile = ".env"
+
+// field es un campo hoja de Config con sus etiquetas
+type field struct {
+	key    string
+	flag   string
+	def    string
+	usage  string
+	secret string
+	value  reflect.Value
+}
+
+// fields recorre Config en orden de declaración
+func fields(c *Config) []field {
+	var out []field
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			if sf.Type.Kind() == reflect.Struct && sf.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(v.Field(i))
+				continue
+			}
+			key := sf.Tag.Get("env")
+			out = append(out, field{
+				key:    key,
+				flag:   strings.ReplaceAll(strings.ToLower(key), "_", "-"),
+				def:    sf.Tag.Get("default"),
+				usage:  sf.Tag.Get("usage"),
+				secret: sf.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem())
+	return out
+}
+
+// Load construye la configuración con prioridad creciente: valores por
+// defecto, fichero KEY=VALUE (--config, o DefaultFile si existe), variables
+// de entorno y flags. Registra en fs un flag por clave más --config; el
+// llamador puede añadir los suyos antes y leer fs.Args() después.
+//
+// Load solo falla si un valor no se puede interpretar o el fichero no se
+// puede leer; la coherencia del conjunto se comprueba con Validate.
+func Load(fs *flag.FlagSet, args []string) (*Config, error) {
+	c := &Config{}
+	fl := fields(c)
+
+	file := fs.String("config", "", "KEY=VALUE file to load (default "+DefaultFile+" if present)")
+	flagValues := make(map[string]*string, len(fl))
+	for _, f := range fl {
+		flagValues[f.key] = fs.String(f.flag, "", f.usage+" ("+f.key+")")
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	setFlags := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { setFlags[f.Name] = true })
+
+	fileValues := map[string]string{}
+	path, explicit := *file, *file != ""
+	if !explicit {
+		path = DefaultFile
+	}
+	if vals, err := godotenv.Read(path); err == nil {
+		fileValues = vals
+	} else if explicit || !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("read config file %s: %w", path, err)
+	}
+
+	var errs []error
+	for _, f := range fl {
+		raw := f.def
+		if v, ok := fileValues[f.key]; ok {
+			raw = v
+		}
+		if v, ok := os.LookupEnv(f.key); ok {
+			raw = v
+		}
+		if setFlags[f.flag] {
+			raw = *flagValues[f.key]
+		}
+		if err := set(f.value, strings.TrimSpace(raw)); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.key, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// set interpreta raw según el tipo del campo
+func set(v reflect.Value, raw string) error {
+	switch v.Interface().(type) {
+	case string:
+		v.SetString(raw)
+	case int:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", raw)
+		}
+		v.SetInt(int64(n))
+	case float64:
+		if raw == "" {
+			v.SetFloat(0)
+			return nil
+		}
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case time.Duration:
+		if raw == "" {
+			v.SetInt(0)
+			return nil
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a duration (e.g. 30s, 15m, 720h)", raw)
+		}
+		v.SetInt(int64(d))
+	case []string:
+		v.Set(reflect.ValueOf(strings.Fields(strings.ReplaceAll(raw, ",", " "))))
+	default:
+		return fmt.Errorf("unsupported config type %s", v.Type())
+	}
+	return nil
+}
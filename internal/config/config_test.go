@@ -0,0 +1,140 @@
+package config
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEncryptionKey = "MDEyMzQ1Njc4OTAxMjM0NTY3ODkwMTIzNDU2Nzg5MDE=" // 32 bytes
+
+func load(t *testing.T, args ...string) *Config {
+	t.Helper()
+	cfg, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	t.Chdir(t.TempDir()) // no stray .env
+	file := filepath.Join(t.TempDir(), "api.env")
+	err := os.WriteFile(file, []byte("PORT=7000\nGRPC_PORT=7001\nACCESS_TOKEN_TTL=5m\nOIDC_SCOPES=openid,email\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("GRPC_PORT", "7002")
+	t.Setenv("SHUTDOWN_TIMEOUT", "10s")
+
+	cfg := load(t, "--config", file, "--shutdown-timeout", "1m")
+
+	if cfg.HTTPPort != 7000 {
+		t.Errorf("HTTPPort = %d, want 7000 from the file", cfg.HTTPPort)
+	}
+	if cfg.GRPCPort != 7002 {
+		t.Errorf("GRPCPort = %d, want 7002 from the environment", cfg.GRPCPort)
+	}
+	if cfg.ShutdownTimeout != time.Minute {
+		t.Errorf("ShutdownTimeout = %s, want 1m from the flag", cfg.ShutdownTimeout)
+	}
+	if cfg.JWT.AccessTTL != 5*time.Minute || cfg.JWT.RefreshTTL != 720*time.Hour {
+		t.Errorf("JWT TTLs = %s/%s", cfg.JWT.AccessTTL, cfg.JWT.RefreshTTL)
+	}
+	if got := strings.Join(cfg.OIDC.Scopes, " "); got != "openid email" {
+		t.Errorf("Scopes = %q", got)
+	}
+}
+
+func TestLoadRejectsMalformedValues(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("PORT", "http")
+	t.Setenv("ACCESS_TOKEN_TTL", "15")
+
+	_, err := Load(flag.NewFlagSet("test", flag.ContinueOnError), nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"PORT", "ACCESS_TOKEN_TTL"} {
+		if !strings.Contains(err.Error(), key+":") {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	t.Chdir(t.TempDir())
+	valid := func() *Config {
+		cfg := load(t)
+		cfg.DatabaseURL = "postgres://localhost/autohost"
+		cfg.JWT.KeyEncryptionKey = testEncryptionKey
+		cfg.FrontendURL = "http://localhost:3000"
+		return cfg
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(*Config)
+		wantErr string
+	}{
+		{"defaults", func(*Config) {}, ""},
+		{"missing database", func(c *Config) { c.DatabaseURL = "" }, "DATABASE_URL: is required"},
+		{"missing frontend url", func(c *Config) { c.FrontendURL = "" }, "FRONTEND_URL: is required"},
+		{"relative frontend url", func(c *Config) { c.FrontendURL = "localhost:3000" }, "FRONTEND_URL: must be an absolute URL"},
+		{"missing encryption key", func(c *Config) { c.JWT.KeyEncryptionKey = "" }, "JWT_KEY_ENCRYPTION_KEY: is required"},
+		{"keys dir instead of encryption key", func(c *Config) { c.JWT.KeyEncryptionKey = ""; c.JWT.KeysDir = "keys" }, ""},
+		{"bad algorithm", func(c *Config) { c.JWT.Algorithm = "HS256" }, "JWT_ALGORITHM"},
+		{"bad mail driver", func(c *Config) { c.Mail.Driver = "smtp" }, "MAIL_DRIVER"},
+		{"oidc without client", func(c *Config) { c.OIDC.IssuerURL = "https://login.example.com" }, "OIDC_CLIENT_ID: is required"},
+		{"zero timeout", func(c *Config) { c.ShutdownTimeout = 0 }, "SHUTDOWN_TIMEOUT"},
+		{"zero request timeout", func(c *Config) { c.RequestTimeout = 0 }, "REQUEST_TIMEOUT"},
+		{"bad tracing exporter", func(c *Config) { c.Tracing.Exporter = "jaeger" }, "TRACING_EXPORTER"},
+		{"sample ratio above one", func(c *Config) { c.Tracing.SampleRatio = 1.5 }, "TRACING_SAMPLE_RATIO"},
+		{"zero inline output", func(c *Config) { c.Jobs.OutputInlineLimit = 0 }, "JOB_OUTPUT_INLINE_LIMIT"},
+		{"max output below inline", func(c *Config) { c.Jobs.OutputMaxSize = 1024 }, "JOB_OUTPUT_MAX_SIZE"},
+		{"zero artifact size", func(c *Config) { c.Artifacts.MaxSize = 0 }, "ARTIFACT_MAX_SIZE"},
+		{"zero artifact retention", func(c *Config) { c.Artifacts.Retention = 0 }, "ARTIFACT_RETENTION"},
+		{"trusted proxies", func(c *Config) { c.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.10", "::1"} }, ""},
+		{"bad trusted proxy", func(c *Config) { c.TrustedProxies = []string{"proxy.internal"} }, "TRUSTED_PROXIES"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.mutate(cfg)
+			err := cfg.Validate()
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Errorf("unexpected error: %v", err)
+			case tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)):
+				t.Errorf("error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	t.Chdir(t.TempDir())
+	cfg := load(t)
+	cfg.DatabaseURL = "postgres://autohost:hunter2@db:5432/autohost"
+	cfg.JWT.KeyEncryptionKey = testEncryptionKey
+	cfg.OIDC.ClientSecret = "oidc-secret"
+
+	var b strings.Builder
+	if err := cfg.Print(&b); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, secret := range []string{"hunter2", testEncryptionKey, "oidc-secret"} {
+		if strings.Contains(out, secret) {
+			t.Errorf("output leaks %q:\n%s", secret, out)
+		}
+	}
+	for _, line := range []string{"DATABASE_URL=postgres://autohost:xxxxx@db:5432/autohost", "JWT_KEY_ENCRYPTION_KEY=" + redacted, "PORT=8080"} {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("output lacks %q:\n%s", line, out)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
+)
+
+// minAdminTokenLen evita tokens de operación adivinables
+const minAdminTokenLen = 32
+
+// Validate comprueba que la configuración basta para arrancar la API y
+// devuelve todos los problemas juntos, cada uno con el nombre de su clave.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...any) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+	checkPort := func(key string, port int) {
+		if port < 1 || port > 65535 {
+			fail(key, "must be a port between 1 and 65535, got %d", port)
+		}
+	}
+	checkPositive := func(key string, d time.Duration) {
+		if d <= 0 {
+			fail(key, "must be a positive duration, got %s", d)
+		}
+	}
+	checkURL := func(key, v string) {
+		if u, err := url.Parse(v); err != nil || u.Scheme == "" || u.Host == "" {
+			fail(key, "must be an absolute URL, got %q", v)
+		}
+	}
+
+	if _, err := logging.ParseLevel(c.LogLevel); err != nil {
+		fail("LOG_LEVEL", "must be debug, info, warn or error, got %q", c.LogLevel)
+	}
+	if c.AdminToken != "" && len(c.AdminToken) < minAdminTokenLen {
+		fail("ADMIN_TOKEN", "must be at least %d characters", minAdminTokenLen)
+	}
+
+	checkPort("PORT", c.HTTPPort)
+	checkPort("GRPC_PORT", c.GRPCPort)
+	checkPositive("SHUTDOWN_TIMEOUT", c.ShutdownTimeout)
+	checkPositive("REQUEST_TIMEOUT", c.RequestTimeout)
+
+	if c.DatabaseURL == "" {
+		fail("DATABASE_URL", "is required")
+	}
+	// Es la base de los enlaces de los correos: sin ella las invitaciones y
+	// los restablecimientos llevarían enlaces relativos que no abren nada
+	if c.FrontendURL == "" {
+		fail("FRONTEND_URL", "is required")
+	} else {
+		checkURL("FRONTEND_URL", c.FrontendURL)
+	}
+	if _, err := c.TrustedProxyPrefixes(); err != nil {
+		fail("TRUSTED_PROXIES", "%v", err)
+	}
+
+	checkPositive("ACCESS_TOKEN_TTL", c.JWT.AccessTTL)
+	checkPositive("REFRESH_TOKEN_TTL", c.JWT.RefreshTTL)
+	if c.JWT.Issuer == "" {
+		fail("JWT_ISSUER", "must not be empty")
+	}
+	if c.JWT.Audience == "" {
+		fail("JWT_AUDIENCE", "must not be empty")
+	}
+	if c.JWT.Algorithm != platform.AlgRS256 && c.JWT.Algorithm != platform.AlgEdDSA {
+		fail("JWT_ALGORITHM", "must be %s or %s, got %q", platform.AlgRS256, platform.AlgEdDSA, c.JWT.Algorithm)
+	}
+	switch {
+	case c.JWT.KeyEncryptionKey != "":
+		if _, err := platform.ParseEncryptionKey(c.JWT.KeyEncryptionKey); err != nil {
+			fail("JWT_KEY_ENCRYPTION_KEY", "%v", err)
+		}
+	case c.JWT.KeysDir == "":
+		fail("JWT_KEY_ENCRYPTION_KEY", "is required unless JWT_KEYS_DIR is set (generate one with: go run ./cmd/jwtkeys encryption-key)")
+	}
+	if c.JWT.SigningKeyID != "" && c.JWT.KeysDir == "" {
+		fail("JWT_SIGNING_KEY_ID", "only applies with JWT_KEYS_DIR")
+	}
+
+	if c.MFA.EncryptionKey != "" {
+		if _, err := platform.ParseEncryptionKey(c.MFA.EncryptionKey); err != nil {
+			fail("MFA_ENCRYPTION_KEY", "%v", err)
+		}
+	}
+
+	switch strings.ToLower(c.Mail.Driver) {
+	case "log":
+	case "file":
+		if c.Mail.Dir == "" {
+			fail("MAIL_DIR", "is required with MAIL_DRIVER=file")
+		}
+	default:
+		fail("MAIL_DRIVER", "must be log or file, got %q", c.Mail.Driver)
+	}
+
+	if c.OIDC.IssuerURL != "" {
+		checkURL("OIDC_ISSUER_URL", c.OIDC.IssuerURL)
+		if c.OIDC.ClientID == "" {
+			fail("OIDC_CLIENT_ID", "is required when OIDC_ISSUER_URL is set")
+		}
+		if c.OIDC.RedirectURL == "" {
+			fail("OIDC_REDIRECT_URL", "is required when OIDC_ISSUER_URL is set")
+		} else {
+			checkURL("OIDC_REDIRECT_URL", c.OIDC.RedirectURL)
+		}
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	default:
+		fail("TRACING_EXPORTER", "must be %s or %s, got %q", tracing.ExporterNone, tracing.ExporterStdout, c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		fail("TRACING_SAMPLE_RATIO", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	if c.Jobs.OutputInlineLimit <= 0 {
+		fail("JOB_OUTPUT_INLINE_LIMIT", "must be positive, got %d", c.Jobs.OutputInlineLimit)
+	}
+	if c.Jobs.OutputMaxSize < c.Jobs.OutputInlineLimit {
+		fail("JOB_OUTPUT_MAX_SIZE", "must be at least JOB_OUTPUT_INLINE_LIMIT (%d), got %d", c.Jobs.OutputInlineLimit, c.Jobs.OutputMaxSize)
+	}
+	if c.Jobs.BlobDir == "" {
+		fail("BLOB_DIR", "must not be empty")
+	}
+
+	if c.Artifacts.Dir == "" {
+		fail("ARTIFACT_DIR", "must not be empty")
+	}
+	if c.Artifacts.MaxSize <= 0 {
+		fail("ARTIFACT_MAX_SIZE", "must be positive, got %d", c.Artifacts.MaxSize)
+	}
+	if c.Artifacts.Retention <= 0 {
+		fail("ARTIFACT_RETENTION", "must be positive, got %s", c.Artifacts.Retention)
+	}
+
+	return errors.Join(errs...)
+}
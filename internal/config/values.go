@@ -0,0 +1,151 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const redacted = "<redacted>"
+
+// Print escribe la configuración efectiva como líneas KEY=VALUE, en el mismo
+// formato que el fichero, con los secretos ocultos.
+func (c *Config) Print(w io.Writer) error {
+	for _, f := range fields(c) {
+		if _, err := fmt.Fprintf(w, "%s=%s\n", f.key, display(f)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func display(f field) string {
+	var s string
+	switch v := f.value.Interface().(type) {
+	case []string:
+		s = strings.Join(v, " ")
+	case time.Duration:
+		s = v.String()
+	default:
+		s = fmt.Sprint(v)
+	}
+	if s == "" {
+		return ""
+	}
+	switch f.secret {
+	case "true":
+		return redacted
+	case "url":
+		u, err := url.Parse(s)
+		if err != nil {
+			return redacted
+		}
+		return u.Redacted()
+	}
+	return s
+}
+
+// JWTConfig devuelve los parámetros de emisión y validación de access tokens
+func (c *Config) JWTConfig() platform.JWTConfig {
+	return platform.JWTConfig{
+		Issuer:    c.JWT.Issuer,
+		Audience:  c.JWT.Audience,
+		AccessTTL: c.JWT.AccessTTL,
+	}
+}
+
+// SigningKeyStore devuelve la clave que cifra las claves de firma guardadas
+// en Postgres y el algoritmo de las claves nuevas
+func (c *Config) SigningKeyStore() (encryptionKey []byte, alg string, err error) {
+	if c.JWT.KeyEncryptionKey == "" {
+		return nil, "", errors.New("JWT_KEY_ENCRYPTION_KEY not set")
+	}
+	encryptionKey, err = platform.ParseEncryptionKey(c.JWT.KeyEncryptionKey)
+	if err != nil {
+		return nil, "", err
+	}
+	if c.JWT.Algorithm != platform.AlgRS256 && c.JWT.Algorithm != platform.AlgEdDSA {
+		return nil, "", fmt.Errorf("%w: %s", platform.ErrUnsupportedAlgorithm, c.JWT.Algorithm)
+	}
+	return encryptionKey, c.JWT.Algorithm, nil
+}
+
+// MFAKey devuelve la clave que cifra los secretos TOTP: MFA_ENCRYPTION_KEY o,
+// si no está, JWT_KEY_ENCRYPTION_KEY. nil si no hay ninguna.
+func (c *Config) MFAKey() ([]byte, error) {
+	encoded := c.MFA.EncryptionKey
+	if encoded == "" {
+		encoded = c.JWT.KeyEncryptionKey
+	}
+	if encoded == "" {
+		return nil, nil
+	}
+	return platform.ParseEncryptionKey(encoded)
+}
+
+// OIDCConfig devuelve el cliente de single sign-on, o nil si está desactivado
+func (c *Config) OIDCConfig() *platform.OIDCConfig {
+	if c.OIDC.IssuerURL == "" {
+		return nil
+	}
+	return &platform.OIDCConfig{
+		IssuerURL:    c.OIDC.IssuerURL,
+		ClientID:     c.OIDC.ClientID,
+		ClientSecret: c.OIDC.ClientSecret,
+		RedirectURL:  c.OIDC.RedirectURL,
+		Scopes:       c.OIDC.Scopes,
+	}
+}
+
+// TrustedProxyPrefixes devuelve TRUSTED_PROXIES como rangos; una IP suelta es
+// un rango de una sola dirección
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(c.TrustedProxies))
+	for _, v := range c.TrustedProxies {
+		if addr, err := netip.ParseAddr(v); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		p, err := netip.ParsePrefix(v)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP or CIDR", v)
+		}
+		prefixes = append(prefixes, p.Masked())
+	}
+	return prefixes, nil
+}
+
+// Mailer construye el Mailer del driver configurado
+func (c *Config) Mailer() (platform.Mailer, error) {
+	return platform.NewMailer(c.Mail.Driver, c.Mail.Dir)
+}
+
+// JobOutputLimits devuelve los límites de salida de los jobs
+func (c *Config) JobOutputLimits() job.OutputLimits {
+	return job.OutputLimits{Inline: c.Jobs.OutputInlineLimit, Max: c.Jobs.OutputMaxSize}
+}
+
+// BlobStore abre el almacén de blobs en BLOB_DIR, creándolo si no existe
+func (c *Config) BlobStore() (*blob.FSStore, error) {
+	return blob.NewFSStore(c.Jobs.BlobDir)
+}
+
+// ArtifactLimits devuelve el tamaño máximo y la retención de los artefactos
+func (c *Config) ArtifactLimits() jobartifact.Limits {
+	return jobartifact.Limits{MaxSize: int64(c.Artifacts.MaxSize), Retention: c.Artifacts.Retention}
+}
+
+// ArtifactStore abre el almacén de artefactos en ARTIFACT_DIR, creándolo si
+// no existe
+func (c *Config) ArtifactStore() (*blob.FSStore, error) {
+	return blob.NewFSStore(c.Artifacts.Dir)
+}
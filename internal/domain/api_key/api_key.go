@@ -0,0 +1,47 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+// APIKey es una credencial personal de larga duración para automatización.
+// Actúa como su usuario, limitada a Scopes si no está vacío.
+type APIKey struct {
+	ID         string                    `json:"id"`
+	UserID     string                    `json:"user_id"`
+	UserEmail  string                    `json:"-"`
+	Name       string                    `json:"name"`
+	Prefix     string                    `json:"prefix"`
+	KeyHash    string                    `json:"-"`
+	Scopes     []organization.Permission `json:"scopes"`
+	ExpiresAt  *time.Time                `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time                `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time                `json:"revoked_at,omitempty"`
+	CreatedAt  time.Time                 `json:"created_at"`
+}
+
+// Allows reports whether the key may be used for perm.
+func (k *APIKey) Allows(perm organization.Permission) bool {
+	if len(k.Scopes) == 0 {
+		return true
+	}
+	for _, s := range k.Scopes {
+		if s == perm {
+			return true
+		}
+	}
+	return false
+}
+
+type Repository interface {
+	Create(ctx context.Context, k *APIKey) error
+	// FindByHash devuelve la clave (con el email de su usuario) aunque esté
+	// revocada o expirada
+	FindByHash(ctx context.Context, keyHash string) (*APIKey, error)
+	FindByUserID(ctx context.Context, userID string) ([]*APIKey, error)
+	Revoke(ctx context.Context, userID, id string, at time.Time) error
+	UpdateLastUsed(ctx context.Context, id string, at time.Time) error
+}
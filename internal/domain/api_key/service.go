@@ -0,0 +1,127 @@
+package apikey
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+var (
+	ErrAPIKeyNotFound    = apperr.New(apperr.NotFound, "api key not found")
+	ErrInvalidAPIKey     = apperr.New(apperr.Unauthenticated, "invalid api key")
+	ErrAPIKeyExpired     = apperr.New(apperr.Unauthenticated, "api key expired")
+	ErrAPIKeyRevoked     = apperr.New(apperr.Unauthenticated, "api key revoked")
+	ErrInvalidAPIKeyData = apperr.New(apperr.InvalidArgument, "invalid api key data")
+)
+
+// lastUsedResolution evita escribir en cada petición
+const lastUsedResolution = time.Minute
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Create emite una API key nueva. El valor en claro solo se devuelve aquí.
+func (s *Service) Create(ctx context.Context, userID, name string, scopes []organization.Permission, expiresAt *time.Time) (plain string, key *APIKey, err error) {
+	name = strings.TrimSpace(name)
+	if userID == "" || name == "" {
+		return "", nil, ErrInvalidAPIKeyData
+	}
+	for _, scope := range scopes {
+		if !scope.Valid() {
+			return "", nil, ErrInvalidAPIKeyData
+		}
+	}
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return "", nil, ErrInvalidAPIKeyData
+	}
+
+	plain, hash, display, err := platform.GenerateAPIKey()
+	if err != nil {
+		return "", nil, err
+	}
+
+	if scopes == nil {
+		scopes = []organization.Permission{}
+	}
+	key = &APIKey{
+		UserID:    userID,
+		Name:      name,
+		Prefix:    display,
+		KeyHash:   hash,
+		Scopes:    scopes,
+		ExpiresAt: expiresAt,
+	}
+	if err := s.repo.Create(ctx, key); err != nil {
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+// Authenticate valida una API key presentada por un cliente
+func (s *Service) Authenticate(ctx context.Context, plain string) (*APIKey, error) {
+	if !strings.HasPrefix(plain, platform.APIKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.FindByHash(ctx, platform.HashAPIKey(plain))
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if key.RevokedAt != nil {
+		return nil, ErrAPIKeyRevoked
+	}
+	if key.ExpiresAt != nil && now.After(*key.ExpiresAt) {
+		return nil, ErrAPIKeyExpired
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > lastUsedResolution {
+		if err := s.repo.UpdateLastUsed(ctx, key.ID, now); err != nil {
+			slog.Error("update api key last_used_at", "api_key_id", key.ID, "error", err)
+		}
+	}
+	return key, nil
+}
+
+// List devuelve las API keys del usuario
+func (s *Service) List(ctx context.Context, userID string) ([]*APIKey, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// Revoke revoca una API key del usuario
+func (s *Service) Revoke(ctx context.Context, userID, id string) error {
+	return s.repo.Revoke(ctx, userID, id, time.Now())
+}
+
+// RevokeAll revoca todas las API keys activas del usuario
+func (s *Service) RevokeAll(ctx context.Context, userID string) error {
+	keys, err := s.repo.FindByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, k := range keys {
+		if k.RevokedAt != nil {
+			continue
+		}
+		if err := s.repo.Revoke(ctx, userID, k.ID, now); err != nil && !errors.Is(err, ErrAPIKeyNotFound) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// ActorType identifies who performed an audited action.
+type ActorType string
+
+const (
+	ActorUser   ActorType = "user"
+	ActorNode   ActorType = "node"
+	ActorSystem ActorType = "system"
+)
+
+// Outcome records whether the audited action succeeded.
+type Outcome string
+
+const (
+	OutcomeSuccess Outcome = "success"
+	OutcomeFailure Outcome = "failure"
+)
+
+// Audited actions.
+const (
+	ActionAuthRegister       = "auth.register"
+	ActionAuthLogin          = "auth.login"
+	ActionAuthRefresh        = "auth.refresh"
+	ActionAuthLogout         = "auth.logout"
+	ActionAuthRefreshReuse   = "auth.refresh_reuse"
+	ActionAuthSessionRevoke  = "auth.session_revoke"
+	ActionAuthEmailVerify    = "auth.email_verify"
+	ActionAuthPasswordForgot = "auth.password_reset_request"
+	ActionAuthPasswordReset  = "auth.password_reset"
+	ActionAuthPasswordChange = "auth.password_change"
+	ActionAPIKeyCreate       = "api_key.create"
+	ActionAPIKeyRevoke       = "api_key.revoke"
+	ActionEnrollTokenCreate  = "enrollment.token_create"
+	ActionNodeEnroll         = "enrollment.enroll"
+	ActionNodeTokenCreate    = "node_token.create"
+	ActionJobDispatch        = "job.dispatch"
+	ActionJobArtifactUpload  = "job.artifact_upload"
+	ActionCommandRegister    = "node_command.register"
+	ActionCommandDelete      = "node_command.delete"
+	ActionScriptCreate       = "script.create"
+	ActionScriptVersion      = "script.version_create"
+	ActionScriptDelete       = "script.delete"
+	ActionScriptAssign       = "script.assign"
+	ActionScriptUnassign     = "script.unassign"
+	ActionNodeGroupCreate    = "node_group.create"
+	ActionNodeGroupDelete    = "node_group.delete"
+	ActionNodeGroupAdd       = "node_group.node_add"
+	ActionNodeGroupRemove    = "node_group.node_remove"
+	ActionMemberRoleChange   = "organization.member_role_change"
+	ActionMemberRemove       = "organization.member_remove"
+	ActionInvitationCreate   = "invitation.create"
+	ActionInvitationAccept   = "invitation.accept"
+	ActionInvitationDecline  = "invitation.decline"
+	ActionInvitationRevoke   = "invitation.revoke"
+	ActionOrganizationCreate = "organization.create"
+	ActionOrganizationUpdate = "organization.update"
+	ActionMFAEnable          = "auth.mfa_enable"
+	ActionMFADisable         = "auth.mfa_disable"
+	ActionMFARecoveryCodes   = "auth.mfa_recovery_codes"
+)
+
+// GenesisHash is the prev_hash of the first event of every chain.
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Event is a single immutable audit record.
+type Event struct {
+	ID             int64          `db:"id" json:"id"`
+	OccurredAt     time.Time      `db:"occurred_at" json:"occurred_at"`
+	OrganizationID *string        `db:"organization_id" json:"organization_id,omitempty"`
+	ActorType      ActorType      `db:"actor_type" json:"actor_type"`
+	ActorID        string         `db:"actor_id" json:"actor_id"`
+	Action         string         `db:"action" json:"action"`
+	TargetType     string         `db:"target_type" json:"target_type,omitempty"`
+	TargetID       string         `db:"target_id" json:"target_id,omitempty"`
+	Outcome        Outcome        `db:"outcome" json:"outcome"`
+	IP             string         `db:"ip" json:"ip,omitempty"`
+	RequestID      string         `db:"request_id" json:"request_id,omitempty"`
+	Metadata       map[string]any `db:"-" json:"metadata,omitempty"`
+	PrevHash       string         `db:"prev_hash" json:"prev_hash"`
+	Hash           string         `db:"hash" json:"hash"`
+}
+
+// Filter narrows a List query. Zero values are ignored.
+type Filter struct {
+	OrganizationID string
+	ActorType      ActorType
+	ActorID        string
+	Action         string
+	TargetType     string
+	TargetID       string
+	Outcome        Outcome
+	Since          *time.Time
+	Until          *time.Time
+	BeforeID       int64 // cursor: only events with id < BeforeID
+	Limit          int
+}
+
+// Repository defines the persistence contract for audit events.
+type Repository interface {
+	// Append links e to the last event of its organization's chain, computes
+	// its hash with ComputeHash and stores it. It must serialize concurrent
+	// appends to the same chain.
+	Append(ctx context.Context, e *Event) (*Event, error)
+	List(ctx context.Context, f Filter) ([]*Event, error)
+	// Chain returns events of one chain in insertion order, starting after afterID.
+	Chain(ctx context.Context, orgID *string, afterID int64, limit int) ([]*Event, error)
+}
+
+// ComputeHash returns the chained hash of e given the hash of the previous
+// event. OccurredAt must already be truncated to the database precision.
+func ComputeHash(prevHash string, e *Event) string {
+	metadata, _ := json.Marshal(e.Metadata) // map keys are sorted => deterministic
+	org := ""
+	if e.OrganizationID != nil {
+		org = *e.OrganizationID
+	}
+	canonical, _ := json.Marshal([]string{
+		prevHash,
+		e.OccurredAt.UTC().Format(time.RFC3339Nano),
+		org,
+		string(e.ActorType),
+		e.ActorID,
+		e.Action,
+		e.TargetType,
+		e.TargetID,
+		string(e.Outcome),
+		e.IP,
+		e.RequestID,
+		string(metadata),
+	})
+	sum := sha256.Sum256(canonical)
+	return hex.EncodeToString(sum[:])
+}
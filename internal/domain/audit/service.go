@@ -0,0 +1,109 @@
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+	verifyBatchSize = 500
+)
+
+var ErrInvalidEventData = apperr.New(apperr.InvalidArgument, "invalid audit event data")
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Record appends an event to the audit log.
+func (s *Service) Record(ctx context.Context, e Event) error {
+	if e.Action == "" || e.ActorType == "" {
+		return ErrInvalidEventData
+	}
+	if e.Outcome == "" {
+		e.Outcome = OutcomeSuccess
+	}
+	if e.OrganizationID != nil && *e.OrganizationID == "" {
+		e.OrganizationID = nil
+	}
+	if len(e.Metadata) == 0 {
+		e.Metadata = nil // "{}" y nil deben producir el mismo hash al releer
+	}
+	// Postgres guarda microsegundos; el hash debe calcularse sobre el mismo valor
+	e.OccurredAt = time.Now().UTC().Truncate(time.Microsecond)
+	_, err := s.repo.Append(ctx, &e)
+	return err
+}
+
+// List returns one page of events (newest first) and the id to pass as
+// BeforeID for the next page, or 0 when there are no more events.
+func (s *Service) List(ctx context.Context, f Filter) ([]*Event, int64, error) {
+	if f.Limit <= 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit > MaxPageSize {
+		f.Limit = MaxPageSize
+	}
+
+	// Pedimos uno extra para saber si hay otra página
+	limit := f.Limit
+	f.Limit = limit + 1
+	events, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var next int64
+	if len(events) > limit {
+		events = events[:limit]
+		next = events[limit-1].ID
+	}
+	return events, next, nil
+}
+
+// VerifyResult reports the state of a hash chain.
+type VerifyResult struct {
+	Valid          bool  `json:"valid"`
+	Checked        int   `json:"checked"`
+	FirstInvalidID int64 `json:"first_invalid_id,omitempty"`
+}
+
+// Verify recomputes the hash chain of an organization and reports the first
+// event whose hash or link does not match.
+func (s *Service) Verify(ctx context.Context, orgID string) (*VerifyResult, error) {
+	var org *string
+	if orgID != "" {
+		org = &orgID
+	}
+
+	res := &VerifyResult{Valid: true}
+	prev := GenesisHash
+	var after int64
+	for {
+		events, err := s.repo.Chain(ctx, org, after, verifyBatchSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range events {
+			res.Checked++
+			if e.PrevHash != prev || ComputeHash(prev, e) != e.Hash {
+				res.Valid = false
+				res.FirstInvalidID = e.ID
+				return res, nil
+			}
+			prev = e.Hash
+			after = e.ID
+		}
+		if len(events) < verifyBatchSize {
+			return res, nil
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const (
+	// EmailVerificationTTL es la vigencia del enlace de verificación de email
+	EmailVerificationTTL = 48 * time.Hour
+	// PasswordResetTTL es la vigencia del enlace de restablecimiento de contraseña
+	PasswordResetTTL = time.Hour
+	// MinPasswordLength es la longitud mínima de una contraseña nueva
+	MinPasswordLength = 8
+)
+
+// ValidatePassword comprueba los requisitos de una contraseña nueva
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength {
+		return ErrWeakPassword
+	}
+	return nil
+}
+
+// SendVerification envía al usuario un enlace para verificar su email.
+// No hace nada si el email ya está verificado.
+func (s *Service) SendVerification(ctx context.Context, userID string) error {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	plain, err := s.issueUserToken(ctx, user.ID, PurposeEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+	if err := s.mailer.Send(ctx, platform.Mail{
+		To:      user.Email,
+		Subject: "Verify your email on Autohost",
+		Body: fmt.Sprintf(
+			"Confirm your email address: %s\n\nThis link expires in %s.",
+			s.link("/verify-email", plain), EmailVerificationTTL,
+		),
+	}); err != nil {
+		return fmt.Errorf("send verification email: %w", err)
+	}
+	return nil
+}
+
+// VerifyEmail canjea un token de verificación y marca el email como verificado
+func (s *Service) VerifyEmail(ctx context.Context, token string) (userID string, err error) {
+	userID, err = s.consumeUserToken(ctx, PurposeEmailVerification, token)
+	if err != nil {
+		return "", err
+	}
+	return userID, s.repo.MarkEmailVerified(ctx, userID)
+}
+
+// MarkEmailVerified marca el email del usuario como verificado, p. ej. cuando
+// lo ha demostrado aceptando una invitación o un proveedor SSO lo garantiza
+func (s *Service) MarkEmailVerified(ctx context.Context, userID string) error {
+	return s.repo.MarkEmailVerified(ctx, userID)
+}
+
+// ForgotPassword envía un enlace de restablecimiento de contraseña.
+//
+// Para no revelar qué emails están registrados devuelve userID vacío y sin
+// error cuando el email no existe; los usuarios solo SSO tampoco reciben
+// enlace porque no tienen contraseña que restablecer.
+func (s *Service) ForgotPassword(ctx context.Context, email string) (userID string, err error) {
+	user, err := s.repo.FindUserByEmail(ctx, strings.TrimSpace(email))
+	if err != nil {
+		return "", err
+	}
+	if user == nil || user.PasswordHash == "" {
+		return "", nil
+	}
+
+	plain, err := s.issueUserToken(ctx, user.ID, PurposePasswordReset, PasswordResetTTL)
+	if err != nil {
+		return "", err
+	}
+	if err := s.mailer.Send(ctx, platform.Mail{
+		To:      user.Email,
+		Subject: "Reset your Autohost password",
+		Body: fmt.Sprintf(
+			"Someone asked to reset the password of your Autohost account.\n\nChoose a new password: %s\n\nThis link expires in %s. If it wasn't you, ignore this email.",
+			s.link("/reset-password", plain), PasswordResetTTL,
+		),
+	}); err != nil {
+		return user.ID, fmt.Errorf("send password reset email: %w", err)
+	}
+	return user.ID, nil
+}
+
+// ResetPassword canjea un token de restablecimiento, fija la nueva contraseña y
+// cierra todas las sesiones del usuario. Recibir el enlace prueba además que
+// el email es suyo.
+func (s *Service) ResetPassword(ctx context.Context, token, newPassword string) (userID string, err error) {
+	if err := ValidatePassword(newPassword); err != nil {
+		return "", err
+	}
+	userID, err = s.consumeUserToken(ctx, PurposePasswordReset, token)
+	if err != nil {
+		return "", err
+	}
+	if err := s.setPassword(ctx, userID, newPassword); err != nil {
+		return userID, err
+	}
+	return userID, s.repo.MarkEmailVerified(ctx, userID)
+}
+
+// ChangePassword cambia la contraseña de un usuario autenticado tras comprobar
+// la actual y cierra todas sus sesiones
+func (s *Service) ChangePassword(ctx context.Context, userID, currentPassword, newPassword string) error {
+	user, err := s.repo.FindUserByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+	if user.PasswordHash == "" || platform.CheckPassword(user.PasswordHash, currentPassword) != nil {
+		return ErrInvalidCredentials
+	}
+	if err := ValidatePassword(newPassword); err != nil {
+		return err
+	}
+	return s.setPassword(ctx, userID, newPassword)
+}
+
+func (s *Service) setPassword(ctx context.Context, userID, password string) error {
+	hash, err := platform.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.UpdatePassword(ctx, userID, hash); err != nil {
+		return err
+	}
+	return s.repo.RevokeAllRefreshTokens(ctx, userID, RevokedPasswordChange)
+}
+
+func (s *Service) issueUserToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	plain, hash, err := platform.GenerateUserToken()
+	if err != nil {
+		return "", err
+	}
+	if err := s.repo.CreateUserToken(ctx, userID, purpose, hash, time.Now().Add(ttl)); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+func (s *Service) consumeUserToken(ctx context.Context, purpose, token string) (string, error) {
+	if !strings.HasPrefix(token, platform.UserTokenPrefix) {
+		return "", ErrInvalidUserToken
+	}
+	userID, err := s.repo.ConsumeUserToken(ctx, purpose, platform.HashUserToken(token))
+	if errors.Is(err, ErrInvalidUserToken) {
+		return "", err
+	}
+	if err != nil {
+		return "", fmt.Errorf("consume %s token: %w", purpose, err)
+	}
+	return userID, nil
+}
+
+func (s *Service) link(path, token string) string {
+	if s.frontendURL == "" {
+		return token
+	}
+	return s.frontendURL + path + "?token=" + url.QueryEscape(token)
+}
@@ -1,34 +1,53 @@
 package auth
 
 import (
+	"context"
 	"errors"
+	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	"github.com/arturo/autohost-cloud-api/internal/platform"
 )
 
 var (
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserAlreadyExists  = errors.New("user already exists")
+	ErrInvalidCredentials  = apperr.New(apperr.Unauthenticated, "invalid credentials")
+	ErrUserAlreadyExists   = apperr.New(apperr.AlreadyExists, "email already exists")
+	ErrInvalidRefreshToken = apperr.New(apperr.Unauthenticated, "invalid or expired refresh token")
+	ErrRefreshTokenExpired = apperr.New(apperr.Unauthenticated, "invalid or expired refresh token")
+	ErrRefreshTokenReused  = apperr.New(apperr.Unauthenticated, "invalid or expired refresh token")
+	ErrSessionNotFound     = apperr.New(apperr.NotFound, "session not found")
+	ErrUserNotFound        = apperr.New(apperr.NotFound, "user not found")
+	ErrInvalidUserToken    = apperr.New(apperr.InvalidArgument, "invalid or expired token")
+	ErrWeakPassword        = apperr.New(apperr.InvalidArgument, "password must be at least 8 characters")
 )
 
 // Service encapsula la lógica de negocio de autenticación
 type Service struct {
-	repo Repository
+	repo        Repository
+	mailer      platform.Mailer
+	frontendURL string
+	refreshTTL  time.Duration
 }
 
-// NewService crea una nueva instancia del servicio de autenticación
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+// NewService crea una nueva instancia del servicio de autenticación.
+// frontendURL es la base de los enlaces enviados por email y refreshTTL la
+// vigencia de los refresh tokens.
+func NewService(repo Repository, mailer platform.Mailer, frontendURL string, refreshTTL time.Duration) *Service {
+	return &Service{repo: repo, mailer: mailer, frontendURL: frontendURL, refreshTTL: refreshTTL}
 }
 
 // Register registra un nuevo usuario
-func (s *Service) Register(email, name, password string) (userID string, err error) {
+func (s *Service) Register(ctx context.Context, email, name, password string) (userID string, err error) {
 	// Verificar si el usuario ya existe
-	existing, _ := s.repo.FindUserByEmail(email)
+	existing, _ := s.repo.FindUserByEmail(ctx, email)
 	if existing != nil {
 		return "", ErrUserAlreadyExists
 	}
 
+	if err := ValidatePassword(password); err != nil {
+		return "", err
+	}
+
 	// Hashear contraseña
 	hash, err := platform.HashPassword(password)
 	if err != nil {
@@ -36,19 +55,113 @@ func (s *Service) Register(email, name, password string) (userID string, err err
 	}
 
 	// Crear usuario
-	return s.repo.CreateUser(email, name, hash)
+	return s.repo.CreateUser(ctx, email, name, hash)
 }
 
 // Login autentica a un usuario
-func (s *Service) Login(email, password string) (*User, error) {
-	user, err := s.repo.FindUserByEmail(email)
+func (s *Service) Login(ctx context.Context, email, password string) (*User, error) {
+	user, err := s.repo.FindUserByEmail(ctx, email)
 	if err != nil || user == nil {
 		return nil, ErrInvalidCredentials
 	}
 
+	// Los usuarios creados por SSO no tienen contraseña
+	if user.PasswordHash == "" {
+		return nil, ErrInvalidCredentials
+	}
 	if err := platform.CheckPassword(user.PasswordHash, password); err != nil {
 		return nil, ErrInvalidCredentials
 	}
 
 	return user, nil
 }
+
+// StartSession emite el primer refresh token de una nueva sesión (familia)
+func (s *Service) StartSession(ctx context.Context, userID, userAgent, ip string) (plain string, err error) {
+	plain, hash := platform.MakeRefreshPair()
+	t := s.newRefreshToken(userID, "", hash, userAgent, ip)
+	if err := s.repo.StoreRefreshToken(ctx, t); err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// Rotate canjea un refresh token por uno nuevo de la misma familia.
+//
+// Si el token presentado ya había sido rotado, alguien está reutilizando un
+// token robado (o el cliente legítimo lo hace con uno viejo): se revoca toda la
+// familia y se devuelve ErrRefreshTokenReused. El token devuelto en ese caso
+// permite identificar al usuario afectado.
+func (s *Service) Rotate(ctx context.Context, plain, userAgent, ip string) (old *RefreshToken, next string, err error) {
+	old, err = s.repo.FindRefreshTokenByHash(ctx, platform.HashRefreshToken(plain))
+	if err != nil {
+		return nil, "", err
+	}
+	if old == nil {
+		return nil, "", ErrInvalidRefreshToken
+	}
+
+	if old.RevokedAt != nil {
+		if old.RevokedReason == RevokedRotated {
+			return old, "", s.revokeReusedFamily(ctx, old)
+		}
+		return old, "", ErrInvalidRefreshToken
+	}
+	if time.Now().After(old.ExpiresAt) {
+		return old, "", ErrRefreshTokenExpired
+	}
+
+	next, hash := platform.MakeRefreshPair()
+	t := s.newRefreshToken(old.UserID, old.FamilyID, hash, userAgent, ip)
+	if err := s.repo.RotateRefreshToken(ctx, old.ID, t); err != nil {
+		if errors.Is(err, ErrRefreshTokenReused) {
+			// Otra petición rotó el mismo token a la vez
+			return old, "", s.revokeReusedFamily(ctx, old)
+		}
+		return old, "", err
+	}
+	return old, next, nil
+}
+
+// Logout revoca la sesión a la que pertenece el refresh token.
+// Devuelve el usuario dueño del token, o "" si el token no existe.
+func (s *Service) Logout(ctx context.Context, plain string) (userID string, err error) {
+	t, err := s.repo.FindRefreshTokenByHash(ctx, platform.HashRefreshToken(plain))
+	if err != nil || t == nil {
+		return "", err
+	}
+	if err := s.repo.RevokeRefreshFamily(ctx, t.UserID, t.FamilyID, RevokedLogout); err != nil &&
+		!errors.Is(err, ErrSessionNotFound) {
+		return t.UserID, err
+	}
+	return t.UserID, nil
+}
+
+// ListSessions devuelve las sesiones activas del usuario
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]*Session, error) {
+	return s.repo.FindActiveSessions(ctx, userID)
+}
+
+// RevokeSession cierra una sesión del usuario
+func (s *Service) RevokeSession(ctx context.Context, userID, sessionID string) error {
+	return s.repo.RevokeRefreshFamily(ctx, userID, sessionID, RevokedSession)
+}
+
+func (s *Service) revokeReusedFamily(ctx context.Context, t *RefreshToken) error {
+	if err := s.repo.RevokeRefreshFamily(ctx, t.UserID, t.FamilyID, RevokedReuseDetected); err != nil &&
+		!errors.Is(err, ErrSessionNotFound) {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+func (s *Service) newRefreshToken(userID, familyID, hash, userAgent, ip string) *RefreshToken {
+	return &RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hash,
+		UserAgent: userAgent,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(s.refreshTTL),
+	}
+}
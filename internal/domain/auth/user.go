@@ -1,23 +1,88 @@
 package auth
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // User representa un usuario del sistema
 type User struct {
-	ID           string    `db:"id"`
-	Email        string    `db:"email"`
-	Name         *string   `db:"name"`
-	PasswordHash string    `db:"password_hash"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID              string     `db:"id"`
+	Email           string     `db:"email"`
+	Name            *string    `db:"name"`
+	PasswordHash    string     `db:"password_hash"`
+	EmailVerifiedAt *time.Time `db:"email_verified_at"`
+	CreatedAt       time.Time  `db:"created_at"`
+	UpdatedAt       time.Time  `db:"updated_at"`
+}
+
+// Propósitos de los tokens de un solo uso enviados por email
+const (
+	PurposeEmailVerification = "email_verification"
+	PurposePasswordReset     = "password_reset"
+)
+
+// Motivos de revocación de un refresh token
+const (
+	RevokedRotated        = "rotated"
+	RevokedLogout         = "logout"
+	RevokedReuseDetected  = "reuse_detected"
+	RevokedSession        = "session_revoked"
+	RevokedPasswordChange = "password_changed"
+	RevokedAccountClaimed = "account_claimed"
+)
+
+// RefreshToken es un token de refresco almacenado (solo su hash).
+// Todos los tokens emitidos por rotación a partir de un mismo login
+// comparten FamilyID, que identifica la sesión.
+type RefreshToken struct {
+	ID            string
+	UserID        string
+	FamilyID      string
+	TokenHash     string
+	UserAgent     string
+	IP            string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	RevokedReason string
+}
+
+// Session es una familia de refresh tokens activa
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
 }
 
 // Repository define las operaciones de persistencia para usuarios
 type Repository interface {
-	CreateUser(email, name, passwordHash string) (string, error)
-	FindUserByEmail(email string) (*User, error)
-	FindUserByID(id string) (*User, error)
-	StoreRefreshToken(userID, tokenHash, userAgent, ip string) error
-	RevokeRefreshToken(userID, tokenHash string) error
-	FindRefreshToken(tokenHash string) (userID string, err error)
+	CreateUser(ctx context.Context, email, name, passwordHash string) (string, error)
+	FindUserByEmail(ctx context.Context, email string) (*User, error)
+	FindUserByID(ctx context.Context, id string) (*User, error)
+
+	StoreRefreshToken(ctx context.Context, t *RefreshToken) error
+	// FindRefreshTokenByHash devuelve el token aunque esté revocado o expirado
+	FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*RefreshToken, error)
+	// RotateRefreshToken revoca oldID (motivo "rotated") y guarda next de forma
+	// atómica. Devuelve ErrRefreshTokenReused si oldID ya estaba revocado.
+	RotateRefreshToken(ctx context.Context, oldID string, next *RefreshToken) error
+	// RevokeRefreshFamily revoca los tokens activos de la sesión familyID de
+	// userID. Devuelve ErrSessionNotFound si no había ninguno.
+	RevokeRefreshFamily(ctx context.Context, userID, familyID, reason string) error
+	FindActiveSessions(ctx context.Context, userID string) ([]*Session, error)
+	// RevokeAllRefreshTokens cierra todas las sesiones del usuario
+	RevokeAllRefreshTokens(ctx context.Context, userID, reason string) error
+
+	UpdatePassword(ctx context.Context, userID, passwordHash string) error
+	MarkEmailVerified(ctx context.Context, userID string) error
+	// CreateUserToken guarda un token de un solo uso e invalida los anteriores
+	// sin usar del mismo propósito
+	CreateUserToken(ctx context.Context, userID, purpose, tokenHash string, expiresAt time.Time) error
+	// ConsumeUserToken marca como usado un token vigente y devuelve su usuario;
+	// ErrInvalidUserToken si no existe, caducó o ya se usó
+	ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (userID string, err error)
 }
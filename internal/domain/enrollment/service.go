@@ -1,14 +1,17 @@
 package enrollment
 
 import (
-	"errors"
+	"context"
 	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 )
 
 var (
-	ErrEnrollTokenNotFound    = errors.New("enroll token not found")
-	ErrInvalidEnrollTokenData = errors.New("invalid enroll token data")
-	ErrUnauthorized           = errors.New("unauthorized")
+	ErrEnrollTokenNotFound    = apperr.New(apperr.Unauthenticated, "invalid token")
+	ErrEnrollTokenUsed        = apperr.New(apperr.Unauthenticated, "token already used")
+	ErrInvalidEnrollTokenData = apperr.New(apperr.InvalidArgument, "invalid token data")
+	ErrUnauthorized           = apperr.New(apperr.PermissionDenied, "unauthorized")
 )
 
 type Service struct {
@@ -19,23 +22,26 @@ func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) CreateEnrollToken(token string, userID string, expiresAt time.Time) error {
-	if token == "" || userID == "" {
+func (s *Service) CreateEnrollToken(ctx context.Context, token string, userID string, orgID string, expiresAt time.Time) error {
+	if token == "" || userID == "" || orgID == "" {
 		return ErrInvalidEnrollTokenData
 	}
-	return s.repo.CreateEnrollToken(token, userID, expiresAt)
+	return s.repo.CreateEnrollToken(ctx, token, userID, orgID, expiresAt)
 }
 
-func (s *Service) FindEnrollTokenByHash(token string) (*EnrollToken, error) {
+func (s *Service) FindEnrollTokenByHash(ctx context.Context, token string) (*EnrollToken, error) {
 	if token == "" {
 		return nil, ErrInvalidEnrollTokenData
 	}
-	return s.repo.FindEnrollTokenByHash(token)
+	return s.repo.FindEnrollTokenByHash(ctx, token)
 }
 
-func (s *Service) MarkTokenAsUsed(token string, usedAt time.Time) error {
+// ConsumeEnrollToken spends token. Concurrent calls with the same token
+// succeed at most once; the others, and calls with an expired token, get
+// ErrEnrollTokenUsed.
+func (s *Service) ConsumeEnrollToken(ctx context.Context, token string, now time.Time) error {
 	if token == "" {
 		return ErrInvalidEnrollTokenData
 	}
-	return s.repo.MarkTokenAsUsed(token, usedAt)
+	return s.repo.ConsumeEnrollToken(ctx, token, now)
 }
@@ -0,0 +1,50 @@
+package enrollment_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+func TestConsumeEnrollToken(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	userID, orgID := memory.SeedOrg(t, db)
+	svc := enrollment.NewService(memory.NewEnrollmentRepository(db))
+	now := time.Now()
+	for token, expires := range map[string]time.Time{"valid": now.Add(time.Hour), "expired": now.Add(-time.Second)} {
+		if err := svc.CreateEnrollToken(ctx, token, userID, orgID, expires); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Concurrent enrollments with one token: only one may consume it
+	const n = 10
+	errs := make(chan error, n)
+	for range n {
+		go func() { errs <- svc.ConsumeEnrollToken(ctx, "valid", now) }()
+	}
+	consumed := 0
+	for range n {
+		switch err := <-errs; {
+		case err == nil:
+			consumed++
+		case !errors.Is(err, enrollment.ErrEnrollTokenUsed):
+			t.Errorf("error = %v, want ErrEnrollTokenUsed", err)
+		}
+	}
+	if consumed != 1 {
+		t.Errorf("token consumed %d times, want 1", consumed)
+	}
+
+	if err := svc.ConsumeEnrollToken(ctx, "expired", now); !errors.Is(err, enrollment.ErrEnrollTokenUsed) {
+		t.Errorf("expired token: error = %v, want ErrEnrollTokenUsed", err)
+	}
+	if err := svc.ConsumeEnrollToken(ctx, "", now); !errors.Is(err, enrollment.ErrInvalidEnrollTokenData) {
+		t.Errorf("empty token: error = %v, want ErrInvalidEnrollTokenData", err)
+	}
+}
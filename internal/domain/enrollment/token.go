@@ -1,18 +1,25 @@
 package enrollment
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type EnrollToken struct {
-	ID         string     `db:"id"`
-	Token      string     `db:"token"`
-	UserID     string     `db:"user_id"`
-	ExpiresAt  time.Time  `db:"expires_at"`
-	ConsumedAt *time.Time `db:"consumed_at"`
-	CreatedAt  time.Time  `db:"created_at"`
+	ID             string     `db:"id"`
+	Token          string     `db:"token"`
+	UserID         string     `db:"user_id"`
+	OrganizationID string     `db:"organization_id"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+	ConsumedAt     *time.Time `db:"consumed_at"`
+	CreatedAt      time.Time  `db:"created_at"`
 }
 
 type Repository interface {
-	CreateEnrollToken(token string, userID string, expiresAt time.Time) error
-	MarkTokenAsUsed(token string, consumedAt time.Time) error
-	FindEnrollTokenByHash(token string) (*EnrollToken, error)
+	CreateEnrollToken(ctx context.Context, token string, userID string, orgID string, expiresAt time.Time) error
+	// ConsumeEnrollToken marks token as consumed at now, in one step with
+	// checking it is neither consumed nor expired; otherwise it returns
+	// ErrEnrollTokenUsed.
+	ConsumeEnrollToken(ctx context.Context, token string, now time.Time) error
+	FindEnrollTokenByHash(ctx context.Context, token string) (*EnrollToken, error)
 }
@@ -0,0 +1,36 @@
+package invitation
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+// Invitation is a pending offer for an email address to join an organization.
+// Only the hash of the token is stored; the plain token travels by email.
+type Invitation struct {
+	ID             string            `db:"id" json:"id"`
+	OrganizationID string            `db:"organization_id" json:"organization_id"`
+	Email          string            `db:"email" json:"email"`
+	Role           organization.Role `db:"role" json:"role"`
+	TokenHash      string            `db:"token_hash" json:"-"`
+	InvitedBy      *string           `db:"invited_by" json:"invited_by"`
+	ExpiresAt      time.Time         `db:"expires_at" json:"expires_at"`
+	AcceptedAt     *time.Time        `db:"accepted_at" json:"accepted_at,omitempty"`
+	DeclinedAt     *time.Time        `db:"declined_at" json:"declined_at,omitempty"`
+	CreatedAt      time.Time         `db:"created_at" json:"created_at"`
+}
+
+// Repository defines the persistence contract for invitations.
+type Repository interface {
+	// Create stores a new invitation, replacing any pending one for the same
+	// organization and email.
+	Create(ctx context.Context, inv *Invitation) (*Invitation, error)
+	FindByTokenHash(ctx context.Context, tokenHash string) (*Invitation, error)
+	FindPendingByOrganization(ctx context.Context, orgID string) ([]*Invitation, error)
+	// MarkAccepted and MarkDeclined only succeed on a still-pending invitation.
+	MarkAccepted(ctx context.Context, id string, at time.Time) error
+	MarkDeclined(ctx context.Context, id string, at time.Time) error
+	Delete(ctx context.Context, orgID, id string) error
+}
@@ -0,0 +1,126 @@
+package invitation
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// DefaultTTL is how long an invitation stays valid.
+const DefaultTTL = 7 * 24 * time.Hour
+
+var (
+	ErrInvitationNotFound    = apperr.New(apperr.NotFound, "invalid invitation")
+	ErrInvitationExpired     = apperr.New(apperr.Gone, "invitation expired")
+	ErrInvitationUsed        = apperr.New(apperr.FailedPrecondition, "invitation already used")
+	ErrInvalidInvitationData = apperr.New(apperr.InvalidArgument, "invalid invitation data")
+)
+
+type Service struct {
+	repo      Repository
+	mailer    platform.Mailer
+	acceptURL string
+}
+
+// NewService creates the invitation service. acceptURL is the frontend page
+// that receives the token as the "token" query parameter.
+func NewService(repo Repository, mailer platform.Mailer, acceptURL string) *Service {
+	return &Service{repo: repo, mailer: mailer, acceptURL: acceptURL}
+}
+
+// Invite creates an invitation on behalf of actor and emails the token to the
+// invitee. Members cannot invite someone with a role above their own.
+func (s *Service) Invite(ctx context.Context, actor *organization.Membership, orgName, email string, role organization.Role) (*Invitation, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil {
+		return nil, ErrInvalidInvitationData
+	}
+	if !role.Valid() {
+		return nil, organization.ErrInvalidRole
+	}
+	if !actor.Role.Can(organization.PermMembersManage) || role.Outranks(actor.Role) {
+		return nil, organization.ErrForbidden
+	}
+
+	plain, hash, err := platform.GenerateInvitationToken()
+	if err != nil {
+		return nil, err
+	}
+
+	inv, err := s.repo.Create(ctx, &Invitation{
+		OrganizationID: actor.OrganizationID,
+		Email:          addr.Address,
+		Role:           role,
+		TokenHash:      hash,
+		InvitedBy:      &actor.UserID,
+		ExpiresAt:      time.Now().Add(DefaultTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.mailer.Send(ctx, platform.Mail{
+		To:      inv.Email,
+		Subject: fmt.Sprintf("You have been invited to %s on Autohost", orgName),
+		Body: fmt.Sprintf(
+			"You have been invited to join %s as %s.\n\nAccept the invitation: %s\n\nThis link expires on %s.",
+			orgName, role, s.link(plain), inv.ExpiresAt.UTC().Format(time.RFC1123),
+		),
+	}); err != nil {
+		return nil, fmt.Errorf("send invitation: %w", err)
+	}
+	return inv, nil
+}
+
+// FindPending resolves a plain token to an invitation that can still be
+// accepted or declined.
+func (s *Service) FindPending(ctx context.Context, plain string) (*Invitation, error) {
+	if !strings.HasPrefix(plain, platform.InvitationTokenPrefix) {
+		return nil, ErrInvitationNotFound
+	}
+	inv, err := s.repo.FindByTokenHash(ctx, platform.HashInvitationToken(plain))
+	if err != nil {
+		return nil, err
+	}
+	if inv.AcceptedAt != nil || inv.DeclinedAt != nil {
+		return nil, ErrInvitationUsed
+	}
+	if time.Now().After(inv.ExpiresAt) {
+		return nil, ErrInvitationExpired
+	}
+	return inv, nil
+}
+
+// Accept marks a pending invitation as accepted.
+func (s *Service) Accept(ctx context.Context, inv *Invitation) error {
+	return s.repo.MarkAccepted(ctx, inv.ID, time.Now())
+}
+
+// Decline marks a pending invitation as declined.
+func (s *Service) Decline(ctx context.Context, inv *Invitation) error {
+	return s.repo.MarkDeclined(ctx, inv.ID, time.Now())
+}
+
+// ListPending returns the invitations of an organization that are still open.
+func (s *Service) ListPending(ctx context.Context, orgID string) ([]*Invitation, error) {
+	return s.repo.FindPendingByOrganization(ctx, orgID)
+}
+
+// Revoke deletes an invitation of the organization.
+func (s *Service) Revoke(ctx context.Context, orgID, id string) error {
+	return s.repo.Delete(ctx, orgID, id)
+}
+
+func (s *Service) link(token string) string {
+	if s.acceptURL == "" {
+		return token
+	}
+	return s.acceptURL + "?token=" + url.QueryEscape(token)
+}
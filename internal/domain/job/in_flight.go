@@ -0,0 +1,80 @@
+package job
+
+import (
+	"context"
+	"sync"
+)
+
+// inFlight tracks the jobs this instance delivered to a connected node that
+// have not reported a final result yet, so shutdown can wait for them.
+type inFlight struct {
+	mu      sync.Mutex
+	ids     map[string]string // job ID → node ID
+	changed chan struct{}     // closed and replaced whenever a job finishes
+}
+
+func newInFlight() *inFlight {
+	return &inFlight{ids: make(map[string]string), changed: make(chan struct{})}
+}
+
+func (f *inFlight) add(id, nodeID string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.ids[id] = nodeID
+}
+
+func (f *inFlight) done(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.ids[id]; !ok {
+		return
+	}
+	delete(f.ids, id)
+	f.notify()
+}
+
+// dropNode forgets every job delivered to nodeID and returns their IDs.
+func (f *inFlight) dropNode(nodeID string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var dropped []string
+	for id, n := range f.ids {
+		if n == nodeID {
+			delete(f.ids, id)
+			dropped = append(dropped, id)
+		}
+	}
+	if len(dropped) > 0 {
+		f.notify()
+	}
+	return dropped
+}
+
+// notify wakes up waiters; f.mu must be held.
+func (f *inFlight) notify() {
+	close(f.changed)
+	f.changed = make(chan struct{})
+}
+
+func (f *inFlight) len() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.ids)
+}
+
+// wait blocks until no job is in flight or ctx ends.
+func (f *inFlight) wait(ctx context.Context) error {
+	for {
+		f.mu.Lock()
+		n, changed := len(f.ids), f.changed
+		f.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
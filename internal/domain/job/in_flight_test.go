@@ -0,0 +1,48 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func deliver(t *testing.T, s *Service, j *Job) {
+	t.Helper()
+	if err := s.Deliver(context.Background(), j, func(context.Context) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestInFlightReleasedWithoutResult(t *testing.T) {
+	ctx := context.Background()
+	repo := &oneJobRepo{job: &Job{ID: "j1", NodeID: "n1", Status: StatusCompleted}}
+	s := NewService(repo, nil, OutputLimits{Inline: 10})
+
+	// A final report the job cannot take is the last one its node sends
+	deliver(t, s, repo.job)
+	if err := s.UpdateResult(ctx, "j1", "n1", StatusFailed, "", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("error = %v, want ErrInvalidTransition", err)
+	}
+	if n := s.InFlight(); n != 0 {
+		t.Errorf("in flight after a rejected final report = %d, want 0", n)
+	}
+
+	deliver(t, s, &Job{ID: "j2", NodeID: "n1"})
+	deliver(t, s, &Job{ID: "j3", NodeID: "n1"})
+	deliver(t, s, &Job{ID: "j4", NodeID: "n2"})
+	s.NodeDisconnected("n1")
+	if n := s.InFlight(); n != 1 {
+		t.Fatalf("in flight after n1 disconnected = %d, want 1", n)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := s.WaitInFlight(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitInFlight with n2's job pending = %v", err)
+	}
+
+	s.NodeDisconnected("n2")
+	if err := s.WaitInFlight(ctx); err != nil {
+		t.Errorf("WaitInFlight = %v", err)
+	}
+}
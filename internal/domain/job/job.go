@@ -1,10 +1,12 @@
 package job
 
 import (
-	"errors"
+	"context"
 	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
 )
 
 // JobStatus represents the lifecycle of a dispatched job.
@@ -18,10 +20,27 @@ const (
 )
 
 var (
-	ErrJobNotFound    = errors.New("job not found")
-	ErrInvalidJobData = errors.New("invalid job data")
+	ErrJobNotFound    = apperr.New(apperr.NotFound, "job not found")
+	ErrInvalidJobData = apperr.New(apperr.InvalidArgument, "invalid job data")
+	ErrInvalidFilter  = apperr.New(apperr.InvalidArgument, "invalid job filter")
+	// ErrInvalidTransition is returned when a status update does not follow
+	// the job state machine, e.g. a late "running" after "completed".
+	ErrInvalidTransition = apperr.New(apperr.FailedPrecondition, "invalid job status transition")
 )
 
+// Sort fields accepted by List. Every sort is keyset-paginated on
+// (field, id), so the fields must be NOT NULL columns.
+const (
+	SortCreatedAt   = "created_at"
+	SortCommandName = "command_name"
+)
+
+// SortFields lists the fields List can sort by.
+var SortFields = []string{SortCreatedAt, SortCommandName}
+
+// DefaultSort is newest first.
+var DefaultSort = platform.Sort{Field: SortCreatedAt, Desc: true}
+
 // Job represents a single execution request sent to a node.
 type Job struct {
 	ID          string                  `db:"id"`
@@ -34,12 +53,36 @@ type Job struct {
 	CreatedAt   time.Time               `db:"created_at"`
 	StartedAt   *time.Time              `db:"started_at"`
 	FinishedAt  *time.Time              `db:"finished_at"`
+	// OutputSize is the size of the output the node reported. When
+	// OutputTruncated is set, Output holds only its head and, if OutputBlob
+	// is set, the full output is in the OutputStore under that key.
+	OutputSize      int64  `db:"output_size"`
+	OutputTruncated bool   `db:"output_truncated"`
+	OutputBlob      string `db:"output_blob" json:"-"`
+}
+
+// ListFilter narrows a List query. Zero values are ignored, except Sort,
+// which the service defaults to DefaultSort.
+type ListFilter struct {
+	NodeID   string
+	Statuses []JobStatus
+	Since    *time.Time // created_at >= Since
+	Until    *time.Time // created_at < Until
+	Sort     platform.Sort
+	After    *platform.Cursor // start after this row
+	Limit    int
 }
 
 // Repository defines the persistence contract for jobs.
 type Repository interface {
-	Create(j *Job) (*Job, error)
-	FindByID(id string) (*Job, error)
-	FindByNodeID(nodeID string) ([]*Job, error)
-	UpdateStatus(id string, status JobStatus, output, errMsg string) error
+	Create(ctx context.Context, j *Job) (*Job, error)
+	FindByID(ctx context.Context, id string) (*Job, error)
+	// List returns the jobs matching f in f.Sort order, ties broken by id.
+	List(ctx context.Context, f ListFilter) ([]*Job, error)
+	// UpdateStatus moves the job id of node nodeID to status, setting
+	// started_at and finished_at as the transition requires. The check and
+	// the update are a single atomic step: it returns ErrJobNotFound when the
+	// job does not exist or belongs to another node, and ErrInvalidTransition
+	// when the job's current status cannot move to status.
+	UpdateStatus(ctx context.Context, id, nodeID string, status JobStatus, output Output, errMsg string) error
 }
@@ -0,0 +1,127 @@
+package job
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
+)
+
+// maxOpenLifecycles bounds the spans kept for jobs waiting for a result; past
+// it new jobs are not traced. Spans end when the job finishes or its node
+// disconnects, so the cap is only reached with that many jobs really running.
+const maxOpenLifecycles = 10000
+
+// Lifecycle span events, in order.
+const (
+	EventDispatched = "job.dispatched" // handed to a transport
+	EventAcked      = "job.acked"      // queued on the node's open connection
+	EventRunning    = "job.running"    // the node reported it started
+)
+
+// Events that end a lifecycle span without a result.
+const (
+	EventNodeDisconnected = "job.node_disconnected" // its node's connection ended
+	EventResultRejected   = "job.result_rejected"   // the final report broke the state machine
+)
+
+// lifecycles keeps one span per delivered job from dispatch until the node
+// reports a final result, so a single trace shows where the time went: the
+// API, Postgres, the dispatcher or the agent.
+type lifecycles struct {
+	mu    sync.Mutex
+	spans map[string]trace.Span
+	full  bool // maxOpenLifecycles was reached; logged once until spans free up
+}
+
+func newLifecycles() *lifecycles {
+	return &lifecycles{spans: make(map[string]trace.Span)}
+}
+
+// start opens the lifecycle span of j as a child of ctx and returns ctx with
+// it, which is what transports propagate to the agent.
+func (l *lifecycles) start(ctx context.Context, j *Job) context.Context {
+	ctx, span := tracing.Tracer().Start(ctx, "job "+j.CommandName,
+		trace.WithAttributes(
+			attribute.String("job.id", j.ID),
+			attribute.String("job.node_id", j.NodeID),
+			attribute.String("job.command_name", j.CommandName),
+			attribute.String("job.command_type", string(j.CommandType)),
+		),
+	)
+	if !span.IsRecording() {
+		return ctx
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.spans) >= maxOpenLifecycles {
+		if !l.full {
+			l.full = true
+			slog.Warn("too many open job lifecycles, new jobs are not traced", "open", len(l.spans))
+		}
+		span.End()
+		return ctx
+	}
+	l.full = false
+	l.spans[j.ID] = span
+	return ctx
+}
+
+func (l *lifecycles) event(id, name string) {
+	l.mu.Lock()
+	span := l.spans[id]
+	l.mu.Unlock()
+	if span != nil {
+		span.AddEvent(name)
+	}
+}
+
+// finish ends the span of job id with its final status.
+func (l *lifecycles) finish(id string, status JobStatus, errMsg string) {
+	l.mu.Lock()
+	span := l.spans[id]
+	delete(l.spans, id)
+	l.mu.Unlock()
+	if span == nil {
+		return
+	}
+	span.SetAttributes(attribute.String("job.status", string(status)))
+	if status == StatusFailed {
+		span.SetStatus(codes.Error, errMsg)
+	}
+	span.End()
+}
+
+// abandon ends the span of a job that could not be delivered; it stays
+// pending, so the span is not an error.
+func (l *lifecycles) abandon(id string, err error) {
+	l.mu.Lock()
+	span := l.spans[id]
+	delete(l.spans, id)
+	l.mu.Unlock()
+	if span == nil {
+		return
+	}
+	span.SetAttributes(attribute.Bool("job.delivered", false))
+	span.AddEvent("job.not_delivered", trace.WithAttributes(attribute.String("error", err.Error())))
+	span.End()
+}
+
+// drop ends the span of job id, whose result will not reach this instance,
+// with event saying why.
+func (l *lifecycles) drop(id, event string) {
+	l.mu.Lock()
+	span := l.spans[id]
+	delete(l.spans, id)
+	l.mu.Unlock()
+	if span == nil {
+		return
+	}
+	span.AddEvent(event)
+	span.End()
+}
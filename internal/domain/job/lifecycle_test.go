@@ -0,0 +1,111 @@
+package job
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+func TestLifecycleSpanCoversDispatchToResult(t *testing.T) {
+	rec := recordSpans(t)
+	l := newLifecycles()
+	j := &Job{ID: "job-1", NodeID: "node-1", CommandName: "backup", CommandType: "custom"}
+
+	ctx := l.start(context.Background(), j)
+	if ctx == context.Background() {
+		t.Fatal("start did not return a context with the span")
+	}
+	l.event(j.ID, EventDispatched)
+	l.event(j.ID, EventAcked)
+	l.event(j.ID, EventRunning)
+	if len(rec.Ended()) != 0 {
+		t.Fatal("span ended before the final result")
+	}
+	l.finish(j.ID, StatusFailed, "exit status 1")
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	var events []string
+	for _, e := range spans[0].Events() {
+		events = append(events, e.Name)
+	}
+	if want := []string{EventDispatched, EventAcked, EventRunning}; !slices.Equal(events, want) {
+		t.Errorf("events = %v, want %v", events, want)
+	}
+	if st := spans[0].Status(); st.Code != codes.Error || st.Description != "exit status 1" {
+		t.Errorf("status = %v", st)
+	}
+
+	l.finish(j.ID, StatusCompleted, "") // a duplicate result is ignored
+	if len(rec.Ended()) != 1 {
+		t.Error("span ended twice")
+	}
+}
+
+func TestLifecycleAbandonedWhenNotDelivered(t *testing.T) {
+	rec := recordSpans(t)
+	l := newLifecycles()
+	j := &Job{ID: "job-2", NodeID: "node-1", CommandName: "restart"}
+
+	l.start(context.Background(), j)
+	l.abandon(j.ID, errors.New("node not connected"))
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	if spans[0].Status().Code == codes.Error {
+		t.Error("an undelivered job stays pending and is not an error")
+	}
+	if len(l.spans) != 0 {
+		t.Error("span still tracked")
+	}
+}
+
+func TestLifecycleEndsWithoutResult(t *testing.T) {
+	rec := recordSpans(t)
+	repo := &oneJobRepo{job: &Job{ID: "j1", NodeID: "n1", Status: StatusCompleted}}
+	s := NewService(repo, nil, OutputLimits{Inline: 10})
+
+	deliver(t, s, repo.job)
+	deliver(t, s, &Job{ID: "j2", NodeID: "n2"})
+	if err := s.UpdateResult(context.Background(), "j1", "n1", StatusFailed, "", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Fatalf("error = %v, want ErrInvalidTransition", err)
+	}
+	s.NodeDisconnected("n2")
+
+	// job ID → last event of its span
+	ended := map[string]string{}
+	for _, span := range rec.Ended() {
+		for _, attr := range span.Attributes() {
+			if attr.Key == "job.id" {
+				events := span.Events()
+				ended[attr.Value.AsString()] = events[len(events)-1].Name
+			}
+		}
+	}
+	if want := map[string]string{"j1": EventResultRejected, "j2": EventNodeDisconnected}; !maps.Equal(ended, want) {
+		t.Errorf("ended spans = %v, want %v", ended, want)
+	}
+	if len(s.lifecycles.spans) != 0 {
+		t.Errorf("%d spans still tracked", len(s.lifecycles.spans))
+	}
+}
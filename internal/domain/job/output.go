@@ -0,0 +1,98 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+// ErrOutputUnavailable is returned when a job's full output lives in a blob
+// store this instance is not configured with.
+var ErrOutputUnavailable = apperr.New(apperr.Unavailable, "job output storage unavailable")
+
+// OutputStore keeps the full output of jobs whose output does not fit inline.
+// blob.Store satisfies it.
+type OutputStore interface {
+	Put(ctx context.Context, r io.Reader) (key string, err error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// OutputLimits bounds how much job output is kept. Zero means no limit.
+type OutputLimits struct {
+	// Inline is the most output stored on the job row itself. Longer output
+	// is cut with a marker and, when there is an OutputStore, kept whole in
+	// the store.
+	Inline int
+	// Max is the most output kept at all; the rest is dropped.
+	Max int
+}
+
+// Output is the result output as persisted by Repository.UpdateStatus.
+type Output struct {
+	Text      string // inline output, possibly cut with a marker
+	Size      int64  // size of the output the node reported, in bytes
+	Truncated bool   // Text is not the whole output
+	BlobKey   string // OutputStore key of the full output, if stored
+}
+
+// storesOutput reports whether prepareOutput may put output in the store.
+func (s *Service) storesOutput(output string) bool {
+	return s.outputs != nil && s.limits.Inline > 0 && len(output) > s.limits.Inline
+}
+
+// prepareOutput applies the limits to the output reported for job id,
+// moving output longer than the inline limit to the output store.
+func (s *Service) prepareOutput(ctx context.Context, id, output string) Output {
+	out := Output{Text: output, Size: int64(len(output))}
+	if s.limits.Max > 0 && len(output) > s.limits.Max {
+		kept := cut(output, s.limits.Max)
+		output = kept + fmt.Sprintf("\n[output truncated: %d bytes dropped]\n", len(output)-len(kept))
+		out.Text, out.Truncated = output, true
+	}
+	if s.limits.Inline <= 0 || len(output) <= s.limits.Inline {
+		return out
+	}
+
+	head := cut(output, s.limits.Inline)
+	out.Truncated = true
+	if s.outputs != nil {
+		key, err := s.outputs.Put(ctx, strings.NewReader(output))
+		if err == nil {
+			out.BlobKey = key
+			out.Text = head + fmt.Sprintf("\n[output truncated: showing %d of %d bytes; full output at GET /v1/jobs/%s/output]\n", len(head), len(output), id)
+			return out
+		}
+		slog.Error("store job output", "job_id", id, "error", err)
+	}
+	out.Text = head + fmt.Sprintf("\n[output truncated: showing %d of %d bytes]\n", len(head), len(output))
+	return out
+}
+
+// cut returns at most n leading bytes of s without splitting a UTF-8
+// sequence.
+func cut(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+	return s[:n]
+}
+
+// OpenOutput returns the full output of j: the blob when the output was
+// moved to the output store, the inline output otherwise.
+func (s *Service) OpenOutput(ctx context.Context, j *Job) (io.ReadCloser, error) {
+	if j.OutputBlob == "" {
+		return io.NopCloser(strings.NewReader(j.Output)), nil
+	}
+	if s.outputs == nil {
+		return nil, ErrOutputUnavailable
+	}
+	return s.outputs.Open(ctx, j.OutputBlob)
+}
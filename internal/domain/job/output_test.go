@@ -0,0 +1,189 @@
+package job
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// mapStore is an in-memory OutputStore keyed by a counter.
+type mapStore struct {
+	blobs map[string]string
+	fail  bool
+}
+
+func (m *mapStore) Put(_ context.Context, r io.Reader) (string, error) {
+	if m.fail {
+		return "", errors.New("disk full")
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	key := strings.Repeat("0", 63) + string(rune('a'+len(m.blobs)))
+	m.blobs[key] = string(data)
+	return key, nil
+}
+
+func (m *mapStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, errors.New("no such blob")
+	}
+	return io.NopCloser(strings.NewReader(data)), nil
+}
+
+func TestPrepareOutput(t *testing.T) {
+	ctx := context.Background()
+	limits := OutputLimits{Inline: 10, Max: 100}
+
+	t.Run("short output stays inline", func(t *testing.T) {
+		s := NewService(nil, &mapStore{blobs: map[string]string{}}, limits)
+		out := s.prepareOutput(ctx, "j1", "ok")
+		if out != (Output{Text: "ok", Size: 2}) {
+			t.Errorf("prepareOutput = %+v", out)
+		}
+	})
+
+	t.Run("long output moves to the store", func(t *testing.T) {
+		store := &mapStore{blobs: map[string]string{}}
+		s := NewService(nil, store, limits)
+		full := strings.Repeat("x", 50)
+		out := s.prepareOutput(ctx, "j1", full)
+		if !out.Truncated || out.Size != 50 || out.BlobKey == "" || store.blobs[out.BlobKey] != full {
+			t.Fatalf("prepareOutput = %+v", out)
+		}
+		if !strings.HasPrefix(out.Text, strings.Repeat("x", 10)+"\n[output truncated: showing 10 of 50 bytes; full output at GET /v1/jobs/j1/output]") {
+			t.Errorf("inline output = %q", out.Text)
+		}
+
+		rc, err := s.OpenOutput(ctx, &Job{Output: out.Text, OutputBlob: out.BlobKey})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, _ := io.ReadAll(rc)
+		if string(got) != full {
+			t.Errorf("OpenOutput = %q", got)
+		}
+	})
+
+	t.Run("output over max is dropped", func(t *testing.T) {
+		store := &mapStore{blobs: map[string]string{}}
+		s := NewService(nil, store, limits)
+		out := s.prepareOutput(ctx, "j1", strings.Repeat("y", 300))
+		kept := store.blobs[out.BlobKey]
+		if out.Size != 300 || !strings.HasPrefix(kept, strings.Repeat("y", 100)+"\n[output truncated: 200 bytes dropped]") {
+			t.Errorf("prepareOutput = %+v, stored %q", out, kept)
+		}
+	})
+
+	t.Run("without a store output is only cut", func(t *testing.T) {
+		for _, store := range []OutputStore{nil, &mapStore{fail: true}} {
+			s := NewService(nil, store, limits)
+			out := s.prepareOutput(ctx, "j1", strings.Repeat("z", 50))
+			if !out.Truncated || out.BlobKey != "" || out.Text != strings.Repeat("z", 10)+"\n[output truncated: showing 10 of 50 bytes]\n" {
+				t.Errorf("prepareOutput = %+v", out)
+			}
+		}
+	})
+
+	t.Run("cuts on a rune boundary", func(t *testing.T) {
+		s := NewService(nil, nil, OutputLimits{Inline: 10})
+		out := s.prepareOutput(ctx, "j1", strings.Repeat("é", 20)) // 2 bytes each
+		head, _, _ := strings.Cut(out.Text, "\n")
+		if !utf8.ValidString(out.Text) || head != strings.Repeat("é", 5) {
+			t.Errorf("inline output = %q", out.Text)
+		}
+	})
+
+	t.Run("zero limits keep everything", func(t *testing.T) {
+		s := NewService(nil, nil, OutputLimits{})
+		big := string(bytes.Repeat([]byte("a"), 1<<20))
+		if out := s.prepareOutput(ctx, "j1", big); out.Truncated || out.Text != big {
+			t.Errorf("prepareOutput truncated with no limits")
+		}
+	})
+}
+
+func TestOpenOutputWithoutStore(t *testing.T) {
+	s := NewService(nil, nil, OutputLimits{})
+	if _, err := s.OpenOutput(context.Background(), &Job{OutputBlob: strings.Repeat("a", 64)}); !errors.Is(err, ErrOutputUnavailable) {
+		t.Errorf("error = %v, want ErrOutputUnavailable", err)
+	}
+	rc, err := s.OpenOutput(context.Background(), &Job{Output: "inline"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(rc); string(got) != "inline" {
+		t.Errorf("OpenOutput = %q", got)
+	}
+}
+
+// oneJobRepo holds a single job and applies UpdateStatus like the real
+// repositories; the rest of Repository is not needed here.
+type oneJobRepo struct {
+	Repository
+	job *Job
+}
+
+func (r *oneJobRepo) FindByID(_ context.Context, id string) (*Job, error) {
+	if id != r.job.ID {
+		return nil, ErrJobNotFound
+	}
+	c := *r.job
+	return &c, nil
+}
+
+func (r *oneJobRepo) UpdateStatus(_ context.Context, id, nodeID string, status JobStatus, out Output, _ string) error {
+	if id != r.job.ID || nodeID != r.job.NodeID {
+		return ErrJobNotFound
+	}
+	if !CanTransition(r.job.Status, status) {
+		return ErrInvalidTransition
+	}
+	r.job.Status, r.job.OutputBlob = status, out.BlobKey
+	return nil
+}
+
+func TestUpdateResultStoresOnlyAcceptedOutput(t *testing.T) {
+	ctx := context.Background()
+	store := &mapStore{blobs: map[string]string{}}
+	repo := &oneJobRepo{job: &Job{ID: "j1", NodeID: "n1", Status: StatusPending}}
+	s := NewService(repo, store, OutputLimits{Inline: 10})
+	long := strings.Repeat("x", 50)
+
+	rejected := []struct {
+		name   string
+		id     string
+		nodeID string
+		want   error
+	}{
+		{"unknown job", "j2", "n1", ErrJobNotFound},
+		{"another node's job", "j1", "n2", ErrJobNotFound},
+	}
+	for _, tt := range rejected {
+		if err := s.UpdateResult(ctx, tt.id, tt.nodeID, StatusCompleted, long, ""); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+	if len(store.blobs) != 0 {
+		t.Fatalf("rejected reports stored %d blobs", len(store.blobs))
+	}
+
+	if err := s.UpdateResult(ctx, "j1", "n1", StatusCompleted, long, ""); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.blobs) != 1 || repo.job.OutputBlob == "" {
+		t.Fatalf("accepted report: %d blobs, job blob %q", len(store.blobs), repo.job.OutputBlob)
+	}
+	if err := s.UpdateResult(ctx, "j1", "n1", StatusFailed, long+"y", ""); !errors.Is(err, ErrInvalidTransition) {
+		t.Errorf("out-of-order report: error = %v, want ErrInvalidTransition", err)
+	}
+	if len(store.blobs) != 1 {
+		t.Errorf("out-of-order report stored a blob")
+	}
+}
@@ -1,18 +1,43 @@
 package job
 
-import nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
 
 type Service struct {
-	repo Repository
+	repo       Repository
+	outputs    OutputStore
+	limits     OutputLimits
+	inFlight   *inFlight
+	lifecycles *lifecycles
 }
 
-func NewService(repo Repository) *Service {
-	return &Service{repo: repo}
+// NewService returns a job service. outputs may be nil, in which case output
+// over limits.Inline is only truncated.
+func NewService(repo Repository, outputs OutputStore, limits OutputLimits) *Service {
+	return &Service{
+		repo:       repo,
+		outputs:    outputs,
+		limits:     limits,
+		inFlight:   newInFlight(),
+		lifecycles: newLifecycles(),
+	}
 }
 
 // Dispatch creates a new pending job and returns it so the caller can send it
 // via WebSocket.
-func (s *Service) Dispatch(nodeID, commandName string, commandType nodecommand.CommandType) (*Job, error) {
+func (s *Service) Dispatch(ctx context.Context, nodeID, commandName string, commandType nodecommand.CommandType) (*Job, error) {
 	if nodeID == "" || commandName == "" {
 		return nil, ErrInvalidJobData
 	}
@@ -25,20 +50,159 @@ func (s *Service) Dispatch(nodeID, commandName string, commandType nodecommand.C
 		CommandType: commandType,
 		Status:      StatusPending,
 	}
-	return s.repo.Create(j)
+	return s.repo.Create(ctx, j)
 }
 
 // GetByID returns a job by its ID.
-func (s *Service) GetByID(id string) (*Job, error) {
-	return s.repo.FindByID(id)
+func (s *Service) GetByID(ctx context.Context, id string) (*Job, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// List returns one page of jobs and the cursor of the next page, or nil when
+// there are no more jobs.
+func (s *Service) List(ctx context.Context, f ListFilter) ([]*Job, *platform.Cursor, error) {
+	if f.Sort.Field == "" {
+		f.Sort = DefaultSort
+	}
+	if !slices.Contains(SortFields, f.Sort.Field) {
+		return nil, nil, ErrInvalidFilter
+	}
+	for _, st := range f.Statuses {
+		if !validStatus(st) {
+			return nil, nil, ErrInvalidFilter
+		}
+	}
+	// A cursor only makes sense in the order it was produced in
+	if f.After != nil && f.After.Sort != f.Sort.String() {
+		return nil, nil, ErrInvalidFilter
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit > MaxPageSize {
+		f.Limit = MaxPageSize
+	}
+
+	// Ask for one extra row to know whether there is another page
+	limit := f.Limit
+	f.Limit = limit + 1
+	jobs, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(jobs) <= limit {
+		return jobs, nil, nil
+	}
+	jobs = jobs[:limit]
+	last := jobs[limit-1]
+	next := &platform.Cursor{Sort: f.Sort.String(), ID: last.ID}
+	switch f.Sort.Field {
+	case SortCreatedAt:
+		next.Value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortCommandName:
+		next.Value = last.CommandName
+	}
+	return jobs, next, nil
+}
+
+func validStatus(st JobStatus) bool {
+	switch st {
+	case StatusPending, StatusRunning, StatusCompleted, StatusFailed:
+		return true
+	}
+	return false
+}
+
+// UpdateResult is called when node nodeID reports back the execution result
+// of job id. Reports for another node's job, and reports that do not follow
+// the state machine (see CanTransition), are rejected without changing the
+// job. Output over the configured limits is truncated; see OutputLimits.
+func (s *Service) UpdateResult(ctx context.Context, id, nodeID string, status JobStatus, output, errMsg string) error {
+	if id == "" || nodeID == "" || !validStatus(status) {
+		return ErrInvalidJobData
+	}
+	if err := s.updateStatus(ctx, id, nodeID, status, output, errMsg); err != nil {
+		// The node considers the job over and will not report it again
+		if status.Final() && errors.Is(err, ErrInvalidTransition) {
+			s.inFlight.done(id)
+			s.lifecycles.drop(id, EventResultRejected)
+		}
+		return err
+	}
+	switch status {
+	case StatusRunning:
+		s.lifecycles.event(id, EventRunning)
+	case StatusCompleted, StatusFailed:
+		s.inFlight.done(id)
+		s.lifecycles.finish(id, status, errMsg)
+	}
+	return nil
+}
+
+func (s *Service) updateStatus(ctx context.Context, id, nodeID string, status JobStatus, output, errMsg string) error {
+	// Check the report before its output reaches the store: a rejected report
+	// must not leave a blob behind. UpdateStatus checks again atomically.
+	if s.storesOutput(output) {
+		if err := s.checkReport(ctx, id, nodeID, status); err != nil {
+			return err
+		}
+	}
+	return s.repo.UpdateStatus(ctx, id, nodeID, status, s.prepareOutput(ctx, id, output), errMsg)
+}
+
+// checkReport returns the error UpdateStatus would return for the report,
+// without changing the job.
+func (s *Service) checkReport(ctx context.Context, id, nodeID string, status JobStatus) error {
+	j, err := s.repo.FindByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	if j.NodeID != nodeID {
+		return ErrJobNotFound
+	}
+	if !CanTransition(j.Status, status) {
+		return ErrInvalidTransition
+	}
+	return nil
+}
+
+// Deliver sends j to its node with send. A delivered job counts as in flight
+// until the node reports a final result or its connection ends (see
+// NodeDisconnected); tracking starts before send so a result that arrives
+// straight away is not missed.
+//
+// Deliver also opens the job's lifecycle span under ctx. send receives a
+// context carrying that span, which transports propagate to the agent.
+func (s *Service) Deliver(ctx context.Context, j *Job, send func(ctx context.Context) error) error {
+	ctx = s.lifecycles.start(ctx, j)
+	s.inFlight.add(j.ID, j.NodeID)
+	s.lifecycles.event(j.ID, EventDispatched)
+	if err := send(ctx); err != nil {
+		s.inFlight.done(j.ID)
+		s.lifecycles.abandon(j.ID, err)
+		return err
+	}
+	s.lifecycles.event(j.ID, EventAcked)
+	return nil
+}
+
+// NodeDisconnected stops waiting for the jobs delivered to nodeID, called when
+// its connection to this instance ends: their results cannot arrive here any
+// more, so their lifecycle spans end too. The jobs keep their status in the
+// repository.
+func (s *Service) NodeDisconnected(nodeID string) {
+	for _, id := range s.inFlight.dropNode(nodeID) {
+		s.lifecycles.drop(id, EventNodeDisconnected)
+	}
 }
 
-// ListByNode returns all jobs for a given node.
-func (s *Service) ListByNode(nodeID string) ([]*Job, error) {
-	return s.repo.FindByNodeID(nodeID)
+// InFlight returns how many delivered jobs are still waiting for a result.
+func (s *Service) InFlight() int {
+	return s.inFlight.len()
 }
 
-// UpdateResult is called when the node reports back the execution result.
-func (s *Service) UpdateResult(id string, status JobStatus, output, errMsg string) error {
-	return s.repo.UpdateStatus(id, status, output, errMsg)
+// WaitInFlight blocks until every delivered job has reported a final result
+// or ctx ends, in which case it returns ctx.Err().
+func (s *Service) WaitInFlight(ctx context.Context) error {
+	return s.inFlight.wait(ctx)
 }
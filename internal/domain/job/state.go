@@ -0,0 +1,38 @@
+package job
+
+import "slices"
+
+// A job starts pending and moves forward only:
+//
+//	pending ──▶ running ──▶ completed | failed
+//	   └───────────────────▶ completed | failed
+//
+// A node may skip running when the job ends before it could report it (a
+// quick command, or one that fails to start). completed and failed are
+// final; any later report for the job is out of order and rejected.
+var transitions = map[JobStatus][]JobStatus{
+	StatusPending: {StatusRunning, StatusCompleted, StatusFailed},
+	StatusRunning: {StatusCompleted, StatusFailed},
+}
+
+// Final reports whether no transition leaves s.
+func (s JobStatus) Final() bool {
+	return s == StatusCompleted || s == StatusFailed
+}
+
+// CanTransition reports whether a job in status from may move to status to.
+func CanTransition(from, to JobStatus) bool {
+	return slices.Contains(transitions[from], to)
+}
+
+// TransitionSources returns the statuses a job can move to status to from,
+// in a stable order. It is empty when to cannot be reached at all.
+func TransitionSources(to JobStatus) []JobStatus {
+	var from []JobStatus
+	for _, st := range []JobStatus{StatusPending, StatusRunning, StatusCompleted, StatusFailed} {
+		if CanTransition(st, to) {
+			from = append(from, st)
+		}
+	}
+	return from
+}
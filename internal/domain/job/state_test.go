@@ -0,0 +1,34 @@
+package job
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCanTransition(t *testing.T) {
+	statuses := []JobStatus{StatusPending, StatusRunning, StatusCompleted, StatusFailed}
+	allowed := map[[2]JobStatus]bool{
+		{StatusPending, StatusRunning}:   true,
+		{StatusPending, StatusCompleted}: true,
+		{StatusPending, StatusFailed}:    true,
+		{StatusRunning, StatusCompleted}: true,
+		{StatusRunning, StatusFailed}:    true,
+	}
+	for _, from := range statuses {
+		for _, to := range statuses {
+			if got := CanTransition(from, to); got != allowed[[2]JobStatus{from, to}] {
+				t.Errorf("CanTransition(%s, %s) = %v", from, to, got)
+			}
+		}
+	}
+
+	if got := TransitionSources(StatusCompleted); !slices.Equal(got, []JobStatus{StatusPending, StatusRunning}) {
+		t.Errorf("TransitionSources(completed) = %v", got)
+	}
+	if got := TransitionSources(StatusPending); len(got) != 0 {
+		t.Errorf("TransitionSources(pending) = %v", got)
+	}
+	if got := TransitionSources("cancelled"); len(got) != 0 {
+		t.Errorf("TransitionSources(cancelled) = %v", got)
+	}
+}
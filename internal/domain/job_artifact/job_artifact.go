@@ -0,0 +1,69 @@
+package jobartifact
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+var (
+	ErrArtifactNotFound = apperr.New(apperr.NotFound, "artifact not found")
+	ErrArtifactExists   = apperr.New(apperr.AlreadyExists, "artifact already exists")
+	ErrArtifactExpired  = apperr.New(apperr.Gone, "artifact expired")
+	ErrArtifactTooLarge = apperr.New(apperr.InvalidArgument, "artifact too large")
+	ErrInvalidArtifact  = apperr.New(apperr.InvalidArgument, "invalid artifact")
+	ErrChecksumMismatch = apperr.New(apperr.InvalidArgument, "artifact checksum mismatch")
+	ErrNoStorage        = apperr.New(apperr.Unavailable, "artifact storage not configured")
+)
+
+// Artifact is a file a node uploaded for one of its jobs, e.g. a database
+// dump. The content lives in a Store under its SHA-256; the row only holds
+// metadata.
+type Artifact struct {
+	ID          string    `db:"id" json:"id"`
+	JobID       string    `db:"job_id" json:"job_id"`
+	Name        string    `db:"name" json:"name"`
+	ContentType string    `db:"content_type" json:"content_type"`
+	Size        int64     `db:"size" json:"size"`
+	SHA256      string    `db:"sha256" json:"sha256"`
+	CreatedAt   time.Time `db:"created_at" json:"created_at"`
+	ExpiresAt   time.Time `db:"expires_at" json:"expires_at"`
+}
+
+// Expired reports whether the artifact is past its retention at t.
+func (a *Artifact) Expired(t time.Time) bool {
+	return !t.Before(a.ExpiresAt)
+}
+
+// Limits bounds what nodes may upload.
+type Limits struct {
+	// MaxSize is the largest artifact accepted, in bytes; zero means no limit.
+	MaxSize int64
+	// Retention is how long an artifact can be downloaded after upload.
+	Retention time.Duration
+}
+
+// Store keeps artifact contents addressed by their SHA-256. blob.Store
+// satisfies it.
+type Store interface {
+	Put(ctx context.Context, r io.Reader) (key string, err error)
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Repository defines the persistence contract for artifacts.
+type Repository interface {
+	// Create inserts a; it returns ErrArtifactExists when the job already
+	// has an artifact with that name.
+	Create(ctx context.Context, a *Artifact) (*Artifact, error)
+	Find(ctx context.Context, jobID, name string) (*Artifact, error)
+	// ListByJob returns the artifacts of a job ordered by name.
+	ListByJob(ctx context.Context, jobID string) ([]*Artifact, error)
+	// DeleteExpired deletes the artifacts expired at t and returns the
+	// checksums of the deleted rows.
+	DeleteExpired(ctx context.Context, t time.Time) ([]string, error)
+	// Referenced reports whether any artifact has checksum sha256.
+	Referenced(ctx context.Context, sha256 string) (bool, error)
+}
@@ -0,0 +1,185 @@
+package jobartifact
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+)
+
+// maxNameLen bounds artifact names, which end up in URLs and file names.
+const maxNameLen = 255
+
+type Service struct {
+	repo   Repository
+	jobs   *job.Service
+	store  Store
+	limits Limits
+}
+
+func NewService(repo Repository, jobs *job.Service, store Store, limits Limits) *Service {
+	return &Service{repo: repo, jobs: jobs, store: store, limits: limits}
+}
+
+// Upload is an artifact as sent by a node.
+type Upload struct {
+	JobID       string
+	Name        string
+	ContentType string
+	// SHA256 is the checksum the node computed, if any. The upload is
+	// rejected when the received content does not match it.
+	SHA256 string
+	Body   io.Reader
+}
+
+// Upload stores an artifact of job u.JobID, which must belong to node
+// nodeID. Uploading the same name and content again returns the existing
+// artifact, so an agent can retry an upload whose response it lost.
+func (s *Service) Upload(ctx context.Context, nodeID string, u Upload) (*Artifact, error) {
+	if s.store == nil {
+		return nil, ErrNoStorage
+	}
+	if !validName(u.Name) || u.Body == nil {
+		return nil, ErrInvalidArtifact
+	}
+	want := strings.ToLower(u.SHA256)
+	if want != "" && !blob.ValidKey(want) {
+		return nil, ErrInvalidArtifact
+	}
+	if u.ContentType == "" {
+		u.ContentType = "application/octet-stream"
+	}
+	j, err := s.jobs.GetByID(ctx, u.JobID)
+	if err != nil {
+		return nil, err
+	}
+	// Another node's job is reported as missing, as for job results
+	if j.NodeID != nodeID {
+		return nil, job.ErrJobNotFound
+	}
+
+	body := &countingReader{r: u.Body, max: s.limits.MaxSize}
+	key, err := s.store.Put(ctx, body)
+	if errors.Is(err, ErrArtifactTooLarge) {
+		return nil, ErrArtifactTooLarge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if want != "" && key != want {
+		s.release(ctx, key)
+		return nil, ErrChecksumMismatch
+	}
+
+	a, err := s.repo.Create(ctx, &Artifact{
+		JobID:       j.ID,
+		Name:        u.Name,
+		ContentType: u.ContentType,
+		Size:        body.n,
+		SHA256:      key,
+		ExpiresAt:   time.Now().Add(s.limits.Retention),
+	})
+	if errors.Is(err, ErrArtifactExists) {
+		existing, ferr := s.repo.Find(ctx, j.ID, u.Name)
+		if ferr == nil && existing.SHA256 == key {
+			return existing, nil
+		}
+		s.release(ctx, key)
+		return nil, ErrArtifactExists
+	}
+	if err != nil {
+		s.release(ctx, key)
+		return nil, err
+	}
+	return a, nil
+}
+
+// List returns the artifacts of a job, expired ones included.
+func (s *Service) List(ctx context.Context, jobID string) ([]*Artifact, error) {
+	return s.repo.ListByJob(ctx, jobID)
+}
+
+// Open returns an artifact and its content. The caller closes the reader.
+func (s *Service) Open(ctx context.Context, jobID, name string) (*Artifact, io.ReadCloser, error) {
+	if s.store == nil {
+		return nil, nil, ErrNoStorage
+	}
+	a, err := s.repo.Find(ctx, jobID, name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.Expired(time.Now()) {
+		return nil, nil, ErrArtifactExpired
+	}
+	rc, err := s.store.Open(ctx, a.SHA256)
+	if err != nil {
+		return nil, nil, err
+	}
+	return a, rc, nil
+}
+
+// Prune deletes the artifacts past their retention, and their content when
+// no other artifact shares it. It returns how many artifacts it deleted.
+func (s *Service) Prune(ctx context.Context) (int, error) {
+	keys, err := s.repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		return 0, err
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if !seen[key] {
+			seen[key] = true
+			s.release(ctx, key)
+		}
+	}
+	return len(keys), nil
+}
+
+// release deletes the content stored under key unless an artifact still
+// uses it. An upload of the same content racing with it can lose its
+// content; the download then fails with not found.
+func (s *Service) release(ctx context.Context, key string) {
+	used, err := s.repo.Referenced(ctx, key)
+	if err == nil && !used {
+		err = s.store.Delete(ctx, key)
+	}
+	if err != nil {
+		slog.Error("release artifact content", "sha256", key, "error", err)
+	}
+}
+
+// countingReader counts the bytes read and fails with ErrArtifactTooLarge
+// once more than max are read.
+type countingReader struct {
+	r   io.Reader
+	max int64
+	n   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	if c.max > 0 && c.n > c.max {
+		return n, ErrArtifactTooLarge
+	}
+	return n, err
+}
+
+// validName accepts names usable as a single path segment and file name.
+func validName(name string) bool {
+	if name == "" || len(name) > maxNameLen || name == "." || name == ".." {
+		return false
+	}
+	for _, r := range name {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,175 @@
+package jobartifact_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+type testEnv struct {
+	jobs   *job.Service
+	store  *blob.FSStore
+	nodeID string
+	// newService builds an artifact service over the shared repositories
+	newService func(jobartifact.Limits) *jobartifact.Service
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db := memory.NewDB()
+	userID, orgID := memory.SeedOrg(t, db)
+	n := memory.SeedNode(t, db, orgID, userID, "db-1")
+
+	store, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		jobs:   job.NewService(memory.NewJobRepository(db), nil, job.OutputLimits{}),
+		store:  store,
+		nodeID: n.ID,
+	}
+	repo := memory.NewJobArtifactRepository(db)
+	env.newService = func(l jobartifact.Limits) *jobartifact.Service {
+		return jobartifact.NewService(repo, env.jobs, store, l)
+	}
+	return env
+}
+
+func (env *testEnv) dispatch(t *testing.T) *job.Job {
+	t.Helper()
+	j, err := env.jobs.Dispatch(context.Background(), env.nodeID, "backup", nodecommand.CommandTypeCustom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return j
+}
+
+func upload(jobID, name, body string) jobartifact.Upload {
+	return jobartifact.Upload{JobID: jobID, Name: name, Body: strings.NewReader(body)}
+}
+
+func TestUpload(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	s := env.newService(jobartifact.Limits{MaxSize: 100, Retention: time.Hour})
+	j := env.dispatch(t)
+
+	a, err := s.Upload(ctx, env.nodeID, upload(j.ID, "dump.sql", "select 1;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Size != 9 || a.ContentType != "application/octet-stream" || a.SHA256 != blob.Key([]byte("select 1;")) {
+		t.Errorf("artifact = %+v", a)
+	}
+	got, rc, err := s.Open(ctx, j.ID, "dump.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := io.ReadAll(rc)
+	rc.Close()
+	if got.ID != a.ID || string(content) != "select 1;" {
+		t.Errorf("Open = %+v, %q", got, content)
+	}
+
+	t.Run("retry with the same content", func(t *testing.T) {
+		u := upload(j.ID, "dump.sql", "select 1;")
+		u.SHA256 = strings.ToUpper(a.SHA256)
+		again, err := s.Upload(ctx, env.nodeID, u)
+		if err != nil || again.ID != a.ID {
+			t.Errorf("Upload again = %+v, %v", again, err)
+		}
+	})
+
+	t.Run("same name, other content", func(t *testing.T) {
+		_, err := s.Upload(ctx, env.nodeID, upload(j.ID, "dump.sql", "select 2;"))
+		if !errors.Is(err, jobartifact.ErrArtifactExists) {
+			t.Errorf("error = %v, want ErrArtifactExists", err)
+		}
+		if _, err := env.store.Open(ctx, blob.Key([]byte("select 2;"))); !errors.Is(err, blob.ErrNotFound) {
+			t.Errorf("rejected content kept: %v", err)
+		}
+	})
+
+	t.Run("rejected uploads", func(t *testing.T) {
+		mismatch := upload(j.ID, "b", "data")
+		mismatch.SHA256 = blob.Key([]byte("other"))
+		tests := []struct {
+			name   string
+			nodeID string
+			u      jobartifact.Upload
+			want   error
+		}{
+			{"another node's job", "00000000-0000-0000-0000-000000000000", upload(j.ID, "a", "x"), job.ErrJobNotFound},
+			{"slash in name", env.nodeID, upload(j.ID, "../etc/passwd", "x"), jobartifact.ErrInvalidArtifact},
+			{"dot name", env.nodeID, upload(j.ID, "..", "x"), jobartifact.ErrInvalidArtifact},
+			{"control character", env.nodeID, upload(j.ID, "a\nb", "x"), jobartifact.ErrInvalidArtifact},
+			{"bad checksum", env.nodeID, jobartifact.Upload{JobID: j.ID, Name: "a", SHA256: "abc", Body: strings.NewReader("x")}, jobartifact.ErrInvalidArtifact},
+			{"checksum mismatch", env.nodeID, mismatch, jobartifact.ErrChecksumMismatch},
+			{"too large", env.nodeID, upload(j.ID, "big", strings.Repeat("x", 101)), jobartifact.ErrArtifactTooLarge},
+		}
+		for _, tt := range tests {
+			if _, err := s.Upload(ctx, tt.nodeID, tt.u); !errors.Is(err, tt.want) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+		if _, err := env.store.Open(ctx, blob.Key([]byte("data"))); !errors.Is(err, blob.ErrNotFound) {
+			t.Errorf("mismatched content kept: %v", err)
+		}
+	})
+
+	t.Run("without storage", func(t *testing.T) {
+		s := jobartifact.NewService(nil, env.jobs, nil, jobartifact.Limits{})
+		if _, err := s.Upload(ctx, env.nodeID, upload(j.ID, "a", "x")); !errors.Is(err, jobartifact.ErrNoStorage) {
+			t.Errorf("error = %v, want ErrNoStorage", err)
+		}
+	})
+}
+
+func TestExpiryAndPrune(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	// Zero retention: artifacts expire as soon as they are stored
+	expiring := env.newService(jobartifact.Limits{})
+	kept := env.newService(jobartifact.Limits{Retention: time.Hour})
+	j := env.dispatch(t)
+
+	if _, err := expiring.Upload(ctx, env.nodeID, upload(j.ID, "old.log", "shared")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := expiring.Upload(ctx, env.nodeID, upload(j.ID, "old.tar", "only old")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := kept.Upload(ctx, env.nodeID, upload(j.ID, "new.log", "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := kept.Open(ctx, j.ID, "old.log"); !errors.Is(err, jobartifact.ErrArtifactExpired) {
+		t.Errorf("Open expired = %v, want ErrArtifactExpired", err)
+	}
+
+	n, err := kept.Prune(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("Prune = %d, %v", n, err)
+	}
+	list, err := kept.List(ctx, j.ID)
+	if err != nil || len(list) != 1 || list[0].Name != "new.log" {
+		t.Errorf("List after prune = %+v, %v", list, err)
+	}
+	// Content still used by new.log survives; the rest is gone
+	if _, _, err := kept.Open(ctx, j.ID, "new.log"); err != nil {
+		t.Errorf("Open shared content = %v", err)
+	}
+	if _, err := env.store.Open(ctx, blob.Key([]byte("only old"))); !errors.Is(err, blob.ErrNotFound) {
+		t.Errorf("pruned content kept: %v", err)
+	}
+}
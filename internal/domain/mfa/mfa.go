@@ -0,0 +1,56 @@
+package mfa
+
+import (
+	"context"
+	"time"
+)
+
+// Enrollment es el estado TOTP de un usuario. SecretEncrypted sin EnabledAt
+// es un alta pendiente de confirmar con un primer código.
+type Enrollment struct {
+	UserID          string     `db:"id"`
+	SecretEncrypted []byte     `db:"totp_secret_encrypted"`
+	EnabledAt       *time.Time `db:"totp_enabled_at"`
+	LastStep        *int64     `db:"totp_last_step"`
+}
+
+// Status es el estado de MFA que ve el usuario
+type Status struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// Challenge es el segundo paso pendiente de un login con MFA
+type Challenge struct {
+	ID        string     `db:"id"`
+	UserID    string     `db:"user_id"`
+	TokenHash string     `db:"token_hash"`
+	Attempts  int        `db:"attempts"`
+	ExpiresAt time.Time  `db:"expires_at"`
+	UsedAt    *time.Time `db:"used_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+type Repository interface {
+	FindEnrollment(ctx context.Context, userID string) (*Enrollment, error)
+	// SetPendingSecret guarda el secreto de un alta; falla con
+	// ErrMFAAlreadyEnabled si el usuario ya tiene MFA activo
+	SetPendingSecret(ctx context.Context, userID string, secretEncrypted []byte) error
+	// Enable activa el secreto pendiente y guarda los códigos de recuperación
+	Enable(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error
+	Disable(ctx context.Context, userID string) error
+	// MarkStepUsed registra el intervalo TOTP usado; false si no es posterior
+	// al último (código reutilizado)
+	MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error)
+	// UseRecoveryCode consume un código sin usar; false si no existe
+	UseRecoveryCode(ctx context.Context, userID, codeHash string, at time.Time) (bool, error)
+	ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error
+	CountRecoveryCodes(ctx context.Context, userID string) (int, error)
+
+	CreateChallenge(ctx context.Context, c *Challenge) error
+	FindChallengeByHash(ctx context.Context, tokenHash string) (*Challenge, error)
+	IncrementChallengeAttempts(ctx context.Context, id string) error
+	// ConsumeChallenge marca el desafío como usado; false si ya lo estaba
+	ConsumeChallenge(ctx context.Context, id string, at time.Time) (bool, error)
+}
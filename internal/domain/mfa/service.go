@@ -0,0 +1,255 @@
+package mfa
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+var (
+	ErrMFANotConfigured    = apperr.New(apperr.Unavailable, "two-factor authentication not available")
+	ErrMFAAlreadyEnabled   = apperr.New(apperr.FailedPrecondition, "two-factor authentication already enabled")
+	ErrMFANotEnabled       = apperr.New(apperr.FailedPrecondition, "two-factor authentication not enabled")
+	ErrNoPendingEnrollment = apperr.New(apperr.FailedPrecondition, "start the enrollment first")
+	ErrInvalidCode         = apperr.New(apperr.InvalidArgument, "invalid mfa code")
+	ErrInvalidChallenge    = apperr.New(apperr.Unauthenticated, "invalid or expired mfa challenge")
+)
+
+const (
+	RecoveryCodeCount    = 10
+	ChallengeTTL         = 5 * time.Minute
+	MaxChallengeAttempts = 5
+)
+
+// Service gestiona TOTP, códigos de recuperación y el desafío del login
+type Service struct {
+	repo          Repository
+	encryptionKey []byte
+	issuer        string
+}
+
+// NewService crea el servicio. encryptionKey cifra los secretos TOTP; si es
+// nil el alta de MFA no está disponible. issuer es el nombre que muestran las
+// apps de autenticación.
+func NewService(repo Repository, encryptionKey []byte, issuer string) *Service {
+	return &Service{repo: repo, encryptionKey: encryptionKey, issuer: issuer}
+}
+
+// IsEnabled indica si el usuario tiene MFA activo
+func (s *Service) IsEnabled(ctx context.Context, userID string) (bool, error) {
+	en, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	return en.EnabledAt != nil, nil
+}
+
+// Status devuelve el estado de MFA del usuario
+func (s *Service) Status(ctx context.Context, userID string) (*Status, error) {
+	en, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	st := &Status{Enabled: en.EnabledAt != nil, EnabledAt: en.EnabledAt}
+	if st.Enabled {
+		if st.RecoveryCodesRemaining, err = s.repo.CountRecoveryCodes(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+	return st, nil
+}
+
+// BeginEnrollment genera un secreto TOTP pendiente y su URI de aprovisionamiento
+func (s *Service) BeginEnrollment(ctx context.Context, userID, account string) (secret, uri string, err error) {
+	if s.encryptionKey == nil {
+		return "", "", ErrMFANotConfigured
+	}
+
+	secret, err = platform.GenerateTOTPSecret()
+	if err != nil {
+		return "", "", err
+	}
+	sealed, err := platform.Seal(s.encryptionKey, []byte(secret))
+	if err != nil {
+		return "", "", err
+	}
+	if err := s.repo.SetPendingSecret(ctx, userID, sealed); err != nil {
+		return "", "", err
+	}
+	return secret, platform.TOTPProvisioningURI(secret, s.issuer, account), nil
+}
+
+// ConfirmEnrollment activa MFA si code corresponde al secreto pendiente y
+// devuelve los códigos de recuperación, que solo se muestran aquí.
+func (s *Service) ConfirmEnrollment(ctx context.Context, userID, code string) ([]string, error) {
+	en, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if en.EnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if en.SecretEncrypted == nil {
+		return nil, ErrNoPendingEnrollment
+	}
+
+	secret, err := s.secret(en)
+	if err != nil {
+		return nil, err
+	}
+	step, ok := platform.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidCode
+	}
+
+	codes, hashes, err := platform.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Enable(ctx, userID, step, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable desactiva MFA tras verificar un código TOTP o de recuperación
+func (s *Service) Disable(ctx context.Context, userID, code string) error {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return err
+	}
+	return s.repo.Disable(ctx, userID)
+}
+
+// Reset desactiva MFA sin pedir código, cuando la cuenta deja de estar en
+// manos de quien lo activó
+func (s *Service) Reset(ctx context.Context, userID string) error {
+	return s.repo.Disable(ctx, userID)
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación
+func (s *Service) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	if err := s.Verify(ctx, userID, code); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := platform.GenerateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userID, hashes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify comprueba un código TOTP (de un solo uso) o consume un código de
+// recuperación del usuario
+func (s *Service) Verify(ctx context.Context, userID, code string) error {
+	en, err := s.repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if en.EnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+
+	secret, err := s.secret(en)
+	if err != nil {
+		return err
+	}
+	if step, ok := platform.ValidateTOTP(secret, code, time.Now()); ok {
+		fresh, err := s.repo.MarkStepUsed(ctx, userID, step)
+		if err != nil {
+			return err
+		}
+		if !fresh {
+			return ErrInvalidCode
+		}
+		return nil
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userID, platform.HashRecoveryCode(code), time.Now())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidCode
+	}
+	return nil
+}
+
+// StartChallenge emite el token del segundo paso del login
+func (s *Service) StartChallenge(ctx context.Context, userID string) (token string, expiresAt time.Time, err error) {
+	token, hash, err := platform.GenerateMFAToken()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	c := &Challenge{
+		UserID:    userID,
+		TokenHash: hash,
+		ExpiresAt: time.Now().Add(ChallengeTTL),
+	}
+	if err := s.repo.CreateChallenge(ctx, c); err != nil {
+		return "", time.Time{}, err
+	}
+	return token, c.ExpiresAt, nil
+}
+
+// ChallengeUser devuelve el usuario de un desafío que aún admite intentos,
+// sin gastar ninguno; ErrInvalidChallenge si no existe, caducó o se agotó
+func (s *Service) ChallengeUser(ctx context.Context, token string) (userID string, err error) {
+	c, err := s.repo.FindChallengeByHash(ctx, platform.HashMFAToken(token))
+	if err != nil {
+		return "", err
+	}
+	if c.UsedAt != nil || time.Now().After(c.ExpiresAt) || c.Attempts >= MaxChallengeAttempts {
+		return c.UserID, ErrInvalidChallenge
+	}
+	return c.UserID, nil
+}
+
+// CompleteChallenge canjea el token del desafío y un código por el usuario
+// autenticado. Cada desafío admite MaxChallengeAttempts intentos y un solo uso.
+func (s *Service) CompleteChallenge(ctx context.Context, token, code string) (userID string, err error) {
+	c, err := s.repo.FindChallengeByHash(ctx, platform.HashMFAToken(token))
+	if errors.Is(err, ErrInvalidChallenge) {
+		return "", ErrInvalidChallenge
+	}
+	if err != nil {
+		return "", err
+	}
+	if c.UsedAt != nil || time.Now().After(c.ExpiresAt) || c.Attempts >= MaxChallengeAttempts {
+		return c.UserID, ErrInvalidChallenge
+	}
+
+	if err := s.Verify(ctx, c.UserID, code); err != nil {
+		if errors.Is(err, ErrInvalidCode) {
+			if err := s.repo.IncrementChallengeAttempts(ctx, c.ID); err != nil {
+				return c.UserID, err
+			}
+		}
+		return c.UserID, err
+	}
+
+	consumed, err := s.repo.ConsumeChallenge(ctx, c.ID, time.Now())
+	if err != nil {
+		return c.UserID, err
+	}
+	if !consumed {
+		return c.UserID, ErrInvalidChallenge
+	}
+	return c.UserID, nil
+}
+
+func (s *Service) secret(en *Enrollment) (string, error) {
+	if s.encryptionKey == nil {
+		return "", ErrMFANotConfigured
+	}
+	secret, err := platform.Open(s.encryptionKey, en.SecretEncrypted)
+	if err != nil {
+		return "", err
+	}
+	return string(secret), nil
+}
@@ -0,0 +1,181 @@
+package mfa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+// wrongCode has the length of a TOTP code but never matches one, which are
+// all digits.
+const wrongCode = "abcdef"
+
+type testEnv struct {
+	svc    *mfa.Service
+	repo   *memory.MFARepository
+	userID string
+	secret string
+	// recovery holds the codes returned when MFA was enabled
+	recovery []string
+}
+
+// newTestEnv enrolls the seeded user in MFA.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewDB()
+	userID, _ := memory.SeedOrg(t, db)
+	env := &testEnv{repo: memory.NewMFARepository(db), userID: userID}
+	env.svc = mfa.NewService(env.repo, make([]byte, 32), "test")
+
+	secret, _, err := env.svc.BeginEnrollment(ctx, userID, "owner@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.secret = secret
+	if _, err := env.svc.ConfirmEnrollment(ctx, userID, wrongCode); !errors.Is(err, mfa.ErrInvalidCode) {
+		t.Fatalf("ConfirmEnrollment with a wrong code: %v", err)
+	}
+	if env.recovery, err = env.svc.ConfirmEnrollment(ctx, userID, env.code(t, 0)); err != nil {
+		t.Fatal(err)
+	}
+	return env
+}
+
+// code returns the TOTP code steps intervals from now.
+func (env *testEnv) code(t *testing.T, steps int) string {
+	t.Helper()
+	c, err := platform.TOTPCode(env.secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestEnrollment(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	if len(env.recovery) != mfa.RecoveryCodeCount {
+		t.Errorf("recovery codes = %d, want %d", len(env.recovery), mfa.RecoveryCodeCount)
+	}
+	st, err := env.svc.Status(ctx, env.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Enabled || st.RecoveryCodesRemaining != mfa.RecoveryCodeCount {
+		t.Errorf("Status = %+v", st)
+	}
+	if _, err := env.svc.ConfirmEnrollment(ctx, env.userID, env.code(t, 1)); !errors.Is(err, mfa.ErrMFAAlreadyEnabled) {
+		t.Errorf("second ConfirmEnrollment: error = %v, want ErrMFAAlreadyEnabled", err)
+	}
+	if _, _, err := mfa.NewService(env.repo, nil, "test").BeginEnrollment(ctx, env.userID, "owner@example.com"); !errors.Is(err, mfa.ErrMFANotConfigured) {
+		t.Errorf("BeginEnrollment without a key: error = %v, want ErrMFANotConfigured", err)
+	}
+}
+
+// TestVerify runs codes in order against one enrolled user: TOTP steps are
+// single use and so are recovery codes.
+func TestVerify(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	tests := []struct {
+		name string
+		code string
+		want error
+	}{
+		{"step used to enroll", env.code(t, 0), mfa.ErrInvalidCode},
+		{"next step", env.code(t, 1), nil},
+		{"next step replayed", env.code(t, 1), mfa.ErrInvalidCode},
+		{"earlier step after a later one", env.code(t, -1), mfa.ErrInvalidCode},
+		{"outside the skew window", env.code(t, 3), mfa.ErrInvalidCode},
+		{"recovery code", env.recovery[0], nil},
+		{"recovery code reused", env.recovery[0], mfa.ErrInvalidCode},
+		{"another recovery code, retyped", " " + env.recovery[1][:4] + env.recovery[1][5:] + " ", nil},
+		{"garbage", "not-a-code", mfa.ErrInvalidCode},
+	}
+	for _, tt := range tests {
+		if err := env.svc.Verify(ctx, env.userID, tt.code); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	st, err := env.svc.Status(ctx, env.userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.RecoveryCodesRemaining != mfa.RecoveryCodeCount-2 {
+		t.Errorf("recovery codes remaining = %d, want %d", st.RecoveryCodesRemaining, mfa.RecoveryCodeCount-2)
+	}
+}
+
+func TestCompleteChallenge(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		// run sends codes for a fresh challenge and returns the last error
+		run  func(t *testing.T, env *testEnv, token string) error
+		want error
+	}{
+		{"correct code", func(t *testing.T, env *testEnv, token string) error {
+			_, err := env.svc.CompleteChallenge(ctx, token, env.code(t, 1))
+			return err
+		}, nil},
+		{"wrong code", func(t *testing.T, env *testEnv, token string) error {
+			_, err := env.svc.CompleteChallenge(ctx, token, wrongCode)
+			return err
+		}, mfa.ErrInvalidCode},
+		{"single use", func(t *testing.T, env *testEnv, token string) error {
+			if _, err := env.svc.CompleteChallenge(ctx, token, env.code(t, 1)); err != nil {
+				t.Fatal(err)
+			}
+			_, err := env.svc.CompleteChallenge(ctx, token, env.recovery[0])
+			return err
+		}, mfa.ErrInvalidChallenge},
+		{"out of attempts", func(t *testing.T, env *testEnv, token string) error {
+			for i := 0; i < mfa.MaxChallengeAttempts; i++ {
+				if _, err := env.svc.CompleteChallenge(ctx, token, wrongCode); !errors.Is(err, mfa.ErrInvalidCode) {
+					t.Fatalf("attempt %d: error = %v, want ErrInvalidCode", i+1, err)
+				}
+			}
+			_, err := env.svc.CompleteChallenge(ctx, token, env.code(t, 1))
+			return err
+		}, mfa.ErrInvalidChallenge},
+		{"unknown token", func(t *testing.T, env *testEnv, token string) error {
+			_, err := env.svc.CompleteChallenge(ctx, token+"x", env.code(t, 1))
+			return err
+		}, mfa.ErrInvalidChallenge},
+		{"expired", func(t *testing.T, env *testEnv, _ string) error {
+			token, hash, err := platform.GenerateMFAToken()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := env.repo.CreateChallenge(ctx, &mfa.Challenge{
+				UserID: env.userID, TokenHash: hash, ExpiresAt: time.Now().Add(-time.Second),
+			}); err != nil {
+				t.Fatal(err)
+			}
+			_, err = env.svc.CompleteChallenge(ctx, token, env.code(t, 1))
+			return err
+		}, mfa.ErrInvalidChallenge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t)
+			token, _, err := env.svc.StartChallenge(ctx, env.userID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.run(t, env, token); !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
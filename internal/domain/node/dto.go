@@ -5,16 +5,17 @@ import "time"
 // NodeWithMetrics representa un nodo con su última métrica
 type NodeWithMetrics struct {
 	// Datos del nodo
-	ID           string     `json:"id"`
-	Hostname     string     `json:"hostname"`
-	IPLocal      string     `json:"ip_local"`
-	OS           string     `json:"os"`
-	Arch         string     `json:"arch"`
-	VersionAgent string     `json:"version_agent"`
-	OwnerID      *string    `json:"owner_id"`
-	LastSeenAt   *time.Time `json:"last_seen_at"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time  `json:"updated_at"`
+	ID             string     `json:"id"`
+	Hostname       string     `json:"hostname"`
+	IPLocal        string     `json:"ip_local"`
+	OS             string     `json:"os"`
+	Arch           string     `json:"arch"`
+	VersionAgent   string     `json:"version_agent"`
+	OrganizationID string     `json:"organization_id"`
+	OwnerID        *string    `json:"owner_id"`
+	LastSeenAt     *time.Time `json:"last_seen_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 
 	// Última métrica (puede ser nil si no hay métricas)
 	LastMetric *LastMetric `json:"last_metric,omitempty"`
@@ -1,26 +1,55 @@
 package node
 
-import "time"
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
 
 // Node representa un nodo/servidor registrado en el sistema
 type Node struct {
-	ID           string     `db:"id"`
-	Hostname     string     `db:"hostname"`
-	IPLocal      string     `db:"ip_local"`
-	OS           string     `db:"os"`
-	Arch         string     `db:"arch"`
-	VersionAgent string     `db:"version_agent"`
-	OwnerID      *string    `db:"owner_id"`
-	LastSeenAt   *time.Time `db:"last_seen_at"`
-	CreatedAt    time.Time  `db:"created_at"`
-	UpdatedAt    time.Time  `db:"updated_at"`
+	ID             string     `db:"id"`
+	Hostname       string     `db:"hostname"`
+	IPLocal        string     `db:"ip_local"`
+	OS             string     `db:"os"`
+	Arch           string     `db:"arch"`
+	VersionAgent   string     `db:"version_agent"`
+	OrganizationID string     `db:"organization_id"`
+	OwnerID        *string    `db:"owner_id"` // usuario que enroló el nodo
+	LastSeenAt     *time.Time `db:"last_seen_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
+}
+
+// Campos por los que se puede ordenar el listado. Los nodos que nunca se
+// han visto ordenan con last_seen_at = epoch.
+const (
+	SortCreatedAt  = "created_at"
+	SortHostname   = "hostname"
+	SortLastSeenAt = "last_seen_at"
+)
+
+var SortFields = []string{SortCreatedAt, SortHostname, SortLastSeenAt}
+
+// DefaultSort muestra primero los nodos más recientes
+var DefaultSort = platform.Sort{Field: SortCreatedAt, Desc: true}
+
+// ListFilter acota un listado de nodos. Los valores vacíos no filtran.
+type ListFilter struct {
+	OrganizationID string
+	Hostname       string // búsqueda por subcadena, sin distinguir mayúsculas
+	Sort           platform.Sort
+	After          *platform.Cursor // empezar después de esta fila
+	Limit          int
 }
 
 // Repository define las operaciones de persistencia para nodos
 type Repository interface {
-	Register(node *Node) (*Node, error)
-	FindByID(id string) (*Node, error)
-	FindByOwnerID(ownerID string) ([]*Node, error)
-	FindByOwnerIDWithMetrics(ownerID string) ([]*NodeWithMetrics, error)
-	UpdateLastSeen(nodeID string) error
+	Register(ctx context.Context, node *Node) (*Node, error)
+	FindByID(ctx context.Context, id string) (*Node, error)
+	// List devuelve los nodos que cumplen f en el orden f.Sort, desempatando por id
+	List(ctx context.Context, f ListFilter) ([]*Node, error)
+	FindByOrganizationIDWithMetrics(ctx context.Context, orgID string) ([]*NodeWithMetrics, error)
+	UpdateLastSeen(ctx context.Context, nodeID string) error
 }
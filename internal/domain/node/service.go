@@ -1,11 +1,24 @@
 package node
 
-import "errors"
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
+)
 
 var (
-	ErrNodeNotFound    = errors.New("node not found")
-	ErrInvalidNodeData = errors.New("invalid node data")
-	ErrUnauthorized    = errors.New("unauthorized")
+	ErrNodeNotFound    = apperr.New(apperr.NotFound, "node not found")
+	ErrInvalidNodeData = apperr.New(apperr.InvalidArgument, "invalid node data")
+	ErrUnauthorized    = apperr.New(apperr.PermissionDenied, "unauthorized")
+	ErrInvalidFilter   = apperr.New(apperr.InvalidArgument, "invalid node filter")
 )
 
 type Service struct {
@@ -16,25 +29,89 @@ func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) Register(node *Node) (*Node, error) {
+func (s *Service) Register(ctx context.Context, node *Node) (*Node, error) {
 	if node.Hostname == "" {
 		return nil, ErrInvalidNodeData
 	}
-	return s.repo.Register(node)
+	return s.repo.Register(ctx, node)
 }
 
-func (s *Service) UpdateLastSeen(nodeID string) error {
+func (s *Service) UpdateLastSeen(ctx context.Context, nodeID string) error {
 	if nodeID == "" {
 		return ErrInvalidNodeData
 	}
-	return s.repo.UpdateLastSeen(nodeID)
+	return s.repo.UpdateLastSeen(ctx, nodeID)
+}
+
+func (s *Service) GetByID(ctx context.Context, id string) (*Node, error) {
+	if id == "" {
+		return nil, ErrNodeNotFound
+	}
+	return s.repo.FindByID(ctx, id)
+}
+
+// GetForOrganization obtiene un nodo solo si pertenece a la organización indicada.
+// Un nodo de otra organización se reporta como inexistente.
+func (s *Service) GetForOrganization(ctx context.Context, id, orgID string) (*Node, error) {
+	n, err := s.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if n.OrganizationID != orgID {
+		return nil, ErrNodeNotFound
+	}
+	return n, nil
 }
 
-func (s *Service) GetByOwner(ownerID string) ([]*Node, error) {
-	return s.repo.FindByOwnerID(ownerID)
+// List devuelve una página de nodos y el cursor de la siguiente, o nil si no
+// hay más
+func (s *Service) List(ctx context.Context, f ListFilter) ([]*Node, *platform.Cursor, error) {
+	if f.Sort.Field == "" {
+		f.Sort = DefaultSort
+	}
+	if !slices.Contains(SortFields, f.Sort.Field) {
+		return nil, nil, ErrInvalidFilter
+	}
+	// Un cursor solo vale para el orden con el que se generó
+	if f.After != nil && f.After.Sort != f.Sort.String() {
+		return nil, nil, ErrInvalidFilter
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit > MaxPageSize {
+		f.Limit = MaxPageSize
+	}
+
+	// Pedimos uno extra para saber si hay otra página
+	limit := f.Limit
+	f.Limit = limit + 1
+	nodes, err := s.repo.List(ctx, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nodes) <= limit {
+		return nodes, nil, nil
+	}
+	nodes = nodes[:limit]
+	last := nodes[limit-1]
+	next := &platform.Cursor{Sort: f.Sort.String(), ID: last.ID}
+	switch f.Sort.Field {
+	case SortCreatedAt:
+		next.Value = last.CreatedAt.UTC().Format(time.RFC3339Nano)
+	case SortHostname:
+		next.Value = last.Hostname
+	case SortLastSeenAt:
+		seen := time.Unix(0, 0)
+		if last.LastSeenAt != nil {
+			seen = *last.LastSeenAt
+		}
+		next.Value = seen.UTC().Format(time.RFC3339Nano)
+	}
+	return nodes, next, nil
 }
 
-// GetByOwnerWithMetrics obtiene todos los nodos de un propietario con sus últimas métricas
-func (s *Service) GetByOwnerWithMetrics(ownerID string) ([]*NodeWithMetrics, error) {
-	return s.repo.FindByOwnerIDWithMetrics(ownerID)
+// GetByOrganizationWithMetrics obtiene todos los nodos de una organización con sus últimas métricas
+func (s *Service) GetByOrganizationWithMetrics(ctx context.Context, orgID string) ([]*NodeWithMetrics, error) {
+	return s.repo.FindByOrganizationIDWithMetrics(ctx, orgID)
 }
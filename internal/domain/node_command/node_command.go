@@ -1,8 +1,10 @@
 package nodecommand
 
 import (
-	"errors"
+	"context"
 	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 )
 
 // CommandType distinguishes built-in commands from custom shell scripts.
@@ -14,9 +16,9 @@ const (
 )
 
 var (
-	ErrCommandNotFound      = errors.New("command not found")
-	ErrCommandAlreadyExists = errors.New("command already exists")
-	ErrInvalidCommandData   = errors.New("invalid command data")
+	ErrCommandNotFound      = apperr.New(apperr.NotFound, "command not found")
+	ErrCommandAlreadyExists = apperr.New(apperr.AlreadyExists, "command already exists")
+	ErrInvalidCommandData   = apperr.New(apperr.InvalidArgument, "invalid command data")
 )
 
 // NodeCommand represents a command available on a node.
@@ -34,8 +36,10 @@ type NodeCommand struct {
 
 // Repository defines the persistence contract for node commands.
 type Repository interface {
-	Upsert(cmd *NodeCommand) (*NodeCommand, error)
-	FindByNodeID(nodeID string) ([]*NodeCommand, error)
-	FindByID(id string) (*NodeCommand, error)
-	Delete(id string) error
+	Upsert(ctx context.Context, cmd *NodeCommand) (*NodeCommand, error)
+	FindByNodeID(ctx context.Context, nodeID string) ([]*NodeCommand, error)
+	FindByID(ctx context.Context, id string) (*NodeCommand, error)
+	// Delete removes the command id of node nodeID; ErrCommandNotFound if it
+	// does not exist or belongs to another node
+	Delete(ctx context.Context, id, nodeID string) error
 }
@@ -1,5 +1,7 @@
 package nodecommand
 
+import "context"
+
 type Service struct {
 	repo Repository
 }
@@ -9,22 +11,22 @@ func NewService(repo Repository) *Service {
 }
 
 // Register upserts a command for a node (called by the agent on startup / discovery).
-func (s *Service) Register(cmd *NodeCommand) (*NodeCommand, error) {
+func (s *Service) Register(ctx context.Context, cmd *NodeCommand) (*NodeCommand, error) {
 	if cmd.NodeID == "" || cmd.Name == "" {
 		return nil, ErrInvalidCommandData
 	}
 	if cmd.Type == "" {
 		cmd.Type = CommandTypeDefault
 	}
-	return s.repo.Upsert(cmd)
+	return s.repo.Upsert(ctx, cmd)
 }
 
 // ListByNode returns all commands registered for a given node.
-func (s *Service) ListByNode(nodeID string) ([]*NodeCommand, error) {
-	return s.repo.FindByNodeID(nodeID)
+func (s *Service) ListByNode(ctx context.Context, nodeID string) ([]*NodeCommand, error) {
+	return s.repo.FindByNodeID(ctx, nodeID)
 }
 
-// Delete removes a command by its ID.
-func (s *Service) Delete(id string) error {
-	return s.repo.Delete(id)
+// Delete removes the command id registered by node nodeID.
+func (s *Service) Delete(ctx context.Context, id, nodeID string) error {
+	return s.repo.Delete(ctx, id, nodeID)
 }
@@ -0,0 +1,41 @@
+package nodegroup
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+var (
+	ErrGroupNotFound  = apperr.New(apperr.NotFound, "node group not found")
+	ErrGroupExists    = apperr.New(apperr.AlreadyExists, "node group already exists")
+	ErrInvalidGroup   = apperr.New(apperr.InvalidArgument, "invalid node group")
+	ErrMemberNotFound = apperr.New(apperr.NotFound, "node is not in the group")
+)
+
+// Group is a named set of nodes of an organization, used to target several
+// nodes at once (e.g. when assigning scripts).
+type Group struct {
+	ID             string    `db:"id" json:"id"`
+	OrganizationID string    `db:"organization_id" json:"organization_id"`
+	Name           string    `db:"name" json:"name"`
+	Description    string    `db:"description" json:"description"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+}
+
+// Repository defines the persistence contract for node groups.
+type Repository interface {
+	// Create inserts g; it returns ErrGroupExists when the organization
+	// already has a group with that name.
+	Create(ctx context.Context, g *Group) (*Group, error)
+	Find(ctx context.Context, orgID, id string) (*Group, error)
+	// List returns the groups of an organization ordered by name.
+	List(ctx context.Context, orgID string) ([]*Group, error)
+	Delete(ctx context.Context, orgID, id string) error
+	// AddNode adds a node to a group; adding it again is not an error.
+	AddNode(ctx context.Context, groupID, nodeID string) error
+	RemoveNode(ctx context.Context, groupID, nodeID string) error
+	// NodeIDs returns the members of a group ordered by node ID.
+	NodeIDs(ctx context.Context, groupID string) ([]string, error)
+}
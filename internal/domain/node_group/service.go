@@ -0,0 +1,77 @@
+package nodegroup
+
+import (
+	"context"
+	"strings"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+)
+
+// maxNameLen bounds group names, which are shown in the dashboard.
+const maxNameLen = 100
+
+type Service struct {
+	repo  Repository
+	nodes *node.Service
+}
+
+func NewService(repo Repository, nodes *node.Service) *Service {
+	return &Service{repo: repo, nodes: nodes}
+}
+
+// Create adds a group to the organization orgID.
+func (s *Service) Create(ctx context.Context, orgID, name, description string) (*Group, error) {
+	name = strings.TrimSpace(name)
+	if orgID == "" || name == "" || len(name) > maxNameLen {
+		return nil, ErrInvalidGroup
+	}
+	return s.repo.Create(ctx, &Group{OrganizationID: orgID, Name: name, Description: description})
+}
+
+// Get returns a group of the organization orgID.
+func (s *Service) Get(ctx context.Context, orgID, id string) (*Group, error) {
+	return s.repo.Find(ctx, orgID, id)
+}
+
+func (s *Service) List(ctx context.Context, orgID string) ([]*Group, error) {
+	return s.repo.List(ctx, orgID)
+}
+
+// Delete removes a group and returns the nodes that were in it.
+func (s *Service) Delete(ctx context.Context, orgID, id string) ([]string, error) {
+	nodeIDs, err := s.Nodes(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return nodeIDs, nil
+}
+
+// Nodes returns the IDs of the nodes in a group of the organization orgID.
+func (s *Service) Nodes(ctx context.Context, orgID, id string) ([]string, error) {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.NodeIDs(ctx, id)
+}
+
+// AddNode adds a node to a group; both must belong to the organization orgID.
+func (s *Service) AddNode(ctx context.Context, orgID, id, nodeID string) error {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return err
+	}
+	if _, err := s.nodes.GetForOrganization(ctx, nodeID, orgID); err != nil {
+		return err
+	}
+	return s.repo.AddNode(ctx, id, nodeID)
+}
+
+// RemoveNode takes a node out of a group of the organization orgID.
+func (s *Service) RemoveNode(ctx context.Context, orgID, id, nodeID string) error {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return err
+	}
+	return s.repo.RemoveNode(ctx, id, nodeID)
+}
@@ -1,6 +1,9 @@
 package nodemetric
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 // NodeMetric representa una métrica completa almacenada en BD (con ID, timestamps, etc)
 type NodeMetric struct {
@@ -35,7 +38,7 @@ type CreateNodeMetricRequest struct {
 }
 
 type Repository interface {
-	StoreNodeMetric(metric *CreateNodeMetricRequest) (*NodeMetric, error)
+	StoreNodeMetric(ctx context.Context, metric *CreateNodeMetricRequest) (*NodeMetric, error)
 	// GetMetricsByNodeID(nodeID string, limit int) ([]*NodeMetric, error)
 	// GetLatestMetricByNodeID(nodeID string) (*NodeMetric, error)
 }
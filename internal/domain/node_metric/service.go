@@ -1,9 +1,12 @@
 package nodemetric
 
-import "errors"
+import (
+	"context"
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
 
 var (
-	ErrInvalidNodeMetricData = errors.New("invalid node metric data")
+	ErrInvalidNodeMetricData = apperr.New(apperr.InvalidArgument, "invalid node metric data")
 )
 
 type Service struct {
@@ -14,23 +17,23 @@ func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) StoreNodeMetric(req *CreateNodeMetricRequest) (*NodeMetric, error) {
+func (s *Service) StoreNodeMetric(ctx context.Context, req *CreateNodeMetricRequest) (*NodeMetric, error) {
 	if req == nil || req.NodeID == "" {
 		return nil, ErrInvalidNodeMetricData
 	}
-	return s.repo.StoreNodeMetric(req)
+	return s.repo.StoreNodeMetric(ctx, req)
 }
 
 // func (s *Service) GetMetricsByNodeID(nodeID string, limit int) ([]*NodeMetric, error) {
 // 	if nodeID == "" || limit <= 0 {
 // 		return nil, ErrInvalidNodeMetricData
 // 	}
-// 	return s.repo.GetMetricsByNodeID(nodeID, limit)
+// 	return s.repo.GetMetricsByNodeID(ctx, nodeID, limit)
 // }
 
 // func (s *Service) GetLatestMetricByNodeID(nodeID string) (*NodeMetric, error) {
 // 	if nodeID == "" {
 // 		return nil, ErrInvalidNodeMetricData
 // 	}
-// 	return s.repo.GetLatestMetricByNodeID(nodeID)
+// 	return s.repo.GetLatestMetricByNodeID(ctx, nodeID)
 // }
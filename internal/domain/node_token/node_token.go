@@ -1,18 +1,22 @@
 package nodetoken
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type NodeToken struct {
-	ID         string     `db:"id"`
-	NodeID     string     `db:"node_id"`
-	Token      string     `db:"token"`
-	CreatedAt  time.Time  `db:"created_at"`
-	LastSeenAt *time.Time `db:"last_seen_at"`
-	RevokedAt  *time.Time `db:"revoked_at"`
+	ID             string     `db:"id"`
+	NodeID         string     `db:"node_id"`
+	OrganizationID string     `db:"organization_id"` // organización del nodo (join con nodes)
+	Token          string     `db:"token"`
+	CreatedAt      time.Time  `db:"created_at"`
+	LastSeenAt     *time.Time `db:"last_seen_at"`
+	RevokedAt      *time.Time `db:"revoked_at"`
 }
 
 type Repository interface {
-	CreateNodeToken(nodeID, token string) error
-	FindNodeTokenByHash(tokenHash string) (*NodeToken, error)
-	UpdateLastSeen(tokenID string, lastSeenAt time.Time) error
+	CreateNodeToken(ctx context.Context, nodeID, token string) error
+	FindNodeTokenByHash(ctx context.Context, tokenHash string) (*NodeToken, error)
+	UpdateLastSeen(ctx context.Context, tokenID string, lastSeenAt time.Time) error
 }
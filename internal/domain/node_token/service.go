@@ -1,10 +1,13 @@
 package nodetoken
 
-import "errors"
+import (
+	"context"
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
 
 var (
-	ErrInvalidNodeTokenData = errors.New("invalid node token data")
-	ErrNodeTokenNotFound    = errors.New("node token not found")
+	ErrInvalidNodeTokenData = apperr.New(apperr.InvalidArgument, "invalid node token data")
+	ErrNodeTokenNotFound    = apperr.New(apperr.Unauthenticated, "invalid node token")
 )
 
 type Service struct {
@@ -15,16 +18,16 @@ func NewService(repo Repository) *Service {
 	return &Service{repo: repo}
 }
 
-func (s *Service) CreateNodeToken(nodeID, tokenHash string) error {
+func (s *Service) CreateNodeToken(ctx context.Context, nodeID, tokenHash string) error {
 	if nodeID == "" || tokenHash == "" {
 		return ErrInvalidNodeTokenData
 	}
-	return s.repo.CreateNodeToken(nodeID, tokenHash)
+	return s.repo.CreateNodeToken(ctx, nodeID, tokenHash)
 }
 
-func (s *Service) FindNodeTokenByHash(tokenHash string) (*NodeToken, error) {
+func (s *Service) FindNodeTokenByHash(ctx context.Context, tokenHash string) (*NodeToken, error) {
 	if tokenHash == "" {
 		return nil, ErrInvalidNodeTokenData
 	}
-	return s.repo.FindNodeTokenByHash(tokenHash)
+	return s.repo.FindNodeTokenByHash(ctx, tokenHash)
 }
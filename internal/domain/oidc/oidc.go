@@ -0,0 +1,35 @@
+package oidc
+
+import (
+	"context"
+	"time"
+)
+
+// LoginState guarda lo necesario para completar un login iniciado con Start
+type LoginState struct {
+	StateHash    string    `db:"state_hash"`
+	BrowserHash  string    `db:"browser_hash"` // hash del secreto de la cookie del navegador que lo inició
+	CodeVerifier string    `db:"code_verifier"`
+	Nonce        string    `db:"nonce"`
+	ExpiresAt    time.Time `db:"expires_at"`
+}
+
+// Identity vincula una cuenta del proveedor (issuer + subject) a un usuario
+type Identity struct {
+	ID        string    `db:"id"`
+	UserID    string    `db:"user_id"`
+	Issuer    string    `db:"issuer"`
+	Subject   string    `db:"subject"`
+	Email     string    `db:"email"`
+	CreatedAt time.Time `db:"created_at"`
+}
+
+type Repository interface {
+	CreateState(ctx context.Context, s *LoginState) error
+	// ConsumeState borra y devuelve el estado iniciado por el navegador
+	// browserHash; ErrInvalidState si no existe o lo inició otro navegador
+	ConsumeState(ctx context.Context, stateHash, browserHash string) (*LoginState, error)
+	// FindIdentity devuelve nil si la cuenta externa no está vinculada
+	FindIdentity(ctx context.Context, issuer, subject string) (*Identity, error)
+	LinkIdentity(ctx context.Context, i *Identity) error
+}
@@ -0,0 +1,196 @@
+package oidc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+var (
+	ErrInvalidState     = apperr.New(apperr.InvalidArgument, "invalid or expired oidc state")
+	ErrEmailNotVerified = apperr.New(apperr.PermissionDenied, "the identity provider did not verify the email")
+)
+
+// StateTTL es el tiempo que tiene el usuario para volver del proveedor
+const StateTTL = 10 * time.Minute
+
+// Service implementa el login OIDC (authorization code + PKCE) y vincula o
+// crea usuarios por email verificado.
+type Service struct {
+	repo     Repository
+	users    auth.Repository
+	apiKeys  *apikey.Service
+	mfa      *mfa.Service
+	provider *platform.OIDCProvider
+}
+
+// NewService crea el servicio; provider nil deja el login OIDC desactivado
+func NewService(repo Repository, users auth.Repository, apiKeys *apikey.Service, mfaService *mfa.Service, provider *platform.OIDCProvider) *Service {
+	return &Service{repo: repo, users: users, apiKeys: apiKeys, mfa: mfaService, provider: provider}
+}
+
+// Enabled indica si hay un proveedor configurado
+func (s *Service) Enabled() bool {
+	return s.provider != nil
+}
+
+// Start genera state, nonce y PKCE y devuelve la URL de autorización y el
+// secreto que el navegador debe presentar en Callback, para que nadie pueda
+// completar el login en un navegador distinto del que lo inició
+func (s *Service) Start(ctx context.Context) (authURL, browserSecret string, err error) {
+	if s.provider == nil {
+		return "", "", platform.ErrOIDCDisabled
+	}
+
+	state, err := platform.RandomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	browserSecret, err = platform.RandomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := platform.RandomURLToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, challenge, err := platform.GeneratePKCE()
+	if err != nil {
+		return "", "", err
+	}
+
+	authURL, err = s.provider.AuthCodeURL(ctx, state, nonce, challenge)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.repo.CreateState(ctx, &LoginState{
+		StateHash:    hashState(state),
+		BrowserHash:  hashState(browserSecret),
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		ExpiresAt:    time.Now().Add(StateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, browserSecret, nil
+}
+
+// Callback completa el login: canjea el código y devuelve el usuario
+// vinculado a la identidad, vinculando por email verificado o creándolo si no
+// existe. browserSecret es el que devolvió Start al mismo navegador.
+// provisioned indica que el usuario se acaba de crear.
+func (s *Service) Callback(ctx context.Context, code, state, browserSecret string) (user *auth.User, provisioned bool, err error) {
+	if s.provider == nil {
+		return nil, false, platform.ErrOIDCDisabled
+	}
+	if code == "" || state == "" || browserSecret == "" {
+		return nil, false, ErrInvalidState
+	}
+
+	st, err := s.repo.ConsumeState(ctx, hashState(state), hashState(browserSecret))
+	if err != nil {
+		return nil, false, err
+	}
+	if time.Now().After(st.ExpiresAt) {
+		return nil, false, ErrInvalidState
+	}
+
+	id, err := s.provider.Exchange(ctx, code, st.CodeVerifier, st.Nonce)
+	if err != nil {
+		return nil, false, err
+	}
+	if id.Subject == "" || id.Email == "" || !id.EmailVerified {
+		return nil, false, ErrEmailNotVerified
+	}
+
+	// 1. Cuenta externa ya vinculada
+	linked, err := s.repo.FindIdentity(ctx, id.Issuer, id.Subject)
+	if err != nil {
+		return nil, false, err
+	}
+	if linked != nil {
+		user, err := s.users.FindUserByID(ctx, linked.UserID)
+		if err != nil {
+			return nil, false, err
+		}
+		if user == nil {
+			return nil, false, auth.ErrInvalidCredentials
+		}
+		return user, false, nil
+	}
+
+	// 2. Usuario existente con el mismo email (verificado por el proveedor)
+	email := strings.ToLower(strings.TrimSpace(id.Email))
+	user, err = s.users.FindUserByEmail(ctx, email)
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Nadie demostró ser dueño del email de una cuenta sin verificar: pudo
+	// registrarla otro antes que el titular. Se le quitan las credenciales
+	// que no vienen del proveedor antes de vincularla.
+	if user != nil && user.EmailVerifiedAt == nil {
+		if err := s.resetCredentials(ctx, user.ID); err != nil {
+			return nil, false, err
+		}
+		user.PasswordHash = ""
+	}
+
+	// 3. Alta de usuario sin contraseña
+	if user == nil {
+		userID, err := s.users.CreateUser(ctx, email, id.Name, "")
+		if err != nil {
+			return nil, false, err
+		}
+		if user, err = s.users.FindUserByID(ctx, userID); err != nil {
+			return nil, false, err
+		}
+		provisioned = true
+	}
+
+	err = s.repo.LinkIdentity(ctx, &Identity{
+		UserID:  user.ID,
+		Issuer:  id.Issuer,
+		Subject: id.Subject,
+		Email:   email,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	// El proveedor garantiza que el email es del usuario
+	if user.EmailVerifiedAt == nil {
+		if err := s.users.MarkEmailVerified(ctx, user.ID); err != nil {
+			return nil, false, err
+		}
+	}
+	return user, provisioned, nil
+}
+
+// resetCredentials deja la cuenta sin contraseña, sesiones, API keys ni MFA
+func (s *Service) resetCredentials(ctx context.Context, userID string) error {
+	if err := s.users.UpdatePassword(ctx, userID, ""); err != nil {
+		return err
+	}
+	if err := s.users.RevokeAllRefreshTokens(ctx, userID, auth.RevokedAccountClaimed); err != nil {
+		return err
+	}
+	if err := s.apiKeys.RevokeAll(ctx, userID); err != nil {
+		return err
+	}
+	return s.mfa.Reset(ctx, userID)
+}
+
+func hashState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,198 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/platform/oidctest"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+type testEnv struct {
+	svc     *oidc.Service
+	users   *memory.AuthRepository
+	apiKeys *apikey.Service
+	mfa     *mfa.Service
+	stub    *oidctest.Provider
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	db := memory.NewDB()
+	stub := oidctest.NewProvider(t)
+	provider := platform.NewOIDCProvider(platform.OIDCConfig{
+		IssuerURL:   stub.URL,
+		ClientID:    stub.ClientID,
+		RedirectURL: "http://localhost:3000/auth/callback",
+		Scopes:      []string{"openid", "email"},
+	}, stub.Client())
+
+	env := &testEnv{
+		users:   memory.NewAuthRepository(db),
+		apiKeys: apikey.NewService(memory.NewAPIKeyRepository(db)),
+		mfa:     mfa.NewService(memory.NewMFARepository(db), make([]byte, 32), "test"),
+		stub:    stub,
+	}
+	env.svc = oidc.NewService(memory.NewOIDCRepository(db), env.users, env.apiKeys, env.mfa, provider)
+	return env
+}
+
+// authorize starts a login and approves it at the stub provider. It returns
+// the code and state the provider sends back and the browser secret.
+func (env *testEnv) authorize(t *testing.T, ctx context.Context) (code, state, browserSecret string) {
+	t.Helper()
+	authURL, browserSecret, err := env.svc.Start(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code = env.stub.Authorize(authURL)
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code, u.Query().Get("state"), browserSecret
+}
+
+// login runs the whole flow against the stub provider.
+func (env *testEnv) login(t *testing.T, ctx context.Context) *auth.User {
+	t.Helper()
+	code, state, browserSecret := env.authorize(t, ctx)
+	user, _, err := env.svc.Callback(ctx, code, state, browserSecret)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	return user
+}
+
+// TestCallbackRequiresSameBrowser covers login CSRF: the code and state of a
+// login started in one browser must not complete it in another.
+func TestCallbackRequiresSameBrowser(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	code, state, browserSecret := env.authorize(t, ctx)
+
+	for _, other := range []string{"", "someone-elses-secret"} {
+		if _, _, err := env.svc.Callback(ctx, code, state, other); !errors.Is(err, oidc.ErrInvalidState) {
+			t.Errorf("Callback with browser secret %q: error = %v, want ErrInvalidState", other, err)
+		}
+	}
+	if _, _, err := env.svc.Callback(ctx, code, state, browserSecret); err != nil {
+		t.Fatalf("Callback from the starting browser: %v", err)
+	}
+	if _, _, err := env.svc.Callback(ctx, code, state, browserSecret); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("reused state: error = %v, want ErrInvalidState", err)
+	}
+}
+
+// TestCallbackClaimsUnverifiedAccount covers account pre-hijacking: someone
+// registers the victim's email with a password and waits for the victim to
+// sign in through SSO.
+func TestCallbackClaimsUnverifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	hash, err := platform.HashPassword("attacker-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	squatterID, err := env.users.CreateUser(ctx, env.stub.Email, "Squatter", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.users.StoreRefreshToken(ctx, &auth.RefreshToken{
+		UserID: squatterID, FamilyID: "family-1", TokenHash: "refresh-hash", ExpiresAt: time.Now().Add(time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := env.apiKeys.Create(ctx, squatterID, "backdoor", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+	secret, _, err := env.mfa.BeginEnrollment(ctx, squatterID, env.stub.Email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, err := platform.TOTPCode(secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.mfa.ConfirmEnrollment(ctx, squatterID, code); err != nil {
+		t.Fatal(err)
+	}
+
+	user := env.login(t, ctx)
+	if user.ID != squatterID {
+		t.Fatalf("linked user = %s, want the existing account %s", user.ID, squatterID)
+	}
+
+	stored, err := env.users.FindUserByID(ctx, squatterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PasswordHash != "" || user.PasswordHash != "" {
+		t.Error("the squatter's password still works")
+	}
+	if stored.EmailVerifiedAt == nil {
+		t.Error("email not marked verified")
+	}
+	if sessions, err := env.users.FindActiveSessions(ctx, squatterID); err != nil || len(sessions) != 0 {
+		t.Errorf("active sessions = %d, %v; want none", len(sessions), err)
+	}
+	keys, err := env.apiKeys.List(ctx, squatterID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, k := range keys {
+		if k.RevokedAt == nil {
+			t.Errorf("api key %q still active", k.Name)
+		}
+	}
+	if enabled, err := env.mfa.IsEnabled(ctx, squatterID); err != nil || enabled {
+		t.Errorf("mfa enabled = %v, %v; want disabled", enabled, err)
+	}
+}
+
+func TestCallbackKeepsVerifiedAccount(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	hash, err := platform.HashPassword("owner-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ownerID, err := env.users.CreateUser(ctx, env.stub.Email, "Owner", hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.users.MarkEmailVerified(ctx, ownerID); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := env.apiKeys.Create(ctx, ownerID, "ci", nil, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if user := env.login(t, ctx); user.ID != ownerID {
+		t.Fatalf("linked user = %s, want %s", user.ID, ownerID)
+	}
+	stored, err := env.users.FindUserByID(ctx, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.PasswordHash != hash {
+		t.Error("password of a verified account was cleared")
+	}
+	keys, err := env.apiKeys.List(ctx, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].RevokedAt != nil {
+		t.Errorf("api keys of a verified account = %+v", keys)
+	}
+}
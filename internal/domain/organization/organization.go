@@ -0,0 +1,126 @@
+package organization
+
+import (
+	"context"
+	"time"
+)
+
+// Role is the level of access a member has inside an organization.
+type Role string
+
+const (
+	RoleOwner    Role = "owner"
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	RoleViewer   Role = "viewer"
+)
+
+// Permission is a single action that can be granted to a role.
+type Permission string
+
+const (
+	PermNodesRead     Permission = "nodes:read"
+	PermNodesWrite    Permission = "nodes:write"
+	PermJobsRead      Permission = "jobs:read"
+	PermJobsWrite     Permission = "jobs:write"
+	PermMembersRead   Permission = "members:read"
+	PermMembersManage Permission = "members:manage"
+	PermOrgManage     Permission = "org:manage"
+	PermAuditRead     Permission = "audit:read"
+)
+
+// rolePermissions maps each role to the permissions it grants. Roles are
+// cumulative: every role includes everything granted to the roles below it.
+var rolePermissions = map[Role][]Permission{
+	RoleViewer:   {PermNodesRead, PermJobsRead, PermMembersRead},
+	RoleOperator: {PermNodesRead, PermJobsRead, PermMembersRead, PermNodesWrite, PermJobsWrite},
+	RoleAdmin:    {PermNodesRead, PermJobsRead, PermMembersRead, PermNodesWrite, PermJobsWrite, PermMembersManage, PermAuditRead},
+	RoleOwner:    {PermNodesRead, PermJobsRead, PermMembersRead, PermNodesWrite, PermJobsWrite, PermMembersManage, PermAuditRead, PermOrgManage},
+}
+
+// roleRank orders roles so that a member can only manage members below them.
+var roleRank = map[Role]int{
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+	RoleOwner:    4,
+}
+
+// Valid reports whether r is one of the known roles.
+func (r Role) Valid() bool {
+	_, ok := roleRank[r]
+	return ok
+}
+
+// Can reports whether the role grants the given permission.
+func (r Role) Can(p Permission) bool {
+	for _, granted := range rolePermissions[r] {
+		if granted == p {
+			return true
+		}
+	}
+	return false
+}
+
+// Valid reports whether p is one of the known permissions.
+func (p Permission) Valid() bool {
+	return RoleOwner.Can(p)
+}
+
+// Outranks reports whether r is strictly above other.
+func (r Role) Outranks(other Role) bool {
+	return roleRank[r] > roleRank[other]
+}
+
+// Organization groups users and the nodes they manage together.
+// Personal organizations are created automatically for every user.
+type Organization struct {
+	ID             string    `db:"id" json:"id"`
+	Name           string    `db:"name" json:"name"`
+	PersonalUserID *string   `db:"personal_user_id" json:"personal_user_id,omitempty"`
+	RequireMFA     bool      `db:"require_mfa" json:"require_mfa"` // members must have two-factor enabled
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// UserOrganization is an organization as seen by one of its members.
+type UserOrganization struct {
+	Organization
+	Role Role `db:"role" json:"role"`
+}
+
+// Membership links a user to an organization with a role.
+type Membership struct {
+	OrganizationID string    `db:"organization_id" json:"organization_id"`
+	UserID         string    `db:"user_id" json:"user_id"`
+	Role           Role      `db:"role" json:"role"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	RequireMFA     bool      `db:"require_mfa" json:"-"` // from the organization
+}
+
+// Member is a membership enriched with the user's public profile.
+type Member struct {
+	UserID    string    `db:"user_id" json:"user_id"`
+	Email     string    `db:"email" json:"email"`
+	Name      *string   `db:"name" json:"name"`
+	Role      Role      `db:"role" json:"role"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Repository defines the persistence contract for organizations and members.
+// UpdateMemberRole and RemoveMember return ErrLastOwner instead of leaving an
+// organization without owners, checked atomically with the change.
+type Repository interface {
+	Create(ctx context.Context, name, ownerID string) (*Organization, error)
+	EnsurePersonal(ctx context.Context, userID, name string) (*Organization, error)
+	FindByID(ctx context.Context, id string) (*Organization, error)
+	FindPersonal(ctx context.Context, userID string) (*Organization, error)
+	FindByUserID(ctx context.Context, userID string) ([]*UserOrganization, error)
+	FindMembership(ctx context.Context, orgID, userID string) (*Membership, error)
+	FindMembers(ctx context.Context, orgID string) ([]*Member, error)
+	AddMember(ctx context.Context, orgID, userID string, role Role) error
+	UpdateMemberRole(ctx context.Context, orgID, userID string, role Role) error
+	RemoveMember(ctx context.Context, orgID, userID string) error
+	CountOwners(ctx context.Context, orgID string) (int, error)
+	SetRequireMFA(ctx context.Context, id string, require bool) (*Organization, error)
+}
@@ -0,0 +1,166 @@
+package organization
+
+import (
+	"context"
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+var (
+	ErrOrganizationNotFound    = apperr.New(apperr.NotFound, "organization not found")
+	ErrInvalidOrganizationData = apperr.New(apperr.InvalidArgument, "invalid organization data")
+	ErrNotMember               = apperr.New(apperr.NotFound, "member not found")
+	ErrMemberAlreadyExists     = apperr.New(apperr.AlreadyExists, "user is already a member")
+	ErrForbidden               = apperr.New(apperr.PermissionDenied, "insufficient permissions")
+	ErrInvalidRole             = apperr.New(apperr.InvalidArgument, "invalid role")
+	ErrLastOwner               = apperr.New(apperr.FailedPrecondition, "organization must keep at least one owner")
+)
+
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Create creates a team organization and makes userID its owner.
+func (s *Service) Create(ctx context.Context, name, userID string) (*Organization, error) {
+	if name == "" || userID == "" {
+		return nil, ErrInvalidOrganizationData
+	}
+	return s.repo.Create(ctx, name, userID)
+}
+
+// EnsurePersonal returns the user's personal organization, creating it if needed.
+func (s *Service) EnsurePersonal(ctx context.Context, userID, name string) (*Organization, error) {
+	if userID == "" {
+		return nil, ErrInvalidOrganizationData
+	}
+	return s.repo.EnsurePersonal(ctx, userID, name)
+}
+
+// ListForUser returns every organization the user belongs to with their role.
+func (s *Service) ListForUser(ctx context.Context, userID string) ([]*UserOrganization, error) {
+	return s.repo.FindByUserID(ctx, userID)
+}
+
+// Authorize checks that userID is a member of orgID and that their role grants
+// perm. When orgID is empty the user's personal organization is used.
+func (s *Service) Authorize(ctx context.Context, orgID, userID string, perm Permission) (*Membership, error) {
+	if userID == "" {
+		return nil, ErrNotMember
+	}
+	if orgID == "" {
+		personal, err := s.repo.FindPersonal(ctx, userID)
+		if err != nil {
+			return nil, err
+		}
+		orgID = personal.ID
+	}
+
+	m, err := s.repo.FindMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !m.Role.Can(perm) {
+		return nil, ErrForbidden
+	}
+	return m, nil
+}
+
+// ListMembers returns all members of an organization.
+func (s *Service) ListMembers(ctx context.Context, orgID string) ([]*Member, error) {
+	return s.repo.FindMembers(ctx, orgID)
+}
+
+// AddMember adds userID to the actor's organization with the given role.
+func (s *Service) AddMember(ctx context.Context, actor *Membership, userID string, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if !actor.Role.Can(PermMembersManage) {
+		return ErrForbidden
+	}
+	// Nadie puede otorgar un rol superior al propio
+	if role.Outranks(actor.Role) {
+		return ErrForbidden
+	}
+	if existing, err := s.repo.FindMembership(ctx, actor.OrganizationID, userID); err == nil && existing != nil {
+		return ErrMemberAlreadyExists
+	}
+	return s.repo.AddMember(ctx, actor.OrganizationID, userID, role)
+}
+
+// ChangeRole updates the role of another member of the actor's organization.
+// Demoting the last owner fails with ErrLastOwner.
+func (s *Service) ChangeRole(ctx context.Context, actor *Membership, userID string, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if _, err := s.manageableMember(ctx, actor, userID); err != nil {
+		return err
+	}
+	if role.Outranks(actor.Role) {
+		return ErrForbidden
+	}
+	return s.repo.UpdateMemberRole(ctx, actor.OrganizationID, userID, role)
+}
+
+// RemoveMember removes a member from the actor's organization. Any member may
+// remove themselves; removing others requires members:manage. The last owner
+// cannot be removed.
+func (s *Service) RemoveMember(ctx context.Context, actor *Membership, userID string) error {
+	if actor.UserID != userID {
+		if _, err := s.manageableMember(ctx, actor, userID); err != nil {
+			return err
+		}
+	}
+	return s.repo.RemoveMember(ctx, actor.OrganizationID, userID)
+}
+
+// manageableMember loads the target membership and checks that actor may
+// manage it: owners manage everyone, others only members strictly below them.
+func (s *Service) manageableMember(ctx context.Context, actor *Membership, userID string) (*Membership, error) {
+	if !actor.Role.Can(PermMembersManage) {
+		return nil, ErrForbidden
+	}
+	target, err := s.repo.FindMembership(ctx, actor.OrganizationID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if actor.Role != RoleOwner && !actor.Role.Outranks(target.Role) {
+		return nil, ErrForbidden
+	}
+	return target, nil
+}
+
+// SetRequireMFA turns the two-factor requirement of the actor's organization
+// on or off. Callers must have checked org:manage.
+func (s *Service) SetRequireMFA(ctx context.Context, actor *Membership, require bool) (*Organization, error) {
+	if !actor.Role.Can(PermOrgManage) {
+		return nil, ErrForbidden
+	}
+	return s.repo.SetRequireMFA(ctx, actor.OrganizationID, require)
+}
+
+// Get returns an organization by ID.
+func (s *Service) Get(ctx context.Context, id string) (*Organization, error) {
+	return s.repo.FindByID(ctx, id)
+}
+
+// Personal returns the personal organization of a user.
+func (s *Service) Personal(ctx context.Context, userID string) (*Organization, error) {
+	return s.repo.FindPersonal(ctx, userID)
+}
+
+// Join adds userID to orgID without an acting member. It is used when a user
+// accepts an invitation that an authorized member already issued.
+func (s *Service) Join(ctx context.Context, orgID, userID string, role Role) error {
+	if !role.Valid() {
+		return ErrInvalidRole
+	}
+	if existing, err := s.repo.FindMembership(ctx, orgID, userID); err == nil && existing != nil {
+		return ErrMemberAlreadyExists
+	}
+	return s.repo.AddMember(ctx, orgID, userID, role)
+}
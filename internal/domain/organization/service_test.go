@@ -0,0 +1,79 @@
+package organization_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+// racingRepo holds each membership change until both owners have sent theirs,
+// so the two requests always overlap.
+type racingRepo struct {
+	*memory.OrganizationRepository
+	arrived sync.WaitGroup
+}
+
+func (r *racingRepo) wait() {
+	r.arrived.Done()
+	r.arrived.Wait()
+}
+
+func (r *racingRepo) UpdateMemberRole(ctx context.Context, orgID, userID string, role organization.Role) error {
+	r.wait()
+	return r.OrganizationRepository.UpdateMemberRole(ctx, orgID, userID, role)
+}
+
+func (r *racingRepo) RemoveMember(ctx context.Context, orgID, userID string) error {
+	r.wait()
+	return r.OrganizationRepository.RemoveMember(ctx, orgID, userID)
+}
+
+// TestOwnersCannotRemoveEachOther checks that two owners demoting or removing
+// each other at the same time leave exactly one owner.
+func TestOwnersCannotRemoveEachOther(t *testing.T) {
+	ctx := context.Background()
+	changes := map[string]func(*organization.Service, *organization.Membership, string) error{
+		"demote": func(s *organization.Service, actor *organization.Membership, userID string) error {
+			return s.ChangeRole(ctx, actor, userID, organization.RoleAdmin)
+		},
+		"remove": func(s *organization.Service, actor *organization.Membership, userID string) error {
+			return s.RemoveMember(ctx, actor, userID)
+		},
+	}
+	for name, change := range changes {
+		t.Run(name, func(t *testing.T) {
+			db := memory.NewDB()
+			firstID, orgID := memory.SeedOrg(t, db)
+			secondID, err := memory.NewAuthRepository(db).CreateUser(ctx, "second@example.com", "Second", "x")
+			if err != nil {
+				t.Fatal(err)
+			}
+			repo := &racingRepo{OrganizationRepository: memory.NewOrganizationRepository(db)}
+			if err := repo.AddMember(ctx, orgID, secondID, organization.RoleOwner); err != nil {
+				t.Fatal(err)
+			}
+			repo.arrived.Add(2)
+			svc := organization.NewService(repo)
+
+			errs := make(chan error, 2)
+			for _, ids := range [][2]string{{firstID, secondID}, {secondID, firstID}} {
+				actor, err := repo.FindMembership(ctx, orgID, ids[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				go func() { errs <- change(svc, actor, ids[1]) }()
+			}
+			first, second := <-errs, <-errs
+			if (first == nil) == (second == nil) || !errors.Is(errors.Join(first, second), organization.ErrLastOwner) {
+				t.Fatalf("errors = %v, %v; want nil and ErrLastOwner", first, second)
+			}
+			if owners, err := repo.CountOwners(ctx, orgID); err != nil || owners != 1 {
+				t.Fatalf("owners = %d, %v; want 1", owners, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,45 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Limit describe un token bucket: Burst peticiones seguidas y luego una cada
+// Every.
+type Limit struct {
+	Every time.Duration
+	Burst int
+}
+
+// PerMinute devuelve un límite de n peticiones por minuto con ráfaga n
+func PerMinute(n int) Limit {
+	return Limit{Every: time.Minute / time.Duration(n), Burst: n}
+}
+
+// Result es el resultado de consumir un token
+type Result struct {
+	Allowed    bool
+	RetryAfter time.Duration // solo si !Allowed
+}
+
+// Lockout es el estado de bloqueo de una cuenta
+type Lockout struct {
+	FailedAttempts int
+	LockedUntil    *time.Time
+}
+
+// Repository persiste buckets y bloqueos. Las operaciones deben ser atómicas
+// para que los límites se respeten entre réplicas.
+type Repository interface {
+	// Take consume un token del bucket key
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+	// PurgeBuckets borra los buckets sin uso desde antes de before
+	PurgeBuckets(ctx context.Context, before time.Time) error
+
+	FindLockout(ctx context.Context, key string) (*Lockout, error)
+	// RecordFailure suma un fallo y fija locked_until con el backoff
+	// calculado a partir del número de fallos resultante
+	RecordFailure(ctx context.Context, key string, backoff func(failures int) time.Duration) (*Lockout, error)
+	ResetLockout(ctx context.Context, key string) error
+}
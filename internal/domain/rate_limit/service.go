@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const (
+	// LockoutThreshold es el número de logins fallidos seguidos a partir del
+	// cual la cuenta se bloquea
+	LockoutThreshold = 5
+	// LockoutBase es la duración del primer bloqueo; cada fallo posterior la duplica
+	LockoutBase = 30 * time.Second
+	// LockoutMax acota la duración de un bloqueo
+	LockoutMax = time.Hour
+)
+
+// Service aplica límites de peticiones y bloqueo de cuentas
+type Service struct {
+	repo Repository
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{repo: repo}
+}
+
+// Allow consume un token del bucket key
+func (s *Service) Allow(ctx context.Context, key string, limit Limit) (Result, error) {
+	return s.repo.Take(ctx, key, limit)
+}
+
+// Purge borra los buckets sin uso en el último día
+func (s *Service) Purge(ctx context.Context) error {
+	return s.repo.PurgeBuckets(ctx, time.Now().Add(-24*time.Hour))
+}
+
+// LockedFor devuelve cuánto falta para que la cuenta de email se desbloquee,
+// o 0 si no está bloqueada
+func (s *Service) LockedFor(ctx context.Context, email string) (time.Duration, error) {
+	l, err := s.repo.FindLockout(ctx, lockoutKey(email))
+	if err != nil || l == nil || l.LockedUntil == nil {
+		return 0, err
+	}
+	if d := time.Until(*l.LockedUntil); d > 0 {
+		return d, nil
+	}
+	return 0, nil
+}
+
+// LoginFailed registra un login fallido de email y devuelve la duración del
+// bloqueo resultante (0 si aún no se alcanzó el umbral)
+func (s *Service) LoginFailed(ctx context.Context, email string) (time.Duration, error) {
+	l, err := s.repo.RecordFailure(ctx, lockoutKey(email), Backoff)
+	if err != nil || l.LockedUntil == nil {
+		return 0, err
+	}
+	return time.Until(*l.LockedUntil), nil
+}
+
+// LoginSucceeded reinicia el contador de fallos de email
+func (s *Service) LoginSucceeded(ctx context.Context, email string) error {
+	return s.repo.ResetLockout(ctx, lockoutKey(email))
+}
+
+// Backoff devuelve la duración del bloqueo tras failures fallos seguidos:
+// LockoutBase al llegar a LockoutThreshold y el doble por cada fallo
+// adicional, hasta LockoutMax
+func Backoff(failures int) time.Duration {
+	if failures < LockoutThreshold {
+		return 0
+	}
+	d := LockoutBase
+	for i := LockoutThreshold; i < failures && d < LockoutMax; i++ {
+		d *= 2
+	}
+	return min(d, LockoutMax)
+}
+
+func lockoutKey(email string) string {
+	return "login:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,95 @@
+package script
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+var (
+	ErrScriptNotFound     = apperr.New(apperr.NotFound, "script not found")
+	ErrScriptExists       = apperr.New(apperr.AlreadyExists, "script already exists")
+	ErrVersionNotFound    = apperr.New(apperr.NotFound, "script version not found")
+	ErrInvalidScript      = apperr.New(apperr.InvalidArgument, "invalid script")
+	ErrScriptTooLarge     = apperr.New(apperr.InvalidArgument, "script too large")
+	ErrAssignmentNotFound = apperr.New(apperr.NotFound, "script assignment not found")
+	ErrAssignmentExists   = apperr.New(apperr.AlreadyExists, "script already assigned")
+	ErrInvalidAssignment  = apperr.New(apperr.InvalidArgument, "invalid script assignment")
+)
+
+// Script is a shell script of the script library. Its body is versioned:
+// every change adds a Version, and nodes install the latest one. Version and
+// SHA256 describe that latest version.
+type Script struct {
+	ID             string    `db:"id" json:"id"`
+	OrganizationID string    `db:"organization_id" json:"organization_id"`
+	Name           string    `db:"name" json:"name"`
+	Description    string    `db:"description" json:"description"`
+	Version        int       `db:"version" json:"version"`
+	SHA256         string    `db:"sha256" json:"sha256"`
+	CreatedBy      *string   `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt      time.Time `db:"created_at" json:"created_at"`
+	UpdatedAt      time.Time `db:"updated_at" json:"updated_at"`
+}
+
+// Version is one revision of a script body.
+type Version struct {
+	ScriptID  string    `db:"script_id" json:"script_id"`
+	Version   int       `db:"version" json:"version"`
+	Body      string    `db:"body" json:"-"`
+	Size      int64     `db:"size" json:"size"`
+	SHA256    string    `db:"sha256" json:"sha256"`
+	CreatedBy *string   `db:"created_by" json:"created_by,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Assignment installs a script on a node, or on every node of a group.
+// Exactly one of NodeID and GroupID is set.
+type Assignment struct {
+	ID        string    `db:"id" json:"id"`
+	ScriptID  string    `db:"script_id" json:"script_id"`
+	NodeID    *string   `db:"node_id" json:"node_id,omitempty"`
+	GroupID   *string   `db:"group_id" json:"group_id,omitempty"`
+	CreatedAt time.Time `db:"created_at" json:"created_at"`
+}
+
+// Entry is a script as listed in the manifest sent to a node: the agent
+// downloads the entries whose checksum differs from what it has installed
+// and removes the scripts no longer listed.
+type Entry struct {
+	Name    string `json:"name"`
+	Version int    `json:"version"`
+	SHA256  string `json:"sha256"`
+}
+
+// Repository defines the persistence contract for the script library.
+type Repository interface {
+	// Create inserts s with v as its first version; it returns
+	// ErrScriptExists when the organization already has a script with that
+	// name.
+	Create(ctx context.Context, s *Script, v *Version) (*Script, error)
+	// AddVersion stores v as the next version of its script and makes it the
+	// latest.
+	AddVersion(ctx context.Context, v *Version) (*Version, error)
+	Find(ctx context.Context, orgID, id string) (*Script, error)
+	// List returns the scripts of an organization ordered by name.
+	List(ctx context.Context, orgID string) ([]*Script, error)
+	Delete(ctx context.Context, orgID, id string) error
+	// Versions returns the versions of a script, newest first, without
+	// their bodies.
+	Versions(ctx context.Context, scriptID string) ([]*Version, error)
+	FindVersion(ctx context.Context, scriptID string, version int) (*Version, error)
+
+	// Assign inserts a; it returns ErrAssignmentExists when the script is
+	// already assigned to that node or group.
+	Assign(ctx context.Context, a *Assignment) (*Assignment, error)
+	Assignments(ctx context.Context, scriptID string) ([]*Assignment, error)
+	Unassign(ctx context.Context, scriptID, id string) error
+	// NodeIDs returns the nodes a script is assigned to, directly or through
+	// a group, ordered by ID.
+	NodeIDs(ctx context.Context, scriptID string) ([]string, error)
+	// ForNode returns the scripts assigned to a node, directly or through
+	// its groups, ordered by name.
+	ForNode(ctx context.Context, nodeID string) ([]*Script, error)
+}
@@ -0,0 +1,203 @@
+package script
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+)
+
+// MaxBodySize is the largest script body accepted, in bytes. Bodies arrive
+// JSON-encoded, so this leaves room for escaping within the API's 1 MiB
+// request limit.
+const MaxBodySize = 256 << 10
+
+// validName accepts names the agent can use as a file name as is.
+var validName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+type Service struct {
+	repo   Repository
+	nodes  *node.Service
+	groups *nodegroup.Service
+}
+
+func NewService(repo Repository, nodes *node.Service, groups *nodegroup.Service) *Service {
+	return &Service{repo: repo, nodes: nodes, groups: groups}
+}
+
+// Create adds a script to the library of the organization orgID with body
+// as its first version. createdBy is the user creating it, if any.
+func (s *Service) Create(ctx context.Context, orgID string, createdBy *string, name, description, body string) (*Script, error) {
+	if orgID == "" || !validName.MatchString(name) {
+		return nil, ErrInvalidScript
+	}
+	v, err := newVersion(body, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Create(ctx, &Script{
+		OrganizationID: orgID,
+		Name:           name,
+		Description:    strings.TrimSpace(description),
+		CreatedBy:      createdBy,
+	}, v)
+}
+
+// AddVersion stores body as the new latest version of a script.
+func (s *Service) AddVersion(ctx context.Context, orgID, id string, createdBy *string, body string) (*Version, error) {
+	sc, err := s.repo.Find(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	v, err := newVersion(body, createdBy)
+	if err != nil {
+		return nil, err
+	}
+	v.ScriptID = sc.ID
+	return s.repo.AddVersion(ctx, v)
+}
+
+// Get returns a script of the organization orgID.
+func (s *Service) Get(ctx context.Context, orgID, id string) (*Script, error) {
+	return s.repo.Find(ctx, orgID, id)
+}
+
+func (s *Service) List(ctx context.Context, orgID string) ([]*Script, error) {
+	return s.repo.List(ctx, orgID)
+}
+
+// Versions returns the versions of a script, newest first, without bodies.
+func (s *Service) Versions(ctx context.Context, orgID, id string) ([]*Version, error) {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Versions(ctx, id)
+}
+
+// GetVersion returns one version of a script, with its body.
+func (s *Service) GetVersion(ctx context.Context, orgID, id string, version int) (*Version, error) {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.FindVersion(ctx, id, version)
+}
+
+// Delete removes a script with its versions and assignments and returns the
+// nodes it was assigned to.
+func (s *Service) Delete(ctx context.Context, orgID, id string) ([]string, error) {
+	nodeIDs, err := s.Nodes(ctx, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.Delete(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return nodeIDs, nil
+}
+
+// Target is what a script is assigned to: a node or a node group.
+type Target struct {
+	NodeID  string
+	GroupID string
+}
+
+// Assign assigns a script to a node or a group. The script and the target
+// must belong to the organization orgID.
+func (s *Service) Assign(ctx context.Context, orgID, id string, t Target) (*Assignment, error) {
+	if (t.NodeID == "") == (t.GroupID == "") {
+		return nil, ErrInvalidAssignment
+	}
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	a := &Assignment{ScriptID: id}
+	if t.NodeID != "" {
+		if _, err := s.nodes.GetForOrganization(ctx, t.NodeID, orgID); err != nil {
+			return nil, err
+		}
+		a.NodeID = &t.NodeID
+	} else {
+		if _, err := s.groups.Get(ctx, orgID, t.GroupID); err != nil {
+			return nil, err
+		}
+		a.GroupID = &t.GroupID
+	}
+	return s.repo.Assign(ctx, a)
+}
+
+func (s *Service) Assignments(ctx context.Context, orgID, id string) ([]*Assignment, error) {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.Assignments(ctx, id)
+}
+
+// Unassign removes an assignment of a script.
+func (s *Service) Unassign(ctx context.Context, orgID, id, assignmentID string) error {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return err
+	}
+	return s.repo.Unassign(ctx, id, assignmentID)
+}
+
+// Nodes returns the nodes a script of the organization orgID is assigned to,
+// directly or through a group.
+func (s *Service) Nodes(ctx context.Context, orgID, id string) ([]string, error) {
+	if _, err := s.repo.Find(ctx, orgID, id); err != nil {
+		return nil, err
+	}
+	return s.repo.NodeIDs(ctx, id)
+}
+
+// Manifest returns the scripts a node must have installed.
+func (s *Service) Manifest(ctx context.Context, nodeID string) ([]Entry, error) {
+	scripts, err := s.repo.ForNode(ctx, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]Entry, 0, len(scripts))
+	for _, sc := range scripts {
+		entries = append(entries, Entry{Name: sc.Name, Version: sc.Version, SHA256: sc.SHA256})
+	}
+	return entries, nil
+}
+
+// NodeScript returns the latest version of the script name, which must be
+// assigned to the node. Scripts not assigned to it are reported as missing.
+func (s *Service) NodeScript(ctx context.Context, nodeID, name string) (*Script, *Version, error) {
+	scripts, err := s.repo.ForNode(ctx, nodeID)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, sc := range scripts {
+		if sc.Name == name {
+			v, err := s.repo.FindVersion(ctx, sc.ID, sc.Version)
+			if err != nil {
+				return nil, nil, err
+			}
+			return sc, v, nil
+		}
+	}
+	return nil, nil, ErrScriptNotFound
+}
+
+// newVersion validates body and computes its checksum. PostgreSQL TEXT does
+// not store NUL bytes nor invalid UTF-8, so those are rejected here.
+func newVersion(body string, createdBy *string) (*Version, error) {
+	if len(body) > MaxBodySize {
+		return nil, ErrScriptTooLarge
+	}
+	if body == "" || !utf8.ValidString(body) || strings.ContainsRune(body, 0) {
+		return nil, ErrInvalidScript
+	}
+	return &Version{
+		Body:      body,
+		Size:      int64(len(body)),
+		SHA256:    blob.Key([]byte(body)),
+		CreatedBy: createdBy,
+	}, nil
+}
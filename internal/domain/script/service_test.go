@@ -0,0 +1,180 @@
+package script_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+type testEnv struct {
+	scripts *script.Service
+	groups  *nodegroup.Service
+	userID  string
+	orgID   string
+	otherID string // another organization
+	nodes   map[string]string
+}
+
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewDB()
+
+	userID, orgID := memory.SeedOrg(t, db)
+	other, err := memory.NewOrganizationRepository(db).Create(ctx, "Other", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{userID: userID, orgID: orgID, otherID: other.ID, nodes: map[string]string{}}
+	for _, n := range []struct{ host, org string }{{"web-1", orgID}, {"web-2", orgID}, {"db-1", orgID}, {"elsewhere", other.ID}} {
+		env.nodes[n.host] = memory.SeedNode(t, db, n.org, userID, n.host).ID
+	}
+	nodes := node.NewService(memory.NewNodeRepository(db))
+	env.groups = nodegroup.NewService(memory.NewNodeGroupRepository(db), nodes)
+	env.scripts = script.NewService(memory.NewScriptRepository(db), nodes, env.groups)
+	return env
+}
+
+func TestCreateAndVersions(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+
+	s, err := env.scripts.Create(ctx, env.orgID, &env.userID, "backup.sh", " nightly dump ", "#!/bin/sh\necho v1\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 || s.SHA256 != blob.Key([]byte("#!/bin/sh\necho v1\n")) || s.Description != "nightly dump" {
+		t.Errorf("Create = %+v", s)
+	}
+	v2, err := env.scripts.AddVersion(ctx, env.orgID, s.ID, &env.userID, "#!/bin/sh\necho v2\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 || v2.Size != 18 {
+		t.Errorf("AddVersion = %+v", v2)
+	}
+	if got, err := env.scripts.Get(ctx, env.orgID, s.ID); err != nil || got.Version != 2 || got.SHA256 != v2.SHA256 {
+		t.Errorf("Get = %+v, %v", got, err)
+	}
+	if v1, err := env.scripts.GetVersion(ctx, env.orgID, s.ID, 1); err != nil || v1.Body != "#!/bin/sh\necho v1\n" {
+		t.Errorf("GetVersion(1) = %+v, %v", v1, err)
+	}
+	if _, err := env.scripts.Versions(ctx, env.otherID, s.ID); !errors.Is(err, script.ErrScriptNotFound) {
+		t.Errorf("Versions from another organization = %v, want ErrScriptNotFound", err)
+	}
+
+	tests := []struct {
+		name       string
+		scriptName string
+		body       string
+		want       error
+	}{
+		{"duplicate name", "backup.sh", "x", script.ErrScriptExists},
+		{"slash in name", "bin/backup.sh", "x", script.ErrInvalidScript},
+		{"hidden file", ".backup", "x", script.ErrInvalidScript},
+		{"empty body", "empty.sh", "", script.ErrInvalidScript},
+		{"NUL byte", "nul.sh", "a\x00b", script.ErrInvalidScript},
+		{"invalid UTF-8", "latin1.sh", "caf\xe9", script.ErrInvalidScript},
+		{"too large", "big.sh", strings.Repeat("x", script.MaxBodySize+1), script.ErrScriptTooLarge},
+	}
+	for _, tt := range tests {
+		if _, err := env.scripts.Create(ctx, env.orgID, nil, tt.scriptName, "", tt.body); !errors.Is(err, tt.want) {
+			t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestAssignAndManifest(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t)
+	backup, err := env.scripts.Create(ctx, env.orgID, nil, "backup.sh", "", "echo backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deploy, err := env.scripts.Create(ctx, env.orgID, nil, "deploy.sh", "", "echo deploy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	web, err := env.groups.Create(ctx, env.orgID, "web", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"web-1", "web-2"} {
+		if err := env.groups.AddNode(ctx, env.orgID, web.ID, env.nodes[host]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byGroup, err := env.scripts.Assign(ctx, env.orgID, backup.ID, script.Target{GroupID: web.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.scripts.Assign(ctx, env.orgID, deploy.ID, script.Target{NodeID: env.nodes["web-1"]}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("rejected assignments", func(t *testing.T) {
+		tests := []struct {
+			name string
+			t    script.Target
+			want error
+		}{
+			{"no target", script.Target{}, script.ErrInvalidAssignment},
+			{"two targets", script.Target{NodeID: env.nodes["db-1"], GroupID: web.ID}, script.ErrInvalidAssignment},
+			{"another organization's node", script.Target{NodeID: env.nodes["elsewhere"]}, node.ErrNodeNotFound},
+			{"unknown group", script.Target{GroupID: "00000000-0000-0000-0000-000000000000"}, nodegroup.ErrGroupNotFound},
+			{"already assigned", script.Target{GroupID: web.ID}, script.ErrAssignmentExists},
+		}
+		for _, tt := range tests {
+			if _, err := env.scripts.Assign(ctx, env.orgID, backup.ID, tt.t); !errors.Is(err, tt.want) {
+				t.Errorf("%s: error = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+	})
+
+	manifest, err := env.scripts.Manifest(ctx, env.nodes["web-1"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []script.Entry{
+		{Name: "backup.sh", Version: 1, SHA256: backup.SHA256},
+		{Name: "deploy.sh", Version: 1, SHA256: deploy.SHA256},
+	}
+	if !slices.Equal(manifest, want) {
+		t.Errorf("Manifest(web-1) = %+v, want %+v", manifest, want)
+	}
+	if manifest, err := env.scripts.Manifest(ctx, env.nodes["db-1"]); err != nil || len(manifest) != 0 {
+		t.Errorf("Manifest(db-1) = %+v, %v", manifest, err)
+	}
+
+	sc, v, err := env.scripts.NodeScript(ctx, env.nodes["web-2"], "backup.sh")
+	if err != nil || sc.ID != backup.ID || v.Body != "echo backup" {
+		t.Errorf("NodeScript = %+v, %+v, %v", sc, v, err)
+	}
+	if _, _, err := env.scripts.NodeScript(ctx, env.nodes["web-2"], "deploy.sh"); !errors.Is(err, script.ErrScriptNotFound) {
+		t.Errorf("NodeScript(unassigned) = %v, want ErrScriptNotFound", err)
+	}
+
+	if err := env.scripts.Unassign(ctx, env.orgID, backup.ID, byGroup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if nodes, err := env.scripts.Nodes(ctx, env.orgID, backup.ID); err != nil || len(nodes) != 0 {
+		t.Errorf("Nodes after Unassign = %v, %v", nodes, err)
+	}
+
+	removed, err := env.scripts.Delete(ctx, env.orgID, deploy.ID)
+	if err != nil || !slices.Equal(removed, []string{env.nodes["web-1"]}) {
+		t.Errorf("Delete = %v, %v", removed, err)
+	}
+	if manifest, err := env.scripts.Manifest(ctx, env.nodes["web-1"]); err != nil || len(manifest) != 0 {
+		t.Errorf("Manifest(web-1) after Delete = %+v, %v", manifest, err)
+	}
+}
@@ -0,0 +1,135 @@
+package signingkey
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// ReloadInterval es cada cuánto cada instancia recarga las claves (ver Load)
+const ReloadInterval = time.Minute
+
+// JWKSMaxAge es cuánto pueden cachear el JWKS sus consumidores
+const JWKSMaxAge = 5 * time.Minute
+
+// ActivationDelay es cuánto solo verifica una clave rotada antes de firmar:
+// para entonces todas las instancias y los consumidores del JWKS la conocen.
+const ActivationDelay = ReloadInterval + JWKSMaxAge
+
+// Service gestiona las claves de firma guardadas en Postgres
+type Service struct {
+	repo            Repository
+	encryptionKey   []byte
+	algorithm       string
+	activationDelay time.Duration
+	verifyGrace     time.Duration
+}
+
+// NewService crea el servicio. Una clave rotada empieza a firmar tras
+// activationDelay (normalmente ActivationDelay); entonces la anterior deja de
+// firmar y sigue verificando durante verifyGrace, que debe cubrir la vida de
+// un access token.
+func NewService(repo Repository, encryptionKey []byte, algorithm string, activationDelay, verifyGrace time.Duration) *Service {
+	return &Service{
+		repo:            repo,
+		encryptionKey:   encryptionKey,
+		algorithm:       algorithm,
+		activationDelay: activationDelay,
+		verifyGrace:     verifyGrace,
+	}
+}
+
+// EnsureActive crea una clave de firma si no hay ninguna activa ni pendiente.
+// Como nadie tiene otra con la que firmar, firma desde ya.
+func (s *Service) EnsureActive(ctx context.Context) error {
+	keys, err := s.repo.FindUsable(ctx)
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if k.RetiredAt == nil {
+			return nil
+		}
+	}
+	_, err = s.rotate(ctx, time.Now())
+	return err
+}
+
+// Rotate genera una clave nueva que solo verifica hasta pasado el retraso de
+// activación y luego sustituye a la clave de firma actual.
+func (s *Service) Rotate(ctx context.Context) (*Key, error) {
+	return s.rotate(ctx, time.Now().Add(s.activationDelay))
+}
+
+func (s *Service) rotate(ctx context.Context, activateAt time.Time) (*Key, error) {
+	sk, err := platform.GenerateSigningKey(s.algorithm)
+	if err != nil {
+		return nil, err
+	}
+	privPEM, err := platform.MarshalPrivateKeyPEM(sk)
+	if err != nil {
+		return nil, err
+	}
+	pubPEM, err := platform.MarshalPublicKeyPEM(sk)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := platform.Seal(s.encryptionKey, privPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	k := &Key{
+		ID:                  sk.ID,
+		Algorithm:           sk.Algorithm,
+		PublicKey:           string(pubPEM),
+		PrivateKeyEncrypted: sealed,
+		ActivatesAt:         activateAt,
+	}
+	if err := s.repo.Rotate(ctx, k, activateAt, activateAt.Add(s.verifyGrace)); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// List devuelve las claves que aún verifican
+func (s *Service) List(ctx context.Context) ([]*Key, error) {
+	return s.repo.FindUsable(ctx)
+}
+
+// Load descifra las claves vigentes y las instala en el llavero. La clave más
+// reciente que ya puede firmar (ver Key.Signs) firma; el resto, incluidas las
+// que aún no se activaron, solo verifica.
+func (s *Service) Load(ctx context.Context, ring *platform.KeyRing) error {
+	keys, err := s.repo.FindUsable(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var signing *platform.SigningKey
+	verification := make([]*platform.SigningKey, 0, len(keys))
+	for _, k := range keys {
+		privPEM, err := platform.Open(s.encryptionKey, k.PrivateKeyEncrypted)
+		if err != nil {
+			return fmt.Errorf("decrypt signing key %s: %w", k.ID, err)
+		}
+		sk, err := platform.ParseSigningKeyPEM(k.ID, privPEM)
+		if err != nil {
+			return fmt.Errorf("parse signing key %s: %w", k.ID, err)
+		}
+		if signing == nil && k.Signs(now) {
+			signing = sk
+			continue
+		}
+		verification = append(verification, sk)
+	}
+	if signing == nil {
+		return platform.ErrNoSigningKey
+	}
+
+	ring.SetKeys(signing, verification)
+	return nil
+}
@@ -0,0 +1,87 @@
+package signingkey_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+// signingKID signs a token with ring and returns the kid it was signed with.
+func signingKID(t *testing.T, ring *platform.KeyRing) (tok, kid string) {
+	t.Helper()
+	tok, err := ring.SignAccessToken("user-1", "dev@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, _, err := jwt.NewParser().ParseUnverified(tok, &platform.JWTClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok, parsed.Header["kid"].(string)
+}
+
+func publishedKIDs(ring *platform.KeyRing) []string {
+	var kids []string
+	for _, k := range ring.JWKS().Keys {
+		kids = append(kids, k.Kid)
+	}
+	return kids
+}
+
+// TestRotateActivatesAfterDelay checks that a rotated key is only published
+// and verifies until its activation, while the previous key keeps signing.
+func TestRotateActivatesAfterDelay(t *testing.T) {
+	ctx := context.Background()
+	repo := memory.NewSigningKeyRepository(memory.NewDB())
+	encKey := make([]byte, 32)
+	svc := signingkey.NewService(repo, encKey, platform.AlgEdDSA, time.Hour, time.Hour)
+	ring := platform.NewKeyRing(platform.JWTConfig{Issuer: "test", Audience: "test", AccessTTL: time.Minute})
+
+	if err := svc.EnsureActive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Load(ctx, ring); err != nil {
+		t.Fatal(err)
+	}
+	oldTok, first := signingKID(t, ring)
+
+	next, err := svc.Rotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.EnsureActive(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Load(ctx, ring); err != nil {
+		t.Fatal(err)
+	}
+	if _, kid := signingKID(t, ring); kid != first {
+		t.Errorf("signing with %s before the new key activates, want %s", kid, first)
+	}
+	if kids := publishedKIDs(ring); len(kids) != 2 || !slices.Contains(kids, next.ID) {
+		t.Errorf("JWKS = %v, want %s and %s", kids, first, next.ID)
+	}
+
+	// Without a delay the rotated key signs on the next load
+	now := signingkey.NewService(repo, encKey, platform.AlgEdDSA, 0, time.Hour)
+	third, err := now.Rotate(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := now.Load(ctx, ring); err != nil {
+		t.Fatal(err)
+	}
+	if _, kid := signingKID(t, ring); kid != third.ID {
+		t.Errorf("signing with %s, want %s", kid, third.ID)
+	}
+	if _, err := ring.ParseAccessToken(oldTok); err != nil {
+		t.Errorf("token of the retired key: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package signingkey
+
+import (
+	"context"
+	"time"
+)
+
+// Key es una clave de firma de access tokens persistida
+type Key struct {
+	ID                  string     `db:"id"` // kid
+	Algorithm           string     `db:"algorithm"`
+	PublicKey           string     `db:"public_key"`
+	PrivateKeyEncrypted []byte     `db:"private_key_encrypted"`
+	CreatedAt           time.Time  `db:"created_at"`
+	ActivatesAt         time.Time  `db:"activates_at"` // empieza a firmar
+	RetiredAt           *time.Time `db:"retired_at"`   // deja de firmar, puede estar en el futuro
+	ExpiresAt           *time.Time `db:"expires_at"`   // deja de verificar
+}
+
+// Signs indica si k puede firmar en t: ya se activó y aún no se retiró
+func (k *Key) Signs(t time.Time) bool {
+	return !k.ActivatesAt.After(t) && (k.RetiredAt == nil || k.RetiredAt.After(t))
+}
+
+type Repository interface {
+	// FindUsable devuelve las claves que aún verifican, de la más nueva a la más vieja
+	FindUsable(ctx context.Context) ([]*Key, error)
+	// Rotate guarda next, que firma desde activateAt, y retira en ese momento
+	// las claves activas (dejan de verificar en verifyUntil), de forma atómica.
+	Rotate(ctx context.Context, next *Key, activateAt, verifyUntil time.Time) error
+}
@@ -323,6 +323,8 @@ type ServerMessage struct {
 	// Types that are valid to be assigned to Payload:
 	//
 	//	*ServerMessage_ExecuteJob
+	//	*ServerMessage_ServerShutdown
+	//	*ServerMessage_SyncScripts
 	Payload       isServerMessage_Payload `protobuf_oneof:"payload"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -374,6 +376,24 @@ func (x *ServerMessage) GetExecuteJob() *ExecuteJobPayload {
 	return nil
 }
 
+func (x *ServerMessage) GetServerShutdown() *ServerShutdownPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_ServerShutdown); ok {
+			return x.ServerShutdown
+		}
+	}
+	return nil
+}
+
+func (x *ServerMessage) GetSyncScripts() *SyncScriptsPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerMessage_SyncScripts); ok {
+			return x.SyncScripts
+		}
+	}
+	return nil
+}
+
 type isServerMessage_Payload interface {
 	isServerMessage_Payload()
 }
@@ -382,8 +402,20 @@ type ServerMessage_ExecuteJob struct {
 	ExecuteJob *ExecuteJobPayload `protobuf:"bytes,1,opt,name=execute_job,json=executeJob,proto3,oneof"`
 }
 
+type ServerMessage_ServerShutdown struct {
+	ServerShutdown *ServerShutdownPayload `protobuf:"bytes,2,opt,name=server_shutdown,json=serverShutdown,proto3,oneof"`
+}
+
+type ServerMessage_SyncScripts struct {
+	SyncScripts *SyncScriptsPayload `protobuf:"bytes,3,opt,name=sync_scripts,json=syncScripts,proto3,oneof"`
+}
+
 func (*ServerMessage_ExecuteJob) isServerMessage_Payload() {}
 
+func (*ServerMessage_ServerShutdown) isServerMessage_Payload() {}
+
+func (*ServerMessage_SyncScripts) isServerMessage_Payload() {}
+
 type JobResultPayload struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
 	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
@@ -497,10 +529,14 @@ func (x *HeartbeatPayload) GetNodeId() string {
 }
 
 type ExecuteJobPayload struct {
-	state         protoimpl.MessageState `protogen:"open.v1"`
-	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
-	CommandName   string                 `protobuf:"bytes,2,opt,name=command_name,json=commandName,proto3" json:"command_name,omitempty"`
-	CommandType   CommandType            `protobuf:"varint,3,opt,name=command_type,json=commandType,proto3,enum=node_agent.v1.CommandType" json:"command_type,omitempty"`
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	JobId       string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`
+	CommandName string                 `protobuf:"bytes,2,opt,name=command_name,json=commandName,proto3" json:"command_name,omitempty"`
+	CommandType CommandType            `protobuf:"varint,3,opt,name=command_type,json=commandType,proto3,enum=node_agent.v1.CommandType" json:"command_type,omitempty"`
+	// W3C trace context (https://www.w3.org/TR/trace-context/) of the job's
+	// lifecycle span; agents start their execution span as its child.
+	Traceparent   string `protobuf:"bytes,4,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
+	Tracestate    string `protobuf:"bytes,5,opt,name=tracestate,proto3" json:"tracestate,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -556,6 +592,314 @@ func (x *ExecuteJobPayload) GetCommandType() CommandType {
 	return CommandType_COMMAND_TYPE_DEFAULT
 }
 
+func (x *ExecuteJobPayload) GetTraceparent() string {
+	if x != nil {
+		return x.Traceparent
+	}
+	return ""
+}
+
+func (x *ExecuteJobPayload) GetTracestate() string {
+	if x != nil {
+		return x.Tracestate
+	}
+	return ""
+}
+
+// Sent before the server stops: the agent should close the stream and
+// reconnect (possibly to another replica) after reconnect_after_ms.
+type ServerShutdownPayload struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Reason           string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReconnectAfterMs int32                  `protobuf:"varint,2,opt,name=reconnect_after_ms,json=reconnectAfterMs,proto3" json:"reconnect_after_ms,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *ServerShutdownPayload) Reset() {
+	*x = ServerShutdownPayload{}
+	mi := &file_node_agent_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerShutdownPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerShutdownPayload) ProtoMessage() {}
+
+func (x *ServerShutdownPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_node_agent_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerShutdownPayload.ProtoReflect.Descriptor instead.
+func (*ServerShutdownPayload) Descriptor() ([]byte, []int) {
+	return file_node_agent_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ServerShutdownPayload) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *ServerShutdownPayload) GetReconnectAfterMs() int32 {
+	if x != nil {
+		return x.ReconnectAfterMs
+	}
+	return 0
+}
+
+type ArtifactChunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	JobId         string                 `protobuf:"bytes,1,opt,name=job_id,json=jobId,proto3" json:"job_id,omitempty"`                   // first chunk only
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`                                  // first chunk only; a file name, no slashes
+	ContentType   string                 `protobuf:"bytes,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"` // first chunk only; optional
+	Sha256        string                 `protobuf:"bytes,4,opt,name=sha256,proto3" json:"sha256,omitempty"`                              // first chunk only; optional, hex, checked on receipt
+	Data          []byte                 `protobuf:"bytes,5,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ArtifactChunk) Reset() {
+	*x = ArtifactChunk{}
+	mi := &file_node_agent_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ArtifactChunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ArtifactChunk) ProtoMessage() {}
+
+func (x *ArtifactChunk) ProtoReflect() protoreflect.Message {
+	mi := &file_node_agent_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ArtifactChunk.ProtoReflect.Descriptor instead.
+func (*ArtifactChunk) Descriptor() ([]byte, []int) {
+	return file_node_agent_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ArtifactChunk) GetJobId() string {
+	if x != nil {
+		return x.JobId
+	}
+	return ""
+}
+
+func (x *ArtifactChunk) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ArtifactChunk) GetContentType() string {
+	if x != nil {
+		return x.ContentType
+	}
+	return ""
+}
+
+func (x *ArtifactChunk) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+func (x *ArtifactChunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+type UploadArtifactResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Size          int64                  `protobuf:"varint,2,opt,name=size,proto3" json:"size,omitempty"`
+	Sha256        string                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *UploadArtifactResponse) Reset() {
+	*x = UploadArtifactResponse{}
+	mi := &file_node_agent_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *UploadArtifactResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*UploadArtifactResponse) ProtoMessage() {}
+
+func (x *UploadArtifactResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_node_agent_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use UploadArtifactResponse.ProtoReflect.Descriptor instead.
+func (*UploadArtifactResponse) Descriptor() ([]byte, []int) {
+	return file_node_agent_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *UploadArtifactResponse) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *UploadArtifactResponse) GetSize() int64 {
+	if x != nil {
+		return x.Size
+	}
+	return 0
+}
+
+func (x *UploadArtifactResponse) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
+type SyncScriptsPayload struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Scripts       []*ScriptRef           `protobuf:"bytes,1,rep,name=scripts,proto3" json:"scripts,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SyncScriptsPayload) Reset() {
+	*x = SyncScriptsPayload{}
+	mi := &file_node_agent_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncScriptsPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncScriptsPayload) ProtoMessage() {}
+
+func (x *SyncScriptsPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_node_agent_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncScriptsPayload.ProtoReflect.Descriptor instead.
+func (*SyncScriptsPayload) Descriptor() ([]byte, []int) {
+	return file_node_agent_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *SyncScriptsPayload) GetScripts() []*ScriptRef {
+	if x != nil {
+		return x.Scripts
+	}
+	return nil
+}
+
+type ScriptRef struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Version       int32                  `protobuf:"varint,2,opt,name=version,proto3" json:"version,omitempty"`
+	Sha256        string                 `protobuf:"bytes,3,opt,name=sha256,proto3" json:"sha256,omitempty"` // hex SHA-256 of the script body
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScriptRef) Reset() {
+	*x = ScriptRef{}
+	mi := &file_node_agent_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScriptRef) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScriptRef) ProtoMessage() {}
+
+func (x *ScriptRef) ProtoReflect() protoreflect.Message {
+	mi := &file_node_agent_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScriptRef.ProtoReflect.Descriptor instead.
+func (*ScriptRef) Descriptor() ([]byte, []int) {
+	return file_node_agent_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ScriptRef) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ScriptRef) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *ScriptRef) GetSha256() string {
+	if x != nil {
+		return x.Sha256
+	}
+	return ""
+}
+
 var File_node_agent_proto protoreflect.FileDescriptor
 
 const file_node_agent_proto_rawDesc = "" +
@@ -576,10 +920,12 @@ const file_node_agent_proto_rawDesc = "" +
 	"\n" +
 	"job_result\x18\x01 \x01(\v2\x1f.node_agent.v1.JobResultPayloadH\x00R\tjobResult\x12?\n" +
 	"\theartbeat\x18\x02 \x01(\v2\x1f.node_agent.v1.HeartbeatPayloadH\x00R\theartbeatB\t\n" +
-	"\apayload\"_\n" +
+	"\apayload\"\xf8\x01\n" +
 	"\rServerMessage\x12C\n" +
 	"\vexecute_job\x18\x01 \x01(\v2 .node_agent.v1.ExecuteJobPayloadH\x00R\n" +
-	"executeJobB\t\n" +
+	"executeJob\x12O\n" +
+	"\x0fserver_shutdown\x18\x02 \x01(\v2$.node_agent.v1.ServerShutdownPayloadH\x00R\x0eserverShutdown\x12F\n" +
+	"\fsync_scripts\x18\x03 \x01(\v2!.node_agent.v1.SyncScriptsPayloadH\x00R\vsyncScriptsB\t\n" +
 	"\apayload\"\x89\x01\n" +
 	"\x10JobResultPayload\x12\x15\n" +
 	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x120\n" +
@@ -587,21 +933,45 @@ const file_node_agent_proto_rawDesc = "" +
 	"\x06output\x18\x03 \x01(\tR\x06output\x12\x14\n" +
 	"\x05error\x18\x04 \x01(\tR\x05error\"+\n" +
 	"\x10HeartbeatPayload\x12\x17\n" +
-	"\anode_id\x18\x01 \x01(\tR\x06nodeId\"\x8c\x01\n" +
+	"\anode_id\x18\x01 \x01(\tR\x06nodeId\"\xce\x01\n" +
 	"\x11ExecuteJobPayload\x12\x15\n" +
 	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12!\n" +
 	"\fcommand_name\x18\x02 \x01(\tR\vcommandName\x12=\n" +
-	"\fcommand_type\x18\x03 \x01(\x0e2\x1a.node_agent.v1.CommandTypeR\vcommandType*@\n" +
+	"\fcommand_type\x18\x03 \x01(\x0e2\x1a.node_agent.v1.CommandTypeR\vcommandType\x12 \n" +
+	"\vtraceparent\x18\x04 \x01(\tR\vtraceparent\x12\x1e\n" +
+	"\n" +
+	"tracestate\x18\x05 \x01(\tR\n" +
+	"tracestate\"]\n" +
+	"\x15ServerShutdownPayload\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12,\n" +
+	"\x12reconnect_after_ms\x18\x02 \x01(\x05R\x10reconnectAfterMs\"\x89\x01\n" +
+	"\rArtifactChunk\x12\x15\n" +
+	"\x06job_id\x18\x01 \x01(\tR\x05jobId\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12!\n" +
+	"\fcontent_type\x18\x03 \x01(\tR\vcontentType\x12\x16\n" +
+	"\x06sha256\x18\x04 \x01(\tR\x06sha256\x12\x12\n" +
+	"\x04data\x18\x05 \x01(\fR\x04data\"X\n" +
+	"\x16UploadArtifactResponse\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x12\n" +
+	"\x04size\x18\x02 \x01(\x03R\x04size\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256\"H\n" +
+	"\x12SyncScriptsPayload\x122\n" +
+	"\ascripts\x18\x01 \x03(\v2\x18.node_agent.v1.ScriptRefR\ascripts\"Q\n" +
+	"\tScriptRef\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x18\n" +
+	"\aversion\x18\x02 \x01(\x05R\aversion\x12\x16\n" +
+	"\x06sha256\x18\x03 \x01(\tR\x06sha256*@\n" +
 	"\vCommandType\x12\x18\n" +
 	"\x14COMMAND_TYPE_DEFAULT\x10\x00\x12\x17\n" +
 	"\x13COMMAND_TYPE_CUSTOM\x10\x01*T\n" +
 	"\tJobStatus\x12\x16\n" +
 	"\x12JOB_STATUS_RUNNING\x10\x00\x12\x18\n" +
 	"\x14JOB_STATUS_COMPLETED\x10\x01\x12\x15\n" +
-	"\x11JOB_STATUS_FAILED\x10\x022\xc1\x01\n" +
+	"\x11JOB_STATUS_FAILED\x10\x022\x9a\x02\n" +
 	"\x10NodeAgentService\x12d\n" +
 	"\x10RegisterCommands\x12%.node_agent.v1.RegisterCommandRequest\x1a'.node_agent.v1.RegisterCommandsResponse(\x01\x12G\n" +
-	"\aConnect\x12\x1a.node_agent.v1.NodeMessage\x1a\x1c.node_agent.v1.ServerMessage(\x010\x01B;Z9github.com/arturo/autohost-cloud-api/internal/grpc/nodepbb\x06proto3"
+	"\aConnect\x12\x1a.node_agent.v1.NodeMessage\x1a\x1c.node_agent.v1.ServerMessage(\x010\x01\x12W\n" +
+	"\x0eUploadArtifact\x12\x1c.node_agent.v1.ArtifactChunk\x1a%.node_agent.v1.UploadArtifactResponse(\x01B;Z9github.com/arturo/autohost-cloud-api/internal/grpc/nodepbb\x06proto3"
 
 var (
 	file_node_agent_proto_rawDescOnce sync.Once
@@ -616,7 +986,7 @@ func file_node_agent_proto_rawDescGZIP() []byte {
 }
 
 var file_node_agent_proto_enumTypes = make([]protoimpl.EnumInfo, 2)
-var file_node_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 7)
+var file_node_agent_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
 var file_node_agent_proto_goTypes = []any{
 	(CommandType)(0),                 // 0: node_agent.v1.CommandType
 	(JobStatus)(0),                   // 1: node_agent.v1.JobStatus
@@ -627,23 +997,33 @@ var file_node_agent_proto_goTypes = []any{
 	(*JobResultPayload)(nil),         // 6: node_agent.v1.JobResultPayload
 	(*HeartbeatPayload)(nil),         // 7: node_agent.v1.HeartbeatPayload
 	(*ExecuteJobPayload)(nil),        // 8: node_agent.v1.ExecuteJobPayload
+	(*ServerShutdownPayload)(nil),    // 9: node_agent.v1.ServerShutdownPayload
+	(*ArtifactChunk)(nil),            // 10: node_agent.v1.ArtifactChunk
+	(*UploadArtifactResponse)(nil),   // 11: node_agent.v1.UploadArtifactResponse
+	(*SyncScriptsPayload)(nil),       // 12: node_agent.v1.SyncScriptsPayload
+	(*ScriptRef)(nil),                // 13: node_agent.v1.ScriptRef
 }
 var file_node_agent_proto_depIdxs = []int32{
-	0, // 0: node_agent.v1.RegisterCommandRequest.type:type_name -> node_agent.v1.CommandType
-	6, // 1: node_agent.v1.NodeMessage.job_result:type_name -> node_agent.v1.JobResultPayload
-	7, // 2: node_agent.v1.NodeMessage.heartbeat:type_name -> node_agent.v1.HeartbeatPayload
-	8, // 3: node_agent.v1.ServerMessage.execute_job:type_name -> node_agent.v1.ExecuteJobPayload
-	1, // 4: node_agent.v1.JobResultPayload.status:type_name -> node_agent.v1.JobStatus
-	0, // 5: node_agent.v1.ExecuteJobPayload.command_type:type_name -> node_agent.v1.CommandType
-	2, // 6: node_agent.v1.NodeAgentService.RegisterCommands:input_type -> node_agent.v1.RegisterCommandRequest
-	4, // 7: node_agent.v1.NodeAgentService.Connect:input_type -> node_agent.v1.NodeMessage
-	3, // 8: node_agent.v1.NodeAgentService.RegisterCommands:output_type -> node_agent.v1.RegisterCommandsResponse
-	5, // 9: node_agent.v1.NodeAgentService.Connect:output_type -> node_agent.v1.ServerMessage
-	8, // [8:10] is the sub-list for method output_type
-	6, // [6:8] is the sub-list for method input_type
-	6, // [6:6] is the sub-list for extension type_name
-	6, // [6:6] is the sub-list for extension extendee
-	0, // [0:6] is the sub-list for field type_name
+	0,  // 0: node_agent.v1.RegisterCommandRequest.type:type_name -> node_agent.v1.CommandType
+	6,  // 1: node_agent.v1.NodeMessage.job_result:type_name -> node_agent.v1.JobResultPayload
+	7,  // 2: node_agent.v1.NodeMessage.heartbeat:type_name -> node_agent.v1.HeartbeatPayload
+	8,  // 3: node_agent.v1.ServerMessage.execute_job:type_name -> node_agent.v1.ExecuteJobPayload
+	9,  // 4: node_agent.v1.ServerMessage.server_shutdown:type_name -> node_agent.v1.ServerShutdownPayload
+	12, // 5: node_agent.v1.ServerMessage.sync_scripts:type_name -> node_agent.v1.SyncScriptsPayload
+	1,  // 6: node_agent.v1.JobResultPayload.status:type_name -> node_agent.v1.JobStatus
+	0,  // 7: node_agent.v1.ExecuteJobPayload.command_type:type_name -> node_agent.v1.CommandType
+	13, // 8: node_agent.v1.SyncScriptsPayload.scripts:type_name -> node_agent.v1.ScriptRef
+	2,  // 9: node_agent.v1.NodeAgentService.RegisterCommands:input_type -> node_agent.v1.RegisterCommandRequest
+	4,  // 10: node_agent.v1.NodeAgentService.Connect:input_type -> node_agent.v1.NodeMessage
+	10, // 11: node_agent.v1.NodeAgentService.UploadArtifact:input_type -> node_agent.v1.ArtifactChunk
+	3,  // 12: node_agent.v1.NodeAgentService.RegisterCommands:output_type -> node_agent.v1.RegisterCommandsResponse
+	5,  // 13: node_agent.v1.NodeAgentService.Connect:output_type -> node_agent.v1.ServerMessage
+	11, // 14: node_agent.v1.NodeAgentService.UploadArtifact:output_type -> node_agent.v1.UploadArtifactResponse
+	12, // [12:15] is the sub-list for method output_type
+	9,  // [9:12] is the sub-list for method input_type
+	9,  // [9:9] is the sub-list for extension type_name
+	9,  // [9:9] is the sub-list for extension extendee
+	0,  // [0:9] is the sub-list for field type_name
 }
 
 func init() { file_node_agent_proto_init() }
@@ -657,6 +1037,8 @@ func file_node_agent_proto_init() {
 	}
 	file_node_agent_proto_msgTypes[3].OneofWrappers = []any{
 		(*ServerMessage_ExecuteJob)(nil),
+		(*ServerMessage_ServerShutdown)(nil),
+		(*ServerMessage_SyncScripts)(nil),
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -664,7 +1046,7 @@ func file_node_agent_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_node_agent_proto_rawDesc), len(file_node_agent_proto_rawDesc)),
 			NumEnums:      2,
-			NumMessages:   7,
+			NumMessages:   12,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
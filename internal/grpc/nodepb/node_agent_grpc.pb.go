@@ -21,6 +21,7 @@ const _ = grpc.SupportPackageIsVersion9
 const (
 	NodeAgentService_RegisterCommands_FullMethodName = "/node_agent.v1.NodeAgentService/RegisterCommands"
 	NodeAgentService_Connect_FullMethodName          = "/node_agent.v1.NodeAgentService/Connect"
+	NodeAgentService_UploadArtifact_FullMethodName   = "/node_agent.v1.NodeAgentService/UploadArtifact"
 )
 
 // NodeAgentServiceClient is the client API for NodeAgentService service.
@@ -31,6 +32,8 @@ type NodeAgentServiceClient interface {
 	RegisterCommands(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[RegisterCommandRequest, RegisterCommandsResponse], error)
 	// Long-lived bidirectional stream used to dispatch jobs and receive results.
 	Connect(ctx context.Context, opts ...grpc.CallOption) (grpc.BidiStreamingClient[NodeMessage, ServerMessage], error)
+	// Agent streams a job artifact; server stores it, then acks.
+	UploadArtifact(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ArtifactChunk, UploadArtifactResponse], error)
 }
 
 type nodeAgentServiceClient struct {
@@ -67,6 +70,19 @@ func (c *nodeAgentServiceClient) Connect(ctx context.Context, opts ...grpc.CallO
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NodeAgentService_ConnectClient = grpc.BidiStreamingClient[NodeMessage, ServerMessage]
 
+func (c *nodeAgentServiceClient) UploadArtifact(ctx context.Context, opts ...grpc.CallOption) (grpc.ClientStreamingClient[ArtifactChunk, UploadArtifactResponse], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &NodeAgentService_ServiceDesc.Streams[2], NodeAgentService_UploadArtifact_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[ArtifactChunk, UploadArtifactResponse]{ClientStream: stream}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NodeAgentService_UploadArtifactClient = grpc.ClientStreamingClient[ArtifactChunk, UploadArtifactResponse]
+
 // NodeAgentServiceServer is the server API for NodeAgentService service.
 // All implementations must embed UnimplementedNodeAgentServiceServer
 // for forward compatibility.
@@ -75,6 +91,8 @@ type NodeAgentServiceServer interface {
 	RegisterCommands(grpc.ClientStreamingServer[RegisterCommandRequest, RegisterCommandsResponse]) error
 	// Long-lived bidirectional stream used to dispatch jobs and receive results.
 	Connect(grpc.BidiStreamingServer[NodeMessage, ServerMessage]) error
+	// Agent streams a job artifact; server stores it, then acks.
+	UploadArtifact(grpc.ClientStreamingServer[ArtifactChunk, UploadArtifactResponse]) error
 	mustEmbedUnimplementedNodeAgentServiceServer()
 }
 
@@ -91,6 +109,9 @@ func (UnimplementedNodeAgentServiceServer) RegisterCommands(grpc.ClientStreaming
 func (UnimplementedNodeAgentServiceServer) Connect(grpc.BidiStreamingServer[NodeMessage, ServerMessage]) error {
 	return status.Error(codes.Unimplemented, "method Connect not implemented")
 }
+func (UnimplementedNodeAgentServiceServer) UploadArtifact(grpc.ClientStreamingServer[ArtifactChunk, UploadArtifactResponse]) error {
+	return status.Error(codes.Unimplemented, "method UploadArtifact not implemented")
+}
 func (UnimplementedNodeAgentServiceServer) mustEmbedUnimplementedNodeAgentServiceServer() {}
 func (UnimplementedNodeAgentServiceServer) testEmbeddedByValue()                          {}
 
@@ -126,6 +147,13 @@ func _NodeAgentService_Connect_Handler(srv interface{}, stream grpc.ServerStream
 // This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
 type NodeAgentService_ConnectServer = grpc.BidiStreamingServer[NodeMessage, ServerMessage]
 
+func _NodeAgentService_UploadArtifact_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(NodeAgentServiceServer).UploadArtifact(&grpc.GenericServerStream[ArtifactChunk, UploadArtifactResponse]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type NodeAgentService_UploadArtifactServer = grpc.ClientStreamingServer[ArtifactChunk, UploadArtifactResponse]
+
 // NodeAgentService_ServiceDesc is the grpc.ServiceDesc for NodeAgentService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -145,6 +173,11 @@ var NodeAgentService_ServiceDesc = grpc.ServiceDesc{
 			ServerStreams: true,
 			ClientStreams: true,
 		},
+		{
+			StreamName:    "UploadArtifact",
+			Handler:       _NodeAgentService_UploadArtifact_Handler,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "node_agent.proto",
 }
@@ -2,63 +2,101 @@ package grpcserver
 
 import (
 	"context"
-	"log"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
 	"strings"
 	"sync"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
 	"google.golang.org/grpc/status"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
 	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
 	pb "github.com/arturo/autohost-cloud-api/internal/grpc/nodepb"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
 )
 
 // nodeStream holds the send side of an active gRPC Connect stream.
 type nodeStream struct {
 	send chan *pb.ServerMessage
+	// replaced is closed when a newer stream of the same node takes over
+	replaced chan struct{}
 }
 
 // NodeAgentServer implements pb.NodeAgentServiceServer.
 type NodeAgentServer struct {
 	pb.UnimplementedNodeAgentServiceServer
 
-	commandSvc *nodecommand.Service
-	jobSvc     *job.Service
-	tokenSvc   *nodetoken.Service
+	commandSvc  *nodecommand.Service
+	jobSvc      *job.Service
+	artifactSvc *jobartifact.Service
+	scriptSvc   *script.Service
+	tokenSvc    *nodetoken.Service
+	auditSvc    *audit.Service
+	// opTimeout bounds the database work done for each message an agent sends
+	opTimeout time.Duration
 
 	streamsMu sync.RWMutex
 	streams   map[string]*nodeStream // nodeID -> active stream
+	draining  bool                   // set by Drain; guarded by streamsMu
 }
 
-// NewNodeAgentServer creates a ready-to-register gRPC server.
+// NewNodeAgentServer creates a ready-to-register gRPC server. opTimeout
+// bounds the database work done for each message received on a stream; zero
+// means no limit beyond the stream itself.
 func NewNodeAgentServer(
 	commandSvc *nodecommand.Service,
 	jobSvc *job.Service,
+	artifactSvc *jobartifact.Service,
+	scriptSvc *script.Service,
 	tokenSvc *nodetoken.Service,
+	auditSvc *audit.Service,
+	opTimeout time.Duration,
 ) *NodeAgentServer {
 	return &NodeAgentServer{
-		commandSvc: commandSvc,
-		jobSvc:     jobSvc,
-		tokenSvc:   tokenSvc,
-		streams:    make(map[string]*nodeStream),
+		commandSvc:  commandSvc,
+		jobSvc:      jobSvc,
+		artifactSvc: artifactSvc,
+		scriptSvc:   scriptSvc,
+		tokenSvc:    tokenSvc,
+		auditSvc:    auditSvc,
+		opTimeout:   opTimeout,
+		streams:     make(map[string]*nodeStream),
 	}
 }
 
+// opContext derives the context for handling one message of a stream: it is
+// cancelled when the stream goes away and after opTimeout.
+func (s *NodeAgentServer) opContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if s.opTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, s.opTimeout)
+}
+
 // ---- Auth helper ------------------------------------------------------------
 
-// nodeIDFromCtx validates the "authorization" metadata and returns the node ID.
-func (s *NodeAgentServer) nodeIDFromCtx(ctx context.Context) (string, error) {
+// nodeTokenFromCtx validates the "authorization" metadata and returns the node token.
+func (s *NodeAgentServer) nodeTokenFromCtx(ctx context.Context) (*nodetoken.NodeToken, error) {
 	md, ok := metadata.FromIncomingContext(ctx)
 	if !ok {
-		return "", status.Error(codes.Unauthenticated, "missing metadata")
+		return nil, status.Error(codes.Unauthenticated, "missing metadata")
 	}
 	values := md.Get("authorization")
 	if len(values) == 0 {
-		return "", status.Error(codes.Unauthenticated, "missing authorization header")
+		return nil, status.Error(codes.Unauthenticated, "missing authorization header")
 	}
 
 	raw := values[0]
@@ -67,18 +105,45 @@ func (s *NodeAgentServer) nodeIDFromCtx(ctx context.Context) (string, error) {
 	}
 
 	if !strings.HasPrefix(raw, platform.TokenApiPrefix) {
-		return "", status.Error(codes.Unauthenticated, "invalid node token format")
+		return nil, status.Error(codes.Unauthenticated, "invalid node token format")
 	}
 
 	tokenHash := platform.HashTokenApi(raw)
-	tok, err := s.tokenSvc.FindNodeTokenByHash(tokenHash)
+	opCtx, cancel := s.opContext(ctx)
+	defer cancel()
+	tok, err := s.tokenSvc.FindNodeTokenByHash(opCtx, tokenHash)
 	if err != nil {
-		return "", status.Error(codes.Unauthenticated, "invalid node token")
+		// Token desconocido => Unauthenticated; un fallo de BD no debe
+		// confundirse con credenciales inválidas
+		return nil, apperr.GRPCStatus(err)
 	}
 	if tok.RevokedAt != nil {
-		return "", status.Error(codes.Unauthenticated, "node token revoked")
+		return nil, status.Error(codes.Unauthenticated, "node token revoked")
+	}
+	logging.AddAttrs(ctx, "node_id", tok.NodeID)
+	return tok, nil
+}
+
+// recordAudit appends an event performed by the node authenticated by tok.
+func (s *NodeAgentServer) recordAudit(ctx context.Context, tok *nodetoken.NodeToken, e audit.Event) {
+	e.ActorType = audit.ActorNode
+	e.ActorID = tok.NodeID
+	e.OrganizationID = &tok.OrganizationID
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			e.IP = host
+		}
+	}
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("x-request-id"); len(v) > 0 {
+			e.RequestID = v[0]
+		}
+	}
+	opCtx, cancel := s.opContext(ctx)
+	defer cancel()
+	if err := s.auditSvc.Record(opCtx, e); err != nil {
+		logging.FromContext(ctx).Error("record audit event", "action", e.Action, "error", err)
 	}
-	return tok.NodeID, nil
 }
 
 // ---- NodeDispatcher (used by JobHandler) ------------------------------------
@@ -86,9 +151,13 @@ func (s *NodeAgentServer) nodeIDFromCtx(ctx context.Context) (string, error) {
 // SendToNode pushes a ServerMessage to a connected node.
 // Returns an error if the node has no live gRPC Connect stream.
 func (s *NodeAgentServer) SendToNode(nodeID string, msg *pb.ServerMessage) error {
+	// The read lock keeps Connect from closing ns.send while we push to it.
 	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+	if s.draining {
+		return status.Error(codes.Unavailable, "server shutting down")
+	}
 	ns, ok := s.streams[nodeID]
-	s.streamsMu.RUnlock()
 	if !ok {
 		return status.Errorf(codes.NotFound, "node %s not connected via gRPC", nodeID)
 	}
@@ -105,10 +174,11 @@ func (s *NodeAgentServer) SendToNode(nodeID string, msg *pb.ServerMessage) error
 func (s *NodeAgentServer) RegisterCommands(
 	stream pb.NodeAgentService_RegisterCommandsServer,
 ) error {
-	nodeID, err := s.nodeIDFromCtx(stream.Context())
+	tok, err := s.nodeTokenFromCtx(stream.Context())
 	if err != nil {
 		return err
 	}
+	nodeID := tok.NodeID
 
 	var count int32
 	for {
@@ -123,36 +193,118 @@ func (s *NodeAgentServer) RegisterCommands(
 			Type:        pbCommandType(req.GetType()),
 			ScriptPath:  req.GetScriptPath(),
 		}
-		if _, err := s.commandSvc.Register(cmd); err != nil {
-			log.Printf("[gRPC] register command %s for node %s: %v", cmd.Name, nodeID, err)
+		ctx, cancel := s.opContext(stream.Context())
+		saved, err := s.commandSvc.Register(ctx, cmd)
+		cancel()
+		if err != nil {
+			logging.FromContext(stream.Context()).Error("register command", "command", cmd.Name, "error", err)
 			continue
 		}
+		s.recordAudit(stream.Context(), tok, audit.Event{
+			Action:     audit.ActionCommandRegister,
+			TargetType: "node_command",
+			TargetID:   saved.ID,
+			Metadata:   map[string]any{"name": saved.Name, "type": string(saved.Type), "script_path": saved.ScriptPath},
+		})
 		count++
 	}
 
-	log.Printf("[gRPC] node %s registered %d commands", nodeID, count)
+	logging.FromContext(stream.Context()).Info("commands registered", "count", count)
 	return stream.SendAndClose(&pb.RegisterCommandsResponse{Registered: count})
 }
 
+// ---- UploadArtifact (client-side stream) ------------------------------------
+
+// UploadArtifact stores the file streamed by the agent as an artifact of one
+// of its jobs. The metadata comes in the first chunk. The upload is bounded by
+// the stream, not by opTimeout, so large files can take as long as they need.
+func (s *NodeAgentServer) UploadArtifact(
+	stream pb.NodeAgentService_UploadArtifactServer,
+) error {
+	tok, err := s.nodeTokenFromCtx(stream.Context())
+	if err != nil {
+		return err
+	}
+	first, err := stream.Recv()
+	if err == io.EOF {
+		return status.Error(codes.InvalidArgument, "empty upload")
+	}
+	if err != nil {
+		return err
+	}
+
+	a, err := s.artifactSvc.Upload(stream.Context(), tok.NodeID, jobartifact.Upload{
+		JobID:       first.GetJobId(),
+		Name:        first.GetName(),
+		ContentType: first.GetContentType(),
+		SHA256:      first.GetSha256(),
+		Body:        &chunkReader{stream: stream, buf: first.GetData()},
+	})
+	if err != nil {
+		logging.FromContext(stream.Context()).Warn("artifact upload rejected", "job_id", first.GetJobId(), "name", first.GetName(), "error", err)
+		return apperr.GRPCStatus(err)
+	}
+	s.recordAudit(stream.Context(), tok, audit.Event{
+		Action:     audit.ActionJobArtifactUpload,
+		TargetType: "job",
+		TargetID:   a.JobID,
+		Metadata:   map[string]any{"name": a.Name, "size": a.Size, "sha256": a.SHA256},
+	})
+	logging.FromContext(stream.Context()).Info("artifact uploaded", "job_id", a.JobID, "name", a.Name, "size", a.Size)
+	return stream.SendAndClose(&pb.UploadArtifactResponse{Name: a.Name, Size: a.Size, Sha256: a.SHA256})
+}
+
+// chunkReader reads the data of the chunks received on an upload stream.
+type chunkReader struct {
+	stream pb.NodeAgentService_UploadArtifactServer
+	buf    []byte
+}
+
+func (c *chunkReader) Read(p []byte) (int, error) {
+	for len(c.buf) == 0 {
+		chunk, err := c.stream.Recv()
+		if err != nil {
+			return 0, err // io.EOF once the agent closes its side
+		}
+		c.buf = chunk.GetData()
+	}
+	n := copy(p, c.buf)
+	c.buf = c.buf[n:]
+	return n, nil
+}
+
 // ---- Connect (bidirectional stream) -----------------------------------------
 
 func (s *NodeAgentServer) Connect(
 	stream pb.NodeAgentService_ConnectServer,
 ) error {
-	nodeID, err := s.nodeIDFromCtx(stream.Context())
+	tok, err := s.nodeTokenFromCtx(stream.Context())
 	if err != nil {
 		return err
 	}
+	nodeID := tok.NodeID
 
-	ns := &nodeStream{send: make(chan *pb.ServerMessage, 64)}
-	s.register(nodeID, ns)
+	ns := &nodeStream{send: make(chan *pb.ServerMessage, 64), replaced: make(chan struct{})}
+	if !s.register(nodeID, ns) {
+		return status.Error(codes.Unavailable, "server shutting down")
+	}
 	defer func() {
-		s.unregister(nodeID)
+		s.unregister(nodeID, ns)
 		close(ns.send)
-		log.Printf("[gRPC] node %s disconnected", nodeID)
+		logging.FromContext(stream.Context()).Info("node disconnected", "transport", "grpc")
 	}()
 
-	log.Printf("[gRPC] node %s connected", nodeID)
+	logging.FromContext(stream.Context()).Info("node connected", "transport", "grpc")
+
+	// The agent may have missed script changes while it was away
+	ctx, cancel := s.opContext(stream.Context())
+	scripts, err := s.scriptSvc.Manifest(ctx, nodeID)
+	cancel()
+	if err != nil {
+		logging.FromContext(stream.Context()).Error("build script manifest", "error", err)
+	} else {
+		ns.send <- syncScriptsMessage(scripts)
+	}
 
 	// Forward queued ServerMessages to the stream.
 	sendErr := make(chan error, 1)
@@ -166,56 +318,140 @@ func (s *NodeAgentServer) Connect(
 		sendErr <- nil
 	}()
 
-	for {
-		// Check if the send goroutine hit an error.
-		select {
-		case err := <-sendErr:
-			return err
-		default:
-		}
-
-		in, recvErr := stream.Recv()
-		if recvErr != nil {
-			break
-		}
-
-		switch p := in.Payload.(type) {
-		case *pb.NodeMessage_JobResult:
-			r := p.JobResult
-			if err := s.jobSvc.UpdateResult(
-				r.GetJobId(),
-				job.JobStatus(pbJobStatus(r.GetStatus())),
-				r.GetOutput(),
-				r.GetError(),
-			); err != nil {
-				log.Printf("[gRPC] update job %s: %v", r.GetJobId(), err)
-			} else {
-				log.Printf("[gRPC] job %s -> %s (node %s)", r.GetJobId(), r.GetStatus(), nodeID)
+	// Receive in the background too, so a newer stream for the node can end
+	// this one while Recv blocks. Returning cancels the stream, which stops
+	// Recv.
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		for {
+			in, err := stream.Recv()
+			if err != nil {
+				return
 			}
+			s.handleNodeMessage(stream.Context(), nodeID, in)
+		}
+	}()
 
-		case *pb.NodeMessage_Heartbeat:
-			log.Printf("[gRPC] heartbeat from node %s", p.Heartbeat.GetNodeId())
+	select {
+	case err := <-sendErr:
+		return err
+	case <-recvDone:
+		return nil
+	case <-ns.replaced:
+		logging.FromContext(stream.Context()).Info("stream replaced by a newer connection", "transport", "grpc")
+		return status.Error(codes.Aborted, "replaced by a newer connection from the same node")
+	}
+}
 
+// handleNodeMessage handles one message received on the Connect stream of
+// nodeID.
+func (s *NodeAgentServer) handleNodeMessage(ctx context.Context, nodeID string, in *pb.NodeMessage) {
+	switch p := in.Payload.(type) {
+	case *pb.NodeMessage_JobResult:
+		r := p.JobResult
+		opCtx, cancel := s.opContext(ctx)
+		err := s.jobSvc.UpdateResult(opCtx,
+			r.GetJobId(),
+			nodeID,
+			job.JobStatus(pbJobStatus(r.GetStatus())),
+			r.GetOutput(),
+			r.GetError(),
+		)
+		cancel()
+		switch {
+		case errors.Is(err, job.ErrJobNotFound), errors.Is(err, job.ErrInvalidTransition), errors.Is(err, job.ErrInvalidJobData):
+			logging.FromContext(ctx).Warn("job result rejected", "job_id", r.GetJobId(), "status", r.GetStatus().String(), "error", err)
+		case err != nil:
+			logging.FromContext(ctx).Error("update job result", "job_id", r.GetJobId(), "error", err)
 		default:
-			log.Printf("[gRPC] unknown payload from node %s", nodeID)
+			logging.FromContext(ctx).Info("job result", "job_id", r.GetJobId(), "status", r.GetStatus().String())
 		}
+
+	case *pb.NodeMessage_Heartbeat:
+		logging.FromContext(ctx).Debug("heartbeat")
+
+	default:
+		logging.FromContext(ctx).Warn("unknown payload")
 	}
+}
+
+// ---- SyncScripts (implements handler.ScriptSyncer) -------------------------
 
-	return nil
+// SyncScripts pushes the manifest of the scripts a node must have installed.
+func (s *NodeAgentServer) SyncScripts(ctx context.Context, nodeID string, scripts []script.Entry) error {
+	return s.SendToNode(nodeID, syncScriptsMessage(scripts))
+}
+
+func syncScriptsMessage(scripts []script.Entry) *pb.ServerMessage {
+	refs := make([]*pb.ScriptRef, 0, len(scripts))
+	for _, e := range scripts {
+		refs = append(refs, &pb.ScriptRef{Name: e.Name, Version: int32(e.Version), Sha256: e.SHA256})
+	}
+	return &pb.ServerMessage{
+		Payload: &pb.ServerMessage_SyncScripts{
+			SyncScripts: &pb.SyncScriptsPayload{Scripts: refs},
+		},
+	}
 }
 
 // ---- Registry ---------------------------------------------------------------
 
-func (s *NodeAgentServer) register(nodeID string, ns *nodeStream) {
+// register adds the stream of nodeID, ending the node's previous stream if
+// it still has one. It refuses new streams once Drain has been called.
+func (s *NodeAgentServer) register(nodeID string, ns *nodeStream) bool {
 	s.streamsMu.Lock()
 	defer s.streamsMu.Unlock()
+	if s.draining {
+		return false
+	}
+	if old, ok := s.streams[nodeID]; ok {
+		close(old.replaced)
+	}
 	s.streams[nodeID] = ns
+	return true
 }
 
-func (s *NodeAgentServer) unregister(nodeID string) {
+// unregister removes ns unless a newer stream of nodeID already replaced it.
+func (s *NodeAgentServer) unregister(nodeID string, ns *nodeStream) {
 	s.streamsMu.Lock()
 	defer s.streamsMu.Unlock()
-	delete(s.streams, nodeID)
+	if s.streams[nodeID] == ns {
+		delete(s.streams, nodeID)
+		s.jobSvc.NodeDisconnected(nodeID)
+	}
+}
+
+// ---- Shutdown -----------------------------------------------------------------
+
+// Drain prepares the server to stop: new Connect streams and job dispatches
+// are refused, and every connected agent gets a server_shutdown message
+// telling it to reconnect after reconnectAfter. Open streams keep working so
+// agents can still report the results of jobs in progress.
+func (s *NodeAgentServer) Drain(reason string, reconnectAfter time.Duration) {
+	msg := &pb.ServerMessage{
+		Payload: &pb.ServerMessage_ServerShutdown{
+			ServerShutdown: &pb.ServerShutdownPayload{
+				Reason:           reason,
+				ReconnectAfterMs: int32(reconnectAfter.Milliseconds()),
+			},
+		},
+	}
+
+	s.streamsMu.Lock()
+	s.draining = true
+	s.streamsMu.Unlock()
+
+	s.streamsMu.RLock()
+	defer s.streamsMu.RUnlock()
+	for nodeID, ns := range s.streams {
+		select {
+		case ns.send <- msg:
+		default:
+			slog.Warn("send buffer full, shutdown notice dropped", "node_id", nodeID, "transport", "grpc")
+		}
+	}
+	slog.Info("draining: notified connected nodes", "transport", "grpc", "nodes", len(s.streams))
 }
 
 // ---- Proto enum converters --------------------------------------------------
@@ -242,6 +478,7 @@ func pbJobStatus(s pb.JobStatus) string {
 
 // DispatchJob builds a gRPC ServerMessage and pushes it to the connected node.
 func (s *NodeAgentServer) DispatchJob(
+	ctx context.Context,
 	nodeID, jobID, commandName string,
 	commandType nodecommand.CommandType,
 ) error {
@@ -249,12 +486,15 @@ func (s *NodeAgentServer) DispatchJob(
 	if commandType == nodecommand.CommandTypeCustom {
 		ct = pb.CommandType_COMMAND_TYPE_CUSTOM
 	}
+	traceparent, tracestate := tracing.Inject(ctx)
 	msg := &pb.ServerMessage{
 		Payload: &pb.ServerMessage_ExecuteJob{
 			ExecuteJob: &pb.ExecuteJobPayload{
 				JobId:       jobID,
 				CommandName: commandName,
 				CommandType: ct,
+				Traceparent: traceparent,
+				Tracestate:  tracestate,
 			},
 		},
 	}
@@ -0,0 +1,470 @@
+package grpcserver
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	pb "github.com/arturo/autohost-cloud-api/internal/grpc/nodepb"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+type testEnv struct {
+	srv       *NodeAgentServer
+	jobs      *job.Service
+	artifacts *jobartifact.Service
+	cmds      *nodecommand.Service
+	audit     *audit.Service
+	scripts   *script.Service
+	orgID     string
+	nodeID    string
+	token     string
+	conn      *grpc.ClientConn
+}
+
+// newTestEnv serves a NodeAgentServer backed by in-memory repositories over
+// bufconn, with one enrolled node.
+func newTestEnv(t *testing.T) *testEnv {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewDB()
+
+	userID, orgID := memory.SeedOrg(t, db)
+	n := memory.SeedNode(t, db, orgID, userID, "web-1")
+	nodes := node.NewService(memory.NewNodeRepository(db))
+	tokens := nodetoken.NewService(memory.NewNodeTokenRepository(db))
+	plain, hash, err := platform.GenerateTokenApi()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.CreateNodeToken(ctx, n.ID, hash); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	env := &testEnv{
+		jobs:   job.NewService(memory.NewJobRepository(db), nil, job.OutputLimits{}),
+		cmds:   nodecommand.NewService(memory.NewNodeCommandRepository(db)),
+		audit:  audit.NewService(memory.NewAuditRepository(db)),
+		orgID:  orgID,
+		nodeID: n.ID,
+		token:  plain,
+	}
+	env.scripts = script.NewService(memory.NewScriptRepository(db), nodes,
+		nodegroup.NewService(memory.NewNodeGroupRepository(db), nodes))
+	env.artifacts = jobartifact.NewService(memory.NewJobArtifactRepository(db), env.jobs, store,
+		jobartifact.Limits{MaxSize: 1 << 20, Retention: time.Hour})
+	env.srv = NewNodeAgentServer(env.cmds, env.jobs, env.artifacts, env.scripts, tokens, env.audit, time.Second)
+
+	gs := agentsim.StartGRPC(func(s *grpc.Server) { pb.RegisterNodeAgentServiceServer(s, env.srv) })
+	t.Cleanup(gs.Stop)
+	env.conn, err = gs.Dial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { env.conn.Close() })
+	return env
+}
+
+// connect opens a Connect stream and waits until the server has registered
+// it, which the protocol does not acknowledge.
+func (env *testEnv) connect(t *testing.T, ctx context.Context) *agentsim.GRPCAgent {
+	t.Helper()
+	env.srv.streamsMu.RLock()
+	before := env.srv.streams[env.nodeID]
+	env.srv.streamsMu.RUnlock()
+
+	a := agentsim.NewGRPCAgent(env.conn, env.token)
+	if err := a.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	for {
+		env.srv.streamsMu.RLock()
+		ns, ok := env.srv.streams[env.nodeID]
+		env.srv.streamsMu.RUnlock()
+		if ok && ns != before {
+			return a
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("stream never registered")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// waitStatus polls until the job reaches want; results are not acknowledged.
+func (env *testEnv) waitStatus(t *testing.T, ctx context.Context, id string, want job.JobStatus) *job.Job {
+	t.Helper()
+	for {
+		j, err := env.jobs.GetByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if j.Status == want {
+			return j
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("job status = %s, want %s", j.Status, want)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestRegisterCommands(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := agentsim.NewGRPCAgent(env.conn, env.token)
+	n, err := a.RegisterCommandsCount(ctx,
+		agentsim.Command{Name: "uptime", Description: "Show uptime", Type: nodecommand.CommandTypeDefault},
+		agentsim.Command{Name: "backup", Type: nodecommand.CommandTypeCustom, ScriptPath: "/opt/backup.sh"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("registered = %d, want 2", n)
+	}
+
+	cmds, err := env.cmds.ListByNode(ctx, env.nodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || cmds[0].Name != "backup" || cmds[0].Type != nodecommand.CommandTypeCustom ||
+		cmds[0].ScriptPath != "/opt/backup.sh" || cmds[1].Description != "Show uptime" {
+		t.Errorf("stored commands = %+v", cmds)
+	}
+
+	events, _, err := env.audit.List(ctx, audit.Filter{Action: audit.ActionCommandRegister})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].ActorID != env.nodeID {
+		t.Errorf("audit events = %+v", events)
+	}
+}
+
+func TestUploadArtifact(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j, err := env.jobs.Dispatch(ctx, env.nodeID, "backup", nodecommand.CommandTypeCustom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := bytes.Repeat([]byte("dump"), 10000)
+	sum := sha256.Sum256(data)
+	a := agentsim.NewGRPCAgent(env.conn, env.token)
+
+	t.Run("chunked", func(t *testing.T) {
+		resp, err := a.UploadArtifact(ctx, agentsim.Artifact{
+			JobID: j.ID, Name: "db.sql", ContentType: "application/sql", SHA256: hex.EncodeToString(sum[:]), Data: data,
+		}, 4096)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.GetName() != "db.sql" || resp.GetSize() != int64(len(data)) || resp.GetSha256() != hex.EncodeToString(sum[:]) {
+			t.Errorf("response = %v", resp)
+		}
+
+		art, rc, err := env.artifacts.Open(ctx, j.ID, "db.sql")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		got, _ := io.ReadAll(rc)
+		if !bytes.Equal(got, data) || art.ContentType != "application/sql" {
+			t.Errorf("stored artifact = %+v, %d bytes", art, len(got))
+		}
+
+		events, _, err := env.audit.List(ctx, audit.Filter{Action: audit.ActionJobArtifactUpload})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 1 || events[0].ActorID != env.nodeID || events[0].TargetID != j.ID {
+			t.Errorf("audit events = %+v", events)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		_, err := a.UploadArtifact(ctx, agentsim.Artifact{
+			JobID: j.ID, Name: "other.sql", SHA256: hex.EncodeToString(make([]byte, 32)), Data: data,
+		}, 4096)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("UploadArtifact = %v, want InvalidArgument", err)
+		}
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		_, err := a.UploadArtifact(ctx, agentsim.Artifact{JobID: j.ID, Name: "big", Data: make([]byte, 1<<20+1)}, 64<<10)
+		if status.Code(err) != codes.InvalidArgument {
+			t.Errorf("UploadArtifact = %v, want InvalidArgument", err)
+		}
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		_, err := a.UploadArtifact(ctx, agentsim.Artifact{JobID: "00000000-0000-0000-0000-000000000000", Name: "x", Data: data}, 4096)
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("UploadArtifact = %v, want NotFound", err)
+		}
+	})
+}
+
+func TestUnauthenticated(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := agentsim.NewGRPCAgent(env.conn, platform.TokenApiPrefix+"unknown").RegisterCommandsCount(ctx,
+		agentsim.Command{Name: "uptime", Type: nodecommand.CommandTypeDefault})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RegisterCommands error = %v, want Unauthenticated", err)
+	}
+
+	a := agentsim.NewGRPCAgent(env.conn, "")
+	if err := a.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	if err := a.Wait(); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Connect error = %v, want Unauthenticated", err)
+	}
+}
+
+func TestDispatchAndReport(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	j, err := env.jobs.Dispatch(ctx, env.nodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.srv.DispatchJob(ctx, env.nodeID, j.ID, j.CommandName, j.CommandType); status.Code(err) != codes.NotFound {
+		t.Errorf("dispatch to disconnected node = %v, want NotFound", err)
+	}
+
+	a := env.connect(t, ctx)
+	if err := env.srv.DispatchJob(ctx, env.nodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.NextJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != j.ID || got.CommandName != "uptime" || got.CommandType != nodecommand.CommandTypeDefault {
+		t.Errorf("received job = %+v", got)
+	}
+
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusRunning}); err != nil {
+		t.Fatal(err)
+	}
+	env.waitStatus(t, ctx, j.ID, job.StatusRunning)
+	if err := a.Heartbeat(); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusFailed, Output: "partial", Error: "exit status 1"}); err != nil {
+		t.Fatal(err)
+	}
+	done := env.waitStatus(t, ctx, j.ID, job.StatusFailed)
+	if done.Output != "partial" || done.Error != "exit status 1" || done.FinishedAt == nil {
+		t.Errorf("finished job = %+v", done)
+	}
+}
+
+// TestDisconnectReleasesInFlightJobs covers an agent that goes away before
+// reporting a job: shutdown must not wait for its result.
+func TestDisconnectReleasesInFlightJobs(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := env.connect(t, ctx)
+	j, err := env.jobs.Dispatch(ctx, env.nodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.jobs.Deliver(ctx, j, func(ctx context.Context) error {
+		return env.srv.DispatchJob(ctx, env.nodeID, j.ID, j.CommandName, j.CommandType)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.NextJob(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := env.jobs.InFlight(); n != 1 {
+		t.Fatalf("in flight = %d, want 1", n)
+	}
+
+	a.Close()
+	if err := env.jobs.WaitInFlight(ctx); err != nil {
+		t.Fatalf("job still in flight after its node disconnected: %v", err)
+	}
+}
+
+// TestReconnectReplacesStream covers an agent that reconnects before the
+// server notices its old stream is gone: the new stream takes over, and the
+// end of the old one must not unregister it.
+func TestReconnectReplacesStream(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	old := env.connect(t, ctx)
+	current := env.connect(t, ctx)
+
+	ended := make(chan error, 1)
+	go func() { ended <- old.Wait() }()
+	select {
+	case err := <-ended:
+		if status.Code(err) != codes.Aborted {
+			t.Errorf("replaced stream ended with %v, want Aborted", err)
+		}
+	case <-ctx.Done():
+		t.Fatal("replaced stream still open")
+	}
+	old.Close()
+
+	j, err := env.jobs.Dispatch(ctx, env.nodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.srv.DispatchJob(ctx, env.nodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+		t.Fatalf("dispatch after the old stream ended: %v", err)
+	}
+	if got, err := current.NextJob(ctx); err != nil || got.ID != j.ID {
+		t.Errorf("current stream received %+v, %v", got, err)
+	}
+}
+
+func TestServeAnswersJobs(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := env.connect(t, ctx)
+	serveCtx, stop := context.WithCancel(ctx)
+	served := make(chan error, 1)
+	go func() {
+		served <- agentsim.Serve(serveCtx, a, func(j agentsim.Job) agentsim.Result {
+			return agentsim.Result{Status: job.StatusCompleted, Output: "ran " + j.CommandName}
+		})
+	}()
+
+	names := []string{"uptime", "df", "whoami"}
+	var ids []string
+	for _, name := range names {
+		j, err := env.jobs.Dispatch(ctx, env.nodeID, name, nodecommand.CommandTypeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.srv.DispatchJob(ctx, env.nodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, j.ID)
+	}
+	for i, id := range ids {
+		j := env.waitStatus(t, ctx, id, job.StatusCompleted)
+		if want := "ran " + names[i]; j.Output != want {
+			t.Errorf("output = %q, want %q", j.Output, want)
+		}
+	}
+
+	stop()
+	if err := <-served; !errors.Is(err, context.Canceled) {
+		t.Errorf("Serve = %v, want context.Canceled", err)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	a := env.connect(t, ctx)
+	env.srv.Drain("deploy", 3*time.Second)
+
+	s, err := a.NextShutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Reason != "deploy" || s.ReconnectAfter != 3*time.Second {
+		t.Errorf("shutdown notice = %+v", s)
+	}
+	if err := env.srv.DispatchJob(ctx, env.nodeID, "job", "uptime", nodecommand.CommandTypeDefault); status.Code(err) != codes.Unavailable {
+		t.Errorf("dispatch while draining = %v, want Unavailable", err)
+	}
+
+	late := agentsim.NewGRPCAgent(env.conn, env.token)
+	if err := late.Connect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	defer late.Close()
+	if err := late.Wait(); status.Code(err) != codes.Unavailable {
+		t.Errorf("Connect while draining = %v, want Unavailable", err)
+	}
+}
+
+func TestSyncScripts(t *testing.T) {
+	env := newTestEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	s, err := env.scripts.Create(ctx, env.orgID, nil, "backup.sh", "", "#!/bin/sh\necho ok\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.scripts.Assign(ctx, env.orgID, s.ID, script.Target{NodeID: env.nodeID}); err != nil {
+		t.Fatal(err)
+	}
+	if err := env.srv.SyncScripts(ctx, env.nodeID, nil); status.Code(err) != codes.NotFound {
+		t.Errorf("sync to disconnected node = %v, want NotFound", err)
+	}
+
+	// The manifest is pushed as soon as the node connects
+	a := env.connect(t, ctx)
+	want := script.Entry{Name: "backup.sh", Version: 1, SHA256: s.SHA256}
+	got, err := a.NextSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0] != want {
+		t.Errorf("manifest on connect = %+v, want [%+v]", got, want)
+	}
+
+	if err := env.srv.SyncScripts(ctx, env.nodeID, []script.Entry{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := a.NextSync(ctx); err != nil || len(got) != 0 {
+		t.Errorf("empty manifest = %+v, %v", got, err)
+	}
+}
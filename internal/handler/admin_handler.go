@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// AdminHandler exposes operator endpoints that act on the running process
+// rather than on tenant data.
+type AdminHandler struct{}
+
+func NewAdminHandler() *AdminHandler {
+	return &AdminHandler{}
+}
+
+func (h *AdminHandler) Routes(adminAuth func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(adminAuth)
+	r.Get("/log-level", h.GetLogLevel)
+	r.Put("/log-level", h.SetLogLevel)
+	return r
+}
+
+type logLevelBody struct {
+	Level string `json:"level"`
+}
+
+func levelBody(l slog.Level) logLevelBody {
+	return logLevelBody{Level: strings.ToLower(l.String())}
+}
+
+// GetLogLevel returns the current minimum log level.
+// GET /admin/log-level
+func (h *AdminHandler) GetLogLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levelBody(logging.Level()))
+}
+
+// SetLogLevel changes the minimum level of every logger in the process,
+// including the ones already attached to open agent connections.
+// PUT /admin/log-level
+func (h *AdminHandler) SetLogLevel(w http.ResponseWriter, r *http.Request) {
+	var in logLevelBody
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+	l, err := logging.ParseLevel(in.Level)
+	if err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "level must be one of debug, info, warn, error")
+		return
+	}
+
+	prev := logging.Level()
+	logging.SetLevel(l)
+	// Warn so the change shows up unless the new level is error
+	logging.FromContext(r.Context()).Warn("log level changed", "from", prev.String(), "to", l.String())
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(levelBody(l))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const testAdminToken = "0123456789abcdef0123456789abcdef"
+
+func TestAdminLogLevel(t *testing.T) {
+	t.Cleanup(func() { logging.SetLevel(slog.LevelInfo) })
+	h := NewRouter(&Config{Keys: platform.NewKeyRing(platform.JWTConfig{}), AdminToken: testAdminToken}).HTTP
+
+	do := func(method, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/admin/log-level", strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	if rec := do(http.MethodGet, "wrong", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("wrong token: status = %d", rec.Code)
+	}
+	if rec := do(http.MethodPut, testAdminToken, `{"level":"verbose"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown level: status = %d", rec.Code)
+	}
+
+	rec := do(http.MethodPut, testAdminToken, `{"level":"debug"}`)
+	if rec.Code != http.StatusOK || logging.Level() != slog.LevelDebug {
+		t.Fatalf("set debug: status = %d, level = %s", rec.Code, logging.Level())
+	}
+	var got logLevelBody
+	if err := json.NewDecoder(do(http.MethodGet, testAdminToken, "").Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Level != "debug" {
+		t.Errorf("GET level = %q", got.Level)
+	}
+}
+
+func TestAdminDisabledWithoutToken(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/log-level", nil)
+	req.Header.Set("Authorization", "Bearer anything")
+	newTestRouter(t).ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+}
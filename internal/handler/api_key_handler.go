@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+type createAPIKeyRequest struct {
+	Name      string                    `json:"name"`
+	Scopes    []organization.Permission `json:"scopes"`
+	ExpiresAt *time.Time                `json:"expires_at"`
+}
+
+// createAPIKeyResponse incluye la clave en claro, que solo se muestra una vez
+type createAPIKeyResponse struct {
+	*apikey.APIKey
+	Key string `json:"key"`
+}
+
+// CreateAPIKey emite una API key personal
+// POST /v1/auth/api-keys
+func (h *AuthHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var in createAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	plain, key, err := h.apiKeys.Create(r.Context(), claims.UserID, in.Name, in.Scopes, in.ExpiresAt)
+	if errors.Is(err, apikey.ErrInvalidAPIKeyData) {
+		apperr.Respond(w, r, apperr.InvalidArgument, "name required; scopes must be known permissions; expires_at must be in the future")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("create api key", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+
+	h.recordPersonalEvent(r, claims.UserID, audit.Event{
+		Action:     audit.ActionAPIKeyCreate,
+		TargetType: "api_key",
+		TargetID:   key.ID,
+		Metadata:   map[string]any{"name": key.Name, "scopes": key.Scopes},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(createAPIKeyResponse{APIKey: key, Key: plain})
+}
+
+// ListAPIKeys lista las API keys activas del usuario
+// GET /v1/auth/api-keys
+func (h *AuthHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	keys, err := h.apiKeys.List(r.Context(), claims.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list api keys", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+// RevokeAPIKey revoca una API key del usuario
+// DELETE /v1/auth/api-keys/{keyID}
+func (h *AuthHandler) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	keyID, ok := uuidParam(w, r, "keyID", "api key")
+	if !ok {
+		return
+	}
+	if err := h.apiKeys.Revoke(r.Context(), claims.UserID, keyID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordPersonalEvent(r, claims.UserID, audit.Event{
+		Action:     audit.ActionAPIKeyRevoke,
+		TargetType: "api_key",
+		TargetID:   keyID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	chimiddleware "github.com/go-chi/chi/middleware"
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// AuditHandler exposes the audit log of the active organization.
+type AuditHandler struct {
+	service *audit.Service
+}
+
+func NewAuditHandler(service *audit.Service) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+func (h *AuditHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
+	r := chi.NewRouter()
+	r.Use(authMiddleware, authz.Require(organization.PermAuditRead))
+	r.Get("/", h.List)
+	r.Get("/verify", h.Verify)
+	return r
+}
+
+type auditPage struct {
+	Events     []*audit.Event `json:"events"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// List returns audit events, newest first, with cursor pagination.
+// GET /v1/audit?actor_type=&actor_id=&action=&target_type=&target_id=&outcome=&since=&until=&limit=&cursor=
+func (h *AuditHandler) List(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filter{
+		OrganizationID: membership.OrganizationID,
+		ActorType:      audit.ActorType(q.Get("actor_type")),
+		ActorID:        q.Get("actor_id"),
+		Action:         q.Get("action"),
+		TargetType:     q.Get("target_type"),
+		TargetID:       q.Get("target_id"),
+		Outcome:        audit.Outcome(q.Get("outcome")),
+	}
+
+	var err error
+	if f.Since, err = parseTimeParam(q.Get("since")); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid since (RFC 3339 expected)")
+		return
+	}
+	if f.Until, err = parseTimeParam(q.Get("until")); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid until (RFC 3339 expected)")
+		return
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit < 1 {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid limit")
+			return
+		}
+	}
+	if c := q.Get("cursor"); c != "" {
+		if f.BeforeID, err = decodeAuditCursor(c); err != nil {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid cursor")
+			return
+		}
+	}
+
+	events, next, err := h.service.List(r.Context(), f)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list audit events", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list audit events")
+		return
+	}
+
+	page := auditPage{Events: events}
+	if page.Events == nil {
+		page.Events = []*audit.Event{}
+	}
+	if next > 0 {
+		page.NextCursor = encodeAuditCursor(next)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Verify recomputes the organization's hash chain.
+// GET /v1/audit/verify
+func (h *AuditHandler) Verify(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	res, err := h.service.Verify(r.Context(), membership.OrganizationID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("verify audit chain", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not verify audit log")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+func encodeAuditCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(id, 10)))
+}
+
+func decodeAuditCursor(c string) (int64, error) {
+	b, err := base64.RawURLEncoding.DecodeString(c)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(string(b), 10, 64)
+}
+
+func parseTimeParam(v string) (*time.Time, error) {
+	if v == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// auditRecordTimeout bounds the write of one audit event.
+const auditRecordTimeout = 5 * time.Second
+
+// recordAudit fills in the actor, organization, client IP and request ID from
+// the request and appends e to the audit log. Failures are logged and never
+// surfaced to the client.
+func recordAudit(svc *audit.Service, r *http.Request, e audit.Event) {
+	ctx := r.Context()
+	if e.ActorType == "" {
+		if claims := middleware.GetClaims(ctx); claims != nil {
+			e.ActorType, e.ActorID = audit.ActorUser, claims.UserID
+		} else if nt := middleware.GetNodeToken(ctx); nt != nil {
+			e.ActorType, e.ActorID = audit.ActorNode, nt.NodeID
+		} else {
+			e.ActorType = audit.ActorSystem
+		}
+	}
+	if e.OrganizationID == nil {
+		if m := middleware.GetMembership(ctx); m != nil {
+			e.OrganizationID = &m.OrganizationID
+		} else if nt := middleware.GetNodeToken(ctx); nt != nil {
+			e.OrganizationID = &nt.OrganizationID
+		}
+	}
+	if key := middleware.GetAPIKey(ctx); key != nil {
+		if e.Metadata == nil {
+			e.Metadata = map[string]any{}
+		}
+		e.Metadata["api_key_id"] = key.ID
+	}
+	e.IP = clientIP(r)
+	e.RequestID = chimiddleware.GetReqID(ctx)
+
+	// The action already happened: record it even if the client has gone
+	// away, with a deadline of its own.
+	recordCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), auditRecordTimeout)
+	defer cancel()
+	if err := svc.Record(recordCtx, e); err != nil {
+		logging.FromContext(ctx).Error("record audit event", "action", e.Action, "error", err)
+	}
+}
@@ -2,36 +2,102 @@ package handler
 
 import (
 	"encoding/json"
+	"errors"
 	"net"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/arturo/autohost-cloud-api/internal/platform"
 	"github.com/go-chi/chi/v5"
 )
 
 type AuthHandler struct {
-	service *auth.Service
-	repo    auth.Repository
+	service      *auth.Service
+	repo         auth.Repository
+	keys         *platform.KeyRing
+	apiKeys      *apikey.Service
+	mfa          *mfa.Service
+	oidc         *oidc.Service
+	orgService   *organization.Service
+	limiter      *ratelimit.Service
+	auditService *audit.Service
 }
 
-func NewAuthHandler(service *auth.Service, repo auth.Repository) *AuthHandler {
+// Límites de las rutas públicas de autenticación
+var (
+	authIPLimit        = ratelimit.PerMinute(20)
+	loginEmailLimit    = ratelimit.PerMinute(10)
+	emailLinkIPLimit   = ratelimit.PerMinute(5)
+	emailLinkLimit     = ratelimit.Limit{Every: 20 * time.Minute, Burst: 3}
+	redeemTokenIPLimit = ratelimit.PerMinute(10)
+)
+
+func NewAuthHandler(service *auth.Service, repo auth.Repository, keys *platform.KeyRing, apiKeys *apikey.Service, mfaService *mfa.Service, oidcService *oidc.Service, orgService *organization.Service, limiter *ratelimit.Service, auditService *audit.Service) *AuthHandler {
 	return &AuthHandler{
-		service: service,
-		repo:    repo,
+		service:      service,
+		repo:         repo,
+		keys:         keys,
+		apiKeys:      apiKeys,
+		mfa:          mfaService,
+		oidc:         oidcService,
+		orgService:   orgService,
+		limiter:      limiter,
+		auditService: auditService,
 	}
 }
 
-func (h *AuthHandler) Routes() chi.Router {
+func (h *AuthHandler) Routes(authMiddleware func(http.Handler) http.Handler) chi.Router {
 	r := chi.NewRouter()
-	r.Post("/register", h.Register)
-	r.Post("/login", h.Login)
+	r.Group(func(pub chi.Router) {
+		pub.Use(middleware.RateLimitByIP(h.limiter, "auth", authIPLimit))
+		pub.Post("/register", h.Register)
+		pub.Post("/login", h.Login)
+		pub.Post("/login/mfa", h.LoginMFA)
+		pub.Get("/oidc/start", h.OIDCStart)
+		pub.Post("/oidc/callback", h.OIDCCallback)
+	})
 	r.Post("/refresh", h.Refresh)
 	r.Post("/logout", h.Logout)
+	r.With(middleware.RateLimitByIP(h.limiter, "email_link", emailLinkIPLimit)).Post("/forgot-password", h.ForgotPassword)
+	r.Group(func(redeem chi.Router) {
+		redeem.Use(middleware.RateLimitByIP(h.limiter, "redeem_token", redeemTokenIPLimit))
+		redeem.Post("/verify-email", h.VerifyEmail)
+		redeem.Post("/reset-password", h.ResetPassword)
+	})
 	r.Group(func(pr chi.Router) {
-		pr.Use(middleware.Auth)
+		pr.Use(authMiddleware)
 		pr.Get("/me", h.Me)
+		pr.With(middleware.DenyAPIKeys).Post("/verify-email/resend", h.ResendVerification)
+		pr.With(middleware.DenyAPIKeys).Post("/change-password", h.ChangePassword)
+		pr.With(middleware.DenyAPIKeys).Get("/sessions", h.ListSessions)
+		pr.With(middleware.DenyAPIKeys).Delete("/sessions/{sessionID}", h.RevokeSession)
+
+		pr.Route("/mfa", func(mr chi.Router) {
+			mr.Use(middleware.DenyAPIKeys)
+			mr.Get("/", h.MFAStatus)
+			mr.Post("/totp", h.BeginTOTP)
+			mr.Post("/totp/confirm", h.ConfirmTOTP)
+			mr.Post("/disable", h.DisableMFA)
+			mr.Post("/recovery-codes", h.RegenerateRecoveryCodes)
+		})
+
+		pr.Route("/api-keys", func(kr chi.Router) {
+			kr.Use(middleware.DenyAPIKeys)
+			kr.Post("/", h.CreateAPIKey)
+			kr.Get("/", h.ListAPIKeys)
+			kr.Delete("/{keyID}", h.RevokeAPIKey)
+		})
 	})
 	return r
 }
@@ -45,31 +111,47 @@ type creds struct {
 func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 	var in creds
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, "bad json", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
 		return
 	}
 
 	if in.Email == "" || in.Password == "" {
-		http.Error(w, "email and password required", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "email and password required")
 		return
 	}
 
 	// Usar el servicio para registrar
-	userID, err := h.service.Register(in.Email, in.Name, in.Password)
-	if err == auth.ErrUserAlreadyExists {
-		http.Error(w, "email already exists", http.StatusConflict)
+	userID, err := h.service.Register(r.Context(), in.Email, in.Name, in.Password)
+	if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
+
+	// Cada usuario nuevo recibe su organización personal
+	orgName := in.Name
+	if orgName == "" {
+		orgName = in.Email
+	}
+	org, err := h.orgService.EnsurePersonal(r.Context(), userID, orgName)
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("create personal organization", "user_id", userID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
 		return
 	}
 
-	// Generar tokens
-	platform.SignAccessToken(userID, in.Email)
-	_, rtHash := platform.MakeRefreshPair()
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType:      audit.ActorUser,
+		ActorID:        userID,
+		OrganizationID: &org.ID,
+		Action:         audit.ActionAuthRegister,
+		TargetType:     "user",
+		TargetID:       userID,
+	})
 
-	_ = h.repo.StoreRefreshToken(userID, rtHash, r.UserAgent(), clientIP(r))
+	// La cuenta ya existe: si el email falla el usuario puede pedir otro
+	if err := h.service.SendVerification(r.Context(), userID); err != nil {
+		logging.FromContext(r.Context()).Error("send verification email", "user_id", userID, "error", err)
+	}
 
 	w.WriteHeader(http.StatusCreated)
 }
@@ -77,24 +159,124 @@ func (h *AuthHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
 	var in creds
 	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
-		http.Error(w, "bad json", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	// Límite por cuenta, además del de IP, contra ataques distribuidos
+	if !h.allowEmail(w, r, "login", in.Email, loginEmailLimit) {
+		return
+	}
+	if locked, err := h.limiter.LockedFor(r.Context(), in.Email); err != nil {
+		logging.FromContext(r.Context()).Error("check login lockout", "email", in.Email, "error", err)
+	} else if locked > 0 {
+		h.recordLoginFailure(r, in.Email, "account locked")
+		apperr.TooManyRequests(w, r, locked)
+		return
+	}
+
+	user, err := h.service.Login(r.Context(), in.Email, in.Password)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		if _, err := h.limiter.LoginFailed(r.Context(), in.Email); err != nil {
+			logging.FromContext(r.Context()).Error("record failed login", "email", in.Email, "error", err)
+		}
+		h.recordLoginFailure(r, in.Email, "")
+		apperr.Respond(w, r, apperr.Unauthenticated, "invalid credentials")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("login", "email", in.Email, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+
+	// El bloqueo se levanta al emitir los tokens, no aquí: con MFA aún falta
+	// el segundo factor
+	h.completeLogin(w, r, user, nil)
+}
+
+func (h *AuthHandler) recordLoginFailure(r *http.Request, email, reason string) {
+	metadata := map[string]any{"email": email}
+	if reason != "" {
+		metadata["reason"] = reason
+	}
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType: audit.ActorUser,
+		Action:    audit.ActionAuthLogin,
+		Outcome:   audit.OutcomeFailure,
+		Metadata:  metadata,
+	})
+}
+
+// allowEmail aplica el límite name a la cuenta email y responde 429 si se
+// agotó. Si el almacén falla deja pasar la petición.
+func (h *AuthHandler) allowEmail(w http.ResponseWriter, r *http.Request, name, email string, limit ratelimit.Limit) bool {
+	key := name + ":email:" + strings.ToLower(strings.TrimSpace(email))
+	res, err := h.limiter.Allow(r.Context(), key, limit)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("rate limit store failed, allowing request", "limit", name, "error", err)
+		return true
+	}
+	if !res.Allowed {
+		apperr.TooManyRequests(w, r, res.RetryAfter)
+		return false
+	}
+	return true
+}
+
+// completeLogin emite los tokens de un usuario ya identificado o, con MFA
+// activo, el desafío del segundo paso
+func (h *AuthHandler) completeLogin(w http.ResponseWriter, r *http.Request, user *auth.User, metadata map[string]any) {
+	mfaEnabled, err := h.mfa.IsEnabled(r.Context(), user.ID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("get mfa status", "user_id", user.ID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+	if mfaEnabled {
+		token, expiresAt, err := h.mfa.StartChallenge(r.Context(), user.ID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("start mfa challenge", "user_id", user.ID, "error", err)
+			apperr.Respond(w, r, apperr.Internal, "internal error")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"mfa_required": true,
+			"mfa_token":    token,
+			"expires_at":   expiresAt,
+		})
 		return
 	}
 
-	user, err := h.service.Login(in.Email, in.Password)
-	if err == auth.ErrInvalidCredentials {
-		http.Error(w, "invalid credentials", http.StatusUnauthorized)
+	h.issueTokens(w, r, user, metadata)
+}
+
+// issueTokens abre una sesión nueva y responde con access y refresh token.
+// Es el final de todo login correcto, así que levanta el bloqueo de la cuenta.
+func (h *AuthHandler) issueTokens(w http.ResponseWriter, r *http.Request, user *auth.User, metadata map[string]any) {
+	if err := h.limiter.LoginSucceeded(r.Context(), user.Email); err != nil {
+		logging.FromContext(r.Context()).Error("reset login lockout", "user_id", user.ID, "error", err)
+	}
+
+	access, err := h.keys.SignAccessToken(user.ID, user.Email)
+	if err != nil {
+		apperr.Respond(w, r, apperr.Internal, "internal error")
 		return
 	}
+	rt, err := h.service.StartSession(r.Context(), user.ID, r.UserAgent(), clientIP(r))
 	if err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("start session", "user_id", user.ID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
 		return
 	}
 
-	// Generar tokens
-	access, _ := platform.SignAccessToken(user.ID, user.Email)
-	rt, rtHash := platform.MakeRefreshPair()
-	_ = h.repo.StoreRefreshToken(user.ID, rtHash, r.UserAgent(), clientIP(r))
+	h.recordPersonalEvent(r, user.ID, audit.Event{
+		Action:     audit.ActionAuthLogin,
+		TargetType: "user",
+		TargetID:   user.ID,
+		Metadata:   metadata,
+	})
 
 	// BFF pattern: devolver tokens como JSON — Next.js es el único que setea cookies
 	w.Header().Set("Content-Type", "application/json")
@@ -111,33 +293,45 @@ func (h *AuthHandler) Refresh(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.RefreshToken == "" {
-		http.Error(w, "no refresh", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "no refresh")
 		return
 	}
 
-	oldHash := platform.HashRefreshToken(body.RefreshToken)
-
-	// Buscar el userID asociado al refresh token en BD
-	userID, err := h.repo.FindRefreshToken(oldHash)
-	if err != nil {
-		http.Error(w, "invalid or expired refresh token", http.StatusUnauthorized)
+	old, rt, err := h.service.Rotate(r.Context(), body.RefreshToken, r.UserAgent(), clientIP(r))
+	switch {
+	case errors.Is(err, auth.ErrRefreshTokenReused):
+		// Toda la sesión queda revocada: el token pudo haber sido robado
+		h.recordUserEvent(r, old.UserID, audit.ActionAuthRefreshReuse, audit.OutcomeFailure)
+		apperr.Respond(w, r, apperr.Unauthenticated, "invalid or expired refresh token")
+		return
+	case errors.Is(err, auth.ErrInvalidRefreshToken), errors.Is(err, auth.ErrRefreshTokenExpired):
+		recordAudit(h.auditService, r, audit.Event{
+			ActorType: audit.ActorUser,
+			Action:    audit.ActionAuthRefresh,
+			Outcome:   audit.OutcomeFailure,
+		})
+		apperr.Respond(w, r, apperr.Unauthenticated, "invalid or expired refresh token")
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("rotate refresh token", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
 		return
 	}
 
 	// Buscar datos del usuario para incluirlos en el nuevo access token
-	user, err := h.repo.FindUserByID(userID)
+	user, err := h.repo.FindUserByID(r.Context(), old.UserID)
 	if err != nil || user == nil {
-		http.Error(w, "user not found", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "user not found")
 		return
 	}
 
-	// Revocar el token viejo (rotación)
-	_ = h.repo.RevokeRefreshToken(userID, oldHash)
+	access, err := h.keys.SignAccessToken(user.ID, user.Email)
+	if err != nil {
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
 
-	// Emitir nuevos tokens
-	access, _ := platform.SignAccessToken(user.ID, user.Email)
-	rt, rtHash := platform.MakeRefreshPair()
-	_ = h.repo.StoreRefreshToken(user.ID, rtHash, r.UserAgent(), clientIP(r))
+	h.recordUserEvent(r, user.ID, audit.ActionAuthRefresh, audit.OutcomeSuccess)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -153,8 +347,12 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 		RefreshToken string `json:"refresh_token"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&body); err == nil && body.RefreshToken != "" {
-		if userID, _, err := platform.ParseRefreshToken(body.RefreshToken); err == nil {
-			_ = h.repo.RevokeRefreshToken(userID, platform.HashRefreshToken(body.RefreshToken))
+		userID, err := h.service.Logout(r.Context(), body.RefreshToken)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("logout", "error", err)
+		}
+		if userID != "" && err == nil {
+			h.recordUserEvent(r, userID, audit.ActionAuthLogout, audit.OutcomeSuccess)
 		}
 	}
 	// Next.js es el responsable de borrar las cookies — Go solo responde 204
@@ -164,19 +362,97 @@ func (h *AuthHandler) Logout(w http.ResponseWriter, r *http.Request) {
 func (h *AuthHandler) Me(w http.ResponseWriter, r *http.Request) {
 	claims := middleware.GetClaims(r.Context())
 	if claims == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	user, err := h.repo.FindUserByID(r.Context(), claims.UserID)
+	if err != nil || user == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 	json.NewEncoder(w).Encode(map[string]any{
-		"user_id": claims.UserID,
-		"email":   claims.Email,
+		"user_id":        claims.UserID,
+		"email":          claims.Email,
+		"email_verified": user.EmailVerifiedAt != nil,
 	})
 }
 
-func clientIP(r *http.Request) string {
-	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+// ListSessions lista las sesiones activas del usuario autenticado
+func (h *AuthHandler) ListSessions(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	sessions, err := h.service.ListSessions(r.Context(), claims.UserID)
 	if err != nil {
-		return ""
+		logging.FromContext(r.Context()).Error("list sessions", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+	if sessions == nil {
+		sessions = []*auth.Session{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sessions)
+}
+
+// RevokeSession cierra una de las sesiones del usuario autenticado
+func (h *AuthHandler) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	sessionID, ok := uuidParam(w, r, "sessionID", "session")
+	if !ok {
+		return
+	}
+
+	if err := h.service.RevokeSession(r.Context(), claims.UserID, sessionID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordPersonalEvent(r, claims.UserID, audit.Event{
+		Action:     audit.ActionAuthSessionRevoke,
+		TargetType: "session",
+		TargetID:   sessionID,
+	})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// recordUserEvent audits an auth event of userID in their personal organization.
+func (h *AuthHandler) recordUserEvent(r *http.Request, userID, action string, outcome audit.Outcome) {
+	h.recordPersonalEvent(r, userID, audit.Event{
+		Action:     action,
+		Outcome:    outcome,
+		TargetType: "user",
+		TargetID:   userID,
+	})
+}
+
+// recordPersonalEvent audits e as done by userID in their personal organization.
+func (h *AuthHandler) recordPersonalEvent(r *http.Request, userID string, e audit.Event) {
+	e.ActorType, e.ActorID = audit.ActorUser, userID
+	if org, err := h.orgService.Personal(r.Context(), userID); err == nil {
+		e.OrganizationID = &org.ID
+	}
+	recordAudit(h.auditService, r, e)
+}
+
+// clientIP devuelve la IP del cliente. middleware.RealIP deja RemoteAddr sin
+// puerto cuando la toma de X-Forwarded-For / X-Real-IP.
+func clientIP(r *http.Request) string {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return ip
+	}
+	if ip := net.ParseIP(r.RemoteAddr); ip != nil {
+		return ip.String()
 	}
-	return ip
+	return ""
 }
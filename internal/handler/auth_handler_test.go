@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+const testEmail, testPassword = "owner@example.com", "correct-horse"
+
+// wrongCode has the length of a TOTP code but never matches one, which are
+// all digits.
+const wrongCode = "abcdef"
+
+// authEnv serves the auth routes for a user with a password and MFA enabled.
+type authEnv struct {
+	srv    *httptest.Server
+	keys   *platform.KeyRing
+	mfa    *mfa.Service
+	userID string
+	secret string
+	mail   mailbox
+}
+
+// mailbox keeps the last message sent
+type mailbox struct{ last platform.Mail }
+
+func (m *mailbox) Send(_ context.Context, mail platform.Mail) error {
+	m.last = mail
+	return nil
+}
+
+func newAuthEnv(t *testing.T) *authEnv {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewDB()
+	authRepo := memory.NewAuthRepository(db)
+	env := &authEnv{mfa: mfa.NewService(memory.NewMFARepository(db), make([]byte, 32), "test")}
+
+	hash, err := platform.HashPassword(testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if env.userID, err = authRepo.CreateUser(ctx, testEmail, "Owner", hash); err != nil {
+		t.Fatal(err)
+	}
+	if env.secret, _, err = env.mfa.BeginEnrollment(ctx, env.userID, testEmail); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.mfa.ConfirmEnrollment(ctx, env.userID, env.code(t, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	env.keys = platform.NewKeyRing(platform.JWTConfig{Issuer: "test", Audience: "test", AccessTTL: time.Minute})
+	signing, err := platform.GenerateSigningKey(platform.AlgRS256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	env.keys.SetKeys(signing, nil)
+
+	h := NewAuthHandler(auth.NewService(authRepo, &env.mail, "http://localhost:3000", time.Hour), authRepo, env.keys,
+		apikey.NewService(memory.NewAPIKeyRepository(db)), env.mfa, nil,
+		organization.NewService(memory.NewOrganizationRepository(db)),
+		ratelimit.NewService(memory.NewRateLimitRepository(db)), audit.NewService(memory.NewAuditRepository(db)))
+	env.srv = httptest.NewServer(h.Routes(middleware.Auth(env.keys, nil)))
+	t.Cleanup(env.srv.Close)
+	return env
+}
+
+// code returns the TOTP code steps intervals from now; the current one is
+// spent confirming the enrollment.
+func (env *authEnv) code(t *testing.T, steps int) string {
+	t.Helper()
+	c, err := platform.TOTPCode(env.secret, time.Now().Add(time.Duration(steps)*30*time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// post sends body as JSON to path, authenticated with token unless it is empty.
+func (env *authEnv) post(t *testing.T, path, token string, body any) (int, map[string]any) {
+	t.Helper()
+	b, err := json.Marshal(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err := http.NewRequest(http.MethodPost, env.srv.URL+path, bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := env.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var out map[string]any
+	json.NewDecoder(resp.Body).Decode(&out)
+	return resp.StatusCode, out
+}
+
+func (env *authEnv) login(t *testing.T, pw string) (int, map[string]any) {
+	t.Helper()
+	return env.post(t, "/login", "", map[string]string{"email": testEmail, "password": pw})
+}
+
+// TestLoginLockoutCoversSecondFactor checks that a correct password does not
+// reset the lockout while the second factor is pending, and that wrong MFA
+// codes count as failed logins.
+func TestLoginLockoutCoversSecondFactor(t *testing.T) {
+	env := newAuthEnv(t)
+
+	for i := 1; i < ratelimit.LockoutThreshold; i++ {
+		if status, _ := env.login(t, "wrong"); status != http.StatusUnauthorized {
+			t.Fatalf("failed login %d: status %d, want 401", i, status)
+		}
+	}
+	status, out := env.login(t, testPassword)
+	mfaToken, _ := out["mfa_token"].(string)
+	if status != http.StatusOK || mfaToken == "" {
+		t.Fatalf("password step: status %d, body %v; want an mfa_token", status, out)
+	}
+
+	// The password alone must not have reset the counter, so one wrong code
+	// reaches the threshold
+	if status, _ := env.post(t, "/login/mfa", "", map[string]string{"mfa_token": mfaToken, "code": wrongCode}); status != http.StatusUnauthorized {
+		t.Fatalf("wrong mfa code: status %d, want 401", status)
+	}
+	if status, _ := env.post(t, "/login/mfa", "", map[string]string{"mfa_token": mfaToken, "code": env.code(t, 1)}); status != http.StatusTooManyRequests {
+		t.Errorf("correct mfa code on a locked account: status %d, want 429", status)
+	}
+	if status, _ := env.login(t, testPassword); status != http.StatusTooManyRequests {
+		t.Errorf("password on a locked account: status %d, want 429", status)
+	}
+}
+
+// TestMFACodeGuessesLockOut checks that a stolen session cannot brute-force
+// the code that disables MFA or regenerates the recovery codes.
+func TestMFACodeGuessesLockOut(t *testing.T) {
+	for _, route := range []string{"disable", "recovery-codes"} {
+		t.Run(route, func(t *testing.T) {
+			path := "/mfa/" + route
+			env := newAuthEnv(t)
+			token, err := env.keys.SignAccessToken(env.userID, testEmail)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i := 1; i <= ratelimit.LockoutThreshold; i++ {
+				if status, _ := env.post(t, path, token, mfaCodeRequest{Code: wrongCode}); status == http.StatusOK || status == http.StatusNoContent {
+					t.Fatalf("wrong code %d accepted", i)
+				}
+			}
+			if status, _ := env.post(t, path, token, mfaCodeRequest{Code: env.code(t, 1)}); status != http.StatusTooManyRequests {
+				t.Errorf("correct code on a locked account: status %d, want 429", status)
+			}
+			if enabled, err := env.mfa.IsEnabled(context.Background(), env.userID); err != nil || !enabled {
+				t.Errorf("MFA enabled = %v, %v", enabled, err)
+			}
+			if status, _ := env.login(t, testPassword); status != http.StatusTooManyRequests {
+				t.Errorf("password on a locked account: status %d, want 429", status)
+			}
+		})
+	}
+}
+
+// TestChangePasswordGuessesLockOut checks that guessing the current password
+// with a stolen session locks the account without ending the session, and
+// that a password reset lifts the lockout.
+func TestChangePasswordGuessesLockOut(t *testing.T) {
+	env := newAuthEnv(t)
+	token, err := env.keys.SignAccessToken(env.userID, testEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+	change := func(current string) int {
+		t.Helper()
+		status, _ := env.post(t, "/change-password", token, map[string]string{"current_password": current, "new_password": "new-password-1"})
+		return status
+	}
+
+	for i := 1; i <= ratelimit.LockoutThreshold; i++ {
+		if status := change("wrong"); status != http.StatusBadRequest {
+			t.Fatalf("wrong current password %d: status %d, want 400", i, status)
+		}
+	}
+	if status := change(testPassword); status != http.StatusTooManyRequests {
+		t.Errorf("correct current password on a locked account: status %d, want 429", status)
+	}
+	if status, _ := env.login(t, testPassword); status != http.StatusTooManyRequests {
+		t.Errorf("password on a locked account: status %d, want 429", status)
+	}
+
+	if status, _ := env.post(t, "/forgot-password", "", map[string]string{"email": testEmail}); status != http.StatusAccepted {
+		t.Fatalf("forgot password: status %d", status)
+	}
+	m := regexp.MustCompile(`token=(\S+)`).FindStringSubmatch(env.mail.last.Body)
+	if m == nil {
+		t.Fatalf("no reset link in %q", env.mail.last.Body)
+	}
+	if status, _ := env.post(t, "/reset-password", "", map[string]string{"token": m[1], "password": "reset-password-1"}); status != http.StatusNoContent {
+		t.Fatalf("reset password: status %d", status)
+	}
+	if status, _ := env.login(t, "reset-password-1"); status != http.StatusOK {
+		t.Errorf("login after a reset: status %d, want 200", status)
+	}
+}
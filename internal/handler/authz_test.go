@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+// TestRolePermissions checks each role against routes guarded by every
+// permission level. Allowed requests carry an empty body, so writes answer
+// 400 once they get past the authorizer.
+func TestRolePermissions(t *testing.T) {
+	ctx := context.Background()
+	db := memory.NewDB()
+	ownerID, orgID := memory.SeedOrg(t, db)
+
+	authRepo := memory.NewAuthRepository(db)
+	orgRepo := memory.NewOrganizationRepository(db)
+	userIDs := map[organization.Role]string{organization.RoleOwner: ownerID}
+	for _, role := range []organization.Role{organization.RoleAdmin, organization.RoleOperator, organization.RoleViewer} {
+		id, err := authRepo.CreateUser(ctx, string(role)+"@example.com", string(role), "x")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := orgRepo.AddMember(ctx, orgID, id, role); err != nil {
+			t.Fatal(err)
+		}
+		userIDs[role] = id
+	}
+
+	keys := platform.NewKeyRing(platform.JWTConfig{Issuer: "test", Audience: "test", AccessTTL: time.Minute})
+	signing, err := platform.GenerateSigningKey(platform.AlgEdDSA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys.SetKeys(signing, nil)
+	apiKeys := apikey.NewService(memory.NewAPIKeyRepository(db))
+	readOnlyKey, _, err := apiKeys.Create(ctx, ownerID, "read only", []organization.Permission{organization.PermNodesRead, organization.PermJobsRead}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	orgs := organization.NewService(orgRepo)
+	mfaService := mfa.NewService(memory.NewMFARepository(db), make([]byte, 32), "test")
+	auditService := audit.NewService(memory.NewAuditRepository(db))
+	nodes := node.NewService(memory.NewNodeRepository(db))
+	jobs := job.NewService(memory.NewJobRepository(db), nil, testOutputLimits)
+	authz := middleware.NewAuthorizer(orgs, mfaService)
+	auth := middleware.Auth(keys, apiKeys)
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Mount("/organizations", NewOrganizationHandler(orgs, mfaService, auditService).Routes(auth, authz))
+		r.Mount("/nodes", NewNodeHandler(nodes).Routes(auth, authz))
+		r.Mount("/jobs", NewJobHandler(jobs, nil, nodes, auditService, NewMultiDispatcher()).
+			Routes(middleware.NodeAuth(nodetoken.NewService(memory.NewNodeTokenRepository(db))), auth, authz))
+		r.Mount("/audit", NewAuditHandler(auditService).Routes(auth, authz))
+	})
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	do := func(token, method, path string) int {
+		t.Helper()
+		var body *strings.Reader
+		if method == http.MethodGet {
+			body = strings.NewReader("")
+		} else {
+			body = strings.NewReader("{}")
+		}
+		req, err := http.NewRequest(method, srv.URL+path, body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set(middleware.OrganizationHeader, orgID)
+		resp, err := srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	const ok, invalid, denied = http.StatusOK, http.StatusBadRequest, http.StatusForbidden
+	routes := []struct {
+		method, path string
+		// want is indexed by role: owner, admin, operator, viewer
+		want [4]int
+	}{
+		{http.MethodGet, "/v1/nodes", [4]int{ok, ok, ok, ok}},
+		{http.MethodPost, "/v1/jobs", [4]int{invalid, invalid, invalid, denied}},
+		{http.MethodGet, "/v1/organizations/" + orgID + "/members", [4]int{ok, ok, ok, ok}},
+		{http.MethodPatch, "/v1/organizations/" + orgID + "/members/" + ownerID, [4]int{invalid, invalid, denied, denied}},
+		{http.MethodGet, "/v1/audit", [4]int{ok, ok, denied, denied}},
+		{http.MethodPatch, "/v1/organizations/" + orgID, [4]int{invalid, denied, denied, denied}},
+	}
+	roles := []organization.Role{organization.RoleOwner, organization.RoleAdmin, organization.RoleOperator, organization.RoleViewer}
+	for i, role := range roles {
+		token, err := keys.SignAccessToken(userIDs[role], string(role)+"@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, rt := range routes {
+			if got := do(token, rt.method, rt.path); got != rt.want[i] {
+				t.Errorf("%s %s %s = %d, want %d", role, rt.method, rt.path, got, rt.want[i])
+			}
+		}
+	}
+
+	// An API key never grants more than its scopes, even to the owner
+	if got := do(readOnlyKey, http.MethodGet, "/v1/nodes"); got != ok {
+		t.Errorf("read-only key GET /v1/nodes = %d, want %d", got, ok)
+	}
+	if got := do(readOnlyKey, http.MethodPost, "/v1/jobs"); got != denied {
+		t.Errorf("read-only key POST /v1/jobs = %d, want %d", got, denied)
+	}
+}
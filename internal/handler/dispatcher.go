@@ -1,7 +1,10 @@
 package handler
 
 import (
+	"context"
+
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
 )
 
 // MultiDispatcher tries each dispatcher in order and returns nil on the first
@@ -19,12 +22,31 @@ func NewMultiDispatcher(dispatchers ...NodeDispatcher) *MultiDispatcher {
 
 // DispatchJob tries each dispatcher until one succeeds.
 func (m *MultiDispatcher) DispatchJob(
+	ctx context.Context,
 	nodeID, jobID, commandName string,
 	commandType nodecommand.CommandType,
 ) error {
 	var lastErr error
 	for _, d := range m.dispatchers {
-		if err := d.DispatchJob(nodeID, jobID, commandName, commandType); err == nil {
+		if err := d.DispatchJob(ctx, nodeID, jobID, commandName, commandType); err == nil {
+			return nil
+		} else {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
+
+// SyncScripts tries each dispatcher that can push scripts (implements
+// ScriptSyncer) until one succeeds.
+func (m *MultiDispatcher) SyncScripts(ctx context.Context, nodeID string, scripts []script.Entry) error {
+	var lastErr error
+	for _, d := range m.dispatchers {
+		syncer, ok := d.(ScriptSyncer)
+		if !ok {
+			continue
+		}
+		if err := syncer.SyncScripts(ctx, nodeID, scripts); err == nil {
 			return nil
 		} else {
 			lastErr = err
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+type dispatcherFunc func(nodeID, jobID string) error
+
+func (f dispatcherFunc) DispatchJob(_ context.Context, nodeID, jobID, _ string, _ nodecommand.CommandType) error {
+	return f(nodeID, jobID)
+}
+
+func TestMultiDispatcher(t *testing.T) {
+	errGRPC := errors.New("grpc: not connected")
+	errWS := errors.New("ws: not connected")
+
+	var calls []string
+	transport := func(name string, err error) NodeDispatcher {
+		return dispatcherFunc(func(string, string) error {
+			calls = append(calls, name)
+			return err
+		})
+	}
+
+	tests := []struct {
+		name      string
+		grpc, ws  error
+		wantErr   error
+		wantCalls []string
+	}{
+		{"first wins", nil, nil, nil, []string{"grpc"}},
+		{"falls back", errGRPC, nil, nil, []string{"grpc", "ws"}},
+		{"all fail", errGRPC, errWS, errWS, []string{"grpc", "ws"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			d := NewMultiDispatcher(transport("grpc", tt.grpc), transport("ws", tt.ws))
+			err := d.DispatchJob(context.Background(), "node", "job", "uptime", nodecommand.CommandTypeDefault)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+
+	if err := NewMultiDispatcher().DispatchJob(context.Background(), "node", "job", "uptime", nodecommand.CommandTypeDefault); err != nil {
+		t.Errorf("no dispatchers: error = %v", err)
+	}
+}
+
+// syncDispatcher is a transport that can also push scripts.
+type syncDispatcher struct {
+	dispatcherFunc
+	sync func(nodeID string) error
+}
+
+func (d syncDispatcher) SyncScripts(_ context.Context, nodeID string, _ []script.Entry) error {
+	return d.sync(nodeID)
+}
+
+func TestMultiDispatcherSyncScripts(t *testing.T) {
+	errGRPC := errors.New("grpc: not connected")
+	errWS := errors.New("ws: not connected")
+
+	var calls []string
+	transport := func(name string, err error) NodeDispatcher {
+		return syncDispatcher{sync: func(string) error {
+			calls = append(calls, name)
+			return err
+		}}
+	}
+	// jobsOnly cannot push scripts and is skipped
+	jobsOnly := dispatcherFunc(func(string, string) error { return nil })
+
+	tests := []struct {
+		name      string
+		grpc, ws  error
+		wantErr   error
+		wantCalls []string
+	}{
+		{"first wins", nil, nil, nil, []string{"grpc"}},
+		{"falls back", errGRPC, nil, nil, []string{"grpc", "ws"}},
+		{"all fail", errGRPC, errWS, errWS, []string{"grpc", "ws"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			d := NewMultiDispatcher(jobsOnly, transport("grpc", tt.grpc), transport("ws", tt.ws))
+			err := d.SyncScripts(context.Background(), "node", []script.Entry{{Name: "backup.sh", Version: 1}})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+			if !slices.Equal(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
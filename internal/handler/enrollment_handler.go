@@ -2,15 +2,19 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
 	"net/http"
 	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
 	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/arturo/autohost-cloud-api/internal/platform"
 	"github.com/go-chi/chi/v5"
 )
@@ -19,21 +23,26 @@ type EnrollmentHandler struct {
 	service          *enrollment.Service
 	nodeService      *node.Service
 	nodeTokenService *nodetoken.Service
+	limiter          *ratelimit.Service
+	auditService     *audit.Service
 }
 
-func NewEnrollmentHandler(service *enrollment.Service, nodeService *node.Service, nodeTokenService *nodetoken.Service) *EnrollmentHandler {
-	return &EnrollmentHandler{service: service, nodeService: nodeService, nodeTokenService: nodeTokenService}
+// enrollIPLimit frena la adivinación de tokens de enrolamiento
+var enrollIPLimit = ratelimit.PerMinute(10)
+
+func NewEnrollmentHandler(service *enrollment.Service, nodeService *node.Service, nodeTokenService *nodetoken.Service, limiter *ratelimit.Service, auditService *audit.Service) *EnrollmentHandler {
+	return &EnrollmentHandler{service: service, nodeService: nodeService, nodeTokenService: nodeTokenService, limiter: limiter, auditService: auditService}
 }
 
-func (h *EnrollmentHandler) Routes() chi.Router {
+func (h *EnrollmentHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
 	r := chi.NewRouter()
 
 	r.Group(func(protected chi.Router) {
-		protected.Use(middleware.Auth)
-		protected.Post("/generate", h.CreateEnrollToken)
+		protected.Use(authMiddleware)
+		protected.With(authz.Require(organization.PermNodesWrite)).Post("/generate", h.CreateEnrollToken)
 	})
 
-	r.Post("/enroll", h.EnrollNode)
+	r.With(middleware.RateLimitByIP(h.limiter, "enroll", enrollIPLimit)).Post("/enroll", h.EnrollNode)
 
 	return r
 }
@@ -44,17 +53,17 @@ type enrollTokenResponse struct {
 }
 
 func (h *EnrollmentHandler) CreateEnrollToken(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaims(r.Context())
-	if claims == nil || claims.UserID == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
 	// Generar token de enrrolamiento
 	plainToken, hash, err := platform.GenerateEnrollToken()
 	if err != nil {
-		log.Printf("[ERROR] generate enroll token: %v", err)
-		http.Error(w, "could not generate token", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("generate enroll token", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not generate token")
 		return
 	}
 
@@ -62,17 +71,16 @@ func (h *EnrollmentHandler) CreateEnrollToken(w http.ResponseWriter, r *http.Req
 	expiresAt := time.Now().Add(1 * time.Hour)
 
 	// Guardar en BD (guardamos el hash, no el token plano)
-	if err := h.service.CreateEnrollToken(hash, claims.UserID, expiresAt); err != nil {
-		if err == enrollment.ErrInvalidEnrollTokenData {
-			http.Error(w, "invalid token data", http.StatusBadRequest)
-			return
-		}
-		log.Printf("[ERROR] create enroll token: %v", err)
-		http.Error(w, "could not create token", http.StatusInternalServerError)
+	if err := h.service.CreateEnrollToken(r.Context(), hash, membership.UserID, membership.OrganizationID, expiresAt); err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
-	log.Printf("[INFO] enroll token created for user: %s", claims.UserID)
+	logging.FromContext(r.Context()).Info("enroll token created", "organization_id", membership.OrganizationID)
+	recordAudit(h.auditService, r, audit.Event{
+		Action:   audit.ActionEnrollTokenCreate,
+		Metadata: map[string]any{"expires_at": expiresAt.UTC().Format(time.RFC3339)},
+	})
 
 	// Devolver token plano al usuario (solo esta vez)
 	w.Header().Set("Content-Type", "application/json")
@@ -94,70 +102,117 @@ func (h *EnrollmentHandler) EnrollNode(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "bad json", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
 		return
 	}
 
 	if req.EnrollToken == "" {
-		fmt.Println("here", req.EnrollToken)
-		http.Error(w, "missing enroll_token", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "missing enroll_token")
 		return
 	}
 
 	hash := platform.HashEnrollToken(req.EnrollToken)
 
-	enroll, err := h.service.FindEnrollTokenByHash(hash)
+	enroll, err := h.service.FindEnrollTokenByHash(r.Context(), hash)
+	if errors.Is(err, enrollment.ErrEnrollTokenNotFound) {
+		h.recordEnrollFailure(r, nil, req.Hostname, "invalid token")
+		apperr.Respond(w, r, apperr.Unauthenticated, "invalid token")
+		return
+	}
 	if err != nil {
-		http.Error(w, "invalid token", http.StatusUnauthorized)
+		apperr.Write(w, r, err)
 		return
 	}
 
 	if enroll.ConsumedAt != nil {
-		http.Error(w, "token already used", http.StatusUnauthorized)
+		h.recordEnrollFailure(r, enroll, req.Hostname, "token already used")
+		apperr.Respond(w, r, apperr.Unauthenticated, "token already used")
 		return
 	}
 
 	if time.Now().After(enroll.ExpiresAt) {
-		http.Error(w, "token expired", http.StatusUnauthorized)
+		h.recordEnrollFailure(r, enroll, req.Hostname, "token expired")
+		apperr.Respond(w, r, apperr.Unauthenticated, "token expired")
 		return
 	}
 
-	if err := h.service.MarkTokenAsUsed(enroll.Token, time.Now()); err != nil {
-		http.Error(w, "internal error", http.StatusInternalServerError)
+	// The checks above only pick the error; the token is checked again as it
+	// is consumed, so concurrent requests cannot both use it
+	err = h.service.ConsumeEnrollToken(r.Context(), enroll.Token, time.Now())
+	if errors.Is(err, enrollment.ErrEnrollTokenUsed) {
+		h.recordEnrollFailure(r, enroll, req.Hostname, "token already used")
+		apperr.Respond(w, r, apperr.Unauthenticated, "token already used")
+		return
+	}
+	if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 	node := &node.Node{
-		Hostname:     req.Hostname,
-		IPLocal:      req.IPLocal,
-		OS:           req.OS,
-		Arch:         req.Arch,
-		VersionAgent: req.VersionAgent,
-		OwnerID:      &enroll.UserID,
+		Hostname:       req.Hostname,
+		IPLocal:        req.IPLocal,
+		OS:             req.OS,
+		Arch:           req.Arch,
+		VersionAgent:   req.VersionAgent,
+		OrganizationID: enroll.OrganizationID,
+		OwnerID:        &enroll.UserID,
 	}
 
-	createdNode, err := h.nodeService.Register(node)
+	createdNode, err := h.nodeService.Register(r.Context(), node)
 	if err != nil {
-		http.Error(w, "failed to create node", http.StatusInternalServerError)
+		apperr.Respond(w, r, apperr.Internal, "failed to create node")
 		return
 	}
 
 	plainToken, hashToken, err := platform.GenerateTokenApi()
 	if err != nil {
-		http.Error(w, "failed to generate api token", http.StatusInternalServerError)
+		apperr.Respond(w, r, apperr.Internal, "failed to generate api token")
 		return
 	}
 
-	err = h.nodeTokenService.CreateNodeToken(createdNode.ID, hashToken)
+	err = h.nodeTokenService.CreateNodeToken(r.Context(), createdNode.ID, hashToken)
 	if err != nil {
-		http.Error(w, "failed to save api token", http.StatusInternalServerError)
+		apperr.Respond(w, r, apperr.Internal, "failed to save api token")
 		return
 	}
 
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType:      audit.ActorNode,
+		ActorID:        createdNode.ID,
+		OrganizationID: &createdNode.OrganizationID,
+		Action:         audit.ActionNodeEnroll,
+		TargetType:     "node",
+		TargetID:       createdNode.ID,
+		Metadata:       map[string]any{"hostname": createdNode.Hostname, "enrolled_by": enroll.UserID},
+	})
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType:      audit.ActorNode,
+		ActorID:        createdNode.ID,
+		OrganizationID: &createdNode.OrganizationID,
+		Action:         audit.ActionNodeTokenCreate,
+		TargetType:     "node",
+		TargetID:       createdNode.ID,
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]string{
 		"node_id":   createdNode.ID,
 		"api_token": plainToken,
 	})
+}
 
+// recordEnrollFailure audits a rejected enrollment attempt. enroll is nil when
+// the token was not found.
+func (h *EnrollmentHandler) recordEnrollFailure(r *http.Request, enroll *enrollment.EnrollToken, hostname, reason string) {
+	e := audit.Event{
+		ActorType: audit.ActorSystem,
+		Action:    audit.ActionNodeEnroll,
+		Outcome:   audit.OutcomeFailure,
+		Metadata:  map[string]any{"hostname": hostname, "reason": reason},
+	}
+	if enroll != nil {
+		e.OrganizationID = &enroll.OrganizationID
+	}
+	recordAudit(h.auditService, r, e)
 }
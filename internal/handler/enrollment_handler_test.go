@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+func TestEnrollNode(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	token := env.newEnrollToken(t, time.Now().Add(time.Hour))
+	info := agentsim.NodeInfo{Hostname: "web-1", IPLocal: "10.0.0.5", OS: "linux", Arch: "arm64", VersionAgent: "1.2.0"}
+	e, err := agentsim.Enroll(ctx, env.srv.Client(), env.srv.URL, token, info)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := env.nodes.GetByID(ctx, e.NodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.Hostname != "web-1" || n.IPLocal != "10.0.0.5" || n.Arch != "arm64" || n.OrganizationID != env.orgID ||
+		n.OwnerID == nil || *n.OwnerID != env.userID {
+		t.Errorf("enrolled node = %+v", n)
+	}
+	events, _, err := env.audit.List(ctx, audit.Filter{OrganizationID: env.orgID, Action: audit.ActionNodeEnroll})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ActorID != e.NodeID || events[0].Outcome != audit.OutcomeSuccess {
+		t.Errorf("audit events = %+v", events)
+	}
+
+	// The API token authenticates the node
+	a, err := agentsim.DialWS(ctx, env.srv.URL, e.APIToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Close()
+
+	// Enroll tokens are single use
+	info.Hostname = "web-2"
+	_, err = agentsim.Enroll(ctx, env.srv.Client(), env.srv.URL, token, info)
+	var httpErr *agentsim.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("reused token: error = %v, want 401", err)
+	}
+}
+
+func TestEnrollNodeRejected(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tests := []struct {
+		name  string
+		token string
+		want  int
+	}{
+		{"missing token", "", http.StatusBadRequest},
+		{"unknown token", "not-a-token", http.StatusUnauthorized},
+		{"expired token", env.newEnrollToken(t, time.Now().Add(-time.Minute)), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := agentsim.Enroll(ctx, env.srv.Client(), env.srv.URL, tt.token, agentsim.NodeInfo{Hostname: "web-1"})
+			var httpErr *agentsim.HTTPError
+			if !errors.As(err, &httpErr) || httpErr.StatusCode != tt.want {
+				t.Errorf("error = %v, want status %d", err, tt.want)
+			}
+		})
+	}
+
+	events, _, err := env.audit.List(ctx, audit.Filter{Action: audit.ActionNodeEnroll, Outcome: audit.OutcomeFailure})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 {
+		t.Errorf("failure events = %d, want 2", len(events))
+	}
+}
+
+// brokenEnrollRepo fails every lookup, like a database that is down.
+type brokenEnrollRepo struct{ enrollment.Repository }
+
+func (brokenEnrollRepo) FindEnrollTokenByHash(context.Context, string) (*enrollment.EnrollToken, error) {
+	return nil, errors.New("connection refused")
+}
+
+// TestEnrollNodeStoreError checks that a store failure is not reported to the
+// agent as an invalid token.
+func TestEnrollNodeStoreError(t *testing.T) {
+	db := memory.NewDB()
+	h := NewEnrollmentHandler(enrollment.NewService(brokenEnrollRepo{}), nil, nil,
+		ratelimit.NewService(memory.NewRateLimitRepository(db)), audit.NewService(memory.NewAuditRepository(db)))
+	r := chi.NewRouter()
+	noAuth := func(next http.Handler) http.Handler { return next }
+	r.Mount("/v1/enrollments", h.Routes(noAuth, nil))
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+
+	_, err := agentsim.Enroll(context.Background(), srv.Client(), srv.URL, "some-token", agentsim.NodeInfo{Hostname: "web-1"})
+	var httpErr *agentsim.HTTPError
+	if !errors.As(err, &httpErr) || httpErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("error = %v, want status 500", err)
+	}
+}
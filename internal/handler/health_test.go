@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func probe(t *testing.T, h http.HandlerFunc) (int, healthResponse) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	var resp healthResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Code, resp
+}
+
+func TestReadiness(t *testing.T) {
+	h := NewHealthHandler()
+	var dbErr error
+	h.AddCheck("database", func(context.Context) error { return dbErr })
+	h.AddCheck("grpc", func(context.Context) error { return nil })
+
+	if code, resp := probe(t, h.Ready); code != http.StatusOK || resp.Checks["database"] != "ok" {
+		t.Errorf("healthy: %d %+v", code, resp)
+	}
+
+	dbErr = errors.New("connection refused")
+	code, resp := probe(t, h.Ready)
+	if code != http.StatusServiceUnavailable || resp.Status != "unavailable" ||
+		resp.Checks["database"] != "connection refused" || resp.Checks["grpc"] != "ok" {
+		t.Errorf("database down: %d %+v", code, resp)
+	}
+
+	dbErr = nil
+	h.Drain()
+	if code, resp := probe(t, h.Ready); code != http.StatusServiceUnavailable || resp.Checks["shutdown"] != "draining" {
+		t.Errorf("draining: %d %+v", code, resp)
+	}
+	if code, _ := probe(t, h.Live); code != http.StatusOK {
+		t.Errorf("liveness while draining = %d", code)
+	}
+}
+
+func TestReadinessWithoutDatabase(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("status = %d, want 503", rec.Code)
+	}
+}
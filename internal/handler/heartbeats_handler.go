@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
 	"github.com/go-chi/chi/v5"
@@ -34,13 +35,13 @@ func (h *HeartbeatsHandler) HandleHeartbeat(w http.ResponseWriter, r *http.Reque
 	// El node_id viene del token validado, no del body
 	nodeToken := middleware.GetNodeToken(r.Context())
 	if nodeToken == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
 	// Actualizar last_seen del nodo
-	if err := h.nodeService.UpdateLastSeen(nodeToken.NodeID); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if err := h.nodeService.UpdateLastSeen(r.Context(), nodeToken.NodeID); err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
@@ -0,0 +1,302 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/go-chi/chi/v5"
+)
+
+// InvitationHandler exposes two groups of routes:
+//   - Admin routes (userAuth + members:manage): invite / list / revoke
+//   - Public routes (token in body): accept / decline
+type InvitationHandler struct {
+	service      *invitation.Service
+	orgService   *organization.Service
+	authService  *auth.Service
+	authRepo     auth.Repository
+	auditService *audit.Service
+}
+
+func NewInvitationHandler(
+	service *invitation.Service,
+	orgService *organization.Service,
+	authService *auth.Service,
+	authRepo auth.Repository,
+	auditService *audit.Service,
+) *InvitationHandler {
+	return &InvitationHandler{
+		service:      service,
+		orgService:   orgService,
+		authService:  authService,
+		authRepo:     authRepo,
+		auditService: auditService,
+	}
+}
+
+func (h *InvitationHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
+	r := chi.NewRouter()
+
+	r.Group(func(admin chi.Router) {
+		admin.Use(authMiddleware, authz.Require(organization.PermMembersManage))
+		admin.Post("/", h.Invite)
+		admin.Get("/", h.ListPending)
+		admin.Delete("/{id}", h.Revoke)
+	})
+
+	r.Post("/accept", h.Accept)
+	r.Post("/decline", h.Decline)
+	return r
+}
+
+type inviteRequest struct {
+	Email string            `json:"email"`
+	Role  organization.Role `json:"role"`
+}
+
+// Invite emails an invitation to join the active organization.
+// POST /v1/invitations
+func (h *InvitationHandler) Invite(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var req inviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	org, err := h.orgService.Get(r.Context(), membership.OrganizationID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("get organization", "organization_id", membership.OrganizationID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not create invitation")
+		return
+	}
+
+	inv, err := h.service.Invite(r.Context(), membership, org.Name, req.Email, req.Role)
+	if err != nil {
+		if errors.Is(err, invitation.ErrInvalidInvitationData) {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid email")
+			return
+		}
+		apperr.Write(w, r, err)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("invitation created", "invitation_id", inv.ID, "email", inv.Email, "organization_id", inv.OrganizationID)
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionInvitationCreate,
+		TargetType: "invitation",
+		TargetID:   inv.ID,
+		Metadata:   map[string]any{"email": inv.Email, "role": string(inv.Role)},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(inv)
+}
+
+// ListPending returns open invitations of the active organization.
+// GET /v1/invitations
+func (h *InvitationHandler) ListPending(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	invs, err := h.service.ListPending(r.Context(), membership.OrganizationID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list invitations", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list invitations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(invs)
+}
+
+// Revoke deletes an invitation of the active organization.
+// DELETE /v1/invitations/{id}
+func (h *InvitationHandler) Revoke(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	id, ok := uuidParam(w, r, "id", "invitation")
+	if !ok {
+		return
+	}
+	if err := h.service.Revoke(r.Context(), membership.OrganizationID, id); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionInvitationRevoke,
+		TargetType: "invitation",
+		TargetID:   id,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type acceptInvitationRequest struct {
+	Token    string `json:"token"`
+	Name     string `json:"name"`
+	Password string `json:"password"` // required only when no account exists for the email
+}
+
+// Accept joins the invited email to the organization, creating the user
+// account first if it does not exist yet.
+// POST /v1/invitations/accept
+func (h *InvitationHandler) Accept(w http.ResponseWriter, r *http.Request) {
+	var req acceptInvitationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	inv, ok := h.findPending(w, r, req.Token)
+	if !ok {
+		return
+	}
+
+	user, err := h.authRepo.FindUserByEmail(r.Context(), inv.Email)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("find user", "email", inv.Email, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+	if user == nil && req.Password == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "password required to create the account")
+		return
+	}
+	if user == nil {
+		if err := auth.ValidatePassword(req.Password); err != nil {
+			apperr.Write(w, r, err)
+			return
+		}
+	}
+
+	var userID string
+	if user != nil {
+		userID = user.ID
+	} else {
+		userID, err = h.authService.Register(r.Context(), inv.Email, req.Name, req.Password)
+		if err != nil {
+			if errors.Is(err, auth.ErrUserAlreadyExists) {
+				apperr.Write(w, r, err)
+				return
+			}
+			logging.FromContext(r.Context()).Error("register invited user", "email", inv.Email, "error", err)
+			apperr.Respond(w, r, apperr.Internal, "could not create user")
+			return
+		}
+		// El token de la invitación llegó a ese email: queda verificado
+		if err := h.authService.MarkEmailVerified(r.Context(), userID); err != nil {
+			logging.FromContext(r.Context()).Error("mark email verified", "user_id", userID, "error", err)
+		}
+	}
+
+	// También para cuentas existentes: si un intento anterior creó la cuenta
+	// y falló después, el reintento llega por aquí. EnsurePersonal es idempotente.
+	orgName := req.Name
+	if user != nil && user.Name != nil {
+		orgName = *user.Name
+	}
+	if orgName == "" {
+		orgName = inv.Email
+	}
+	if _, err := h.orgService.EnsurePersonal(r.Context(), userID, orgName); err != nil {
+		logging.FromContext(r.Context()).Error("create personal organization", "user_id", userID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+
+	if err := h.orgService.Join(r.Context(), inv.OrganizationID, userID, inv.Role); err != nil &&
+		!errors.Is(err, organization.ErrMemberAlreadyExists) {
+		logging.FromContext(r.Context()).Error("join organization", "organization_id", inv.OrganizationID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not join organization")
+		return
+	}
+
+	// La invitación se consume al final, con una actualización condicional:
+	// si algo falla antes sigue pendiente y se puede reintentar, y de dos
+	// aceptaciones simultáneas solo una la consume
+	if err := h.service.Accept(r.Context(), inv); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	logging.FromContext(r.Context()).Info("user joined organization", "user_id", userID, "organization_id", inv.OrganizationID, "role", inv.Role)
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType:      audit.ActorUser,
+		ActorID:        userID,
+		OrganizationID: &inv.OrganizationID,
+		Action:         audit.ActionInvitationAccept,
+		TargetType:     "invitation",
+		TargetID:       inv.ID,
+		Metadata:       map[string]any{"role": string(inv.Role), "user_created": user == nil},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"organization_id": inv.OrganizationID,
+		"user_id":         userID,
+		"role":            inv.Role,
+		"user_created":    user == nil,
+	})
+}
+
+// Decline closes an invitation without joining.
+// POST /v1/invitations/decline
+func (h *InvitationHandler) Decline(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	inv, ok := h.findPending(w, r, req.Token)
+	if !ok {
+		return
+	}
+	if err := h.service.Decline(r.Context(), inv); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		ActorType:      audit.ActorSystem,
+		OrganizationID: &inv.OrganizationID,
+		Action:         audit.ActionInvitationDecline,
+		TargetType:     "invitation",
+		TargetID:       inv.ID,
+		Metadata:       map[string]any{"email": inv.Email},
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *InvitationHandler) findPending(w http.ResponseWriter, r *http.Request, token string) (*invitation.Invitation, bool) {
+	inv, err := h.service.FindPending(r.Context(), token)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return nil, false
+	}
+	return inv, true
+}
@@ -1,41 +1,70 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"io"
+	"mime"
 	"net/http"
+	"strconv"
+	"strings"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/go-chi/chi/v5"
 )
 
 // NodeDispatcher abstracts sending an execute_job request to a connected node,
 // regardless of the underlying transport (WebSocket or gRPC).
 type NodeDispatcher interface {
-	DispatchJob(nodeID, jobID, commandName string, commandType nodecommand.CommandType) error
+	// DispatchJob sends the job to the node. ctx carries the job's trace,
+	// which the transport forwards to the agent.
+	DispatchJob(ctx context.Context, nodeID, jobID, commandName string, commandType nodecommand.CommandType) error
 }
 
-// JobHandler handles job dispatch and status queries.
+// JobHandler handles job dispatch and status queries, and the artifacts
+// nodes upload for their jobs.
 type JobHandler struct {
-	jobService *job.Service
-	dispatcher NodeDispatcher
+	jobService      *job.Service
+	artifactService *jobartifact.Service
+	nodeService     *node.Service
+	auditService    *audit.Service
+	dispatcher      NodeDispatcher
 }
 
-func NewJobHandler(jobService *job.Service, dispatcher NodeDispatcher) *JobHandler {
+func NewJobHandler(jobService *job.Service, artifactService *jobartifact.Service, nodeService *node.Service, auditService *audit.Service, dispatcher NodeDispatcher) *JobHandler {
 	return &JobHandler{
-		jobService: jobService,
-		dispatcher: dispatcher,
+		jobService:      jobService,
+		artifactService: artifactService,
+		nodeService:     nodeService,
+		auditService:    auditService,
+		dispatcher:      dispatcher,
 	}
 }
 
-func (h *JobHandler) Routes() chi.Router {
+func (h *JobHandler) Routes(nodeAuthMiddleware, authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
 	r := chi.NewRouter()
-	r.Use(middleware.Auth)
-	r.Post("/", h.Dispatch)
-	r.Get("/{id}", h.GetJob)
-	r.Get("/node/{nodeID}", h.ListByNode)
+
+	// Node-facing endpoints (agent calls these)
+	r.With(nodeAuthMiddleware).Post("/{id}/artifacts", h.UploadArtifact)
+
+	// User-facing endpoints (dashboard calls these)
+	r.Group(func(user chi.Router) {
+		user.Use(authMiddleware)
+		user.With(authz.Require(organization.PermJobsWrite)).Post("/", h.Dispatch)
+		user.With(authz.Require(organization.PermJobsRead)).Get("/{id}", h.GetJob)
+		user.With(authz.Require(organization.PermJobsRead)).Get("/{id}/output", h.GetOutput)
+		user.With(authz.Require(organization.PermJobsRead)).Get("/{id}/artifacts", h.ListArtifacts)
+		user.With(authz.Require(organization.PermJobsRead)).Get("/{id}/artifacts/{name}", h.GetArtifact)
+		user.With(authz.Require(organization.PermJobsRead)).Get("/node/{nodeID}", h.ListByNode)
+	})
 	return r
 }
 
@@ -49,30 +78,51 @@ type dispatchJobRequest struct {
 // Dispatch creates a pending job and sends an execute_job message to the node.
 // POST /v1/jobs
 func (h *JobHandler) Dispatch(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaims(r.Context())
-	if claims == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
 	var req dispatchJobRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.NodeID == "" || req.CommandName == "" {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
 		return
 	}
 
-	j, err := h.jobService.Dispatch(req.NodeID, req.CommandName, req.CommandType)
+	if !h.nodeInOrganization(w, r, req.NodeID, membership.OrganizationID) {
+		return
+	}
+
+	j, err := h.jobService.Dispatch(r.Context(), req.NodeID, req.CommandName, req.CommandType)
 	if err != nil {
-		log.Printf("[ERROR] dispatch job: %v", err)
-		http.Error(w, "could not create job", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("dispatch job", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not create job")
 		return
 	}
 
-	if err := h.dispatcher.DispatchJob(req.NodeID, j.ID, j.CommandName, j.CommandType); err != nil {
-		log.Printf("[WARN] node %s not connected, job %s stays pending: %v", req.NodeID, j.ID, err)
+	delivered := true
+	err = h.jobService.Deliver(r.Context(), j, func(ctx context.Context) error {
+		return h.dispatcher.DispatchJob(ctx, req.NodeID, j.ID, j.CommandName, j.CommandType)
+	})
+	if err != nil {
+		logging.FromContext(r.Context()).Warn("node not connected, job stays pending", "node_id", req.NodeID, "job_id", j.ID, "error", err)
 		// Job stays pending – future: queue and push on reconnect.
+		delivered = false
 	}
 
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionJobDispatch,
+		TargetType: "node",
+		TargetID:   req.NodeID,
+		Metadata: map[string]any{
+			"job_id":       j.ID,
+			"command_name": j.CommandName,
+			"command_type": string(j.CommandType),
+			"delivered":    delivered,
+		},
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(j)
@@ -81,30 +131,266 @@ func (h *JobHandler) Dispatch(w http.ResponseWriter, r *http.Request) {
 // GetJob returns the current status of a job.
 // GET /v1/jobs/{id}
 func (h *JobHandler) GetJob(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	j, err := h.jobService.GetByID(id)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	j, ok := h.jobInOrganization(w, r, membership.OrganizationID)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(j)
+}
+
+// GetOutput streams the full output of a job as plain text, including the
+// part cut from the inline output when it went over the inline limit.
+// GET /v1/jobs/{id}/output
+func (h *JobHandler) GetOutput(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	j, ok := h.jobInOrganization(w, r, membership.OrganizationID)
+	if !ok {
+		return
+	}
+	out, err := h.jobService.OpenOutput(r.Context(), j)
 	if err != nil {
-		if err == job.ErrJobNotFound {
-			http.Error(w, "job not found", http.StatusNotFound)
+		logging.FromContext(r.Context()).Error("open job output", "job_id", j.ID, "error", err)
+		apperr.Write(w, r, err)
+		return
+	}
+	defer out.Close()
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if j.OutputBlob != "" {
+		// Content-addressed, so the bytes behind this ETag never change
+		w.Header().Set("ETag", `"`+j.OutputBlob+`"`)
+	}
+	if _, err := io.Copy(w, out); err != nil {
+		logging.FromContext(r.Context()).Warn("stream job output", "job_id", j.ID, "error", err)
+	}
+}
+
+// UploadArtifact stores a file produced by a job of the authenticated node.
+// The body is multipart/form-data: an optional "sha256" field, sent before
+// the file, is checked against the received content, and the "file" part
+// carries the content, named after its file name unless a "name" field comes
+// first. The whole upload is bounded by the request timeout; agents send
+// large files with the UploadArtifact gRPC stream instead.
+// POST /v1/jobs/{id}/artifacts
+func (h *JobHandler) UploadArtifact(w http.ResponseWriter, r *http.Request) {
+	nodeToken := middleware.GetNodeToken(r.Context())
+	if nodeToken == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "job")
+	if !ok {
+		return
+	}
+	mr, err := r.MultipartReader()
+	if err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "multipart/form-data body expected")
+		return
+	}
+
+	u := jobartifact.Upload{JobID: id}
+	for u.Body == nil {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			apperr.Respond(w, r, apperr.InvalidArgument, "missing file part")
 			return
 		}
-		http.Error(w, "could not get job", http.StatusInternalServerError)
+		if err != nil {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid multipart body")
+			return
+		}
+		switch part.FormName() {
+		case "file":
+			if u.Name == "" {
+				u.Name = part.FileName()
+			}
+			u.ContentType = part.Header.Get("Content-Type")
+			u.Body = part
+		case "name", "sha256":
+			v, err := io.ReadAll(io.LimitReader(part, 1<<10))
+			if err != nil {
+				apperr.Respond(w, r, apperr.InvalidArgument, "invalid multipart body")
+				return
+			}
+			if part.FormName() == "name" {
+				u.Name = string(v)
+			} else {
+				u.SHA256 = strings.TrimSpace(string(v))
+			}
+		}
+	}
+
+	a, err := h.artifactService.Upload(r.Context(), nodeToken.NodeID, u)
+	if err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionJobArtifactUpload,
+		TargetType: "job",
+		TargetID:   a.JobID,
+		Metadata:   map[string]any{"name": a.Name, "size": a.Size, "sha256": a.SHA256},
+	})
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(j)
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
 }
 
-// ListByNode lists all jobs for a node.
-// GET /v1/jobs/node/{nodeID}
+// ListArtifacts lists the artifacts of a job, expired ones included.
+// GET /v1/jobs/{id}/artifacts
+func (h *JobHandler) ListArtifacts(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	j, ok := h.jobInOrganization(w, r, membership.OrganizationID)
+	if !ok {
+		return
+	}
+	artifacts, err := h.artifactService.List(r.Context(), j.ID)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if artifacts == nil {
+		artifacts = []*jobartifact.Artifact{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"artifacts": artifacts})
+}
+
+// GetArtifact downloads an artifact of a job. Expired artifacts answer 410.
+// GET /v1/jobs/{id}/artifacts/{name}
+func (h *JobHandler) GetArtifact(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	j, ok := h.jobInOrganization(w, r, membership.OrganizationID)
+	if !ok {
+		return
+	}
+	a, content, err := h.artifactService.Open(r.Context(), j.ID, chi.URLParam(r, "name"))
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	defer content.Close()
+
+	w.Header().Set("Content-Type", a.ContentType)
+	w.Header().Set("Content-Length", strconv.FormatInt(a.Size, 10))
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": a.Name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Checksum-SHA256", a.SHA256)
+	w.Header().Set("ETag", `"`+a.SHA256+`"`)
+	if _, err := io.Copy(w, content); err != nil {
+		logging.FromContext(r.Context()).Warn("stream job artifact", "job_id", j.ID, "name", a.Name, "error", err)
+	}
+}
+
+// jobInOrganization loads the job named by the id URL parameter. It writes an
+// error and returns false when the id is invalid or the job is missing; jobs
+// of nodes outside the organization are reported as missing too.
+func (h *JobHandler) jobInOrganization(w http.ResponseWriter, r *http.Request, orgID string) (*job.Job, bool) {
+	id, ok := uuidParam(w, r, "id", "job")
+	if !ok {
+		return nil, false
+	}
+	j, err := h.jobService.GetByID(r.Context(), id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return nil, false
+	}
+	if _, err := h.nodeService.GetForOrganization(r.Context(), j.NodeID, orgID); err != nil {
+		apperr.Respond(w, r, apperr.NotFound, "job not found")
+		return nil, false
+	}
+	return j, true
+}
+
+type jobPage struct {
+	Jobs       []*job.Job `json:"jobs"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+}
+
+// ListByNode lists the jobs of a node, newest first by default, with cursor
+// pagination.
+// GET /v1/jobs/node/{nodeID}?status=&since=&until=&sort=&limit=&cursor=
 func (h *JobHandler) ListByNode(w http.ResponseWriter, r *http.Request) {
-	nodeID := chi.URLParam(r, "nodeID")
-	jobs, err := h.jobService.ListByNode(nodeID)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	nodeID, ok := uuidParam(w, r, "nodeID", "node")
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	f := job.ListFilter{NodeID: nodeID}
+	if v := q.Get("status"); v != "" {
+		for _, st := range strings.Split(v, ",") {
+			f.Statuses = append(f.Statuses, job.JobStatus(strings.TrimSpace(st)))
+		}
+	}
+	var err error
+	if f.Since, err = parseTimeParam(q.Get("since")); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid since (RFC 3339 expected)")
+		return
+	}
+	if f.Until, err = parseTimeParam(q.Get("until")); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid until (RFC 3339 expected)")
+		return
+	}
+	if f.Sort, f.After, f.Limit, ok = pageParams(w, r, job.DefaultSort, job.SortFields); !ok {
+		return
+	}
+
+	if !h.nodeInOrganization(w, r, nodeID, membership.OrganizationID) {
+		return
+	}
+
+	jobs, next, err := h.jobService.List(r.Context(), f)
 	if err != nil {
-		log.Printf("[ERROR] list jobs: %v", err)
-		http.Error(w, "could not list jobs", http.StatusInternalServerError)
+		apperr.Write(w, r, err)
 		return
 	}
+
+	page := jobPage{Jobs: jobs, NextCursor: nextCursor(next)}
+	if page.Jobs == nil {
+		page.Jobs = []*job.Job{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jobs)
+	json.NewEncoder(w).Encode(page)
+}
+
+// nodeInOrganization writes a 404 and returns false when nodeID does not
+// belong to the organization.
+func (h *JobHandler) nodeInOrganization(w http.ResponseWriter, r *http.Request, nodeID, orgID string) bool {
+	if _, err := h.nodeService.GetForOrganization(r.Context(), nodeID, orgID); err != nil {
+		apperr.Write(w, r, err)
+		return false
+	}
+	return true
 }
@@ -0,0 +1,240 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+)
+
+// get sends an authenticated GET for the test user in the test organization.
+func (env *agentEnv) get(t *testing.T, ctx context.Context, path string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.srv.URL+path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+env.apiKey)
+	req.Header.Set(middleware.OrganizationHeader, env.orgID)
+	resp, err := env.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestJobOutput(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	report := func(output string) *job.Job {
+		t.Helper()
+		j, err := env.jobs.Dispatch(ctx, e.NodeID, "journalctl", nodecommand.CommandTypeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted, Output: output}); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.Ping(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return j
+	}
+
+	t.Run("inline", func(t *testing.T) {
+		j := report("short")
+		resp, body := env.get(t, ctx, "/v1/jobs/"+j.ID+"/output")
+		if resp.StatusCode != http.StatusOK || string(body) != "short" ||
+			resp.Header.Get("Content-Type") != "text/plain; charset=utf-8" {
+			t.Errorf("GET output = %d %q", resp.StatusCode, body)
+		}
+	})
+
+	t.Run("over the inline limit", func(t *testing.T) {
+		full := strings.Repeat("log line\n", 1000)
+		j := report(full)
+
+		resp, body := env.get(t, ctx, "/v1/jobs/"+j.ID)
+		var got struct {
+			Output          string
+			OutputSize      int64
+			OutputTruncated bool
+			OutputBlob      string
+		}
+		if err := json.Unmarshal(body, &got); err != nil {
+			t.Fatalf("GET job = %d %s", resp.StatusCode, body)
+		}
+		if !got.OutputTruncated || got.OutputSize != int64(len(full)) || got.OutputBlob != "" ||
+			!strings.Contains(got.Output, "full output at GET /v1/jobs/"+j.ID+"/output") {
+			t.Errorf("GET job = %+v", got)
+		}
+
+		resp, body = env.get(t, ctx, "/v1/jobs/"+j.ID+"/output")
+		if resp.StatusCode != http.StatusOK || string(body) != full || resp.Header.Get("ETag") == "" {
+			t.Errorf("GET output = %d, %d bytes", resp.StatusCode, len(body))
+		}
+	})
+
+	t.Run("over the max size", func(t *testing.T) {
+		j := report(strings.Repeat("x", testOutputLimits.Max+100))
+		_, body := env.get(t, ctx, "/v1/jobs/"+j.ID+"/output")
+		if !strings.HasSuffix(string(body), "\n[output truncated: 100 bytes dropped]\n") {
+			t.Errorf("GET output ends with %q", body[max(0, len(body)-60):])
+		}
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		resp, _ := env.get(t, ctx, "/v1/jobs/"+unknownJobID+"/output")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET unknown job output = %d", resp.StatusCode)
+		}
+	})
+}
+
+const unknownJobID = "00000000-0000-0000-0000-000000000000"
+
+// uploadArtifact posts content as artifact name of jobID with the node token,
+// as a multipart form with an optional sha256 field before the file.
+func (env *agentEnv) uploadArtifact(t *testing.T, ctx context.Context, nodeToken, jobID, name, sum, content string) (*http.Response, []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if sum != "" {
+		mw.WriteField("sha256", sum)
+	}
+	h := textproto.MIMEHeader{}
+	h.Set("Content-Disposition", `form-data; name="file"; filename="`+name+`"`)
+	h.Set("Content-Type", "application/gzip")
+	part, err := mw.CreatePart(h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(part, content)
+	mw.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, env.srv.URL+"/v1/jobs/"+jobID+"/artifacts", &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+nodeToken)
+	resp, err := env.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, body
+}
+
+func TestJobArtifacts(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, _ := env.connectNode(t, ctx, "db-1")
+	other, _ := env.connectNode(t, ctx, "web-1")
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "backup", nodecommand.CommandTypeCustom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := strings.Repeat("dump", 1000)
+
+	resp, body := env.uploadArtifact(t, ctx, e.APIToken, j.ID, "db.sql.gz", blob.Key([]byte(content)), content)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("upload = %d %s", resp.StatusCode, body)
+	}
+
+	t.Run("download", func(t *testing.T) {
+		resp, body := env.get(t, ctx, "/v1/jobs/"+j.ID+"/artifacts/db.sql.gz")
+		if resp.StatusCode != http.StatusOK || string(body) != content {
+			t.Fatalf("GET artifact = %d, %d bytes", resp.StatusCode, len(body))
+		}
+		if resp.Header.Get("Content-Type") != "application/gzip" ||
+			resp.Header.Get("Content-Disposition") != `attachment; filename=db.sql.gz` ||
+			resp.Header.Get("X-Checksum-SHA256") != blob.Key([]byte(content)) {
+			t.Errorf("headers = %v", resp.Header)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		_, body := env.get(t, ctx, "/v1/jobs/"+j.ID+"/artifacts")
+		var got struct {
+			Artifacts []struct {
+				Name string `json:"name"`
+				Size int64  `json:"size"`
+			} `json:"artifacts"`
+		}
+		if err := json.Unmarshal(body, &got); err != nil || len(got.Artifacts) != 1 ||
+			got.Artifacts[0].Name != "db.sql.gz" || got.Artifacts[0].Size != int64(len(content)) {
+			t.Errorf("GET artifacts = %s", body)
+		}
+	})
+
+	t.Run("checksum mismatch", func(t *testing.T) {
+		resp, _ := env.uploadArtifact(t, ctx, e.APIToken, j.ID, "other.gz", blob.Key([]byte("else")), content)
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("upload = %d, want 400", resp.StatusCode)
+		}
+	})
+
+	t.Run("another node's job", func(t *testing.T) {
+		resp, _ := env.uploadArtifact(t, ctx, other.APIToken, j.ID, "steal.gz", "", "x")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("upload = %d, want 404", resp.StatusCode)
+		}
+	})
+
+	t.Run("unknown artifact", func(t *testing.T) {
+		resp, _ := env.get(t, ctx, "/v1/jobs/"+j.ID+"/artifacts/missing")
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("GET missing artifact = %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestWSRejectsOversizedMessage(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "journalctl", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	huge := strings.Repeat("x", int(AgentMessageLimit(testOutputLimits)))
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted, Output: huge}); err != nil {
+		t.Fatal(err)
+	}
+	// The server closes the connection instead of buffering the message
+	if err := a.Wait(); err == nil {
+		t.Error("connection still open after an oversized message")
+	}
+	if got, _ := env.jobs.GetByID(ctx, j.ID); got.Status != job.StatusPending {
+		t.Errorf("job = %+v", got)
+	}
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// JWKSHandler publica las claves públicas con las que se verifican los access
+// tokens, para que otros servicios los validen sin compartir secretos.
+type JWKSHandler struct {
+	keys *platform.KeyRing
+}
+
+func NewJWKSHandler(keys *platform.KeyRing) *JWKSHandler {
+	return &JWKSHandler{keys: keys}
+}
+
+func (h *JWKSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	// Una clave rotada no firma hasta que caduca esta caché (ver signingkey.ActivationDelay)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(signingkey.JWKSMaxAge.Seconds())))
+	json.NewEncoder(w).Encode(h.keys.JWKS())
+}
@@ -0,0 +1,256 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+type mfaCodeRequest struct {
+	Code string `json:"code"`
+}
+
+// LoginMFA completa un login con MFA canjeando el token del desafío y un
+// código TOTP o de recuperación por access y refresh token.
+// POST /v1/auth/login/mfa
+func (h *AuthHandler) LoginMFA(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		MFAToken string `json:"mfa_token"`
+		Code     string `json:"code"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil || in.MFAToken == "" || in.Code == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "mfa_token and code required")
+		return
+	}
+
+	// Los códigos fallidos cuentan para el bloqueo de la cuenta igual que las
+	// contraseñas, y una cuenta bloqueada no puede gastar los desafíos que
+	// obtuvo antes
+	userID, err := h.mfa.ChallengeUser(r.Context(), in.MFAToken)
+	var user *auth.User
+	if err == nil {
+		user, err = h.repo.FindUserByID(r.Context(), userID)
+		if err == nil && user == nil {
+			err = mfa.ErrInvalidChallenge
+		}
+	}
+	if err == nil {
+		if locked, lerr := h.limiter.LockedFor(r.Context(), user.Email); lerr != nil {
+			logging.FromContext(r.Context()).Error("check login lockout", "user_id", user.ID, "error", lerr)
+		} else if locked > 0 {
+			h.recordMFAFailure(r, userID, "account locked")
+			apperr.TooManyRequests(w, r, locked)
+			return
+		}
+		_, err = h.mfa.CompleteChallenge(r.Context(), in.MFAToken, in.Code)
+	}
+	if errors.Is(err, mfa.ErrInvalidChallenge) || errors.Is(err, mfa.ErrInvalidCode) {
+		if user != nil {
+			h.credentialFailed(r, user.ID, user.Email)
+		}
+		h.recordMFAFailure(r, userID, "")
+		apperr.Respond(w, r, apperr.Unauthenticated, "invalid or expired mfa code")
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("complete mfa challenge", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return
+	}
+
+	h.issueTokens(w, r, user, map[string]any{"mfa": true})
+}
+
+// recordMFAFailure audita un segundo paso fallido; userID vacío si el
+// desafío no existe
+func (h *AuthHandler) recordMFAFailure(r *http.Request, userID, reason string) {
+	e := audit.Event{
+		ActorType: audit.ActorUser,
+		Action:    audit.ActionAuthLogin,
+		Outcome:   audit.OutcomeFailure,
+		Metadata:  map[string]any{"mfa": true},
+	}
+	if reason != "" {
+		e.Metadata["reason"] = reason
+	}
+	if userID != "" {
+		h.recordPersonalEvent(r, userID, e)
+	} else {
+		recordAudit(h.auditService, r, e)
+	}
+}
+
+// MFAStatus devuelve el estado de MFA del usuario
+// GET /v1/auth/mfa
+func (h *AuthHandler) MFAStatus(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	status, err := h.mfa.Status(r.Context(), claims.UserID)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// BeginTOTP genera un secreto TOTP pendiente de confirmar
+// POST /v1/auth/mfa/totp
+func (h *AuthHandler) BeginTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	secret, uri, err := h.mfa.BeginEnrollment(r.Context(), claims.UserID, claims.Email)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"secret":      secret,
+		"otpauth_uri": uri,
+	})
+}
+
+// ConfirmTOTP activa MFA con el primer código y devuelve los códigos de recuperación
+// POST /v1/auth/mfa/totp/confirm
+func (h *AuthHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var in mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	codes, err := h.mfa.ConfirmEnrollment(r.Context(), claims.UserID, in.Code)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordUserEvent(r, claims.UserID, audit.ActionMFAEnable, audit.OutcomeSuccess)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"recovery_codes": codes})
+}
+
+// DisableMFA desactiva MFA; exige un código TOTP o de recuperación
+// POST /v1/auth/mfa/disable
+func (h *AuthHandler) DisableMFA(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var in mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	email, ok := h.checkUserLockout(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	err := h.mfa.Disable(r.Context(), claims.UserID, in.Code)
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		h.credentialFailed(r, claims.UserID, email)
+		h.recordUserEvent(r, claims.UserID, audit.ActionMFADisable, audit.OutcomeFailure)
+	}
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordUserEvent(r, claims.UserID, audit.ActionMFADisable, audit.OutcomeSuccess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RegenerateRecoveryCodes reemplaza los códigos de recuperación
+// POST /v1/auth/mfa/recovery-codes
+func (h *AuthHandler) RegenerateRecoveryCodes(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var in mfaCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	email, ok := h.checkUserLockout(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	codes, err := h.mfa.RegenerateRecoveryCodes(r.Context(), claims.UserID, in.Code)
+	if errors.Is(err, mfa.ErrInvalidCode) {
+		h.credentialFailed(r, claims.UserID, email)
+	}
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordUserEvent(r, claims.UserID, audit.ActionMFARecoveryCodes, audit.OutcomeSuccess)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"recovery_codes": codes})
+}
+
+// checkUserLockout responde 429 y devuelve false si la cuenta de userID está
+// bloqueada. Los códigos de MFA y la contraseña actual que se piden con la
+// sesión ya abierta cuentan para el mismo bloqueo que el login (ver
+// credentialFailed): si no, quien robe un access token podría adivinarlos y
+// desactivar MFA o cambiar la contraseña.
+func (h *AuthHandler) checkUserLockout(w http.ResponseWriter, r *http.Request, userID string) (email string, ok bool) {
+	user, err := h.repo.FindUserByID(r.Context(), userID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("get user", "user_id", userID, "error", err)
+		apperr.Respond(w, r, apperr.Internal, "internal error")
+		return "", false
+	}
+	if user == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return "", false
+	}
+	if locked, err := h.limiter.LockedFor(r.Context(), user.Email); err != nil {
+		logging.FromContext(r.Context()).Error("check login lockout", "user_id", userID, "error", err)
+	} else if locked > 0 {
+		apperr.TooManyRequests(w, r, locked)
+		return "", false
+	}
+	return user.Email, true
+}
+
+// credentialFailed cuenta un código de MFA o una contraseña incorrectos como
+// un login fallido de email
+func (h *AuthHandler) credentialFailed(r *http.Request, userID, email string) {
+	if _, err := h.limiter.LoginFailed(r.Context(), email); err != nil {
+		logging.FromContext(r.Context()).Error("record failed login", "user_id", userID, "error", err)
+	}
+}
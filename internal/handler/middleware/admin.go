@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+)
+
+// AdminAuth protege los endpoints de operación con el token ADMIN_TOKEN. Sin
+// token configurado los endpoints no existen (404).
+func AdminAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if token == "" {
+				apperr.Respond(w, r, apperr.NotFound, "not found")
+				return
+			}
+			parts := strings.SplitN(r.Header.Get("Authorization"), " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") ||
+				subtle.ConstantTimeCompare([]byte(parts[1]), []byte(token)) != 1 {
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid admin token")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -3,81 +3,78 @@ package middleware
 import (
 	"context"
 	"errors"
-	"fmt"
-	"log"
 	"net/http"
-	"os"
 	"strings"
-	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
 	"github.com/golang-jwt/jwt/v5"
 )
 
-var jwtSecret []byte
-
 type ctxKey int
 
-const claimsKey ctxKey = iota
-
-type Claims struct {
-	UserID string `json:"uid"`
-	Email  string `json:"email"`
-	jwt.RegisteredClaims
-}
+const (
+	claimsKey ctxKey = iota
+	apiKeyKey
+)
 
-func Auth(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Lazy-load JWT secret on first use (after .env is loaded)
-		if jwtSecret == nil {
-			jwtSecret = mustJWTSecret()
-		}
+// Claims son los claims del access token del usuario autenticado
+type Claims = platform.JWTClaims
 
-		authz := r.Header.Get("Authorization")
-		parts := strings.SplitN(authz, " ", 2)
-		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			http.Error(w, "invalid Authorization header", http.StatusUnauthorized)
-			return
-		}
-		raw := parts[1]
+// Auth middleware autentica al usuario con un access token (firma, kid, issuer
+// y audience) o con una API key personal. Con API key los claims se rellenan a
+// partir de su usuario y la clave queda en el contexto (GetAPIKey).
+func Auth(keys *platform.KeyRing, apiKeys *apikey.Service) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			authz := r.Header.Get("Authorization")
+			parts := strings.SplitN(authz, " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid Authorization header")
+				return
+			}
 
-		parser := jwt.NewParser(
-			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
-			jwt.WithLeeway(30*time.Second),
-		)
+			if strings.HasPrefix(parts[1], platform.APIKeyPrefix) {
+				key, err := apiKeys.Authenticate(r.Context(), parts[1])
+				switch {
+				case errors.Is(err, apikey.ErrAPIKeyExpired):
+					apperr.Respond(w, r, apperr.Unauthenticated, "api key expired")
+					return
+				case errors.Is(err, apikey.ErrAPIKeyRevoked), errors.Is(err, apikey.ErrInvalidAPIKey):
+					apperr.Respond(w, r, apperr.Unauthenticated, "invalid api key")
+					return
+				case err != nil:
+					logging.FromContext(r.Context()).Error("api key lookup", "error", err)
+					apperr.Respond(w, r, apperr.Internal, "internal error")
+					return
+				}
 
-		token, err := parser.ParseWithClaims(raw, &Claims{}, func(t *jwt.Token) (interface{}, error) {
-			if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
-			}
-			return jwtSecret, nil
-		})
-		if err != nil {
-			// Distingue expirado para mejor DX
-			if errors.Is(err, jwt.ErrTokenExpired) {
-				http.Error(w, "token expired", http.StatusUnauthorized)
+				logging.AddAttrs(r.Context(), "user_id", key.UserID, "api_key_id", key.ID)
+				ctx := context.WithValue(r.Context(), claimsKey, &Claims{UserID: key.UserID, Email: key.UserEmail})
+				ctx = context.WithValue(ctx, apiKeyKey, key)
+				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
-			log.Printf("[AUTH] jwt parse/validate error: %v", err)
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
-		if !token.Valid {
-			http.Error(w, "invalid token", http.StatusUnauthorized)
-			return
-		}
 
-		claims, ok := token.Claims.(*Claims)
-		if !ok {
-			http.Error(w, "invalid claims", http.StatusUnauthorized)
-			return
-		}
-
-		// (Opcional extra) valida issuer/audience si los usas:
-		// if claims.Issuer != "autohost-cloud" { ... }
+			claims, err := keys.ParseAccessToken(parts[1])
+			if err != nil {
+				// Distingue expirado para mejor DX
+				if errors.Is(err, jwt.ErrTokenExpired) {
+					apperr.Respond(w, r, apperr.Unauthenticated, "token expired")
+					return
+				}
+				logging.FromContext(r.Context()).Info("reject access token", "error", err)
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid token")
+				return
+			}
 
-		ctx := context.WithValue(r.Context(), claimsKey, claims)
-		next.ServeHTTP(w, r.WithContext(ctx))
-	})
+			logging.AddAttrs(r.Context(), "user_id", claims.UserID)
+			ctx := context.WithValue(r.Context(), claimsKey, claims)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
 }
 
 func GetClaims(ctx context.Context) *Claims {
@@ -89,10 +86,25 @@ func GetClaims(ctx context.Context) *Claims {
 	return nil
 }
 
-func mustJWTSecret() []byte {
-	s := strings.TrimSpace(os.Getenv("JWT_SECRET"))
-	if s == "" {
-		log.Fatal("JWT_SECRET missing")
+// GetAPIKey obtiene la API key con la que se autenticó la petición, o nil si
+// se usó un access token
+func GetAPIKey(ctx context.Context) *apikey.APIKey {
+	if v := ctx.Value(apiKeyKey); v != nil {
+		if k, ok := v.(*apikey.APIKey); ok {
+			return k
+		}
 	}
-	return []byte(s) // mismo tratamiento que al firmar
+	return nil
+}
+
+// DenyAPIKeys rechaza peticiones autenticadas con API key. Protege la gestión
+// de credenciales y sesiones, que exige una sesión interactiva.
+func DenyAPIKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if GetAPIKey(r.Context()) != nil {
+			apperr.Respond(w, r, apperr.PermissionDenied, "api keys cannot access this endpoint")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
 }
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/middleware"
+
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// RequestLogger pone en el contexto un logger con el id de la petición (debe
+// ir después de chimiddleware.RequestID) y escribe una línea por petición al
+// terminar, con los atributos que hayan añadido Auth o NodeAuth por el camino.
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := logging.NewContext(r.Context(), slog.Default().With(
+			"request_id", chimiddleware.GetReqID(r.Context()),
+			"method", r.Method,
+			"path", r.URL.Path,
+		))
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r.WithContext(ctx))
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		lvl := slog.LevelInfo
+		switch {
+		case status >= 500:
+			lvl = slog.LevelError
+		case status >= 400:
+			lvl = slog.LevelWarn
+		}
+		logging.FromContext(ctx).Log(ctx, lvl, "http request",
+			"status", status,
+			"bytes", ww.BytesWritten(),
+			"duration_ms", time.Since(start).Milliseconds(),
+			"remote_addr", r.RemoteAddr,
+		)
+	})
+}
@@ -2,11 +2,12 @@ package middleware
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"strings"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/arturo/autohost-cloud-api/internal/platform"
 )
 
@@ -21,14 +22,14 @@ func NodeAuth(nodeTokenService *nodetoken.Service) func(http.Handler) http.Handl
 			authz := r.Header.Get("Authorization")
 			parts := strings.SplitN(authz, " ", 2)
 			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-				http.Error(w, "invalid Authorization header", http.StatusUnauthorized)
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid Authorization header")
 				return
 			}
 			plainToken := parts[1]
 
 			// Validar formato del token
 			if !strings.HasPrefix(plainToken, platform.TokenApiPrefix) {
-				http.Error(w, "invalid node token format", http.StatusUnauthorized)
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid node token format")
 				return
 			}
 
@@ -36,20 +37,21 @@ func NodeAuth(nodeTokenService *nodetoken.Service) func(http.Handler) http.Handl
 			tokenHash := platform.HashTokenApi(plainToken)
 
 			// Buscar token en BD
-			nodeToken, err := nodeTokenService.FindNodeTokenByHash(tokenHash)
+			nodeToken, err := nodeTokenService.FindNodeTokenByHash(r.Context(), tokenHash)
 			if err != nil {
-				log.Printf("[NODE_AUTH] token not found: %v", err)
-				http.Error(w, "invalid node token", http.StatusUnauthorized)
+				logging.FromContext(r.Context()).Warn("node token lookup failed", "error", err)
+				apperr.Respond(w, r, apperr.Unauthenticated, "invalid node token")
 				return
 			}
 
 			// Verificar que no esté revocado
 			if nodeToken.RevokedAt != nil {
-				http.Error(w, "node token revoked", http.StatusUnauthorized)
+				apperr.Respond(w, r, apperr.Unauthenticated, "node token revoked")
 				return
 			}
 
 			// Agregar información del token al contexto
+			logging.AddAttrs(r.Context(), "node_id", nodeToken.NodeID)
 			ctx := context.WithValue(r.Context(), nodeTokenKey, nodeToken)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
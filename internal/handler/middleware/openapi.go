@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/openapi"
+)
+
+// maxValidatedBody limita lo que se lee para validar; ningún cuerpo de la
+// API se acerca a este tamaño
+const maxValidatedBody = 1 << 20
+
+// ValidateRequest rechaza con 400 los cuerpos JSON que no cumplen el esquema
+// de su operación en la especificación OpenAPI, antes de que lleguen al
+// handler. Las rutas que el documento no conoce pasan sin tocar y las
+// resuelve el router. El cuerpo se restaura para que el handler lo decodifique.
+func ValidateRequest(spec *openapi.Spec) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch {
+				next.ServeHTTP(w, r)
+				return
+			}
+			op, ok := spec.FindOperation(r.Method, r.URL.Path)
+			if !ok || op.RequestBody.JSONSchema() == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxValidatedBody))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					apperr.Respond(w, r, apperr.InvalidArgument, "request body too large")
+					return
+				}
+				apperr.Respond(w, r, apperr.InvalidArgument, "could not read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			if len(bytes.TrimSpace(body)) == 0 {
+				if op.RequestBody.Required {
+					apperr.Respond(w, r, apperr.InvalidArgument, "request body required")
+					return
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			dec := json.NewDecoder(bytes.NewReader(body))
+			dec.UseNumber()
+			var v any
+			if err := dec.Decode(&v); err != nil || dec.More() {
+				apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+				return
+			}
+			if errs := op.RequestBody.JSONSchema().Validate(v); len(errs) > 0 {
+				apperr.Write(w, r, apperr.WithDetails(apperr.InvalidArgument, "request body does not match the schema", errs))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// OrganizationHeader selects the organization a request acts on. When it is
+// absent (and the route has no {orgID} param) the user's personal
+// organization is used.
+const OrganizationHeader = "X-Organization-ID"
+
+type orgCtxKey int
+
+const membershipKey orgCtxKey = iota
+
+// Authorizer resuelve la organización activa de la petición y verifica que el
+// rol del usuario conceda el permiso requerido. Debe ir después de Auth.
+type Authorizer struct {
+	orgService *organization.Service
+	mfaService *mfa.Service
+}
+
+func NewAuthorizer(orgService *organization.Service, mfaService *mfa.Service) *Authorizer {
+	return &Authorizer{orgService: orgService, mfaService: mfaService}
+}
+
+// Require returns a middleware that rejects the request unless the
+// authenticated user holds perm in the active organization and, for API keys,
+// perm is within the key's scopes. Organizations that require MFA reject users
+// without two-factor enabled.
+func (a *Authorizer) Require(perm organization.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims := GetClaims(r.Context())
+			if claims == nil || claims.UserID == "" {
+				apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+				return
+			}
+
+			if key := GetAPIKey(r.Context()); key != nil && !key.Allows(perm) {
+				apperr.Respond(w, r, apperr.PermissionDenied, "api key scope does not allow "+string(perm))
+				return
+			}
+
+			orgID := chi.URLParam(r, "orgID")
+			if orgID == "" {
+				orgID = r.Header.Get(OrganizationHeader)
+			}
+			if orgID != "" {
+				if _, err := uuid.Parse(orgID); err != nil {
+					apperr.Respond(w, r, apperr.NotFound, "organization not found")
+					return
+				}
+			}
+
+			membership, err := a.orgService.Authorize(r.Context(), orgID, claims.UserID, perm)
+			switch {
+			case errors.Is(err, organization.ErrNotMember), errors.Is(err, organization.ErrOrganizationNotFound):
+				apperr.Respond(w, r, apperr.NotFound, "organization not found")
+				return
+			case errors.Is(err, organization.ErrForbidden):
+				apperr.Respond(w, r, apperr.PermissionDenied, "insufficient permissions")
+				return
+			case err != nil:
+				logging.FromContext(r.Context()).Error("authorize", "organization_id", orgID, "error", err)
+				apperr.Respond(w, r, apperr.Internal, "internal error")
+				return
+			}
+
+			if membership.RequireMFA {
+				enabled, err := a.mfaService.IsEnabled(r.Context(), claims.UserID)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("get mfa status", "error", err)
+					apperr.Respond(w, r, apperr.Internal, "internal error")
+					return
+				}
+				if !enabled {
+					apperr.Respond(w, r, apperr.PermissionDenied, "organization requires two-factor authentication")
+					return
+				}
+			}
+
+			ctx := context.WithValue(r.Context(), membershipKey, membership)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// GetMembership obtiene la membresía resuelta por Authorizer.Require
+func GetMembership(ctx context.Context) *organization.Membership {
+	if v := ctx.Value(membershipKey); v != nil {
+		if m, ok := v.(*organization.Membership); ok {
+			return m
+		}
+	}
+	return nil
+}
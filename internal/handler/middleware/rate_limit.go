@@ -0,0 +1,37 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// RateLimitByIP limita las peticiones de cada IP cliente con un token bucket
+// compartido entre réplicas. name separa los buckets de cada ruta. Si el
+// almacén falla la petición pasa: es preferible a dejar fuera a todo el mundo.
+func RateLimitByIP(limiter *ratelimit.Service, name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := limiter.Allow(r.Context(), name+":ip:"+remoteIP(r), limit)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("rate limit store failed, allowing request", "limit", name, "error", err)
+			} else if !res.Allowed {
+				apperr.TooManyRequests(w, r, res.RetryAfter)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// remoteIP devuelve la IP de RemoteAddr (sin puerto); debe ir después de
+// RealIP para respetar X-Forwarded-For de los proxies de confianza.
+func remoteIP(r *http.Request) string {
+	if ip, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return ip
+	}
+	return r.RemoteAddr
+}
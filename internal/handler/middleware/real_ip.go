@@ -0,0 +1,70 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+)
+
+// RealIP reemplaza RemoteAddr por la IP del cliente que indican
+// X-Forwarded-For o X-Real-IP, pero solo si la petición llega de uno de los
+// proxies de trusted: cualquier cliente puede poner esas cabeceras, y usarlas
+// sin más le daría un bucket de rate limit nuevo por petición y una IP falsa
+// en la auditoría. Sin proxies de confianza no hace nada.
+func RealIP(trusted []netip.Prefix) func(http.Handler) http.Handler {
+	isTrusted := func(addr netip.Addr) bool {
+		addr = addr.Unmap()
+		return slices.ContainsFunc(trusted, func(p netip.Prefix) bool { return p.Contains(addr) })
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if peer, ok := parseAddr(r.RemoteAddr); ok && isTrusted(peer) {
+				if ip, ok := forwardedFor(r.Header, isTrusted); ok {
+					r.RemoteAddr = ip.String()
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedFor recorre X-Forwarded-For de derecha a izquierda saltando los
+// proxies de confianza: la primera IP que no lo es la añadió el último proxy
+// nuestro y es la del cliente. Lo que hay a su izquierda lo controla el
+// cliente. Sin X-Forwarded-For usa X-Real-IP.
+func forwardedFor(h http.Header, isTrusted func(netip.Addr) bool) (netip.Addr, bool) {
+	var hops []string
+	for _, v := range h.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(v, ",")...)
+	}
+	var last netip.Addr
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			return netip.Addr{}, false
+		}
+		if !isTrusted(ip) {
+			return ip.Unmap(), true
+		}
+		last = ip.Unmap()
+	}
+	if last.IsValid() {
+		// Toda la cadena son proxies nuestros
+		return last, true
+	}
+	if ip, err := netip.ParseAddr(strings.TrimSpace(h.Get("X-Real-IP"))); err == nil {
+		return ip.Unmap(), true
+	}
+	return netip.Addr{}, false
+}
+
+// parseAddr acepta RemoteAddr con o sin puerto
+func parseAddr(s string) (netip.Addr, bool) {
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		s = host
+	}
+	addr, err := netip.ParseAddr(s)
+	return addr, err == nil
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name       string
+		trusted    []netip.Prefix
+		remoteAddr string
+		xff        []string
+		xRealIP    string
+		want       string
+	}{
+		{"no trusted proxies ignores headers", nil, "203.0.113.7:4321", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7:4321"},
+		{"untrusted peer cannot spoof", trusted, "203.0.113.7:4321", []string{"198.51.100.1"}, "198.51.100.2", "203.0.113.7:4321"},
+		{"trusted proxy", trusted, "10.0.0.2:4321", []string{"198.51.100.1"}, "", "198.51.100.1"},
+		{"client-supplied hops are skipped", trusted, "10.0.0.2:4321", []string{"1.2.3.4, 198.51.100.1"}, "", "198.51.100.1"},
+		{"chain of trusted proxies", trusted, "10.0.0.2:4321", []string{"198.51.100.1, 10.0.0.9", "10.0.0.3"}, "", "198.51.100.1"},
+		{"only trusted hops", trusted, "10.0.0.2:4321", []string{"10.0.0.9"}, "", "10.0.0.9"},
+		{"x-real-ip from trusted proxy", trusted, "10.0.0.2:4321", nil, "198.51.100.2", "198.51.100.2"},
+		{"garbage keeps the peer", trusted, "10.0.0.2:4321", []string{"not-an-ip"}, "", "10.0.0.2:4321"},
+		{"ipv4-mapped peer", trusted, "[::ffff:10.0.0.2]:4321", []string{"198.51.100.1"}, "", "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			h := RealIP(tt.trusted)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got = r.RemoteAddr
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, v := range tt.xff {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			if tt.xRealIP != "" {
+				req.Header.Set("X-Real-IP", tt.xRealIP)
+			}
+			h.ServeHTTP(httptest.NewRecorder(), req)
+			if got != tt.want {
+				t.Errorf("RemoteAddr = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
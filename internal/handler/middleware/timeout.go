@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Timeout limita cada petición a d: cuando vence, o cuando el cliente se va,
+// el contexto se cancela y las consultas en curso se abortan. Las
+// actualizaciones a WebSocket quedan fuera porque la conexión dura mucho más;
+// sus mensajes llevan su propio plazo. Con d <= 0 no hace nada.
+func Timeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), d)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
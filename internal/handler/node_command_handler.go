@@ -2,11 +2,16 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
+	"errors"
 	"net/http"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -14,14 +19,16 @@ import (
 //   - Node-facing routes (nodeAuth): register / delete / list own commands
 //   - User-facing routes (userAuth): list commands for a given node (dashboard)
 type NodeCommandHandler struct {
-	service *nodecommand.Service
+	service      *nodecommand.Service
+	nodeService  *node.Service
+	auditService *audit.Service
 }
 
-func NewNodeCommandHandler(service *nodecommand.Service) *NodeCommandHandler {
-	return &NodeCommandHandler{service: service}
+func NewNodeCommandHandler(service *nodecommand.Service, nodeService *node.Service, auditService *audit.Service) *NodeCommandHandler {
+	return &NodeCommandHandler{service: service, nodeService: nodeService, auditService: auditService}
 }
 
-func (h *NodeCommandHandler) Routes(nodeAuthMiddleware func(http.Handler) http.Handler) chi.Router {
+func (h *NodeCommandHandler) Routes(nodeAuthMiddleware, authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
 	r := chi.NewRouter()
 
 	// Node-facing endpoints (agent calls these)
@@ -33,7 +40,7 @@ func (h *NodeCommandHandler) Routes(nodeAuthMiddleware func(http.Handler) http.H
 	})
 
 	// User-facing endpoints (dashboard calls these) – authenticated via JWT
-	r.With(middleware.Auth).Get("/node/{nodeID}", h.ListByNodeID)
+	r.With(authMiddleware, authz.Require(organization.PermNodesRead)).Get("/node/{nodeID}", h.ListByNodeID)
 
 	return r
 }
@@ -51,13 +58,13 @@ type registerCommandRequest struct {
 func (h *NodeCommandHandler) Register(w http.ResponseWriter, r *http.Request) {
 	nodeToken := middleware.GetNodeToken(r.Context())
 	if nodeToken == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
 	var req registerCommandRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "invalid request body", http.StatusBadRequest)
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
 		return
 	}
 
@@ -69,13 +76,20 @@ func (h *NodeCommandHandler) Register(w http.ResponseWriter, r *http.Request) {
 		ScriptPath:  req.ScriptPath,
 	}
 
-	saved, err := h.service.Register(cmd)
+	saved, err := h.service.Register(r.Context(), cmd)
 	if err != nil {
-		log.Printf("[ERROR] register node command: %v", err)
-		http.Error(w, "could not register command", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("register node command", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not register command")
 		return
 	}
 
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionCommandRegister,
+		TargetType: "node_command",
+		TargetID:   saved.ID,
+		Metadata:   map[string]any{"name": saved.Name, "type": string(saved.Type), "script_path": saved.ScriptPath},
+	})
+
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(saved)
@@ -86,14 +100,14 @@ func (h *NodeCommandHandler) Register(w http.ResponseWriter, r *http.Request) {
 func (h *NodeCommandHandler) ListForNode(w http.ResponseWriter, r *http.Request) {
 	nodeToken := middleware.GetNodeToken(r.Context())
 	if nodeToken == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
-	cmds, err := h.service.ListByNode(nodeToken.NodeID)
+	cmds, err := h.service.ListByNode(r.Context(), nodeToken.NodeID)
 	if err != nil {
-		log.Printf("[ERROR] list node commands: %v", err)
-		http.Error(w, "could not list commands", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("list node commands", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list commands")
 		return
 	}
 
@@ -104,27 +118,60 @@ func (h *NodeCommandHandler) ListForNode(w http.ResponseWriter, r *http.Request)
 // Delete removes a command by ID (must belong to the authenticated node).
 // DELETE /v1/node-commands/{id}
 func (h *NodeCommandHandler) Delete(w http.ResponseWriter, r *http.Request) {
-	id := chi.URLParam(r, "id")
-	if err := h.service.Delete(id); err != nil {
-		if err == nodecommand.ErrCommandNotFound {
-			http.Error(w, "command not found", http.StatusNotFound)
+	nodeToken := middleware.GetNodeToken(r.Context())
+	if nodeToken == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "command")
+	if !ok {
+		return
+	}
+	if err := h.service.Delete(r.Context(), id, nodeToken.NodeID); err != nil {
+		if errors.Is(err, nodecommand.ErrCommandNotFound) {
+			apperr.Respond(w, r, apperr.NotFound, "command not found")
 			return
 		}
-		log.Printf("[ERROR] delete node command: %v", err)
-		http.Error(w, "could not delete command", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("delete node command", "error", err)
+		recordAudit(h.auditService, r, audit.Event{
+			Action:     audit.ActionCommandDelete,
+			TargetType: "node_command",
+			TargetID:   id,
+			Outcome:    audit.OutcomeFailure,
+		})
+		apperr.Respond(w, r, apperr.Internal, "could not delete command")
 		return
 	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionCommandDelete,
+		TargetType: "node_command",
+		TargetID:   id,
+	})
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // ListByNodeID returns all commands for a specific node (dashboard / user auth).
 // GET /v1/node-commands/node/{nodeID}
 func (h *NodeCommandHandler) ListByNodeID(w http.ResponseWriter, r *http.Request) {
-	nodeID := chi.URLParam(r, "nodeID")
-	cmds, err := h.service.ListByNode(nodeID)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	nodeID, ok := uuidParam(w, r, "nodeID", "node")
+	if !ok {
+		return
+	}
+	if _, err := h.nodeService.GetForOrganization(r.Context(), nodeID, membership.OrganizationID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	cmds, err := h.service.ListByNode(r.Context(), nodeID)
 	if err != nil {
-		log.Printf("[ERROR] list commands by nodeID: %v", err)
-		http.Error(w, "could not list commands", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("list commands by node", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list commands")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+)
+
+func TestNodeCommandDeleteIsScopedToNode(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	enroll := func(hostname string) *agentsim.Enrollment {
+		t.Helper()
+		e, err := agentsim.Enroll(ctx, env.srv.Client(), env.srv.URL, env.newEnrollToken(t, time.Now().Add(time.Hour)),
+			agentsim.NodeInfo{Hostname: hostname, OS: "linux", Arch: "amd64", VersionAgent: "1.0.0"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e
+	}
+	owner, other := enroll("web-1"), enroll("web-2")
+	cmd, err := env.cmds.Register(ctx, &nodecommand.NodeCommand{NodeID: owner.NodeID, Name: "backup"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	del := func(token string) int {
+		t.Helper()
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, env.srv.URL+"/v1/node-commands/"+cmd.ID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := env.srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := del(other.APIToken); status != http.StatusNotFound {
+		t.Errorf("DELETE by another node: status %d, want 404", status)
+	}
+	if cmds, err := env.cmds.ListByNode(ctx, owner.NodeID); err != nil || len(cmds) != 1 {
+		t.Fatalf("commands after foreign DELETE = %d, %v; want 1", len(cmds), err)
+	}
+	if status := del(owner.APIToken); status != http.StatusNoContent {
+		t.Errorf("DELETE by the owning node: status %d, want 204", status)
+	}
+	if err := env.cmds.Delete(ctx, cmd.ID, owner.NodeID); !errors.Is(err, nodecommand.ErrCommandNotFound) {
+		t.Errorf("command still exists: %v", err)
+	}
+}
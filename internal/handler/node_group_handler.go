@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/go-chi/chi/v5"
+)
+
+// NodeGroupHandler manages the node groups of an organization. Membership
+// changes alter the scripts assigned to a node, so the affected nodes get a
+// new manifest.
+type NodeGroupHandler struct {
+	service       *nodegroup.Service
+	scriptService *script.Service
+	auditService  *audit.Service
+	syncer        ScriptSyncer
+}
+
+func NewNodeGroupHandler(service *nodegroup.Service, scriptService *script.Service, auditService *audit.Service, syncer ScriptSyncer) *NodeGroupHandler {
+	return &NodeGroupHandler{service: service, scriptService: scriptService, auditService: auditService, syncer: syncer}
+}
+
+func (h *NodeGroupHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
+	r := chi.NewRouter()
+	r.Use(authMiddleware)
+
+	read := r.With(authz.Require(organization.PermNodesRead))
+	write := r.With(authz.Require(organization.PermNodesWrite))
+	write.Post("/", h.Create)
+	read.Get("/", h.List)
+	read.Get("/{id}", h.Get)
+	write.Delete("/{id}", h.Delete)
+	read.Get("/{id}/nodes", h.ListNodes)
+	write.Put("/{id}/nodes/{nodeID}", h.AddNode)
+	write.Delete("/{id}/nodes/{nodeID}", h.RemoveNode)
+	return r
+}
+
+type createNodeGroupRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Create adds an empty group to the organization.
+// POST /v1/node-groups
+func (h *NodeGroupHandler) Create(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	var req createNodeGroupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	g, err := h.service.Create(r.Context(), membership.OrganizationID, req.Name, req.Description)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionNodeGroupCreate,
+		TargetType: "node_group",
+		TargetID:   g.ID,
+		Metadata:   map[string]any{"name": g.Name},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(g)
+}
+
+// List returns the groups of the organization ordered by name.
+// GET /v1/node-groups
+func (h *NodeGroupHandler) List(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	groups, err := h.service.List(r.Context(), membership.OrganizationID)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if groups == nil {
+		groups = []*nodegroup.Group{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"groups": groups})
+}
+
+// Get returns a group.
+// GET /v1/node-groups/{id}
+func (h *NodeGroupHandler) Get(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "node group")
+	if !ok {
+		return
+	}
+	g, err := h.service.Get(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(g)
+}
+
+// Delete removes a group and the script assignments made to it.
+// DELETE /v1/node-groups/{id}
+func (h *NodeGroupHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "node group")
+	if !ok {
+		return
+	}
+	nodeIDs, err := h.service.Delete(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionNodeGroupDelete,
+		TargetType: "node_group",
+		TargetID:   id,
+	})
+	syncNodes(r, h.scriptService, h.syncer, nodeIDs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListNodes returns the IDs of the nodes in a group.
+// GET /v1/node-groups/{id}/nodes
+func (h *NodeGroupHandler) ListNodes(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "node group")
+	if !ok {
+		return
+	}
+	nodeIDs, err := h.service.Nodes(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if nodeIDs == nil {
+		nodeIDs = []string{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"node_ids": nodeIDs})
+}
+
+// AddNode adds a node to a group; adding it again is a no-op.
+// PUT /v1/node-groups/{id}/nodes/{nodeID}
+func (h *NodeGroupHandler) AddNode(w http.ResponseWriter, r *http.Request) {
+	h.changeMembership(w, r, audit.ActionNodeGroupAdd, h.service.AddNode)
+}
+
+// RemoveNode takes a node out of a group.
+// DELETE /v1/node-groups/{id}/nodes/{nodeID}
+func (h *NodeGroupHandler) RemoveNode(w http.ResponseWriter, r *http.Request) {
+	h.changeMembership(w, r, audit.ActionNodeGroupRemove, h.service.RemoveNode)
+}
+
+// changeMembership applies change to the group and node named in the URL,
+// records action and resyncs the node's scripts.
+func (h *NodeGroupHandler) changeMembership(w http.ResponseWriter, r *http.Request, action string, change func(ctx context.Context, orgID, id, nodeID string) error) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "node group")
+	if !ok {
+		return
+	}
+	nodeID, ok := uuidParam(w, r, "nodeID", "node")
+	if !ok {
+		return
+	}
+	if err := change(r.Context(), membership.OrganizationID, id, nodeID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     action,
+		TargetType: "node_group",
+		TargetID:   id,
+		Metadata:   map[string]any{"node_id": nodeID},
+	})
+	syncNodes(r, h.scriptService, h.syncer, []string{nodeID})
+	w.WriteHeader(http.StatusNoContent)
+}
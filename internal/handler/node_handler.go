@@ -2,11 +2,13 @@ package handler
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -18,44 +20,63 @@ func NewNodeHandler(service *node.Service) *NodeHandler {
 	return &NodeHandler{service: service}
 }
 
-func (h *NodeHandler) Routes() chi.Router {
+func (h *NodeHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
 	r := chi.NewRouter()
-	r.With(middleware.Auth).Get("/", h.List)
-	r.With(middleware.Auth).Get("/with-metrics", h.ListWithMetrics)
+	r.Use(authMiddleware)
+	r.With(authz.Require(organization.PermNodesRead)).Get("/", h.List)
+	r.With(authz.Require(organization.PermNodesRead)).Get("/with-metrics", h.ListWithMetrics)
 	return r
 }
 
+type nodePage struct {
+	Nodes      []*node.Node `json:"nodes"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// List devuelve los nodos de la organización activa con paginación por cursor
+// GET /v1/nodes?hostname=&sort=&limit=&cursor=
 func (h *NodeHandler) List(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaims(r.Context())
-	if claims == nil || claims.UserID == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	f := node.ListFilter{
+		OrganizationID: membership.OrganizationID,
+		Hostname:       r.URL.Query().Get("hostname"),
+	}
+	var ok bool
+	if f.Sort, f.After, f.Limit, ok = pageParams(w, r, node.DefaultSort, node.SortFields); !ok {
 		return
 	}
 
-	// Usar el servicio para obtener nodos
-	nodes, err := h.service.GetByOwner(claims.UserID)
+	nodes, next, err := h.service.List(r.Context(), f)
 	if err != nil {
-		log.Printf("[ERROR] list nodes: %v", err)
-		http.Error(w, "could not list nodes", http.StatusInternalServerError)
+		apperr.Write(w, r, err)
 		return
 	}
 
+	page := nodePage{Nodes: nodes, NextCursor: nextCursor(next)}
+	if page.Nodes == nil {
+		page.Nodes = []*node.Node{}
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(nodes)
+	json.NewEncoder(w).Encode(page)
 }
 
 func (h *NodeHandler) ListWithMetrics(w http.ResponseWriter, r *http.Request) {
-	claims := middleware.GetClaims(r.Context())
-	if claims == nil || claims.UserID == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 
 	// Obtener nodos con sus últimas métricas
-	nodes, err := h.service.GetByOwnerWithMetrics(claims.UserID)
+	nodes, err := h.service.GetByOrganizationWithMetrics(r.Context(), membership.OrganizationID)
 	if err != nil {
-		log.Printf("[ERROR] list nodes with metrics: %v", err)
-		http.Error(w, "could not list nodes", http.StatusInternalServerError)
+		logging.FromContext(r.Context()).Error("list nodes with metrics", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list nodes")
 		return
 	}
 
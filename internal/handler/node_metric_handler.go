@@ -2,12 +2,13 @@ package handler
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
 	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
 	"github.com/go-chi/chi/v5"
 )
 
@@ -36,14 +37,13 @@ func (h *NodeMetricHandler) PostMetrics(w http.ResponseWriter, r *http.Request)
 	nodeToken := middleware.GetNodeToken(r.Context())
 
 	if nodeToken == nil {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 		return
 	}
 	var nodeMetrics nodemetric.CreateNodeMetricRequest
 	if err := json.NewDecoder(r.Body).Decode(&nodeMetrics); err != nil {
-		fmt.Println("Error decoding JSON:", &nodeMetrics)
-		http.Error(w, "bad json", http.StatusBadRequest)
-		fmt.Println(err)
+		logging.FromContext(r.Context()).Warn("decode node metrics", "error", err)
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
 		return
 	}
 
@@ -51,8 +51,8 @@ func (h *NodeMetricHandler) PostMetrics(w http.ResponseWriter, r *http.Request)
 	nodeMetrics.CollectedAt = time.Now()
 
 	// Usar el servicio para guardar las métricas
-	if _, err := h.service.StoreNodeMetric(&nodeMetrics); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	if _, err := h.service.StoreNodeMetric(r.Context(), &nodeMetrics); err != nil {
+		apperr.Write(w, r, err)
 		return
 	}
 
@@ -63,14 +63,14 @@ func (h *NodeMetricHandler) PostMetrics(w http.ResponseWriter, r *http.Request)
 // func (h *NodeMetricHandler) ListMetrics(w http.ResponseWriter, r *http.Request) {
 // 	claims := middleware.GetClaims(r.Context())
 // 	if claims == nil || claims.UserID == "" {
-// 		http.Error(w, "unauthorized", http.StatusUnauthorized)
+// 		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
 // 		return
 // 	}
 
 // 	// Usar el servicio para obtener métricas
 // 	metrics, err := h.service.GetMetricsByNodeID()
 // 	if err != nil {
-// 		http.Error(w, "could not list metrics", http.StatusInternalServerError)
+// 		apperr.Respond(w, r, apperr.Internal, "could not list metrics")
 // 		return
 // 	}
 
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// oidcBrowserCookie liga el login OIDC al navegador que lo inició
+const oidcBrowserCookie = "autohost_oidc"
+
+// oidcCookie devuelve la cookie del navegador con value; maxAge negativo la
+// borra
+func oidcCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcBrowserCookie,
+		Value:    value,
+		Path:     "/v1/auth/oidc",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// OIDCStart inicia el login con el proveedor OIDC. El frontend redirige al
+// usuario a authorization_url; el proveedor vuelve a OIDC_REDIRECT_URL con
+// code y state, que el frontend envía a /oidc/callback desde el mismo
+// navegador, con la cookie que fija esta respuesta.
+// GET /v1/auth/oidc/start
+func (h *AuthHandler) OIDCStart(w http.ResponseWriter, r *http.Request) {
+	authURL, browserSecret, err := h.oidc.Start(r.Context())
+	if errors.Is(err, platform.ErrOIDCDisabled) {
+		apperr.Write(w, r, err)
+		return
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("start oidc login", "error", err)
+		apperr.Respond(w, r, apperr.UpstreamError, "identity provider unavailable")
+		return
+	}
+
+	http.SetCookie(w, oidcCookie(browserSecret, int(oidc.StateTTL.Seconds())))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"authorization_url": authURL})
+}
+
+// OIDCCallback completa el login OIDC y responde igual que Login
+// POST /v1/auth/oidc/callback
+func (h *AuthHandler) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	var in struct {
+		Code  string `json:"code"`
+		State string `json:"state"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&in); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	var browserSecret string
+	if c, err := r.Cookie(oidcBrowserCookie); err == nil {
+		browserSecret = c.Value
+	}
+	// El state es de un solo uso: la cookie ya no sirve, acierte o no
+	http.SetCookie(w, oidcCookie("", -1))
+
+	user, provisioned, err := h.oidc.Callback(r.Context(), in.Code, in.State, browserSecret)
+	switch {
+	case errors.Is(err, platform.ErrOIDCDisabled):
+		apperr.Write(w, r, err)
+		return
+	case errors.Is(err, oidc.ErrInvalidState),
+		errors.Is(err, oidc.ErrEmailNotVerified),
+		errors.Is(err, platform.ErrOIDCInvalidToken),
+		errors.Is(err, auth.ErrInvalidCredentials):
+		recordAudit(h.auditService, r, audit.Event{
+			ActorType: audit.ActorUser,
+			Action:    audit.ActionAuthLogin,
+			Outcome:   audit.OutcomeFailure,
+			Metadata:  map[string]any{"sso": "oidc", "reason": err.Error()},
+		})
+		apperr.Respond(w, r, apperr.Unauthenticated, "single sign-on failed")
+		return
+	case err != nil:
+		logging.FromContext(r.Context()).Error("oidc callback", "error", err)
+		apperr.Respond(w, r, apperr.UpstreamError, "identity provider unavailable")
+		return
+	}
+
+	if provisioned {
+		orgName := user.Email
+		if user.Name != nil && *user.Name != "" {
+			orgName = *user.Name
+		}
+		if _, err := h.orgService.EnsurePersonal(r.Context(), user.ID, orgName); err != nil {
+			logging.FromContext(r.Context()).Error("create personal organization", "user_id", user.ID, "error", err)
+			apperr.Respond(w, r, apperr.Internal, "internal error")
+			return
+		}
+		h.recordPersonalEvent(r, user.ID, audit.Event{
+			Action:     audit.ActionAuthRegister,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Metadata:   map[string]any{"sso": "oidc"},
+		})
+	}
+
+	h.completeLogin(w, r, user, map[string]any{"sso": "oidc"})
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/openapi"
+)
+
+// OpenAPIHandler publica la especificación OpenAPI 3.1 de la API, la misma
+// contra la que se validan los cuerpos de las peticiones.
+type OpenAPIHandler struct{}
+
+func NewOpenAPIHandler() *OpenAPIHandler {
+	return &OpenAPIHandler{}
+}
+
+func (h *OpenAPIHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	w.Write(openapi.Document())
+}
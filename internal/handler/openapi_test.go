@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/openapi"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// newTestRouter builds the router without a database; only routes that are
+// rejected before touching a repository can be exercised through it.
+func newTestRouter(t *testing.T) http.Handler {
+	t.Helper()
+	return NewRouter(&Config{Keys: platform.NewKeyRing(platform.JWTConfig{})}).HTTP
+}
+
+// routerOperations lists every method and path registered in the router, with
+// the trailing slash of mounted subrouters removed as in the spec.
+func routerOperations(t *testing.T, h http.Handler) []string {
+	t.Helper()
+	var ops []string
+	err := chi.Walk(h.(chi.Routes), func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		ops = append(ops, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestOpenAPISpecCoversEveryRoute(t *testing.T) {
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := routerOperations(t, newTestRouter(t))
+
+	for _, op := range ops {
+		method, route, _ := strings.Cut(op, " ")
+		if !spec.Has(method, route) {
+			t.Errorf("route %s is missing from internal/openapi/openapi.json", op)
+		}
+	}
+	for route, methods := range spec.Paths {
+		for method := range methods {
+			if op := strings.ToUpper(method) + " " + route; !slices.Contains(ops, op) {
+				t.Errorf("spec documents %s, which the router does not serve", op)
+			}
+		}
+	}
+}
+
+func TestValidateRequestRejectsInvalidBodies(t *testing.T) {
+	h := newTestRouter(t)
+
+	tests := []struct {
+		name       string
+		path       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"enroll without token", "/v1/enrollments/enroll", `{"hostname":"web-1"}`, http.StatusBadRequest, "body.enroll_token: is required"},
+		{"enroll with wrong type", "/v1/enrollments/enroll", `{"enroll_token":"t","hostname":42}`, http.StatusBadRequest, "body.hostname: must be of type string"},
+		{"dispatch with bad node id", "/v1/jobs", `{"node_id":"nope","command_name":"uptime"}`, http.StatusBadRequest, "body.node_id: must be a UUID"},
+		{"dispatch with unknown type", "/v1/jobs", `{"node_id":"6f1c1f3e-8a55-4c7e-9d55-2a4f7f0f3b10","command_name":"uptime","command_type":"shell"}`, http.StatusBadRequest, "body.command_type: must be one of [default custom]"},
+		{"malformed json", "/v1/jobs", `{"node_id":`, http.StatusBadRequest, ""},
+		{"empty required body", "/v1/jobs", ``, http.StatusBadRequest, ""},
+		{"valid dispatch reaches auth", "/v1/jobs", `{"node_id":"6f1c1f3e-8a55-4c7e-9d55-2a4f7f0f3b10","command_name":"uptime"}`, http.StatusUnauthorized, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantError == "" {
+				return
+			}
+			var p apperr.Problem
+			if err := json.NewDecoder(rec.Body).Decode(&p); err != nil {
+				t.Fatal(err)
+			}
+			if p.Code != apperr.InvalidArgument || !slices.Contains(p.Errors, tt.wantError) {
+				t.Errorf("problem = %+v, want error %q", p, tt.wantError)
+			}
+		})
+	}
+}
+
+func TestOpenAPIDocumentIsServed(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusOK || doc.OpenAPI != "3.1.0" {
+		t.Errorf("GET /openapi.json = %d %q", rec.Code, doc.OpenAPI)
+	}
+}
@@ -0,0 +1,232 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/go-chi/chi/v5"
+)
+
+// OrganizationHandler manages organizations and their members.
+type OrganizationHandler struct {
+	service      *organization.Service
+	mfaService   *mfa.Service
+	auditService *audit.Service
+}
+
+func NewOrganizationHandler(service *organization.Service, mfaService *mfa.Service, auditService *audit.Service) *OrganizationHandler {
+	return &OrganizationHandler{service: service, mfaService: mfaService, auditService: auditService}
+}
+
+func (h *OrganizationHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
+	r := chi.NewRouter()
+	r.Use(authMiddleware)
+	r.Get("/", h.List)
+	r.With(middleware.DenyAPIKeys).Post("/", h.Create)
+	r.With(authz.Require(organization.PermOrgManage)).Patch("/{orgID}", h.Update)
+
+	r.Route("/{orgID}/members", func(r chi.Router) {
+		r.With(authz.Require(organization.PermMembersRead)).Get("/", h.ListMembers)
+		r.With(authz.Require(organization.PermMembersManage)).Patch("/{userID}", h.UpdateMember)
+		// Any member may leave; the service checks members:manage for others.
+		r.With(authz.Require(organization.PermMembersRead)).Delete("/{userID}", h.RemoveMember)
+	})
+	return r
+}
+
+// List returns the organizations the authenticated user belongs to.
+// GET /v1/organizations
+func (h *OrganizationHandler) List(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil || claims.UserID == "" {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	orgs, err := h.service.ListForUser(r.Context(), claims.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list organizations", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list organizations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(orgs)
+}
+
+// Create creates a team organization owned by the authenticated user.
+// POST /v1/organizations
+func (h *OrganizationHandler) Create(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil || claims.UserID == "" {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Name == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	org, err := h.service.Create(r.Context(), req.Name, claims.UserID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("create organization", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not create organization")
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		OrganizationID: &org.ID,
+		Action:         audit.ActionOrganizationCreate,
+		TargetType:     "organization",
+		TargetID:       org.ID,
+		Metadata:       map[string]any{"name": org.Name},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(org)
+}
+
+// Update changes organization settings.
+// PATCH /v1/organizations/{orgID}
+func (h *OrganizationHandler) Update(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var req struct {
+		RequireMFA *bool `json:"require_mfa"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RequireMFA == nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	// Evita que quien activa el requisito se quede fuera de la organización
+	if *req.RequireMFA {
+		enabled, err := h.mfaService.IsEnabled(r.Context(), membership.UserID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("get mfa status", "member_id", membership.UserID, "error", err)
+			apperr.Respond(w, r, apperr.Internal, "internal error")
+			return
+		}
+		if !enabled {
+			apperr.Respond(w, r, apperr.FailedPrecondition, "enable two-factor authentication on your account first")
+			return
+		}
+	}
+
+	org, err := h.service.SetRequireMFA(r.Context(), membership, *req.RequireMFA)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionOrganizationUpdate,
+		TargetType: "organization",
+		TargetID:   org.ID,
+		Metadata:   map[string]any{"require_mfa": org.RequireMFA},
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(org)
+}
+
+// ListMembers returns the members of an organization.
+// GET /v1/organizations/{orgID}/members
+func (h *OrganizationHandler) ListMembers(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	members, err := h.service.ListMembers(r.Context(), membership.OrganizationID)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list members", "error", err)
+		apperr.Respond(w, r, apperr.Internal, "could not list members")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(members)
+}
+
+// UpdateMember changes the role of a member.
+// PATCH /v1/organizations/{orgID}/members/{userID}
+func (h *OrganizationHandler) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var req struct {
+		Role organization.Role `json:"role"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	userID, ok := uuidParam(w, r, "userID", "member")
+	if !ok {
+		return
+	}
+	err := h.service.ChangeRole(r.Context(), membership, userID, req.Role)
+	h.recordMemberEvent(r, audit.ActionMemberRoleChange, userID, err, map[string]any{"role": string(req.Role)})
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RemoveMember removes a member (or the caller) from the organization.
+// DELETE /v1/organizations/{orgID}/members/{userID}
+func (h *OrganizationHandler) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	userID, ok := uuidParam(w, r, "userID", "member")
+	if !ok {
+		return
+	}
+	err := h.service.RemoveMember(r.Context(), membership, userID)
+	h.recordMemberEvent(r, audit.ActionMemberRemove, userID, err, nil)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OrganizationHandler) recordMemberEvent(r *http.Request, action, userID string, err error, metadata map[string]any) {
+	outcome := audit.OutcomeSuccess
+	if err != nil {
+		outcome = audit.OutcomeFailure
+	}
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     action,
+		TargetType: "user",
+		TargetID:   userID,
+		Outcome:    outcome,
+		Metadata:   metadata,
+	})
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// pageParams reads the limit, sort and cursor query parameters shared by the
+// keyset-paginated lists. It writes a 400 and returns false when one is invalid.
+func pageParams(w http.ResponseWriter, r *http.Request, def platform.Sort, fields []string) (sort platform.Sort, after *platform.Cursor, limit int, ok bool) {
+	q := r.URL.Query()
+
+	sort, err := platform.ParseSort(q.Get("sort"), def, fields...)
+	if err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid sort")
+		return sort, nil, 0, false
+	}
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid limit")
+			return sort, nil, 0, false
+		}
+	}
+	if c := q.Get("cursor"); c != "" {
+		if after, err = platform.DecodeCursor(c); err != nil || after.Sort != sort.String() {
+			apperr.Respond(w, r, apperr.InvalidArgument, "invalid cursor")
+			return sort, nil, 0, false
+		}
+	}
+	return sort, after, limit, true
+}
+
+// nextCursor encodes the cursor of the next page, or "" on the last page.
+func nextCursor(c *platform.Cursor) string {
+	if c == nil {
+		return ""
+	}
+	return c.Encode()
+}
@@ -0,0 +1,21 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// uuidParam lee el parámetro de ruta name. Un valor que no es un UUID no
+// puede existir, así que responde 404 "<resource> not found" en vez de dejar
+// que Postgres falle al convertirlo.
+func uuidParam(w http.ResponseWriter, r *http.Request, name, resource string) (string, bool) {
+	v := chi.URLParam(r, name)
+	if _, err := uuid.Parse(v); err != nil {
+		apperr.Respond(w, r, apperr.NotFound, resource+" not found")
+		return "", false
+	}
+	return v, true
+}
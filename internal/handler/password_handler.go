@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+)
+
+// VerifyEmail canjea el token del email de verificación
+func (h *AuthHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "token required")
+		return
+	}
+
+	userID, err := h.service.VerifyEmail(r.Context(), req.Token)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordUserEvent(r, userID, audit.ActionAuthEmailVerify, audit.OutcomeSuccess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendVerification envía de nuevo el email de verificación al usuario autenticado
+func (h *AuthHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	if !h.allowEmail(w, r, "email_link", claims.Email, emailLinkLimit) {
+		return
+	}
+	if err := h.service.SendVerification(r.Context(), claims.UserID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ForgotPassword envía el enlace de restablecimiento. Responde 202 exista o no
+// el email para no revelar qué cuentas hay registradas.
+func (h *AuthHandler) ForgotPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Email == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "email required")
+		return
+	}
+	if !h.allowEmail(w, r, "email_link", req.Email, emailLinkLimit) {
+		return
+	}
+
+	userID, err := h.service.ForgotPassword(r.Context(), req.Email)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("forgot password", "error", err)
+	}
+	if userID != "" {
+		outcome := audit.OutcomeSuccess
+		if err != nil {
+			outcome = audit.OutcomeFailure
+		}
+		h.recordUserEvent(r, userID, audit.ActionAuthPasswordForgot, outcome)
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ResetPassword fija una contraseña nueva con el token recibido por email
+func (h *AuthHandler) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Token == "" {
+		apperr.Respond(w, r, apperr.InvalidArgument, "token and password required")
+		return
+	}
+
+	userID, err := h.service.ResetPassword(r.Context(), req.Token, req.Password)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	h.clearLockout(r, userID)
+
+	h.recordUserEvent(r, userID, audit.ActionAuthPasswordReset, audit.OutcomeSuccess)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// clearLockout levanta el bloqueo de la cuenta de userID tras restablecer su
+// contraseña, que de otro modo duraría hasta una hora más
+func (h *AuthHandler) clearLockout(r *http.Request, userID string) {
+	user, err := h.repo.FindUserByID(r.Context(), userID)
+	if err == nil && user != nil {
+		err = h.limiter.LoginSucceeded(r.Context(), user.Email)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("reset login lockout", "user_id", userID, "error", err)
+	}
+}
+
+// ChangePassword cambia la contraseña del usuario autenticado. Todas sus
+// sesiones se cierran, incluida la actual.
+func (h *AuthHandler) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	claims := middleware.GetClaims(r.Context())
+	if claims == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+
+	var req struct {
+		CurrentPassword string `json:"current_password"`
+		NewPassword     string `json:"new_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "bad json")
+		return
+	}
+
+	email, ok := h.checkUserLockout(w, r, claims.UserID)
+	if !ok {
+		return
+	}
+	err := h.service.ChangePassword(r.Context(), claims.UserID, req.CurrentPassword, req.NewPassword)
+	if errors.Is(err, auth.ErrInvalidCredentials) {
+		// No es un 401: la sesión sigue siendo válida y el cliente no debe
+		// cerrarla
+		h.credentialFailed(r, claims.UserID, email)
+		h.recordUserEvent(r, claims.UserID, audit.ActionAuthPasswordChange, audit.OutcomeFailure)
+		apperr.Respond(w, r, apperr.InvalidArgument, "current password is incorrect")
+		return
+	}
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	h.recordUserEvent(r, claims.UserID, audit.ActionAuthPasswordChange, audit.OutcomeSuccess)
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -1,27 +1,70 @@
 package handler
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"os"
+	"time"
 
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/jmoiron/sqlx"
 
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
 	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
 	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
 	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
 	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
 	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
 	grpcserver "github.com/arturo/autohost-cloud-api/internal/grpc"
 	handlerMiddleware "github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/openapi"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
 	"github.com/arturo/autohost-cloud-api/internal/repository/postgres"
 )
 
 type Config struct {
-	DB *sqlx.DB
+	DB     *sqlx.DB
+	Mailer platform.Mailer
+	Keys   *platform.KeyRing // claves de firma de los access tokens
+	// MFAKey cifra los secretos TOTP; sin ella el alta de MFA no está disponible
+	MFAKey []byte
+	// OIDC es el proveedor de single sign-on; nil lo desactiva
+	OIDC *platform.OIDCProvider
+	// FrontendURL es el origen permitido por CORS y la base de los enlaces
+	// de los correos
+	FrontendURL string
+	// TrustedProxies son los proxies cuyas X-Forwarded-For y X-Real-IP se
+	// creen; vacío usa siempre la IP de la conexión
+	TrustedProxies []netip.Prefix
+	// RefreshTTL es la vigencia de los refresh tokens
+	RefreshTTL time.Duration
+	// AdminToken protege /admin; vacío lo desactiva
+	AdminToken string
+	// RequestTimeout limita cada petición HTTP y el trabajo de cada mensaje
+	// de un agente; cero no pone límite
+	RequestTimeout time.Duration
+	// JobOutputs guarda la salida completa de los jobs que pasan de
+	// JobOutputLimits.Inline; nil solo la trunca
+	JobOutputs      job.OutputStore
+	JobOutputLimits job.OutputLimits
+	// Artifacts guarda los ficheros que suben los agentes para sus jobs; nil
+	// desactiva la subida y la descarga
+	Artifacts      jobartifact.Store
+	ArtifactLimits jobartifact.Limits
 }
 
 // Application bundles the HTTP handler together with the gRPC server so that
@@ -29,20 +72,115 @@ type Config struct {
 type Application struct {
 	HTTP       http.Handler
 	GRPCServer *grpcserver.NodeAgentServer
+	// Health serves /livez and /readyz; main adds the checks that depend on
+	// the listeners it owns.
+	Health *HealthHandler
+
+	ws        *WSHandler
+	jobs      *job.Service
+	limiter   *ratelimit.Service
+	artifacts *jobartifact.Service // nil sin almacén de artefactos
+}
+
+// ShutdownReconnectDelay is how long agents are asked to wait before they
+// reconnect when this instance shuts down.
+const ShutdownReconnectDelay = 2 * time.Second
+
+// DrainAgents prepares the agent transports for shutdown: it fails readiness,
+// refuses new agent connections and dispatches, tells connected agents over gRPC and
+// WebSocket to reconnect elsewhere, waits until the jobs already delivered
+// report their results (or ctx ends), and finally closes the WebSocket
+// connections. The gRPC streams are left for GracefulStop.
+func (a *Application) DrainAgents(ctx context.Context) error {
+	a.Health.Drain()
+	a.GRPCServer.Drain("server shutdown", ShutdownReconnectDelay)
+	a.ws.Drain("server shutdown", ShutdownReconnectDelay)
+
+	if n := a.jobs.InFlight(); n > 0 {
+		slog.Info("waiting for in-flight jobs", "jobs", n)
+	}
+	err := a.jobs.WaitInFlight(ctx)
+	if err != nil {
+		slog.Warn("gave up waiting for in-flight jobs", "jobs", a.jobs.InFlight())
+	}
+	a.ws.CloseAll()
+	return err
+}
+
+// RunMaintenance purges stale rate limit buckets and expired job artifacts
+// every hour until ctx ends.
+func (a *Application) RunMaintenance(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Los buckets de IPs que ya no vuelven se acumularían indefinidamente
+		if err := a.limiter.Purge(ctx); err != nil && ctx.Err() == nil {
+			slog.Error("purge rate limit buckets", "error", err)
+		}
+
+		// Los artefactos caducados dejan de descargarse pero siguen ocupando
+		// disco hasta que se purgan
+		if a.artifacts == nil {
+			continue
+		}
+		n, err := a.artifacts.Prune(ctx)
+		if err != nil && ctx.Err() == nil {
+			slog.Error("prune job artifacts", "error", err)
+		} else if n > 0 {
+			slog.Info("pruned job artifacts", "count", n)
+		}
+	}
 }
 
 // NewRouter builds all repositories, services, and handlers and returns the
 // Application containing both the HTTP mux and the gRPC server.
+// It starts no background work; the caller runs RunMaintenance.
 func NewRouter(cfg *Config) *Application {
+	if cfg.Mailer == nil {
+		cfg.Mailer = platform.LogMailer{}
+	}
+
 	r := chi.NewRouter()
 
-	r.Use(middleware.Logger)
-	r.Use(middleware.Recoverer)
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
-	r.Use(corsMiddleware)
+	r.Use(handlerMiddleware.RealIP(cfg.TrustedProxies))
+	r.Use(handlerMiddleware.RequestLogger)
+	r.Use(handlerMiddleware.Trace)
+	r.Use(middleware.Recoverer)
+	r.Use(corsMiddleware(cfg.FrontendURL))
+	r.Use(handlerMiddleware.Timeout(cfg.RequestTimeout))
 
+	spec, err := openapi.Load()
+	if err != nil {
+		slog.Error("load openapi spec", "error", err)
+		os.Exit(1)
+	}
+	r.Use(handlerMiddleware.ValidateRequest(spec))
+
+	healthHandler := NewHealthHandler()
+	healthHandler.AddCheck("database", func(ctx context.Context) error {
+		if cfg.DB == nil {
+			return errNoDatabase
+		}
+		return cfg.DB.PingContext(ctx)
+	})
+	healthHandler.AddCheck("migrations", func(ctx context.Context) error {
+		if cfg.DB == nil {
+			return errNoDatabase
+		}
+		return postgres.CheckSchema(ctx, cfg.DB)
+	})
 	r.Get("/health", healthCheckHandler)
+	r.Get("/livez", healthHandler.Live)
+	r.Get("/readyz", healthHandler.Ready)
+	r.Method(http.MethodGet, "/.well-known/jwks.json", NewJWKSHandler(cfg.Keys))
+	r.Method(http.MethodGet, "/openapi.json", NewOpenAPIHandler())
 
 	// Repositories
 	authRepo := postgres.NewAuthRepository(cfg.DB)
@@ -51,47 +189,88 @@ func NewRouter(cfg *Config) *Application {
 	enrollmentRepo := postgres.NewEnrollmentRepository(cfg.DB)
 	nodeTokenRepo := postgres.NewNodeTokenRepository(cfg.DB)
 	nodeCommandRepo := postgres.NewNodeCommandRepository(cfg.DB)
+	nodeGroupRepo := postgres.NewNodeGroupRepository(cfg.DB)
+	scriptRepo := postgres.NewScriptRepository(cfg.DB)
 	jobRepo := postgres.NewJobRepository(cfg.DB)
+	jobArtifactRepo := postgres.NewJobArtifactRepository(cfg.DB)
+	orgRepo := postgres.NewOrganizationRepository(cfg.DB)
+	invitationRepo := postgres.NewInvitationRepository(cfg.DB)
+	auditRepo := postgres.NewAuditRepository(cfg.DB)
+	apiKeyRepo := postgres.NewAPIKeyRepository(cfg.DB)
+	mfaRepo := postgres.NewMFARepository(cfg.DB)
+	oidcRepo := postgres.NewOIDCRepository(cfg.DB)
+	rateLimitRepo := postgres.NewRateLimitRepository(cfg.DB)
 
 	// Services
-	authService := auth.NewService(authRepo)
+	authService := auth.NewService(authRepo, cfg.Mailer, cfg.FrontendURL, cfg.RefreshTTL)
 	nodeService := node.NewService(nodeRepo)
 	nodeMetricService := nodemetric.NewService(nodeMetricRepo)
 	enrollmentService := enrollment.NewService(enrollmentRepo)
 	nodeTokenService := nodetoken.NewService(nodeTokenRepo)
 	nodeCommandService := nodecommand.NewService(nodeCommandRepo)
-	jobService := job.NewService(jobRepo)
+	nodeGroupService := nodegroup.NewService(nodeGroupRepo, nodeService)
+	scriptService := script.NewService(scriptRepo, nodeService, nodeGroupService)
+	jobService := job.NewService(jobRepo, cfg.JobOutputs, cfg.JobOutputLimits)
+	jobArtifactService := jobartifact.NewService(jobArtifactRepo, jobService, cfg.Artifacts, cfg.ArtifactLimits)
+	orgService := organization.NewService(orgRepo)
+	auditService := audit.NewService(auditRepo)
+	apiKeyService := apikey.NewService(apiKeyRepo)
+	mfaService := mfa.NewService(mfaRepo, cfg.MFAKey, "Autohost Cloud")
+	oidcService := oidc.NewService(oidcRepo, authRepo, apiKeyService, mfaService, cfg.OIDC)
+	limiter := ratelimit.NewService(rateLimitRepo)
+	invitationService := invitation.NewService(invitationRepo, cfg.Mailer, cfg.FrontendURL+"/invitations/accept")
 
 	nodeAuthMiddleware := handlerMiddleware.NodeAuth(nodeTokenService)
+	authMiddleware := handlerMiddleware.Auth(cfg.Keys, apiKeyService)
+	authz := handlerMiddleware.NewAuthorizer(orgService, mfaService)
 
 	// gRPC server — also a NodeDispatcher over gRPC transport
-	grpcSrv := grpcserver.NewNodeAgentServer(nodeCommandService, jobService, nodeTokenService)
+	grpcSrv := grpcserver.NewNodeAgentServer(nodeCommandService, jobService, jobArtifactService, scriptService, nodeTokenService, auditService, cfg.RequestTimeout)
 
 	// HTTP handlers
-	authHandler := NewAuthHandler(authService, authRepo)
+	authHandler := NewAuthHandler(authService, authRepo, cfg.Keys, apiKeyService, mfaService, oidcService, orgService, limiter, auditService)
 	nodeHandler := NewNodeHandler(nodeService)
 	nodeMetricHandler := NewNodeMetricHandler(nodeMetricService)
-	enrollmentHandler := NewEnrollmentHandler(enrollmentService, nodeService, nodeTokenService)
+	enrollmentHandler := NewEnrollmentHandler(enrollmentService, nodeService, nodeTokenService, limiter, auditService)
 	heartbeatsHandler := NewHeartbeatsHandler(nodeService)
-	wsHandler := NewWSHandler(jobService, nodeCommandService)
-	nodeCommandHandler := NewNodeCommandHandler(nodeCommandService)
+	wsHandler := NewWSHandler(jobService, nodeCommandService, scriptService, auditService, cfg.RequestTimeout, AgentMessageLimit(cfg.JobOutputLimits))
+	nodeCommandHandler := NewNodeCommandHandler(nodeCommandService, nodeService, auditService)
+	organizationHandler := NewOrganizationHandler(orgService, mfaService, auditService)
+	invitationHandler := NewInvitationHandler(invitationService, orgService, authService, authRepo, auditService)
+	auditHandler := NewAuditHandler(auditService)
+	adminHandler := NewAdminHandler()
 
-	// MultiDispatcher: tries gRPC first, falls back to WebSocket
+	// MultiDispatcher: tries gRPC first, falls back to WebSocket, for jobs and
+	// script syncs alike
 	dispatcher := NewMultiDispatcher(grpcSrv, wsHandler)
-	jobHandler := NewJobHandler(jobService, dispatcher)
+	jobHandler := NewJobHandler(jobService, jobArtifactService, nodeService, auditService, dispatcher)
+	scriptHandler := NewScriptHandler(scriptService, auditService, dispatcher)
+	nodeGroupHandler := NewNodeGroupHandler(nodeGroupService, scriptService, auditService, dispatcher)
+
+	r.Mount("/admin", adminHandler.Routes(handlerMiddleware.AdminAuth(cfg.AdminToken)))
 
 	r.Route("/v1", func(r chi.Router) {
-		r.Mount("/auth", authHandler.Routes())
-		r.Mount("/nodes", nodeHandler.Routes())
+		r.Mount("/auth", authHandler.Routes(authMiddleware))
+		r.Mount("/organizations", organizationHandler.Routes(authMiddleware, authz))
+		r.Mount("/invitations", invitationHandler.Routes(authMiddleware, authz))
+		r.Mount("/nodes", nodeHandler.Routes(authMiddleware, authz))
 		r.Mount("/node-metrics", nodeMetricHandler.Routes(nodeAuthMiddleware))
-		r.Mount("/enrollments", enrollmentHandler.Routes())
+		r.Mount("/enrollments", enrollmentHandler.Routes(authMiddleware, authz))
 		r.Mount("/heartbeats", heartbeatsHandler.Routes(nodeAuthMiddleware))
-		r.Mount("/node-commands", nodeCommandHandler.Routes(nodeAuthMiddleware))
-		r.Mount("/jobs", jobHandler.Routes())
+		r.Mount("/node-commands", nodeCommandHandler.Routes(nodeAuthMiddleware, authMiddleware, authz))
+		r.Mount("/node-groups", nodeGroupHandler.Routes(authMiddleware, authz))
+		r.Mount("/scripts", scriptHandler.Routes(authMiddleware, authz))
+		r.Mount("/node-scripts", scriptHandler.NodeRoutes(nodeAuthMiddleware))
+		r.Mount("/jobs", jobHandler.Routes(nodeAuthMiddleware, authMiddleware, authz))
 		r.Mount("/ws", wsHandler.Routes(nodeAuthMiddleware))
+		r.Mount("/audit", auditHandler.Routes(authMiddleware, authz))
 	})
 
-	return &Application{HTTP: r, GRPCServer: grpcSrv}
+	app := &Application{HTTP: r, GRPCServer: grpcSrv, Health: healthHandler, ws: wsHandler, jobs: jobService, limiter: limiter}
+	if cfg.Artifacts != nil {
+		app.artifacts = jobArtifactService
+	}
+	return app
 }
 
 func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
@@ -100,17 +279,18 @@ func healthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(`{"status":"ok","service":"autohost-cloud-api"}`))
 }
 
-func corsMiddleware(next http.Handler) http.Handler {
-	frontendURL := os.Getenv("FRONTEND_URL")
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", frontendURL)
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-		next.ServeHTTP(w, r)
-	})
+func corsMiddleware(frontendURL string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", frontendURL)
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+			w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlerMiddleware.OrganizationHeader)
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
+			if r.Method == "OPTIONS" {
+				w.WriteHeader(http.StatusOK)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
 }
@@ -0,0 +1,446 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/go-chi/chi/v5"
+)
+
+// ScriptSyncer abstracts pushing a sync_scripts manifest to a connected node,
+// regardless of the underlying transport (WebSocket or gRPC).
+type ScriptSyncer interface {
+	// SyncScripts sends the node every script it must have installed.
+	SyncScripts(ctx context.Context, nodeID string, scripts []script.Entry) error
+}
+
+// syncNodes pushes the current manifest to each node. Nodes that are not
+// connected get it when they next connect, so failures are only logged.
+func syncNodes(r *http.Request, scripts *script.Service, syncer ScriptSyncer, nodeIDs []string) {
+	log := logging.FromContext(r.Context())
+	for _, nodeID := range nodeIDs {
+		manifest, err := scripts.Manifest(r.Context(), nodeID)
+		if err != nil {
+			log.Error("build script manifest", "node_id", nodeID, "error", err)
+			continue
+		}
+		if err := syncer.SyncScripts(r.Context(), nodeID, manifest); err != nil {
+			log.Debug("node not connected, scripts sync on reconnect", "node_id", nodeID, "error", err)
+		}
+	}
+}
+
+// ScriptHandler serves the script library: users manage scripts, their
+// versions and assignments; nodes fetch the scripts assigned to them.
+type ScriptHandler struct {
+	service      *script.Service
+	auditService *audit.Service
+	syncer       ScriptSyncer
+}
+
+func NewScriptHandler(service *script.Service, auditService *audit.Service, syncer ScriptSyncer) *ScriptHandler {
+	return &ScriptHandler{service: service, auditService: auditService, syncer: syncer}
+}
+
+// Routes serves the library to users, under /v1/scripts.
+func (h *ScriptHandler) Routes(authMiddleware func(http.Handler) http.Handler, authz *middleware.Authorizer) chi.Router {
+	r := chi.NewRouter()
+	r.Use(authMiddleware)
+
+	read := r.With(authz.Require(organization.PermNodesRead))
+	write := r.With(authz.Require(organization.PermNodesWrite))
+	write.Post("/", h.Create)
+	read.Get("/", h.List)
+	read.Get("/{id}", h.Get)
+	write.Delete("/{id}", h.Delete)
+	write.Post("/{id}/versions", h.AddVersion)
+	read.Get("/{id}/versions", h.ListVersions)
+	read.Get("/{id}/versions/{version}", h.GetVersion)
+	write.Post("/{id}/assignments", h.Assign)
+	read.Get("/{id}/assignments", h.ListAssignments)
+	write.Delete("/{id}/assignments/{assignmentID}", h.Unassign)
+	return r
+}
+
+// NodeRoutes serves the scripts assigned to the authenticated node, under
+// /v1/node-scripts.
+func (h *ScriptHandler) NodeRoutes(nodeAuthMiddleware func(http.Handler) http.Handler) chi.Router {
+	r := chi.NewRouter()
+	r.Use(nodeAuthMiddleware)
+	r.Get("/", h.Manifest)
+	r.Get("/{name}", h.Download)
+	return r
+}
+
+type createScriptRequest struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Body        string `json:"body"`
+}
+
+// Create adds a script to the library with its first version.
+// POST /v1/scripts
+func (h *ScriptHandler) Create(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	var req createScriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	s, err := h.service.Create(r.Context(), membership.OrganizationID, userID(r), req.Name, req.Description, req.Body)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionScriptCreate,
+		TargetType: "script",
+		TargetID:   s.ID,
+		Metadata:   map[string]any{"name": s.Name, "sha256": s.SHA256},
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(s)
+}
+
+// List returns the scripts of the organization ordered by name.
+// GET /v1/scripts
+func (h *ScriptHandler) List(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	scripts, err := h.service.List(r.Context(), membership.OrganizationID)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if scripts == nil {
+		scripts = []*script.Script{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"scripts": scripts})
+}
+
+// Get returns a script and its latest version number and checksum.
+// GET /v1/scripts/{id}
+func (h *ScriptHandler) Get(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	s, err := h.service.Get(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s)
+}
+
+// Delete removes a script; the nodes it was assigned to are told to remove it.
+// DELETE /v1/scripts/{id}
+func (h *ScriptHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	nodeIDs, err := h.service.Delete(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionScriptDelete,
+		TargetType: "script",
+		TargetID:   id,
+	})
+	syncNodes(r, h.service, h.syncer, nodeIDs)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type addScriptVersionRequest struct {
+	Body string `json:"body"`
+}
+
+// AddVersion stores a new body for a script, which becomes the version the
+// nodes install.
+// POST /v1/scripts/{id}/versions
+func (h *ScriptHandler) AddVersion(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	var req addScriptVersionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	v, err := h.service.AddVersion(r.Context(), membership.OrganizationID, id, userID(r), req.Body)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionScriptVersion,
+		TargetType: "script",
+		TargetID:   id,
+		Metadata:   map[string]any{"version": v.Version, "sha256": v.SHA256},
+	})
+	h.syncScript(r, membership.OrganizationID, id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(v)
+}
+
+// ListVersions returns the versions of a script, newest first.
+// GET /v1/scripts/{id}/versions
+func (h *ScriptHandler) ListVersions(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	versions, err := h.service.Versions(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if versions == nil {
+		versions = []*script.Version{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"versions": versions})
+}
+
+// GetVersion returns the body of one version of a script.
+// GET /v1/scripts/{id}/versions/{version}
+func (h *ScriptHandler) GetVersion(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	version, err := strconv.Atoi(chi.URLParam(r, "version"))
+	if err != nil || version <= 0 {
+		apperr.Write(w, r, script.ErrVersionNotFound)
+		return
+	}
+	v, err := h.service.GetVersion(r.Context(), membership.OrganizationID, id, version)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	writeScriptBody(w, r, v)
+}
+
+type assignScriptRequest struct {
+	NodeID  string `json:"node_id"`
+	GroupID string `json:"group_id"`
+}
+
+// Assign installs a script on a node or on every node of a group.
+// POST /v1/scripts/{id}/assignments
+func (h *ScriptHandler) Assign(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	var req assignScriptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		apperr.Respond(w, r, apperr.InvalidArgument, "invalid request body")
+		return
+	}
+
+	a, err := h.service.Assign(r.Context(), membership.OrganizationID, id, script.Target{NodeID: req.NodeID, GroupID: req.GroupID})
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionScriptAssign,
+		TargetType: "script",
+		TargetID:   id,
+		Metadata:   map[string]any{"assignment_id": a.ID, "node_id": a.NodeID, "group_id": a.GroupID},
+	})
+	h.syncScript(r, membership.OrganizationID, id)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(a)
+}
+
+// ListAssignments returns the nodes and groups a script is assigned to.
+// GET /v1/scripts/{id}/assignments
+func (h *ScriptHandler) ListAssignments(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	assignments, err := h.service.Assignments(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if assignments == nil {
+		assignments = []*script.Assignment{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"assignments": assignments})
+}
+
+// Unassign removes an assignment; the nodes that no longer get the script
+// are told to remove it.
+// DELETE /v1/scripts/{id}/assignments/{assignmentID}
+func (h *ScriptHandler) Unassign(w http.ResponseWriter, r *http.Request) {
+	membership := middleware.GetMembership(r.Context())
+	if membership == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	id, ok := uuidParam(w, r, "id", "script")
+	if !ok {
+		return
+	}
+	assignmentID, ok := uuidParam(w, r, "assignmentID", "script assignment")
+	if !ok {
+		return
+	}
+	before, err := h.service.Nodes(r.Context(), membership.OrganizationID, id)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	if err := h.service.Unassign(r.Context(), membership.OrganizationID, id, assignmentID); err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+
+	recordAudit(h.auditService, r, audit.Event{
+		Action:     audit.ActionScriptUnassign,
+		TargetType: "script",
+		TargetID:   id,
+		Metadata:   map[string]any{"assignment_id": assignmentID},
+	})
+	// A node may still get the script through another assignment; its
+	// manifest then just stays the same
+	syncNodes(r, h.service, h.syncer, before)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Manifest returns the scripts the authenticated node must have installed,
+// the same list pushed to it in sync_scripts messages.
+// GET /v1/node-scripts
+func (h *ScriptHandler) Manifest(w http.ResponseWriter, r *http.Request) {
+	nodeToken := middleware.GetNodeToken(r.Context())
+	if nodeToken == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	manifest, err := h.service.Manifest(r.Context(), nodeToken.NodeID)
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{"scripts": manifest})
+}
+
+// Download returns the body of the latest version of a script assigned to
+// the authenticated node. The agent checks it against X-Checksum-SHA256
+// before installing it.
+// GET /v1/node-scripts/{name}
+func (h *ScriptHandler) Download(w http.ResponseWriter, r *http.Request) {
+	nodeToken := middleware.GetNodeToken(r.Context())
+	if nodeToken == nil {
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	_, v, err := h.service.NodeScript(r.Context(), nodeToken.NodeID, chi.URLParam(r, "name"))
+	if err != nil {
+		apperr.Write(w, r, err)
+		return
+	}
+	writeScriptBody(w, r, v)
+}
+
+// syncScript pushes the manifest to every node a script is assigned to.
+func (h *ScriptHandler) syncScript(r *http.Request, orgID, id string) {
+	nodeIDs, err := h.service.Nodes(r.Context(), orgID, id)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("list script nodes", "script_id", id, "error", err)
+		return
+	}
+	syncNodes(r, h.service, h.syncer, nodeIDs)
+}
+
+func writeScriptBody(w http.ResponseWriter, r *http.Request, v *script.Version) {
+	w.Header().Set("Content-Type", "text/x-shellscript; charset=utf-8")
+	w.Header().Set("Content-Length", strconv.FormatInt(v.Size, 10))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Checksum-SHA256", v.SHA256)
+	w.Header().Set("X-Script-Version", strconv.Itoa(v.Version))
+	w.Header().Set("ETag", `"`+v.SHA256+`"`)
+	if _, err := w.Write([]byte(v.Body)); err != nil {
+		logging.FromContext(r.Context()).Warn("write script body", "script_id", v.ScriptID, "error", err)
+	}
+}
+
+// userID returns the ID of the authenticated user, or nil.
+func userID(r *http.Request) *string {
+	if claims := middleware.GetClaims(r.Context()); claims != nil {
+		return &claims.UserID
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+)
+
+// send sends an authenticated request for the test user in the test
+// organization, with body encoded as JSON when it is not nil.
+func (env *agentEnv) send(t *testing.T, ctx context.Context, method, path string, body any) (*http.Response, []byte) {
+	t.Helper()
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, env.srv.URL+path, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+env.apiKey)
+	req.Header.Set(middleware.OrganizationHeader, env.orgID)
+	resp, err := env.srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	out, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, out
+}
+
+// nextSync waits for the next manifest pushed to a.
+func nextSync(t *testing.T, ctx context.Context, a *agentsim.WSAgent) []script.Entry {
+	t.Helper()
+	entries, err := a.NextSync(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return entries
+}
+
+func checksum(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestScriptSync(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	if got := nextSync(t, ctx, a); len(got) != 0 {
+		t.Fatalf("manifest on connect = %+v, want empty", got)
+	}
+
+	const v1, v2 = "#!/bin/sh\necho v1\n", "#!/bin/sh\necho v2\n"
+	resp, body := env.send(t, ctx, http.MethodPost, "/v1/scripts",
+		map[string]string{"name": "backup.sh", "description": "Nightly backup", "body": v1})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /v1/scripts = %d %s", resp.StatusCode, body)
+	}
+	var s script.Script
+	if err := json.Unmarshal(body, &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Version != 1 || s.SHA256 != checksum(v1) {
+		t.Errorf("created script = %+v", s)
+	}
+	if resp, body := env.send(t, ctx, http.MethodPost, "/v1/scripts",
+		map[string]string{"name": "backup.sh", "body": v1}); resp.StatusCode != http.StatusConflict {
+		t.Errorf("duplicate name = %d %s, want 409", resp.StatusCode, body)
+	}
+
+	resp, body = env.send(t, ctx, http.MethodPost, "/v1/node-groups", map[string]string{"name": "web"})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /v1/node-groups = %d %s", resp.StatusCode, body)
+	}
+	var group struct{ ID string }
+	if err := json.Unmarshal(body, &group); err != nil {
+		t.Fatal(err)
+	}
+	if resp, body := env.send(t, ctx, http.MethodPut, "/v1/node-groups/"+group.ID+"/nodes/"+e.NodeID, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("add node to group = %d %s", resp.StatusCode, body)
+	}
+	if got := nextSync(t, ctx, a); len(got) != 0 {
+		t.Errorf("manifest after joining an empty group = %+v", got)
+	}
+
+	resp, body = env.send(t, ctx, http.MethodPost, "/v1/scripts/"+s.ID+"/assignments", map[string]string{"group_id": group.ID})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("assign to group = %d %s", resp.StatusCode, body)
+	}
+	want := script.Entry{Name: "backup.sh", Version: 1, SHA256: checksum(v1)}
+	if got := nextSync(t, ctx, a); len(got) != 1 || got[0] != want {
+		t.Errorf("manifest after assignment = %+v, want [%+v]", got, want)
+	}
+
+	t.Run("node downloads the script", func(t *testing.T) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, env.srv.URL+"/v1/node-scripts/backup.sh", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+e.APIToken)
+		resp, err := env.srv.Client().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		got, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK || string(got) != v1 {
+			t.Fatalf("GET /v1/node-scripts/backup.sh = %d %q", resp.StatusCode, got)
+		}
+		if sum := resp.Header.Get("X-Checksum-SHA256"); sum != checksum(string(got)) {
+			t.Errorf("X-Checksum-SHA256 = %q, want %q", sum, checksum(string(got)))
+		}
+	})
+
+	resp, body = env.send(t, ctx, http.MethodPost, "/v1/scripts/"+s.ID+"/versions", map[string]string{"body": v2})
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST versions = %d %s", resp.StatusCode, body)
+	}
+	want = script.Entry{Name: "backup.sh", Version: 2, SHA256: checksum(v2)}
+	if got := nextSync(t, ctx, a); len(got) != 1 || got[0] != want {
+		t.Errorf("manifest after new version = %+v, want [%+v]", got, want)
+	}
+	if resp, body := env.get(t, ctx, "/v1/scripts/"+s.ID+"/versions/1"); resp.StatusCode != http.StatusOK || string(body) != v1 {
+		t.Errorf("GET version 1 = %d %q", resp.StatusCode, body)
+	}
+
+	if resp, body := env.send(t, ctx, http.MethodDelete, "/v1/node-groups/"+group.ID+"/nodes/"+e.NodeID, nil); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("remove node from group = %d %s", resp.StatusCode, body)
+	}
+	if got := nextSync(t, ctx, a); len(got) != 0 {
+		t.Errorf("manifest after leaving the group = %+v, want empty", got)
+	}
+}
@@ -1,49 +1,91 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"log/slog"
 	"net/http"
 	"sync"
 	"time"
 
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
 	"github.com/arturo/autohost-cloud-api/internal/domain/job"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
 	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/logging"
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
+	chimiddleware "github.com/go-chi/chi/middleware"
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/websocket"
 )
 
 var upgrader = websocket.Upgrader{
-	ReadBufferSize:  1024,
-	WriteBufferSize: 1024,
+	// Buffer sizes do not limit message size (see AgentMessageLimit), but
+	// job results carry output, so 1 KB meant many reads per message.
+	ReadBufferSize:  32 << 10,
+	WriteBufferSize: 4 << 10,
 	CheckOrigin: func(r *http.Request) bool {
 		return true
 	},
 }
 
 // WSHandler manages WebSocket connections from node agents.
-// It also implements NodeDispatcher so the JobHandler can push jobs to nodes.
+// It also implements NodeDispatcher so the JobHandler can push jobs to nodes,
+// and ScriptSyncer to push script manifests.
 type WSHandler struct {
 	clients        map[string]*Client
 	clientsMu      sync.RWMutex
+	draining       bool // set by Drain; guarded by clientsMu
 	jobService     *job.Service
 	commandService *nodecommand.Service
+	scriptService  *script.Service
+	auditService   *audit.Service
+	// opTimeout bounds the database work done for each message a node sends
+	opTimeout time.Duration
+	// maxMessage is the largest message a node may send; zero means no limit
+	maxMessage int64
 }
 
-func NewWSHandler(jobService *job.Service, commandService *nodecommand.Service) *WSHandler {
+func NewWSHandler(jobService *job.Service, commandService *nodecommand.Service, scriptService *script.Service, auditService *audit.Service, opTimeout time.Duration, maxMessage int64) *WSHandler {
 	return &WSHandler{
 		clients:        make(map[string]*Client),
 		jobService:     jobService,
 		commandService: commandService,
+		scriptService:  scriptService,
+		auditService:   auditService,
+		opTimeout:      opTimeout,
+		maxMessage:     maxMessage,
 	}
 }
 
+// AgentMessageLimit returns the largest WebSocket message a node needs to
+// report a result within limits: JSON escaping can double text output, plus
+// room for the envelope. Zero (no limit) when limits.Max is zero.
+func AgentMessageLimit(limits job.OutputLimits) int64 {
+	if limits.Max <= 0 {
+		return 0
+	}
+	return 2*int64(limits.Max) + 64<<10
+}
+
 type Client struct {
-	NodeID string
-	Conn   *websocket.Conn
-	Send   chan []byte
-	mu     sync.Mutex
+	NodeID         string
+	OrganizationID string
+	Conn           *websocket.Conn
+	Send           chan []byte
+	mu             sync.Mutex
+
+	// Request metadata captured at upgrade time, used for audit events.
+	ip        string
+	requestID string
+	// log carries the request and node IDs of the upgrade request.
+	log *slog.Logger
+	// ctx lives as long as the connection; it carries the upgrade request's
+	// logger and trace and is cancelled when the read pump stops.
+	ctx context.Context
 }
 
 // Message is the envelope used for all WebSocket communication.
@@ -65,35 +107,66 @@ func (h *WSHandler) Routes(nodeAuthMiddleware func(http.Handler) http.Handler) h
 func (h *WSHandler) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	nodeToken := middleware.GetNodeToken(r.Context())
 	if nodeToken == nil || nodeToken.NodeID == "" {
-		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		return
+	}
+	if h.isDraining() {
+		apperr.Respond(w, r, apperr.Unavailable, "server shutting down")
 		return
 	}
 
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("Error upgrading connection: %v", err)
+		logging.FromContext(r.Context()).Warn("websocket upgrade", "error", err)
 		return
 	}
 
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
 	client := &Client{
-		NodeID: nodeToken.NodeID,
-		Conn:   conn,
-		Send:   make(chan []byte, 256),
+		NodeID:         nodeToken.NodeID,
+		OrganizationID: nodeToken.OrganizationID,
+		Conn:           conn,
+		Send:           make(chan []byte, 256),
+		ip:             clientIP(r),
+		requestID:      chimiddleware.GetReqID(r.Context()),
+		log:            logging.FromContext(r.Context()),
+		ctx:            ctx,
 	}
 
-	h.registerClient(client)
+	if !h.registerClient(client) {
+		// Drain started between the check above and the upgrade
+		conn.WriteControl(websocket.CloseMessage,
+			websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down"),
+			time.Now().Add(time.Second))
+		conn.Close()
+		return
+	}
 	defer h.unregisterClient(client)
 
 	welcomeMsg := Message{Type: "connected", Timestamp: time.Now()}
 	if err := client.WriteJSON(welcomeMsg); err != nil {
-		log.Printf("Error sending welcome message: %v", err)
+		client.log.Warn("send welcome message", "error", err)
 		return
 	}
+	// The agent may have missed script changes while it was away
+	if err := h.sendManifest(client); err != nil {
+		client.log.Error("send script manifest", "error", err)
+	}
 
 	go client.writePump()
 	client.readPump(h)
 }
 
+// opContext derives the context for handling one message from c: it is
+// cancelled when the connection goes away and after opTimeout.
+func (h *WSHandler) opContext(c *Client) (context.Context, context.CancelFunc) {
+	if h.opTimeout <= 0 {
+		return context.WithCancel(c.ctx)
+	}
+	return context.WithTimeout(c.ctx, h.opTimeout)
+}
+
 // ─── NodeDispatcher interface ────────────────────────────────────────────────
 
 // wsExecuteJobPayload is the JSON body inside a WebSocket "execute_job" message.
@@ -101,15 +174,21 @@ type wsExecuteJobPayload struct {
 	JobID       string                  `json:"job_id"`
 	CommandName string                  `json:"command_name"`
 	CommandType nodecommand.CommandType `json:"command_type"`
+	// W3C trace context of the job, for agents that continue the trace.
+	Traceparent string `json:"traceparent,omitempty"`
+	Tracestate  string `json:"tracestate,omitempty"`
 }
 
 // DispatchJob implements handler.NodeDispatcher: serializes an execute_job
 // WebSocket message and queues it for the target node.
-func (h *WSHandler) DispatchJob(nodeID, jobID, commandName string, commandType nodecommand.CommandType) error {
+func (h *WSHandler) DispatchJob(ctx context.Context, nodeID, jobID, commandName string, commandType nodecommand.CommandType) error {
+	traceparent, tracestate := tracing.Inject(ctx)
 	payload, err := json.Marshal(wsExecuteJobPayload{
 		JobID:       jobID,
 		CommandName: commandName,
 		CommandType: commandType,
+		Traceparent: traceparent,
+		Tracestate:  tracestate,
 	})
 	if err != nil {
 		return err
@@ -124,18 +203,23 @@ func (h *WSHandler) DispatchJob(nodeID, jobID, commandName string, commandType n
 // SendToNode marshals msg and queues it for the named node client.
 // Returns an error if the node is not currently connected.
 func (h *WSHandler) SendToNode(nodeID string, msg interface{}) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// The read lock keeps the registry from closing client.Send while we
+	// push to it.
 	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	if h.draining {
+		return errServerShuttingDown
+	}
 	client, ok := h.clients[nodeID]
-	h.clientsMu.RUnlock()
 	if !ok {
 		return websocket.ErrCloseSent
 	}
 
-	data, err := json.Marshal(msg)
-	if err != nil {
-		return err
-	}
-
 	select {
 	case client.Send <- data:
 		return nil
@@ -144,22 +228,134 @@ func (h *WSHandler) SendToNode(nodeID string, msg interface{}) error {
 	}
 }
 
+// ─── ScriptSyncer interface ──────────────────────────────────────────────────
+
+// wsSyncScriptsPayload is the JSON body of a "sync_scripts" message: every
+// script the node must have installed.
+type wsSyncScriptsPayload struct {
+	Scripts []script.Entry `json:"scripts"`
+}
+
+// SyncScripts implements handler.ScriptSyncer: queues a sync_scripts message
+// with the manifest for the target node.
+func (h *WSHandler) SyncScripts(ctx context.Context, nodeID string, scripts []script.Entry) error {
+	msg, err := syncScriptsMessage(scripts)
+	if err != nil {
+		return err
+	}
+	return h.SendToNode(nodeID, msg)
+}
+
+// sendManifest writes the current manifest of c's node to it.
+func (h *WSHandler) sendManifest(c *Client) error {
+	ctx, cancel := h.opContext(c)
+	scripts, err := h.scriptService.Manifest(ctx, c.NodeID)
+	cancel()
+	if err != nil {
+		return err
+	}
+	msg, err := syncScriptsMessage(scripts)
+	if err != nil {
+		return err
+	}
+	return c.WriteJSON(msg)
+}
+
+func syncScriptsMessage(scripts []script.Entry) (Message, error) {
+	payload, err := json.Marshal(wsSyncScriptsPayload{Scripts: scripts})
+	if err != nil {
+		return Message{}, err
+	}
+	return Message{Type: "sync_scripts", Payload: payload, Timestamp: time.Now()}, nil
+}
+
 // ─── Client registry ─────────────────────────────────────────────────────────
 
-func (h *WSHandler) registerClient(client *Client) {
+// registerClient adds client to the registry, closing the node's previous
+// connection if it still has one. It refuses new clients once Drain has been
+// called.
+func (h *WSHandler) registerClient(client *Client) bool {
 	h.clientsMu.Lock()
 	defer h.clientsMu.Unlock()
+	if h.draining {
+		return false
+	}
+	if old, ok := h.clients[client.NodeID]; ok {
+		// Closing Send makes the old write pump send a close frame and drop
+		// the connection; its read pump then stops
+		close(old.Send)
+		old.log.Info("node connection replaced", "transport", "websocket")
+	}
 	h.clients[client.NodeID] = client
-	log.Printf("Node connected: %s (total: %d)", client.NodeID, len(h.clients))
+	client.log.Info("node connected", "transport", "websocket", "connected_nodes", len(h.clients))
+	return true
 }
 
+// unregisterClient removes client unless a newer connection of the same node
+// already replaced it.
 func (h *WSHandler) unregisterClient(client *Client) {
 	h.clientsMu.Lock()
 	defer h.clientsMu.Unlock()
-	if _, ok := h.clients[client.NodeID]; ok {
+	if h.clients[client.NodeID] == client {
 		delete(h.clients, client.NodeID)
 		close(client.Send)
-		log.Printf("Node disconnected: %s (total: %d)", client.NodeID, len(h.clients))
+		h.jobService.NodeDisconnected(client.NodeID)
+		client.log.Info("node disconnected", "transport", "websocket", "connected_nodes", len(h.clients))
+	}
+}
+
+// ─── Shutdown ─────────────────────────────────────────────────────────────────
+
+var errServerShuttingDown = errors.New("server shutting down")
+
+// wsServerShutdownPayload is the JSON body of a "server_shutdown" message.
+type wsServerShutdownPayload struct {
+	Reason           string `json:"reason"`
+	ReconnectAfterMs int64  `json:"reconnect_after_ms"`
+}
+
+func (h *WSHandler) isDraining() bool {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	return h.draining
+}
+
+// Drain prepares the handler to stop: new connections and job dispatches are
+// refused, and every connected agent gets a server_shutdown message telling
+// it to reconnect after reconnectAfter. Open connections keep working so
+// agents can still report the results of jobs in progress.
+func (h *WSHandler) Drain(reason string, reconnectAfter time.Duration) {
+	payload, _ := json.Marshal(wsServerShutdownPayload{
+		Reason:           reason,
+		ReconnectAfterMs: reconnectAfter.Milliseconds(),
+	})
+	data, _ := json.Marshal(Message{Type: "server_shutdown", Payload: payload, Timestamp: time.Now()})
+
+	h.clientsMu.Lock()
+	h.draining = true
+	h.clientsMu.Unlock()
+
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	for nodeID, client := range h.clients {
+		select {
+		case client.Send <- data:
+		default:
+			slog.Warn("send buffer full, shutdown notice dropped", "node_id", nodeID, "transport", "websocket")
+		}
+	}
+	slog.Info("draining: notified connected nodes", "transport", "websocket", "nodes", len(h.clients))
+}
+
+// CloseAll closes every connection with a 1012 (service restart) close
+// frame. The read pumps then unregister the clients.
+func (h *WSHandler) CloseAll() {
+	h.clientsMu.RLock()
+	defer h.clientsMu.RUnlock()
+	closeMsg := websocket.FormatCloseMessage(websocket.CloseServiceRestart, "server shutting down")
+	for _, client := range h.clients {
+		client.Conn.WriteControl(websocket.CloseMessage, closeMsg, time.Now().Add(time.Second))
+		client.Conn.Close()
 	}
 }
 
@@ -189,25 +385,32 @@ func (h *WSHandler) handleMessage(c *Client, msg Message) {
 	case "job_result":
 		var p jobResultPayload
 		if err := json.Unmarshal(msg.Payload, &p); err != nil {
-			log.Printf("[WARN] invalid job_result payload from %s: %v", c.NodeID, err)
+			c.log.Warn("invalid job_result payload", "error", err)
 			return
 		}
-		if err := h.jobService.UpdateResult(
+		ctx, cancel := h.opContext(c)
+		err := h.jobService.UpdateResult(ctx,
 			p.JobID,
+			c.NodeID,
 			job.JobStatus(p.Status),
 			p.Output,
 			p.Error,
-		); err != nil {
-			log.Printf("[ERROR] update job %s result: %v", p.JobID, err)
-		} else {
-			log.Printf("Job %s -> %s (node %s)", p.JobID, p.Status, c.NodeID)
+		)
+		cancel()
+		switch {
+		case errors.Is(err, job.ErrJobNotFound), errors.Is(err, job.ErrInvalidTransition), errors.Is(err, job.ErrInvalidJobData):
+			c.log.Warn("job result rejected", "job_id", p.JobID, "status", p.Status, "error", err)
+		case err != nil:
+			c.log.Error("update job result", "job_id", p.JobID, "error", err)
+		default:
+			c.log.Info("job result", "job_id", p.JobID, "status", p.Status)
 		}
 
 	case "register_command":
 		// The agent can also register commands over the WS connection.
 		var p registerCommandPayload
 		if err := json.Unmarshal(msg.Payload, &p); err != nil {
-			log.Printf("[WARN] invalid register_command payload from %s: %v", c.NodeID, err)
+			c.log.Warn("invalid register_command payload", "error", err)
 			return
 		}
 		cmd := &nodecommand.NodeCommand{
@@ -217,14 +420,37 @@ func (h *WSHandler) handleMessage(c *Client, msg Message) {
 			Type:        p.Type,
 			ScriptPath:  p.ScriptPath,
 		}
-		if _, err := h.commandService.Register(cmd); err != nil {
-			log.Printf("[ERROR] register command %s for node %s: %v", p.Name, c.NodeID, err)
-		} else {
-			log.Printf("Command '%s' (%s) registered for node %s", p.Name, p.Type, c.NodeID)
+		ctx, cancel := h.opContext(c)
+		saved, err := h.commandService.Register(ctx, cmd)
+		cancel()
+		if err != nil {
+			c.log.Error("register command", "command", p.Name, "error", err)
+			return
 		}
+		c.log.Info("command registered", "command", p.Name, "command_type", p.Type)
+		h.recordAudit(c, audit.Event{
+			Action:     audit.ActionCommandRegister,
+			TargetType: "node_command",
+			TargetID:   saved.ID,
+			Metadata:   map[string]any{"name": saved.Name, "type": string(saved.Type), "script_path": saved.ScriptPath},
+		})
 
 	default:
-		log.Printf("Unknown message type '%s' from node %s", msg.Type, c.NodeID)
+		c.log.Warn("unknown message type", "type", msg.Type)
+	}
+}
+
+// recordAudit appends an event performed by the connected node.
+func (h *WSHandler) recordAudit(c *Client, e audit.Event) {
+	e.ActorType = audit.ActorNode
+	e.ActorID = c.NodeID
+	e.OrganizationID = &c.OrganizationID
+	e.IP = c.ip
+	e.RequestID = c.requestID
+	ctx, cancel := h.opContext(c)
+	defer cancel()
+	if err := h.auditService.Record(ctx, e); err != nil {
+		c.log.Error("record audit event", "action", e.Action, "error", err)
 	}
 }
 
@@ -233,6 +459,9 @@ func (h *WSHandler) handleMessage(c *Client, msg Message) {
 func (c *Client) readPump(handler *WSHandler) {
 	defer c.Conn.Close()
 
+	if handler.maxMessage > 0 {
+		c.Conn.SetReadLimit(handler.maxMessage)
+	}
 	c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
 	c.Conn.SetPongHandler(func(string) error {
 		c.Conn.SetReadDeadline(time.Now().Add(60 * time.Second))
@@ -243,7 +472,7 @@ func (c *Client) readPump(handler *WSHandler) {
 		var msg Message
 		if err := c.Conn.ReadJSON(&msg); err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error (node %s): %v", c.NodeID, err)
+				c.log.Warn("websocket read", "error", err)
 			}
 			break
 		}
@@ -262,35 +491,50 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.Send:
-			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 			if !ok {
+				c.mu.Lock()
+				c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
 				c.Conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.mu.Unlock()
 				return
 			}
-			w, err := c.Conn.NextWriter(websocket.TextMessage)
-			if err != nil {
-				return
-			}
-			w.Write(message)
-			// Flush any additional queued messages
-			n := len(c.Send)
-			for i := 0; i < n; i++ {
-				w.Write([]byte{'\n'})
-				w.Write(<-c.Send)
-			}
-			if err := w.Close(); err != nil {
+			if err := c.writeQueued(message); err != nil {
 				return
 			}
 
 		case <-ticker.C:
+			c.mu.Lock()
 			c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+			err := c.Conn.WriteMessage(websocket.PingMessage, nil)
+			c.mu.Unlock()
+			if err != nil {
 				return
 			}
 		}
 	}
 }
 
+// writeQueued writes message and any other queued messages as one frame,
+// separated by newlines. It holds c.mu so it does not interleave with
+// WriteJSON replies sent from the read pump.
+func (c *Client) writeQueued(message []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	w, err := c.Conn.NextWriter(websocket.TextMessage)
+	if err != nil {
+		return err
+	}
+	w.Write(message)
+	// Flush any additional queued messages
+	n := len(c.Send)
+	for i := 0; i < n; i++ {
+		w.Write([]byte{'\n'})
+		w.Write(<-c.Send)
+	}
+	return w.Close()
+}
+
 func (c *Client) WriteJSON(v interface{}) error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -0,0 +1,466 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/arturo/autohost-cloud-api/internal/agentsim"
+	"github.com/arturo/autohost-cloud-api/internal/apperr"
+	"github.com/arturo/autohost-cloud-api/internal/blob"
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	grpcserver "github.com/arturo/autohost-cloud-api/internal/grpc"
+	"github.com/arturo/autohost-cloud-api/internal/handler/middleware"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+	"github.com/arturo/autohost-cloud-api/internal/repository/memory"
+)
+
+// agentEnv serves the enrollment and WebSocket routes backed by in-memory
+// repositories, for driving them with simulated agents.
+type agentEnv struct {
+	srv       *httptest.Server
+	ws        *WSHandler
+	grpc      *grpcserver.NodeAgentServer
+	enroll    *enrollment.Service
+	nodes     *node.Service
+	jobs      *job.Service
+	artifacts *jobartifact.Service
+	cmds      *nodecommand.Service
+	audit     *audit.Service
+	groups    *nodegroup.Service
+	scripts   *script.Service
+	userID    string
+	orgID     string
+	// apiKey authenticates the test user on the /v1/jobs, /v1/scripts and
+	// /v1/node-groups routes
+	apiKey string
+}
+
+// testOutputLimits are small enough to exercise truncation in tests.
+var testOutputLimits = job.OutputLimits{Inline: 1 << 10, Max: 64 << 10}
+
+var testArtifactLimits = jobartifact.Limits{MaxSize: 1 << 20, Retention: time.Hour}
+
+func newAgentEnv(t *testing.T) *agentEnv {
+	t.Helper()
+	ctx := context.Background()
+	db := memory.NewDB()
+
+	userID, orgID := memory.SeedOrg(t, db)
+	orgs := organization.NewService(memory.NewOrganizationRepository(db))
+
+	blobs, err := blob.NewFSStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	apiKeys := apikey.NewService(memory.NewAPIKeyRepository(db))
+	plainKey, _, err := apiKeys.Create(ctx, userID, "tests", []organization.Permission{organization.PermJobsRead, organization.PermJobsWrite,
+		organization.PermNodesRead, organization.PermNodesWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env := &agentEnv{
+		enroll: enrollment.NewService(memory.NewEnrollmentRepository(db)),
+		nodes:  node.NewService(memory.NewNodeRepository(db)),
+		jobs:   job.NewService(memory.NewJobRepository(db), blobs, testOutputLimits),
+		cmds:   nodecommand.NewService(memory.NewNodeCommandRepository(db)),
+		audit:  audit.NewService(memory.NewAuditRepository(db)),
+		userID: userID,
+		orgID:  orgID,
+		apiKey: plainKey,
+	}
+	tokens := nodetoken.NewService(memory.NewNodeTokenRepository(db))
+	limiter := ratelimit.NewService(memory.NewRateLimitRepository(db))
+	authz := middleware.NewAuthorizer(orgs, mfa.NewService(memory.NewMFARepository(db), make([]byte, 32), "test"))
+	env.artifacts = jobartifact.NewService(memory.NewJobArtifactRepository(db), env.jobs, blobs, testArtifactLimits)
+	env.groups = nodegroup.NewService(memory.NewNodeGroupRepository(db), env.nodes)
+	env.scripts = script.NewService(memory.NewScriptRepository(db), env.nodes, env.groups)
+	env.ws = NewWSHandler(env.jobs, env.cmds, env.scripts, env.audit, time.Second, AgentMessageLimit(testOutputLimits))
+	env.grpc = grpcserver.NewNodeAgentServer(env.cmds, env.jobs, env.artifacts, env.scripts, tokens, env.audit, time.Second)
+
+	// Users never authenticate here; enroll tokens are created directly
+	denyUsers := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apperr.Respond(w, r, apperr.Unauthenticated, "unauthorized")
+		})
+	}
+	dispatcher := NewMultiDispatcher(env.grpc, env.ws)
+	userAuth := middleware.Auth(nil, apiKeys)
+	r := chi.NewRouter()
+	r.Route("/v1", func(r chi.Router) {
+		r.Mount("/enrollments", NewEnrollmentHandler(env.enroll, env.nodes, tokens, limiter, env.audit).Routes(denyUsers, authz))
+		r.Mount("/ws", env.ws.Routes(middleware.NodeAuth(tokens)))
+		r.Mount("/node-commands", NewNodeCommandHandler(env.cmds, env.nodes, env.audit).Routes(middleware.NodeAuth(tokens), userAuth, authz))
+		r.Mount("/jobs", NewJobHandler(env.jobs, env.artifacts, env.nodes, env.audit, dispatcher).
+			Routes(middleware.NodeAuth(tokens), userAuth, authz))
+		scripts := NewScriptHandler(env.scripts, env.audit, dispatcher)
+		r.Mount("/scripts", scripts.Routes(userAuth, authz))
+		r.Mount("/node-scripts", scripts.NodeRoutes(middleware.NodeAuth(tokens)))
+		r.Mount("/node-groups", NewNodeGroupHandler(env.groups, env.scripts, env.audit, dispatcher).Routes(userAuth, authz))
+	})
+	env.srv = httptest.NewServer(r)
+	t.Cleanup(env.srv.Close)
+	return env
+}
+
+// newEnrollToken stores an enroll token for the test organization and
+// returns it in plain text.
+func (env *agentEnv) newEnrollToken(t *testing.T, expiresAt time.Time) string {
+	t.Helper()
+	plain, hash, err := platform.GenerateEnrollToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.enroll.CreateEnrollToken(context.Background(), hash, env.userID, env.orgID, expiresAt); err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+// connectNode enrolls a new node and connects it over WebSocket.
+func (env *agentEnv) connectNode(t *testing.T, ctx context.Context, hostname string) (*agentsim.Enrollment, *agentsim.WSAgent) {
+	t.Helper()
+	e, err := agentsim.Enroll(ctx, env.srv.Client(), env.srv.URL, env.newEnrollToken(t, time.Now().Add(time.Hour)),
+		agentsim.NodeInfo{Hostname: hostname, OS: "linux", Arch: "amd64", VersionAgent: "1.0.0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := agentsim.DialWS(ctx, env.srv.URL, e.APIToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { a.Close() })
+	return e, a
+}
+
+func TestWSRegisterAndAnswerJob(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	err := a.RegisterCommands(ctx,
+		agentsim.Command{Name: "uptime", Description: "Show uptime", Type: nodecommand.CommandTypeDefault},
+		agentsim.Command{Name: "backup", Type: nodecommand.CommandTypeCustom, ScriptPath: "/opt/backup.sh"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Messages are handled in order, so the pong means both are stored
+	if err := a.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cmds, err := env.cmds.ListByNode(ctx, e.NodeID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || cmds[0].Name != "backup" || cmds[0].ScriptPath != "/opt/backup.sh" || cmds[1].Description != "Show uptime" {
+		t.Errorf("stored commands = %+v", cmds)
+	}
+
+	// The node is only connected over WebSocket, so the dispatcher has to
+	// fall back from gRPC
+	dispatcher := NewMultiDispatcher(env.grpc, env.ws)
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "backup", nodecommand.CommandTypeCustom)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dispatcher.DispatchJob(ctx, e.NodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+		t.Fatal(err)
+	}
+	got, err := a.NextJob(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != j.ID || got.CommandName != "backup" || got.CommandType != nodecommand.CommandTypeCustom {
+		t.Errorf("received job = %+v", got)
+	}
+
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted, Output: "done"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := env.jobs.GetByID(ctx, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Status != job.StatusCompleted || stored.Output != "done" || stored.FinishedAt == nil {
+		t.Errorf("stored job = %+v", stored)
+	}
+
+	events, _, err := env.audit.List(ctx, audit.Filter{ActorID: e.NodeID, Action: audit.ActionCommandRegister})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 2 || events[0].OrganizationID == nil || *events[0].OrganizationID != env.orgID {
+		t.Errorf("audit events = %+v", events)
+	}
+}
+
+func TestWSJobResultsFollowStateMachine(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	_, other := env.connectNode(t, ctx, "web-2")
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	status := func() *job.Job {
+		t.Helper()
+		stored, err := env.jobs.GetByID(ctx, j.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stored
+	}
+
+	// A node cannot report another node's job
+	if err := other.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted, Output: "spoofed"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := status(); got.Status != job.StatusPending {
+		t.Errorf("after another node's report: %+v", got)
+	}
+
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted, Output: "up 3 days"}); err != nil {
+		t.Fatal(err)
+	}
+	// A late running message must not reopen the finished job
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusRunning, Output: "late"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	got := status()
+	if got.Status != job.StatusCompleted || got.Output != "up 3 days" || got.StartedAt == nil || got.FinishedAt == nil {
+		t.Errorf("after late running: %+v", got)
+	}
+}
+
+func TestWSDispatchBurst(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	// Queued messages may reach the agent batched in a single frame
+	const n = 20
+	want := make(map[string]bool)
+	for range n {
+		j, err := env.jobs.Dispatch(ctx, e.NodeID, "uptime", nodecommand.CommandTypeDefault)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := env.ws.DispatchJob(ctx, e.NodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+			t.Fatal(err)
+		}
+		want[j.ID] = true
+	}
+	for range n {
+		got, err := a.NextJob(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !want[got.ID] {
+			t.Fatalf("unexpected or repeated job %s", got.ID)
+		}
+		delete(want, got.ID)
+	}
+}
+
+func TestWSDispatchToDisconnectedNode(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	a.Close()
+	// The handler unregisters the node once its read pump notices the close
+	for {
+		err := env.ws.DispatchJob(ctx, e.NodeID, "job", "uptime", nodecommand.CommandTypeDefault)
+		if err != nil {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("node still registered after closing")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if err := NewMultiDispatcher(env.grpc, env.ws).DispatchJob(ctx, e.NodeID, "job", "uptime", nodecommand.CommandTypeDefault); err == nil {
+		t.Error("dispatch to a node connected on no transport succeeded")
+	}
+}
+
+// TestWSDisconnectReleasesInFlightJobs covers an agent that goes away
+// before reporting a job: shutdown must not wait for its result.
+func TestWSDisconnectReleasesInFlightJobs(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = env.jobs.Deliver(ctx, j, func(ctx context.Context) error {
+		return env.ws.DispatchJob(ctx, e.NodeID, j.ID, j.CommandName, j.CommandType)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.NextJob(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if n := env.jobs.InFlight(); n != 1 {
+		t.Fatalf("in flight = %d, want 1", n)
+	}
+
+	a.Close()
+	if err := env.jobs.WaitInFlight(ctx); err != nil {
+		t.Fatalf("job still in flight after its node disconnected: %v", err)
+	}
+}
+
+// TestWSReconnectReplacesConnection covers an agent that reconnects before
+// the server notices its old connection is gone: the new connection takes
+// over, and the end of the old one must not unregister it.
+func TestWSReconnectReplacesConnection(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, old := env.connectNode(t, ctx, "web-1")
+	current, err := agentsim.DialWS(ctx, env.srv.URL, e.APIToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { current.Close() })
+
+	ended := make(chan struct{})
+	go func() { old.Wait(); close(ended) }()
+	select {
+	case <-ended:
+	case <-ctx.Done():
+		t.Fatal("replaced connection still open")
+	}
+	old.Close()
+
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := env.ws.DispatchJob(ctx, e.NodeID, j.ID, j.CommandName, j.CommandType); err != nil {
+		t.Fatalf("dispatch after the old connection ended: %v", err)
+	}
+	if got, err := current.NextJob(ctx); err != nil || got.ID != j.ID {
+		t.Errorf("current connection received %+v, %v", got, err)
+	}
+}
+
+func TestWSRegistryKeepsNewerClient(t *testing.T) {
+	jobs := job.NewService(memory.NewJobRepository(memory.NewDB()), nil, testOutputLimits)
+	h := NewWSHandler(jobs, nil, nil, nil, time.Second, 0)
+	newClient := func() *Client {
+		return &Client{NodeID: "node-1", Send: make(chan []byte, 1), log: slog.Default()}
+	}
+	old, current := newClient(), newClient()
+
+	h.registerClient(old)
+	h.registerClient(current)
+	select {
+	case _, open := <-old.Send:
+		if open {
+			t.Error("replaced client's send queue still open")
+		}
+	default:
+		t.Error("replaced client's send queue still open")
+	}
+	h.unregisterClient(old)
+	if h.clients["node-1"] != current {
+		t.Fatal("unregistering the replaced client removed the current one")
+	}
+	h.unregisterClient(current)
+	if _, ok := h.clients["node-1"]; ok {
+		t.Error("current client still registered")
+	}
+}
+
+func TestWSRejectsBadToken(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, token := range []string{"", platform.TokenApiPrefix + "unknown"} {
+		if _, err := agentsim.DialWS(ctx, env.srv.URL, token); err == nil {
+			t.Errorf("token %q: connected", token)
+		}
+	}
+}
+
+func TestWSDrain(t *testing.T) {
+	env := newAgentEnv(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	e, a := env.connectNode(t, ctx, "web-1")
+	env.ws.Drain("deploy", 3*time.Second)
+	s, err := a.NextShutdown(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Reason != "deploy" || s.ReconnectAfter != 3*time.Second {
+		t.Errorf("shutdown notice = %+v", s)
+	}
+	if err := env.ws.DispatchJob(ctx, e.NodeID, "job", "uptime", nodecommand.CommandTypeDefault); !errors.Is(err, errServerShuttingDown) {
+		t.Errorf("dispatch while draining = %v", err)
+	}
+
+	// Jobs in progress can still be reported while draining
+	j, err := env.jobs.Dispatch(ctx, e.NodeID, "uptime", nodecommand.CommandTypeDefault)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Report(ctx, j.ID, agentsim.Result{Status: job.StatusCompleted}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.Ping(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if stored, err := env.jobs.GetByID(ctx, j.ID); err != nil || stored.Status != job.StatusCompleted {
+		t.Errorf("stored job = %+v, %v", stored, err)
+	}
+
+	if _, err := agentsim.DialWS(ctx, env.srv.URL, "irrelevant"); err == nil {
+		t.Error("connected while draining")
+	}
+}
@@ -0,0 +1,72 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDMetadata es la clave de metadata con la que un cliente gRPC puede
+// propagar su id de petición; si no llega se genera uno
+const RequestIDMetadata = "x-request-id"
+
+func grpcContext(ctx context.Context, method string) context.Context {
+	reqID := ""
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(RequestIDMetadata); len(v) > 0 {
+			reqID = v[0]
+		}
+	}
+	if reqID == "" {
+		reqID = uuid.NewString()
+	}
+	return NewContext(ctx, slog.Default().With("request_id", reqID, "grpc_method", method))
+}
+
+func logCall(ctx context.Context, msg string, start time.Time, err error) {
+	code := status.Code(err)
+	lvl := slog.LevelInfo
+	switch code {
+	case codes.OK, codes.Canceled:
+	case codes.Internal, codes.Unknown, codes.DataLoss:
+		lvl = slog.LevelError
+	default:
+		lvl = slog.LevelWarn
+	}
+	FromContext(ctx).Log(ctx, lvl, msg, "grpc_code", code.String(), "duration_ms", time.Since(start).Milliseconds())
+}
+
+// UnaryServerInterceptor pone en el contexto un logger con el id de la
+// petición y el método, y registra cada llamada al terminar
+func UnaryServerInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	ctx = grpcContext(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	logCall(ctx, "grpc call", start, err)
+	return resp, err
+}
+
+// StreamServerInterceptor hace lo mismo para los streams; el handler ve el
+// logger a través de ss.Context()
+func StreamServerInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	ctx := grpcContext(ss.Context(), info.FullMethod)
+	err := handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	logCall(ctx, "grpc stream closed", start, err)
+	return err
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,80 @@
+// Package logging configura slog con salida JSON y un nivel que se puede
+// cambiar en caliente, y transporta por el contexto un logger con los datos
+// de correlación de la petición (request_id, user_id, node_id).
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+)
+
+// level es el nivel mínimo del logger por defecto; SetLevel lo cambia sin
+// reiniciar
+var level = new(slog.LevelVar)
+
+// Setup instala como logger por defecto un handler JSON sobre w con el nivel
+// indicado. Lo que aún se escriba con el paquete log sale por el mismo
+// handler con nivel INFO.
+func Setup(w io.Writer, l slog.Level) *slog.Logger {
+	level.Set(l)
+	logger := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level}))
+	slog.SetDefault(logger)
+	return logger
+}
+
+// Level devuelve el nivel mínimo actual
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetLevel cambia el nivel mínimo de todos los loggers derivados del de
+// Setup, incluidos los que ya viven en contextos
+func SetLevel(l slog.Level) {
+	level.Set(l)
+}
+
+// ParseLevel acepta debug, info, warn o error (sin distinguir mayúsculas)
+func ParseLevel(s string) (slog.Level, error) {
+	var l slog.Level
+	err := l.UnmarshalText([]byte(strings.TrimSpace(s)))
+	return l, err
+}
+
+type ctxKey struct{}
+
+// entry es el logger de una petición. Es mutable para que los datos que se
+// conocen tarde (el usuario tras autenticar, el nodo tras el handshake)
+// también aparezcan en la línea de acceso que se escribe al terminar.
+type entry struct {
+	mu     sync.RWMutex
+	logger *slog.Logger
+}
+
+// NewContext devuelve un contexto que lleva l como logger de la petición
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &entry{logger: l})
+}
+
+// FromContext devuelve el logger de la petición o, si no hay, el por defecto
+func FromContext(ctx context.Context) *slog.Logger {
+	if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+		return e.logger
+	}
+	return slog.Default()
+}
+
+// AddAttrs añade atributos al logger de la petición, visibles para todo el
+// que lo obtenga después con FromContext. Sin logger en el contexto no hace
+// nada.
+func AddAttrs(ctx context.Context, args ...any) {
+	if e, ok := ctx.Value(ctxKey{}).(*entry); ok {
+		e.mu.Lock()
+		e.logger = e.logger.With(args...)
+		e.mu.Unlock()
+	}
+}
@@ -0,0 +1,64 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func decodeLines(t *testing.T, b *bytes.Buffer) []map[string]any {
+	t.Helper()
+	var out []map[string]any
+	dec := json.NewDecoder(b)
+	for dec.More() {
+		var m map[string]any
+		if err := dec.Decode(&m); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+func TestAddAttrsIsVisibleToLaterLookups(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(&buf, slog.LevelInfo)
+	t.Cleanup(func() { Setup(new(bytes.Buffer), slog.LevelInfo) })
+
+	ctx := NewContext(context.Background(), slog.Default().With("request_id", "req-1"))
+	AddAttrs(ctx, "user_id", "u-1")
+	FromContext(ctx).Info("done")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 {
+		t.Fatalf("got %d lines", len(lines))
+	}
+	if lines[0]["request_id"] != "req-1" || lines[0]["user_id"] != "u-1" {
+		t.Errorf("line = %v", lines[0])
+	}
+}
+
+func TestSetLevelAffectsExistingLoggers(t *testing.T) {
+	var buf bytes.Buffer
+	Setup(&buf, slog.LevelInfo)
+	t.Cleanup(func() { Setup(new(bytes.Buffer), slog.LevelInfo) })
+
+	ctx := NewContext(context.Background(), slog.Default().With("node_id", "n-1"))
+	FromContext(ctx).Debug("hidden")
+	SetLevel(slog.LevelDebug)
+	FromContext(ctx).Debug("shown")
+
+	lines := decodeLines(t, &buf)
+	if len(lines) != 1 || lines[0]["msg"] != "shown" {
+		t.Errorf("lines = %v", lines)
+	}
+}
+
+func TestFromContextFallsBackToDefault(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Error("expected the default logger")
+	}
+	AddAttrs(context.Background(), "ignored", true) // must not panic
+}
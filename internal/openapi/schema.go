@@ -0,0 +1,195 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema es el subconjunto de JSON Schema 2020-12 que usa el documento:
+// tipos (también como lista, para nullable), objetos, arrays, enum, límites
+// de longitud y valor, formatos uuid/email/date-time, oneOf y allOf.
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 Types              `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *bool              `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+	AllOf                []*Schema          `json:"allOf"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+
+	resolved *Schema // destino de $ref, rellenado por Spec.resolve
+}
+
+// Types acepta "type": "string" y "type": ["string", "null"]
+type Types []string
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var one string
+	if err := json.Unmarshal(data, &one); err == nil {
+		*t = Types{one}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*t = many
+	return nil
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Validate comprueba v (decodificado con json.Decoder.UseNumber) contra el
+// esquema y devuelve un mensaje por cada incumplimiento, con la ruta del
+// campo. Una lista vacía significa que el valor es válido.
+func (s *Schema) Validate(v any) []string {
+	var errs []string
+	s.validate("body", v, &errs)
+	return errs
+}
+
+func (s *Schema) validate(path string, v any, errs *[]string) {
+	if s == nil {
+		return
+	}
+	if s.resolved != nil {
+		s.resolved.validate(path, v, errs)
+		return
+	}
+	fail := func(format string, args ...any) {
+		*errs = append(*errs, path+": "+fmt.Sprintf(format, args...))
+	}
+
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(v, t) }) {
+		fail("must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return fmt.Sprint(e) == fmt.Sprint(v) }) {
+		fail("must be one of %v", s.Enum)
+	}
+
+	for _, sub := range s.AllOf {
+		sub.validate(path, v, errs)
+	}
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, sub := range s.OneOf {
+			if len(sub.Validate(v)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			fail("must match exactly one schema")
+		}
+	}
+
+	switch val := v.(type) {
+	case string:
+		s.validateString(val, fail)
+	case json.Number:
+		n, _ := val.Float64()
+		if s.Minimum != nil && n < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && n > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	case []any:
+		for i, item := range val {
+			s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := val[name]; !ok {
+				*errs = append(*errs, path+"."+name+": is required")
+			}
+		}
+		names := make([]string, 0, len(val))
+		for name := range val {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					*errs = append(*errs, path+"."+name+": is not allowed")
+				}
+				continue
+			}
+			prop.validate(path+"."+name, val[name], errs)
+		}
+	}
+}
+
+func (s *Schema) validateString(v string, fail func(string, ...any)) {
+	n := utf8.RuneCountInString(v)
+	if s.MinLength != nil && n < *s.MinLength {
+		if *s.MinLength == 1 {
+			fail("must not be empty")
+		} else {
+			fail("must be at least %d characters", *s.MinLength)
+		}
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		fail("must be at most %d characters", *s.MaxLength)
+	}
+	switch s.Format {
+	case "uuid":
+		if !uuidPattern.MatchString(v) {
+			fail("must be a UUID")
+		}
+	case "email":
+		if _, err := mail.ParseAddress(v); err != nil {
+			fail("must be an email address")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			fail("must be an RFC 3339 date-time")
+		}
+	}
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "null":
+		return v == nil
+	case "boolean":
+		_, ok := v.(bool)
+		return ok
+	case "string":
+		_, ok := v.(string)
+		return ok
+	case "number":
+		_, ok := v.(json.Number)
+		return ok
+	case "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			return false
+		}
+		_, err := n.Int64()
+		return err == nil
+	case "array":
+		_, ok := v.([]any)
+		return ok
+	case "object":
+		_, ok := v.(map[string]any)
+		return ok
+	}
+	return false
+}
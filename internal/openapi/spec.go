@@ -0,0 +1,172 @@
+// Package openapi contiene la especificación OpenAPI 3.1 de la API y un
+// validador mínimo de cuerpos JSON contra sus esquemas. El documento se
+// embebe en el binario, así que lo que se sirve en /openapi.json y lo que se
+// valida en cada petición es exactamente lo mismo.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+//go:embed openapi.json
+var document []byte
+
+// Document devuelve el documento tal cual se publica
+func Document() []byte {
+	return document
+}
+
+// Spec es la parte del documento que necesita el validador
+type Spec struct {
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `json:"schemas"`
+	} `json:"components"`
+
+	routes []route
+}
+
+// Operation describe un método de una ruta
+type Operation struct {
+	OperationID string       `json:"operationId"`
+	RequestBody *RequestBody `json:"requestBody"`
+}
+
+// RequestBody es el cuerpo esperado de una operación
+type RequestBody struct {
+	Required bool `json:"required"`
+	Content  map[string]struct {
+		Schema *Schema `json:"schema"`
+	} `json:"content"`
+}
+
+// JSONSchema devuelve el esquema de application/json, o nil si no hay
+func (b *RequestBody) JSONSchema() *Schema {
+	if b == nil {
+		return nil
+	}
+	return b.Content["application/json"].Schema
+}
+
+type route struct {
+	template string
+	segments []string
+}
+
+var (
+	loadOnce sync.Once
+	loaded   *Spec
+	loadErr  error
+)
+
+// Load parsea el documento embebido y resuelve sus $ref. El resultado se
+// comparte entre llamadas.
+func Load() (*Spec, error) {
+	loadOnce.Do(func() {
+		loaded, loadErr = Parse(document)
+	})
+	return loaded, loadErr
+}
+
+// Parse construye un Spec a partir de un documento OpenAPI en JSON
+func Parse(data []byte) (*Spec, error) {
+	var s Spec
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("parse openapi: %w", err)
+	}
+	for template, ops := range s.Paths {
+		for method, op := range ops {
+			if op.RequestBody == nil {
+				continue
+			}
+			if err := s.resolve(op.RequestBody.JSONSchema(), map[*Schema]bool{}); err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), template, err)
+			}
+		}
+		s.routes = append(s.routes, route{template: template, segments: split(template)})
+	}
+	return &s, nil
+}
+
+// resolve sustituye recursivamente cada $ref por el esquema de components
+func (s *Spec) resolve(schema *Schema, seen map[*Schema]bool) error {
+	if schema == nil || seen[schema] {
+		return nil
+	}
+	seen[schema] = true
+	if schema.Ref != "" {
+		name := strings.TrimPrefix(schema.Ref, "#/components/schemas/")
+		target, ok := s.Components.Schemas[name]
+		if !ok {
+			return fmt.Errorf("unknown schema %q", schema.Ref)
+		}
+		schema.resolved = target
+		return s.resolve(target, seen)
+	}
+	children := append([]*Schema{schema.Items}, schema.OneOf...)
+	children = append(children, schema.AllOf...)
+	for _, p := range schema.Properties {
+		children = append(children, p)
+	}
+	for _, c := range children {
+		if err := s.resolve(c, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has indica si el documento declara method en la plantilla de ruta template
+// (con la sintaxis {param} de OpenAPI)
+func (s *Spec) Has(method, template string) bool {
+	_, ok := s.Paths[template][strings.ToLower(method)]
+	return ok
+}
+
+// FindOperation busca la operación que atiende una petición real. Las
+// rutas literales ganan a las que tienen parámetros, como en chi.
+func (s *Spec) FindOperation(method, path string) (*Operation, bool) {
+	segments := split(path)
+	best, bestScore := "", -1
+	for _, rt := range s.routes {
+		if score, ok := match(rt.segments, segments); ok && score > bestScore {
+			if _, has := s.Paths[rt.template][strings.ToLower(method)]; has {
+				best, bestScore = rt.template, score
+			}
+		}
+	}
+	if bestScore < 0 {
+		return nil, false
+	}
+	return s.Paths[best][strings.ToLower(method)], true
+}
+
+// match devuelve cuántos segmentos literales coinciden
+func match(template, path []string) (int, bool) {
+	if len(template) != len(path) {
+		return 0, false
+	}
+	score := 0
+	for i, seg := range template {
+		if strings.HasPrefix(seg, "{") && strings.HasSuffix(seg, "}") {
+			continue
+		}
+		if seg != path[i] {
+			return 0, false
+		}
+		score++
+	}
+	return score, true
+}
+
+func split(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
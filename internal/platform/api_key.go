@@ -0,0 +1,33 @@
+package platform
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	APIKeyPrefix        = "autohost-key_"
+	apiKeyBytes         = 32 // 32 bytes => ~43 chars base64
+	apiKeyDisplayLength = len(APIKeyPrefix) + 8
+)
+
+// GenerateAPIKey genera una API key personal, su hash y el prefijo visible
+// con el que el usuario la reconoce en los listados.
+func GenerateAPIKey() (plain, hash, display string, err error) {
+	buf := make([]byte, apiKeyBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	plain = APIKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return plain, HashAPIKey(plain), plain[:apiKeyDisplayLength], nil
+}
+
+// HashAPIKey genera el hash de una API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
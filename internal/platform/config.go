@@ -1,13 +0,0 @@
-package platform
-
-import (
-	"log"
-
-	"github.com/joho/godotenv"
-)
-
-func LoadEnv() {
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found, using system env")
-	}
-}
@@ -0,0 +1,69 @@
+package platform
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidSort = errors.New("invalid sort")
+
+// Sort es el orden de un listado: un campo y la dirección. En la query se
+// escribe "campo" (ascendente) o "-campo" (descendente).
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+func (s Sort) String() string {
+	if s.Desc {
+		return "-" + s.Field
+	}
+	return s.Field
+}
+
+// ParseSort interpreta v ("campo" o "-campo") aceptando solo los campos
+// indicados; si v está vacío devuelve def.
+func ParseSort(v string, def Sort, fields ...string) (Sort, error) {
+	if v == "" {
+		return def, nil
+	}
+	s := Sort{Field: strings.TrimPrefix(v, "-"), Desc: strings.HasPrefix(v, "-")}
+	if !slices.Contains(fields, s.Field) {
+		return Sort{}, ErrInvalidSort
+	}
+	return s, nil
+}
+
+// Cursor marca la última fila de una página para paginar por keyset: la
+// siguiente página empieza justo después de (Value, ID) en el orden Sort.
+// El cliente lo recibe opaco y solo sirve con el mismo orden.
+type Cursor struct {
+	Sort  string `json:"s"`
+	Value string `json:"v"`
+	ID    string `json:"id"`
+}
+
+// Encode devuelve el cursor en base64url para usarlo en una URL
+func (c Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor es la inversa de Cursor.Encode
+func DecodeCursor(s string) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.Sort == "" || c.ID == "" {
+		return nil, errors.New("incomplete cursor")
+	}
+	return &c, nil
+}
@@ -0,0 +1,31 @@
+package platform
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	InvitationTokenPrefix = "autohost-inv_"
+	invitationTokenBytes  = 32 // 32 bytes => ~43 chars base64
+)
+
+// GenerateInvitationToken genera un token de invitación seguro y su hash
+func GenerateInvitationToken() (plain string, hash string, err error) {
+	buf := make([]byte, invitationTokenBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	plain = InvitationTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return plain, HashInvitationToken(plain), nil
+}
+
+// HashInvitationToken genera el hash de un token de invitación
+func HashInvitationToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
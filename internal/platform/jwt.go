@@ -0,0 +1,347 @@
+package platform
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Algoritmos de firma soportados para los access tokens
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+const rsaKeyBits = 2048
+
+var (
+	ErrNoSigningKey         = errors.New("no active signing key")
+	ErrUnknownKeyID         = errors.New("unknown key id")
+	ErrUnsupportedKeyType   = errors.New("unsupported key type")
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+type JWTClaims struct {
+	UserID string `json:"uid"`
+	Email  string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+// SigningKey es una clave asimétrica identificada por su kid
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	Private   crypto.Signer
+}
+
+// Public devuelve la clave pública
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.Private.Public()
+}
+
+// GenerateSigningKey crea una clave nueva (RSA 2048 o Ed25519). El kid es la
+// huella SHA-256 de la clave pública.
+func GenerateSigningKey(alg string) (*SigningKey, error) {
+	var priv crypto.Signer
+	switch alg {
+	case AlgRS256:
+		k, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		priv = k
+	case AlgEdDSA:
+		_, k, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		priv = k
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedAlgorithm, alg)
+	}
+	return newSigningKey("", priv)
+}
+
+func newSigningKey(kid string, priv crypto.Signer) (*SigningKey, error) {
+	var alg string
+	switch priv.(type) {
+	case *rsa.PrivateKey:
+		alg = AlgRS256
+	case ed25519.PrivateKey:
+		alg = AlgEdDSA
+	default:
+		return nil, ErrUnsupportedKeyType
+	}
+
+	if kid == "" {
+		der, err := x509.MarshalPKIXPublicKey(priv.Public())
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(der)
+		kid = base64.RawURLEncoding.EncodeToString(sum[:16])
+	}
+	return &SigningKey{ID: kid, Algorithm: alg, Private: priv}, nil
+}
+
+// MarshalPrivateKeyPEM codifica la clave privada como PEM PKCS#8
+func MarshalPrivateKeyPEM(k *SigningKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(k.Private)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// MarshalPublicKeyPEM codifica la clave pública como PEM PKIX
+func MarshalPublicKeyPEM(k *SigningKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(k.Public())
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParseSigningKeyPEM decodifica una clave privada PEM (PKCS#8 o PKCS#1)
+func ParseSigningKeyPEM(kid string, data []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("invalid PEM data")
+	}
+
+	var priv any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		priv, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	default:
+		priv, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	signer, ok := priv.(crypto.Signer)
+	if !ok {
+		return nil, ErrUnsupportedKeyType
+	}
+	return newSigningKey(kid, signer)
+}
+
+// LoadSigningKeysDir carga todas las claves *.pem de dir; el kid es el nombre
+// del fichero sin extensión.
+func LoadSigningKeysDir(dir string) ([]*SigningKey, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		kid := strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+		k, err := ParseSigningKeyPEM(kid, data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+// JWTConfig son los parámetros comunes de emisión y validación
+type JWTConfig struct {
+	Issuer    string
+	Audience  string
+	AccessTTL time.Duration
+}
+
+// KeyRing firma access tokens con la clave activa y los valida con cualquiera
+// de las claves de verificación, lo que permite rotar sin invalidar los tokens
+// emitidos con la clave anterior.
+type KeyRing struct {
+	cfg JWTConfig
+
+	mu      sync.RWMutex
+	signing *SigningKey
+	keys    map[string]*SigningKey
+}
+
+// NewKeyRing crea un llavero vacío
+func NewKeyRing(cfg JWTConfig) *KeyRing {
+	return &KeyRing{cfg: cfg, keys: map[string]*SigningKey{}}
+}
+
+// Config devuelve la configuración de emisión
+func (kr *KeyRing) Config() JWTConfig {
+	return kr.cfg
+}
+
+// SetKeys reemplaza las claves: signing firma y además verifica; verification
+// solo verifican.
+func (kr *KeyRing) SetKeys(signing *SigningKey, verification []*SigningKey) {
+	keys := make(map[string]*SigningKey, len(verification)+1)
+	for _, k := range verification {
+		keys[k.ID] = k
+	}
+	if signing != nil {
+		keys[signing.ID] = signing
+	}
+
+	kr.mu.Lock()
+	kr.signing = signing
+	kr.keys = keys
+	kr.mu.Unlock()
+}
+
+// SignAccessToken emite un access token firmado con la clave activa
+func (kr *KeyRing) SignAccessToken(userID string, email string) (string, error) {
+	kr.mu.RLock()
+	key := kr.signing
+	kr.mu.RUnlock()
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	now := time.Now().UTC()
+	claims := JWTClaims{
+		UserID: userID,
+		Email:  email,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    kr.cfg.Issuer,
+			Subject:   userID,
+			Audience:  jwt.ClaimStrings{kr.cfg.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(kr.cfg.AccessTTL)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.Private)
+}
+
+// ParseAccessToken valida firma, kid, expiración, issuer y audience
+func (kr *KeyRing) ParseAccessToken(tok string) (*JWTClaims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods([]string{AlgRS256, AlgEdDSA}),
+		jwt.WithIssuer(kr.cfg.Issuer),
+		jwt.WithAudience(kr.cfg.Audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+
+	token, err := parser.ParseWithClaims(tok, &JWTClaims{}, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+
+		kr.mu.RLock()
+		key, ok := kr.keys[kid]
+		kr.mu.RUnlock()
+		if !ok {
+			return nil, ErrUnknownKeyID
+		}
+		// El alg de la cabecera debe corresponder al tipo de la clave
+		if t.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method: %s", t.Method.Alg())
+		}
+		return key.Public(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
+		return claims, nil
+	}
+	return nil, jwt.ErrSignatureInvalid
+}
+
+// JWK es una clave pública en formato RFC 7517
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// JWKSet es el documento servido en /.well-known/jwks.json
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS devuelve las claves públicas de verificación
+func (kr *KeyRing) JWKS() JWKSet {
+	kr.mu.RLock()
+	defer kr.mu.RUnlock()
+
+	set := JWKSet{Keys: make([]JWK, 0, len(kr.keys))}
+	for _, k := range kr.keys {
+		jwk := JWK{Use: "sig", Kid: k.ID, Alg: k.Algorithm}
+		switch pub := k.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
+
+// LoadDir instala las claves PEM de dir. Firma la clave signingKID o, si está
+// vacío, la última por orden alfabético (p. ej. nombres con fecha).
+func (kr *KeyRing) LoadDir(dir, signingKID string) error {
+	keys, err := LoadSigningKeysDir(dir)
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("%w in %s", ErrNoSigningKey, dir)
+	}
+
+	signing := keys[len(keys)-1]
+	if signingKID != "" {
+		signing = nil
+		for _, k := range keys {
+			if k.ID == signingKID {
+				signing = k
+			}
+		}
+		if signing == nil {
+			return fmt.Errorf("%w: %s", ErrUnknownKeyID, signingKID)
+		}
+	}
+
+	kr.SetKeys(signing, keys)
+	return nil
+}
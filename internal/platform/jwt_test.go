@@ -0,0 +1,199 @@
+package platform
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var testJWTConfig = JWTConfig{Issuer: "autohost", Audience: "autohost-api", AccessTTL: time.Minute}
+
+func generateKey(t *testing.T, alg string) *SigningKey {
+	t.Helper()
+	k, err := GenerateSigningKey(alg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+// signWith signs valid access token claims for testJWTConfig with key,
+// forcing method and kid so tests can forge headers.
+func signWith(t *testing.T, method jwt.SigningMethod, kid string, key any) string {
+	t.Helper()
+	now := time.Now()
+	tok := jwt.NewWithClaims(method, JWTClaims{
+		UserID: "user-1",
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    testJWTConfig.Issuer,
+			Subject:   "user-1",
+			Audience:  jwt.ClaimStrings{testJWTConfig.Audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Minute)),
+		},
+	})
+	if kid != "" {
+		tok.Header["kid"] = kid
+	}
+	signed, err := tok.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestKeyRingSignAndParse(t *testing.T) {
+	for _, alg := range []string{AlgRS256, AlgEdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			kr := NewKeyRing(testJWTConfig)
+			key := generateKey(t, alg)
+			kr.SetKeys(key, nil)
+
+			tok, err := kr.SignAccessToken("user-1", "dev@example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			parsed, _, err := jwt.NewParser().ParseUnverified(tok, &JWTClaims{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if parsed.Header["kid"] != key.ID || parsed.Header["alg"] != alg {
+				t.Errorf("header = %v", parsed.Header)
+			}
+
+			claims, err := kr.ParseAccessToken(tok)
+			if err != nil {
+				t.Fatalf("ParseAccessToken: %v", err)
+			}
+			if claims.UserID != "user-1" || claims.Email != "dev@example.com" || claims.Subject != "user-1" ||
+				claims.Issuer != testJWTConfig.Issuer || claims.ExpiresAt == nil {
+				t.Errorf("claims = %+v", claims)
+			}
+		})
+	}
+
+	if _, err := NewKeyRing(testJWTConfig).SignAccessToken("user-1", "dev@example.com"); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("empty ring: error = %v, want ErrNoSigningKey", err)
+	}
+}
+
+func TestKeyRingRejects(t *testing.T) {
+	rsaKey := generateKey(t, AlgRS256)
+	edKey := generateKey(t, AlgEdDSA)
+	outsider := generateKey(t, AlgRS256)
+	kr := NewKeyRing(testJWTConfig)
+	kr.SetKeys(rsaKey, []*SigningKey{edKey})
+
+	otherConfig := func(cfg JWTConfig) string {
+		other := NewKeyRing(cfg)
+		other.SetKeys(rsaKey, nil)
+		tok, err := other.SignAccessToken("user-1", "dev@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tok
+	}
+	rsaPub, err := MarshalPublicKeyPEM(rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		tok  string
+		want error // nil: any error
+	}{
+		{"unknown kid", signWith(t, jwt.SigningMethodRS256, outsider.ID, outsider.Private), ErrUnknownKeyID},
+		{"missing kid", signWith(t, jwt.SigningMethodRS256, "", rsaKey.Private), ErrUnknownKeyID},
+		{"kid of a key of another type", signWith(t, jwt.SigningMethodEdDSA, rsaKey.ID, edKey.Private), nil},
+		{"hmac keyed with the public key", signWith(t, jwt.SigningMethodHS256, rsaKey.ID, rsaPub), nil},
+		{"alg none", signWith(t, jwt.SigningMethodNone, rsaKey.ID, jwt.UnsafeAllowNoneSignatureType), nil},
+		{"another issuer", otherConfig(JWTConfig{Issuer: "evil", Audience: testJWTConfig.Audience, AccessTTL: time.Minute}), jwt.ErrTokenInvalidIssuer},
+		{"another audience", otherConfig(JWTConfig{Issuer: testJWTConfig.Issuer, Audience: "other", AccessTTL: time.Minute}), jwt.ErrTokenInvalidAudience},
+		{"expired", otherConfig(JWTConfig{Issuer: testJWTConfig.Issuer, Audience: testJWTConfig.Audience, AccessTTL: -time.Minute}), jwt.ErrTokenExpired},
+		{"tampered", signWith(t, jwt.SigningMethodRS256, rsaKey.ID, rsaKey.Private) + "x", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := kr.ParseAccessToken(tt.tok)
+			if err == nil {
+				t.Fatalf("accepted, claims = %+v", claims)
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// TestKeyRingRotation covers the grace period: after a rotation the retired
+// key still verifies the tokens it signed but no longer signs, and once it
+// leaves the ring its tokens are rejected.
+func TestKeyRingRotation(t *testing.T) {
+	old, next := generateKey(t, AlgRS256), generateKey(t, AlgEdDSA)
+	kr := NewKeyRing(testJWTConfig)
+	kr.SetKeys(old, nil)
+	oldTok, err := kr.SignAccessToken("user-1", "dev@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kr.SetKeys(next, []*SigningKey{old})
+	if _, err := kr.ParseAccessToken(oldTok); err != nil {
+		t.Errorf("token of the retired key during the grace period: %v", err)
+	}
+	newTok, err := kr.SignAccessToken("user-1", "dev@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed, _, err := jwt.NewParser().ParseUnverified(newTok, &JWTClaims{}); err != nil || parsed.Header["kid"] != next.ID {
+		t.Errorf("new token signed by kid %v, want %s", parsed.Header["kid"], next.ID)
+	}
+
+	kr.SetKeys(next, nil)
+	if _, err := kr.ParseAccessToken(oldTok); !errors.Is(err, ErrUnknownKeyID) {
+		t.Errorf("token of a dropped key: error = %v, want ErrUnknownKeyID", err)
+	}
+	if _, err := kr.ParseAccessToken(newTok); err != nil {
+		t.Errorf("token of the signing key: %v", err)
+	}
+}
+
+func TestKeyRingJWKS(t *testing.T) {
+	rsaKey, edKey := generateKey(t, AlgRS256), generateKey(t, AlgEdDSA)
+	kr := NewKeyRing(testJWTConfig)
+	kr.SetKeys(rsaKey, []*SigningKey{edKey})
+
+	set := kr.JWKS()
+	if len(set.Keys) != 2 || set.Keys[0].Kid > set.Keys[1].Kid {
+		t.Fatalf("JWKS = %+v, want both keys sorted by kid", set)
+	}
+	for _, jwk := range set.Keys {
+		if jwk.Use != "sig" {
+			t.Errorf("%s: use = %q", jwk.Kid, jwk.Use)
+		}
+		switch jwk.Kid {
+		case rsaKey.ID:
+			pub := rsaKey.Public().(*rsa.PublicKey)
+			n, _ := base64.RawURLEncoding.DecodeString(jwk.N)
+			e, _ := base64.RawURLEncoding.DecodeString(jwk.E)
+			if jwk.Kty != "RSA" || jwk.Alg != AlgRS256 || new(big.Int).SetBytes(n).Cmp(pub.N) != 0 ||
+				new(big.Int).SetBytes(e).Int64() != int64(pub.E) || jwk.X != "" {
+				t.Errorf("RSA JWK = %+v", jwk)
+			}
+		case edKey.ID:
+			x, _ := base64.RawURLEncoding.DecodeString(jwk.X)
+			if jwk.Kty != "OKP" || jwk.Crv != "Ed25519" || jwk.Alg != AlgEdDSA ||
+				!edKey.Public().(ed25519.PublicKey).Equal(ed25519.PublicKey(x)) || jwk.N != "" {
+				t.Errorf("Ed25519 JWK = %+v", jwk)
+			}
+		default:
+			t.Errorf("unexpected kid %s", jwk.Kid)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package platform
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Mail is a plain-text email message.
+type Mail struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends transactional emails (invitations, password resets, ...).
+// Production deployments plug in an SMTP or provider implementation; LogMailer
+// and FileMailer cover local development and tests.
+type Mailer interface {
+	Send(ctx context.Context, m Mail) error
+}
+
+// LogMailer writes every message to the standard logger.
+type LogMailer struct{}
+
+func (LogMailer) Send(_ context.Context, m Mail) error {
+	slog.Info("mail", "to", m.To, "subject", m.Subject, "body", m.Body)
+	return nil
+}
+
+// FileMailer writes every message as a .eml file under Dir.
+type FileMailer struct {
+	Dir string
+	mu  sync.Mutex
+	seq int
+}
+
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("create mail dir: %w", err)
+	}
+	return &FileMailer{Dir: dir}, nil
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^a-zA-Z0-9@._-]`)
+
+func (f *FileMailer) Send(_ context.Context, m Mail) error {
+	f.mu.Lock()
+	f.seq++
+	seq := f.seq
+	f.mu.Unlock()
+
+	name := fmt.Sprintf("%s-%04d-%s.eml",
+		time.Now().UTC().Format("20060102T150405"), seq, unsafeFileChars.ReplaceAllString(m.To, "_"))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "To: %s\r\nSubject: %s\r\nDate: %s\r\n\r\n%s\r\n",
+		m.To, m.Subject, time.Now().UTC().Format(time.RFC1123Z), m.Body)
+
+	return os.WriteFile(filepath.Join(f.Dir, name), []byte(b.String()), 0o644)
+}
+
+// NewMailer selecciona la implementación según el driver ("log" | "file");
+// dir es el directorio del driver file.
+func NewMailer(driver, dir string) (Mailer, error) {
+	switch strings.ToLower(driver) {
+	case "", "log":
+		return LogMailer{}, nil
+	case "file":
+		return NewFileMailer(dir)
+	default:
+		return nil, fmt.Errorf("unknown mail driver %q", driver)
+	}
+}
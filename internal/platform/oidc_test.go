@@ -0,0 +1,106 @@
+package platform
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform/oidctest"
+)
+
+// stubClient returns a client of the stub provider p.
+func stubClient(p *oidctest.Provider) *OIDCProvider {
+	return NewOIDCProvider(OIDCConfig{
+		IssuerURL:   p.URL,
+		ClientID:    p.ClientID,
+		RedirectURL: "http://localhost:3000/auth/callback",
+		Scopes:      []string{"openid", "email"},
+	}, p.Client())
+}
+
+func TestOIDCProviderExchange(t *testing.T) {
+	ctx := context.Background()
+	stub := oidctest.NewProvider(t)
+	provider := stubClient(stub)
+
+	verifier, challenge, err := GeneratePKCE()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", challenge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := stub.Authorize(authURL)
+
+	id, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if id.Subject != "user-123" || id.Email != "dev@example.com" || !id.EmailVerified || id.Issuer != stub.URL {
+		t.Fatalf("unexpected identity: %+v", id)
+	}
+}
+
+func TestOIDCProviderRejects(t *testing.T) {
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		setup    func(*oidctest.Provider)
+		verifier func(real string) string
+		nonce    string
+		want     error
+	}{
+		{
+			name:  "nonce mismatch",
+			nonce: "other",
+			want:  ErrOIDCInvalidToken,
+		},
+		{
+			name:  "wrong audience",
+			setup: func(p *oidctest.Provider) { p.Audience = "someone-else" },
+			want:  ErrOIDCInvalidToken,
+		},
+		{
+			name:     "wrong pkce verifier",
+			verifier: func(string) string { return "not-the-verifier" },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := oidctest.NewProvider(t)
+			if tt.setup != nil {
+				tt.setup(stub)
+			}
+			provider := stubClient(stub)
+
+			verifier, challenge, err := GeneratePKCE()
+			if err != nil {
+				t.Fatal(err)
+			}
+			authURL, err := provider.AuthCodeURL(ctx, "state", "nonce-1", challenge)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code := stub.Authorize(authURL)
+
+			if tt.verifier != nil {
+				verifier = tt.verifier(verifier)
+			}
+			nonce := "nonce-1"
+			if tt.nonce != "" {
+				nonce = tt.nonce
+			}
+
+			_, err = provider.Exchange(ctx, code, verifier, nonce)
+			if err == nil {
+				t.Fatal("expected error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("got %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
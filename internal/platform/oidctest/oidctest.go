@@ -0,0 +1,136 @@
+// Package oidctest provides a stub OIDC provider for tests.
+package oidctest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Provider is a minimal OIDC provider: it issues a code for every
+// authorization request it is handed and checks PKCE on the token endpoint.
+// Change the exported fields before Authorize to alter the next ID token.
+type Provider struct {
+	URL      string
+	ClientID string
+	Audience string // aud of issued ID tokens; defaults to ClientID
+	Subject  string
+	Email    string
+	Verified bool
+
+	t         *testing.T
+	srv       *httptest.Server
+	key       *rsa.PrivateKey
+	challenge string
+	nonce     string
+}
+
+// NewProvider starts a provider that is closed when the test ends.
+func NewProvider(t *testing.T) *Provider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Provider{
+		ClientID: "autohost",
+		Subject:  "user-123",
+		Email:    "dev@example.com",
+		Verified: true,
+		t:        t,
+		key:      key,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 p.URL,
+			"authorization_endpoint": p.URL + "/authorize",
+			"token_endpoint":         p.URL + "/token",
+			"jwks_uri":               p.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "stub",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", p.token)
+	p.srv = httptest.NewServer(mux)
+	p.URL = p.srv.URL
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+// Client returns an HTTP client that reaches the provider.
+func (p *Provider) Client() *http.Client {
+	return p.srv.Client()
+}
+
+// Authorize plays the user approving the login at authURL and returns the
+// code the provider would send back.
+func (p *Provider) Authorize(authURL string) string {
+	p.t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		p.t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("code_challenge_method") != "S256" || q.Get("client_id") != p.ClientID {
+		p.t.Fatalf("unexpected authorization request: %s", authURL)
+	}
+	p.challenge = q.Get("code_challenge")
+	p.nonce = q.Get("nonce")
+	return "stub-code"
+}
+
+func (p *Provider) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || r.Form.Get("code") != "stub-code" {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if base64.RawURLEncoding.EncodeToString(sum[:]) != p.challenge {
+		http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+		return
+	}
+
+	aud := p.Audience
+	if aud == "" {
+		aud = p.ClientID
+	}
+	now := time.Now()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"iss":            p.URL,
+		"sub":            p.Subject,
+		"aud":            aud,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Minute).Unix(),
+		"nonce":          p.nonce,
+		"email":          p.Email,
+		"email_verified": p.Verified,
+		"name":           "Dev",
+	})
+	tok.Header["kid"] = "stub"
+	signed, err := tok.SignedString(p.key)
+	if err != nil {
+		p.t.Error(err)
+		http.Error(w, `{"error":"server_error"}`, http.StatusInternalServerError)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]string{"access_token": "x", "id_token": signed, "token_type": "Bearer"})
+}
@@ -0,0 +1,140 @@
+package platform
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Parámetros TOTP (RFC 6238) compatibles con las apps de autenticación habituales
+const (
+	totpPeriod      = 30
+	totpDigits      = 6
+	totpSecretBytes = 20
+	// totpSkew acepta el código del intervalo anterior y del siguiente
+	totpSkew = 1
+
+	MFATokenPrefix = "autohost-mfa_"
+	mfaTokenBytes  = 32
+
+	recoveryCodeBytes = 10 // 16 caracteres base32
+)
+
+var base32NoPad = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateTOTPSecret genera un secreto TOTP aleatorio en base32
+func GenerateTOTPSecret() (string, error) {
+	buf := make([]byte, totpSecretBytes)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("rand.Read: %w", err)
+	}
+	return base32NoPad.EncodeToString(buf), nil
+}
+
+// TOTPProvisioningURI construye la URI otpauth:// que se muestra como QR
+func TOTPProvisioningURI(secret, issuer, account string) string {
+	q := url.Values{}
+	q.Set("secret", secret)
+	q.Set("issuer", issuer)
+	q.Set("algorithm", "SHA1")
+	q.Set("digits", fmt.Sprint(totpDigits))
+	q.Set("period", fmt.Sprint(totpPeriod))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return "otpauth://totp/" + label + "?" + q.Encode()
+}
+
+// TOTPCode calcula el código de secret para el instante t
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return hotp(key, uint64(t.Unix()/totpPeriod)), nil
+}
+
+// ValidateTOTP comprueba code contra secret en t ± totpSkew intervalos y
+// devuelve el intervalo que coincide, para poder rechazar su reutilización.
+func ValidateTOTP(secret, code string, t time.Time) (step int64, ok bool) {
+	key, err := base32NoPad.DecodeString(strings.ToUpper(secret))
+	if err != nil || len(code) != totpDigits {
+		return 0, false
+	}
+
+	current := t.Unix() / totpPeriod
+	for i := int64(-totpSkew); i <= totpSkew; i++ {
+		s := current + i
+		if subtle.ConstantTimeCompare([]byte(hotp(key, uint64(s))), []byte(code)) == 1 {
+			return s, true
+		}
+	}
+	return 0, false
+}
+
+// hotp implementa RFC 4226 con SHA-1
+func hotp(key []byte, counter uint64) string {
+	var msg [8]byte
+	binary.BigEndian.PutUint64(msg[:], counter)
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(msg[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	bin := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, bin%mod)
+}
+
+// GenerateRecoveryCodes genera n códigos de recuperación (xxxx-xxxx-xxxx-xxxx)
+// y sus hashes
+func GenerateRecoveryCodes(n int) (plain []string, hashes []string, err error) {
+	for i := 0; i < n; i++ {
+		buf := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, fmt.Errorf("rand.Read: %w", err)
+		}
+		raw := strings.ToLower(base32NoPad.EncodeToString(buf))
+		code := raw[0:4] + "-" + raw[4:8] + "-" + raw[8:12] + "-" + raw[12:16]
+		plain = append(plain, code)
+		hashes = append(hashes, HashRecoveryCode(code))
+	}
+	return plain, hashes, nil
+}
+
+// HashRecoveryCode normaliza (minúsculas, sin guiones ni espacios) y hashea un
+// código de recuperación
+func HashRecoveryCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateMFAToken genera el token del desafío MFA de un login y su hash
+func GenerateMFAToken() (plain string, hash string, err error) {
+	buf := make([]byte, mfaTokenBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+	plain = MFATokenPrefix + base32NoPad.EncodeToString(buf)
+	return plain, HashMFAToken(plain), nil
+}
+
+// HashMFAToken genera el hash de un token de desafío MFA
+func HashMFAToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
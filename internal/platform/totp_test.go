@@ -0,0 +1,106 @@
+package platform
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors, "12345678901234567890".
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B, truncated to six digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(rfcSecret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	step := now.Unix() / totpPeriod
+	codeAt := func(d time.Duration) string {
+		c, err := TOTPCode(rfcSecret, now.Add(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfcSecret, codeAt(0), step, true},
+		{"previous step", rfcSecret, codeAt(-totpPeriod * time.Second), step - 1, true},
+		{"next step", rfcSecret, codeAt(totpPeriod * time.Second), step + 1, true},
+		{"two steps back", rfcSecret, codeAt(-2 * totpPeriod * time.Second), 0, false},
+		{"two steps ahead", rfcSecret, codeAt(2 * totpPeriod * time.Second), 0, false},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", codeAt(0), step, true},
+		{"short code", rfcSecret, codeAt(0)[:5], 0, false},
+		{"long code", rfcSecret, codeAt(0) + "0", 0, false},
+		{"invalid secret", "not base32!", codeAt(0), 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gotStep, ok := ValidateTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || gotStep != tt.wantStep {
+				t.Errorf("ValidateTOTP = %d, %v; want %d, %v", gotStep, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	plain, hashes, err := GenerateRecoveryCodes(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plain) != 3 || len(hashes) != 3 {
+		t.Fatalf("GenerateRecoveryCodes(3) = %d codes, %d hashes", len(plain), len(hashes))
+	}
+	seen := map[string]bool{}
+	for i, code := range plain {
+		if len(code) != 19 || code[4] != '-' || code[9] != '-' || code[14] != '-' {
+			t.Errorf("code %q is not xxxx-xxxx-xxxx-xxxx", code)
+		}
+		if seen[code] {
+			t.Errorf("duplicate code %q", code)
+		}
+		seen[code] = true
+		if HashRecoveryCode(code) != hashes[i] {
+			t.Errorf("hash of %q does not match", code)
+		}
+	}
+
+	// Users may retype a code without dashes, in upper case or with spaces
+	code := plain[0]
+	for _, typed := range []string{
+		code[0:4] + code[5:9] + code[10:14] + code[15:19],
+		"  " + code + " ",
+		strings.ToUpper(code),
+	} {
+		if HashRecoveryCode(typed) != hashes[0] {
+			t.Errorf("HashRecoveryCode(%q) does not match %q", typed, code)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package platform
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+)
+
+const (
+	UserTokenPrefix = "autohost-usr_"
+	userTokenBytes  = 32 // 32 bytes => ~43 chars base64
+)
+
+// GenerateUserToken genera un token de un solo uso para enviar por email
+// (verificación de email, restablecimiento de contraseña) y su hash
+func GenerateUserToken() (plain string, hash string, err error) {
+	buf := make([]byte, userTokenBytes)
+	if _, err = rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("rand.Read: %w", err)
+	}
+
+	plain = UserTokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return plain, HashUserToken(plain), nil
+}
+
+// HashUserToken genera el hash de un token de usuario
+func HashUserToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+// APIKeyRepository implementa apikey.Repository en memoria
+type APIKeyRepository struct {
+	db *DB
+}
+
+// NewAPIKeyRepository crea una nueva instancia del repositorio
+func NewAPIKeyRepository(db *DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// Create guarda una API key nueva
+func (r *APIKeyRepository) Create(ctx context.Context, k *apikey.APIKey) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[k.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, other := range r.db.apiKeys {
+		if other.KeyHash == k.KeyHash {
+			return ErrUniqueViolation
+		}
+	}
+	k.ID = newID()
+	k.CreatedAt = now()
+	r.db.apiKeys = append(r.db.apiKeys, &apikey.APIKey{
+		ID:        k.ID,
+		UserID:    k.UserID,
+		Name:      k.Name,
+		Prefix:    k.Prefix,
+		KeyHash:   k.KeyHash,
+		Scopes:    slices.Clone(k.Scopes),
+		ExpiresAt: copyTime(k.ExpiresAt),
+		CreatedAt: k.CreatedAt,
+	})
+	return nil
+}
+
+// FindByHash busca una API key por su hash, con el email de su usuario
+func (r *APIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*apikey.APIKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, k := range r.db.apiKeys {
+		if k.KeyHash == keyHash {
+			return r.copyKey(k), nil
+		}
+	}
+	return nil, apikey.ErrAPIKeyNotFound
+}
+
+// FindByUserID lista las API keys no revocadas de un usuario, de la más
+// nueva a la más vieja
+func (r *APIKeyRepository) FindByUserID(ctx context.Context, userID string) ([]*apikey.APIKey, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	keys := []*apikey.APIKey{}
+	for _, k := range r.db.apiKeys {
+		if k.UserID == userID && k.RevokedAt == nil {
+			keys = append(keys, r.copyKey(k))
+		}
+	}
+	slices.SortStableFunc(keys, func(a, b *apikey.APIKey) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return keys, nil
+}
+
+// Revoke revoca una API key activa del usuario
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, id string, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, k := range r.db.apiKeys {
+		if k.ID == id && k.UserID == userID && k.RevokedAt == nil {
+			k.RevokedAt = &at
+			return nil
+		}
+	}
+	return apikey.ErrAPIKeyNotFound
+}
+
+// UpdateLastUsed registra el último uso de una API key
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string, at time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, k := range r.db.apiKeys {
+		if k.ID == id {
+			k.LastUsedAt = &at
+		}
+	}
+	return nil
+}
+
+// copyKey requiere el mutex tomado
+func (r *APIKeyRepository) copyKey(k *apikey.APIKey) *apikey.APIKey {
+	c := *k
+	c.UserEmail = r.db.users[k.UserID].Email
+	c.Scopes = append([]organization.Permission{}, k.Scopes...)
+	c.ExpiresAt = copyTime(k.ExpiresAt)
+	c.LastUsedAt = copyTime(k.LastUsedAt)
+	c.RevokedAt = copyTime(k.RevokedAt)
+	return &c
+}
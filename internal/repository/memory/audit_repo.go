@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+)
+
+// AuditRepository implements audit.Repository in memory.
+type AuditRepository struct {
+	db *DB
+}
+
+func NewAuditRepository(db *DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+// Append chains and stores an event. The store mutex serializes appends, so
+// two events never share the same prev_hash.
+func (r *AuditRepository) Append(ctx context.Context, e *audit.Event) (*audit.Event, error) {
+	if e.ActorType != audit.ActorUser && e.ActorType != audit.ActorNode && e.ActorType != audit.ActorSystem {
+		return nil, ErrCheckViolation
+	}
+	if e.Outcome != audit.OutcomeSuccess && e.Outcome != audit.OutcomeFailure {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	prev := audit.GenesisHash
+	for i := len(r.db.auditEvents) - 1; i >= 0; i-- {
+		if sameOrganization(r.db.auditEvents[i].OrganizationID, e.OrganizationID) {
+			prev = r.db.auditEvents[i].Hash
+			break
+		}
+	}
+	e.PrevHash = prev
+	e.Hash = audit.ComputeHash(prev, e)
+
+	stored, err := copyEvent(e)
+	if err != nil {
+		return nil, err
+	}
+	r.db.auditSeq++
+	stored.ID = r.db.auditSeq
+	e.ID = stored.ID
+	r.db.auditEvents = append(r.db.auditEvents, stored)
+	return e, nil
+}
+
+// List returns events matching f, newest first.
+func (r *AuditRepository) List(ctx context.Context, f audit.Filter) ([]*audit.Event, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := []*audit.Event{}
+	for i := len(r.db.auditEvents) - 1; i >= 0 && len(out) < f.Limit; i-- {
+		e := r.db.auditEvents[i]
+		switch {
+		case f.OrganizationID != "" && (e.OrganizationID == nil || *e.OrganizationID != f.OrganizationID),
+			f.ActorType != "" && e.ActorType != f.ActorType,
+			f.ActorID != "" && e.ActorID != f.ActorID,
+			f.Action != "" && e.Action != f.Action,
+			f.TargetType != "" && e.TargetType != f.TargetType,
+			f.TargetID != "" && e.TargetID != f.TargetID,
+			f.Outcome != "" && e.Outcome != f.Outcome,
+			f.Since != nil && e.OccurredAt.Before(*f.Since),
+			f.Until != nil && !e.OccurredAt.Before(*f.Until),
+			f.BeforeID > 0 && e.ID >= f.BeforeID:
+			continue
+		}
+		c, err := copyEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+// Chain returns the events of one chain in insertion order.
+func (r *AuditRepository) Chain(ctx context.Context, orgID *string, afterID int64, limit int) ([]*audit.Event, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := []*audit.Event{}
+	for _, e := range r.db.auditEvents {
+		if len(out) >= limit {
+			break
+		}
+		if e.ID <= afterID || !sameOrganization(e.OrganizationID, orgID) {
+			continue
+		}
+		c, err := copyEvent(e)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, c)
+	}
+	return out, nil
+}
+
+func sameOrganization(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return *a == *b
+}
+
+// copyEvent copies e, round-tripping the metadata through JSON as the jsonb
+// column does, so numbers come back as float64.
+func copyEvent(e *audit.Event) (*audit.Event, error) {
+	c := *e
+	c.OrganizationID = copyString(e.OrganizationID)
+	c.Metadata = nil
+	if len(e.Metadata) > 0 {
+		b, err := json.Marshal(e.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(b, &c.Metadata); err != nil {
+			return nil, err
+		}
+	}
+	return &c, nil
+}
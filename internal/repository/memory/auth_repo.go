@@ -0,0 +1,299 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+)
+
+// AuthRepository implementa auth.Repository en memoria
+type AuthRepository struct {
+	db *DB
+}
+
+// NewAuthRepository crea una nueva instancia del repositorio
+func NewAuthRepository(db *DB) *AuthRepository {
+	return &AuthRepository{db: db}
+}
+
+// userTokenRow es la fila de user_action_tokens
+type userTokenRow struct {
+	userID    string
+	purpose   string
+	tokenHash string
+	expiresAt time.Time
+	usedAt    *time.Time
+}
+
+// CreateUser crea un nuevo usuario. passwordHash vacío crea un usuario sin
+// contraseña (solo SSO). El email es único sin distinguir mayúsculas.
+func (r *AuthRepository) CreateUser(ctx context.Context, email, name, passwordHash string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if sameEmail(u.Email, email) {
+			return "", ErrUniqueViolation
+		}
+	}
+	ts := now()
+	u := &userRow{User: auth.User{
+		ID:           newID(),
+		Email:        email,
+		Name:         &name,
+		PasswordHash: passwordHash,
+		CreatedAt:    ts,
+		UpdatedAt:    ts,
+	}}
+	r.db.users[u.ID] = u
+	return u.ID, nil
+}
+
+// FindUserByEmail busca un usuario por email; nil si no existe
+func (r *AuthRepository) FindUserByEmail(ctx context.Context, email string) (*auth.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, u := range r.db.users {
+		if sameEmail(u.Email, email) {
+			return copyUser(&u.User), nil
+		}
+	}
+	return nil, nil
+}
+
+// FindUserByID busca un usuario por ID; nil si no existe
+func (r *AuthRepository) FindUserByID(ctx context.Context, id string) (*auth.User, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[id]; ok {
+		return copyUser(&u.User), nil
+	}
+	return nil, nil
+}
+
+// StoreRefreshToken almacena un token de refresco. Si FamilyID está vacío el
+// token abre una nueva familia cuyo id es el del propio token.
+func (r *AuthRepository) StoreRefreshToken(ctx context.Context, t *auth.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.insertRefreshToken(t)
+}
+
+// insertRefreshToken requiere el mutex tomado
+func (r *AuthRepository) insertRefreshToken(t *auth.RefreshToken) error {
+	if _, ok := r.db.users[t.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, other := range r.db.refreshTokens {
+		if other.TokenHash == t.TokenHash {
+			return ErrUniqueViolation
+		}
+	}
+	t.ID = newID()
+	if t.FamilyID == "" {
+		t.FamilyID = t.ID
+	}
+	t.CreatedAt = now()
+	stored := *t
+	stored.RevokedAt = nil
+	stored.RevokedReason = ""
+	r.db.refreshTokens = append(r.db.refreshTokens, &stored)
+	return nil
+}
+
+// FindRefreshTokenByHash busca un refresh token por su hash (incluidos revocados)
+func (r *AuthRepository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*auth.RefreshToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.refreshTokens {
+		if t.TokenHash == tokenHash {
+			c := *t
+			c.RevokedAt = copyTime(t.RevokedAt)
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// RotateRefreshToken revoca el token viejo y guarda el nuevo de forma
+// atómica; solo una rotación del mismo token puede ganar
+func (r *AuthRepository) RotateRefreshToken(ctx context.Context, oldID string, next *auth.RefreshToken) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.IndexFunc(r.db.refreshTokens, func(t *auth.RefreshToken) bool {
+		return t.ID == oldID && t.RevokedAt == nil
+	})
+	if i < 0 {
+		return auth.ErrRefreshTokenReused
+	}
+	if err := r.insertRefreshToken(next); err != nil {
+		return err
+	}
+	ts := now()
+	r.db.refreshTokens[i].RevokedAt = &ts
+	r.db.refreshTokens[i].RevokedReason = auth.RevokedRotated
+	return nil
+}
+
+// RevokeRefreshFamily revoca todos los tokens activos de una sesión
+func (r *AuthRepository) RevokeRefreshFamily(ctx context.Context, userID, familyID, reason string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.revoke(func(t *auth.RefreshToken) bool {
+		return t.UserID == userID && t.FamilyID == familyID
+	}, reason) == 0 {
+		return auth.ErrSessionNotFound
+	}
+	return nil
+}
+
+// FindActiveSessions devuelve una fila por token vigente, del más reciente
+// al más antiguo, con el inicio de su familia
+func (r *AuthRepository) FindActiveSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	sessions := []*auth.Session{}
+	var active []*auth.RefreshToken
+	for _, t := range r.db.refreshTokens {
+		if t.UserID == userID && t.RevokedAt == nil && t.ExpiresAt.After(ts) {
+			active = append(active, t)
+		}
+	}
+	slices.SortStableFunc(active, func(a, b *auth.RefreshToken) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	for _, t := range active {
+		started := t.CreatedAt
+		for _, f := range r.db.refreshTokens {
+			if f.FamilyID == t.FamilyID && f.CreatedAt.Before(started) {
+				started = f.CreatedAt
+			}
+		}
+		sessions = append(sessions, &auth.Session{
+			ID:         t.FamilyID,
+			UserAgent:  t.UserAgent,
+			IP:         t.IP,
+			CreatedAt:  started,
+			LastUsedAt: t.CreatedAt,
+			ExpiresAt:  t.ExpiresAt,
+		})
+	}
+	return sessions, nil
+}
+
+// RevokeAllRefreshTokens revoca todos los tokens activos del usuario
+func (r *AuthRepository) RevokeAllRefreshTokens(ctx context.Context, userID, reason string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	r.revoke(func(t *auth.RefreshToken) bool { return t.UserID == userID }, reason)
+	return nil
+}
+
+// revoke revoca los tokens activos que cumplen match y devuelve cuántos;
+// requiere el mutex tomado
+func (r *AuthRepository) revoke(match func(*auth.RefreshToken) bool, reason string) int {
+	ts := now()
+	n := 0
+	for _, t := range r.db.refreshTokens {
+		if t.RevokedAt == nil && match(t) {
+			t.RevokedAt = &ts
+			t.RevokedReason = reason
+			n++
+		}
+	}
+	return n
+}
+
+// UpdatePassword reemplaza el hash de la contraseña del usuario
+func (r *AuthRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return auth.ErrUserNotFound
+	}
+	u.PasswordHash = passwordHash
+	u.UpdatedAt = now()
+	return nil
+}
+
+// MarkEmailVerified marca el email del usuario como verificado (idempotente)
+func (r *AuthRepository) MarkEmailVerified(ctx context.Context, userID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userID]; ok && u.EmailVerifiedAt == nil {
+		ts := now()
+		u.EmailVerifiedAt = &ts
+		u.UpdatedAt = ts
+	}
+	return nil
+}
+
+// CreateUserToken guarda un token de un solo uso e invalida los anteriores
+// sin usar del mismo propósito
+func (r *AuthRepository) CreateUserToken(ctx context.Context, userID, purpose, tokenHash string, expiresAt time.Time) error {
+	if purpose != auth.PurposeEmailVerification && purpose != auth.PurposePasswordReset {
+		return ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[userID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, t := range r.db.userTokens {
+		if t.tokenHash == tokenHash {
+			return ErrUniqueViolation
+		}
+	}
+	ts := now()
+	for _, t := range r.db.userTokens {
+		if t.userID == userID && t.purpose == purpose && t.usedAt == nil {
+			t.usedAt = &ts
+		}
+	}
+	r.db.userTokens = append(r.db.userTokens, &userTokenRow{
+		userID:    userID,
+		purpose:   purpose,
+		tokenHash: tokenHash,
+		expiresAt: expiresAt,
+	})
+	return nil
+}
+
+// ConsumeUserToken marca el token como usado si sigue vigente y devuelve su
+// usuario. Un token solo se puede canjear una vez.
+func (r *AuthRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	for _, t := range r.db.userTokens {
+		if t.tokenHash == tokenHash && t.purpose == purpose && t.usedAt == nil && t.expiresAt.After(ts) {
+			t.usedAt = &ts
+			return t.userID, nil
+		}
+	}
+	return "", auth.ErrInvalidUserToken
+}
+
+func copyUser(u *auth.User) *auth.User {
+	c := *u
+	c.Name = copyString(u.Name)
+	c.EmailVerifiedAt = copyTime(u.EmailVerifiedAt)
+	return &c
+}
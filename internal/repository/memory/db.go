@@ -0,0 +1,141 @@
+// Package memory implementa los repositorios del dominio en memoria para
+// probar servicios y transportes sin PostgreSQL. Reproduce la semántica de
+// los repositorios de postgres: los mismos errores del dominio, las
+// restricciones de unicidad y claves foráneas que usan los servicios y el
+// mismo orden en los listados.
+package memory
+
+import (
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+)
+
+// Errores equivalentes a las violaciones de restricciones de PostgreSQL. Los
+// repositorios de postgres no las traducen a errores del dominio, así que
+// aquí tampoco.
+var (
+	ErrUniqueViolation     = errors.New("memory: unique constraint violated")
+	ErrForeignKeyViolation = errors.New("memory: foreign key constraint violated")
+	ErrCheckViolation      = errors.New("memory: check constraint violated")
+)
+
+// DB es el almacén compartido por los repositorios, el equivalente a una
+// base de datos: los repositorios creados sobre el mismo DB ven los mismos
+// datos. Un único mutex serializa todas las operaciones, así que cada una es
+// atómica como lo es su transacción en postgres.
+type DB struct {
+	mu sync.Mutex
+
+	users         map[string]*userRow
+	refreshTokens []*auth.RefreshToken
+	userTokens    []*userTokenRow
+	recoveryCodes []*recoveryCodeRow
+	challenges    []*mfa.Challenge
+	oidcStates    map[string]*oidc.LoginState
+	identities    []*oidc.Identity
+	apiKeys       []*apikey.APIKey
+
+	organizations map[string]*organization.Organization
+	members       []*organization.Membership
+	invitations   []*invitation.Invitation
+
+	nodes        map[string]*node.Node
+	nodeTokens   []*nodetoken.NodeToken
+	enrollTokens []*enrollment.EnrollToken
+	commands     []*nodecommand.NodeCommand
+	metrics      []*nodemetric.NodeMetric
+	jobs         map[string]*job.Job
+	artifacts    []*jobartifact.Artifact
+	nodeGroups   []*nodegroup.Group
+	groupMembers []groupMemberRow
+
+	scripts           []*script.Script
+	scriptVersions    []*script.Version
+	scriptAssignments []*script.Assignment
+
+	auditEvents []*audit.Event
+	auditSeq    int64
+	buckets     map[string]*bucketRow
+	lockouts    map[string]*lockoutRow
+	signingKeys []*signingkey.Key
+}
+
+// NewDB crea un almacén vacío
+func NewDB() *DB {
+	return &DB{
+		users:         make(map[string]*userRow),
+		oidcStates:    make(map[string]*oidc.LoginState),
+		organizations: make(map[string]*organization.Organization),
+		nodes:         make(map[string]*node.Node),
+		jobs:          make(map[string]*job.Job),
+		buckets:       make(map[string]*bucketRow),
+		lockouts:      make(map[string]*lockoutRow),
+	}
+}
+
+// userRow es la fila de users: el usuario y su estado TOTP
+type userRow struct {
+	auth.User
+	totpSecret    []byte
+	totpEnabledAt *time.Time
+	totpLastStep  *int64
+}
+
+// groupMemberRow es la fila de node_group_members
+type groupMemberRow struct {
+	groupID string
+	nodeID  string
+}
+
+// now devuelve la hora con la precisión de timestamptz, para que los valores
+// se comparen igual que los leídos de postgres
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func newID() string {
+	return uuid.NewString()
+}
+
+// sameEmail compara emails como lo hace una columna CITEXT
+func sameEmail(a, b string) bool {
+	return strings.EqualFold(a, b)
+}
+
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
+
+func copyString(s *string) *string {
+	if s == nil {
+		return nil
+	}
+	c := *s
+	return &c
+}
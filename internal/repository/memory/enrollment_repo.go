@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+)
+
+type EnrollTokenRepo struct{ db *DB }
+
+func NewEnrollmentRepository(db *DB) *EnrollTokenRepo { return &EnrollTokenRepo{db: db} }
+
+func (r *EnrollTokenRepo) CreateEnrollToken(ctx context.Context, token string, userID string, orgID string, expiresAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[userID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if _, ok := r.db.organizations[orgID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, t := range r.db.enrollTokens {
+		if t.Token == token {
+			return ErrUniqueViolation
+		}
+	}
+	r.db.enrollTokens = append(r.db.enrollTokens, &enrollment.EnrollToken{
+		ID:             newID(),
+		Token:          token,
+		UserID:         userID,
+		OrganizationID: orgID,
+		ExpiresAt:      expiresAt,
+		CreatedAt:      now(),
+	})
+	return nil
+}
+
+func (r *EnrollTokenRepo) FindEnrollTokenByHash(ctx context.Context, token string) (*enrollment.EnrollToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.enrollTokens {
+		if t.Token == token {
+			c := *t
+			c.ConsumedAt = copyTime(t.ConsumedAt)
+			return &c, nil
+		}
+	}
+	return nil, enrollment.ErrEnrollTokenNotFound
+}
+
+func (r *EnrollTokenRepo) ConsumeEnrollToken(ctx context.Context, token string, now time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.enrollTokens {
+		if t.Token == token && t.ConsumedAt == nil && t.ExpiresAt.After(now) {
+			t.ConsumedAt = &now
+			return nil
+		}
+	}
+	return enrollment.ErrEnrollTokenUsed
+}
@@ -0,0 +1,133 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+)
+
+// InvitationRepository implements invitation.Repository in memory.
+type InvitationRepository struct {
+	db *DB
+}
+
+func NewInvitationRepository(db *DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+// Create replaces any pending invitation for the same org/email and inserts the new one.
+func (r *InvitationRepository) Create(ctx context.Context, inv *invitation.Invitation) (*invitation.Invitation, error) {
+	if !inv.Role.Valid() {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.organizations[inv.OrganizationID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, other := range r.db.invitations {
+		if other.TokenHash == inv.TokenHash {
+			return nil, ErrUniqueViolation
+		}
+	}
+	r.db.invitations = slices.DeleteFunc(r.db.invitations, func(other *invitation.Invitation) bool {
+		return other.OrganizationID == inv.OrganizationID && sameEmail(other.Email, inv.Email) &&
+			other.AcceptedAt == nil && other.DeclinedAt == nil
+	})
+
+	stored := &invitation.Invitation{
+		ID:             newID(),
+		OrganizationID: inv.OrganizationID,
+		Email:          inv.Email,
+		Role:           inv.Role,
+		TokenHash:      inv.TokenHash,
+		InvitedBy:      copyString(inv.InvitedBy),
+		ExpiresAt:      inv.ExpiresAt,
+		CreatedAt:      now(),
+	}
+	r.db.invitations = append(r.db.invitations, stored)
+	return copyInvitation(stored), nil
+}
+
+// FindByTokenHash returns an invitation by the hash of its token.
+func (r *InvitationRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*invitation.Invitation, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, inv := range r.db.invitations {
+		if inv.TokenHash == tokenHash {
+			return copyInvitation(inv), nil
+		}
+	}
+	return nil, invitation.ErrInvitationNotFound
+}
+
+// FindPendingByOrganization lists open, unexpired invitations of an
+// organization, newest first.
+func (r *InvitationRepository) FindPendingByOrganization(ctx context.Context, orgID string) ([]*invitation.Invitation, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	var invs []*invitation.Invitation
+	for _, inv := range r.db.invitations {
+		if inv.OrganizationID == orgID && inv.AcceptedAt == nil && inv.DeclinedAt == nil && inv.ExpiresAt.After(ts) {
+			invs = append(invs, copyInvitation(inv))
+		}
+	}
+	slices.SortStableFunc(invs, func(a, b *invitation.Invitation) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return invs, nil
+}
+
+// MarkAccepted sets accepted_at on a pending invitation.
+func (r *InvitationRepository) MarkAccepted(ctx context.Context, id string, at time.Time) error {
+	return r.resolve(id, func(inv *invitation.Invitation) { inv.AcceptedAt = &at })
+}
+
+// MarkDeclined sets declined_at on a pending invitation.
+func (r *InvitationRepository) MarkDeclined(ctx context.Context, id string, at time.Time) error {
+	return r.resolve(id, func(inv *invitation.Invitation) { inv.DeclinedAt = &at })
+}
+
+// resolve closes a pending invitation; an invitation can only be resolved once.
+func (r *InvitationRepository) resolve(id string, set func(*invitation.Invitation)) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, inv := range r.db.invitations {
+		if inv.ID == id && inv.AcceptedAt == nil && inv.DeclinedAt == nil {
+			set(inv)
+			return nil
+		}
+	}
+	return invitation.ErrInvitationUsed
+}
+
+// Delete removes an invitation that belongs to orgID.
+func (r *InvitationRepository) Delete(ctx context.Context, orgID, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := len(r.db.invitations)
+	r.db.invitations = slices.DeleteFunc(r.db.invitations, func(inv *invitation.Invitation) bool {
+		return inv.OrganizationID == orgID && inv.ID == id
+	})
+	if len(r.db.invitations) == n {
+		return invitation.ErrInvitationNotFound
+	}
+	return nil
+}
+
+func copyInvitation(inv *invitation.Invitation) *invitation.Invitation {
+	c := *inv
+	c.InvitedBy = copyString(inv.InvitedBy)
+	c.AcceptedAt = copyTime(inv.AcceptedAt)
+	c.DeclinedAt = copyTime(inv.DeclinedAt)
+	return &c
+}
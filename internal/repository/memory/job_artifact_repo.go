@@ -0,0 +1,94 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+)
+
+// JobArtifactRepository implements jobartifact.Repository in memory.
+type JobArtifactRepository struct {
+	db *DB
+}
+
+func NewJobArtifactRepository(db *DB) *JobArtifactRepository {
+	return &JobArtifactRepository{db: db}
+}
+
+func (r *JobArtifactRepository) Create(ctx context.Context, a *jobartifact.Artifact) (*jobartifact.Artifact, error) {
+	if a.Name == "" || strings.ContainsAny(a.Name, `/\`) || a.Size < 0 {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.jobs[a.JobID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, x := range r.db.artifacts {
+		if x.JobID == a.JobID && x.Name == a.Name {
+			return nil, jobartifact.ErrArtifactExists
+		}
+	}
+	c := *a
+	c.ID = newID()
+	c.CreatedAt = now()
+	c.ExpiresAt = a.ExpiresAt.UTC().Truncate(time.Microsecond)
+	r.db.artifacts = append(r.db.artifacts, &c)
+	out := c
+	return &out, nil
+}
+
+func (r *JobArtifactRepository) Find(ctx context.Context, jobID, name string) (*jobartifact.Artifact, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, a := range r.db.artifacts {
+		if a.JobID == jobID && a.Name == name {
+			out := *a
+			return &out, nil
+		}
+	}
+	return nil, jobartifact.ErrArtifactNotFound
+}
+
+func (r *JobArtifactRepository) ListByJob(ctx context.Context, jobID string) ([]*jobartifact.Artifact, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*jobartifact.Artifact
+	for _, a := range r.db.artifacts {
+		if a.JobID == jobID {
+			c := *a
+			out = append(out, &c)
+		}
+	}
+	slices.SortFunc(out, func(a, b *jobartifact.Artifact) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *JobArtifactRepository) DeleteExpired(ctx context.Context, t time.Time) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var keys []string
+	r.db.artifacts = slices.DeleteFunc(r.db.artifacts, func(a *jobartifact.Artifact) bool {
+		if a.Expired(t) {
+			keys = append(keys, a.SHA256)
+			return true
+		}
+		return false
+	})
+	return keys, nil
+}
+
+func (r *JobArtifactRepository) Referenced(ctx context.Context, sha256 string) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return slices.ContainsFunc(r.db.artifacts, func(a *jobartifact.Artifact) bool { return a.SHA256 == sha256 }), nil
+}
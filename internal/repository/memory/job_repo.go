@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+)
+
+// JobRepository implements job.Repository in memory.
+type JobRepository struct {
+	db *DB
+}
+
+func NewJobRepository(db *DB) *JobRepository {
+	return &JobRepository{db: db}
+}
+
+// validJobStatus mirrors the CHECK constraint on jobs.status.
+func validJobStatus(s job.JobStatus) bool {
+	switch s {
+	case job.StatusPending, job.StatusRunning, job.StatusCompleted, job.StatusFailed:
+		return true
+	}
+	return false
+}
+
+// Create inserts a new job record.
+func (r *JobRepository) Create(ctx context.Context, j *job.Job) (*job.Job, error) {
+	if !validJobStatus(j.Status) {
+		return nil, ErrCheckViolation
+	}
+	if j.CommandType != nodecommand.CommandTypeDefault && j.CommandType != nodecommand.CommandTypeCustom {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.nodes[j.NodeID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	stored := &job.Job{
+		ID:          newID(),
+		NodeID:      j.NodeID,
+		CommandName: j.CommandName,
+		CommandType: j.CommandType,
+		Status:      j.Status,
+		CreatedAt:   now(),
+	}
+	r.db.jobs[stored.ID] = stored
+	return copyJob(stored), nil
+}
+
+// FindByID returns a job by its ID.
+func (r *JobRepository) FindByID(ctx context.Context, id string) (*job.Job, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	j, ok := r.db.jobs[id]
+	if !ok {
+		return nil, job.ErrJobNotFound
+	}
+	return copyJob(j), nil
+}
+
+// jobSortColumns mirrors the sort columns of the postgres repository.
+var jobSortColumns = map[string]sortColumn[*job.Job]{
+	job.SortCreatedAt:   {key: func(j *job.Job) sortKey { return sortKey{t: j.CreatedAt} }, isTime: true},
+	job.SortCommandName: {key: func(j *job.Job) sortKey { return sortKey{s: j.CommandName} }},
+}
+
+// List returns the jobs matching f, keyset-paginated on (sort field, id).
+func (r *JobRepository) List(ctx context.Context, f job.ListFilter) ([]*job.Job, error) {
+	col, ok := jobSortColumns[f.Sort.Field]
+	if !ok {
+		return nil, job.ErrInvalidFilter
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var jobs []*job.Job
+	for _, j := range r.db.jobs {
+		if f.NodeID != "" && j.NodeID != f.NodeID {
+			continue
+		}
+		if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, j.Status) {
+			continue
+		}
+		if f.Since != nil && j.CreatedAt.Before(*f.Since) {
+			continue
+		}
+		if f.Until != nil && !j.CreatedAt.Before(*f.Until) {
+			continue
+		}
+		jobs = append(jobs, copyJob(j))
+	}
+	return keysetPage(jobs, col, func(j *job.Job) string { return j.ID }, f.Sort, f.After, f.Limit)
+}
+
+// UpdateStatus moves a job to status following the state machine, setting
+// started_at / finished_at like the postgres repository does.
+func (r *JobRepository) UpdateStatus(ctx context.Context, id, nodeID string, status job.JobStatus, output job.Output, errMsg string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	j, ok := r.db.jobs[id]
+	if !ok || j.NodeID != nodeID {
+		return job.ErrJobNotFound
+	}
+	if !job.CanTransition(j.Status, status) {
+		return job.ErrInvalidTransition
+	}
+	ts := now()
+	if j.StartedAt == nil {
+		j.StartedAt = &ts
+	}
+	if status.Final() {
+		j.FinishedAt = &ts
+	}
+	j.Status = status
+	j.Output = output.Text
+	j.OutputSize = output.Size
+	j.OutputTruncated = output.Truncated
+	j.OutputBlob = output.BlobKey
+	j.Error = errMsg
+	return nil
+}
+
+func copyJob(j *job.Job) *job.Job {
+	c := *j
+	c.StartedAt = copyTime(j.StartedAt)
+	c.FinishedAt = copyTime(j.FinishedAt)
+	return &c
+}
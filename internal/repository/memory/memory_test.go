@@ -0,0 +1,121 @@
+package memory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// Cada repositorio en memoria implementa la interfaz de su dominio
+var (
+	_ apikey.Repository       = (*APIKeyRepository)(nil)
+	_ audit.Repository        = (*AuditRepository)(nil)
+	_ auth.Repository         = (*AuthRepository)(nil)
+	_ enrollment.Repository   = (*EnrollTokenRepo)(nil)
+	_ invitation.Repository   = (*InvitationRepository)(nil)
+	_ job.Repository          = (*JobRepository)(nil)
+	_ jobartifact.Repository  = (*JobArtifactRepository)(nil)
+	_ mfa.Repository          = (*MFARepository)(nil)
+	_ node.Repository         = (*NodeRepository)(nil)
+	_ nodecommand.Repository  = (*NodeCommandRepository)(nil)
+	_ nodegroup.Repository    = (*NodeGroupRepository)(nil)
+	_ nodemetric.Repository   = (*NodeMetricRepo)(nil)
+	_ nodetoken.Repository    = (*NodeTokenRepo)(nil)
+	_ oidc.Repository         = (*OIDCRepository)(nil)
+	_ organization.Repository = (*OrganizationRepository)(nil)
+	_ ratelimit.Repository    = (*RateLimitRepository)(nil)
+	_ script.Repository       = (*ScriptRepository)(nil)
+	_ signingkey.Repository   = (*SigningKeyRepository)(nil)
+)
+
+func TestNodePagination(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	_, orgID := SeedOrg(t, db)
+	svc := node.NewService(NewNodeRepository(db))
+	for _, h := range []string{"db-2", "web-1", "db-1", "web-3", "web-2"} {
+		if _, err := svc.Register(ctx, &node.Node{Hostname: h, OrganizationID: orgID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, sort := range []platform.Sort{{Field: node.SortHostname}, {Field: node.SortHostname, Desc: true}} {
+		var got []string
+		f := node.ListFilter{OrganizationID: orgID, Sort: sort, Limit: 2}
+		for {
+			page, next, err := svc.List(ctx, f)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, n := range page {
+				got = append(got, n.Hostname)
+			}
+			if next == nil {
+				break
+			}
+			f.After = next
+		}
+		want := "[db-1 db-2 web-1 web-2 web-3]"
+		if sort.Desc {
+			want = "[web-3 web-2 web-1 db-2 db-1]"
+		}
+		if fmt.Sprint(got) != want {
+			t.Errorf("%s: pages = %v, want %s", sort, got, want)
+		}
+	}
+
+	page, _, err := svc.List(ctx, node.ListFilter{OrganizationID: orgID, Hostname: "WEB"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 3 {
+		t.Errorf("search: %d nodes, want 3", len(page))
+	}
+}
+
+func TestConstraints(t *testing.T) {
+	ctx := context.Background()
+	db := NewDB()
+	userID, orgID := SeedOrg(t, db)
+
+	nodes := NewNodeRepository(db)
+	if _, err := nodes.Register(ctx, &node.Node{Hostname: "web-1", OrganizationID: "missing"}); !errors.Is(err, ErrForeignKeyViolation) {
+		t.Errorf("unknown organization: %v", err)
+	}
+	if _, err := nodes.Register(ctx, &node.Node{Hostname: "web-1", OrganizationID: orgID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodes.Register(ctx, &node.Node{Hostname: "web-1", OrganizationID: orgID}); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("duplicate hostname: %v", err)
+	}
+	if _, err := NewAuthRepository(db).CreateUser(ctx, "OWNER@example.com", "Other", "x"); !errors.Is(err, ErrUniqueViolation) {
+		t.Errorf("duplicate email: %v", err)
+	}
+	if err := NewOrganizationRepository(db).AddMember(ctx, orgID, userID, "superuser"); !errors.Is(err, ErrCheckViolation) {
+		t.Errorf("invalid role: %v", err)
+	}
+	if _, err := NewJobRepository(db).FindByID(ctx, "missing"); !errors.Is(err, job.ErrJobNotFound) {
+		t.Errorf("missing job: %v", err)
+	}
+}
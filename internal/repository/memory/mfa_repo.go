@@ -0,0 +1,234 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+)
+
+// MFARepository implementa mfa.Repository en memoria
+type MFARepository struct {
+	db *DB
+}
+
+// NewMFARepository crea una nueva instancia del repositorio
+func NewMFARepository(db *DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// recoveryCodeRow es la fila de mfa_recovery_codes
+type recoveryCodeRow struct {
+	userID   string
+	codeHash string
+	usedAt   *time.Time
+}
+
+// FindEnrollment devuelve el estado TOTP de un usuario
+func (r *MFARepository) FindEnrollment(ctx context.Context, userID string) (*mfa.Enrollment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok {
+		return nil, auth.ErrUserNotFound
+	}
+	en := &mfa.Enrollment{
+		UserID:          u.ID,
+		SecretEncrypted: slices.Clone(u.totpSecret),
+		EnabledAt:       copyTime(u.totpEnabledAt),
+	}
+	if u.totpLastStep != nil {
+		step := *u.totpLastStep
+		en.LastStep = &step
+	}
+	return en, nil
+}
+
+// SetPendingSecret guarda el secreto de un alta no confirmada
+func (r *MFARepository) SetPendingSecret(ctx context.Context, userID string, secretEncrypted []byte) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok || u.totpEnabledAt != nil {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+	u.totpSecret = slices.Clone(secretEncrypted)
+	u.totpLastStep = nil
+	u.UpdatedAt = now()
+	return nil
+}
+
+// Enable activa MFA y guarda los códigos de recuperación
+func (r *MFARepository) Enable(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok || u.totpEnabledAt != nil || u.totpSecret == nil {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+	if err := r.replaceRecoveryCodes(userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	ts := now()
+	u.totpEnabledAt = &ts
+	u.totpLastStep = &step
+	u.UpdatedAt = ts
+	return nil
+}
+
+// Disable borra el secreto y los códigos de recuperación
+func (r *MFARepository) Disable(ctx context.Context, userID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if u, ok := r.db.users[userID]; ok {
+		u.totpSecret = nil
+		u.totpEnabledAt = nil
+		u.totpLastStep = nil
+		u.UpdatedAt = now()
+	}
+	r.db.recoveryCodes = slices.DeleteFunc(r.db.recoveryCodes, func(c *recoveryCodeRow) bool {
+		return c.userID == userID
+	})
+	return nil
+}
+
+// MarkStepUsed avanza totp_last_step solo si step es posterior
+func (r *MFARepository) MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	u, ok := r.db.users[userID]
+	if !ok || (u.totpLastStep != nil && *u.totpLastStep >= step) {
+		return false, nil
+	}
+	u.totpLastStep = &step
+	return true, nil
+}
+
+// UseRecoveryCode consume un código de recuperación sin usar
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.recoveryCodes {
+		if c.userID == userID && c.codeHash == codeHash && c.usedAt == nil {
+			c.usedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// ReplaceRecoveryCodes reemplaza todos los códigos de recuperación del usuario
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.replaceRecoveryCodes(userID, codeHashes)
+}
+
+// CountRecoveryCodes cuenta los códigos de recuperación sin usar
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := 0
+	for _, c := range r.db.recoveryCodes {
+		if c.userID == userID && c.usedAt == nil {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// CreateChallenge guarda un desafío MFA
+func (r *MFARepository) CreateChallenge(ctx context.Context, c *mfa.Challenge) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[c.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, other := range r.db.challenges {
+		if other.TokenHash == c.TokenHash {
+			return ErrUniqueViolation
+		}
+	}
+	c.ID = newID()
+	c.CreatedAt = now()
+	r.db.challenges = append(r.db.challenges, &mfa.Challenge{
+		ID:        c.ID,
+		UserID:    c.UserID,
+		TokenHash: c.TokenHash,
+		ExpiresAt: c.ExpiresAt,
+		CreatedAt: c.CreatedAt,
+	})
+	return nil
+}
+
+// FindChallengeByHash busca un desafío por el hash de su token
+func (r *MFARepository) FindChallengeByHash(ctx context.Context, tokenHash string) (*mfa.Challenge, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.challenges {
+		if c.TokenHash == tokenHash {
+			out := *c
+			out.UsedAt = copyTime(c.UsedAt)
+			return &out, nil
+		}
+	}
+	return nil, mfa.ErrInvalidChallenge
+}
+
+// IncrementChallengeAttempts suma un intento fallido al desafío
+func (r *MFARepository) IncrementChallengeAttempts(ctx context.Context, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.challenges {
+		if c.ID == id {
+			c.Attempts++
+		}
+	}
+	return nil
+}
+
+// ConsumeChallenge marca el desafío como usado
+func (r *MFARepository) ConsumeChallenge(ctx context.Context, id string, at time.Time) (bool, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.challenges {
+		if c.ID == id && c.UsedAt == nil {
+			c.UsedAt = &at
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// replaceRecoveryCodes requiere el mutex tomado
+func (r *MFARepository) replaceRecoveryCodes(userID string, codeHashes []string) error {
+	if _, ok := r.db.users[userID]; !ok && len(codeHashes) > 0 {
+		return ErrForeignKeyViolation
+	}
+	for i, h := range codeHashes {
+		if slices.Contains(codeHashes[:i], h) {
+			return ErrUniqueViolation
+		}
+	}
+	r.db.recoveryCodes = slices.DeleteFunc(r.db.recoveryCodes, func(c *recoveryCodeRow) bool {
+		return c.userID == userID
+	})
+	for _, h := range codeHashes {
+		r.db.recoveryCodes = append(r.db.recoveryCodes, &recoveryCodeRow{userID: userID, codeHash: h})
+	}
+	return nil
+}
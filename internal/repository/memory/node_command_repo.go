@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+)
+
+// NodeCommandRepository implements nodecommand.Repository in memory.
+type NodeCommandRepository struct {
+	db *DB
+}
+
+func NewNodeCommandRepository(db *DB) *NodeCommandRepository {
+	return &NodeCommandRepository{db: db}
+}
+
+// Upsert inserts or updates a node command (matched on node_id + name). An
+// empty description keeps the stored one, as in postgres.
+func (r *NodeCommandRepository) Upsert(ctx context.Context, cmd *nodecommand.NodeCommand) (*nodecommand.NodeCommand, error) {
+	if cmd.Type != nodecommand.CommandTypeDefault && cmd.Type != nodecommand.CommandTypeCustom {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.nodes[cmd.NodeID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, c := range r.db.commands {
+		if c.NodeID == cmd.NodeID && c.Name == cmd.Name {
+			if cmd.Description != "" {
+				c.Description = cmd.Description
+			}
+			c.Type = cmd.Type
+			c.ScriptPath = cmd.ScriptPath
+			out := *c
+			return &out, nil
+		}
+	}
+
+	c := &nodecommand.NodeCommand{
+		ID:          newID(),
+		NodeID:      cmd.NodeID,
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Type:        cmd.Type,
+		ScriptPath:  cmd.ScriptPath,
+		CreatedAt:   now(),
+	}
+	r.db.commands = append(r.db.commands, c)
+	out := *c
+	return &out, nil
+}
+
+// FindByNodeID returns all commands registered for a node, ordered by name.
+func (r *NodeCommandRepository) FindByNodeID(ctx context.Context, nodeID string) ([]*nodecommand.NodeCommand, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	out := []*nodecommand.NodeCommand{}
+	for _, c := range r.db.commands {
+		if c.NodeID == nodeID {
+			cp := *c
+			out = append(out, &cp)
+		}
+	}
+	slices.SortFunc(out, func(a, b *nodecommand.NodeCommand) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return out, nil
+}
+
+// FindByID returns a single command by its ID.
+func (r *NodeCommandRepository) FindByID(ctx context.Context, id string) (*nodecommand.NodeCommand, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, c := range r.db.commands {
+		if c.ID == id {
+			cp := *c
+			return &cp, nil
+		}
+	}
+	return nil, nodecommand.ErrCommandNotFound
+}
+
+// Delete removes a command by its ID if it belongs to nodeID.
+func (r *NodeCommandRepository) Delete(ctx context.Context, id, nodeID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := len(r.db.commands)
+	r.db.commands = slices.DeleteFunc(r.db.commands, func(c *nodecommand.NodeCommand) bool {
+		return c.ID == id && c.NodeID == nodeID
+	})
+	if len(r.db.commands) == n {
+		return nodecommand.ErrCommandNotFound
+	}
+	return nil
+}
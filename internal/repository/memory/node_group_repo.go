@@ -0,0 +1,137 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+// NodeGroupRepository implements nodegroup.Repository in memory.
+type NodeGroupRepository struct {
+	db *DB
+}
+
+func NewNodeGroupRepository(db *DB) *NodeGroupRepository {
+	return &NodeGroupRepository{db: db}
+}
+
+func (r *NodeGroupRepository) Create(ctx context.Context, g *nodegroup.Group) (*nodegroup.Group, error) {
+	if g.Name == "" {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.organizations[g.OrganizationID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, x := range r.db.nodeGroups {
+		if x.OrganizationID == g.OrganizationID && x.Name == g.Name {
+			return nil, nodegroup.ErrGroupExists
+		}
+	}
+	c := *g
+	c.ID = newID()
+	c.CreatedAt = now()
+	r.db.nodeGroups = append(r.db.nodeGroups, &c)
+	out := c
+	return &out, nil
+}
+
+func (r *NodeGroupRepository) Find(ctx context.Context, orgID, id string) (*nodegroup.Group, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, g := range r.db.nodeGroups {
+		if g.ID == id && g.OrganizationID == orgID {
+			out := *g
+			return &out, nil
+		}
+	}
+	return nil, nodegroup.ErrGroupNotFound
+}
+
+func (r *NodeGroupRepository) List(ctx context.Context, orgID string) ([]*nodegroup.Group, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*nodegroup.Group
+	for _, g := range r.db.nodeGroups {
+		if g.OrganizationID == orgID {
+			c := *g
+			out = append(out, &c)
+		}
+	}
+	slices.SortFunc(out, func(a, b *nodegroup.Group) int { return strings.Compare(a.Name, b.Name) })
+	return out, nil
+}
+
+func (r *NodeGroupRepository) Delete(ctx context.Context, orgID, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.IndexFunc(r.db.nodeGroups, func(g *nodegroup.Group) bool { return g.ID == id && g.OrganizationID == orgID })
+	if i < 0 {
+		return nodegroup.ErrGroupNotFound
+	}
+	r.db.nodeGroups = slices.Delete(r.db.nodeGroups, i, i+1)
+	// ON DELETE CASCADE
+	r.db.groupMembers = slices.DeleteFunc(r.db.groupMembers, func(m groupMemberRow) bool { return m.groupID == id })
+	r.db.scriptAssignments = slices.DeleteFunc(r.db.scriptAssignments, func(a *script.Assignment) bool {
+		return a.GroupID != nil && *a.GroupID == id
+	})
+	return nil
+}
+
+func (r *NodeGroupRepository) AddNode(ctx context.Context, groupID, nodeID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.nodes[nodeID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if !slices.ContainsFunc(r.db.nodeGroups, func(g *nodegroup.Group) bool { return g.ID == groupID }) {
+		return ErrForeignKeyViolation
+	}
+	m := groupMemberRow{groupID: groupID, nodeID: nodeID}
+	if !slices.Contains(r.db.groupMembers, m) {
+		r.db.groupMembers = append(r.db.groupMembers, m)
+	}
+	return nil
+}
+
+func (r *NodeGroupRepository) RemoveNode(ctx context.Context, groupID, nodeID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.Index(r.db.groupMembers, groupMemberRow{groupID: groupID, nodeID: nodeID})
+	if i < 0 {
+		return nodegroup.ErrMemberNotFound
+	}
+	r.db.groupMembers = slices.Delete(r.db.groupMembers, i, i+1)
+	return nil
+}
+
+func (r *NodeGroupRepository) NodeIDs(ctx context.Context, groupID string) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	return r.db.groupNodeIDs(groupID), nil
+}
+
+// groupNodeIDs returns the members of a group ordered by node ID. The caller
+// holds db.mu.
+func (db *DB) groupNodeIDs(groupID string) []string {
+	var out []string
+	for _, m := range db.groupMembers {
+		if m.groupID == groupID {
+			out = append(out, m.nodeID)
+		}
+	}
+	slices.Sort(out)
+	return out
+}
@@ -0,0 +1,42 @@
+package memory
+
+import (
+	"context"
+
+	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
+)
+
+type NodeMetricRepo struct {
+	db *DB
+}
+
+func NewNodeMetricRepository(db *DB) *NodeMetricRepo {
+	return &NodeMetricRepo{db: db}
+}
+
+func (r *NodeMetricRepo) StoreNodeMetric(ctx context.Context, req *nodemetric.CreateNodeMetricRequest) (*nodemetric.NodeMetric, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.nodes[req.NodeID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	m := &nodemetric.NodeMetric{
+		ID:                   newID(),
+		NodeID:               req.NodeID,
+		CPUUsagePercent:      req.CPUUsagePercent,
+		MemoryTotalBytes:     req.MemoryTotalBytes,
+		MemoryUsedBytes:      req.MemoryUsedBytes,
+		MemoryAvailableBytes: req.MemoryAvailableBytes,
+		MemoryUsagePercent:   req.MemoryUsagePercent,
+		DiskTotalBytes:       req.DiskTotalBytes,
+		DiskUsedBytes:        req.DiskUsedBytes,
+		DiskAvailableBytes:   req.DiskAvailableBytes,
+		DiskUsagePercent:     req.DiskUsagePercent,
+		CollectedAt:          req.CollectedAt,
+		CreatedAt:            now(),
+	}
+	r.db.metrics = append(r.db.metrics, m)
+	out := *m
+	return &out, nil
+}
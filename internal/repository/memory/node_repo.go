@@ -0,0 +1,157 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+)
+
+// NodeRepository implementa node.Repository en memoria
+type NodeRepository struct {
+	db *DB
+}
+
+// NewNodeRepository crea una nueva instancia del repositorio
+func NewNodeRepository(db *DB) *NodeRepository {
+	return &NodeRepository{db: db}
+}
+
+// Register crea un nuevo nodo; el hostname es único dentro de la organización
+func (r *NodeRepository) Register(ctx context.Context, n *node.Node) (*node.Node, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.organizations[n.OrganizationID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	if n.OwnerID != nil {
+		if _, ok := r.db.users[*n.OwnerID]; !ok {
+			return nil, ErrForeignKeyViolation
+		}
+	}
+	for _, other := range r.db.nodes {
+		if other.OrganizationID == n.OrganizationID && other.Hostname == n.Hostname {
+			return nil, ErrUniqueViolation
+		}
+	}
+
+	ts := now()
+	n.ID = newID()
+	n.LastSeenAt = nil
+	n.CreatedAt = ts
+	n.UpdatedAt = ts
+	r.db.nodes[n.ID] = copyNode(n)
+	return n, nil
+}
+
+func (r *NodeRepository) FindByID(ctx context.Context, id string) (*node.Node, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n, ok := r.db.nodes[id]
+	if !ok {
+		return nil, node.ErrNodeNotFound
+	}
+	return copyNode(n), nil
+}
+
+// nodeSortColumns es el equivalente de las columnas de ordenación de
+// postgres; los nodos que nunca se han visto ordenan con epoch
+var nodeSortColumns = map[string]sortColumn[*node.Node]{
+	node.SortCreatedAt: {key: func(n *node.Node) sortKey { return sortKey{t: n.CreatedAt} }, isTime: true},
+	node.SortHostname:  {key: func(n *node.Node) sortKey { return sortKey{s: n.Hostname} }},
+	node.SortLastSeenAt: {key: func(n *node.Node) sortKey {
+		if n.LastSeenAt == nil {
+			return sortKey{t: time.Unix(0, 0)}
+		}
+		return sortKey{t: *n.LastSeenAt}
+	}, isTime: true},
+}
+
+// List busca los nodos que cumplen f, paginando por keyset sobre (campo, id)
+func (r *NodeRepository) List(ctx context.Context, f node.ListFilter) ([]*node.Node, error) {
+	col, ok := nodeSortColumns[f.Sort.Field]
+	if !ok {
+		return nil, node.ErrInvalidFilter
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var nodes []*node.Node
+	for _, n := range r.db.nodes {
+		if f.OrganizationID != "" && n.OrganizationID != f.OrganizationID {
+			continue
+		}
+		if f.Hostname != "" && !strings.Contains(strings.ToLower(n.Hostname), strings.ToLower(f.Hostname)) {
+			continue
+		}
+		nodes = append(nodes, copyNode(n))
+	}
+	return keysetPage(nodes, col, func(n *node.Node) string { return n.ID }, f.Sort, f.After, f.Limit)
+}
+
+func (r *NodeRepository) UpdateLastSeen(ctx context.Context, nodeID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if n, ok := r.db.nodes[nodeID]; ok {
+		ts := now()
+		n.LastSeenAt = &ts
+	}
+	return nil
+}
+
+// FindByOrganizationIDWithMetrics busca todos los nodos de una organización con sus últimas métricas
+func (r *NodeRepository) FindByOrganizationIDWithMetrics(ctx context.Context, orgID string) ([]*node.NodeWithMetrics, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var results []*node.NodeWithMetrics
+	for _, n := range r.db.nodes {
+		if n.OrganizationID != orgID {
+			continue
+		}
+		nwm := &node.NodeWithMetrics{
+			ID:             n.ID,
+			Hostname:       n.Hostname,
+			IPLocal:        n.IPLocal,
+			OS:             n.OS,
+			Arch:           n.Arch,
+			VersionAgent:   n.VersionAgent,
+			OrganizationID: n.OrganizationID,
+			OwnerID:        copyString(n.OwnerID),
+			LastSeenAt:     copyTime(n.LastSeenAt),
+			CreatedAt:      n.CreatedAt,
+			UpdatedAt:      n.UpdatedAt,
+		}
+		for _, m := range r.db.metrics {
+			if m.NodeID != n.ID {
+				continue
+			}
+			if nwm.LastMetric == nil || m.CollectedAt.After(nwm.LastMetric.CollectedAt) {
+				nwm.LastMetric = &node.LastMetric{
+					CPUUsagePercent:    m.CPUUsagePercent,
+					MemoryUsagePercent: m.MemoryUsagePercent,
+					DiskUsagePercent:   m.DiskUsagePercent,
+					CollectedAt:        m.CollectedAt,
+				}
+			}
+		}
+		results = append(results, nwm)
+	}
+	slices.SortFunc(results, func(a, b *node.NodeWithMetrics) int {
+		return b.CreatedAt.Compare(a.CreatedAt)
+	})
+	return results, nil
+}
+
+func copyNode(n *node.Node) *node.Node {
+	c := *n
+	c.OwnerID = copyString(n.OwnerID)
+	c.LastSeenAt = copyTime(n.LastSeenAt)
+	return &c
+}
@@ -0,0 +1,67 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+)
+
+type NodeTokenRepo struct{ db *DB }
+
+func NewNodeTokenRepository(db *DB) *NodeTokenRepo { return &NodeTokenRepo{db: db} }
+
+func (r *NodeTokenRepo) CreateNodeToken(ctx context.Context, nodeID, tokenHash string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.nodes[nodeID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, t := range r.db.nodeTokens {
+		if t.Token == tokenHash {
+			return ErrUniqueViolation
+		}
+	}
+	r.db.nodeTokens = append(r.db.nodeTokens, &nodetoken.NodeToken{
+		ID:        newID(),
+		NodeID:    nodeID,
+		Token:     tokenHash,
+		CreatedAt: now(),
+	})
+	return nil
+}
+
+// FindNodeTokenByHash devuelve el token con la organización de su nodo
+func (r *NodeTokenRepo) FindNodeTokenByHash(ctx context.Context, tokenHash string) (*nodetoken.NodeToken, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.nodeTokens {
+		if t.Token != tokenHash {
+			continue
+		}
+		n, ok := r.db.nodes[t.NodeID]
+		if !ok {
+			break
+		}
+		c := *t
+		c.OrganizationID = n.OrganizationID
+		c.LastSeenAt = copyTime(t.LastSeenAt)
+		c.RevokedAt = copyTime(t.RevokedAt)
+		return &c, nil
+	}
+	return nil, nodetoken.ErrNodeTokenNotFound
+}
+
+func (r *NodeTokenRepo) UpdateLastSeen(ctx context.Context, tokenID string, lastSeenAt time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, t := range r.db.nodeTokens {
+		if t.ID == tokenID {
+			t.LastSeenAt = &lastSeenAt
+		}
+	}
+	return nil
+}
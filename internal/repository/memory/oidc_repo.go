@@ -0,0 +1,84 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+)
+
+// OIDCRepository implementa oidc.Repository en memoria
+type OIDCRepository struct {
+	db *DB
+}
+
+// NewOIDCRepository crea una nueva instancia del repositorio
+func NewOIDCRepository(db *DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+// CreateState guarda el estado de un login en curso y purga los caducados
+func (r *OIDCRepository) CreateState(ctx context.Context, s *oidc.LoginState) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	for hash, st := range r.db.oidcStates {
+		if st.ExpiresAt.Before(ts) {
+			delete(r.db.oidcStates, hash)
+		}
+	}
+	if _, ok := r.db.oidcStates[s.StateHash]; ok {
+		return ErrUniqueViolation
+	}
+	c := *s
+	r.db.oidcStates[s.StateHash] = &c
+	return nil
+}
+
+// ConsumeState borra y devuelve el estado (un solo uso) si lo inició el
+// navegador browserHash
+func (r *OIDCRepository) ConsumeState(ctx context.Context, stateHash, browserHash string) (*oidc.LoginState, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	s, ok := r.db.oidcStates[stateHash]
+	if !ok || s.BrowserHash != browserHash {
+		return nil, oidc.ErrInvalidState
+	}
+	delete(r.db.oidcStates, stateHash)
+	return s, nil
+}
+
+// FindIdentity busca una identidad externa vinculada; nil si no existe
+func (r *OIDCRepository) FindIdentity(ctx context.Context, issuer, subject string) (*oidc.Identity, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, i := range r.db.identities {
+		if i.Issuer == issuer && i.Subject == subject {
+			c := *i
+			return &c, nil
+		}
+	}
+	return nil, nil
+}
+
+// LinkIdentity vincula una identidad externa a un usuario
+func (r *OIDCRepository) LinkIdentity(ctx context.Context, i *oidc.Identity) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[i.UserID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	for _, other := range r.db.identities {
+		if other.Issuer == i.Issuer && other.Subject == i.Subject {
+			return ErrUniqueViolation
+		}
+	}
+	i.ID = newID()
+	i.CreatedAt = now()
+	c := *i
+	r.db.identities = append(r.db.identities, &c)
+	return nil
+}
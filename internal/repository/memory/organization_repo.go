@@ -0,0 +1,285 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+// OrganizationRepository implements organization.Repository in memory.
+type OrganizationRepository struct {
+	db *DB
+}
+
+func NewOrganizationRepository(db *DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Create inserts a team organization and its first owner.
+func (r *OrganizationRepository) Create(ctx context.Context, name, ownerID string) (*organization.Organization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[ownerID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	org := r.insertOrganization(name, nil)
+	r.insertMember(org.ID, ownerID, organization.RoleOwner)
+	return copyOrganization(org), nil
+}
+
+// EnsurePersonal returns the personal organization of userID, creating it
+// (with the user as owner) if it does not exist yet.
+func (r *OrganizationRepository) EnsurePersonal(ctx context.Context, userID, name string) (*organization.Organization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.users[userID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	org := r.personal(userID)
+	if org == nil {
+		org = r.insertOrganization(name, &userID)
+	}
+	if r.membership(org.ID, userID) == nil {
+		r.insertMember(org.ID, userID, organization.RoleOwner)
+	}
+	return copyOrganization(org), nil
+}
+
+// FindByID returns an organization by its ID.
+func (r *OrganizationRepository) FindByID(ctx context.Context, id string) (*organization.Organization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	org, ok := r.db.organizations[id]
+	if !ok {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	return copyOrganization(org), nil
+}
+
+// FindPersonal returns the personal organization of a user.
+func (r *OrganizationRepository) FindPersonal(ctx context.Context, userID string) (*organization.Organization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	org := r.personal(userID)
+	if org == nil {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	return copyOrganization(org), nil
+}
+
+// FindByUserID returns every organization the user is a member of, the
+// personal one first and then by creation date.
+func (r *OrganizationRepository) FindByUserID(ctx context.Context, userID string) ([]*organization.UserOrganization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var orgs []*organization.UserOrganization
+	for _, m := range r.db.members {
+		if m.UserID == userID {
+			org := r.db.organizations[m.OrganizationID]
+			orgs = append(orgs, &organization.UserOrganization{Organization: *copyOrganization(org), Role: m.Role})
+		}
+	}
+	slices.SortStableFunc(orgs, func(a, b *organization.UserOrganization) int {
+		if (a.PersonalUserID == nil) != (b.PersonalUserID == nil) {
+			if a.PersonalUserID != nil {
+				return -1
+			}
+			return 1
+		}
+		return a.CreatedAt.Compare(b.CreatedAt)
+	})
+	return orgs, nil
+}
+
+// FindMembership returns the membership of userID in orgID.
+func (r *OrganizationRepository) FindMembership(ctx context.Context, orgID, userID string) (*organization.Membership, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	m := r.membership(orgID, userID)
+	if m == nil {
+		return nil, organization.ErrNotMember
+	}
+	c := *m
+	c.RequireMFA = r.db.organizations[orgID].RequireMFA
+	return &c, nil
+}
+
+// FindMembers lists the members of an organization with their user profile.
+func (r *OrganizationRepository) FindMembers(ctx context.Context, orgID string) ([]*organization.Member, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var members []*organization.Member
+	for _, m := range r.db.members {
+		if m.OrganizationID != orgID {
+			continue
+		}
+		u := r.db.users[m.UserID]
+		members = append(members, &organization.Member{
+			UserID:    m.UserID,
+			Email:     u.Email,
+			Name:      copyString(u.Name),
+			Role:      m.Role,
+			CreatedAt: m.CreatedAt,
+		})
+	}
+	return members, nil
+}
+
+// AddMember inserts a new membership.
+func (r *OrganizationRepository) AddMember(ctx context.Context, orgID, userID string, role organization.Role) error {
+	if !role.Valid() {
+		return ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.organizations[orgID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if _, ok := r.db.users[userID]; !ok {
+		return ErrForeignKeyViolation
+	}
+	if r.membership(orgID, userID) != nil {
+		return ErrUniqueViolation
+	}
+	r.insertMember(orgID, userID, role)
+	return nil
+}
+
+// UpdateMemberRole changes the role of an existing member.
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID string, role organization.Role) error {
+	if !role.Valid() {
+		return ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	m := r.membership(orgID, userID)
+	if m == nil {
+		return organization.ErrNotMember
+	}
+	if role != organization.RoleOwner && r.lastOwner(orgID, userID) {
+		return organization.ErrLastOwner
+	}
+	m.Role = role
+	return nil
+}
+
+// RemoveMember deletes a membership.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if r.lastOwner(orgID, userID) {
+		return organization.ErrLastOwner
+	}
+	n := len(r.db.members)
+	r.db.members = slices.DeleteFunc(r.db.members, func(m *organization.Membership) bool {
+		return m.OrganizationID == orgID && m.UserID == userID
+	})
+	if len(r.db.members) == n {
+		return organization.ErrNotMember
+	}
+	return nil
+}
+
+// CountOwners returns how many owners an organization has.
+func (r *OrganizationRepository) CountOwners(ctx context.Context, orgID string) (int, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := 0
+	for _, m := range r.db.members {
+		if m.OrganizationID == orgID && m.Role == organization.RoleOwner {
+			n++
+		}
+	}
+	return n, nil
+}
+
+// lastOwner indica si userID es el único owner de orgID; requiere el mutex
+// tomado
+func (r *OrganizationRepository) lastOwner(orgID, userID string) bool {
+	owners := 0
+	for _, m := range r.db.members {
+		if m.OrganizationID == orgID && m.Role == organization.RoleOwner {
+			if m.UserID != userID {
+				return false
+			}
+			owners++
+		}
+	}
+	return owners == 1
+}
+
+// SetRequireMFA updates the two-factor requirement of an organization.
+func (r *OrganizationRepository) SetRequireMFA(ctx context.Context, id string, require bool) (*organization.Organization, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	org, ok := r.db.organizations[id]
+	if !ok {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	org.RequireMFA = require
+	org.UpdatedAt = now()
+	return copyOrganization(org), nil
+}
+
+// The helpers below require the mutex to be held.
+
+func (r *OrganizationRepository) insertOrganization(name string, personalUserID *string) *organization.Organization {
+	ts := now()
+	org := &organization.Organization{
+		ID:             newID(),
+		Name:           name,
+		PersonalUserID: copyString(personalUserID),
+		CreatedAt:      ts,
+		UpdatedAt:      ts,
+	}
+	r.db.organizations[org.ID] = org
+	return org
+}
+
+func (r *OrganizationRepository) insertMember(orgID, userID string, role organization.Role) {
+	r.db.members = append(r.db.members, &organization.Membership{
+		OrganizationID: orgID,
+		UserID:         userID,
+		Role:           role,
+		CreatedAt:      now(),
+	})
+}
+
+func (r *OrganizationRepository) personal(userID string) *organization.Organization {
+	for _, org := range r.db.organizations {
+		if org.PersonalUserID != nil && *org.PersonalUserID == userID {
+			return org
+		}
+	}
+	return nil
+}
+
+func (r *OrganizationRepository) membership(orgID, userID string) *organization.Membership {
+	for _, m := range r.db.members {
+		if m.OrganizationID == orgID && m.UserID == userID {
+			return m
+		}
+	}
+	return nil
+}
+
+func copyOrganization(org *organization.Organization) *organization.Organization {
+	c := *org
+	c.PersonalUserID = copyString(org.PersonalUserID)
+	return &c
+}
@@ -0,0 +1,79 @@
+package memory
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// sortKey es el valor de una fila en un campo de ordenación: un instante o
+// un texto, según el tipo de la columna
+type sortKey struct {
+	t time.Time
+	s string
+}
+
+// sortColumn es el equivalente en memoria de la columna de ordenación de
+// postgres: extrae el valor de una fila y sabe convertir el del cursor
+type sortColumn[T any] struct {
+	key    func(T) sortKey
+	isTime bool
+}
+
+func (c sortColumn[T]) compare(a, b sortKey) int {
+	if c.isTime {
+		return a.t.Compare(b.t)
+	}
+	return strings.Compare(a.s, b.s)
+}
+
+// cursorKey convierte cursor.Value al tipo de la columna; falla igual que el
+// cast ::timestamptz de postgres con un valor que no es un instante
+func (c sortColumn[T]) cursorKey(value string) (sortKey, error) {
+	if !c.isTime {
+		return sortKey{s: value}, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return sortKey{}, fmt.Errorf("invalid cursor value %q: %w", value, err)
+	}
+	return sortKey{t: t}, nil
+}
+
+// keysetPage ordena rows por (campo, id), deja solo las filas posteriores al
+// cursor y recorta a limit, como keysetCondition y keysetOrder en postgres
+func keysetPage[T any](rows []T, col sortColumn[T], id func(T) string, sort platform.Sort, after *platform.Cursor, limit int) ([]T, error) {
+	cmp := func(ka sortKey, ida string, kb sortKey, idb string) int {
+		c := col.compare(ka, kb)
+		if c == 0 {
+			c = strings.Compare(ida, idb)
+		}
+		if sort.Desc {
+			c = -c
+		}
+		return c
+	}
+	slices.SortFunc(rows, func(a, b T) int {
+		return cmp(col.key(a), id(a), col.key(b), id(b))
+	})
+
+	if after != nil {
+		ck, err := col.cursorKey(after.Value)
+		if err != nil {
+			return nil, err
+		}
+		rows = slices.DeleteFunc(rows, func(r T) bool {
+			return cmp(col.key(r), id(r), ck, after.ID) <= 0
+		})
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+	if rows == nil {
+		rows = []T{}
+	}
+	return rows, nil
+}
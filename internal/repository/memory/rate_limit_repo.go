@@ -0,0 +1,119 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+)
+
+// RateLimitRepository implementa ratelimit.Repository en memoria
+type RateLimitRepository struct {
+	db *DB
+}
+
+func NewRateLimitRepository(db *DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// bucketRow es la fila de rate_limit_buckets
+type bucketRow struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+// lockoutRow es la fila de login_lockouts
+type lockoutRow struct {
+	failedAttempts int
+	lastFailedAt   time.Time
+	lockedUntil    *time.Time
+}
+
+// Take rellena el bucket según el tiempo transcurrido y consume un token
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := time.Now()
+	burst := float64(limit.Burst)
+	b, ok := r.db.buckets[key]
+	if !ok {
+		b = &bucketRow{tokens: burst, updatedAt: ts}
+		r.db.buckets[key] = b
+	}
+
+	perSecond := 1 / limit.Every.Seconds()
+	elapsed := ts.Sub(b.updatedAt).Seconds()
+	tokens := min(burst, b.tokens+max(elapsed, 0)*perSecond)
+	res := ratelimit.Result{Allowed: tokens >= 1}
+	if res.Allowed {
+		tokens--
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+	b.tokens = tokens
+	b.updatedAt = ts
+	return res, nil
+}
+
+// PurgeBuckets borra los buckets que no se usan desde before
+func (r *RateLimitRepository) PurgeBuckets(ctx context.Context, before time.Time) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for key, b := range r.db.buckets {
+		if b.updatedAt.Before(before) {
+			delete(r.db.buckets, key)
+		}
+	}
+	return nil
+}
+
+// FindLockout devuelve nil si la clave no tiene fallos registrados
+func (r *RateLimitRepository) FindLockout(ctx context.Context, key string) (*ratelimit.Lockout, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	l, ok := r.db.lockouts[key]
+	if !ok {
+		return nil, nil
+	}
+	return &ratelimit.Lockout{FailedAttempts: l.failedAttempts, LockedUntil: copyTime(l.lockedUntil)}, nil
+}
+
+// RecordFailure suma un fallo (el contador se reinicia si el último fallo fue
+// hace más de un día) y fija el bloqueo que corresponda
+func (r *RateLimitRepository) RecordFailure(ctx context.Context, key string, backoff func(failures int) time.Duration) (*ratelimit.Lockout, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	l, ok := r.db.lockouts[key]
+	switch {
+	case !ok:
+		l = &lockoutRow{failedAttempts: 1}
+		r.db.lockouts[key] = l
+	case l.lastFailedAt.Before(ts.Add(-24 * time.Hour)):
+		l.failedAttempts = 1
+	default:
+		l.failedAttempts++
+	}
+	l.lastFailedAt = ts
+
+	out := &ratelimit.Lockout{FailedAttempts: l.failedAttempts}
+	if d := backoff(l.failedAttempts); d > 0 {
+		until := ts.Add(d)
+		l.lockedUntil = &until
+		out.LockedUntil = copyTime(&until)
+	}
+	return out, nil
+}
+
+// ResetLockout olvida los fallos de la clave
+func (r *RateLimitRepository) ResetLockout(ctx context.Context, key string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	delete(r.db.lockouts, key)
+	return nil
+}
@@ -0,0 +1,280 @@
+package memory
+
+import (
+	"context"
+	"regexp"
+	"slices"
+	"strings"
+
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+// scriptName is the CHECK constraint of scripts.name
+var scriptName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// ScriptRepository implements script.Repository in memory.
+type ScriptRepository struct {
+	db *DB
+}
+
+func NewScriptRepository(db *DB) *ScriptRepository {
+	return &ScriptRepository{db: db}
+}
+
+func (r *ScriptRepository) Create(ctx context.Context, s *script.Script, v *script.Version) (*script.Script, error) {
+	if !scriptName.MatchString(s.Name) {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if _, ok := r.db.organizations[s.OrganizationID]; !ok {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, x := range r.db.scripts {
+		if x.OrganizationID == s.OrganizationID && x.Name == s.Name {
+			return nil, script.ErrScriptExists
+		}
+	}
+	c := *s
+	c.ID = newID()
+	c.Version = 1
+	c.SHA256 = v.SHA256
+	c.CreatedBy = copyString(s.CreatedBy)
+	c.CreatedAt = now()
+	c.UpdatedAt = c.CreatedAt
+	r.db.scripts = append(r.db.scripts, &c)
+	r.db.scriptVersions = append(r.db.scriptVersions, &script.Version{
+		ScriptID:  c.ID,
+		Version:   1,
+		Body:      v.Body,
+		Size:      v.Size,
+		SHA256:    v.SHA256,
+		CreatedBy: copyString(v.CreatedBy),
+		CreatedAt: c.CreatedAt,
+	})
+	out := c
+	return &out, nil
+}
+
+func (r *ScriptRepository) AddVersion(ctx context.Context, v *script.Version) (*script.Version, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.IndexFunc(r.db.scripts, func(s *script.Script) bool { return s.ID == v.ScriptID })
+	if i < 0 {
+		return nil, script.ErrScriptNotFound
+	}
+	s := r.db.scripts[i]
+	s.Version++
+	s.SHA256 = v.SHA256
+	s.UpdatedAt = now()
+	c := *v
+	c.Version = s.Version
+	c.CreatedBy = copyString(v.CreatedBy)
+	c.CreatedAt = s.UpdatedAt
+	r.db.scriptVersions = append(r.db.scriptVersions, &c)
+	out := c
+	out.Body = ""
+	return &out, nil
+}
+
+func (r *ScriptRepository) Find(ctx context.Context, orgID, id string) (*script.Script, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, s := range r.db.scripts {
+		if s.ID == id && s.OrganizationID == orgID {
+			return copyScript(s), nil
+		}
+	}
+	return nil, script.ErrScriptNotFound
+}
+
+func (r *ScriptRepository) List(ctx context.Context, orgID string) ([]*script.Script, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*script.Script
+	for _, s := range r.db.scripts {
+		if s.OrganizationID == orgID {
+			out = append(out, copyScript(s))
+		}
+	}
+	sortScripts(out)
+	return out, nil
+}
+
+func (r *ScriptRepository) Delete(ctx context.Context, orgID, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	i := slices.IndexFunc(r.db.scripts, func(s *script.Script) bool { return s.ID == id && s.OrganizationID == orgID })
+	if i < 0 {
+		return script.ErrScriptNotFound
+	}
+	r.db.scripts = slices.Delete(r.db.scripts, i, i+1)
+	// ON DELETE CASCADE
+	r.db.scriptVersions = slices.DeleteFunc(r.db.scriptVersions, func(v *script.Version) bool { return v.ScriptID == id })
+	r.db.scriptAssignments = slices.DeleteFunc(r.db.scriptAssignments, func(a *script.Assignment) bool { return a.ScriptID == id })
+	return nil
+}
+
+func (r *ScriptRepository) Versions(ctx context.Context, scriptID string) ([]*script.Version, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*script.Version
+	for _, v := range r.db.scriptVersions {
+		if v.ScriptID == scriptID {
+			c := *v
+			c.Body = ""
+			c.CreatedBy = copyString(v.CreatedBy)
+			out = append(out, &c)
+		}
+	}
+	slices.SortFunc(out, func(a, b *script.Version) int { return b.Version - a.Version })
+	return out, nil
+}
+
+func (r *ScriptRepository) FindVersion(ctx context.Context, scriptID string, version int) (*script.Version, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, v := range r.db.scriptVersions {
+		if v.ScriptID == scriptID && v.Version == version {
+			c := *v
+			c.CreatedBy = copyString(v.CreatedBy)
+			return &c, nil
+		}
+	}
+	return nil, script.ErrVersionNotFound
+}
+
+func (r *ScriptRepository) Assign(ctx context.Context, a *script.Assignment) (*script.Assignment, error) {
+	if (a.NodeID == nil) == (a.GroupID == nil) {
+		return nil, ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	if !slices.ContainsFunc(r.db.scripts, func(s *script.Script) bool { return s.ID == a.ScriptID }) {
+		return nil, ErrForeignKeyViolation
+	}
+	if a.NodeID != nil {
+		if _, ok := r.db.nodes[*a.NodeID]; !ok {
+			return nil, ErrForeignKeyViolation
+		}
+	}
+	if a.GroupID != nil && !slices.ContainsFunc(r.db.nodeGroups, func(g *nodegroup.Group) bool { return g.ID == *a.GroupID }) {
+		return nil, ErrForeignKeyViolation
+	}
+	for _, x := range r.db.scriptAssignments {
+		if x.ScriptID == a.ScriptID && (sameTarget(x.NodeID, a.NodeID) || sameTarget(x.GroupID, a.GroupID)) {
+			return nil, script.ErrAssignmentExists
+		}
+	}
+	c := script.Assignment{
+		ID:        newID(),
+		ScriptID:  a.ScriptID,
+		NodeID:    copyString(a.NodeID),
+		GroupID:   copyString(a.GroupID),
+		CreatedAt: now(),
+	}
+	r.db.scriptAssignments = append(r.db.scriptAssignments, &c)
+	return copyAssignment(&c), nil
+}
+
+func (r *ScriptRepository) Assignments(ctx context.Context, scriptID string) ([]*script.Assignment, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*script.Assignment
+	for _, a := range r.db.scriptAssignments {
+		if a.ScriptID == scriptID {
+			out = append(out, copyAssignment(a))
+		}
+	}
+	return out, nil
+}
+
+func (r *ScriptRepository) Unassign(ctx context.Context, scriptID, id string) error {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	n := len(r.db.scriptAssignments)
+	r.db.scriptAssignments = slices.DeleteFunc(r.db.scriptAssignments, func(a *script.Assignment) bool {
+		return a.ID == id && a.ScriptID == scriptID
+	})
+	if len(r.db.scriptAssignments) == n {
+		return script.ErrAssignmentNotFound
+	}
+	return nil
+}
+
+func (r *ScriptRepository) NodeIDs(ctx context.Context, scriptID string) ([]string, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []string
+	for _, a := range r.db.scriptAssignments {
+		if a.ScriptID != scriptID {
+			continue
+		}
+		if a.NodeID != nil {
+			out = append(out, *a.NodeID)
+		} else {
+			out = append(out, r.db.groupNodeIDs(*a.GroupID)...)
+		}
+	}
+	slices.Sort(out)
+	return slices.Compact(out), nil
+}
+
+func (r *ScriptRepository) ForNode(ctx context.Context, nodeID string) ([]*script.Script, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	var out []*script.Script
+	for _, s := range r.db.scripts {
+		if slices.ContainsFunc(r.db.scriptAssignments, func(a *script.Assignment) bool {
+			if a.ScriptID != s.ID {
+				return false
+			}
+			if a.NodeID != nil {
+				return *a.NodeID == nodeID
+			}
+			return slices.Contains(r.db.groupNodeIDs(*a.GroupID), nodeID)
+		}) {
+			out = append(out, copyScript(s))
+		}
+	}
+	sortScripts(out)
+	return out, nil
+}
+
+// sameTarget compares two nullable UUID columns as a UNIQUE constraint does:
+// NULLs never collide.
+func sameTarget(a, b *string) bool {
+	return a != nil && b != nil && *a == *b
+}
+
+func sortScripts(scripts []*script.Script) {
+	slices.SortFunc(scripts, func(a, b *script.Script) int { return strings.Compare(a.Name, b.Name) })
+}
+
+func copyScript(s *script.Script) *script.Script {
+	c := *s
+	c.CreatedBy = copyString(s.CreatedBy)
+	return &c
+}
+
+func copyAssignment(a *script.Assignment) *script.Assignment {
+	c := *a
+	c.NodeID = copyString(a.NodeID)
+	c.GroupID = copyString(a.GroupID)
+	return &c
+}
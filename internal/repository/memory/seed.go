@@ -0,0 +1,36 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+)
+
+// SeedOrg crea el usuario owner@example.com y su organización Acme, el punto
+// de partida de la mayoría de las pruebas
+func SeedOrg(t testing.TB, db *DB) (userID, orgID string) {
+	t.Helper()
+	ctx := context.Background()
+	userID, err := NewAuthRepository(db).CreateUser(ctx, "owner@example.com", "Owner", "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	org, err := NewOrganizationRepository(db).Create(ctx, "Acme", userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return userID, org.ID
+}
+
+// SeedNode registra en orgID un nodo hostname del usuario ownerID
+func SeedNode(t testing.TB, db *DB, orgID, ownerID, hostname string) *node.Node {
+	t.Helper()
+	n, err := node.NewService(NewNodeRepository(db)).Register(context.Background(), &node.Node{
+		Hostname: hostname, OrganizationID: orgID, OwnerID: &ownerID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
@@ -0,0 +1,75 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+)
+
+// SigningKeyRepository implementa signingkey.Repository en memoria
+type SigningKeyRepository struct {
+	db *DB
+}
+
+// NewSigningKeyRepository crea una nueva instancia del repositorio
+func NewSigningKeyRepository(db *DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// FindUsable devuelve las claves que aún no expiraron, de la más nueva a la
+// más vieja
+func (r *SigningKeyRepository) FindUsable(ctx context.Context) ([]*signingkey.Key, error) {
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	ts := now()
+	var keys []*signingkey.Key
+	for i := len(r.db.signingKeys) - 1; i >= 0; i-- {
+		k := r.db.signingKeys[i]
+		if k.ExpiresAt == nil || k.ExpiresAt.After(ts) {
+			c := *k
+			c.PrivateKeyEncrypted = slices.Clone(k.PrivateKeyEncrypted)
+			c.RetiredAt = copyTime(k.RetiredAt)
+			c.ExpiresAt = copyTime(k.ExpiresAt)
+			keys = append(keys, &c)
+		}
+	}
+	return keys, nil
+}
+
+// Rotate retira las claves activas en activateAt e inserta la nueva de forma
+// atómica
+func (r *SigningKeyRepository) Rotate(ctx context.Context, next *signingkey.Key, activateAt, verifyUntil time.Time) error {
+	if next.Algorithm != "RS256" && next.Algorithm != "EdDSA" {
+		return ErrCheckViolation
+	}
+
+	r.db.mu.Lock()
+	defer r.db.mu.Unlock()
+
+	for _, k := range r.db.signingKeys {
+		if k.ID == next.ID {
+			return ErrUniqueViolation
+		}
+	}
+	ts := now()
+	for _, k := range r.db.signingKeys {
+		if k.RetiredAt == nil {
+			retired, until := activateAt, verifyUntil
+			k.RetiredAt = &retired
+			k.ExpiresAt = &until
+		}
+	}
+	next.CreatedAt = ts
+	r.db.signingKeys = append(r.db.signingKeys, &signingkey.Key{
+		ID:                  next.ID,
+		Algorithm:           next.Algorithm,
+		PublicKey:           next.PublicKey,
+		PrivateKeyEncrypted: slices.Clone(next.PrivateKeyEncrypted),
+		CreatedAt:           ts,
+		ActivatesAt:         activateAt,
+	})
+	return nil
+}
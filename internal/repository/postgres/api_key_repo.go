@@ -0,0 +1,121 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// APIKeyRepository implementa apikey.Repository usando PostgreSQL
+type APIKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewAPIKeyRepository crea una nueva instancia del repositorio
+func NewAPIKeyRepository(db *sqlx.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = `k.id, k.user_id, u.email AS user_email, k.name, k.prefix, k.key_hash,
+	k.scopes, k.expires_at, k.last_used_at, k.revoked_at, k.created_at`
+
+// Create guarda una API key nueva
+func (r *APIKeyRepository) Create(ctx context.Context, k *apikey.APIKey) error {
+	scopes := make(pq.StringArray, len(k.Scopes))
+	for i, s := range k.Scopes {
+		scopes[i] = string(s)
+	}
+	return r.db.QueryRowxContext(ctx, `
+		INSERT INTO api_keys (user_id, name, prefix, key_hash, scopes, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at`,
+		k.UserID, k.Name, k.Prefix, k.KeyHash, scopes, k.ExpiresAt,
+	).Scan(&k.ID, &k.CreatedAt)
+}
+
+// FindByHash busca una API key por su hash
+func (r *APIKeyRepository) FindByHash(ctx context.Context, keyHash string) (*apikey.APIKey, error) {
+	var m APIKeyModel
+	err := r.db.GetContext(ctx, &m, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.key_hash = $1`, keyHash)
+	if err == sql.ErrNoRows {
+		return nil, apikey.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.toDomain(), nil
+}
+
+// FindByUserID lista las API keys no revocadas de un usuario
+func (r *APIKeyRepository) FindByUserID(ctx context.Context, userID string) ([]*apikey.APIKey, error) {
+	var models []APIKeyModel
+	err := r.db.SelectContext(ctx, &models, `
+		SELECT `+apiKeyColumns+`
+		FROM api_keys k
+		JOIN users u ON u.id = k.user_id
+		WHERE k.user_id = $1 AND k.revoked_at IS NULL
+		ORDER BY k.created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*apikey.APIKey, len(models))
+	for i := range models {
+		keys[i] = models[i].toDomain()
+	}
+	return keys, nil
+}
+
+// Revoke revoca una API key activa del usuario
+func (r *APIKeyRepository) Revoke(ctx context.Context, userID, id string, at time.Time) error {
+	if _, err := uuid.Parse(id); err != nil {
+		return apikey.ErrAPIKeyNotFound
+	}
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE api_keys SET revoked_at = $1
+		WHERE id = $2 AND user_id = $3 AND revoked_at IS NULL`, at, id, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return apikey.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// UpdateLastUsed registra el último uso de una API key
+func (r *APIKeyRepository) UpdateLastUsed(ctx context.Context, id string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE api_keys SET last_used_at = $1 WHERE id = $2`, at, id)
+	return err
+}
+
+func (m *APIKeyModel) toDomain() *apikey.APIKey {
+	scopes := make([]organization.Permission, len(m.Scopes))
+	for i, s := range m.Scopes {
+		scopes[i] = organization.Permission(s)
+	}
+	return &apikey.APIKey{
+		ID:         m.ID,
+		UserID:     m.UserID,
+		UserEmail:  m.UserEmail,
+		Name:       m.Name,
+		Prefix:     m.Prefix,
+		KeyHash:    m.KeyHash,
+		Scopes:     scopes,
+		ExpiresAt:  m.ExpiresAt,
+		LastUsedAt: m.LastUsedAt,
+		RevokedAt:  m.RevokedAt,
+		CreatedAt:  m.CreatedAt,
+	}
+}
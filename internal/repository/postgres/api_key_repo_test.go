@@ -0,0 +1,87 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	apikey "github.com/arturo/autohost-cloud-api/internal/domain/api_key"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+func TestAPIKeyRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAPIKeyRepository(db)
+	userID := createUser(t, db, "ci@example.com")
+	expires := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Microsecond)
+
+	scoped := &apikey.APIKey{
+		UserID:    userID,
+		Name:      "deploy pipeline",
+		Prefix:    "ahk_abcd",
+		KeyHash:   "hash-1",
+		Scopes:    []organization.Permission{organization.PermJobsWrite, organization.PermNodesRead},
+		ExpiresAt: &expires,
+	}
+	if err := repo.Create(ctx, scoped); err != nil {
+		t.Fatal(err)
+	}
+	sleepTick()
+	unscoped := &apikey.APIKey{UserID: userID, Name: "admin", Prefix: "ahk_efgh", KeyHash: "hash-2"}
+	if err := repo.Create(ctx, unscoped); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := repo.FindByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != scoped.ID || got.UserEmail != "ci@example.com" || got.ExpiresAt == nil || !got.ExpiresAt.Equal(expires) ||
+		!slices.Equal(got.Scopes, scoped.Scopes) {
+		t.Errorf("FindByHash = %+v", got)
+	}
+	if got, _ := repo.FindByHash(ctx, "hash-2"); len(got.Scopes) != 0 || got.ExpiresAt != nil {
+		t.Errorf("unscoped key = %+v", got)
+	}
+	if _, err := repo.FindByHash(ctx, "missing"); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("FindByHash(unknown) error = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	used := time.Now().UTC().Truncate(time.Microsecond)
+	if err := repo.UpdateLastUsed(ctx, scoped.ID, used); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.FindByHash(ctx, "hash-1"); got.LastUsedAt == nil || !got.LastUsedAt.Equal(used) {
+		t.Errorf("last_used_at = %v, want %v", got.LastUsedAt, used)
+	}
+
+	// Revocar solo funciona para el dueño y una vez; la clave revocada deja
+	// de listarse pero se sigue encontrando por hash para rechazarla
+	other := createUser(t, db, "eve@example.com")
+	if err := repo.Revoke(ctx, other, scoped.ID, time.Now()); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("Revoke by another user error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if err := repo.Revoke(ctx, userID, scoped.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Revoke(ctx, userID, scoped.ID, time.Now()); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("second Revoke error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if err := repo.Revoke(ctx, userID, "not-a-uuid", time.Now()); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("Revoke(invalid id) error = %v, want ErrAPIKeyNotFound", err)
+	}
+	if got, _ := repo.FindByHash(ctx, "hash-1"); got.RevokedAt == nil {
+		t.Error("revoked key has no revoked_at")
+	}
+
+	keys, err := repo.FindByUserID(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 1 || keys[0].ID != unscoped.ID {
+		t.Errorf("FindByUserID = %+v", keys)
+	}
+}
@@ -0,0 +1,189 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+	"github.com/jmoiron/sqlx"
+)
+
+// AuditRepository implements audit.Repository using PostgreSQL.
+type AuditRepository struct {
+	db *sqlx.DB
+}
+
+func NewAuditRepository(db *sqlx.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+const auditColumns = `id, occurred_at, organization_id, actor_type, actor_id, action,
+	target_type, target_id, outcome, ip, request_id, metadata, prev_hash, hash`
+
+// Append chains and inserts an event. An advisory lock per chain serializes
+// concurrent appends so two events never share the same prev_hash.
+func (r *AuditRepository) Append(ctx context.Context, e *audit.Event) (*audit.Event, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	chainKey := "audit:"
+	if e.OrganizationID != nil {
+		chainKey += *e.OrganizationID
+	}
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, chainKey); err != nil {
+		return nil, err
+	}
+
+	var prev string
+	if e.OrganizationID == nil {
+		err = tx.GetContext(ctx, &prev,
+			`SELECT hash FROM audit_events WHERE organization_id IS NULL ORDER BY id DESC LIMIT 1`)
+	} else {
+		err = tx.GetContext(ctx, &prev,
+			`SELECT hash FROM audit_events WHERE organization_id = $1 ORDER BY id DESC LIMIT 1`, *e.OrganizationID)
+	}
+	if err == sql.ErrNoRows {
+		prev = audit.GenesisHash
+	} else if err != nil {
+		return nil, err
+	}
+
+	e.PrevHash = prev
+	e.Hash = audit.ComputeHash(prev, e)
+
+	metadata, err := json.Marshal(e.Metadata)
+	if err != nil {
+		return nil, err
+	}
+	if e.Metadata == nil {
+		metadata = []byte("{}")
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO audit_events (occurred_at, organization_id, actor_type, actor_id, action,
+			target_type, target_id, outcome, ip, request_id, metadata, prev_hash, hash)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id`,
+		e.OccurredAt, e.OrganizationID, e.ActorType, e.ActorID, e.Action,
+		e.TargetType, e.TargetID, e.Outcome, e.IP, e.RequestID, metadata, e.PrevHash, e.Hash,
+	).Scan(&e.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// List returns events matching f, newest first.
+func (r *AuditRepository) List(ctx context.Context, f audit.Filter) ([]*audit.Event, error) {
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.OrganizationID != "" {
+		add("organization_id = $%d", f.OrganizationID)
+	}
+	if f.ActorType != "" {
+		add("actor_type = $%d", f.ActorType)
+	}
+	if f.ActorID != "" {
+		add("actor_id = $%d", f.ActorID)
+	}
+	if f.Action != "" {
+		add("action = $%d", f.Action)
+	}
+	if f.TargetType != "" {
+		add("target_type = $%d", f.TargetType)
+	}
+	if f.TargetID != "" {
+		add("target_id = $%d", f.TargetID)
+	}
+	if f.Outcome != "" {
+		add("outcome = $%d", f.Outcome)
+	}
+	if f.Since != nil {
+		add("occurred_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("occurred_at < $%d", *f.Until)
+	}
+	if f.BeforeID > 0 {
+		add("id < $%d", f.BeforeID)
+	}
+
+	query := `SELECT ` + auditColumns + ` FROM audit_events`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(` ORDER BY id DESC LIMIT $%d`, len(args))
+
+	var models []AuditEventModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
+		return nil, err
+	}
+	return modelsToAuditEvents(models)
+}
+
+// Chain returns the events of one chain in insertion order.
+func (r *AuditRepository) Chain(ctx context.Context, orgID *string, afterID int64, limit int) ([]*audit.Event, error) {
+	var models []AuditEventModel
+	var err error
+	if orgID == nil {
+		err = r.db.SelectContext(ctx, &models, `
+			SELECT `+auditColumns+` FROM audit_events
+			WHERE organization_id IS NULL AND id > $1
+			ORDER BY id LIMIT $2`, afterID, limit)
+	} else {
+		err = r.db.SelectContext(ctx, &models, `
+			SELECT `+auditColumns+` FROM audit_events
+			WHERE organization_id = $1 AND id > $2
+			ORDER BY id LIMIT $3`, *orgID, afterID, limit)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return modelsToAuditEvents(models)
+}
+
+func modelsToAuditEvents(models []AuditEventModel) ([]*audit.Event, error) {
+	out := make([]*audit.Event, len(models))
+	for i, m := range models {
+		e := &audit.Event{
+			ID:             m.ID,
+			OccurredAt:     m.OccurredAt,
+			OrganizationID: m.OrganizationID,
+			ActorType:      audit.ActorType(m.ActorType),
+			ActorID:        m.ActorID,
+			Action:         m.Action,
+			TargetType:     m.TargetType,
+			TargetID:       m.TargetID,
+			Outcome:        audit.Outcome(m.Outcome),
+			IP:             m.IP,
+			RequestID:      m.RequestID,
+			PrevHash:       m.PrevHash,
+			Hash:           m.Hash,
+		}
+		if len(m.Metadata) > 0 && string(m.Metadata) != "{}" {
+			if err := json.Unmarshal(m.Metadata, &e.Metadata); err != nil {
+				return nil, err
+			}
+		}
+		out[i] = e
+	}
+	return out, nil
+}
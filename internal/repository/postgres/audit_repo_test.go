@@ -0,0 +1,137 @@
+package postgres
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/audit"
+)
+
+func TestAuditRepositoryChains(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAuditRepository(db)
+	userID, orgID := createOrg(t, db, "owner@example.com")
+
+	appendEvent := func(org *string, action string, outcome audit.Outcome, metadata map[string]any) *audit.Event {
+		t.Helper()
+		e, err := repo.Append(ctx, &audit.Event{
+			OccurredAt:     time.Now().UTC().Truncate(time.Microsecond),
+			OrganizationID: org,
+			ActorType:      audit.ActorUser,
+			ActorID:        userID,
+			Action:         action,
+			TargetType:     "node",
+			TargetID:       "db-03",
+			Outcome:        outcome,
+			IP:             "203.0.113.7",
+			RequestID:      "req-1",
+			Metadata:       metadata,
+		})
+		if err != nil {
+			t.Fatalf("append %s: %v", action, err)
+		}
+		return e
+	}
+
+	first := appendEvent(&orgID, "job.dispatch", audit.OutcomeSuccess, map[string]any{"command": "reboot"})
+	system := appendEvent(nil, "auth.login", audit.OutcomeFailure, nil)
+	second := appendEvent(&orgID, "job.dispatch", audit.OutcomeFailure, nil)
+
+	// Cada organización tiene su propia cadena, y el sistema la suya
+	if first.PrevHash != audit.GenesisHash || system.PrevHash != audit.GenesisHash || second.PrevHash != first.Hash {
+		t.Errorf("prev hashes = %s, %s, %s", first.PrevHash, system.PrevHash, second.PrevHash)
+	}
+
+	chain, err := repo.Chain(ctx, &orgID, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chain) != 2 || chain[0].ID != first.ID || chain[1].ID != second.ID {
+		t.Fatalf("Chain = %+v", chain)
+	}
+	// Lo leído de la base tiene que producir el mismo hash que al insertar
+	prev := audit.GenesisHash
+	for _, e := range chain {
+		if got := audit.ComputeHash(prev, e); got != e.Hash {
+			t.Errorf("event %d: recomputed hash %s, stored %s", e.ID, got, e.Hash)
+		}
+		prev = e.Hash
+	}
+	if chain[0].Metadata["command"] != "reboot" || chain[1].Metadata != nil {
+		t.Errorf("metadata = %v, %v", chain[0].Metadata, chain[1].Metadata)
+	}
+	if after, _ := repo.Chain(ctx, &orgID, first.ID, 10); len(after) != 1 || after[0].ID != second.ID {
+		t.Errorf("Chain after the first event = %+v", after)
+	}
+	if sys, _ := repo.Chain(ctx, nil, 0, 10); len(sys) != 1 || sys[0].ID != system.ID {
+		t.Errorf("system chain = %+v", sys)
+	}
+
+	// Solo se puede añadir
+	if _, err := db.Exec(`UPDATE audit_events SET outcome = 'success' WHERE id = $1`, second.ID); err == nil {
+		t.Error("UPDATE on audit_events succeeded")
+	}
+	if _, err := db.Exec(`DELETE FROM audit_events WHERE id = $1`, first.ID); err == nil {
+		t.Error("DELETE on audit_events succeeded")
+	}
+}
+
+func TestAuditRepositoryList(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAuditRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	start := time.Now().UTC().Truncate(time.Microsecond)
+
+	var ids []int64
+	for i, action := range []string{"auth.login", "job.dispatch", "job.dispatch", "command.delete"} {
+		e, err := repo.Append(ctx, &audit.Event{
+			OccurredAt:     start.Add(time.Duration(i) * time.Minute),
+			OrganizationID: &orgID,
+			ActorType:      audit.ActorNode,
+			ActorID:        "node-1",
+			Action:         action,
+			Outcome:        audit.OutcomeSuccess,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, e.ID)
+	}
+
+	list := func(f audit.Filter) []int64 {
+		t.Helper()
+		if f.Limit == 0 {
+			f.Limit = 10
+		}
+		events, err := repo.List(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out := make([]int64, len(events))
+		for i, e := range events {
+			out[i] = e.ID
+		}
+		return out
+	}
+
+	if got := list(audit.Filter{OrganizationID: orgID}); !slices.Equal(got, []int64{ids[3], ids[2], ids[1], ids[0]}) {
+		t.Errorf("newest first = %v", got)
+	}
+	if got := list(audit.Filter{Action: "job.dispatch"}); !slices.Equal(got, []int64{ids[2], ids[1]}) {
+		t.Errorf("by action = %v", got)
+	}
+	since, until := start.Add(time.Minute), start.Add(3*time.Minute)
+	if got := list(audit.Filter{Since: &since, Until: &until}); !slices.Equal(got, []int64{ids[2], ids[1]}) {
+		t.Errorf("time range = %v", got)
+	}
+	if got := list(audit.Filter{BeforeID: ids[2], Limit: 1}); !slices.Equal(got, []int64{ids[1]}) {
+		t.Errorf("page before %d = %v", ids[2], got)
+	}
+	if got := list(audit.Filter{ActorType: audit.ActorUser}); len(got) != 0 {
+		t.Errorf("by actor type = %v", got)
+	}
+}
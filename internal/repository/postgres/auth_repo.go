@@ -3,6 +3,7 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"time"
 
 	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
 	"github.com/jmoiron/sqlx"
@@ -18,21 +19,22 @@ func NewAuthRepository(db *sqlx.DB) *AuthRepository {
 	return &AuthRepository{db: db}
 }
 
-// CreateUser crea un nuevo usuario
-func (r *AuthRepository) CreateUser(email, name, passwordHash string) (string, error) {
+// CreateUser crea un nuevo usuario. passwordHash vacío crea un usuario sin
+// contraseña (solo SSO).
+func (r *AuthRepository) CreateUser(ctx context.Context, email, name, passwordHash string) (string, error) {
 	var id string
-	err := r.db.QueryRowContext(context.Background(), `
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO users (email, name, password_hash)
-		VALUES ($1, $2, $3)
+		VALUES ($1, $2, NULLIF($3, ''))
 		RETURNING id`, email, name, passwordHash).Scan(&id)
 	return id, err
 }
 
 // FindUserByEmail busca un usuario por email
-func (r *AuthRepository) FindUserByEmail(email string) (*auth.User, error) {
+func (r *AuthRepository) FindUserByEmail(ctx context.Context, email string) (*auth.User, error) {
 	var model UserModel
-	err := r.db.Get(&model, `
-		SELECT id, email, name, password_hash, created_at, updated_at 
+	err := r.db.GetContext(ctx, &model, `
+		SELECT id, email, name, password_hash, email_verified_at, created_at, updated_at 
 		FROM users 
 		WHERE email = $1`, email)
 
@@ -44,38 +46,21 @@ func (r *AuthRepository) FindUserByEmail(email string) (*auth.User, error) {
 	}
 
 	return &auth.User{
-		ID:           model.ID,
-		Email:        model.Email,
-		Name:         model.Name,
-		PasswordHash: model.PasswordHash,
-		CreatedAt:    model.CreatedAt,
-		UpdatedAt:    model.UpdatedAt,
+		ID:              model.ID,
+		Email:           model.Email,
+		Name:            model.Name,
+		PasswordHash:    model.PasswordHash.String,
+		EmailVerifiedAt: model.EmailVerifiedAt,
+		CreatedAt:       model.CreatedAt,
+		UpdatedAt:       model.UpdatedAt,
 	}, nil
 }
 
-// StoreRefreshToken almacena un token de refresco
-func (r *AuthRepository) StoreRefreshToken(userID, tokenHash, userAgent, ip string) error {
-	_, err := r.db.Exec(`
-		INSERT INTO refresh_tokens (user_id, token_hash, user_agent, ip)
-		VALUES ($1, $2, $3, $4)`, userID, tokenHash, userAgent, ip)
-	return err
-}
-
-// RevokeRefreshToken revoca un token de refresco
-func (r *AuthRepository) RevokeRefreshToken(userID, tokenHash string) error {
-	_, err := r.db.Exec(`
-		UPDATE refresh_tokens 
-		SET revoked_at = now()
-		WHERE user_id = $1 AND token_hash = $2 AND revoked_at IS NULL`,
-		userID, tokenHash)
-	return err
-}
-
 // FindUserByID busca un usuario por ID
-func (r *AuthRepository) FindUserByID(id string) (*auth.User, error) {
+func (r *AuthRepository) FindUserByID(ctx context.Context, id string) (*auth.User, error) {
 	var model UserModel
-	err := r.db.Get(&model, `
-		SELECT id, email, name, password_hash, created_at, updated_at 
+	err := r.db.GetContext(ctx, &model, `
+		SELECT id, email, name, password_hash, email_verified_at, created_at, updated_at 
 		FROM users 
 		WHERE id = $1`, id)
 
@@ -87,28 +72,201 @@ func (r *AuthRepository) FindUserByID(id string) (*auth.User, error) {
 	}
 
 	return &auth.User{
-		ID:           model.ID,
-		Email:        model.Email,
-		Name:         model.Name,
-		PasswordHash: model.PasswordHash,
-		CreatedAt:    model.CreatedAt,
-		UpdatedAt:    model.UpdatedAt,
+		ID:              model.ID,
+		Email:           model.Email,
+		Name:            model.Name,
+		PasswordHash:    model.PasswordHash.String,
+		EmailVerifiedAt: model.EmailVerifiedAt,
+		CreatedAt:       model.CreatedAt,
+		UpdatedAt:       model.UpdatedAt,
 	}, nil
 }
 
-// FindRefreshToken busca un refresh token activo y retorna el userID
-func (r *AuthRepository) FindRefreshToken(tokenHash string) (string, error) {
-	var userID string
-	err := r.db.QueryRowContext(context.Background(), `
-		SELECT user_id FROM refresh_tokens
-		WHERE token_hash = $1 AND revoked_at IS NULL
-	`, tokenHash).Scan(&userID)
+// StoreRefreshToken almacena un token de refresco. Si FamilyID está vacío el
+// token abre una nueva familia cuyo id es el del propio token.
+func (r *AuthRepository) StoreRefreshToken(ctx context.Context, t *auth.RefreshToken) error {
+	return r.insertRefreshToken(ctx, r.db, t)
+}
 
+func (r *AuthRepository) insertRefreshToken(ctx context.Context, q sqlx.QueryerContext, t *auth.RefreshToken) error {
+	return q.QueryRowxContext(ctx, `
+		WITH new_token AS (SELECT gen_random_uuid() AS id)
+		INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, user_agent, ip, expires_at)
+		SELECT id, $1, COALESCE($2::uuid, id), $3, $4, NULLIF($5, '')::inet, $6
+		FROM new_token
+		RETURNING id, family_id, created_at`,
+		t.UserID, sql.NullString{String: t.FamilyID, Valid: t.FamilyID != ""},
+		t.TokenHash, t.UserAgent, t.IP, t.ExpiresAt,
+	).Scan(&t.ID, &t.FamilyID, &t.CreatedAt)
+}
+
+// FindRefreshTokenByHash busca un refresh token por su hash (incluidos revocados)
+func (r *AuthRepository) FindRefreshTokenByHash(ctx context.Context, tokenHash string) (*auth.RefreshToken, error) {
+	var m RefreshTokenModel
+	err := r.db.GetContext(ctx, &m, `
+		SELECT id, user_id, family_id, token_hash, user_agent, host(ip) AS ip,
+		       created_at, expires_at, revoked_at, revoked_reason
+		FROM refresh_tokens
+		WHERE token_hash = $1`, tokenHash)
 	if err == sql.ErrNoRows {
-		return "", sql.ErrNoRows
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &auth.RefreshToken{
+		ID:            m.ID,
+		UserID:        m.UserID,
+		FamilyID:      m.FamilyID,
+		TokenHash:     m.TokenHash,
+		UserAgent:     m.UserAgent.String,
+		IP:            m.IP.String,
+		CreatedAt:     m.CreatedAt,
+		ExpiresAt:     m.ExpiresAt,
+		RevokedAt:     m.RevokedAt,
+		RevokedReason: m.RevokedReason.String,
+	}, nil
+}
+
+// RotateRefreshToken revoca el token viejo y guarda el nuevo en una transacción.
+// El UPDATE condicionado a revoked_at IS NULL hace que solo una rotación
+// concurrente del mismo token pueda ganar.
+func (r *AuthRepository) RotateRefreshToken(ctx context.Context, oldID string, next *auth.RefreshToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now(), revoked_reason = $1
+		WHERE id = $2 AND revoked_at IS NULL`, auth.RevokedRotated, oldID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return auth.ErrRefreshTokenReused
+	}
+
+	if err := r.insertRefreshToken(ctx, tx, next); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// RevokeRefreshFamily revoca todos los tokens activos de una sesión
+func (r *AuthRepository) RevokeRefreshFamily(ctx context.Context, userID, familyID, reason string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now(), revoked_reason = $1
+		WHERE user_id = $2 AND family_id = $3 AND revoked_at IS NULL`,
+		reason, userID, familyID)
+	if err != nil {
+		return err
 	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return auth.ErrSessionNotFound
+	}
+	return nil
+}
+
+// FindActiveSessions devuelve una fila por familia con un token vigente
+func (r *AuthRepository) FindActiveSessions(ctx context.Context, userID string) ([]*auth.Session, error) {
+	var models []SessionModel
+	err := r.db.SelectContext(ctx, &models, `
+		SELECT t.family_id, t.user_agent, host(t.ip) AS ip,
+		       (SELECT min(f.created_at) FROM refresh_tokens f WHERE f.family_id = t.family_id) AS created_at,
+		       t.created_at AS last_used_at,
+		       t.expires_at
+		FROM refresh_tokens t
+		WHERE t.user_id = $1 AND t.revoked_at IS NULL AND t.expires_at > now()
+		ORDER BY t.created_at DESC`, userID)
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+
+	sessions := make([]*auth.Session, len(models))
+	for i, m := range models {
+		sessions[i] = &auth.Session{
+			ID:         m.FamilyID,
+			UserAgent:  m.UserAgent.String,
+			IP:         m.IP.String,
+			CreatedAt:  m.CreatedAt,
+			LastUsedAt: m.LastUsedAt,
+			ExpiresAt:  m.ExpiresAt,
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeAllRefreshTokens revoca todos los tokens activos del usuario
+func (r *AuthRepository) RevokeAllRefreshTokens(ctx context.Context, userID, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE refresh_tokens
+		SET revoked_at = now(), revoked_reason = $1
+		WHERE user_id = $2 AND revoked_at IS NULL`,
+		reason, userID)
+	return err
+}
+
+// UpdatePassword reemplaza el hash de la contraseña del usuario
+func (r *AuthRepository) UpdatePassword(ctx context.Context, userID, passwordHash string) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET password_hash = NULLIF($1, ''), updated_at = now()
+		WHERE id = $2`, passwordHash, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return auth.ErrUserNotFound
+	}
+	return nil
+}
+
+// MarkEmailVerified marca el email del usuario como verificado (idempotente)
+func (r *AuthRepository) MarkEmailVerified(ctx context.Context, userID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE users SET email_verified_at = now(), updated_at = now()
+		WHERE id = $1 AND email_verified_at IS NULL`, userID)
+	return err
+}
+
+// CreateUserToken guarda un token de un solo uso e invalida los anteriores
+// sin usar del mismo propósito
+func (r *AuthRepository) CreateUserToken(ctx context.Context, userID, purpose, tokenHash string, expiresAt time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE user_action_tokens SET used_at = now()
+		WHERE user_id = $1 AND purpose = $2 AND used_at IS NULL`,
+		userID, purpose); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO user_action_tokens (user_id, purpose, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)`,
+		userID, purpose, tokenHash, expiresAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ConsumeUserToken marca el token como usado si sigue vigente y devuelve su
+// usuario. La actualización es atómica: un token solo se puede canjear una vez.
+func (r *AuthRepository) ConsumeUserToken(ctx context.Context, purpose, tokenHash string) (string, error) {
+	var userID string
+	err := r.db.QueryRowContext(ctx, `
+		UPDATE user_action_tokens SET used_at = now()
+		WHERE token_hash = $1 AND purpose = $2
+		  AND used_at IS NULL AND expires_at > now()
+		RETURNING user_id`, tokenHash, purpose).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return "", auth.ErrInvalidUserToken
 	}
-	return userID, nil
+	return userID, err
 }
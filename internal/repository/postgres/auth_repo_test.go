@@ -0,0 +1,209 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+)
+
+func TestAuthRepositoryUsers(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAuthRepository(db)
+
+	id, err := repo.CreateUser(ctx, "Ana@Example.com", "Ana", "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.CreateUser(ctx, "ana@example.com", "Otra", "hash-2"); err == nil {
+		t.Error("CreateUser accepted a duplicate email differing only in case")
+	}
+
+	// email es CITEXT: la búsqueda no distingue mayúsculas
+	u, err := repo.FindUserByEmail(ctx, "ana@EXAMPLE.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u == nil || u.ID != id || u.PasswordHash != "hash-1" || u.EmailVerifiedAt != nil {
+		t.Fatalf("FindUserByEmail = %+v", u)
+	}
+	if u, err := repo.FindUserByID(ctx, id); err != nil || u == nil || u.Email != "Ana@Example.com" {
+		t.Errorf("FindUserByID = %+v, %v", u, err)
+	}
+	if u, err := repo.FindUserByEmail(ctx, "nobody@example.com"); u != nil || err != nil {
+		t.Errorf("FindUserByEmail(unknown) = %+v, %v; want nil, nil", u, err)
+	}
+	if u, err := repo.FindUserByID(ctx, unknownID); u != nil || err != nil {
+		t.Errorf("FindUserByID(unknown) = %+v, %v; want nil, nil", u, err)
+	}
+
+	// Sin contraseña (usuarios solo SSO)
+	ssoID, err := repo.CreateUser(ctx, "sso@example.com", "SSO", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := repo.FindUserByID(ctx, ssoID); u.PasswordHash != "" {
+		t.Errorf("SSO user password hash = %q", u.PasswordHash)
+	}
+
+	if err := repo.UpdatePassword(ctx, id, "hash-3"); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := repo.FindUserByID(ctx, id); u.PasswordHash != "hash-3" {
+		t.Errorf("password hash after update = %q", u.PasswordHash)
+	}
+	if err := repo.UpdatePassword(ctx, unknownID, "x"); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Errorf("UpdatePassword(unknown) error = %v, want ErrUserNotFound", err)
+	}
+
+	if err := repo.MarkEmailVerified(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := repo.FindUserByID(ctx, id)
+	sleepTick()
+	if err := repo.MarkEmailVerified(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := repo.FindUserByID(ctx, id)
+	if first.EmailVerifiedAt == nil || !second.EmailVerifiedAt.Equal(*first.EmailVerifiedAt) {
+		t.Errorf("email_verified_at = %v then %v, want set once", first.EmailVerifiedAt, second.EmailVerifiedAt)
+	}
+}
+
+func TestAuthRepositoryRefreshTokens(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAuthRepository(db)
+	userID := createUser(t, db, "ana@example.com")
+	expires := time.Now().Add(time.Hour)
+
+	first := &auth.RefreshToken{UserID: userID, TokenHash: "h1", UserAgent: "curl/8", IP: "203.0.113.7", ExpiresAt: expires}
+	if err := repo.StoreRefreshToken(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if first.FamilyID != first.ID {
+		t.Errorf("first token family = %s, want its own id %s", first.FamilyID, first.ID)
+	}
+
+	got, err := repo.FindRefreshTokenByHash(ctx, "h1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.UserID != userID || got.IP != "203.0.113.7" || got.UserAgent != "curl/8" || got.RevokedAt != nil {
+		t.Fatalf("FindRefreshTokenByHash = %+v", got)
+	}
+	if got, err := repo.FindRefreshTokenByHash(ctx, "missing"); got != nil || err != nil {
+		t.Errorf("FindRefreshTokenByHash(unknown) = %+v, %v; want nil, nil", got, err)
+	}
+
+	// Rotar mantiene la familia y revoca el token anterior
+	next := &auth.RefreshToken{UserID: userID, FamilyID: first.FamilyID, TokenHash: "h2", ExpiresAt: expires}
+	if err := repo.RotateRefreshToken(ctx, first.ID, next); err != nil {
+		t.Fatal(err)
+	}
+	if next.FamilyID != first.FamilyID {
+		t.Errorf("rotated token family = %s, want %s", next.FamilyID, first.FamilyID)
+	}
+	old, _ := repo.FindRefreshTokenByHash(ctx, "h1")
+	if old.RevokedAt == nil || old.RevokedReason != auth.RevokedRotated {
+		t.Errorf("old token after rotation = %+v", old)
+	}
+
+	// Reutilizar el token ya rotado
+	replay := &auth.RefreshToken{UserID: userID, FamilyID: first.FamilyID, TokenHash: "h3", ExpiresAt: expires}
+	if err := repo.RotateRefreshToken(ctx, first.ID, replay); !errors.Is(err, auth.ErrRefreshTokenReused) {
+		t.Errorf("second rotation error = %v, want ErrRefreshTokenReused", err)
+	}
+	if got, _ := repo.FindRefreshTokenByHash(ctx, "h3"); got != nil {
+		t.Error("failed rotation stored the new token")
+	}
+
+	// Otra sesión del mismo usuario, y una caducada que no se lista
+	other := &auth.RefreshToken{UserID: userID, TokenHash: "h4", ExpiresAt: expires}
+	if err := repo.StoreRefreshToken(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	expired := &auth.RefreshToken{UserID: userID, TokenHash: "h5", ExpiresAt: time.Now().Add(-time.Minute)}
+	if err := repo.StoreRefreshToken(ctx, expired); err != nil {
+		t.Fatal(err)
+	}
+
+	sessions, err := repo.FindActiveSessions(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != other.FamilyID || sessions[1].ID != first.FamilyID {
+		t.Fatalf("sessions = %+v", sessions)
+	}
+	if s := sessions[1]; !s.CreatedAt.Equal(first.CreatedAt) || !s.LastUsedAt.Equal(next.CreatedAt) || s.IP != "" {
+		t.Errorf("rotated session = %+v, want created at the first token and last used at the second", s)
+	}
+
+	if err := repo.RevokeRefreshFamily(ctx, userID, first.FamilyID, auth.RevokedLogout); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RevokeRefreshFamily(ctx, userID, first.FamilyID, auth.RevokedLogout); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("revoking a revoked family error = %v, want ErrSessionNotFound", err)
+	}
+	otherUser := createUser(t, db, "eve@example.com")
+	if err := repo.RevokeRefreshFamily(ctx, otherUser, other.FamilyID, auth.RevokedLogout); !errors.Is(err, auth.ErrSessionNotFound) {
+		t.Errorf("revoking another user's family error = %v, want ErrSessionNotFound", err)
+	}
+
+	if err := repo.RevokeAllRefreshTokens(ctx, userID, auth.RevokedPasswordChange); err != nil {
+		t.Fatal(err)
+	}
+	if sessions, _ := repo.FindActiveSessions(ctx, userID); len(sessions) != 0 {
+		t.Errorf("sessions after revoking all = %+v", sessions)
+	}
+	if got, _ := repo.FindRefreshTokenByHash(ctx, "h4"); got.RevokedReason != auth.RevokedPasswordChange {
+		t.Errorf("revoked reason = %q", got.RevokedReason)
+	}
+}
+
+func TestAuthRepositoryUserTokens(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewAuthRepository(db)
+	userID := createUser(t, db, "ana@example.com")
+	expires := time.Now().Add(time.Hour)
+
+	if err := repo.CreateUserToken(ctx, userID, auth.PurposePasswordReset, "reset-1", expires); err != nil {
+		t.Fatal(err)
+	}
+	// Un token nuevo invalida el anterior del mismo propósito, pero no los de otros
+	if err := repo.CreateUserToken(ctx, userID, auth.PurposeEmailVerification, "verify-1", expires); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.CreateUserToken(ctx, userID, auth.PurposePasswordReset, "reset-2", expires); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ConsumeUserToken(ctx, auth.PurposePasswordReset, "reset-1"); !errors.Is(err, auth.ErrInvalidUserToken) {
+		t.Errorf("superseded token error = %v, want ErrInvalidUserToken", err)
+	}
+	if got, err := repo.ConsumeUserToken(ctx, auth.PurposeEmailVerification, "verify-1"); err != nil || got != userID {
+		t.Errorf("ConsumeUserToken(verify-1) = %q, %v", got, err)
+	}
+
+	// El propósito tiene que coincidir
+	if _, err := repo.ConsumeUserToken(ctx, auth.PurposeEmailVerification, "reset-2"); !errors.Is(err, auth.ErrInvalidUserToken) {
+		t.Errorf("wrong purpose error = %v, want ErrInvalidUserToken", err)
+	}
+	if got, err := repo.ConsumeUserToken(ctx, auth.PurposePasswordReset, "reset-2"); err != nil || got != userID {
+		t.Errorf("ConsumeUserToken(reset-2) = %q, %v", got, err)
+	}
+	// Un solo uso
+	if _, err := repo.ConsumeUserToken(ctx, auth.PurposePasswordReset, "reset-2"); !errors.Is(err, auth.ErrInvalidUserToken) {
+		t.Errorf("second use error = %v, want ErrInvalidUserToken", err)
+	}
+
+	if err := repo.CreateUserToken(ctx, userID, auth.PurposePasswordReset, "reset-old", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.ConsumeUserToken(ctx, auth.PurposePasswordReset, "reset-old"); !errors.Is(err, auth.ErrInvalidUserToken) {
+		t.Errorf("expired token error = %v, want ErrInvalidUserToken", err)
+	}
+}
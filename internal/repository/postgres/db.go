@@ -0,0 +1,23 @@
+package postgres
+
+import (
+	"database/sql"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+
+	"github.com/arturo/autohost-cloud-api/internal/tracing"
+)
+
+// DriverName es el driver de lib/pq envuelto para trazar las consultas
+const DriverName = "postgres+otel"
+
+func init() {
+	sql.Register(DriverName, tracing.WrapDriver(&pq.Driver{}, "postgresql"))
+	sqlx.BindDriver(DriverName, sqlx.DOLLAR)
+}
+
+// Connect abre el pool con DriverName y comprueba la conexión
+func Connect(dsn string) (*sqlx.DB, error) {
+	return sqlx.Connect(DriverName, dsn)
+}
@@ -1,6 +1,8 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
@@ -11,38 +13,50 @@ type EnrollTokenRepo struct{ DB *sqlx.DB }
 
 func NewEnrollmentRepository(db *sqlx.DB) *EnrollTokenRepo { return &EnrollTokenRepo{DB: db} }
 
-func (r *EnrollTokenRepo) CreateEnrollToken(token string, userID string, expiresAt time.Time) error {
-	_, err := r.DB.Exec(`
-		INSERT INTO enroll_tokens (token, user_id, expires_at)
-		VALUES ($1, $2, $3)
-	`, token, userID, expiresAt)
+func (r *EnrollTokenRepo) CreateEnrollToken(ctx context.Context, token string, userID string, orgID string, expiresAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
+		INSERT INTO enroll_tokens (token, user_id, organization_id, expires_at)
+		VALUES ($1, $2, $3, $4)
+	`, token, userID, orgID, expiresAt)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func (r *EnrollTokenRepo) FindEnrollTokenByHash(token string) (*enrollment.EnrollToken, error) {
+func (r *EnrollTokenRepo) FindEnrollTokenByHash(ctx context.Context, token string) (*enrollment.EnrollToken, error) {
 	var model enrollment.EnrollToken
-	err := r.DB.Get(&model, `
-		SELECT id, token, user_id, expires_at, consumed_at, created_at
+	err := r.DB.GetContext(ctx, &model, `
+		SELECT id, token, user_id, organization_id, expires_at, consumed_at, created_at
 		FROM enroll_tokens
 		WHERE token = $1
 	`, token)
+	if err == sql.ErrNoRows {
+		return nil, enrollment.ErrEnrollTokenNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &model, nil
 }
 
-func (r *EnrollTokenRepo) MarkTokenAsUsed(token string, usedAt time.Time) error {
-	_, err := r.DB.Exec(`
+// ConsumeEnrollToken comprueba y consume el token en una sola sentencia para
+// que dos enrollments simultáneos no lo usen los dos
+func (r *EnrollTokenRepo) ConsumeEnrollToken(ctx context.Context, token string, now time.Time) error {
+	res, err := r.DB.ExecContext(ctx, `
 		UPDATE enroll_tokens
 		SET consumed_at = $1
-		WHERE token = $2
-	`, usedAt, token)
+		WHERE token = $2 AND consumed_at IS NULL AND expires_at > $1
+	`, now, token)
 	if err != nil {
 		return err
 	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return enrollment.ErrEnrollTokenUsed
+	}
 	return nil
 }
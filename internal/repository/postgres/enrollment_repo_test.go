@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/enrollment"
+)
+
+func TestEnrollTokenRepo(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewEnrollmentRepository(db)
+	userID, orgID := createOrg(t, db, "owner@example.com")
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+
+	if err := repo.CreateEnrollToken(ctx, "hash-1", userID, orgID, expires); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := repo.FindEnrollTokenByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.UserID != userID || tok.OrganizationID != orgID || !tok.ExpiresAt.Equal(expires) || tok.ConsumedAt != nil {
+		t.Fatalf("FindEnrollTokenByHash = %+v", tok)
+	}
+
+	usedAt := time.Now().UTC().Truncate(time.Microsecond)
+	if err := repo.ConsumeEnrollToken(ctx, "hash-1", usedAt); err != nil {
+		t.Fatal(err)
+	}
+	tok, _ = repo.FindEnrollTokenByHash(ctx, "hash-1")
+	if tok.ConsumedAt == nil || !tok.ConsumedAt.Equal(usedAt) {
+		t.Errorf("consumed_at = %v, want %v", tok.ConsumedAt, usedAt)
+	}
+	if err := repo.ConsumeEnrollToken(ctx, "hash-1", usedAt); !errors.Is(err, enrollment.ErrEnrollTokenUsed) {
+		t.Errorf("second ConsumeEnrollToken error = %v, want ErrEnrollTokenUsed", err)
+	}
+
+	// An expired token cannot be consumed
+	if err := repo.CreateEnrollToken(ctx, "hash-2", userID, orgID, usedAt.Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.ConsumeEnrollToken(ctx, "hash-2", usedAt); !errors.Is(err, enrollment.ErrEnrollTokenUsed) {
+		t.Errorf("ConsumeEnrollToken(expired) error = %v, want ErrEnrollTokenUsed", err)
+	}
+
+	if _, err := repo.FindEnrollTokenByHash(ctx, "missing"); !errors.Is(err, enrollment.ErrEnrollTokenNotFound) {
+		t.Errorf("FindEnrollTokenByHash(unknown) error = %v, want ErrEnrollTokenNotFound", err)
+	}
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+	"github.com/jmoiron/sqlx"
+)
+
+// InvitationRepository implements invitation.Repository using PostgreSQL.
+type InvitationRepository struct {
+	db *sqlx.DB
+}
+
+func NewInvitationRepository(db *sqlx.DB) *InvitationRepository {
+	return &InvitationRepository{db: db}
+}
+
+const invitationColumns = `id, organization_id, email, role, token_hash, invited_by,
+	expires_at, accepted_at, declined_at, created_at`
+
+// Create replaces any pending invitation for the same org/email and inserts the new one.
+func (r *InvitationRepository) Create(ctx context.Context, inv *invitation.Invitation) (*invitation.Invitation, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		DELETE FROM organization_invitations
+		WHERE organization_id = $1 AND email = $2
+		  AND accepted_at IS NULL AND declined_at IS NULL`,
+		inv.OrganizationID, inv.Email); err != nil {
+		return nil, err
+	}
+
+	var out invitation.Invitation
+	err = tx.GetContext(ctx, &out, `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+invitationColumns,
+		inv.OrganizationID, inv.Email, inv.Role, inv.TokenHash, inv.InvitedBy, inv.ExpiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FindByTokenHash returns an invitation by the hash of its token.
+func (r *InvitationRepository) FindByTokenHash(ctx context.Context, tokenHash string) (*invitation.Invitation, error) {
+	var inv invitation.Invitation
+	err := r.db.GetContext(ctx, &inv,
+		`SELECT `+invitationColumns+` FROM organization_invitations WHERE token_hash = $1`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, invitation.ErrInvitationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &inv, nil
+}
+
+// FindPendingByOrganization lists open, unexpired invitations of an organization.
+func (r *InvitationRepository) FindPendingByOrganization(ctx context.Context, orgID string) ([]*invitation.Invitation, error) {
+	var invs []*invitation.Invitation
+	err := r.db.SelectContext(ctx, &invs, `
+		SELECT `+invitationColumns+`
+		FROM organization_invitations
+		WHERE organization_id = $1
+		  AND accepted_at IS NULL AND declined_at IS NULL
+		  AND expires_at > now()
+		ORDER BY created_at DESC`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return invs, nil
+}
+
+// MarkAccepted sets accepted_at on a pending invitation.
+func (r *InvitationRepository) MarkAccepted(ctx context.Context, id string, at time.Time) error {
+	return r.resolve(ctx, `accepted_at`, id, at)
+}
+
+// MarkDeclined sets declined_at on a pending invitation.
+func (r *InvitationRepository) MarkDeclined(ctx context.Context, id string, at time.Time) error {
+	return r.resolve(ctx, `declined_at`, id, at)
+}
+
+// resolve closes a pending invitation; the WHERE clause makes it single-use
+// even under concurrent requests.
+func (r *InvitationRepository) resolve(ctx context.Context, column, id string, at time.Time) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE organization_invitations SET `+column+` = $1
+		WHERE id = $2 AND accepted_at IS NULL AND declined_at IS NULL`, at, id)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return invitation.ErrInvitationUsed
+	}
+	return nil
+}
+
+// Delete removes an invitation that belongs to orgID.
+func (r *InvitationRepository) Delete(ctx context.Context, orgID, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM organization_invitations WHERE organization_id = $1 AND id = $2`, orgID, id)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return invitation.ErrInvitationNotFound
+	}
+	return nil
+}
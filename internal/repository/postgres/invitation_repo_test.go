@@ -0,0 +1,95 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/invitation"
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+func TestInvitationRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewInvitationRepository(db)
+	ownerID, orgID := createOrg(t, db, "owner@example.com")
+
+	invite := func(email, hash string, expires time.Time) *invitation.Invitation {
+		t.Helper()
+		inv, err := repo.Create(ctx, &invitation.Invitation{
+			OrganizationID: orgID,
+			Email:          email,
+			Role:           organization.RoleOperator,
+			TokenHash:      hash,
+			InvitedBy:      &ownerID,
+			ExpiresAt:      expires,
+		})
+		if err != nil {
+			t.Fatalf("create invitation: %v", err)
+		}
+		return inv
+	}
+	later := time.Now().Add(time.Hour)
+
+	first := invite("bob@example.com", "h1", later)
+	// Reinvitar reemplaza la invitación pendiente anterior
+	second := invite("BOB@example.com", "h2", later)
+	if _, err := repo.FindByTokenHash(ctx, "h1"); !errors.Is(err, invitation.ErrInvitationNotFound) {
+		t.Errorf("replaced invitation error = %v, want ErrInvitationNotFound", err)
+	}
+	got, err := repo.FindByTokenHash(ctx, "h2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != second.ID || got.ID == first.ID || got.Role != organization.RoleOperator ||
+		got.InvitedBy == nil || *got.InvitedBy != ownerID {
+		t.Errorf("FindByTokenHash = %+v", got)
+	}
+
+	invite("expired@example.com", "h3", time.Now().Add(-time.Minute))
+	carol := invite("carol@example.com", "h4", later)
+
+	pending, err := repo.FindPendingByOrganization(ctx, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 2 || pending[0].ID != carol.ID || pending[1].ID != second.ID {
+		t.Errorf("FindPendingByOrganization = %+v", pending)
+	}
+
+	// Aceptar o rechazar es de un solo uso
+	if err := repo.MarkAccepted(ctx, second.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.MarkDeclined(ctx, second.ID, time.Now()); !errors.Is(err, invitation.ErrInvitationUsed) {
+		t.Errorf("declining an accepted invitation error = %v, want ErrInvitationUsed", err)
+	}
+	if err := repo.MarkDeclined(ctx, carol.ID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.FindByTokenHash(ctx, "h4"); got.DeclinedAt == nil || got.AcceptedAt != nil {
+		t.Errorf("declined invitation = %+v", got)
+	}
+	if pending, _ := repo.FindPendingByOrganization(ctx, orgID); len(pending) != 0 {
+		t.Errorf("pending after resolving = %+v", pending)
+	}
+
+	// Una invitación aceptada no se reemplaza al volver a invitar
+	invite("bob@example.com", "h5", later)
+	if _, err := repo.FindByTokenHash(ctx, "h2"); err != nil {
+		t.Errorf("accepted invitation was removed: %v", err)
+	}
+
+	_, otherOrg := createOrg(t, db, "other@example.com")
+	if err := repo.Delete(ctx, otherOrg, carol.ID); !errors.Is(err, invitation.ErrInvitationNotFound) {
+		t.Errorf("Delete from another organization error = %v, want ErrInvitationNotFound", err)
+	}
+	if err := repo.Delete(ctx, orgID, carol.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FindByTokenHash(ctx, "h4"); !errors.Is(err, invitation.ErrInvitationNotFound) {
+		t.Errorf("deleted invitation error = %v, want ErrInvitationNotFound", err)
+	}
+}
@@ -0,0 +1,81 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+	"github.com/jmoiron/sqlx"
+)
+
+// JobArtifactRepository implements jobartifact.Repository using PostgreSQL.
+type JobArtifactRepository struct {
+	db *sqlx.DB
+}
+
+func NewJobArtifactRepository(db *sqlx.DB) *JobArtifactRepository {
+	return &JobArtifactRepository{db: db}
+}
+
+const jobArtifactColumns = `id, job_id, name, content_type, size, sha256, created_at, expires_at`
+
+// Create inserts an artifact; the (job_id, name) conflict is reported as
+// ErrArtifactExists.
+func (r *JobArtifactRepository) Create(ctx context.Context, a *jobartifact.Artifact) (*jobartifact.Artifact, error) {
+	var out jobartifact.Artifact
+	err := r.db.GetContext(ctx, &out, `
+		INSERT INTO job_artifacts (job_id, name, content_type, size, sha256, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (job_id, name) DO NOTHING
+		RETURNING `+jobArtifactColumns,
+		a.JobID, a.Name, a.ContentType, a.Size, a.SHA256, a.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, jobartifact.ErrArtifactExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *JobArtifactRepository) Find(ctx context.Context, jobID, name string) (*jobartifact.Artifact, error) {
+	var out jobartifact.Artifact
+	err := r.db.GetContext(ctx, &out,
+		`SELECT `+jobArtifactColumns+` FROM job_artifacts WHERE job_id = $1 AND name = $2`, jobID, name)
+	if err == sql.ErrNoRows {
+		return nil, jobartifact.ErrArtifactNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *JobArtifactRepository) ListByJob(ctx context.Context, jobID string) ([]*jobartifact.Artifact, error) {
+	var out []*jobartifact.Artifact
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT `+jobArtifactColumns+` FROM job_artifacts WHERE job_id = $1 ORDER BY name`, jobID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *JobArtifactRepository) DeleteExpired(ctx context.Context, t time.Time) ([]string, error) {
+	var keys []string
+	err := r.db.SelectContext(ctx, &keys,
+		`DELETE FROM job_artifacts WHERE expires_at <= $1 RETURNING sha256`, t)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *JobArtifactRepository) Referenced(ctx context.Context, sha256 string) (bool, error) {
+	var used bool
+	err := r.db.GetContext(ctx, &used,
+		`SELECT EXISTS (SELECT 1 FROM job_artifacts WHERE sha256 = $1)`, sha256)
+	return used, err
+}
@@ -0,0 +1,83 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	jobartifact "github.com/arturo/autohost-cloud-api/internal/domain/job_artifact"
+)
+
+func TestJobArtifactRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewJobArtifactRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "db-01")
+	j := createJob(t, NewJobRepository(db), n.ID, "backup")
+
+	sumA, sumB := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	create := func(name, sum string, expiresAt time.Time) (*jobartifact.Artifact, error) {
+		return repo.Create(ctx, &jobartifact.Artifact{
+			JobID: j.ID, Name: name, ContentType: "application/gzip", Size: 42, SHA256: sum, ExpiresAt: expiresAt,
+		})
+	}
+
+	later := time.Now().Add(time.Hour)
+	dump, err := create("dump.sql.gz", sumA, later)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dump.ID == "" || dump.Size != 42 || dump.CreatedAt.IsZero() {
+		t.Errorf("Create = %+v", dump)
+	}
+	if _, err := create("dump.sql.gz", sumB, later); !errors.Is(err, jobartifact.ErrArtifactExists) {
+		t.Errorf("duplicate name error = %v, want ErrArtifactExists", err)
+	}
+	if _, err := create("../etc/passwd", sumB, later); err == nil {
+		t.Error("the database accepted a name with a slash")
+	}
+	if _, err := create("old.log", sumB, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := create("copy.sql.gz", sumA, time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, err := repo.Find(ctx, j.ID, "dump.sql.gz"); err != nil || got.SHA256 != sumA {
+		t.Errorf("Find = %+v, %v", got, err)
+	}
+	if _, err := repo.Find(ctx, j.ID, "missing"); !errors.Is(err, jobartifact.ErrArtifactNotFound) {
+		t.Errorf("Find(missing) error = %v, want ErrArtifactNotFound", err)
+	}
+	list, err := repo.ListByJob(ctx, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, a := range list {
+		names = append(names, a.Name)
+	}
+	if !slices.Equal(names, []string{"copy.sql.gz", "dump.sql.gz", "old.log"}) {
+		t.Errorf("ListByJob = %v", names)
+	}
+
+	keys, err := repo.DeleteExpired(ctx, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(keys)
+	if !slices.Equal(keys, []string{sumA, sumB}) {
+		t.Errorf("DeleteExpired = %v", keys)
+	}
+	// dump.sql.gz still uses sumA
+	if used, err := repo.Referenced(ctx, sumA); err != nil || !used {
+		t.Errorf("Referenced(a) = %v, %v", used, err)
+	}
+	if used, err := repo.Referenced(ctx, sumB); err != nil || used {
+		t.Errorf("Referenced(b) = %v, %v", used, err)
+	}
+}
@@ -3,11 +3,13 @@ package postgres
 import (
 	"context"
 	"database/sql"
-	"time"
+	"fmt"
+	"strings"
 
 	"github.com/arturo/autohost-cloud-api/internal/domain/job"
 	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 // JobRepository implements job.Repository using PostgreSQL.
@@ -20,16 +22,18 @@ func NewJobRepository(db *sqlx.DB) *JobRepository {
 }
 
 // Create inserts a new job record with status=pending.
-func (r *JobRepository) Create(j *job.Job) (*job.Job, error) {
+func (r *JobRepository) Create(ctx context.Context, j *job.Job) (*job.Job, error) {
 	var m JobModel
-	err := r.db.QueryRowContext(context.Background(), `
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO jobs (node_id, command_name, command_type, status)
 		VALUES ($1, $2, $3, $4)
-		RETURNING id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at`,
+		RETURNING id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at,
+		          output_size, output_truncated, output_blob`,
 		j.NodeID, j.CommandName, j.CommandType, j.Status,
 	).Scan(
 		&m.ID, &m.NodeID, &m.CommandName, &m.CommandType, &m.Status,
 		&m.Output, &m.Error, &m.CreatedAt, &m.StartedAt, &m.FinishedAt,
+		&m.OutputSize, &m.OutputTruncated, &m.OutputBlob,
 	)
 	if err != nil {
 		return nil, err
@@ -38,10 +42,11 @@ func (r *JobRepository) Create(j *job.Job) (*job.Job, error) {
 }
 
 // FindByID returns a job by its UUID.
-func (r *JobRepository) FindByID(id string) (*job.Job, error) {
+func (r *JobRepository) FindByID(ctx context.Context, id string) (*job.Job, error) {
 	var m JobModel
-	err := r.db.GetContext(context.Background(), &m,
-		`SELECT id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at
+	err := r.db.GetContext(ctx, &m,
+		`SELECT id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at,
+		        output_size, output_truncated, output_blob
 		 FROM jobs WHERE id = $1`, id)
 	if err == sql.ErrNoRows {
 		return nil, job.ErrJobNotFound
@@ -52,13 +57,61 @@ func (r *JobRepository) FindByID(id string) (*job.Job, error) {
 	return modelToJob(m), nil
 }
 
-// FindByNodeID returns all jobs for a node ordered by creation time desc.
-func (r *JobRepository) FindByNodeID(nodeID string) ([]*job.Job, error) {
+// jobSortColumns maps the job sort fields to their columns.
+var jobSortColumns = map[string]sortColumn{
+	job.SortCreatedAt:   {"created_at", "timestamptz"},
+	job.SortCommandName: {"command_name", "text"},
+}
+
+// List returns the jobs matching f, keyset-paginated on (sort field, id).
+func (r *JobRepository) List(ctx context.Context, f job.ListFilter) ([]*job.Job, error) {
+	col, ok := jobSortColumns[f.Sort.Field]
+	if !ok {
+		return nil, job.ErrInvalidFilter
+	}
+
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.NodeID != "" {
+		add("node_id = $%d", f.NodeID)
+	}
+	if len(f.Statuses) > 0 {
+		statuses := make([]string, len(f.Statuses))
+		for i, st := range f.Statuses {
+			statuses[i] = string(st)
+		}
+		add("status = ANY($%d)", pq.Array(statuses))
+	}
+	if f.Since != nil {
+		add("created_at >= $%d", *f.Since)
+	}
+	if f.Until != nil {
+		add("created_at < $%d", *f.Until)
+	}
+	if f.After != nil {
+		args = append(args, f.After.Value, f.After.ID)
+		where = append(where, keysetCondition(col, f.Sort.Desc, len(args)-1, len(args)))
+	}
+
+	query := `SELECT id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at,
+		       output_size, output_truncated, output_blob
+		FROM jobs`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += keysetOrder(col, f.Sort)
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
+
 	var models []JobModel
-	err := r.db.SelectContext(context.Background(), &models,
-		`SELECT id, node_id, command_name, command_type, status, output, error, created_at, started_at, finished_at
-		 FROM jobs WHERE node_id = $1 ORDER BY created_at DESC`, nodeID)
-	if err != nil {
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
 		return nil, err
 	}
 	out := make([]*job.Job, len(models))
@@ -68,51 +121,62 @@ func (r *JobRepository) FindByNodeID(nodeID string) ([]*job.Job, error) {
 	return out, nil
 }
 
-// UpdateStatus updates a job's status, output and error. It also sets
-// started_at / finished_at timestamps automatically from the status transition.
-func (r *JobRepository) UpdateStatus(id string, status job.JobStatus, output, errMsg string) error {
-	now := time.Now()
-	var query string
-	var args []interface{}
-
-	switch status {
-	case job.StatusRunning:
-		query = `UPDATE jobs
-		         SET status = $1, output = $2, error = $3, started_at = $4
-		         WHERE id = $5`
-		args = []interface{}{status, output, errMsg, now, id}
-	case job.StatusCompleted, job.StatusFailed:
-		query = `UPDATE jobs
-		         SET status = $1, output = $2, error = $3, finished_at = $4
-		         WHERE id = $5`
-		args = []interface{}{status, output, errMsg, now, id}
-	default:
-		query = `UPDATE jobs SET status = $1, output = $2, error = $3 WHERE id = $4`
-		args = []interface{}{status, output, errMsg, id}
+// UpdateStatus moves a job to status if it belongs to nodeID and its current
+// status allows the transition; the WHERE clause makes check and update one
+// atomic statement. started_at is set on the first move out of pending (also
+// when the node skips running) and finished_at on a final status.
+func (r *JobRepository) UpdateStatus(ctx context.Context, id, nodeID string, status job.JobStatus, output job.Output, errMsg string) error {
+	from := job.TransitionSources(status)
+	sources := make([]string, len(from))
+	for i, st := range from {
+		sources[i] = string(st)
 	}
-
-	res, err := r.db.ExecContext(context.Background(), query, args...)
+	// Timestamps come from the database clock so started_at <= finished_at
+	// holds (the jobs_status_timestamps CHECK) across API replicas.
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE jobs
+		SET status = $1, output = $2, error = $3,
+		    output_size = $4, output_truncated = $5, output_blob = NULLIF($6, ''),
+		    started_at = COALESCE(started_at, now()),
+		    finished_at = CASE WHEN $7 THEN now() END
+		WHERE id = $8 AND node_id = $9 AND status = ANY($10)`,
+		status, output.Text, errMsg, output.Size, output.Truncated, output.BlobKey,
+		status.Final(), id, nodeID, pq.Array(sources),
+	)
 	if err != nil {
 		return err
 	}
-	n, _ := res.RowsAffected()
-	if n == 0 {
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+
+	// Nothing changed: either the job is not this node's or the transition
+	// is not allowed. Statuses only move forward, so this answer stays true.
+	var current string
+	err = r.db.GetContext(ctx, &current, `SELECT status FROM jobs WHERE id = $1 AND node_id = $2`, id, nodeID)
+	if err == sql.ErrNoRows {
 		return job.ErrJobNotFound
 	}
-	return nil
+	if err != nil {
+		return err
+	}
+	return job.ErrInvalidTransition
 }
 
 func modelToJob(m JobModel) *job.Job {
 	return &job.Job{
-		ID:          m.ID,
-		NodeID:      m.NodeID,
-		CommandName: m.CommandName,
-		CommandType: nodecommand.CommandType(m.CommandType),
-		Status:      job.JobStatus(m.Status),
-		Output:      m.Output.String,
-		Error:       m.Error.String,
-		CreatedAt:   m.CreatedAt,
-		StartedAt:   m.StartedAt,
-		FinishedAt:  m.FinishedAt,
+		ID:              m.ID,
+		NodeID:          m.NodeID,
+		CommandName:     m.CommandName,
+		CommandType:     nodecommand.CommandType(m.CommandType),
+		Status:          job.JobStatus(m.Status),
+		Output:          m.Output.String,
+		Error:           m.Error.String,
+		CreatedAt:       m.CreatedAt,
+		StartedAt:       m.StartedAt,
+		FinishedAt:      m.FinishedAt,
+		OutputSize:      m.OutputSize,
+		OutputTruncated: m.OutputTruncated,
+		OutputBlob:      m.OutputBlob.String,
 	}
 }
@@ -0,0 +1,229 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/job"
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+const unknownID = "00000000-0000-0000-0000-000000000000"
+
+func createJob(t *testing.T, repo *JobRepository, nodeID, command string) *job.Job {
+	t.Helper()
+	j, err := repo.Create(context.Background(), &job.Job{
+		NodeID:      nodeID,
+		CommandName: command,
+		CommandType: nodecommand.CommandTypeDefault,
+		Status:      job.StatusPending,
+	})
+	if err != nil {
+		t.Fatalf("create job: %v", err)
+	}
+	return j
+}
+
+func TestJobRepositoryCreateAndFind(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewJobRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+
+	j := createJob(t, repo, n.ID, "uptime")
+	if j.ID == "" || j.Status != job.StatusPending || j.StartedAt != nil || j.FinishedAt != nil {
+		t.Fatalf("Create = %+v", j)
+	}
+
+	got, err := repo.FindByID(ctx, j.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.NodeID != n.ID || got.CommandName != "uptime" || got.CommandType != nodecommand.CommandTypeDefault ||
+		got.Output != "" || got.Error != "" {
+		t.Errorf("FindByID = %+v", got)
+	}
+
+	if _, err := repo.FindByID(ctx, unknownID); !errors.Is(err, job.ErrJobNotFound) {
+		t.Errorf("FindByID(unknown) error = %v, want ErrJobNotFound", err)
+	}
+}
+
+func TestJobRepositoryUpdateStatusTransitions(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewJobRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+	other := createNode(t, db, orgID, "web-02")
+
+	t.Run("running sets started_at", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusRunning, job.Output{}, ""); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := repo.FindByID(ctx, j.ID)
+		if got.Status != job.StatusRunning || got.StartedAt == nil || got.FinishedAt != nil {
+			t.Errorf("after running: %+v", got)
+		}
+	})
+
+	t.Run("completed sets finished_at and output", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusRunning, job.Output{}, ""); err != nil {
+			t.Fatal(err)
+		}
+		started, _ := repo.FindByID(ctx, j.ID)
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusCompleted, job.Output{Text: "up 3 days"}, ""); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := repo.FindByID(ctx, j.ID)
+		if got.Status != job.StatusCompleted || got.Output != "up 3 days" || got.StartedAt == nil ||
+			!got.StartedAt.Equal(*started.StartedAt) || got.FinishedAt == nil || got.FinishedAt.Before(*got.StartedAt) {
+			t.Errorf("after completed: %+v", got)
+		}
+	})
+
+	t.Run("truncated output keeps size and blob key", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "journalctl")
+		out := job.Output{Text: "head", Size: 1 << 20, Truncated: true, BlobKey: strings.Repeat("ab", 32)}
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusCompleted, out, ""); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := repo.FindByID(ctx, j.ID)
+		if got.Output != out.Text || got.OutputSize != out.Size || !got.OutputTruncated || got.OutputBlob != out.BlobKey {
+			t.Errorf("after truncated result: %+v", got)
+		}
+
+		bad := createJob(t, repo, n.ID, "journalctl")
+		out.BlobKey = "../../etc/passwd"
+		if err := repo.UpdateStatus(ctx, bad.ID, n.ID, job.StatusCompleted, out, ""); err == nil {
+			t.Error("jobs_output_blob_key CHECK accepted a malformed key")
+		}
+	})
+
+	t.Run("failed straight from pending sets both timestamps", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "reboot")
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusFailed, job.Output{Text: "partial"}, "exit status 1"); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := repo.FindByID(ctx, j.ID)
+		if got.Status != job.StatusFailed || got.Output != "partial" || got.Error != "exit status 1" ||
+			got.StartedAt == nil || got.FinishedAt == nil {
+			t.Errorf("after failed: %+v", got)
+		}
+	})
+
+	t.Run("out of order updates are rejected", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusCompleted, job.Output{Text: "done"}, ""); err != nil {
+			t.Fatal(err)
+		}
+		for _, st := range []job.JobStatus{job.StatusRunning, job.StatusFailed, job.StatusCompleted, job.StatusPending} {
+			if err := repo.UpdateStatus(ctx, j.ID, n.ID, st, job.Output{Text: "late"}, ""); !errors.Is(err, job.ErrInvalidTransition) {
+				t.Errorf("%s after completed: error = %v, want ErrInvalidTransition", st, err)
+			}
+		}
+		got, _ := repo.FindByID(ctx, j.ID)
+		if got.Status != job.StatusCompleted || got.Output != "done" {
+			t.Errorf("job changed by rejected updates: %+v", got)
+		}
+	})
+
+	t.Run("pending is not reachable", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if err := repo.UpdateStatus(ctx, j.ID, n.ID, job.StatusPending, job.Output{}, ""); !errors.Is(err, job.ErrInvalidTransition) {
+			t.Errorf("error = %v, want ErrInvalidTransition", err)
+		}
+	})
+
+	t.Run("another node's job", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if err := repo.UpdateStatus(ctx, j.ID, other.ID, job.StatusCompleted, job.Output{}, ""); !errors.Is(err, job.ErrJobNotFound) {
+			t.Errorf("error = %v, want ErrJobNotFound", err)
+		}
+		if got, _ := repo.FindByID(ctx, j.ID); got.Status != job.StatusPending {
+			t.Errorf("job changed by another node: %+v", got)
+		}
+	})
+
+	t.Run("unknown job", func(t *testing.T) {
+		if err := repo.UpdateStatus(ctx, unknownID, n.ID, job.StatusRunning, job.Output{}, ""); !errors.Is(err, job.ErrJobNotFound) {
+			t.Errorf("error = %v, want ErrJobNotFound", err)
+		}
+	})
+
+	t.Run("timestamps must match the status", func(t *testing.T) {
+		j := createJob(t, repo, n.ID, "uptime")
+		if _, err := db.ExecContext(ctx, `UPDATE jobs SET status = 'completed' WHERE id = $1`, j.ID); err == nil {
+			t.Error("the database accepted a completed job without finished_at")
+		}
+	})
+}
+
+func TestJobRepositoryList(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewJobRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+	other := createNode(t, db, orgID, "web-02")
+
+	var jobs []*job.Job
+	for _, cmd := range []string{"a", "b", "c", "d"} {
+		jobs = append(jobs, createJob(t, repo, n.ID, cmd))
+		sleepTick()
+	}
+	createJob(t, repo, other.ID, "e")
+	if err := repo.UpdateStatus(ctx, jobs[1].ID, n.ID, job.StatusFailed, job.Output{}, "boom"); err != nil {
+		t.Fatal(err)
+	}
+
+	newest := platform.Sort{Field: job.SortCreatedAt, Desc: true}
+	list := func(f job.ListFilter) []string {
+		t.Helper()
+		if f.Limit == 0 {
+			f.Limit = 10
+		}
+		out, err := repo.List(ctx, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]string, len(out))
+		for i, j := range out {
+			names[i] = j.CommandName
+		}
+		return names
+	}
+
+	if got := list(job.ListFilter{NodeID: n.ID, Sort: newest}); !slices.Equal(got, []string{"d", "c", "b", "a"}) {
+		t.Errorf("newest first = %v", got)
+	}
+	if got := list(job.ListFilter{NodeID: n.ID, Sort: newest, Statuses: []job.JobStatus{job.StatusFailed}}); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("failed only = %v", got)
+	}
+	since, until := jobs[1].CreatedAt, jobs[3].CreatedAt
+	if got := list(job.ListFilter{NodeID: n.ID, Sort: newest, Since: &since, Until: &until}); !slices.Equal(got, []string{"c", "b"}) {
+		t.Errorf("date range = %v", got)
+	}
+
+	after := &platform.Cursor{Value: jobs[2].CreatedAt.UTC().Format(time.RFC3339Nano), ID: jobs[2].ID}
+	if got := list(job.ListFilter{NodeID: n.ID, Sort: newest, After: after, Limit: 1}); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("page after c = %v", got)
+	}
+
+	byName := platform.Sort{Field: job.SortCommandName}
+	if got := list(job.ListFilter{Sort: byName}); !slices.Equal(got, []string{"a", "b", "c", "d", "e"}) {
+		t.Errorf("all nodes by name = %v", got)
+	}
+
+	if _, err := repo.List(ctx, job.ListFilter{Sort: platform.Sort{Field: "status"}, Limit: 10}); !errors.Is(err, job.ErrInvalidFilter) {
+		t.Errorf("List with unknown sort error = %v, want ErrInvalidFilter", err)
+	}
+}
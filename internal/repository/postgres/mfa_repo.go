@@ -0,0 +1,200 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+	"github.com/jmoiron/sqlx"
+)
+
+// MFARepository implementa mfa.Repository usando PostgreSQL
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+// NewMFARepository crea una nueva instancia del repositorio
+func NewMFARepository(db *sqlx.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// FindEnrollment devuelve el estado TOTP de un usuario
+func (r *MFARepository) FindEnrollment(ctx context.Context, userID string) (*mfa.Enrollment, error) {
+	var en mfa.Enrollment
+	err := r.db.GetContext(ctx, &en, `
+		SELECT id, totp_secret_encrypted, totp_enabled_at, totp_last_step
+		FROM users WHERE id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, auth.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &en, nil
+}
+
+// SetPendingSecret guarda el secreto de un alta no confirmada
+func (r *MFARepository) SetPendingSecret(ctx context.Context, userID string, secretEncrypted []byte) error {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET totp_secret_encrypted = $1, totp_last_step = NULL, updated_at = now()
+		WHERE id = $2 AND totp_enabled_at IS NULL`, secretEncrypted, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+	return nil
+}
+
+// Enable activa MFA y guarda los códigos de recuperación en una transacción
+func (r *MFARepository) Enable(ctx context.Context, userID string, step int64, recoveryCodeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		UPDATE users SET totp_enabled_at = now(), totp_last_step = $1, updated_at = now()
+		WHERE id = $2 AND totp_enabled_at IS NULL AND totp_secret_encrypted IS NOT NULL`, step, userID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return mfa.ErrMFAAlreadyEnabled
+	}
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, recoveryCodeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Disable borra el secreto y los códigos de recuperación
+func (r *MFARepository) Disable(ctx context.Context, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE users
+		SET totp_secret_encrypted = NULL, totp_enabled_at = NULL, totp_last_step = NULL, updated_at = now()
+		WHERE id = $1`, userID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// MarkStepUsed avanza totp_last_step solo si step es posterior
+func (r *MFARepository) MarkStepUsed(ctx context.Context, userID string, step int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE users SET totp_last_step = $1
+		WHERE id = $2 AND (totp_last_step IS NULL OR totp_last_step < $1)`, step, userID)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// UseRecoveryCode consume un código de recuperación sin usar
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userID, codeHash string, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_recovery_codes SET used_at = $1
+		WHERE user_id = $2 AND code_hash = $3 AND used_at IS NULL`, at, userID, codeHash)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// ReplaceRecoveryCodes reemplaza todos los códigos de recuperación del usuario
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userID string, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(ctx, tx, userID, codeHashes); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CountRecoveryCodes cuenta los códigos de recuperación sin usar
+func (r *MFARepository) CountRecoveryCodes(ctx context.Context, userID string) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `
+		SELECT count(*) FROM mfa_recovery_codes
+		WHERE user_id = $1 AND used_at IS NULL`, userID)
+	return n, err
+}
+
+// CreateChallenge guarda un desafío MFA
+func (r *MFARepository) CreateChallenge(ctx context.Context, c *mfa.Challenge) error {
+	return r.db.QueryRowxContext(ctx, `
+		INSERT INTO mfa_challenges (user_id, token_hash, expires_at)
+		VALUES ($1, $2, $3)
+		RETURNING id, created_at`,
+		c.UserID, c.TokenHash, c.ExpiresAt,
+	).Scan(&c.ID, &c.CreatedAt)
+}
+
+// FindChallengeByHash busca un desafío por el hash de su token
+func (r *MFARepository) FindChallengeByHash(ctx context.Context, tokenHash string) (*mfa.Challenge, error) {
+	var c mfa.Challenge
+	err := r.db.GetContext(ctx, &c, `
+		SELECT id, user_id, token_hash, attempts, expires_at, used_at, created_at
+		FROM mfa_challenges WHERE token_hash = $1`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, mfa.ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// IncrementChallengeAttempts suma un intento fallido al desafío
+func (r *MFARepository) IncrementChallengeAttempts(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE mfa_challenges SET attempts = attempts + 1 WHERE id = $1`, id)
+	return err
+}
+
+// ConsumeChallenge marca el desafío como usado
+func (r *MFARepository) ConsumeChallenge(ctx context.Context, id string, at time.Time) (bool, error) {
+	res, err := r.db.ExecContext(ctx, `
+		UPDATE mfa_challenges SET used_at = $1
+		WHERE id = $2 AND used_at IS NULL`, at, id)
+	if err != nil {
+		return false, err
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+func replaceRecoveryCodes(ctx context.Context, tx *sqlx.Tx, userID string, codeHashes []string) error {
+	if _, err := tx.ExecContext(ctx,
+		`DELETE FROM mfa_recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO mfa_recovery_codes (user_id, code_hash) VALUES ($1, $2)`, userID, h); err != nil {
+			return err
+		}
+	}
+	return nil
+}
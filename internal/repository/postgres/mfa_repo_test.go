@@ -0,0 +1,130 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/auth"
+	"github.com/arturo/autohost-cloud-api/internal/domain/mfa"
+)
+
+func TestMFARepositoryEnrollment(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewMFARepository(db)
+	userID := createUser(t, db, "ana@example.com")
+
+	en, err := repo.FindEnrollment(ctx, userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if en.UserID != userID || en.SecretEncrypted != nil || en.EnabledAt != nil || en.LastStep != nil {
+		t.Fatalf("initial enrollment = %+v", en)
+	}
+	if _, err := repo.FindEnrollment(ctx, unknownID); !errors.Is(err, auth.ErrUserNotFound) {
+		t.Errorf("FindEnrollment(unknown) error = %v, want ErrUserNotFound", err)
+	}
+
+	// Sin secreto pendiente no se puede activar
+	if err := repo.Enable(ctx, userID, 100, nil); !errors.Is(err, mfa.ErrMFAAlreadyEnabled) {
+		t.Errorf("Enable without secret error = %v, want ErrMFAAlreadyEnabled", err)
+	}
+
+	if err := repo.SetPendingSecret(ctx, userID, []byte("sealed")); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Enable(ctx, userID, 100, []string{"r1", "r2", "r3"}); err != nil {
+		t.Fatal(err)
+	}
+	en, _ = repo.FindEnrollment(ctx, userID)
+	if string(en.SecretEncrypted) != "sealed" || en.EnabledAt == nil || en.LastStep == nil || *en.LastStep != 100 {
+		t.Fatalf("enabled enrollment = %+v", en)
+	}
+	if err := repo.SetPendingSecret(ctx, userID, []byte("other")); !errors.Is(err, mfa.ErrMFAAlreadyEnabled) {
+		t.Errorf("SetPendingSecret while enabled error = %v, want ErrMFAAlreadyEnabled", err)
+	}
+
+	// Cada paso TOTP se acepta una sola vez y nunca uno anterior
+	for _, tc := range []struct {
+		step int64
+		want bool
+	}{{100, false}, {99, false}, {101, true}, {101, false}} {
+		if ok, err := repo.MarkStepUsed(ctx, userID, tc.step); err != nil || ok != tc.want {
+			t.Errorf("MarkStepUsed(%d) = %v, %v; want %v", tc.step, ok, err, tc.want)
+		}
+	}
+
+	if ok, err := repo.UseRecoveryCode(ctx, userID, "r2", time.Now()); err != nil || !ok {
+		t.Errorf("UseRecoveryCode(r2) = %v, %v", ok, err)
+	}
+	if ok, _ := repo.UseRecoveryCode(ctx, userID, "r2", time.Now()); ok {
+		t.Error("recovery code accepted twice")
+	}
+	if ok, _ := repo.UseRecoveryCode(ctx, userID, "nope", time.Now()); ok {
+		t.Error("unknown recovery code accepted")
+	}
+	if n, err := repo.CountRecoveryCodes(ctx, userID); err != nil || n != 2 {
+		t.Errorf("CountRecoveryCodes = %d, %v; want 2", n, err)
+	}
+
+	if err := repo.ReplaceRecoveryCodes(ctx, userID, []string{"n1", "n2", "n3", "n4"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.CountRecoveryCodes(ctx, userID); n != 4 {
+		t.Errorf("codes after replacing = %d, want 4", n)
+	}
+	if ok, _ := repo.UseRecoveryCode(ctx, userID, "r1", time.Now()); ok {
+		t.Error("replaced recovery code still accepted")
+	}
+
+	if err := repo.Disable(ctx, userID); err != nil {
+		t.Fatal(err)
+	}
+	en, _ = repo.FindEnrollment(ctx, userID)
+	if en.SecretEncrypted != nil || en.EnabledAt != nil || en.LastStep != nil {
+		t.Errorf("enrollment after disabling = %+v", en)
+	}
+	if n, _ := repo.CountRecoveryCodes(ctx, userID); n != 0 {
+		t.Errorf("codes after disabling = %d", n)
+	}
+}
+
+func TestMFARepositoryChallenges(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewMFARepository(db)
+	userID := createUser(t, db, "ana@example.com")
+
+	c := &mfa.Challenge{UserID: userID, TokenHash: "challenge-1", ExpiresAt: time.Now().Add(5 * time.Minute)}
+	if err := repo.CreateChallenge(ctx, c); err != nil {
+		t.Fatal(err)
+	}
+	if c.ID == "" || c.CreatedAt.IsZero() {
+		t.Fatalf("CreateChallenge = %+v", c)
+	}
+
+	for range 2 {
+		if err := repo.IncrementChallengeAttempts(ctx, c.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := repo.FindChallengeByHash(ctx, "challenge-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.ID != c.ID || got.UserID != userID || got.Attempts != 2 || got.UsedAt != nil {
+		t.Errorf("FindChallengeByHash = %+v", got)
+	}
+	if _, err := repo.FindChallengeByHash(ctx, "missing"); !errors.Is(err, mfa.ErrInvalidChallenge) {
+		t.Errorf("FindChallengeByHash(unknown) error = %v, want ErrInvalidChallenge", err)
+	}
+
+	if ok, err := repo.ConsumeChallenge(ctx, c.ID, time.Now()); err != nil || !ok {
+		t.Errorf("ConsumeChallenge = %v, %v", ok, err)
+	}
+	if ok, _ := repo.ConsumeChallenge(ctx, c.ID, time.Now()); ok {
+		t.Error("challenge consumed twice")
+	}
+}
@@ -0,0 +1,14 @@
+package postgres
+
+import (
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
+)
+
+// NewMigrator prepara golang-migrate con las migraciones de dir contra
+// databaseURL. Lo usan cmd/migrate y los tests de integración, así que los
+// tests aplican el esquema exactamente igual que en producción.
+func NewMigrator(dir, databaseURL string) (*migrate.Migrate, error) {
+	return migrate.New("file://"+dir, databaseURL)
+}
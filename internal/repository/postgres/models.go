@@ -3,6 +3,8 @@ package postgres
 import (
 	"database/sql"
 	"time"
+
+	"github.com/lib/pq"
 )
 
 // NodeCommandModel represents the node_commands table row.
@@ -18,48 +20,111 @@ type NodeCommandModel struct {
 
 // JobModel represents the jobs table row.
 type JobModel struct {
-	ID          string         `db:"id"`
-	NodeID      string         `db:"node_id"`
-	CommandName string         `db:"command_name"`
-	CommandType string         `db:"command_type"`
-	Status      string         `db:"status"`
-	Output      sql.NullString `db:"output"`
-	Error       sql.NullString `db:"error"`
-	CreatedAt   time.Time      `db:"created_at"`
-	StartedAt   *time.Time     `db:"started_at"`
-	FinishedAt  *time.Time     `db:"finished_at"`
+	ID              string         `db:"id"`
+	NodeID          string         `db:"node_id"`
+	CommandName     string         `db:"command_name"`
+	CommandType     string         `db:"command_type"`
+	Status          string         `db:"status"`
+	Output          sql.NullString `db:"output"`
+	Error           sql.NullString `db:"error"`
+	CreatedAt       time.Time      `db:"created_at"`
+	StartedAt       *time.Time     `db:"started_at"`
+	FinishedAt      *time.Time     `db:"finished_at"`
+	OutputSize      int64          `db:"output_size"`
+	OutputTruncated bool           `db:"output_truncated"`
+	OutputBlob      sql.NullString `db:"output_blob"`
 }
 
 // UserModel representa la estructura de la tabla users
 type UserModel struct {
-	ID           string    `db:"id"`
-	Email        string    `db:"email"`
-	Name         *string   `db:"name"`
-	PasswordHash string    `db:"password_hash"`
-	CreatedAt    time.Time `db:"created_at"`
-	UpdatedAt    time.Time `db:"updated_at"`
+	ID              string         `db:"id"`
+	Email           string         `db:"email"`
+	Name            *string        `db:"name"`
+	PasswordHash    sql.NullString `db:"password_hash"` // NULL en usuarios creados por SSO
+	EmailVerifiedAt *time.Time     `db:"email_verified_at"`
+	CreatedAt       time.Time      `db:"created_at"`
+	UpdatedAt       time.Time      `db:"updated_at"`
+}
+
+// RefreshTokenModel representa la estructura de la tabla refresh_tokens
+type RefreshTokenModel struct {
+	ID            string         `db:"id"`
+	UserID        string         `db:"user_id"`
+	FamilyID      string         `db:"family_id"`
+	TokenHash     string         `db:"token_hash"`
+	UserAgent     sql.NullString `db:"user_agent"`
+	IP            sql.NullString `db:"ip"`
+	CreatedAt     time.Time      `db:"created_at"`
+	ExpiresAt     time.Time      `db:"expires_at"`
+	RevokedAt     *time.Time     `db:"revoked_at"`
+	RevokedReason sql.NullString `db:"revoked_reason"`
+}
+
+// SessionModel es una familia de refresh tokens activa
+type SessionModel struct {
+	FamilyID   string         `db:"family_id"`
+	UserAgent  sql.NullString `db:"user_agent"`
+	IP         sql.NullString `db:"ip"`
+	CreatedAt  time.Time      `db:"created_at"`
+	LastUsedAt time.Time      `db:"last_used_at"`
+	ExpiresAt  time.Time      `db:"expires_at"`
 }
 
 // NodeModel representa la estructura de la tabla nodes
 type NodeModel struct {
-	ID           string     `db:"id"`
-	Hostname     string     `db:"hostname"`
-	IPLocal      string     `db:"ip_local"`
-	OS           string     `db:"os"`
-	Arch         string     `db:"arch"`
-	VersionAgent string     `db:"version_agent"`
-	OwnerID      *string    `db:"owner_id"`
-	LastSeenAt   *time.Time `db:"last_seen_at"`
-	CreatedAt    time.Time  `db:"created_at"`
-	UpdatedAt    time.Time  `db:"updated_at"`
+	ID             string     `db:"id"`
+	Hostname       string     `db:"hostname"`
+	IPLocal        string     `db:"ip_local"`
+	OS             string     `db:"os"`
+	Arch           string     `db:"arch"`
+	VersionAgent   string     `db:"version_agent"`
+	OrganizationID string     `db:"organization_id"`
+	OwnerID        *string    `db:"owner_id"`
+	LastSeenAt     *time.Time `db:"last_seen_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+	UpdatedAt      time.Time  `db:"updated_at"`
 }
 
 // EnrollTokenModel representa la estructura de la tabla enroll_tokens
 type EnrollTokenModel struct {
-	ID        string     `db:"id"`
-	Token     string     `db:"token"`
-	UserID    string     `db:"user_id"`
-	ExpiresAt time.Time  `db:"expires_at"`
-	UsedAt    *time.Time `db:"used_at"`
-	CreatedAt time.Time  `db:"created_at"`
+	ID             string     `db:"id"`
+	Token          string     `db:"token"`
+	UserID         string     `db:"user_id"`
+	OrganizationID string     `db:"organization_id"`
+	ExpiresAt      time.Time  `db:"expires_at"`
+	UsedAt         *time.Time `db:"used_at"`
+	CreatedAt      time.Time  `db:"created_at"`
+}
+
+// AuditEventModel represents the audit_events table row.
+type AuditEventModel struct {
+	ID             int64     `db:"id"`
+	OccurredAt     time.Time `db:"occurred_at"`
+	OrganizationID *string   `db:"organization_id"`
+	ActorType      string    `db:"actor_type"`
+	ActorID        string    `db:"actor_id"`
+	Action         string    `db:"action"`
+	TargetType     string    `db:"target_type"`
+	TargetID       string    `db:"target_id"`
+	Outcome        string    `db:"outcome"`
+	IP             string    `db:"ip"`
+	RequestID      string    `db:"request_id"`
+	Metadata       []byte    `db:"metadata"`
+	PrevHash       string    `db:"prev_hash"`
+	Hash           string    `db:"hash"`
+}
+
+// APIKeyModel representa la tabla api_keys (user_email viene del join con users)
+type APIKeyModel struct {
+	ID         string         `db:"id"`
+	UserID     string         `db:"user_id"`
+	UserEmail  string         `db:"user_email"`
+	Name       string         `db:"name"`
+	Prefix     string         `db:"prefix"`
+	KeyHash    string         `db:"key_hash"`
+	Scopes     pq.StringArray `db:"scopes"`
+	ExpiresAt  *time.Time     `db:"expires_at"`
+	LastUsedAt *time.Time     `db:"last_used_at"`
+	RevokedAt  *time.Time     `db:"revoked_at"`
+	CreatedAt  time.Time      `db:"created_at"`
 }
@@ -18,9 +18,9 @@ func NewNodeCommandRepository(db *sqlx.DB) *NodeCommandRepository {
 }
 
 // Upsert inserts or updates a node command (matched on node_id + name).
-func (r *NodeCommandRepository) Upsert(cmd *nodecommand.NodeCommand) (*nodecommand.NodeCommand, error) {
+func (r *NodeCommandRepository) Upsert(ctx context.Context, cmd *nodecommand.NodeCommand) (*nodecommand.NodeCommand, error) {
 	var m NodeCommandModel
-	err := r.db.QueryRowContext(context.Background(), `
+	err := r.db.QueryRowContext(ctx, `
 		INSERT INTO node_commands (node_id, name, description, type, script_path)
 		VALUES ($1, $2, $3, $4, $5)
 		ON CONFLICT (node_id, name) DO UPDATE
@@ -37,9 +37,9 @@ func (r *NodeCommandRepository) Upsert(cmd *nodecommand.NodeCommand) (*nodecomma
 }
 
 // FindByNodeID returns all commands registered for a node.
-func (r *NodeCommandRepository) FindByNodeID(nodeID string) ([]*nodecommand.NodeCommand, error) {
+func (r *NodeCommandRepository) FindByNodeID(ctx context.Context, nodeID string) ([]*nodecommand.NodeCommand, error) {
 	var models []NodeCommandModel
-	err := r.db.SelectContext(context.Background(), &models,
+	err := r.db.SelectContext(ctx, &models,
 		`SELECT id, node_id, name, description, type, script_path, created_at
 		 FROM node_commands WHERE node_id = $1 ORDER BY name`, nodeID)
 	if err != nil {
@@ -53,9 +53,9 @@ func (r *NodeCommandRepository) FindByNodeID(nodeID string) ([]*nodecommand.Node
 }
 
 // FindByID returns a single command by its UUID.
-func (r *NodeCommandRepository) FindByID(id string) (*nodecommand.NodeCommand, error) {
+func (r *NodeCommandRepository) FindByID(ctx context.Context, id string) (*nodecommand.NodeCommand, error) {
 	var m NodeCommandModel
-	err := r.db.GetContext(context.Background(), &m,
+	err := r.db.GetContext(ctx, &m,
 		`SELECT id, node_id, name, description, type, script_path, created_at
 		 FROM node_commands WHERE id = $1`, id)
 	if err == sql.ErrNoRows {
@@ -67,10 +67,10 @@ func (r *NodeCommandRepository) FindByID(id string) (*nodecommand.NodeCommand, e
 	return modelToNodeCommand(m), nil
 }
 
-// Delete removes a command by its UUID.
-func (r *NodeCommandRepository) Delete(id string) error {
-	res, err := r.db.ExecContext(context.Background(),
-		`DELETE FROM node_commands WHERE id = $1`, id)
+// Delete removes a command by its UUID if it belongs to nodeID.
+func (r *NodeCommandRepository) Delete(ctx context.Context, id, nodeID string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM node_commands WHERE id = $1 AND node_id = $2`, id, nodeID)
 	if err != nil {
 		return err
 	}
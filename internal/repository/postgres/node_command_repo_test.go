@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	nodecommand "github.com/arturo/autohost-cloud-api/internal/domain/node_command"
+)
+
+func TestNodeCommandRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeCommandRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+
+	backup, err := repo.Upsert(ctx, &nodecommand.NodeCommand{
+		NodeID:      n.ID,
+		Name:        "backup",
+		Description: "Dump the database",
+		Type:        nodecommand.CommandTypeCustom,
+		ScriptPath:  "/opt/scripts/backup.sh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Upsert(ctx, &nodecommand.NodeCommand{NodeID: n.ID, Name: "uptime", Type: nodecommand.CommandTypeDefault}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Re-registrar actualiza la fila existente y conserva la descripción si
+	// la nueva viene vacía
+	again, err := repo.Upsert(ctx, &nodecommand.NodeCommand{
+		NodeID:     n.ID,
+		Name:       "backup",
+		Type:       nodecommand.CommandTypeCustom,
+		ScriptPath: "/srv/backup.sh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != backup.ID || again.Description != "Dump the database" || again.ScriptPath != "/srv/backup.sh" {
+		t.Errorf("re-registered command = %+v", again)
+	}
+
+	cmds, err := repo.FindByNodeID(ctx, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || cmds[0].Name != "backup" || cmds[1].Name != "uptime" || cmds[1].ScriptPath != "" {
+		t.Errorf("FindByNodeID = %+v", cmds)
+	}
+
+	if got, err := repo.FindByID(ctx, backup.ID); err != nil || got.Type != nodecommand.CommandTypeCustom {
+		t.Errorf("FindByID = %+v, %v", got, err)
+	}
+	other := createNode(t, db, orgID, "web-02")
+	if err := repo.Delete(ctx, backup.ID, other.ID); !errors.Is(err, nodecommand.ErrCommandNotFound) {
+		t.Errorf("Delete by another node error = %v, want ErrCommandNotFound", err)
+	}
+	if err := repo.Delete(ctx, backup.ID, n.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.FindByID(ctx, backup.ID); !errors.Is(err, nodecommand.ErrCommandNotFound) {
+		t.Errorf("FindByID(deleted) error = %v, want ErrCommandNotFound", err)
+	}
+	if err := repo.Delete(ctx, backup.ID, n.ID); !errors.Is(err, nodecommand.ErrCommandNotFound) {
+		t.Errorf("second Delete error = %v, want ErrCommandNotFound", err)
+	}
+}
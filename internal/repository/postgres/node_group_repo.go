@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/jmoiron/sqlx"
+)
+
+// NodeGroupRepository implements nodegroup.Repository using PostgreSQL.
+type NodeGroupRepository struct {
+	db *sqlx.DB
+}
+
+func NewNodeGroupRepository(db *sqlx.DB) *NodeGroupRepository {
+	return &NodeGroupRepository{db: db}
+}
+
+const nodeGroupColumns = `id, organization_id, name, description, created_at`
+
+// Create inserts a group; the (organization_id, name) conflict is reported
+// as ErrGroupExists.
+func (r *NodeGroupRepository) Create(ctx context.Context, g *nodegroup.Group) (*nodegroup.Group, error) {
+	var out nodegroup.Group
+	err := r.db.GetContext(ctx, &out, `
+		INSERT INTO node_groups (organization_id, name, description)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, name) DO NOTHING
+		RETURNING `+nodeGroupColumns,
+		g.OrganizationID, g.Name, g.Description,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nodegroup.ErrGroupExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *NodeGroupRepository) Find(ctx context.Context, orgID, id string) (*nodegroup.Group, error) {
+	var out nodegroup.Group
+	err := r.db.GetContext(ctx, &out,
+		`SELECT `+nodeGroupColumns+` FROM node_groups WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err == sql.ErrNoRows {
+		return nil, nodegroup.ErrGroupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *NodeGroupRepository) List(ctx context.Context, orgID string) ([]*nodegroup.Group, error) {
+	var out []*nodegroup.Group
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT `+nodeGroupColumns+` FROM node_groups WHERE organization_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *NodeGroupRepository) Delete(ctx context.Context, orgID, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM node_groups WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nodegroup.ErrGroupNotFound
+	}
+	return nil
+}
+
+func (r *NodeGroupRepository) AddNode(ctx context.Context, groupID, nodeID string) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO node_group_members (group_id, node_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING`, groupID, nodeID)
+	return err
+}
+
+func (r *NodeGroupRepository) RemoveNode(ctx context.Context, groupID, nodeID string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM node_group_members WHERE group_id = $1 AND node_id = $2`, groupID, nodeID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nodegroup.ErrMemberNotFound
+	}
+	return nil
+}
+
+func (r *NodeGroupRepository) NodeIDs(ctx context.Context, groupID string) ([]string, error) {
+	var out []string
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT node_id FROM node_group_members WHERE group_id = $1 ORDER BY node_id`, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,72 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+)
+
+func TestNodeGroupRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeGroupRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	_, otherOrg := createOrg(t, db, "other@example.com")
+	web1 := createNode(t, db, orgID, "web-01")
+	web2 := createNode(t, db, orgID, "web-02")
+
+	web, err := repo.Create(ctx, &nodegroup.Group{OrganizationID: orgID, Name: "web", Description: "frontends"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if web.ID == "" || web.CreatedAt.IsZero() {
+		t.Errorf("Create = %+v", web)
+	}
+	if _, err := repo.Create(ctx, &nodegroup.Group{OrganizationID: orgID, Name: "web"}); !errors.Is(err, nodegroup.ErrGroupExists) {
+		t.Errorf("duplicate name error = %v, want ErrGroupExists", err)
+	}
+	if _, err := repo.Create(ctx, &nodegroup.Group{OrganizationID: otherOrg, Name: "web"}); err != nil {
+		t.Errorf("same name in another organization: %v", err)
+	}
+	if _, err := repo.Create(ctx, &nodegroup.Group{OrganizationID: orgID, Name: "db"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := repo.Find(ctx, otherOrg, web.ID); !errors.Is(err, nodegroup.ErrGroupNotFound) {
+		t.Errorf("Find in another organization = %v, want ErrGroupNotFound", err)
+	}
+	list, err := repo.List(ctx, orgID)
+	if err != nil || len(list) != 2 || list[0].Name != "db" || list[1].Name != "web" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+
+	for _, id := range []string{web2.ID, web1.ID, web1.ID} {
+		if err := repo.AddNode(ctx, web.ID, id); err != nil {
+			t.Fatalf("AddNode: %v", err)
+		}
+	}
+	want := []string{web1.ID, web2.ID}
+	slices.Sort(want)
+	if ids, err := repo.NodeIDs(ctx, web.ID); err != nil || !slices.Equal(ids, want) {
+		t.Errorf("NodeIDs = %v, %v, want %v", ids, err, want)
+	}
+	if err := repo.RemoveNode(ctx, web.ID, web1.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.RemoveNode(ctx, web.ID, web1.ID); !errors.Is(err, nodegroup.ErrMemberNotFound) {
+		t.Errorf("RemoveNode again = %v, want ErrMemberNotFound", err)
+	}
+
+	if err := repo.Delete(ctx, otherOrg, web.ID); !errors.Is(err, nodegroup.ErrGroupNotFound) {
+		t.Errorf("Delete from another organization = %v, want ErrGroupNotFound", err)
+	}
+	if err := repo.Delete(ctx, orgID, web.ID); err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := repo.NodeIDs(ctx, web.ID); err != nil || len(ids) != 0 {
+		t.Errorf("members after delete = %v, %v", ids, err)
+	}
+}
@@ -1,6 +1,7 @@
 package postgres
 
 import (
+	"context"
 	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
 	"github.com/jmoiron/sqlx"
 )
@@ -13,9 +14,9 @@ func NewNodeMetricRepository(db *sqlx.DB) *NodeMetricRepo {
 	return &NodeMetricRepo{DB: db}
 }
 
-func (r *NodeMetricRepo) StoreNodeMetric(req *nodemetric.CreateNodeMetricRequest) (*nodemetric.NodeMetric, error) {
+func (r *NodeMetricRepo) StoreNodeMetric(ctx context.Context, req *nodemetric.CreateNodeMetricRequest) (*nodemetric.NodeMetric, error) {
 	var metric nodemetric.NodeMetric
-	err := r.DB.QueryRowx(`
+	err := r.DB.QueryRowxContext(ctx, `
 		INSERT INTO node_metrics (
 			node_id, cpu_usage_percent, memory_total_bytes, memory_used_bytes,
 			memory_available_bytes, memory_usage_percent, disk_total_bytes, 
@@ -37,7 +38,7 @@ func (r *NodeMetricRepo) StoreNodeMetric(req *nodemetric.CreateNodeMetricRequest
 
 // func (r *NodeMetricRepo) GetLatestMetricByNodeID(nodeID string) (*nodemetric.NodeMetric, error) {
 // 	var model nodemetric.NodeMetric
-// 	err := r.DB.Get(&model, `
+// 	err := r.DB.GetContext(ctx, &model, `
 // 		SELECT id, node_id, cpu_usage_percent, memory_total_bytes, memory_used_bytes,
 // 		       memory_usage_percent, disk_total_bytes, disk_used_bytes,
 // 		       disk_available_bytes, disk_usage_percent, collected_at, created_at
@@ -54,7 +55,7 @@ func (r *NodeMetricRepo) StoreNodeMetric(req *nodemetric.CreateNodeMetricRequest
 
 // func (r *NodeMetricRepo) GetMetricsByNodeID(nodeID string, limit, offset int) ([]*nodemetric.NodeMetric, error) {
 // 	var models []*nodemetric.NodeMetric
-// 	err := r.DB.Select(&models, `
+// 	err := r.DB.SelectContext(ctx, &models, `
 // 		SELECT id, node_id, cpu_usage_percent, memory_total_bytes, memory_used_bytes,
 // 		       memory_usage_percent, disk_total_bytes, disk_used_bytes,
 // 		       disk_available_bytes, disk_usage_percent, collected_at, created_at
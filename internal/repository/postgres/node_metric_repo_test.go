@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
+)
+
+func TestNodeMetricRepo(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeMetricRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+	collected := time.Now().UTC().Truncate(time.Microsecond)
+
+	req := &nodemetric.CreateNodeMetricRequest{
+		NodeID:               n.ID,
+		CPUUsagePercent:      12.5,
+		MemoryTotalBytes:     8 << 30,
+		MemoryUsedBytes:      2 << 30,
+		MemoryAvailableBytes: 6 << 30,
+		MemoryUsagePercent:   25,
+		DiskTotalBytes:       100 << 30,
+		DiskUsedBytes:        40 << 30,
+		DiskAvailableBytes:   60 << 30,
+		DiskUsagePercent:     40,
+		CollectedAt:          collected,
+	}
+	m, err := repo.StoreNodeMetric(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.ID == "" || m.NodeID != n.ID || m.CPUUsagePercent != 12.5 || m.MemoryTotalBytes != 8<<30 ||
+		m.DiskAvailableBytes != 60<<30 || m.DiskUsagePercent != 40 || !m.CollectedAt.Equal(collected) {
+		t.Errorf("StoreNodeMetric = %+v", m)
+	}
+
+	req.NodeID = unknownID
+	if _, err := repo.StoreNodeMetric(ctx, req); err == nil {
+		t.Error("StoreNodeMetric accepted an unknown node")
+	}
+}
@@ -3,6 +3,8 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"strings"
 
 	"github.com/arturo/autohost-cloud-api/internal/domain/node"
 	"github.com/jmoiron/sqlx"
@@ -19,19 +21,20 @@ func NewNodeRepository(db *sqlx.DB) *NodeRepository {
 }
 
 // Register crea un nuevo nodo
-func (r *NodeRepository) Register(n *node.Node) (*node.Node, error) {
+func (r *NodeRepository) Register(ctx context.Context, n *node.Node) (*node.Node, error) {
 	var model NodeModel
-	err := r.db.QueryRowContext(context.Background(), `
-		INSERT INTO nodes (hostname, ip_local, os, arch, version_agent, owner_id)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		RETURNING id, hostname, ip_local, os, arch, version_agent, owner_id, last_seen_at, created_at, updated_at`,
-		n.Hostname, n.IPLocal, n.OS, n.Arch, n.VersionAgent, n.OwnerID).Scan(
+	err := r.db.QueryRowContext(ctx, `
+		INSERT INTO nodes (hostname, ip_local, os, arch, version_agent, organization_id, owner_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, hostname, ip_local, os, arch, version_agent, organization_id, owner_id, last_seen_at, created_at, updated_at`,
+		n.Hostname, n.IPLocal, n.OS, n.Arch, n.VersionAgent, n.OrganizationID, n.OwnerID).Scan(
 		&model.ID,
 		&model.Hostname,
 		&model.IPLocal,
 		&model.OS,
 		&model.Arch,
 		&model.VersionAgent,
+		&model.OrganizationID,
 		&model.OwnerID,
 		&model.LastSeenAt,
 		&model.CreatedAt,
@@ -50,10 +53,10 @@ func (r *NodeRepository) Register(n *node.Node) (*node.Node, error) {
 	return n, nil
 }
 
-func (r *NodeRepository) FindByID(id string) (*node.Node, error) {
+func (r *NodeRepository) FindByID(ctx context.Context, id string) (*node.Node, error) {
 	var model NodeModel
-	err := r.db.Get(&model, `
-		SELECT id, hostname, ip_local, os, arch, version_agent, owner_id, 
+	err := r.db.GetContext(ctx, &model, `
+		SELECT id, hostname, ip_local, os, arch, version_agent, organization_id, owner_id,
 		       last_seen_at, created_at, updated_at
 		FROM nodes 
 		WHERE id = $1`, id)
@@ -66,68 +69,107 @@ func (r *NodeRepository) FindByID(id string) (*node.Node, error) {
 	}
 
 	return &node.Node{
-		ID:           model.ID,
-		Hostname:     model.Hostname,
-		IPLocal:      model.IPLocal,
-		OS:           model.OS,
-		Arch:         model.Arch,
-		VersionAgent: model.VersionAgent,
-		OwnerID:      model.OwnerID,
-		LastSeenAt:   model.LastSeenAt,
-		CreatedAt:    model.CreatedAt,
-		UpdatedAt:    model.UpdatedAt,
+		ID:             model.ID,
+		Hostname:       model.Hostname,
+		IPLocal:        model.IPLocal,
+		OS:             model.OS,
+		Arch:           model.Arch,
+		VersionAgent:   model.VersionAgent,
+		OrganizationID: model.OrganizationID,
+		OwnerID:        model.OwnerID,
+		LastSeenAt:     model.LastSeenAt,
+		CreatedAt:      model.CreatedAt,
+		UpdatedAt:      model.UpdatedAt,
 	}, nil
 }
 
-// FindByOwnerID busca todos los nodos de un propietario
-func (r *NodeRepository) FindByOwnerID(ownerID string) ([]*node.Node, error) {
-	var models []NodeModel
-	err := r.db.Select(&models, `
-		SELECT id, hostname, ip_local, os, arch, version_agent, owner_id, 
+// nodeSortColumns traduce los campos de ordenación a columnas; last_seen_at
+// usa epoch para los nodos que nunca se han visto, así el keyset no trata NULL
+var nodeSortColumns = map[string]sortColumn{
+	node.SortCreatedAt:  {"created_at", "timestamptz"},
+	node.SortHostname:   {"hostname", "text"},
+	node.SortLastSeenAt: {"COALESCE(last_seen_at, 'epoch'::timestamptz)", "timestamptz"},
+}
+
+// List busca los nodos que cumplen f, paginando por keyset sobre (campo, id)
+func (r *NodeRepository) List(ctx context.Context, f node.ListFilter) ([]*node.Node, error) {
+	col, ok := nodeSortColumns[f.Sort.Field]
+	if !ok {
+		return nil, node.ErrInvalidFilter
+	}
+
+	var (
+		where []string
+		args  []interface{}
+	)
+	add := func(cond string, v interface{}) {
+		args = append(args, v)
+		where = append(where, fmt.Sprintf(cond, len(args)))
+	}
+
+	if f.OrganizationID != "" {
+		add("organization_id = $%d", f.OrganizationID)
+	}
+	if f.Hostname != "" {
+		add("hostname ILIKE $%d", "%"+escapeLike(f.Hostname)+"%")
+	}
+	if f.After != nil {
+		args = append(args, f.After.Value, f.After.ID)
+		where = append(where, keysetCondition(col, f.Sort.Desc, len(args)-1, len(args)))
+	}
+
+	query := `
+		SELECT id, hostname, ip_local, os, arch, version_agent, organization_id, owner_id,
 		       last_seen_at, created_at, updated_at
-		FROM nodes 
-		WHERE owner_id = $1
-		ORDER BY created_at DESC`, ownerID)
+		FROM nodes`
+	if len(where) > 0 {
+		query += ` WHERE ` + strings.Join(where, " AND ")
+	}
+	query += keysetOrder(col, f.Sort)
+	args = append(args, f.Limit)
+	query += fmt.Sprintf(` LIMIT $%d`, len(args))
 
-	if err != nil {
+	var models []NodeModel
+	if err := r.db.SelectContext(ctx, &models, query, args...); err != nil {
 		return nil, err
 	}
 
 	nodes := make([]*node.Node, len(models))
 	for i, model := range models {
 		nodes[i] = &node.Node{
-			ID:           model.ID,
-			Hostname:     model.Hostname,
-			IPLocal:      model.IPLocal,
-			OS:           model.OS,
-			Arch:         model.Arch,
-			VersionAgent: model.VersionAgent,
-			OwnerID:      model.OwnerID,
-			LastSeenAt:   model.LastSeenAt,
-			CreatedAt:    model.CreatedAt,
-			UpdatedAt:    model.UpdatedAt,
+			ID:             model.ID,
+			Hostname:       model.Hostname,
+			IPLocal:        model.IPLocal,
+			OS:             model.OS,
+			Arch:           model.Arch,
+			VersionAgent:   model.VersionAgent,
+			OrganizationID: model.OrganizationID,
+			OwnerID:        model.OwnerID,
+			LastSeenAt:     model.LastSeenAt,
+			CreatedAt:      model.CreatedAt,
+			UpdatedAt:      model.UpdatedAt,
 		}
 	}
 
 	return nodes, nil
 }
 
-func (r *NodeRepository) UpdateLastSeen(nodeID string) error {
-	_, err := r.db.ExecContext(context.Background(), `
+func (r *NodeRepository) UpdateLastSeen(ctx context.Context, nodeID string) error {
+	_, err := r.db.ExecContext(ctx, `
 		UPDATE nodes 
 		SET last_seen_at = now()
 		WHERE id = $1`, nodeID)
 	return err
 }
 
-// FindByOwnerIDWithMetrics busca todos los nodos de un propietario con sus últimas métricas
-func (r *NodeRepository) FindByOwnerIDWithMetrics(ownerID string) ([]*node.NodeWithMetrics, error) {
+// FindByOrganizationIDWithMetrics busca todos los nodos de una organización con sus últimas métricas
+func (r *NodeRepository) FindByOrganizationIDWithMetrics(ctx context.Context, orgID string) ([]*node.NodeWithMetrics, error) {
 	var results []*node.NodeWithMetrics
 
 	query := `
 		SELECT 
 			n.id, n.hostname, n.ip_local, n.os, n.arch, n.version_agent, 
-			n.owner_id, n.last_seen_at, n.created_at, n.updated_at,
+			n.organization_id, n.owner_id, n.last_seen_at, n.created_at, n.updated_at,
 			m.cpu_usage_percent, m.memory_usage_percent, m.disk_usage_percent, m.collected_at
 		FROM nodes n
 		LEFT JOIN LATERAL (
@@ -137,11 +179,11 @@ func (r *NodeRepository) FindByOwnerIDWithMetrics(ownerID string) ([]*node.NodeW
 			ORDER BY collected_at DESC
 			LIMIT 1
 		) m ON true
-		WHERE n.owner_id = $1
+		WHERE n.organization_id = $1
 		ORDER BY n.created_at DESC
 	`
 
-	rows, err := r.db.Queryx(query, ownerID)
+	rows, err := r.db.QueryxContext(ctx, query, orgID)
 	if err != nil {
 		return nil, err
 	}
@@ -154,7 +196,7 @@ func (r *NodeRepository) FindByOwnerIDWithMetrics(ownerID string) ([]*node.NodeW
 
 		err := rows.Scan(
 			&nwm.ID, &nwm.Hostname, &nwm.IPLocal, &nwm.OS, &nwm.Arch,
-			&nwm.VersionAgent, &nwm.OwnerID, &nwm.LastSeenAt,
+			&nwm.VersionAgent, &nwm.OrganizationID, &nwm.OwnerID, &nwm.LastSeenAt,
 			&nwm.CreatedAt, &nwm.UpdatedAt,
 			&cpuUsage, &memUsage, &diskUsage, &collectedAt,
 		)
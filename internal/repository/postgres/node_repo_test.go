@@ -0,0 +1,195 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+	nodemetric "github.com/arturo/autohost-cloud-api/internal/domain/node_metric"
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+func TestNodeRepositoryRegisterAndFind(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeRepository(db)
+	userID, orgID := createOrg(t, db, "owner@example.com")
+
+	n, err := repo.Register(ctx, &node.Node{
+		Hostname:       "db-03",
+		IPLocal:        "192.168.1.20",
+		OS:             "linux",
+		Arch:           "arm64",
+		VersionAgent:   "1.2.3",
+		OrganizationID: orgID,
+		OwnerID:        &userID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n.ID == "" || n.CreatedAt.IsZero() {
+		t.Fatalf("Register did not fill the generated columns: %+v", n)
+	}
+
+	got, err := repo.FindByID(ctx, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Hostname != "db-03" || got.IPLocal != "192.168.1.20" || got.OrganizationID != orgID ||
+		got.OwnerID == nil || *got.OwnerID != userID {
+		t.Errorf("FindByID = %+v", got)
+	}
+
+	if _, err := repo.Register(ctx, &node.Node{Hostname: "db-03", IPLocal: "10.0.0.1", OrganizationID: orgID}); err == nil {
+		t.Error("Register accepted a duplicate hostname in the same organization")
+	}
+	if _, err := repo.FindByID(ctx, "00000000-0000-0000-0000-000000000000"); !errors.Is(err, node.ErrNodeNotFound) {
+		t.Errorf("FindByID(unknown) error = %v, want ErrNodeNotFound", err)
+	}
+}
+
+func TestNodeRepositoryList(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	_, otherOrg := createOrg(t, db, "other@example.com")
+
+	for _, h := range []string{"web-01", "web-02", "db-01"} {
+		createNode(t, db, orgID, h)
+	}
+	createNode(t, db, otherOrg, "web-99")
+
+	byHostname := platform.Sort{Field: node.SortHostname}
+	nodes, err := repo.List(ctx, node.ListFilter{OrganizationID: orgID, Sort: byHostname, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hostnames(nodes); !slices.Equal(got, []string{"db-01", "web-01", "web-02"}) {
+		t.Errorf("List by hostname = %v", got)
+	}
+
+	nodes, err = repo.List(ctx, node.ListFilter{OrganizationID: orgID, Hostname: "WEB", Sort: byHostname, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hostnames(nodes); !slices.Equal(got, []string{"web-01", "web-02"}) {
+		t.Errorf("hostname search = %v", got)
+	}
+
+	// Un "%" en la búsqueda es literal, no un comodín
+	nodes, err = repo.List(ctx, node.ListFilter{OrganizationID: orgID, Hostname: "%", Sort: byHostname, Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 0 {
+		t.Errorf("search for %% matched %v", hostnames(nodes))
+	}
+
+	// Paginación por keyset: la segunda página empieza tras el cursor
+	first, err := repo.List(ctx, node.ListFilter{OrganizationID: orgID, Sort: byHostname, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := first[len(first)-1]
+	after := &platform.Cursor{Value: last.Hostname, ID: last.ID}
+	second, err := repo.List(ctx, node.ListFilter{OrganizationID: orgID, Sort: byHostname, After: after, Limit: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := hostnames(second); !slices.Equal(got, []string{"web-02"}) {
+		t.Errorf("second page = %v", got)
+	}
+
+	if _, err := repo.List(ctx, node.ListFilter{Sort: platform.Sort{Field: "os"}, Limit: 10}); !errors.Is(err, node.ErrInvalidFilter) {
+		t.Errorf("List with unknown sort error = %v, want ErrInvalidFilter", err)
+	}
+}
+
+func TestNodeRepositoryUpdateLastSeen(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+
+	sleepTick()
+	if err := repo.UpdateLastSeen(ctx, n.ID); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.FindByID(ctx, n.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.LastSeenAt == nil || !got.LastSeenAt.After(*n.LastSeenAt) {
+		t.Errorf("last_seen_at = %v, registered with %v", got.LastSeenAt, n.LastSeenAt)
+	}
+}
+
+func TestNodeRepositoryFindByOrganizationIDWithMetrics(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeRepository(db)
+	metrics := NewNodeMetricRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	_, otherOrg := createOrg(t, db, "other@example.com")
+
+	withMetrics := createNode(t, db, orgID, "web-01")
+	sleepTick()
+	withoutMetrics := createNode(t, db, orgID, "web-02")
+	createNode(t, db, otherOrg, "web-99")
+
+	collected := time.Now().Add(-time.Minute).UTC().Truncate(time.Microsecond)
+	for i, cpu := range []float64{10, 75, 40} {
+		// La más reciente por collected_at es la de 75, aunque no sea la última insertada
+		at := collected.Add(time.Duration(i) * time.Second)
+		if cpu == 75 {
+			at = collected.Add(time.Hour)
+		}
+		if _, err := metrics.StoreNodeMetric(ctx, &nodemetric.CreateNodeMetricRequest{
+			NodeID:             withMetrics.ID,
+			CPUUsagePercent:    cpu,
+			MemoryUsagePercent: 50,
+			DiskUsagePercent:   20,
+			CollectedAt:        at,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	nodes, err := repo.FindByOrganizationIDWithMetrics(ctx, orgID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 2 {
+		t.Fatalf("got %d nodes, want the 2 of the organization", len(nodes))
+	}
+	// Más nuevos primero
+	if nodes[0].ID != withoutMetrics.ID || nodes[1].ID != withMetrics.ID {
+		t.Fatalf("order = %s, %s", nodes[0].Hostname, nodes[1].Hostname)
+	}
+	if nodes[0].LastMetric != nil {
+		t.Errorf("node without metrics has LastMetric %+v", nodes[0].LastMetric)
+	}
+	m := nodes[1].LastMetric
+	if m == nil || m.CPUUsagePercent != 75 || m.MemoryUsagePercent != 50 || m.DiskUsagePercent != 20 ||
+		!m.CollectedAt.Equal(collected.Add(time.Hour)) {
+		t.Errorf("LastMetric = %+v, want the most recently collected one", m)
+	}
+
+	empty, err := repo.FindByOrganizationIDWithMetrics(ctx, "00000000-0000-0000-0000-000000000000")
+	if err != nil || len(empty) != 0 {
+		t.Errorf("unknown organization = %v, %v", empty, err)
+	}
+}
+
+func hostnames(nodes []*node.Node) []string {
+	out := make([]string, len(nodes))
+	for i, n := range nodes {
+		out[i] = n.Hostname
+	}
+	return out
+}
@@ -1,6 +1,8 @@
 package postgres
 
 import (
+	"context"
+	"database/sql"
 	"time"
 
 	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
@@ -11,8 +13,8 @@ type NodeTokenRepo struct{ DB *sqlx.DB }
 
 func NewNodeTokenRepository(db *sqlx.DB) *NodeTokenRepo { return &NodeTokenRepo{DB: db} }
 
-func (r *NodeTokenRepo) CreateNodeToken(nodeID, tokenHash string) error {
-	_, err := r.DB.Exec(`
+func (r *NodeTokenRepo) CreateNodeToken(ctx context.Context, nodeID, tokenHash string) error {
+	_, err := r.DB.ExecContext(ctx, `
 		INSERT INTO node_tokens (node_id, token)
 		VALUES ($1, $2)
 	`, nodeID, tokenHash)
@@ -22,21 +24,25 @@ func (r *NodeTokenRepo) CreateNodeToken(nodeID, tokenHash string) error {
 	return nil
 }
 
-func (r *NodeTokenRepo) FindNodeTokenByHash(tokenHash string) (*nodetoken.NodeToken, error) {
+func (r *NodeTokenRepo) FindNodeTokenByHash(ctx context.Context, tokenHash string) (*nodetoken.NodeToken, error) {
 	var model nodetoken.NodeToken
-	err := r.DB.Get(&model, `
-		SELECT id, node_id, token, created_at, last_seen_at, revoked_at
-		FROM node_tokens
-		WHERE token = $1
+	err := r.DB.GetContext(ctx, &model, `
+		SELECT t.id, t.node_id, n.organization_id, t.token, t.created_at, t.last_seen_at, t.revoked_at
+		FROM node_tokens t
+		JOIN nodes n ON n.id = t.node_id
+		WHERE t.token = $1
 	`, tokenHash)
+	if err == sql.ErrNoRows {
+		return nil, nodetoken.ErrNodeTokenNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 	return &model, nil
 }
 
-func (r *NodeTokenRepo) UpdateLastSeen(tokenID string, lastSeenAt time.Time) error {
-	_, err := r.DB.Exec(`
+func (r *NodeTokenRepo) UpdateLastSeen(ctx context.Context, tokenID string, lastSeenAt time.Time) error {
+	_, err := r.DB.ExecContext(ctx, `
 		UPDATE node_tokens
 		SET last_seen_at = $1
 		WHERE id = $2
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	nodetoken "github.com/arturo/autohost-cloud-api/internal/domain/node_token"
+)
+
+func TestNodeTokenRepo(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewNodeTokenRepository(db)
+	_, orgID := createOrg(t, db, "owner@example.com")
+	n := createNode(t, db, orgID, "web-01")
+
+	if err := repo.CreateNodeToken(ctx, n.ID, "hash-1"); err != nil {
+		t.Fatal(err)
+	}
+	tok, err := repo.FindNodeTokenByHash(ctx, "hash-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// La organización sale del nodo
+	if tok.NodeID != n.ID || tok.OrganizationID != orgID || tok.LastSeenAt != nil || tok.RevokedAt != nil {
+		t.Fatalf("FindNodeTokenByHash = %+v", tok)
+	}
+
+	seen := time.Now().UTC().Truncate(time.Microsecond)
+	if err := repo.UpdateLastSeen(ctx, tok.ID, seen); err != nil {
+		t.Fatal(err)
+	}
+	tok, _ = repo.FindNodeTokenByHash(ctx, "hash-1")
+	if tok.LastSeenAt == nil || !tok.LastSeenAt.Equal(seen) {
+		t.Errorf("last_seen_at = %v, want %v", tok.LastSeenAt, seen)
+	}
+
+	if _, err := repo.FindNodeTokenByHash(ctx, "missing"); !errors.Is(err, nodetoken.ErrNodeTokenNotFound) {
+		t.Errorf("FindNodeTokenByHash(unknown) error = %v, want ErrNodeTokenNotFound", err)
+	}
+	if err := repo.CreateNodeToken(ctx, unknownID, "hash-2"); err == nil {
+		t.Error("CreateNodeToken accepted an unknown node")
+	}
+}
@@ -0,0 +1,71 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+	"github.com/jmoiron/sqlx"
+)
+
+// OIDCRepository implementa oidc.Repository usando PostgreSQL
+type OIDCRepository struct {
+	db *sqlx.DB
+}
+
+// NewOIDCRepository crea una nueva instancia del repositorio
+func NewOIDCRepository(db *sqlx.DB) *OIDCRepository {
+	return &OIDCRepository{db: db}
+}
+
+// CreateState guarda el estado de un login en curso y purga los caducados
+func (r *OIDCRepository) CreateState(ctx context.Context, s *oidc.LoginState) error {
+	if _, err := r.db.ExecContext(ctx,
+		`DELETE FROM oidc_login_states WHERE expires_at < now()`); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO oidc_login_states (state_hash, browser_hash, code_verifier, nonce, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`, s.StateHash, s.BrowserHash, s.CodeVerifier, s.Nonce, s.ExpiresAt)
+	return err
+}
+
+// ConsumeState borra y devuelve el estado (un solo uso) si lo inició el
+// navegador browserHash
+func (r *OIDCRepository) ConsumeState(ctx context.Context, stateHash, browserHash string) (*oidc.LoginState, error) {
+	var s oidc.LoginState
+	err := r.db.GetContext(ctx, &s, `
+		DELETE FROM oidc_login_states WHERE state_hash = $1 AND browser_hash = $2
+		RETURNING state_hash, browser_hash, code_verifier, nonce, expires_at`, stateHash, browserHash)
+	if err == sql.ErrNoRows {
+		return nil, oidc.ErrInvalidState
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// FindIdentity busca una identidad externa vinculada
+func (r *OIDCRepository) FindIdentity(ctx context.Context, issuer, subject string) (*oidc.Identity, error) {
+	var i oidc.Identity
+	err := r.db.GetContext(ctx, &i, `
+		SELECT id, user_id, issuer, subject, email, created_at
+		FROM user_identities WHERE issuer = $1 AND subject = $2`, issuer, subject)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &i, nil
+}
+
+// LinkIdentity vincula una identidad externa a un usuario
+func (r *OIDCRepository) LinkIdentity(ctx context.Context, i *oidc.Identity) error {
+	return r.db.QueryRowxContext(ctx, `
+		INSERT INTO user_identities (user_id, issuer, subject, email)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, i.UserID, i.Issuer, i.Subject, i.Email,
+	).Scan(&i.ID, &i.CreatedAt)
+}
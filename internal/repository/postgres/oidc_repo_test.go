@@ -0,0 +1,79 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/oidc"
+)
+
+func TestOIDCRepositoryStates(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewOIDCRepository(db)
+	expires := time.Now().Add(10 * time.Minute).UTC().Truncate(time.Microsecond)
+
+	if err := repo.CreateState(ctx, &oidc.LoginState{
+		StateHash: "stale", BrowserHash: "b0", CodeVerifier: "v0", Nonce: "n0", ExpiresAt: time.Now().Add(-time.Minute),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := oidc.LoginState{StateHash: "state-1", BrowserHash: "browser", CodeVerifier: "verifier", Nonce: "nonce", ExpiresAt: expires}
+	if err := repo.CreateState(ctx, &want); err != nil {
+		t.Fatal(err)
+	}
+
+	// Solo lo consume el navegador que lo inició
+	if _, err := repo.ConsumeState(ctx, "state-1", "other-browser"); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("ConsumeState from another browser error = %v, want ErrInvalidState", err)
+	}
+	got, err := repo.ConsumeState(ctx, "state-1", "browser")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.CodeVerifier != "verifier" || got.Nonce != "nonce" || !got.ExpiresAt.Equal(expires) {
+		t.Errorf("ConsumeState = %+v", got)
+	}
+	// Un solo uso
+	if _, err := repo.ConsumeState(ctx, "state-1", "browser"); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("second ConsumeState error = %v, want ErrInvalidState", err)
+	}
+	// CreateState purga los estados caducados
+	if _, err := repo.ConsumeState(ctx, "stale", "b0"); !errors.Is(err, oidc.ErrInvalidState) {
+		t.Errorf("expired state was not purged: %v", err)
+	}
+}
+
+func TestOIDCRepositoryIdentities(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewOIDCRepository(db)
+	userID := createUser(t, db, "ana@example.com")
+	const issuer = "https://idp.example.com"
+
+	if got, err := repo.FindIdentity(ctx, issuer, "sub-1"); got != nil || err != nil {
+		t.Errorf("FindIdentity before linking = %+v, %v; want nil, nil", got, err)
+	}
+
+	id := &oidc.Identity{UserID: userID, Issuer: issuer, Subject: "sub-1", Email: "ana@example.com"}
+	if err := repo.LinkIdentity(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	got, err := repo.FindIdentity(ctx, issuer, "sub-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.ID != id.ID || got.UserID != userID || got.Email != "ana@example.com" {
+		t.Errorf("FindIdentity = %+v", got)
+	}
+	if got, _ := repo.FindIdentity(ctx, "https://other.example.com", "sub-1"); got != nil {
+		t.Errorf("identity matched under another issuer: %+v", got)
+	}
+
+	dup := &oidc.Identity{UserID: createUser(t, db, "eve@example.com"), Issuer: issuer, Subject: "sub-1", Email: "eve@example.com"}
+	if err := repo.LinkIdentity(ctx, dup); err == nil {
+		t.Error("LinkIdentity linked the same external account twice")
+	}
+}
@@ -0,0 +1,260 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+	"github.com/jmoiron/sqlx"
+)
+
+// OrganizationRepository implements organization.Repository using PostgreSQL.
+type OrganizationRepository struct {
+	db *sqlx.DB
+}
+
+func NewOrganizationRepository(db *sqlx.DB) *OrganizationRepository {
+	return &OrganizationRepository{db: db}
+}
+
+// Create inserts a team organization and its first owner in a single transaction.
+func (r *OrganizationRepository) Create(ctx context.Context, name, ownerID string) (*organization.Organization, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var org organization.Organization
+	err = tx.GetContext(ctx, &org, `
+		INSERT INTO organizations (name)
+		VALUES ($1)
+		RETURNING id, name, personal_user_id, require_mfa, created_at, updated_at`, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)`, org.ID, ownerID, organization.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// EnsurePersonal returns the personal organization of userID, creating it
+// (with the user as owner) if it does not exist yet.
+func (r *OrganizationRepository) EnsurePersonal(ctx context.Context, userID, name string) (*organization.Organization, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organizations (name, personal_user_id)
+		VALUES ($1, $2)
+		ON CONFLICT (personal_user_id) DO NOTHING`, name, userID); err != nil {
+		return nil, err
+	}
+
+	var org organization.Organization
+	if err := tx.GetContext(ctx, &org, `
+		SELECT id, name, personal_user_id, require_mfa, created_at, updated_at
+		FROM organizations WHERE personal_user_id = $1`, userID); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (organization_id, user_id) DO NOTHING`, org.ID, userID, organization.RoleOwner); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// FindByID returns an organization by its UUID.
+func (r *OrganizationRepository) FindByID(ctx context.Context, id string) (*organization.Organization, error) {
+	var org organization.Organization
+	err := r.db.GetContext(ctx, &org, `
+		SELECT id, name, personal_user_id, require_mfa, created_at, updated_at
+		FROM organizations WHERE id = $1`, id)
+	if err == sql.ErrNoRows {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// FindPersonal returns the personal organization of a user.
+func (r *OrganizationRepository) FindPersonal(ctx context.Context, userID string) (*organization.Organization, error) {
+	var org organization.Organization
+	err := r.db.GetContext(ctx, &org, `
+		SELECT id, name, personal_user_id, require_mfa, created_at, updated_at
+		FROM organizations WHERE personal_user_id = $1`, userID)
+	if err == sql.ErrNoRows {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
+
+// FindByUserID returns every organization the user is a member of.
+func (r *OrganizationRepository) FindByUserID(ctx context.Context, userID string) ([]*organization.UserOrganization, error) {
+	var orgs []*organization.UserOrganization
+	err := r.db.SelectContext(ctx, &orgs, `
+		SELECT o.id, o.name, o.personal_user_id, o.require_mfa, o.created_at, o.updated_at, m.role
+		FROM organizations o
+		JOIN organization_members m ON m.organization_id = o.id
+		WHERE m.user_id = $1
+		ORDER BY o.personal_user_id IS NULL, o.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	return orgs, nil
+}
+
+// FindMembership returns the membership of userID in orgID.
+func (r *OrganizationRepository) FindMembership(ctx context.Context, orgID, userID string) (*organization.Membership, error) {
+	var m organization.Membership
+	err := r.db.GetContext(ctx, &m, `
+		SELECT m.organization_id, m.user_id, m.role, m.created_at, o.require_mfa
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.organization_id = $1 AND m.user_id = $2`, orgID, userID)
+	if err == sql.ErrNoRows {
+		return nil, organization.ErrNotMember
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// FindMembers lists the members of an organization with their user profile.
+func (r *OrganizationRepository) FindMembers(ctx context.Context, orgID string) ([]*organization.Member, error) {
+	var members []*organization.Member
+	err := r.db.SelectContext(ctx, &members, `
+		SELECT m.user_id, u.email, u.name, m.role, m.created_at
+		FROM organization_members m
+		JOIN users u ON u.id = m.user_id
+		WHERE m.organization_id = $1
+		ORDER BY m.created_at`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return members, nil
+}
+
+// AddMember inserts a new membership.
+func (r *OrganizationRepository) AddMember(ctx context.Context, orgID, userID string, role organization.Role) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role)
+		VALUES ($1, $2, $3)`, orgID, userID, role)
+	return err
+}
+
+// UpdateMemberRole changes the role of an existing member.
+func (r *OrganizationRepository) UpdateMemberRole(ctx context.Context, orgID, userID string, role organization.Role) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if role != organization.RoleOwner {
+		if err := keepAnotherOwner(ctx, tx, orgID, userID); err != nil {
+			return err
+		}
+	}
+	res, err := tx.ExecContext(ctx, `
+		UPDATE organization_members SET role = $1
+		WHERE organization_id = $2 AND user_id = $3`, role, orgID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return organization.ErrNotMember
+	}
+	return tx.Commit()
+}
+
+// RemoveMember deletes a membership.
+func (r *OrganizationRepository) RemoveMember(ctx context.Context, orgID, userID string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := keepAnotherOwner(ctx, tx, orgID, userID); err != nil {
+		return err
+	}
+	res, err := tx.ExecContext(ctx, `
+		DELETE FROM organization_members
+		WHERE organization_id = $1 AND user_id = $2`, orgID, userID)
+	if err != nil {
+		return err
+	}
+	n, _ := res.RowsAffected()
+	if n == 0 {
+		return organization.ErrNotMember
+	}
+	return tx.Commit()
+}
+
+// keepAnotherOwner returns ErrLastOwner if userID is the only owner of orgID.
+// It locks the owner rows until tx ends, so two owners demoting or removing
+// each other at once are serialized and the second one sees the first change.
+func keepAnotherOwner(ctx context.Context, tx *sqlx.Tx, orgID, userID string) error {
+	var owners []string
+	if err := tx.SelectContext(ctx, &owners, `
+		SELECT user_id FROM organization_members
+		WHERE organization_id = $1 AND role = $2
+		FOR UPDATE`, orgID, organization.RoleOwner); err != nil {
+		return err
+	}
+	if len(owners) == 1 && owners[0] == userID {
+		return organization.ErrLastOwner
+	}
+	return nil
+}
+
+// CountOwners returns how many owners an organization has.
+func (r *OrganizationRepository) CountOwners(ctx context.Context, orgID string) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, `
+		SELECT COUNT(*) FROM organization_members
+		WHERE organization_id = $1 AND role = $2`, orgID, organization.RoleOwner)
+	return n, err
+}
+
+// SetRequireMFA updates the two-factor requirement of an organization.
+func (r *OrganizationRepository) SetRequireMFA(ctx context.Context, id string, require bool) (*organization.Organization, error) {
+	var org organization.Organization
+	err := r.db.GetContext(ctx, &org, `
+		UPDATE organizations SET require_mfa = $1, updated_at = now()
+		WHERE id = $2
+		RETURNING id, name, personal_user_id, require_mfa, created_at, updated_at`, require, id)
+	if err == sql.ErrNoRows {
+		return nil, organization.ErrOrganizationNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &org, nil
+}
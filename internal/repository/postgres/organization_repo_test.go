@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/organization"
+)
+
+func TestOrganizationRepositoryPersonal(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewOrganizationRepository(db)
+	userID := createUser(t, db, "ana@example.com")
+
+	org, err := repo.EnsurePersonal(ctx, userID, "Ana")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if org.PersonalUserID == nil || *org.PersonalUserID != userID || org.RequireMFA {
+		t.Fatalf("EnsurePersonal = %+v", org)
+	}
+	// Idempotente: la segunda llamada devuelve la misma organización
+	again, err := repo.EnsurePersonal(ctx, userID, "Other name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.ID != org.ID || again.Name != "Ana" {
+		t.Errorf("second EnsurePersonal = %+v, want %+v", again, org)
+	}
+
+	if got, err := repo.FindPersonal(ctx, userID); err != nil || got.ID != org.ID {
+		t.Errorf("FindPersonal = %+v, %v", got, err)
+	}
+	other := createUser(t, db, "eve@example.com")
+	if _, err := repo.FindPersonal(ctx, other); !errors.Is(err, organization.ErrOrganizationNotFound) {
+		t.Errorf("FindPersonal(no org) error = %v, want ErrOrganizationNotFound", err)
+	}
+	if _, err := repo.FindByID(ctx, unknownID); !errors.Is(err, organization.ErrOrganizationNotFound) {
+		t.Errorf("FindByID(unknown) error = %v, want ErrOrganizationNotFound", err)
+	}
+	if owners, err := repo.CountOwners(ctx, org.ID); err != nil || owners != 1 {
+		t.Errorf("CountOwners = %d, %v", owners, err)
+	}
+}
+
+func TestOrganizationRepositoryMembers(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewOrganizationRepository(db)
+	ownerID, personalID := createOrg(t, db, "ana@example.com")
+	memberID := createUser(t, db, "bob@example.com")
+
+	team, err := repo.Create(ctx, "Platform", ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if team.PersonalUserID != nil {
+		t.Errorf("team organization has personal_user_id %v", *team.PersonalUserID)
+	}
+	if got, err := repo.FindByID(ctx, team.ID); err != nil || got.Name != "Platform" {
+		t.Errorf("FindByID = %+v, %v", got, err)
+	}
+
+	if err := repo.AddMember(ctx, team.ID, memberID, organization.RoleViewer); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.AddMember(ctx, team.ID, memberID, organization.RoleAdmin); err == nil {
+		t.Error("AddMember accepted an existing member")
+	}
+	if err := repo.AddMember(ctx, team.ID, createUser(t, db, "x@example.com"), organization.Role("root")); err == nil {
+		t.Error("AddMember accepted an unknown role")
+	}
+
+	m, err := repo.FindMembership(ctx, team.ID, memberID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Role != organization.RoleViewer || m.RequireMFA {
+		t.Errorf("FindMembership = %+v", m)
+	}
+	if _, err := repo.FindMembership(ctx, personalID, memberID); !errors.Is(err, organization.ErrNotMember) {
+		t.Errorf("FindMembership(not a member) error = %v, want ErrNotMember", err)
+	}
+
+	members, err := repo.FindMembers(ctx, team.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != 2 || members[0].UserID != ownerID || members[0].Role != organization.RoleOwner ||
+		members[1].Email != "bob@example.com" {
+		t.Errorf("FindMembers = %+v", members)
+	}
+
+	// La organización personal va primero
+	orgs, err := repo.FindByUserID(ctx, ownerID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(orgs) != 2 || orgs[0].ID != personalID || orgs[1].ID != team.ID || orgs[1].Role != organization.RoleOwner {
+		t.Errorf("FindByUserID = %+v", orgs)
+	}
+
+	if err := repo.UpdateMemberRole(ctx, team.ID, memberID, organization.RoleOwner); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := repo.CountOwners(ctx, team.ID); n != 2 {
+		t.Errorf("CountOwners after promotion = %d", n)
+	}
+	if err := repo.UpdateMemberRole(ctx, personalID, memberID, organization.RoleAdmin); !errors.Is(err, organization.ErrNotMember) {
+		t.Errorf("UpdateMemberRole(not a member) error = %v, want ErrNotMember", err)
+	}
+
+	if err := repo.RemoveMember(ctx, team.ID, memberID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.UpdateMemberRole(ctx, team.ID, ownerID, organization.RoleAdmin); !errors.Is(err, organization.ErrLastOwner) {
+		t.Errorf("demoting the last owner error = %v, want ErrLastOwner", err)
+	}
+	if err := repo.RemoveMember(ctx, team.ID, ownerID); !errors.Is(err, organization.ErrLastOwner) {
+		t.Errorf("removing the last owner error = %v, want ErrLastOwner", err)
+	}
+	if err := repo.RemoveMember(ctx, team.ID, memberID); !errors.Is(err, organization.ErrNotMember) {
+		t.Errorf("second RemoveMember error = %v, want ErrNotMember", err)
+	}
+
+	updated, err := repo.SetRequireMFA(ctx, team.ID, true)
+	if err != nil || !updated.RequireMFA {
+		t.Fatalf("SetRequireMFA = %+v, %v", updated, err)
+	}
+	if m, _ := repo.FindMembership(ctx, team.ID, ownerID); !m.RequireMFA {
+		t.Error("membership does not reflect the organization's MFA requirement")
+	}
+	if _, err := repo.SetRequireMFA(ctx, unknownID, true); !errors.Is(err, organization.ErrOrganizationNotFound) {
+		t.Errorf("SetRequireMFA(unknown) error = %v, want ErrOrganizationNotFound", err)
+	}
+}
@@ -0,0 +1,42 @@
+package postgres
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/arturo/autohost-cloud-api/internal/platform"
+)
+
+// sortColumn es la expresión SQL de un campo de ordenación y el tipo al que
+// se convierte el valor del cursor para compararlo
+type sortColumn struct {
+	expr string
+	typ  string
+}
+
+// keysetCondition devuelve la condición que deja solo las filas posteriores
+// al cursor en el orden dado; valueArg e idArg son los placeholders de
+// cursor.Value y cursor.ID. El id desempata filas con el mismo valor.
+func keysetCondition(col sortColumn, desc bool, valueArg, idArg int) string {
+	op := ">"
+	if desc {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, id) %s ($%d::%s, $%d::uuid)", col.expr, op, valueArg, col.typ, idArg)
+}
+
+// keysetOrder es el ORDER BY que corresponde a keysetCondition
+func keysetOrder(col sortColumn, sort platform.Sort) string {
+	dir := "ASC"
+	if sort.Desc {
+		dir = "DESC"
+	}
+	return fmt.Sprintf(" ORDER BY %s %s, id %s", col.expr, dir, dir)
+}
+
+// likeEscaper escapa los comodines de LIKE para buscar el texto literal
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
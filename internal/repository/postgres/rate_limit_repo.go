@@ -0,0 +1,126 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+	"github.com/jmoiron/sqlx"
+)
+
+// RateLimitRepository implementa ratelimit.Repository usando PostgreSQL
+type RateLimitRepository struct {
+	db *sqlx.DB
+}
+
+func NewRateLimitRepository(db *sqlx.DB) *RateLimitRepository {
+	return &RateLimitRepository{db: db}
+}
+
+// Take rellena el bucket según el tiempo transcurrido (medido con el reloj de
+// la BD para que todas las réplicas coincidan) y consume un token. La fila
+// queda bloqueada durante la transacción.
+func (r *RateLimitRepository) Take(ctx context.Context, key string, limit ratelimit.Limit) (ratelimit.Result, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	defer tx.Rollback()
+
+	burst := float64(limit.Burst)
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO rate_limit_buckets (key, tokens) VALUES ($1, $2)
+		ON CONFLICT (key) DO NOTHING`, key, burst); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	var tokens, elapsed float64
+	if err := tx.QueryRowContext(ctx, `
+		SELECT tokens, EXTRACT(EPOCH FROM now() - updated_at)::float8
+		FROM rate_limit_buckets WHERE key = $1
+		FOR UPDATE`, key).Scan(&tokens, &elapsed); err != nil {
+		return ratelimit.Result{}, err
+	}
+
+	perSecond := 1 / limit.Every.Seconds()
+	tokens = min(burst, tokens+max(elapsed, 0)*perSecond)
+
+	res := ratelimit.Result{Allowed: tokens >= 1}
+	if res.Allowed {
+		tokens--
+	} else {
+		res.RetryAfter = time.Duration((1 - tokens) / perSecond * float64(time.Second))
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE rate_limit_buckets SET tokens = $1, updated_at = now()
+		WHERE key = $2`, tokens, key); err != nil {
+		return ratelimit.Result{}, err
+	}
+	return res, tx.Commit()
+}
+
+// PurgeBuckets borra los buckets que no se usan desde before
+func (r *RateLimitRepository) PurgeBuckets(ctx context.Context, before time.Time) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM rate_limit_buckets WHERE updated_at < $1`, before)
+	return err
+}
+
+// FindLockout devuelve nil si la clave no tiene fallos registrados
+func (r *RateLimitRepository) FindLockout(ctx context.Context, key string) (*ratelimit.Lockout, error) {
+	var l ratelimit.Lockout
+	err := r.db.QueryRowContext(ctx, `
+		SELECT failed_attempts, locked_until FROM login_lockouts WHERE key = $1`,
+		key).Scan(&l.FailedAttempts, &l.LockedUntil)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &l, nil
+}
+
+// RecordFailure suma un fallo (el contador se reinicia si el último fallo fue
+// hace más de un día) y fija el bloqueo que corresponda
+func (r *RateLimitRepository) RecordFailure(ctx context.Context, key string, backoff func(failures int) time.Duration) (*ratelimit.Lockout, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var l ratelimit.Lockout
+	if err := tx.QueryRowContext(ctx, `
+		INSERT INTO login_lockouts (key, failed_attempts, last_failed_at)
+		VALUES ($1, 1, now())
+		ON CONFLICT (key) DO UPDATE SET
+			failed_attempts = CASE
+				WHEN login_lockouts.last_failed_at < now() - interval '24 hours' THEN 1
+				ELSE login_lockouts.failed_attempts + 1
+			END,
+			last_failed_at = now()
+		RETURNING failed_attempts`, key).Scan(&l.FailedAttempts); err != nil {
+		return nil, err
+	}
+
+	if d := backoff(l.FailedAttempts); d > 0 {
+		if err := tx.QueryRowContext(ctx, `
+			UPDATE login_lockouts
+			SET locked_until = now() + make_interval(secs => $1)
+			WHERE key = $2
+			RETURNING locked_until`, d.Seconds(), key).Scan(&l.LockedUntil); err != nil {
+			return nil, err
+		}
+	}
+	return &l, tx.Commit()
+}
+
+// ResetLockout olvida los fallos de la clave
+func (r *RateLimitRepository) ResetLockout(ctx context.Context, key string) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM login_lockouts WHERE key = $1`, key)
+	return err
+}
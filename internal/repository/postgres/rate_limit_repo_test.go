@@ -0,0 +1,109 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ratelimit "github.com/arturo/autohost-cloud-api/internal/domain/rate_limit"
+)
+
+func TestRateLimitRepositoryTake(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewRateLimitRepository(db)
+	// Ráfaga de 3 y un token por hora: dentro del test no se rellena
+	limit := ratelimit.Limit{Every: time.Hour, Burst: 3}
+
+	for i := range 3 {
+		res, err := repo.Take(ctx, "login:ip:203.0.113.7", limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !res.Allowed {
+			t.Fatalf("request %d rejected within the burst", i+1)
+		}
+	}
+	res, err := repo.Take(ctx, "login:ip:203.0.113.7", limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Allowed || res.RetryAfter <= 0 || res.RetryAfter > time.Hour+time.Second {
+		t.Errorf("request over the limit = %+v", res)
+	}
+	// Las claves no comparten bucket
+	if res, _ := repo.Take(ctx, "login:ip:203.0.113.8", limit); !res.Allowed {
+		t.Error("another key was throttled")
+	}
+
+	// Con un token cada milisegundo el bucket se rellena enseguida
+	fast := ratelimit.Limit{Every: time.Millisecond, Burst: 1}
+	if res, _ := repo.Take(ctx, "fast", fast); !res.Allowed {
+		t.Fatal("first request rejected")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if res, _ := repo.Take(ctx, "fast", fast); !res.Allowed {
+		t.Error("bucket did not refill")
+	}
+
+	if err := repo.PurgeBuckets(ctx, time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := repo.Take(ctx, "login:ip:203.0.113.7", limit); !res.Allowed {
+		t.Error("purged bucket is still empty")
+	}
+}
+
+func TestRateLimitRepositoryLockouts(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewRateLimitRepository(db)
+	const key = "login:account:ana@example.com"
+	// Bloqueo a partir del tercer fallo
+	backoff := func(failures int) time.Duration {
+		if failures < 3 {
+			return 0
+		}
+		return time.Duration(failures) * time.Minute
+	}
+
+	if l, err := repo.FindLockout(ctx, key); l != nil || err != nil {
+		t.Errorf("FindLockout before failures = %+v, %v; want nil, nil", l, err)
+	}
+
+	for i := 1; i <= 3; i++ {
+		l, err := repo.RecordFailure(ctx, key, backoff)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l.FailedAttempts != i {
+			t.Errorf("failure %d recorded as %d", i, l.FailedAttempts)
+		}
+		if locked := l.LockedUntil != nil; locked != (i == 3) {
+			t.Errorf("failure %d: locked until %v", i, l.LockedUntil)
+		}
+	}
+
+	l, err := repo.FindLockout(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l.FailedAttempts != 3 || l.LockedUntil == nil || time.Until(*l.LockedUntil) < 2*time.Minute {
+		t.Errorf("FindLockout = %+v, want locked for about 3 minutes", l)
+	}
+
+	// Un fallo de hace más de un día no cuenta
+	if _, err := db.Exec(`UPDATE login_lockouts SET last_failed_at = now() - interval '25 hours' WHERE key = $1`, key); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := repo.RecordFailure(ctx, key, backoff); l.FailedAttempts != 1 {
+		t.Errorf("failures after a quiet day = %d, want 1", l.FailedAttempts)
+	}
+
+	if err := repo.ResetLockout(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if l, _ := repo.FindLockout(ctx, key); l != nil {
+		t.Errorf("FindLockout after reset = %+v", l)
+	}
+}
@@ -0,0 +1,35 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// SchemaVersion es la última migración de migrations/ que este binario
+// necesita. Hay que subirla con cada migración nueva; un test lo comprueba.
+const SchemaVersion = 25
+
+// CheckSchema comprueba que la base de datos responde y que golang-migrate
+// la dejó exactamente en SchemaVersion y sin una migración a medias.
+func CheckSchema(ctx context.Context, db *sqlx.DB) error {
+	var (
+		version int
+		dirty   bool
+	)
+	err := db.QueryRowContext(ctx, `SELECT version, dirty FROM schema_migrations LIMIT 1`).Scan(&version, &dirty)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("no migrations applied, want version %d", SchemaVersion)
+	case err != nil:
+		return fmt.Errorf("read schema version: %w", err)
+	case dirty:
+		return fmt.Errorf("migration %d is dirty", version)
+	case version != SchemaVersion:
+		return fmt.Errorf("schema version %d, want %d", version, SchemaVersion)
+	}
+	return nil
+}
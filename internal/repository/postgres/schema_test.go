@@ -0,0 +1,43 @@
+package postgres
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestSchemaVersionMatchesMigrations(t *testing.T) {
+	entries, err := os.ReadDir("../../../migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := 0
+	for _, e := range entries {
+		prefix, _, ok := strings.Cut(e.Name(), "_")
+		if !ok || !strings.HasSuffix(e.Name(), ".up.sql") {
+			continue
+		}
+		if n, err := strconv.Atoi(prefix); err == nil && n > latest {
+			latest = n
+		}
+	}
+	if latest != SchemaVersion {
+		t.Errorf("SchemaVersion = %d, but the latest migration is %d", SchemaVersion, latest)
+	}
+}
+
+func TestCheckSchemaAfterMigrations(t *testing.T) {
+	db := newTestDB(t)
+	if err := CheckSchema(context.Background(), db); err != nil {
+		t.Fatalf("CheckSchema after migrating: %v", err)
+	}
+
+	if _, err := db.Exec(`UPDATE schema_migrations SET dirty = true`); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckSchema(context.Background(), db); err == nil {
+		t.Error("CheckSchema accepted a dirty migration")
+	}
+}
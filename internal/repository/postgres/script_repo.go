@@ -0,0 +1,228 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+	"github.com/jmoiron/sqlx"
+)
+
+// ScriptRepository implements script.Repository using PostgreSQL.
+type ScriptRepository struct {
+	db *sqlx.DB
+}
+
+func NewScriptRepository(db *sqlx.DB) *ScriptRepository {
+	return &ScriptRepository{db: db}
+}
+
+const (
+	scriptColumns           = `id, organization_id, name, description, version, sha256, created_by, created_at, updated_at`
+	scriptVersionColumns    = `script_id, version, size, sha256, created_by, created_at`
+	scriptAssignmentColumns = `id, script_id, node_id, group_id, created_at`
+)
+
+// Create inserts a script and its first version in one transaction; the
+// (organization_id, name) conflict is reported as ErrScriptExists.
+func (r *ScriptRepository) Create(ctx context.Context, s *script.Script, v *script.Version) (*script.Script, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var out script.Script
+	err = tx.GetContext(ctx, &out, `
+		INSERT INTO scripts (organization_id, name, description, version, sha256, created_by)
+		VALUES ($1, $2, $3, 1, $4, $5)
+		ON CONFLICT (organization_id, name) DO NOTHING
+		RETURNING `+scriptColumns,
+		s.OrganizationID, s.Name, s.Description, v.SHA256, s.CreatedBy,
+	)
+	if err == sql.ErrNoRows {
+		return nil, script.ErrScriptExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO script_versions (script_id, version, body, size, sha256, created_by)
+		VALUES ($1, 1, $2, $3, $4, $5)`,
+		out.ID, v.Body, v.Size, v.SHA256, v.CreatedBy); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddVersion bumps the version of the script row, which also serializes
+// concurrent uploads, and inserts the body under the new number.
+func (r *ScriptRepository) AddVersion(ctx context.Context, v *script.Version) (*script.Version, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var version int
+	err = tx.GetContext(ctx, &version, `
+		UPDATE scripts SET version = version + 1, sha256 = $1, updated_at = now()
+		WHERE id = $2
+		RETURNING version`, v.SHA256, v.ScriptID)
+	if err == sql.ErrNoRows {
+		return nil, script.ErrScriptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out script.Version
+	err = tx.GetContext(ctx, &out, `
+		INSERT INTO script_versions (script_id, version, body, size, sha256, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+scriptVersionColumns,
+		v.ScriptID, version, v.Body, v.Size, v.SHA256, v.CreatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *ScriptRepository) Find(ctx context.Context, orgID, id string) (*script.Script, error) {
+	var out script.Script
+	err := r.db.GetContext(ctx, &out,
+		`SELECT `+scriptColumns+` FROM scripts WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err == sql.ErrNoRows {
+		return nil, script.ErrScriptNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *ScriptRepository) List(ctx context.Context, orgID string) ([]*script.Script, error) {
+	var out []*script.Script
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT `+scriptColumns+` FROM scripts WHERE organization_id = $1 ORDER BY name`, orgID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *ScriptRepository) Delete(ctx context.Context, orgID, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM scripts WHERE id = $1 AND organization_id = $2`, id, orgID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return script.ErrScriptNotFound
+	}
+	return nil
+}
+
+func (r *ScriptRepository) Versions(ctx context.Context, scriptID string) ([]*script.Version, error) {
+	var out []*script.Version
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT `+scriptVersionColumns+` FROM script_versions WHERE script_id = $1 ORDER BY version DESC`, scriptID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *ScriptRepository) FindVersion(ctx context.Context, scriptID string, version int) (*script.Version, error) {
+	var out script.Version
+	err := r.db.GetContext(ctx, &out,
+		`SELECT `+scriptVersionColumns+`, body FROM script_versions WHERE script_id = $1 AND version = $2`,
+		scriptID, version)
+	if err == sql.ErrNoRows {
+		return nil, script.ErrVersionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Assign inserts an assignment; assigning the script to the same node or
+// group again is reported as ErrAssignmentExists.
+func (r *ScriptRepository) Assign(ctx context.Context, a *script.Assignment) (*script.Assignment, error) {
+	var out script.Assignment
+	err := r.db.GetContext(ctx, &out, `
+		INSERT INTO script_assignments (script_id, node_id, group_id)
+		VALUES ($1, $2, $3)
+		ON CONFLICT DO NOTHING
+		RETURNING `+scriptAssignmentColumns,
+		a.ScriptID, a.NodeID, a.GroupID,
+	)
+	if err == sql.ErrNoRows {
+		return nil, script.ErrAssignmentExists
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (r *ScriptRepository) Assignments(ctx context.Context, scriptID string) ([]*script.Assignment, error) {
+	var out []*script.Assignment
+	err := r.db.SelectContext(ctx, &out,
+		`SELECT `+scriptAssignmentColumns+` FROM script_assignments WHERE script_id = $1 ORDER BY created_at, id`, scriptID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *ScriptRepository) Unassign(ctx context.Context, scriptID, id string) error {
+	res, err := r.db.ExecContext(ctx,
+		`DELETE FROM script_assignments WHERE id = $1 AND script_id = $2`, id, scriptID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return script.ErrAssignmentNotFound
+	}
+	return nil
+}
+
+func (r *ScriptRepository) NodeIDs(ctx context.Context, scriptID string) ([]string, error) {
+	var out []string
+	err := r.db.SelectContext(ctx, &out, `
+		SELECT node_id FROM script_assignments WHERE script_id = $1 AND node_id IS NOT NULL
+		UNION
+		SELECT m.node_id FROM script_assignments a
+		JOIN node_group_members m ON m.group_id = a.group_id
+		WHERE a.script_id = $1
+		ORDER BY node_id`, scriptID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (r *ScriptRepository) ForNode(ctx context.Context, nodeID string) ([]*script.Script, error) {
+	var out []*script.Script
+	err := r.db.SelectContext(ctx, &out, `
+		SELECT `+scriptColumns+` FROM scripts s
+		WHERE EXISTS (
+			SELECT 1 FROM script_assignments a
+			LEFT JOIN node_group_members m ON m.group_id = a.group_id
+			WHERE a.script_id = s.id AND (a.node_id = $1 OR m.node_id = $1)
+		)
+		ORDER BY name`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
@@ -0,0 +1,138 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	nodegroup "github.com/arturo/autohost-cloud-api/internal/domain/node_group"
+	"github.com/arturo/autohost-cloud-api/internal/domain/script"
+)
+
+func TestScriptRepository(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewScriptRepository(db)
+	userID, orgID := createOrg(t, db, "owner@example.com")
+	_, otherOrg := createOrg(t, db, "other@example.com")
+	web1 := createNode(t, db, orgID, "web-01")
+	web2 := createNode(t, db, orgID, "web-02")
+	db1 := createNode(t, db, orgID, "db-01")
+
+	sumA, sumB := strings.Repeat("a", 64), strings.Repeat("b", 64)
+	create := func(name, sum string) (*script.Script, error) {
+		return repo.Create(ctx,
+			&script.Script{OrganizationID: orgID, Name: name, CreatedBy: &userID},
+			&script.Version{Body: "#!/bin/sh\necho " + name + "\n", Size: 20, SHA256: sum, CreatedBy: &userID})
+	}
+
+	backup, err := create("backup.sh", sumA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if backup.ID == "" || backup.Version != 1 || backup.SHA256 != sumA || backup.CreatedBy == nil {
+		t.Errorf("Create = %+v", backup)
+	}
+	if _, err := create("backup.sh", sumB); !errors.Is(err, script.ErrScriptExists) {
+		t.Errorf("duplicate name error = %v, want ErrScriptExists", err)
+	}
+	if _, err := create("../backup.sh", sumB); err == nil {
+		t.Error("the database accepted a name with a slash")
+	}
+	deploy, err := create("deploy.sh", sumB)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v2, err := repo.AddVersion(ctx, &script.Version{ScriptID: backup.ID, Body: "#!/bin/sh\n", Size: 10, SHA256: sumB})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v2.Version != 2 || v2.Body != "" {
+		t.Errorf("AddVersion = %+v", v2)
+	}
+	if got, err := repo.Find(ctx, orgID, backup.ID); err != nil || got.Version != 2 || got.SHA256 != sumB {
+		t.Errorf("Find after AddVersion = %+v, %v", got, err)
+	}
+	if _, err := repo.Find(ctx, otherOrg, backup.ID); !errors.Is(err, script.ErrScriptNotFound) {
+		t.Errorf("Find in another organization = %v, want ErrScriptNotFound", err)
+	}
+	versions, err := repo.Versions(ctx, backup.ID)
+	if err != nil || len(versions) != 2 || versions[0].Version != 2 || versions[0].Body != "" {
+		t.Errorf("Versions = %+v, %v", versions, err)
+	}
+	if v, err := repo.FindVersion(ctx, backup.ID, 1); err != nil || !strings.Contains(v.Body, "echo backup.sh") {
+		t.Errorf("FindVersion(1) = %+v, %v", v, err)
+	}
+	if _, err := repo.FindVersion(ctx, backup.ID, 3); !errors.Is(err, script.ErrVersionNotFound) {
+		t.Errorf("FindVersion(3) = %v, want ErrVersionNotFound", err)
+	}
+	if list, err := repo.List(ctx, orgID); err != nil || len(list) != 2 || list[0].Name != "backup.sh" {
+		t.Errorf("List = %+v, %v", list, err)
+	}
+
+	groups := NewNodeGroupRepository(db)
+	web, err := groups.Create(ctx, &nodegroup.Group{OrganizationID: orgID, Name: "web"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{web1.ID, web2.ID} {
+		if err := groups.AddNode(ctx, web.ID, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	byGroup, err := repo.Assign(ctx, &script.Assignment{ScriptID: backup.ID, GroupID: &web.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Assign(ctx, &script.Assignment{ScriptID: backup.ID, NodeID: &web1.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Assign(ctx, &script.Assignment{ScriptID: backup.ID, GroupID: &web.ID}); !errors.Is(err, script.ErrAssignmentExists) {
+		t.Errorf("duplicate assignment error = %v, want ErrAssignmentExists", err)
+	}
+	if _, err := repo.Assign(ctx, &script.Assignment{ScriptID: backup.ID, NodeID: &db1.ID, GroupID: &web.ID}); err == nil {
+		t.Error("the database accepted an assignment to a node and a group")
+	}
+	if _, err := repo.Assign(ctx, &script.Assignment{ScriptID: deploy.ID, NodeID: &db1.ID}); err != nil {
+		t.Fatal(err)
+	}
+	if list, err := repo.Assignments(ctx, backup.ID); err != nil || len(list) != 2 || list[0].ID != byGroup.ID {
+		t.Errorf("Assignments = %+v, %v", list, err)
+	}
+
+	want := []string{web1.ID, web2.ID}
+	slices.Sort(want)
+	if ids, err := repo.NodeIDs(ctx, backup.ID); err != nil || !slices.Equal(ids, want) {
+		t.Errorf("NodeIDs = %v, %v, want %v", ids, err, want)
+	}
+	if got, err := repo.ForNode(ctx, web2.ID); err != nil || len(got) != 1 || got[0].Name != "backup.sh" {
+		t.Errorf("ForNode(web-02) = %+v, %v", got, err)
+	}
+	if got, err := repo.ForNode(ctx, db1.ID); err != nil || len(got) != 1 || got[0].Name != "deploy.sh" {
+		t.Errorf("ForNode(db-01) = %+v, %v", got, err)
+	}
+
+	if err := repo.Unassign(ctx, backup.ID, byGroup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Unassign(ctx, backup.ID, byGroup.ID); !errors.Is(err, script.ErrAssignmentNotFound) {
+		t.Errorf("Unassign again = %v, want ErrAssignmentNotFound", err)
+	}
+	if got, err := repo.ForNode(ctx, web2.ID); err != nil || len(got) != 0 {
+		t.Errorf("ForNode(web-02) after Unassign = %+v, %v", got, err)
+	}
+
+	if err := repo.Delete(ctx, otherOrg, backup.ID); !errors.Is(err, script.ErrScriptNotFound) {
+		t.Errorf("Delete from another organization = %v, want ErrScriptNotFound", err)
+	}
+	if err := repo.Delete(ctx, orgID, backup.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.ForNode(ctx, web1.ID); err != nil || len(got) != 0 {
+		t.Errorf("ForNode(web-01) after Delete = %+v, %v", got, err)
+	}
+}
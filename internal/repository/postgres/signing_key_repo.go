@@ -0,0 +1,67 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+	"github.com/jmoiron/sqlx"
+)
+
+// SigningKeyRepository implementa signingkey.Repository usando PostgreSQL
+type SigningKeyRepository struct {
+	db *sqlx.DB
+}
+
+// NewSigningKeyRepository crea una nueva instancia del repositorio
+func NewSigningKeyRepository(db *sqlx.DB) *SigningKeyRepository {
+	return &SigningKeyRepository{db: db}
+}
+
+// FindUsable devuelve las claves que aún no expiraron
+func (r *SigningKeyRepository) FindUsable(ctx context.Context) ([]*signingkey.Key, error) {
+	var keys []*signingkey.Key
+	err := r.db.SelectContext(ctx, &keys, `
+		SELECT id, algorithm, public_key, private_key_encrypted, created_at, activates_at, retired_at, expires_at
+		FROM signing_keys
+		WHERE expires_at IS NULL OR expires_at > now()
+		ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// Rotate programa la retirada de las claves activas en activateAt e inserta
+// la nueva. El advisory lock serializa rotaciones concurrentes de varias
+// instancias.
+func (r *SigningKeyRepository) Rotate(ctx context.Context, next *signingkey.Key, activateAt, verifyUntil time.Time) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext('signing_keys'))`); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE signing_keys
+		SET retired_at = $1, expires_at = $2
+		WHERE retired_at IS NULL`, activateAt, verifyUntil)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowxContext(ctx, `
+		INSERT INTO signing_keys (id, algorithm, public_key, private_key_encrypted, activates_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING created_at`,
+		next.ID, next.Algorithm, next.PublicKey, next.PrivateKeyEncrypted, activateAt,
+	).Scan(&next.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
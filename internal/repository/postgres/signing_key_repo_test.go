@@ -0,0 +1,61 @@
+package postgres
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	signingkey "github.com/arturo/autohost-cloud-api/internal/domain/signing_key"
+)
+
+func TestSigningKeyRepositoryRotate(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	repo := NewSigningKeyRepository(db)
+
+	if keys, err := repo.FindUsable(ctx); err != nil || len(keys) != 0 {
+		t.Fatalf("FindUsable on an empty table = %+v, %v", keys, err)
+	}
+
+	first := &signingkey.Key{ID: "kid-1", Algorithm: "EdDSA", PublicKey: "pem-1", PrivateKeyEncrypted: []byte("sealed-1")}
+	if err := repo.Rotate(ctx, first, time.Now(), time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	sleepTick()
+	activateAt := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	verifyUntil := activateAt.Add(time.Hour)
+	second := &signingkey.Key{ID: "kid-2", Algorithm: "RS256", PublicKey: "pem-2", PrivateKeyEncrypted: []byte("sealed-2")}
+	if err := repo.Rotate(ctx, second, activateAt, verifyUntil); err != nil {
+		t.Fatal(err)
+	}
+
+	// La nueva firma desde activateAt; la anterior se retira entonces y sigue
+	// verificando hasta verifyUntil
+	keys, err := repo.FindUsable(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 || keys[0].ID != "kid-2" || keys[1].ID != "kid-1" {
+		t.Fatalf("FindUsable = %+v", keys)
+	}
+	if !keys[0].ActivatesAt.Equal(activateAt) || keys[0].RetiredAt != nil || keys[0].ExpiresAt != nil || string(keys[0].PrivateKeyEncrypted) != "sealed-2" {
+		t.Errorf("new key = %+v", keys[0])
+	}
+	if keys[1].RetiredAt == nil || !keys[1].RetiredAt.Equal(activateAt) || keys[1].ExpiresAt == nil || !keys[1].ExpiresAt.Equal(verifyUntil) {
+		t.Errorf("retired key = %+v", keys[1])
+	}
+
+	// Al rotar con verifyUntil en el pasado la anterior deja de verificar
+	third := &signingkey.Key{ID: "kid-3", Algorithm: "EdDSA", PublicKey: "pem-3", PrivateKeyEncrypted: []byte("sealed-3")}
+	if err := repo.Rotate(ctx, third, time.Now(), time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	keys, _ = repo.FindUsable(ctx)
+	if len(keys) != 2 || keys[0].ID != "kid-3" || keys[1].ID != "kid-1" {
+		t.Errorf("FindUsable after expiring kid-2 = %+v", keys)
+	}
+
+	if err := repo.Rotate(ctx, &signingkey.Key{ID: "kid-4", Algorithm: "HS256", PublicKey: "x", PrivateKeyEncrypted: []byte("x")}, time.Now(), time.Now()); err == nil {
+		t.Error("Rotate accepted an unsupported algorithm")
+	}
+}
@@ -0,0 +1,231 @@
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/jmoiron/sqlx"
+
+	"github.com/arturo/autohost-cloud-api/internal/domain/node"
+)
+
+// Los tests de integración necesitan un PostgreSQL de usar y tirar. TestMain
+// lo busca en este orden:
+//
+//  1. TEST_DATABASE_URL: un servidor existente; el usuario necesita CREATEDB.
+//  2. initdb y pg_ctl en el PATH o en /usr/lib/postgresql/*/bin: arranca un
+//     cluster temporal que solo escucha en un socket Unix y lo borra al final.
+//
+// Si no hay ninguno, los tests que piden base de datos se saltan. Las
+// migraciones se aplican una sola vez, con NewMigrator como cmd/migrate, a una
+// base plantilla; cada test recibe una copia limpia (CREATE DATABASE ...
+// TEMPLATE), así que pueden ensuciar lo que quieran.
+var testServer struct {
+	baseURL  string // URL del servidor; la base de datos se reemplaza
+	template string
+	admin    *sqlx.DB
+	skip     string // motivo para saltar los tests, si no hay servidor
+	dbs      atomic.Int64
+}
+
+const migrationsDir = "../../../migrations"
+
+func TestMain(m *testing.M) {
+	os.Exit(runTests(m))
+}
+
+func runTests(m *testing.M) int {
+	baseURL := os.Getenv("TEST_DATABASE_URL")
+	if baseURL == "" {
+		local, stop, err := startLocalPostgres()
+		if err != nil {
+			testServer.skip = err.Error()
+			return m.Run()
+		}
+		defer stop()
+		baseURL = local
+	}
+
+	if err := prepareTemplate(baseURL); err != nil {
+		fmt.Fprintf(os.Stderr, "postgres tests: %v\n", err)
+		return 1
+	}
+	defer func() {
+		testServer.admin.Exec(`DROP DATABASE IF EXISTS ` + testServer.template)
+		testServer.admin.Close()
+	}()
+	return m.Run()
+}
+
+// prepareTemplate crea la base plantilla y le aplica migrations/
+func prepareTemplate(baseURL string) error {
+	admin, err := Connect(baseURL)
+	if err != nil {
+		return fmt.Errorf("connect %s: %w", redactURL(baseURL), err)
+	}
+	testServer.baseURL = baseURL
+	testServer.admin = admin
+	testServer.template = fmt.Sprintf("autohost_test_%d", os.Getpid())
+
+	if _, err := admin.Exec(`CREATE DATABASE ` + testServer.template); err != nil {
+		return fmt.Errorf("create template database: %w", err)
+	}
+	m, err := NewMigrator(migrationsDir, databaseURL(testServer.template))
+	if err != nil {
+		return fmt.Errorf("create migrator: %w", err)
+	}
+	defer m.Close()
+	if err := m.Up(); err != nil && !errors.Is(err, migrate.ErrNoChange) {
+		return fmt.Errorf("migrate template database: %w", err)
+	}
+	return nil
+}
+
+// newTestDB devuelve una base de datos recién migrada que se borra al
+// terminar el test
+func newTestDB(t *testing.T) *sqlx.DB {
+	t.Helper()
+	if testServer.skip != "" {
+		t.Skipf("no PostgreSQL available (%s); set TEST_DATABASE_URL or install initdb/pg_ctl", testServer.skip)
+	}
+
+	name := fmt.Sprintf("%s_%d", testServer.template, testServer.dbs.Add(1))
+	if _, err := testServer.admin.Exec(`CREATE DATABASE ` + name + ` TEMPLATE ` + testServer.template); err != nil {
+		t.Fatalf("create test database: %v", err)
+	}
+	db, err := Connect(databaseURL(name))
+	if err != nil {
+		t.Fatalf("connect test database: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if _, err := testServer.admin.Exec(`DROP DATABASE IF EXISTS ` + name); err != nil {
+			t.Errorf("drop test database: %v", err)
+		}
+	})
+	return db
+}
+
+// databaseURL es testServer.baseURL apuntando a la base name
+func databaseURL(name string) string {
+	u, err := url.Parse(testServer.baseURL)
+	if err != nil {
+		panic(err)
+	}
+	u.Path = "/" + name
+	return u.String()
+}
+
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "<invalid url>"
+	}
+	return u.Redacted()
+}
+
+// startLocalPostgres arranca un cluster en un directorio temporal, sin TCP y
+// con autenticación trust, y devuelve su URL y la función que lo para
+func startLocalPostgres() (string, func(), error) {
+	initdb, pgCtl, err := findPostgresBinaries()
+	if err != nil {
+		return "", nil, err
+	}
+	// La ruta del socket tiene un límite de ~100 bytes: mejor /tmp que t.TempDir
+	dir, err := os.MkdirTemp("", "autohost-pg")
+	if err != nil {
+		return "", nil, err
+	}
+	data := filepath.Join(dir, "data")
+	cleanup := func() { os.RemoveAll(dir) }
+
+	out, err := exec.Command(initdb, "-D", data, "-U", "postgres", "--auth=trust",
+		"--encoding=UTF8", "--no-sync").CombinedOutput()
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("initdb: %v: %s", err, out)
+	}
+
+	opts := fmt.Sprintf("-c listen_addresses='' -k %s -F", dir)
+	out, err = exec.Command(pgCtl, "-D", data, "-o", opts, "-l", filepath.Join(dir, "postgres.log"),
+		"-w", "-t", "30", "start").CombinedOutput()
+	if err != nil {
+		cleanup()
+		return "", nil, fmt.Errorf("pg_ctl start: %v: %s", err, out)
+	}
+
+	stop := func() {
+		exec.Command(pgCtl, "-D", data, "-m", "immediate", "-w", "stop").Run()
+		cleanup()
+	}
+	u := url.URL{Scheme: "postgres", User: url.User("postgres"), Path: "/postgres",
+		RawQuery: url.Values{"host": {dir}, "sslmode": {"disable"}}.Encode()}
+	return u.String(), stop, nil
+}
+
+func findPostgresBinaries() (initdb, pgCtl string, err error) {
+	if initdb, err = exec.LookPath("initdb"); err == nil {
+		if pgCtl, err = exec.LookPath("pg_ctl"); err == nil {
+			return initdb, pgCtl, nil
+		}
+	}
+	// Debian/Ubuntu no ponen los binarios del servidor en el PATH
+	dirs, _ := filepath.Glob("/usr/lib/postgresql/*/bin")
+	for i := len(dirs) - 1; i >= 0; i-- {
+		initdb, pgCtl = filepath.Join(dirs[i], "initdb"), filepath.Join(dirs[i], "pg_ctl")
+		if _, err := os.Stat(initdb); err == nil {
+			return initdb, pgCtl, nil
+		}
+	}
+	return "", "", errors.New("initdb and pg_ctl not found")
+}
+
+// Fixtures comunes
+
+func createUser(t *testing.T, db *sqlx.DB, email string) string {
+	t.Helper()
+	id, err := NewAuthRepository(db).CreateUser(context.Background(), email, "Test User", "hash")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return id
+}
+
+// createOrg crea un usuario y su organización personal; devuelve ambos ids
+func createOrg(t *testing.T, db *sqlx.DB, email string) (userID, orgID string) {
+	t.Helper()
+	userID = createUser(t, db, email)
+	org, err := NewOrganizationRepository(db).EnsurePersonal(context.Background(), userID, email)
+	if err != nil {
+		t.Fatalf("create organization: %v", err)
+	}
+	return userID, org.ID
+}
+
+func createNode(t *testing.T, db *sqlx.DB, orgID, hostname string) *node.Node {
+	t.Helper()
+	n, err := NewNodeRepository(db).Register(context.Background(), &node.Node{
+		Hostname:       hostname,
+		IPLocal:        "10.0.0.10",
+		OS:             "linux",
+		Arch:           "amd64",
+		VersionAgent:   "1.0.0",
+		OrganizationID: orgID,
+	})
+	if err != nil {
+		t.Fatalf("register node: %v", err)
+	}
+	return n
+}
+
+// sleepTick separa dos inserciones para que now() cambie entre ellas
+func sleepTick() { time.Sleep(5 * time.Millisecond) }
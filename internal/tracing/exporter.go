@@ -0,0 +1,88 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// JSONExporter escribe cada span como una línea JSON. Pensado para
+// desarrollo y para recoger los spans con el mismo agente que los logs.
+type JSONExporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func NewJSONExporter(w io.Writer) *JSONExporter {
+	return &JSONExporter{enc: json.NewEncoder(w)}
+}
+
+type jsonEvent struct {
+	Name       string         `json:"name"`
+	Time       time.Time      `json:"time"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+}
+
+type jsonSpan struct {
+	TraceID    string         `json:"trace_id"`
+	SpanID     string         `json:"span_id"`
+	ParentID   string         `json:"parent_span_id,omitempty"`
+	Name       string         `json:"name"`
+	Kind       string         `json:"kind"`
+	Start      time.Time      `json:"start"`
+	End        time.Time      `json:"end"`
+	DurationMS float64        `json:"duration_ms"`
+	Status     string         `json:"status"`
+	StatusMsg  string         `json:"status_message,omitempty"`
+	Attributes map[string]any `json:"attributes,omitempty"`
+	Events     []jsonEvent    `json:"events,omitempty"`
+}
+
+func (e *JSONExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, s := range spans {
+		out := jsonSpan{
+			TraceID:    s.SpanContext().TraceID().String(),
+			SpanID:     s.SpanContext().SpanID().String(),
+			Name:       s.Name(),
+			Kind:       s.SpanKind().String(),
+			Start:      s.StartTime(),
+			End:        s.EndTime(),
+			DurationMS: float64(s.EndTime().Sub(s.StartTime()).Microseconds()) / 1000,
+			Status:     s.Status().Code.String(),
+			StatusMsg:  s.Status().Description,
+			Attributes: attrMap(s.Attributes()),
+		}
+		if s.Parent().IsValid() {
+			out.ParentID = s.Parent().SpanID().String()
+		}
+		for _, ev := range s.Events() {
+			out.Events = append(out.Events, jsonEvent{Name: ev.Name, Time: ev.Time, Attributes: attrMap(ev.Attributes)})
+		}
+		if err := e.enc.Encode(out); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *JSONExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func attrMap(kvs []attribute.KeyValue) map[string]any {
+	if len(kvs) == 0 {
+		return nil
+	}
+	m := make(map[string]any, len(kvs))
+	for _, kv := range kvs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
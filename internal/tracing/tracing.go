@@ -0,0 +1,87 @@
+// Package tracing configura OpenTelemetry: el TracerProvider global, el
+// exportador, la propagación W3C trace context y la instrumentación de gRPC y
+// de la base de datos. El middleware HTTP está en handler/middleware.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName identifica a la API en los spans exportados
+const ServiceName = "autohost-cloud-api"
+
+const instrumentationName = "github.com/arturo/autohost-cloud-api"
+
+// Exportadores soportados por NewExporter
+const (
+	ExporterNone   = "none"
+	ExporterStdout = "stdout"
+)
+
+// Tracer devuelve el tracer de la aplicación. Se resuelve contra el provider
+// global en cada llamada, así que sirve el que instale Setup o un test.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// NewExporter crea el exportador con ese nombre: none (nil, no se exporta
+// nada) o stdout (una línea JSON por span en w).
+func NewExporter(name string, w io.Writer) (sdktrace.SpanExporter, error) {
+	switch name {
+	case "", ExporterNone:
+		return nil, nil
+	case ExporterStdout:
+		return NewJSONExporter(w), nil
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", name)
+	}
+}
+
+// Setup instala la propagación W3C y, si hay exportador, un TracerProvider
+// que muestrea la fracción ratio de las trazas nuevas y respeta la decisión
+// de las que llegan de fuera. Sin exportador los spans no se graban, pero el
+// contexto entrante se sigue propagando a los agentes.
+//
+// La función devuelta vacía los spans pendientes; hay que llamarla al salir.
+func Setup(exp sdktrace.SpanExporter, ratio float64) func(context.Context) error {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{}, propagation.Baggage{},
+	))
+	if exp == nil {
+		return func(context.Context) error { return nil }
+	}
+
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exp),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown
+}
+
+// Inject devuelve las cabeceras traceparent y tracestate del span de ctx,
+// para mandarlas a un agente que continúe la traza. Vacías si no hay span.
+func Inject(ctx context.Context) (traceparent, tracestate string) {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent"), carrier.Get("tracestate")
+}
+
+// TraceID devuelve el id de la traza de ctx, o "" si no hay ninguna
+func TraceID(ctx context.Context) string {
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.HasTraceID() {
+		return ""
+	}
+	return sc.TraceID().String()
+}
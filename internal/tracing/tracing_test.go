@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	grpccodes "google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// record instala un provider que guarda en memoria los spans terminados
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	Setup(nil, 1)
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+	return rec
+}
+
+const parent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestUnaryInterceptorContinuesIncomingTrace(t *testing.T) {
+	rec := record(t)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("traceparent", parent))
+	info := &grpc.UnaryServerInfo{FullMethod: "/node_agent.v1.NodeAgentService/Heartbeat"}
+
+	var traceparent string
+	_, err := UnaryServerInterceptor(ctx, nil, info, func(ctx context.Context, _ any) (any, error) {
+		traceparent, _ = Inject(ctx)
+		return nil, status.Error(grpccodes.Internal, "boom")
+	})
+	if status.Code(err) != grpccodes.Internal {
+		t.Fatalf("err = %v", err)
+	}
+
+	spans := rec.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "node_agent.v1.NodeAgentService/Heartbeat" {
+		t.Errorf("name = %q", s.Name())
+	}
+	if got := s.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("parent span = %s", got)
+	}
+	if s.Status().Code != codes.Error {
+		t.Errorf("status = %v, want error", s.Status())
+	}
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") || strings.Contains(traceparent, "00f067aa0ba902b7") {
+		t.Errorf("Inject inside the handler = %q, want the server span of the same trace", traceparent)
+	}
+}
+
+func TestClientErrorsDoNotMarkSpan(t *testing.T) {
+	rec := record(t)
+	info := &grpc.UnaryServerInfo{FullMethod: "/node_agent.v1.NodeAgentService/Register"}
+	UnaryServerInterceptor(context.Background(), nil, info, func(context.Context, any) (any, error) {
+		return nil, status.Error(grpccodes.Unauthenticated, "bad token")
+	})
+	if s := rec.Ended()[0]; s.Status().Code == codes.Error {
+		t.Errorf("status = %v", s.Status())
+	}
+}
+
+func TestInjectWithoutSpan(t *testing.T) {
+	if tp, ts := Inject(context.Background()); tp != "" || ts != "" {
+		t.Errorf("Inject = %q, %q", tp, ts)
+	}
+}
@@ -0,0 +1,17 @@
+ALTER TABLE enroll_tokens DROP COLUMN IF EXISTS organization_id;
+
+DROP INDEX IF EXISTS ux_nodes_org_host;
+
+-- Nodes without an enrolling user cannot go back to the old schema
+DELETE FROM nodes WHERE owner_id IS NULL;
+
+CREATE UNIQUE INDEX ux_nodes_owner_host ON nodes(owner_id, hostname);
+
+ALTER TABLE nodes DROP CONSTRAINT nodes_owner_id_fkey;
+ALTER TABLE nodes ADD CONSTRAINT nodes_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE CASCADE;
+ALTER TABLE nodes ALTER COLUMN owner_id SET NOT NULL;
+ALTER TABLE nodes DROP COLUMN IF EXISTS organization_id;
+
+DROP TABLE IF EXISTS organization_members;
+DROP TABLE IF EXISTS organizations;
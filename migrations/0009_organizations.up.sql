@@ -0,0 +1,53 @@
+-- Organizations: nodes belong to an organization; users join through memberships
+CREATE TABLE organizations (
+    id               UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    name             TEXT        NOT NULL,
+    personal_user_id UUID        UNIQUE REFERENCES users(id) ON DELETE CASCADE, -- set only for personal orgs
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE TABLE organization_members (
+    organization_id UUID        NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    user_id         UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role            TEXT        NOT NULL CHECK (role IN ('owner', 'admin', 'operator', 'viewer')),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (organization_id, user_id)
+);
+
+CREATE INDEX idx_organization_members_user ON organization_members(user_id);
+
+-- Cada usuario existente obtiene una organización personal de la que es owner
+INSERT INTO organizations (name, personal_user_id)
+SELECT COALESCE(NULLIF(u.name, ''), u.email::text), u.id
+FROM users u;
+
+INSERT INTO organization_members (organization_id, user_id, role)
+SELECT o.id, o.personal_user_id, 'owner'
+FROM organizations o
+WHERE o.personal_user_id IS NOT NULL;
+
+-- Los nodos pasan a pertenecer a la organización; owner_id queda como "enrolled by"
+ALTER TABLE nodes ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+UPDATE nodes n
+SET organization_id = o.id
+FROM organizations o
+WHERE o.personal_user_id = n.owner_id;
+
+ALTER TABLE nodes ALTER COLUMN organization_id SET NOT NULL;
+ALTER TABLE nodes ALTER COLUMN owner_id DROP NOT NULL;
+ALTER TABLE nodes DROP CONSTRAINT nodes_owner_id_fkey;
+ALTER TABLE nodes ADD CONSTRAINT nodes_owner_id_fkey
+    FOREIGN KEY (owner_id) REFERENCES users(id) ON DELETE SET NULL;
+
+DROP INDEX IF EXISTS ux_nodes_owner_host;
+CREATE UNIQUE INDEX ux_nodes_org_host ON nodes(organization_id, hostname);
+
+-- Enrollment tokens record the organization the node will join
+ALTER TABLE enroll_tokens ADD COLUMN organization_id UUID REFERENCES organizations(id) ON DELETE CASCADE;
+
+UPDATE enroll_tokens e
+SET organization_id = o.id
+FROM organizations o
+WHERE o.personal_user_id = e.user_id;
@@ -0,0 +1,15 @@
+-- Invitations to join an organization, sent by email
+CREATE TABLE organization_invitations (
+    id              UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID        NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    email           CITEXT      NOT NULL,
+    role            TEXT        NOT NULL CHECK (role IN ('owner', 'admin', 'operator', 'viewer')),
+    token_hash      TEXT        NOT NULL UNIQUE,  -- guarda SOLO el hash del token
+    invited_by      UUID        REFERENCES users(id) ON DELETE SET NULL,
+    expires_at      TIMESTAMPTZ NOT NULL,
+    accepted_at     TIMESTAMPTZ,
+    declined_at     TIMESTAMPTZ,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_organization_invitations_org ON organization_invitations(organization_id);
@@ -0,0 +1,37 @@
+-- Audit log: append-only record of user and node actions.
+-- Each organization has its own hash chain (organization_id NULL = system chain);
+-- hash = sha256(prev_hash || event) so any edited or removed row breaks the chain.
+CREATE TABLE audit_events (
+    id              BIGSERIAL   PRIMARY KEY,
+    occurred_at     TIMESTAMPTZ NOT NULL,
+    organization_id UUID,                    -- sin FK: el log sobrevive al borrado de la organización
+    actor_type      TEXT        NOT NULL CHECK (actor_type IN ('user', 'node', 'system')),
+    actor_id        TEXT        NOT NULL DEFAULT '',
+    action          TEXT        NOT NULL,
+    target_type     TEXT        NOT NULL DEFAULT '',
+    target_id       TEXT        NOT NULL DEFAULT '',
+    outcome         TEXT        NOT NULL CHECK (outcome IN ('success', 'failure')),
+    ip              TEXT        NOT NULL DEFAULT '',
+    request_id      TEXT        NOT NULL DEFAULT '',
+    metadata        JSONB       NOT NULL DEFAULT '{}',
+    prev_hash       TEXT        NOT NULL,
+    hash            TEXT        NOT NULL UNIQUE
+);
+
+CREATE INDEX idx_audit_events_org ON audit_events(organization_id, id DESC);
+CREATE INDEX idx_audit_events_actor ON audit_events(actor_id, id DESC);
+CREATE INDEX idx_audit_events_target ON audit_events(target_type, target_id);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_no_update_delete
+    BEFORE UPDATE OR DELETE ON audit_events
+    FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+CREATE TRIGGER audit_events_no_truncate
+    BEFORE TRUNCATE ON audit_events
+    FOR EACH STATEMENT EXECUTE FUNCTION audit_events_append_only();
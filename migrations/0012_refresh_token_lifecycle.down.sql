@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_refresh_tokens_family;
+DROP INDEX IF EXISTS ux_refresh_tokens_hash;
+
+ALTER TABLE refresh_tokens
+    DROP COLUMN IF EXISTS revoked_reason,
+    DROP COLUMN IF EXISTS expires_at,
+    DROP COLUMN IF EXISTS family_id;
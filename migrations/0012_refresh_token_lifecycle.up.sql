@@ -0,0 +1,17 @@
+-- Refresh tokens: expiración, familias (una por sesión) y motivo de revocación
+ALTER TABLE refresh_tokens
+    ADD COLUMN family_id      UUID,
+    ADD COLUMN expires_at     TIMESTAMPTZ,
+    ADD COLUMN revoked_reason TEXT;
+
+-- Los tokens existentes forman cada uno su propia sesión y caducan a los 30 días
+UPDATE refresh_tokens
+SET family_id  = id,
+    expires_at = created_at + INTERVAL '30 days';
+
+ALTER TABLE refresh_tokens
+    ALTER COLUMN family_id  SET NOT NULL,
+    ALTER COLUMN expires_at SET NOT NULL;
+
+CREATE UNIQUE INDEX ux_refresh_tokens_hash ON refresh_tokens(token_hash);
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family_id);
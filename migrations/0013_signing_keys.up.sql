@@ -0,0 +1,13 @@
+-- Claves asimétricas para firmar access tokens. La privada se guarda cifrada
+-- (AES-256-GCM con JWT_KEY_ENCRYPTION_KEY); la pública se publica en el JWKS.
+CREATE TABLE signing_keys (
+    id                    TEXT        PRIMARY KEY,  -- kid
+    algorithm             TEXT        NOT NULL CHECK (algorithm IN ('RS256', 'EdDSA')),
+    public_key            TEXT        NOT NULL,     -- PEM
+    private_key_encrypted BYTEA       NOT NULL,
+    created_at            TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at            TIMESTAMPTZ,              -- deja de firmar
+    expires_at            TIMESTAMPTZ               -- deja de verificar
+);
+
+CREATE INDEX idx_signing_keys_created ON signing_keys(created_at DESC);
@@ -0,0 +1,15 @@
+-- API keys personales para automatización (CI). Solo se guarda el hash.
+CREATE TABLE api_keys (
+    id           UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id      UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL,
+    prefix       TEXT        NOT NULL,              -- inicio visible de la clave, para identificarla
+    key_hash     TEXT        NOT NULL UNIQUE,
+    scopes       TEXT[]      NOT NULL DEFAULT '{}', -- vacío = todos los permisos del usuario
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_api_keys_user ON api_keys(user_id);
@@ -0,0 +1,30 @@
+-- Autenticación en dos pasos (TOTP)
+ALTER TABLE users
+    ADD COLUMN totp_secret_encrypted BYTEA,       -- AES-256-GCM, pendiente hasta totp_enabled_at
+    ADD COLUMN totp_enabled_at       TIMESTAMPTZ,
+    ADD COLUMN totp_last_step        BIGINT;      -- último intervalo usado, evita reutilizar un código
+
+CREATE TABLE mfa_recovery_codes (
+    id         UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    code_hash  TEXT        NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, code_hash)
+);
+
+-- Segundo paso del login: token de corta duración emitido tras la contraseña
+CREATE TABLE mfa_challenges (
+    id         UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    token_hash TEXT        NOT NULL UNIQUE,
+    attempts   INT         NOT NULL DEFAULT 0,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_mfa_challenges_user ON mfa_challenges(user_id);
+
+ALTER TABLE organizations
+    ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT false;
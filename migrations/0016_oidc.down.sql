@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS user_identities;
+
+-- Falla si quedan usuarios creados por SSO sin contraseña
+ALTER TABLE users ALTER COLUMN password_hash SET NOT NULL;
@@ -0,0 +1,24 @@
+-- Login con OIDC: los usuarios creados por SSO no tienen contraseña
+ALTER TABLE users ALTER COLUMN password_hash DROP NOT NULL;
+
+-- Identidades externas vinculadas a un usuario
+CREATE TABLE user_identities (
+    id         UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer     TEXT        NOT NULL,
+    subject    TEXT        NOT NULL,
+    email      CITEXT      NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (issuer, subject)
+);
+
+CREATE INDEX idx_user_identities_user ON user_identities(user_id);
+
+-- Estado de un login OIDC en curso (state de un solo uso, PKCE y nonce)
+CREATE TABLE oidc_login_states (
+    state_hash    TEXT        PRIMARY KEY,
+    code_verifier TEXT        NOT NULL,
+    nonce         TEXT        NOT NULL,
+    expires_at    TIMESTAMPTZ NOT NULL,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,18 @@
+-- Verificación de email y restablecimiento de contraseña
+ALTER TABLE users ADD COLUMN email_verified_at TIMESTAMPTZ;
+
+-- Los usuarios existentes se registraron antes de que hubiera verificación
+UPDATE users SET email_verified_at = created_at;
+
+-- Tokens de un solo uso enviados por email (solo se guarda el hash)
+CREATE TABLE user_action_tokens (
+    id         UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id    UUID        NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    purpose    TEXT        NOT NULL CHECK (purpose IN ('email_verification', 'password_reset')),
+    token_hash TEXT        NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_user_action_tokens_user ON user_action_tokens(user_id, purpose);
@@ -0,0 +1,16 @@
+-- Token buckets compartidos entre réplicas (clave: "login:ip:1.2.3.4", ...)
+CREATE TABLE rate_limit_buckets (
+    key        TEXT             PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ      NOT NULL DEFAULT now()
+);
+
+CREATE INDEX idx_rate_limit_buckets_updated ON rate_limit_buckets(updated_at);
+
+-- Bloqueo de cuentas tras logins fallidos consecutivos
+CREATE TABLE login_lockouts (
+    key             TEXT        PRIMARY KEY,
+    failed_attempts INT         NOT NULL DEFAULT 0,
+    locked_until    TIMESTAMPTZ,
+    last_failed_at  TIMESTAMPTZ NOT NULL DEFAULT now()
+);
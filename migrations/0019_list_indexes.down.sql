@@ -0,0 +1,7 @@
+DROP INDEX IF EXISTS idx_nodes_hostname_trgm;
+DROP INDEX IF EXISTS idx_nodes_org_last_seen;
+DROP INDEX IF EXISTS idx_nodes_org_hostname;
+DROP INDEX IF EXISTS idx_nodes_org_created;
+DROP INDEX IF EXISTS idx_jobs_node_command_name;
+DROP INDEX IF EXISTS idx_jobs_node_status_created;
+DROP INDEX IF EXISTS idx_jobs_node_created;
@@ -0,0 +1,13 @@
+-- Índices para los listados paginados por keyset (campo, id) de jobs y nodos.
+-- jobs no tenía ninguno: cada listado de un nodo recorría la tabla entera.
+CREATE INDEX idx_jobs_node_created ON jobs(node_id, created_at DESC, id DESC);
+CREATE INDEX idx_jobs_node_status_created ON jobs(node_id, status, created_at DESC, id DESC);
+CREATE INDEX idx_jobs_node_command_name ON jobs(node_id, command_name, id);
+
+CREATE INDEX idx_nodes_org_created ON nodes(organization_id, created_at DESC, id DESC);
+CREATE INDEX idx_nodes_org_hostname ON nodes(organization_id, hostname, id);
+CREATE INDEX idx_nodes_org_last_seen ON nodes(organization_id, COALESCE(last_seen_at, 'epoch'::timestamptz), id);
+
+-- Búsqueda por subcadena del hostname (ILIKE '%texto%')
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+CREATE INDEX idx_nodes_hostname_trgm ON nodes USING gin (hostname gin_trgm_ops);
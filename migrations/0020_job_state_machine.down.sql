@@ -0,0 +1 @@
+ALTER TABLE jobs DROP CONSTRAINT IF EXISTS jobs_status_timestamps;
@@ -0,0 +1,26 @@
+-- Máquina de estados de los jobs: pending -> running -> completed | failed
+-- (o pending -> completed | failed directamente). Hasta ahora un mensaje
+-- tardío podía dejar filas incoherentes; se corrigen antes de la restricción.
+
+-- Un "running" tardío sobre un job terminado: vuelve a su estado final
+UPDATE jobs
+SET status = CASE WHEN COALESCE(error, '') = '' THEN 'completed' ELSE 'failed' END
+WHERE status IN ('pending', 'running') AND finished_at IS NOT NULL;
+
+-- Un "pending" reportado sobre un job en marcha
+UPDATE jobs SET status = 'running' WHERE status = 'pending' AND started_at IS NOT NULL;
+
+-- Jobs terminados sin finished_at
+UPDATE jobs SET finished_at = COALESCE(started_at, created_at)
+WHERE status IN ('completed', 'failed') AND finished_at IS NULL;
+
+-- Jobs terminados sin started_at, o con un started_at posterior al final
+UPDATE jobs SET started_at = finished_at
+WHERE finished_at IS NOT NULL AND (started_at IS NULL OR started_at > finished_at);
+
+ALTER TABLE jobs ADD CONSTRAINT jobs_status_timestamps CHECK (
+    (status = 'pending' AND started_at IS NULL AND finished_at IS NULL) OR
+    (status = 'running' AND started_at IS NOT NULL AND finished_at IS NULL) OR
+    (status IN ('completed', 'failed') AND started_at IS NOT NULL AND finished_at IS NOT NULL
+        AND finished_at >= started_at)
+);
@@ -0,0 +1,5 @@
+ALTER TABLE jobs
+    DROP CONSTRAINT IF EXISTS jobs_output_blob_key,
+    DROP COLUMN IF EXISTS output_blob,
+    DROP COLUMN IF EXISTS output_truncated,
+    DROP COLUMN IF EXISTS output_size;
@@ -0,0 +1,12 @@
+-- Salida grande de los jobs: la fila guarda como mucho el límite inline y el
+-- resto va al almacén de blobs, direccionado por el SHA-256 del contenido.
+ALTER TABLE jobs
+    ADD COLUMN output_size BIGINT NOT NULL DEFAULT 0,
+    ADD COLUMN output_truncated BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN output_blob TEXT;
+
+-- Hasta ahora la salida se guardaba entera
+UPDATE jobs SET output_size = octet_length(output) WHERE output IS NOT NULL;
+
+ALTER TABLE jobs ADD CONSTRAINT jobs_output_blob_key
+    CHECK (output_blob IS NULL OR output_blob ~ '^[0-9a-f]{64}$');
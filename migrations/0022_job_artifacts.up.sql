@@ -0,0 +1,17 @@
+-- Ficheros que los agentes suben para un job (volcados, diagnósticos). El
+-- contenido va al almacén de blobs direccionado por sha256; aquí solo los
+-- metadatos. Tras expires_at ya no se puede descargar y se purga.
+CREATE TABLE job_artifacts (
+    id           UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    job_id       UUID        NOT NULL REFERENCES jobs(id) ON DELETE CASCADE,
+    name         TEXT        NOT NULL CHECK (name <> '' AND name !~ '[/\\]'),
+    content_type TEXT        NOT NULL,
+    size         BIGINT      NOT NULL CHECK (size >= 0),
+    sha256       TEXT        NOT NULL CHECK (sha256 ~ '^[0-9a-f]{64}$'),
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    expires_at   TIMESTAMPTZ NOT NULL,
+    UNIQUE (job_id, name)
+);
+
+CREATE INDEX idx_job_artifacts_expires ON job_artifacts(expires_at);
+CREATE INDEX idx_job_artifacts_sha256 ON job_artifacts(sha256);
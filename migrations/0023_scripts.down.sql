@@ -0,0 +1,5 @@
+DROP TABLE IF EXISTS script_assignments;
+DROP TABLE IF EXISTS script_versions;
+DROP TABLE IF EXISTS scripts;
+DROP TABLE IF EXISTS node_group_members;
+DROP TABLE IF EXISTS node_groups;
@@ -0,0 +1,58 @@
+-- Grupos de nodos de una organización, para asignarles scripts de una vez
+CREATE TABLE node_groups (
+    id              UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID        NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name            TEXT        NOT NULL CHECK (name <> ''),
+    description     TEXT        NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE node_group_members (
+    group_id UUID NOT NULL REFERENCES node_groups(id) ON DELETE CASCADE,
+    node_id  UUID NOT NULL REFERENCES nodes(id) ON DELETE CASCADE,
+    PRIMARY KEY (group_id, node_id)
+);
+
+CREATE INDEX idx_node_group_members_node ON node_group_members(node_id);
+
+-- Biblioteca de scripts. Cada cambio del cuerpo es una versión nueva; version
+-- y sha256 repiten los de la última para no buscarla en cada manifiesto.
+CREATE TABLE scripts (
+    id              UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    organization_id UUID        NOT NULL REFERENCES organizations(id) ON DELETE CASCADE,
+    name            TEXT        NOT NULL CHECK (name ~ '^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$'),
+    description     TEXT        NOT NULL DEFAULT '',
+    version         INT         NOT NULL CHECK (version > 0),
+    sha256          TEXT        NOT NULL CHECK (sha256 ~ '^[0-9a-f]{64}$'),
+    created_by      UUID        REFERENCES users(id) ON DELETE SET NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (organization_id, name)
+);
+
+CREATE TABLE script_versions (
+    script_id  UUID        NOT NULL REFERENCES scripts(id) ON DELETE CASCADE,
+    version    INT         NOT NULL CHECK (version > 0),
+    body       TEXT        NOT NULL,
+    size       BIGINT      NOT NULL CHECK (size >= 0),
+    sha256     TEXT        NOT NULL CHECK (sha256 ~ '^[0-9a-f]{64}$'),
+    created_by UUID        REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (script_id, version)
+);
+
+-- Un script se asigna a un nodo o a un grupo, nunca a los dos a la vez
+CREATE TABLE script_assignments (
+    id         UUID        PRIMARY KEY DEFAULT gen_random_uuid(),
+    script_id  UUID        NOT NULL REFERENCES scripts(id) ON DELETE CASCADE,
+    node_id    UUID        REFERENCES nodes(id) ON DELETE CASCADE,
+    group_id   UUID        REFERENCES node_groups(id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    CHECK (num_nonnulls(node_id, group_id) = 1),
+    UNIQUE (script_id, node_id),
+    UNIQUE (script_id, group_id)
+);
+
+CREATE INDEX idx_script_assignments_node ON script_assignments(node_id);
+CREATE INDEX idx_script_assignments_group ON script_assignments(group_id);
@@ -0,0 +1,5 @@
+-- El state de un login OIDC queda ligado al navegador que lo inició: se
+-- guarda el hash del secreto que viaja en su cookie. Los logins en curso se
+-- descartan; caducan en minutos.
+DELETE FROM oidc_login_states;
+ALTER TABLE oidc_login_states ADD COLUMN browser_hash TEXT NOT NULL;
@@ -0,0 +1,4 @@
+-- Una clave nueva solo verifica hasta activates_at, para que todas las
+-- instancias y los consumidores del JWKS la conozcan antes de que firme.
+-- retired_at pasa a poder estar en el futuro: la clave firma hasta entonces.
+ALTER TABLE signing_keys ADD COLUMN activates_at TIMESTAMPTZ NOT NULL DEFAULT now();